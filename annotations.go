@@ -0,0 +1,511 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Annotation はURL全体または訪問1件に付けたタグとメモ
+type Annotation struct {
+	URL string `json:"url"`
+	// VisitID は訪問に付けた場合の history_visits.id（URL全体に付けた場合は省く）
+	VisitID int64    `json:"visit_id,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Note    string   `json:"note,omitempty"`
+}
+
+// TagCount はタグと、そのタグを付けたURL・訪問の数
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TagMatch は -tag で絞り込む、タグを付けたURL・訪問
+type TagMatch struct {
+	Tag string
+	// URLs はURL全体にタグを付けたURL
+	URLs []string
+	// VisitIDs は訪問にタグを付けた history_visits.id
+	VisitIDs []int64
+	// urls は URLs と VisitIDs の訪問のURL（Go側で集計する統計で使う）
+	urls map[string]bool
+}
+
+// normalizeTag はタグを小文字にし、先頭の # を除く（空白・カンマを含むタグはエラー）
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" {
+		return "", errors.New("タグが空です")
+	}
+	if strings.ContainsFunc(tag, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		return "", fmt.Errorf("タグに空白・カンマは使えません: %s", tag)
+	}
+	return tag, nil
+}
+
+// validAnnotationURL はタグ・メモを付けられるURLかを確かめる
+func validAnnotationURL(rawURL string) error {
+	if rawURL == "" || rawURL == redactedLabel {
+		return fmt.Errorf("タグ・メモを付けられないURLです: %q", rawURL)
+	}
+	return nil
+}
+
+// AddTags は rawURL（visitID が 0 でなければその訪問）にタグを付け、新しく付けた数を返す
+func (s *SidecarDB) AddTags(rawURL string, visitID int64, tags []string, now time.Time) (int, error) {
+	if err := validAnnotationURL(rawURL); err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return added, err
+		}
+		res, err := s.db.Exec(`INSERT OR IGNORE INTO tags (url, visit_id, tag, added_at) VALUES (?, ?, ?, ?)`,
+			rawURL, visitID, tag, now.UTC().Format(time.RFC3339))
+		if err != nil {
+			return added, fmt.Errorf("タグの追加に失敗: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// RemoveTags は rawURL（visitID が 0 でなければその訪問）からタグを外し、外した数を返す
+func (s *SidecarDB) RemoveTags(rawURL string, visitID int64, tags []string) (int, error) {
+	removed := 0
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return removed, err
+		}
+		res, err := s.db.Exec(`DELETE FROM tags WHERE url = ? AND visit_id = ? AND tag = ?`, rawURL, visitID, tag)
+		if err != nil {
+			return removed, fmt.Errorf("タグの削除に失敗: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// SetNote は rawURL（visitID が 0 でなければその訪問）のメモを書き換える（空なら消す）
+func (s *SidecarDB) SetNote(rawURL string, visitID int64, note string, now time.Time) error {
+	if err := validAnnotationURL(rawURL); err != nil {
+		return err
+	}
+	var err error
+	if note = strings.TrimSpace(note); note == "" {
+		_, err = s.db.Exec(`DELETE FROM notes WHERE url = ? AND visit_id = ?`, rawURL, visitID)
+	} else {
+		_, err = s.db.Exec(`INSERT INTO notes (url, visit_id, note, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (url, visit_id) DO UPDATE SET note = excluded.note, updated_at = excluded.updated_at`,
+			rawURL, visitID, note, now.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return fmt.Errorf("メモの保存に失敗: %w", err)
+	}
+	return nil
+}
+
+// ExpireAnnotations は before より前に付けたタグ・最後に更新したメモを削除し、件数を返す（dryRun なら数えるだけ）
+func (s *SidecarDB) ExpireAnnotations(before time.Time, dryRun bool) (tags, notes int, err error) {
+	cutoff := before.UTC().Format(time.RFC3339)
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM tags WHERE added_at < ?`, cutoff).Scan(&tags); err != nil {
+		return 0, 0, fmt.Errorf("タグの件数の取得に失敗: %w", err)
+	}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM notes WHERE updated_at < ?`, cutoff).Scan(&notes); err != nil {
+		return 0, 0, fmt.Errorf("メモの件数の取得に失敗: %w", err)
+	}
+	if dryRun || tags+notes == 0 {
+		return tags, notes, nil
+	}
+	if _, err := s.db.Exec(`DELETE FROM tags WHERE added_at < ?`, cutoff); err != nil {
+		return 0, 0, fmt.Errorf("タグの削除に失敗: %w", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM notes WHERE updated_at < ?`, cutoff); err != nil {
+		return 0, 0, fmt.Errorf("メモの削除に失敗: %w", err)
+	}
+	// 削除したタグ・メモがファイルに残らないよう詰める
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return 0, 0, fmt.Errorf("サイドカーDBの整理に失敗: %w", err)
+	}
+	return tags, notes, nil
+}
+
+// Annotations は rawURL とその訪問に付けたタグ・メモを返す（URL全体のものが先、訪問は ID 順）
+func (s *SidecarDB) Annotations(rawURL string) ([]Annotation, error) {
+	byVisit := make(map[int64]*Annotation)
+	get := func(visitID int64) *Annotation {
+		if a, ok := byVisit[visitID]; ok {
+			return a
+		}
+		a := &Annotation{URL: rawURL, VisitID: visitID}
+		byVisit[visitID] = a
+		return a
+	}
+
+	rows, err := s.db.Query(`SELECT visit_id, tag FROM tags WHERE url = ? ORDER BY tag`, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("タグの取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var visitID int64
+		var tag string
+		if err := rows.Scan(&visitID, &tag); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		a := get(visitID)
+		a.Tags = append(a.Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT visit_id, note FROM notes WHERE url = ?`, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("メモの取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var visitID int64
+		var note string
+		if err := rows.Scan(&visitID, &note); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		get(visitID).Note = note
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	annotations := make([]Annotation, 0, len(byVisit))
+	for _, a := range byVisit {
+		annotations = append(annotations, *a)
+	}
+	sort.Slice(annotations, func(i, j int) bool { return annotations[i].VisitID < annotations[j].VisitID })
+	return annotations, nil
+}
+
+// TagCounts は全てのタグと、付けたURL・訪問の数を返す（多い順）
+func (s *SidecarDB) TagCounts() ([]TagCount, error) {
+	rows, err := s.db.Query(`SELECT tag, COUNT(*) FROM tags GROUP BY tag ORDER BY COUNT(*) DESC, tag`)
+	if err != nil {
+		return nil, fmt.Errorf("タグの集計に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	counts := []TagCount{}
+	for rows.Next() {
+		var c TagCount
+		if err := rows.Scan(&c.Tag, &c.Count); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// TagMatch は tag を付けたURL・訪問を返す
+func (s *SidecarDB) TagMatch(tag string) (*TagMatch, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	m := &TagMatch{Tag: tag, urls: make(map[string]bool)}
+	if s == nil {
+		return m, nil
+	}
+	rows, err := s.db.Query(`SELECT url, visit_id FROM tags WHERE tag = ?`, tag)
+	if err != nil {
+		return nil, fmt.Errorf("タグの取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var rawURL string
+		var visitID int64
+		if err := rows.Scan(&rawURL, &visitID); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if visitID == 0 {
+			m.URLs = append(m.URLs, rawURL)
+		} else {
+			m.VisitIDs = append(m.VisitIDs, visitID)
+		}
+		for _, v := range urlVariants(rawURL) {
+			m.urls[v] = true
+		}
+	}
+	return m, rows.Err()
+}
+
+// loadTagMatch はサイドカーDBから tag を付けたURL・訪問を読み込む（DBがまだなければ一致なし）
+func loadTagMatch(path, tag string) (*TagMatch, error) {
+	sidecar, err := openExistingSidecarDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if sidecar != nil {
+		defer func() { _ = sidecar.Close() }()
+	}
+	return sidecar.TagMatch(tag)
+}
+
+// matchesURL はURLにタグを付けたか（URLのどれかの訪問に付けた場合も含む）を返す
+// SQLを使わずGo側で集計する統計（ドメイン別など）で使う
+func (m *TagMatch) matchesURL(u string) bool {
+	return m.urls[u]
+}
+
+// WithTagMatch はタグを付けたURL・訪問に絞り込む条件を追加
+func (qb *QueryBuilder) WithTagMatch(m *TagMatch) *QueryBuilder {
+	if m == nil {
+		return qb
+	}
+	var conds []string
+	if len(m.URLs) > 0 {
+		var urls []interface{}
+		for _, u := range m.URLs {
+			for _, v := range urlVariants(u) {
+				urls = append(urls, v)
+			}
+		}
+		conds = append(conds, `hi.url IN (`+inPlaceholders(len(urls))+`)`)
+		qb.args = append(qb.args, urls...)
+	}
+	if len(m.VisitIDs) > 0 {
+		conds = append(conds, `hv.id IN (`+inPlaceholders(len(m.VisitIDs))+`)`)
+		for _, id := range m.VisitIDs {
+			qb.args = append(qb.args, id)
+		}
+	}
+	if len(conds) == 0 {
+		// タグを付けたものがなければ何も一致しない
+		qb.where.WriteString(` AND 0`)
+		return qb
+	}
+	qb.where.WriteString(` AND (` + strings.Join(conds, ` OR `) + `)`)
+	return qb
+}
+
+// printAnnotations はタグ・メモを出力する
+func printAnnotations(w io.Writer, annotations []Annotation) {
+	if len(annotations) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "🏷  タグ・メモ\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, a := range annotations {
+		target := "URL全体"
+		if a.VisitID != 0 {
+			target = fmt.Sprintf("訪問 #%d", a.VisitID)
+		}
+		_, _ = fmt.Fprintf(w, "  %s", target)
+		for _, tag := range a.Tags {
+			_, _ = fmt.Fprintf(w, "  #%s", tag)
+		}
+		_, _ = fmt.Fprintln(w)
+		if a.Note != "" {
+			for _, line := range strings.Split(a.Note, "\n") {
+				_, _ = fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// resolveAnnotationTarget は -id の訪問、または引数の先頭のURLを、タグ・メモを付ける対象にする
+// 返り値はURL・訪問ID（URL全体なら 0）・残りの引数
+func resolveAnnotationTarget(visitID int64, args []string) (string, int64, []string, error) {
+	if visitID <= 0 {
+		if len(args) == 0 {
+			return "", 0, nil, errors.New("URLを指定してください")
+		}
+		return args[0], 0, args[1:], nil
+	}
+	db, err := setupDatabase()
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer func() { _ = db.Close() }()
+	visit, _, err := getVisitByID(db, visitID)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return visit.URL, visit.ID, args, nil
+}
+
+// runTagCommand は hist tag サブコマンドを実行する
+func runTagCommand(args []string) error {
+	usage := "使い方: hist tag add [-id 訪問ID] [<URL>] <タグ>... | remove [-id 訪問ID] [<URL>] <タグ>... | list [-json]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	path, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "add", "remove":
+		fs := flag.NewFlagSet("tag "+action, flag.ContinueOnError)
+		visitID := fs.Int64("id", 0, "URL全体ではなく、この訪問ID（-json・-csv・-ndjson の id）の訪問に付ける")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		rawURL, id, tags, err := resolveAnnotationTarget(*visitID, fs.Args())
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return errors.New("タグを1つ以上指定してください")
+		}
+		sidecar, err := openSidecarDB(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = sidecar.Close() }()
+		if action == "add" {
+			n, err := sidecar.AddTags(rawURL, id, tags, time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("タグを%d件付けました: %s\n", n, rawURL)
+			return nil
+		}
+		n, err := sidecar.RemoveTags(rawURL, id, tags)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("タグを%d件外しました: %s\n", n, rawURL)
+		return nil
+
+	case "list":
+		fs := flag.NewFlagSet("tag list", flag.ContinueOnError)
+		jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		counts := []TagCount{}
+		sidecar, err := openExistingSidecarDB(path)
+		if err != nil {
+			return err
+		}
+		if sidecar != nil {
+			defer func() { _ = sidecar.Close() }()
+			if counts, err = sidecar.TagCounts(); err != nil {
+				return err
+			}
+		}
+		if *jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(counts)
+		}
+		if len(counts) == 0 {
+			fmt.Println("タグはまだありません")
+			return nil
+		}
+		for _, c := range counts {
+			fmt.Printf("  #%-20s %d\n", c.Tag, c.Count)
+		}
+		return nil
+	}
+	return errors.New(usage)
+}
+
+// runNoteCommand は hist note サブコマンドを実行する
+func runNoteCommand(args []string) error {
+	fs := flag.NewFlagSet("note", flag.ContinueOnError)
+	visitID := fs.Int64("id", 0, "URL全体ではなく、この訪問ID（-json・-csv・-ndjson の id）の訪問に付ける")
+	clearNote := fs.Bool("clear", false, "メモを消す")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist note [オプション] <URL> <メモ>\n")
+		fmt.Fprintf(fs.Output(), "        hist note -id <訪問ID> <メモ>\n\n")
+		fmt.Fprintf(fs.Output(), "  URL または訪問にメモを付けます（hist show・インタラクティブモード・Web の詳細に表示）\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	rawURL, id, rest, err := resolveAnnotationTarget(*visitID, fs.Args())
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	note := strings.Join(rest, " ")
+	if *clearNote {
+		note = ""
+	} else if note == "" {
+		return errors.New("メモを指定してください（消すときは -clear）")
+	}
+
+	path, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sidecar.Close() }()
+	if err := sidecar.SetNote(rawURL, id, note, time.Now()); err != nil {
+		return err
+	}
+	if note == "" {
+		fmt.Printf("メモを消しました: %s\n", rawURL)
+	} else {
+		fmt.Printf("メモを保存しました: %s\n", rawURL)
+	}
+	return nil
+}
+
+// loadAnnotations はサイドカーDBから rawURL のタグ・メモを読み込む（DBがまだなければ空）
+func loadAnnotations(path, rawURL string) ([]Annotation, error) {
+	sidecar, err := openExistingSidecarDB(path)
+	if err != nil || sidecar == nil {
+		return nil, err
+	}
+	defer func() { _ = sidecar.Close() }()
+	return sidecar.Annotations(rawURL)
+}
+
+// handleAPIAnnotations は GET /api/annotations?url=... で URL とその訪問のタグ・メモを返す
+func (s *WebServer) handleAPIAnnotations(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+	if err := validAnnotationURL(rawURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// リダクト対象のURLのタグ・メモは見せない
+	if domainMatchesList(extractDomain(rawURL), s.redactList()) {
+		http.Error(w, "リダクト対象のURLです", http.StatusNotFound)
+		return
+	}
+	annotations, err := loadAnnotations(s.sidecarPath, rawURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if annotations == nil {
+		annotations = []Annotation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(annotations); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{"research", "research", false},
+		{" #Research ", "research", false},
+		{"読書", "読書", false},
+		{"", "", true},
+		{"#", "", true},
+		{"two words", "", true},
+		{"a,b", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeTag(tt.tag)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeTag(%q) = %q, %v, want %q", tt.tag, got, err, tt.want)
+		}
+	}
+}
+
+func TestSidecarAnnotations(t *testing.T) {
+	sidecar, err := openSidecarDB(filepath.Join(t.TempDir(), "hist", "hist.db"))
+	if err != nil {
+		t.Fatalf("openSidecarDB() error = %v", err)
+	}
+	defer func() { _ = sidecar.Close() }()
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	const u = "https://go.dev/blog/"
+
+	if n, err := sidecar.AddTags(u, 0, []string{"go", "#Research", "go"}, now); err != nil || n != 2 {
+		t.Errorf("AddTags() = %d, %v, want 2", n, err)
+	}
+	if _, err := sidecar.AddTags(u, 42, []string{"research", "todo"}, now); err != nil {
+		t.Fatalf("AddTags(訪問) error = %v", err)
+	}
+	if err := sidecar.SetNote(u, 42, " 読み返す \n", now); err != nil {
+		t.Fatalf("SetNote() error = %v", err)
+	}
+	if err := sidecar.SetNote(u, 0, "Go のブログ", now); err != nil {
+		t.Fatalf("SetNote() error = %v", err)
+	}
+	if err := sidecar.SetNote(u, 0, "", now); err != nil {
+		t.Fatalf("SetNote(空) error = %v", err)
+	}
+	if _, err := sidecar.AddTags(redactedLabel, 0, []string{"x"}, now); err == nil {
+		t.Error("リダクトしたURLにタグを付けられる")
+	}
+
+	annotations, err := sidecar.Annotations(u)
+	if err != nil {
+		t.Fatalf("Annotations() error = %v", err)
+	}
+	if len(annotations) != 2 ||
+		annotations[0].VisitID != 0 || strings.Join(annotations[0].Tags, ",") != "go,research" || annotations[0].Note != "" ||
+		annotations[1].VisitID != 42 || strings.Join(annotations[1].Tags, ",") != "research,todo" || annotations[1].Note != "読み返す" {
+		t.Errorf("Annotations() = %+v", annotations)
+	}
+
+	counts, err := sidecar.TagCounts()
+	if err != nil {
+		t.Fatalf("TagCounts() error = %v", err)
+	}
+	if len(counts) != 3 || counts[0] != (TagCount{Tag: "research", Count: 2}) {
+		t.Errorf("TagCounts() = %+v", counts)
+	}
+
+	if n, err := sidecar.RemoveTags(u, 0, []string{"go", "missing"}); err != nil || n != 1 {
+		t.Errorf("RemoveTags() = %d, %v, want 1", n, err)
+	}
+	m, err := sidecar.TagMatch("research")
+	if err != nil {
+		t.Fatalf("TagMatch() error = %v", err)
+	}
+	if len(m.URLs) != 1 || len(m.VisitIDs) != 1 || m.VisitIDs[0] != 42 || !m.matchesURL("https://go.dev/blog") {
+		t.Errorf("TagMatch() = %+v", m)
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	path := filepath.Join(t.TempDir(), "hist.db")
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		t.Fatalf("openSidecarDB() error = %v", err)
+	}
+	// github.com/test はURL全体、youtube は2025-01-02の訪問（ID 5）だけに付ける
+	now := time.Now()
+	if _, err := sidecar.AddTags("https://github.com/test/", 0, []string{"research"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sidecar.AddTags("https://youtube.com/watch", 5, []string{"research"}, now); err != nil {
+		t.Fatal(err)
+	}
+	_ = sidecar.Close()
+
+	match, err := loadTagMatch(path, "research")
+	if err != nil {
+		t.Fatalf("loadTagMatch() error = %v", err)
+	}
+	visits, err := getRecentVisits(db, 10, SearchFilter{Tag: match})
+	if err != nil {
+		t.Fatalf("getRecentVisits失敗: %v", err)
+	}
+	var ids []int64
+	for _, v := range visits {
+		ids = append(ids, v.ID)
+	}
+	if len(ids) != 3 || ids[0] != 5 || ids[1] != 4 || ids[2] != 1 {
+		t.Errorf("タグで絞り込んだ訪問のID = %v, want [5 4 1]", ids)
+	}
+
+	stats, err := getDomainStats(db, 10, SearchFilter{Tag: match})
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Errorf("タグで絞り込んだドメイン統計 = %+v", stats)
+	}
+
+	// タグを付けたものがなければ何も一致しない（サイドカーDBがなくてもよい）
+	empty, err := loadTagMatch(filepath.Join(t.TempDir(), "none.db"), "research")
+	if err != nil {
+		t.Fatalf("loadTagMatch() error = %v", err)
+	}
+	if visits, err := getRecentVisits(db, 10, SearchFilter{Tag: empty}); err != nil || len(visits) != 0 {
+		t.Errorf("一致なしのタグで %d件, %v", len(visits), err)
+	}
+	if summary := summarizeFilter(SearchFilter{Tag: match}); summary.Tag != "research" {
+		t.Errorf("summarizeFilter().Tag = %q", summary.Tag)
+	}
+}
+
+func TestHandleAPIAnnotations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hist.db")
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		t.Fatalf("openSidecarDB() error = %v", err)
+	}
+	now := time.Now()
+	for _, u := range []string{"https://go.dev/blog/", "https://secret.example/a"} {
+		if _, err := sidecar.AddTags(u, 0, []string{"research"}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = sidecar.Close()
+
+	s := &WebServer{sidecarPath: path, redactDomains: []string{"secret.example"}}
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleAPIAnnotations(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/api/annotations?url=https://go.dev/blog/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"tags":["research"]`) {
+		t.Errorf("ステータス = %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := get("/api/annotations?url=https://none.example/").Body.String(); strings.TrimSpace(body) != "[]" {
+		t.Errorf("タグのないURL = %s", body)
+	}
+	if rec := get("/api/annotations?url=https://secret.example/a"); rec.Code != http.StatusNotFound {
+		t.Errorf("リダクト対象のステータス = %d, want 404", rec.Code)
+	}
+	if rec := get("/api/annotations"); rec.Code != http.StatusBadRequest {
+		t.Errorf("URLなしのステータス = %d, want 400", rec.Code)
+	}
+}
+
+func TestInteractiveDetailAnnotations(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := getSidecarPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		t.Fatalf("openSidecarDB() error = %v", err)
+	}
+	now := time.Now()
+	_, _ = sidecar.AddTags("https://example.com/a", 0, []string{"research"}, now)
+	_, _ = sidecar.AddTags("https://example.com/a", 2, []string{"other-visit"}, now)
+	_ = sidecar.SetNote("https://example.com/a", 1, "あとで引用する", now)
+	_ = sidecar.Close()
+
+	m := newInteractiveModel(nil)
+	m.visits = []HistoryVisit{{ID: 1, URL: "https://example.com/a", Title: "A"}}
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("Enter でタグ・メモを読み込まない")
+	}
+	updated, _ = updated.Update(cmd())
+	view := updated.(interactiveModel).renderDetail()
+	if !strings.Contains(view, "#research") || !strings.Contains(view, "あとで引用する") || strings.Contains(view, "other-visit") {
+		t.Errorf("詳細表示:\n%s", view)
+	}
+}
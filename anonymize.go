@@ -0,0 +1,273 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// AnonymizeMode は匿名化の強度を表す
+type AnonymizeMode int
+
+const (
+	// AnonymizeOff は匿名化しない
+	AnonymizeOff AnonymizeMode = iota
+	// AnonymizeNormal はURL・タイトルをハッシュ化し、ドメインは残す
+	AnonymizeNormal
+	// AnonymizeStrict はURL・タイトルに加えてドメインもハッシュ化する
+	AnonymizeStrict
+)
+
+// anonymizeHashLength はハッシュ値の表示桁数（16進数）
+const anonymizeHashLength = 12
+
+// String はflag.Valueインターフェースの実装
+func (m *AnonymizeMode) String() string {
+	if m == nil {
+		return ""
+	}
+	switch *m {
+	case AnonymizeNormal:
+		return "true"
+	case AnonymizeStrict:
+		return "strict"
+	default:
+		return "false"
+	}
+}
+
+// Set はflag.Valueインターフェースの実装
+// -anonymize / -anonymize=true / -anonymize=strict / -anonymize=false を受け付ける
+func (m *AnonymizeMode) Set(value string) error {
+	switch value {
+	case "", "true":
+		*m = AnonymizeNormal
+	case "strict":
+		*m = AnonymizeStrict
+	case "false":
+		*m = AnonymizeOff
+	default:
+		return fmt.Errorf("不正な匿名化モード: %s（true, strict, false のいずれか）", value)
+	}
+	return nil
+}
+
+// IsBoolFlag は値なしの -anonymize を許可する
+func (m *AnonymizeMode) IsBoolFlag() bool {
+	return true
+}
+
+// anonymizeKey は hashValue の HMAC-SHA256 の鍵
+// HIST_ANONYMIZE_KEY があればその値（別々の出力でハッシュをそろえる場合）、なければ実行ごとに生成した乱数
+// 1回の実行の出力の中ではハッシュがそろい、sha256("github.com") のような辞書から元の値を引けない
+var anonymizeKey = sync.OnceValue(func() []byte {
+	if key := os.Getenv(HistAnonymizeKeyEnv); key != "" {
+		return []byte(key)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// hashValue は文字列を短いハッシュ値（anonymizeKey の HMAC-SHA256）に変換する
+// 空文字列はそのまま返す（「タイトルなし」の情報は残す）
+func hashValue(s string) string {
+	if s == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, anonymizeKey())
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:anonymizeHashLength]
+}
+
+// anonymizeDomain はモードに応じてドメインを匿名化する
+func anonymizeDomain(domain string, mode AnonymizeMode) string {
+	if mode == AnonymizeStrict {
+		return hashValue(domain)
+	}
+	return domain
+}
+
+// anonymizeResult は分析結果を匿名化したコピーを返す
+// 集計値（訪問数・時間帯・日付）はそのまま残す
+func anonymizeResult(result AnalysisResult, mode AnonymizeMode) AnalysisResult {
+	if mode == AnonymizeOff {
+		return result
+	}
+
+	anonymized := result
+
+	if result.RecentVisits != nil {
+		anonymized.RecentVisits = make([]HistoryVisit, len(result.RecentVisits))
+		for i, v := range result.RecentVisits {
+			v.URL = hashValue(v.URL)
+			v.Title = hashValue(v.Title)
+			v.Domain = anonymizeDomain(v.Domain, mode)
+			anonymized.RecentVisits[i] = v
+		}
+	}
+
+	if result.DomainStats != nil {
+		anonymized.DomainStats = make([]DomainStats, len(result.DomainStats))
+		for i, s := range result.DomainStats {
+			s.Domain = anonymizeDomain(s.Domain, mode)
+			anonymized.DomainStats[i] = s
+		}
+	}
+
+	if result.DomainPathStats != nil {
+		anonymized.DomainPathStats = make([]DomainPathStats, len(result.DomainPathStats))
+		for i, ds := range result.DomainPathStats {
+			ds.Domain = anonymizeDomain(ds.Domain, mode)
+			paths := make([]PathStats, len(ds.Paths))
+			for j, p := range ds.Paths {
+				paths[j] = PathStats{Path: hashValue(p.Path), Title: hashValue(p.Title), VisitCount: p.VisitCount}
+			}
+			ds.Paths = paths
+			anonymized.DomainPathStats[i] = ds
+		}
+	}
+
+	if result.TitleClusters != nil {
+		anonymized.TitleClusters = make([]TitleCluster, len(result.TitleClusters))
+		for i, c := range result.TitleClusters {
+			c.Title = hashValue(c.Title)
+			urls := make([]string, len(c.URLs))
+			for j, u := range c.URLs {
+				urls[j] = hashValue(u)
+			}
+			c.URLs = urls
+			anonymized.TitleClusters[i] = c
+		}
+	}
+
+	if result.Videos != nil {
+		videos := *result.Videos
+		videos.Videos = make([]VideoStats, len(result.Videos.Videos))
+		for i, v := range result.Videos.Videos {
+			v.ID = hashValue(v.ID)
+			v.Title = hashValue(v.Title)
+			v.URL = hashValue(v.URL)
+			videos.Videos[i] = v
+		}
+		anonymized.Videos = &videos
+	}
+
+	if result.GitHub != nil {
+		github := *result.GitHub
+		github.Repos = make([]GitHubRepoStats, len(result.GitHub.Repos))
+		for i, r := range result.GitHub.Repos {
+			r.Repo = hashValue(r.Repo)
+			github.Repos[i] = r
+		}
+		anonymized.GitHub = &github
+	}
+
+	if result.Docs != nil {
+		docs := DocsReport{
+			Sites:   make([]DocSiteStats, len(result.Docs.Sites)),
+			Lookups: make([]DocLookup, len(result.Docs.Lookups)),
+		}
+		for i, s := range result.Docs.Sites {
+			s.Site = anonymizeDomain(s.Site, mode)
+			docs.Sites[i] = s
+		}
+		for i, l := range result.Docs.Lookups {
+			l.Site = anonymizeDomain(l.Site, mode)
+			l.Path = hashValue(l.Path)
+			l.Title = hashValue(l.Title)
+			l.URL = hashValue(l.URL)
+			docs.Lookups[i] = l
+		}
+		anonymized.Docs = &docs
+	}
+
+	if result.LocalHosts != nil {
+		anonymized.LocalHosts = make([]LocalHostStats, len(result.LocalHosts))
+		for i, s := range result.LocalHosts {
+			s.Host = anonymizeDomain(s.Host, mode)
+			paths := make([]PathStats, len(s.Paths))
+			for j, p := range s.Paths {
+				paths[j] = PathStats{Path: hashValue(p.Path), Title: hashValue(p.Title), VisitCount: p.VisitCount}
+			}
+			s.Paths = paths
+			anonymized.LocalHosts[i] = s
+		}
+	}
+
+	if result.HourlyProfiles != nil {
+		anonymized.HourlyProfiles = make([]HourlyProfile, len(result.HourlyProfiles))
+		for i, p := range result.HourlyProfiles {
+			p.Domain = anonymizeDomain(p.Domain, mode)
+			anonymized.HourlyProfiles[i] = p
+		}
+	}
+
+	if result.GroupStats != nil && result.GroupStats.GroupBy == GroupByDomain {
+		anonymized.GroupStats = anonymizeGroupReport(*result.GroupStats, mode)
+	}
+
+	if result.Forecast != nil {
+		forecast := *result.Forecast
+		forecast.Domains = make([]DomainForecast, len(result.Forecast.Domains))
+		for i, d := range result.Forecast.Domains {
+			if d.Domain != redactedLabel {
+				d.Domain = anonymizeDomain(d.Domain, mode)
+			}
+			forecast.Domains[i] = d
+		}
+		anonymized.Forecast = &forecast
+	}
+
+	if result.Changepoints != nil {
+		report := *result.Changepoints
+		report.Changepoints = make([]Changepoint, len(result.Changepoints.Changepoints))
+		for i, cp := range result.Changepoints.Changepoints {
+			domains := make([]ChangepointDomain, len(cp.Domains))
+			for j, d := range cp.Domains {
+				if d.Domain != redactedLabel {
+					d.Domain = anonymizeDomain(d.Domain, mode)
+				}
+				domains[j] = d
+			}
+			cp.Domains = domains
+			report.Changepoints[i] = cp
+		}
+		anonymized.Changepoints = &report
+	}
+
+	anonymized.Query = anonymizeQueryInfo(result.Query, mode)
+
+	return anonymized
+}
+
+// anonymizeGroupReport は -group-by domain のグループ名（ドメイン）を匿名化したコピーを返す
+// 伏せたドメインと「その他」はそのまま残す
+func anonymizeGroupReport(report GroupReport, mode AnonymizeMode) *GroupReport {
+	anonymizeGroups := func(groups []GroupTotal) []GroupTotal {
+		anonymized := make([]GroupTotal, len(groups))
+		for i, g := range groups {
+			if g.Group != redactedLabel && g.Group != groupOtherLabel {
+				g.Group = anonymizeDomain(g.Group, mode)
+			}
+			anonymized[i] = g
+		}
+		return anonymized
+	}
+	report.Groups = anonymizeGroups(report.Groups)
+	daily := make([]GroupDayStats, len(report.Daily))
+	for i, d := range report.Daily {
+		d.Groups = anonymizeGroups(d.Groups)
+		daily[i] = d
+	}
+	if report.Daily != nil {
+		report.Daily = daily
+	}
+	return &report
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnonymizeModeSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    AnonymizeMode
+		wantErr bool
+	}{
+		{"true", AnonymizeNormal, false},
+		{"", AnonymizeNormal, false},
+		{"strict", AnonymizeStrict, false},
+		{"false", AnonymizeOff, false},
+		{"invalid", AnonymizeOff, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var m AnonymizeMode
+			err := m.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && m != tt.want {
+				t.Errorf("Set(%q) = %v, want %v", tt.value, m, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	if hashValue("") != "" {
+		t.Error("空文字列はそのまま返すべき")
+	}
+	h1 := hashValue("https://example.com/secret")
+	h2 := hashValue("https://example.com/secret")
+	if h1 != h2 {
+		t.Errorf("同じ入力で異なるハッシュ: %s, %s", h1, h2)
+	}
+	if len(h1) != anonymizeHashLength {
+		t.Errorf("ハッシュ長 = %d, want %d", len(h1), anonymizeHashLength)
+	}
+	if h1 == hashValue("https://example.com/other") {
+		t.Error("異なる入力で同じハッシュ")
+	}
+
+	// 鍵なしの SHA-256 の辞書からは引けない
+	sum := sha256.Sum256([]byte("github.com"))
+	if hashValue("github.com") == hex.EncodeToString(sum[:])[:anonymizeHashLength] {
+		t.Error("ハッシュが鍵なしの SHA-256 と同じ")
+	}
+}
+
+// TestAnonymizeKeyEnv は HIST_ANONYMIZE_KEY を指定すると、その鍵の HMAC-SHA256 になるか
+func TestAnonymizeKeyEnv(t *testing.T) {
+	if os.Getenv("HIST_TEST_ANONYMIZE") != "" {
+		fmt.Println(hashValue("github.com"))
+		return
+	}
+	run := func(key string) string {
+		t.Helper()
+		cmd := exec.Command(os.Args[0], "-test.run=^TestAnonymizeKeyEnv$")
+		cmd.Env = append(os.Environ(), "HIST_TEST_ANONYMIZE=1", HistAnonymizeKeyEnv+"="+key)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("サブプロセスの実行に失敗: %v", err)
+		}
+		return strings.SplitN(string(out), "\n", 2)[0]
+	}
+
+	mac := hmac.New(sha256.New, []byte("team-key"))
+	mac.Write([]byte("github.com"))
+	want := hex.EncodeToString(mac.Sum(nil))[:anonymizeHashLength]
+	if got := run("team-key"); got != want {
+		t.Errorf("HIST_ANONYMIZE_KEY=team-key のハッシュ = %q, want %q", got, want)
+	}
+	if got := run("other-key"); got == want {
+		t.Error("別の鍵で同じハッシュ")
+	}
+}
+
+func TestAnonymizeResult(t *testing.T) {
+	result := AnalysisResult{
+		TotalVisits: 10,
+		RecentVisits: []HistoryVisit{
+			{
+				URL:       "https://example.com/secret",
+				Title:     "Secret Page",
+				Domain:    "example.com",
+				VisitTime: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		DomainStats: []DomainStats{{Domain: "example.com", VisitCount: 5}},
+		DomainPathStats: []DomainPathStats{{
+			Domain:     "example.com",
+			TotalCount: 5,
+			Paths:      []PathStats{{Path: "/secret", Title: "Secret Page", VisitCount: 5}},
+		}},
+	}
+
+	normal := anonymizeResult(result, AnonymizeNormal)
+	v := normal.RecentVisits[0]
+	if v.URL == "https://example.com/secret" || v.Title == "Secret Page" {
+		t.Errorf("URL・タイトルがハッシュ化されていない: %+v", v)
+	}
+	if v.Domain != "example.com" || normal.DomainStats[0].Domain != "example.com" {
+		t.Error("通常モードではドメインを残すべき")
+	}
+	if p := normal.DomainPathStats[0].Paths[0]; p.Path == "/secret" || p.Title == "Secret Page" || p.VisitCount != 5 {
+		t.Errorf("パス・タイトルがハッシュ化されていない: %+v", p)
+	}
+	if !v.VisitTime.Equal(result.RecentVisits[0].VisitTime) || normal.DomainStats[0].VisitCount != 5 {
+		t.Error("集計値・訪問時刻は変更すべきでない")
+	}
+
+	// 元の結果が変更されていないこと
+	if result.RecentVisits[0].URL != "https://example.com/secret" {
+		t.Error("元の結果が変更された")
+	}
+
+	strict := anonymizeResult(result, AnonymizeStrict)
+	if strict.RecentVisits[0].Domain == "example.com" || strict.DomainStats[0].Domain == "example.com" {
+		t.Error("strictモードではドメインもハッシュ化すべき")
+	}
+
+	off := anonymizeResult(result, AnonymizeOff)
+	if off.RecentVisits[0].URL != "https://example.com/secret" {
+		t.Error("匿名化オフで値が変更された")
+	}
+}
@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveFileName は他のマシンの履歴とまとめたアーカイブDB（Safari の履歴DBと同じ形で、-db で読み込める）
+	archiveFileName = "archive.db"
+	// archiveKeyFileName は差分ファイルの署名に使う鍵（同期する全てのマシンに同じファイルを置く）
+	archiveKeyFileName = "archive.key"
+	// archiveDirFileName は最後に push した同期フォルダ（pull の既定値）
+	archiveDirFileName = "archive_dir.txt"
+	// archiveDeltaExt は差分ファイルの拡張子、archiveSignatureExt はその署名の拡張子
+	archiveDeltaExt     = ".json.gz"
+	archiveSignatureExt = ".sig"
+	// archiveDeltaVersion は差分ファイルの形式のバージョン
+	archiveDeltaVersion = 1
+	// archiveKeySize は署名の鍵のバイト数
+	archiveKeySize = 32
+	// archiveKeyPerms は鍵ファイルのパーミッション（本人だけが読める）
+	archiveKeyPerms = 0600
+)
+
+// archiveDeviceName はデバイス名（同期フォルダのサブディレクトリ名）に使える文字列
+var archiveDeviceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// archiveSchema はアーカイブDBのスキーマ
+// history_items・history_visits は genSchema と同じ形、archive_visits は取り込んだ訪問の元のデバイスと訪問ID
+// （seen_at はその訪問を push した日時＝Safari の履歴DBにあることを確認した日時）
+// archive_deltas は取り込み済みの差分ファイル
+// archive_checks・archive_missing は hist archive diff で確認した日時と、Safari の履歴DBから消えた訪問
+const archiveSchema = `
+CREATE TABLE IF NOT EXISTS history_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL UNIQUE,
+	domain_expansion TEXT NULL,
+	visit_count INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS history_visits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	history_item INTEGER NOT NULL REFERENCES history_items(id) ON DELETE CASCADE,
+	visit_time REAL NOT NULL,
+	title TEXT NULL,
+	load_successful BOOLEAN NOT NULL DEFAULT 1,
+	redirect_source INTEGER NULL UNIQUE REFERENCES history_visits(id) ON DELETE CASCADE,
+	redirect_destination INTEGER NULL UNIQUE REFERENCES history_visits(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS history_items__domain_expansion ON history_items (domain_expansion);
+CREATE INDEX IF NOT EXISTS history_visits__last_visit ON history_visits (history_item, visit_time DESC);
+CREATE TABLE IF NOT EXISTS archive_visits (
+	visit_id INTEGER PRIMARY KEY REFERENCES history_visits(id) ON DELETE CASCADE,
+	device TEXT NOT NULL,
+	source_id INTEGER NOT NULL,
+	seen_at TEXT NULL,
+	UNIQUE (device, source_id)
+);
+CREATE TABLE IF NOT EXISTS archive_deltas (
+	device TEXT NOT NULL,
+	name TEXT NOT NULL,
+	visit_count INTEGER NOT NULL,
+	pulled_at TEXT NOT NULL,
+	PRIMARY KEY (device, name)
+);
+CREATE TABLE IF NOT EXISTS archive_checks (
+	device TEXT PRIMARY KEY,
+	checked_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS archive_missing (
+	visit_id INTEGER PRIMARY KEY REFERENCES history_visits(id) ON DELETE CASCADE,
+	last_seen TEXT NOT NULL,
+	missing_since TEXT NOT NULL
+);`
+
+// ArchiveDelta は hist archive push で書き出す差分ファイルの中身
+type ArchiveDelta struct {
+	Version   int       `json:"version"`
+	Device    string    `json:"device"`
+	CreatedAt time.Time `json:"created_at"`
+	// Cursor は最後の訪問の位置（次の push はこれより後の訪問を書き出す）
+	Cursor string         `json:"cursor"`
+	Visits []HistoryVisit `json:"visits"`
+}
+
+// ArchivePullResult は hist archive pull の結果
+type ArchivePullResult struct {
+	Deltas  int `json:"deltas"`
+	Visits  int `json:"visits"`
+	Skipped int `json:"skipped"`
+	// Rejected は署名を確認できなかった差分ファイル（取り込まない）
+	Rejected []string `json:"rejected,omitempty"`
+}
+
+// errArchiveSignature は差分ファイルの署名が一致しない場合のエラー
+var errArchiveSignature = errors.New("署名が一致しません（別の鍵で書き出されたか、改ざんされています）")
+
+// getArchivePath はアーカイブDBのパスを返す
+func getArchivePath() (string, error) {
+	return getConfigFilePath(archiveFileName)
+}
+
+// defaultArchiveDevice はホスト名からデバイス名を作る（"MacBook-Pro.local" → "macbook-pro"）
+func defaultArchiveDevice() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "mac"
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, ".local"))
+	var b strings.Builder
+	for _, r := range host {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	if device := strings.Trim(b.String(), "-_"); archiveDeviceName.MatchString(device) {
+		return device
+	}
+	return "mac"
+}
+
+// loadArchiveKey は署名の鍵を読み込む（create なら、なければ作る）
+func loadArchiveKey(create bool) ([]byte, error) {
+	path, err := getConfigFilePath(archiveKeyFileName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != archiveKeySize {
+			return nil, fmt.Errorf("署名の鍵が不正です: %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("署名の鍵の読み込みに失敗: %w", err)
+	}
+	if !create {
+		return nil, fmt.Errorf("署名の鍵がありません（hist archive push を実行したマシンの %s をコピーしてください）", path)
+	}
+	key := make([]byte, archiveKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("署名の鍵の作成に失敗: %w", err)
+	}
+	if err := ensureConfigDir(); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), archiveKeyPerms); err != nil {
+		return nil, fmt.Errorf("署名の鍵の保存に失敗: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "署名の鍵を作成しました: %s（同期する他のマシンにもコピーしてください）\n", path)
+	return key, nil
+}
+
+// signArchiveDelta は差分ファイルの中身の署名（HMAC-SHA256 の16進数）を返す
+func signArchiveDelta(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readArchiveDelta は差分ファイルを読み込み、署名を確かめてから展開する
+func readArchiveDelta(path string, key []byte) (ArchiveDelta, error) {
+	var delta ArchiveDelta
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return delta, fmt.Errorf("差分ファイルの読み込みに失敗: %w", err)
+	}
+	sig, err := os.ReadFile(strings.TrimSuffix(path, archiveDeltaExt) + archiveSignatureExt)
+	if err != nil {
+		return delta, fmt.Errorf("署名の読み込みに失敗: %w", err)
+	}
+	want, err := hex.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return delta, errArchiveSignature
+	}
+	got, _ := hex.DecodeString(signArchiveDelta(key, data))
+	if !hmac.Equal(got, want) {
+		return delta, errArchiveSignature
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return delta, fmt.Errorf("差分ファイルの展開に失敗: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+	if err := json.NewDecoder(zr).Decode(&delta); err != nil {
+		return delta, fmt.Errorf("差分ファイルの解析に失敗: %w", err)
+	}
+	if delta.Version != archiveDeltaVersion {
+		return delta, fmt.Errorf("対応していない差分ファイルの形式です（version %d）", delta.Version)
+	}
+	return delta, nil
+}
+
+// writeArchiveDelta は差分ファイルと署名を dir に書き出し、差分ファイルのパスを返す
+// 同期ソフトが書きかけのファイルを送らないよう、一時ファイルから置き換える（署名は後に書く）
+func writeArchiveDelta(dir string, delta ArchiveDelta, key []byte) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(delta); err != nil {
+		return "", fmt.Errorf("差分ファイルの作成に失敗: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("差分ファイルの作成に失敗: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, configDirPerms); err != nil {
+		return "", fmt.Errorf("同期フォルダの作成に失敗: %w", err)
+	}
+	last := delta.Visits[len(delta.Visits)-1]
+	base := filepath.Join(dir, fmt.Sprintf("%s-%d", delta.CreatedAt.UTC().Format("20060102T150405Z"), last.ID))
+	for _, f := range []struct {
+		path string
+		data []byte
+	}{
+		{base + archiveDeltaExt, buf.Bytes()},
+		{base + archiveSignatureExt, []byte(signArchiveDelta(key, buf.Bytes()) + "\n")},
+	} {
+		tmp := f.path + ".tmp"
+		if err := os.WriteFile(tmp, f.data, configFilePerms); err != nil {
+			return "", fmt.Errorf("差分ファイルの書き込みに失敗: %w", err)
+		}
+		if err := os.Rename(tmp, f.path); err != nil {
+			return "", fmt.Errorf("差分ファイルの書き込みに失敗: %w", err)
+		}
+	}
+	return base + archiveDeltaExt, nil
+}
+
+// listArchiveDeltas は dir 直下の差分ファイルの名前を古い順に返す（dir がなければ空）
+func listArchiveDeltas(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("同期フォルダの読み込みに失敗: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), archiveDeltaExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// lastArchiveCursor は device の最後の差分ファイルのカーソルを返す（まだ push していなければ nil）
+func lastArchiveCursor(deviceDir string, key []byte) (*HistoryCursor, error) {
+	names, err := listArchiveDeltas(deviceDir)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	delta, err := readArchiveDelta(filepath.Join(deviceDir, names[len(names)-1]), key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", names[len(names)-1], err)
+	}
+	cursor, err := parseHistoryCursor(delta.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// pushArchive は前回の push より後の訪問を dir/<device>/ に差分ファイルとして書き出す
+// 書き出した差分ファイルのパスと訪問数を返す（新しい訪問がなければ空のパス）
+func pushArchive(db dbQuerier, dir, device string, key []byte, now time.Time) (string, int, error) {
+	if !archiveDeviceName.MatchString(device) {
+		return "", 0, fmt.Errorf("デバイス名には英小文字・数字・-・_ を使ってください: %s", device)
+	}
+	deviceDir := filepath.Join(dir, device)
+	cursor, err := lastArchiveCursor(deviceDir, key)
+	if err != nil {
+		return "", 0, err
+	}
+
+	qb := NewQueryBuilder(historyBaseQuery)
+	if cursor != nil {
+		qb.WithRawCondition("hv.visit_time > ? OR (hv.visit_time = ? AND hv.id > ?)", cursor.VisitTime, cursor.VisitTime, cursor.ID)
+	}
+	query, args := qb.OrderByAsc("hv.visit_time ASC, hv.id").Build()
+	visits, err := executeHistoryQuery(db, query, args)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(visits) == 0 {
+		return "", 0, nil
+	}
+
+	// HistoryVisit の時刻は変換済みなので、カーソルには DB の値をそのまま使う
+	last := HistoryCursor{ID: visits[len(visits)-1].ID}
+	if err := db.QueryRow(`SELECT visit_time FROM history_visits WHERE id = ?`, last.ID).Scan(&last.VisitTime); err != nil {
+		return "", 0, fmt.Errorf("カーソルの取得に失敗: %w", err)
+	}
+	delta := ArchiveDelta{
+		Version:   archiveDeltaVersion,
+		Device:    device,
+		CreatedAt: now,
+		Cursor:    last.String(),
+		Visits:    visits,
+	}
+	path, err := writeArchiveDelta(deviceDir, delta, key)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, len(visits), nil
+}
+
+// openArchiveDB は path のアーカイブDBを開く（なければ作る）
+func openArchiveDB(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerms); err != nil {
+		return nil, fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+	}
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("アーカイブDBを開けませんでした: %w", err)
+	}
+	if _, err := db.Exec(archiveSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("アーカイブDBの作成に失敗: %w", err)
+	}
+	// seen_at がない以前のアーカイブDBには列を足す（取り込み済みの訪問は NULL のまま）
+	seenAt, err := hasColumn(db, "archive_visits", "seen_at")
+	if err == nil && !seenAt {
+		_, err = db.Exec(`ALTER TABLE archive_visits ADD COLUMN seen_at TEXT NULL`)
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("アーカイブDBの更新に失敗: %w", err)
+	}
+	return db, nil
+}
+
+// existingArchivePath は path のアーカイブDBか、暗号化したアーカイブDBがあればそのパスを返す（なければ空）
+func existingArchivePath(path string) string {
+	for _, p := range []string{withEncryptionExt(path), path} {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// withArchiveDB は path のアーカイブDBを開いて fn を実行し、実際に使ったアーカイブDBのパスを返す
+// encrypt か、既に暗号化したアーカイブDB（path + ".enc"）があれば、同じフォルダの一時ファイルに復号して開き、
+// fn の後で暗号化し直す（平文のアーカイブDBしかなければ暗号化して平文のほうは削除する）
+func withArchiveDB(path string, encrypt bool, fn func(*sql.DB) error) (string, error) {
+	encPath := withEncryptionExt(path)
+	if _, err := os.Stat(encPath); err == nil {
+		encrypt = true
+	}
+	if !encrypt {
+		archive, err := openArchiveDB(path)
+		if err != nil {
+			return path, err
+		}
+		defer func() { _ = archive.Close() }()
+		return path, fn(archive)
+	}
+
+	key, err := loadEncryptionKey()
+	if err != nil {
+		return encPath, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerms); err != nil {
+		return encPath, fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-*.db")
+	if err != nil {
+		return encPath, fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	work := tmp.Name()
+	if _, err := os.Stat(encPath); err == nil {
+		if err := decryptFile(encPath, work, key); err != nil {
+			return encPath, fmt.Errorf("%s: %w", encPath, err)
+		}
+	} else if _, err := os.Stat(path); err == nil {
+		work = path
+	}
+	archive, err := openArchiveDB(work)
+	if err != nil {
+		return encPath, err
+	}
+	// fn が途中で失敗しても確定済みの取り込みは残るので、暗号化し直す
+	fnErr := fn(archive)
+	if err := archive.Close(); err != nil {
+		return encPath, fmt.Errorf("アーカイブDBを閉じられませんでした: %w", err)
+	}
+	if err := encryptFile(work, encPath, key); err != nil {
+		return encPath, err
+	}
+	if work == path {
+		if err := os.Remove(path); err != nil {
+			return encPath, fmt.Errorf("平文のアーカイブDBの削除に失敗: %w", err)
+		}
+	}
+	return encPath, fnErr
+}
+
+// expireArchiveVisits は before より前の訪問と、訪問がなくなったURLをアーカイブDBから削除し、件数を返す（dryRun なら数えるだけ）
+// 取り込み済みの差分ファイルの記録は残すため、次の pull で削除した訪問を取り込み直すことはない
+func expireArchiveVisits(archive *sql.DB, before time.Time, dryRun bool) (visits, urls int, err error) {
+	ts := convertToTimestamp(before)
+	if err := archive.QueryRow(`SELECT COUNT(*) FROM history_visits WHERE visit_time < ?`, ts).Scan(&visits); err != nil {
+		return 0, 0, fmt.Errorf("訪問数の取得に失敗: %w", err)
+	}
+	if err := archive.QueryRow(`SELECT COUNT(*) FROM history_items hi
+		WHERE NOT EXISTS (SELECT 1 FROM history_visits hv WHERE hv.history_item = hi.id AND hv.visit_time >= ?)`, ts).Scan(&urls); err != nil {
+		return 0, 0, fmt.Errorf("URL数の取得に失敗: %w", err)
+	}
+	if dryRun || visits+urls == 0 {
+		return visits, urls, nil
+	}
+
+	tx, err := archive.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM archive_missing WHERE visit_id IN (SELECT id FROM history_visits WHERE visit_time < ?)`, ts); err != nil {
+		return 0, 0, fmt.Errorf("古い訪問の削除に失敗: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM archive_visits WHERE visit_id IN (SELECT id FROM history_visits WHERE visit_time < ?)`, ts); err != nil {
+		return 0, 0, fmt.Errorf("古い訪問の削除に失敗: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM history_visits WHERE visit_time < ?`, ts); err != nil {
+		return 0, 0, fmt.Errorf("古い訪問の削除に失敗: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM history_items WHERE NOT EXISTS (SELECT 1 FROM history_visits hv WHERE hv.history_item = history_items.id)`); err != nil {
+		return 0, 0, fmt.Errorf("URLの削除に失敗: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE history_items SET visit_count = (SELECT COUNT(*) FROM history_visits hv WHERE hv.history_item = history_items.id)`); err != nil {
+		return 0, 0, fmt.Errorf("訪問数の更新に失敗: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("削除の確定に失敗: %w", err)
+	}
+	// 削除した訪問がファイルに残らないよう詰める
+	if _, err := archive.Exec(`VACUUM`); err != nil {
+		return 0, 0, fmt.Errorf("アーカイブDBの整理に失敗: %w", err)
+	}
+	return visits, urls, nil
+}
+
+// pullArchive は dir の全てのデバイス（このマシンを含む）の差分ファイルのうち、まだ取り込んでいないものをアーカイブDBに取り込む
+// 署名を確認できない差分ファイルは取り込まず Rejected に入れる。同じ訪問は二重に取り込まない
+func pullArchive(archive *sql.DB, dir string, key []byte, now time.Time) (ArchivePullResult, error) {
+	result := ArchivePullResult{}
+	devices, err := os.ReadDir(dir)
+	if err != nil {
+		return result, fmt.Errorf("同期フォルダの読み込みに失敗: %w", err)
+	}
+	for _, d := range devices {
+		if !d.IsDir() || !archiveDeviceName.MatchString(d.Name()) {
+			continue
+		}
+		names, err := listArchiveDeltas(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return result, err
+		}
+		for _, name := range names {
+			var pulled int
+			if err := archive.QueryRow(`SELECT COUNT(*) FROM archive_deltas WHERE device = ? AND name = ?`, d.Name(), name).Scan(&pulled); err != nil {
+				return result, fmt.Errorf("取り込み済みの差分の確認に失敗: %w", err)
+			}
+			if pulled > 0 {
+				continue
+			}
+			rel := filepath.Join(d.Name(), name)
+			delta, err := readArchiveDelta(filepath.Join(dir, rel), key)
+			if err == nil && delta.Device != d.Name() {
+				err = fmt.Errorf("差分ファイルのデバイス名（%s）がフォルダと一致しません", delta.Device)
+			}
+			if err != nil {
+				result.Rejected = append(result.Rejected, fmt.Sprintf("%s: %v", rel, err))
+				continue
+			}
+			added, skipped, err := importArchiveDelta(archive, name, delta, now)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", rel, err)
+			}
+			result.Deltas++
+			result.Visits += added
+			result.Skipped += skipped
+		}
+	}
+	return result, nil
+}
+
+// importArchiveDelta は差分ファイル1つ分の訪問をアーカイブDBに追加する（取り込み済みの訪問は数えて飛ばす）
+func importArchiveDelta(archive *sql.DB, name string, delta ArchiveDelta, now time.Time) (int, int, error) {
+	tx, err := archive.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	added, skipped := 0, 0
+	for _, v := range delta.Visits {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM archive_visits WHERE device = ? AND source_id = ?`, delta.Device, v.ID).Scan(&exists); err != nil {
+			return 0, 0, fmt.Errorf("訪問の確認に失敗: %w", err)
+		}
+		if exists > 0 {
+			skipped++
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO history_items (url, domain_expansion, visit_count) VALUES (?, ?, 1)
+			ON CONFLICT(url) DO UPDATE SET visit_count = visit_count + 1`, v.URL, nullIfEmpty(v.Domain)); err != nil {
+			return 0, 0, fmt.Errorf("URLの書き込みに失敗: %w", err)
+		}
+		res, err := tx.Exec(`INSERT INTO history_visits (history_item, visit_time, title)
+			SELECT id, ?, ? FROM history_items WHERE url = ?`, convertToTimestamp(v.VisitTime), v.Title, v.URL)
+		if err != nil {
+			return 0, 0, fmt.Errorf("訪問の書き込みに失敗: %w", err)
+		}
+		visitID, err := res.LastInsertId()
+		if err != nil {
+			return 0, 0, fmt.Errorf("訪問の書き込みに失敗: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO archive_visits (visit_id, device, source_id, seen_at) VALUES (?, ?, ?, ?)`,
+			visitID, delta.Device, v.ID, delta.CreatedAt.UTC().Format(time.RFC3339)); err != nil {
+			return 0, 0, fmt.Errorf("訪問の書き込みに失敗: %w", err)
+		}
+		added++
+	}
+	if _, err := tx.Exec(`INSERT INTO archive_deltas (device, name, visit_count, pulled_at) VALUES (?, ?, ?, ?)`,
+		delta.Device, name, len(delta.Visits), now.UTC().Format(time.RFC3339)); err != nil {
+		return 0, 0, fmt.Errorf("取り込んだ差分の記録に失敗: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("取り込みの確定に失敗: %w", err)
+	}
+	return added, skipped, nil
+}
+
+// nullIfEmpty は空文字列を NULL にする
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// loadArchiveDir は最後に push した同期フォルダを返す（なければ空）
+func loadArchiveDir() (string, error) {
+	lines, err := loadDomainListFile(archiveDirFileName, "同期フォルダの設定")
+	if err != nil || len(lines) == 0 {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// runArchiveCommand は hist archive サブコマンドを実行する
+func runArchiveCommand(args []string) error {
+	usage := "使い方: hist archive push -to <同期フォルダ> [-device 名前] | pull [-from <同期フォルダ>] [-encrypt] [-key-file 鍵ファイル] [-json]" +
+		" | diff [-device 名前] [-limit 20] [-json]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "push":
+		fs := flag.NewFlagSet("archive push", flag.ContinueOnError)
+		to := fs.String("to", "", "差分ファイルを書き出す同期フォルダ（Dropbox・iCloud Drive など、省略時は前回のフォルダ）")
+		device := fs.String("device", defaultArchiveDevice(), "このマシンのデバイス名（同期フォルダのサブディレクトリ名）")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		dir := *to
+		if dir == "" {
+			var err error
+			if dir, err = loadArchiveDir(); err != nil {
+				return err
+			}
+			if dir == "" {
+				return errors.New("-to で同期フォルダを指定してください")
+			}
+		}
+		key, err := loadArchiveKey(true)
+		if err != nil {
+			return err
+		}
+		db, err := setupDatabase()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		path, n, err := pushArchive(db, dir, *device, key, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := saveDomainListFile(archiveDirFileName, "同期フォルダの設定", []string{dir}); err != nil {
+			return err
+		}
+		if n == 0 {
+			fmt.Println("前回の push から新しい訪問はありません")
+			return nil
+		}
+		fmt.Printf("%d件の訪問を書き出しました: %s\n", n, path)
+		return nil
+
+	case "pull":
+		fs := flag.NewFlagSet("archive pull", flag.ContinueOnError)
+		from := fs.String("from", "", "差分ファイルを読み込む同期フォルダ（省略時は前回 push したフォルダ）")
+		jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+		encrypt := fs.Bool("encrypt", false, "アーカイブDBを暗号化して保存する（archive.db.enc。一度暗号化すると以降の pull も暗号化する）")
+		keyFile := fs.String("key-file", "", "アーカイブDBの暗号化に使う鍵ファイル（環境変数 "+HistKeyFileEnv+" と同じ。省略時は "+HistPassphraseEnv+" のパスフレーズ）")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if err := setKeyFileEnv(*keyFile); err != nil {
+			return err
+		}
+		dir := *from
+		if dir == "" {
+			var err error
+			if dir, err = loadArchiveDir(); err != nil {
+				return err
+			}
+			if dir == "" {
+				return errors.New("-from で同期フォルダを指定してください")
+			}
+		}
+		key, err := loadArchiveKey(false)
+		if err != nil {
+			return err
+		}
+		path, err := getArchivePath()
+		if err != nil {
+			return err
+		}
+		var result ArchivePullResult
+		path, err = withArchiveDB(path, *encrypt, func(archive *sql.DB) error {
+			var err error
+			result, err = pullArchive(archive, dir, key, time.Now())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if *jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+		for _, r := range result.Rejected {
+			fmt.Fprintf(os.Stderr, "取り込みませんでした: %s\n", r)
+		}
+		fmt.Printf("%d件の差分ファイルから%d件の訪問を取り込みました（取り込み済み %d件）: %s\n", result.Deltas, result.Visits, result.Skipped, path)
+		fmt.Printf("  hist -db %s -all でまとめた履歴を表示できます\n", path)
+		if encrypted, _ := splitEncryptionExt(path); encrypted {
+			fmt.Printf("  （暗号化したアーカイブDBは -key-file か環境変数 %s で同じ鍵を指定してください）\n", HistPassphraseEnv)
+		}
+		return nil
+
+	case "diff":
+		fs := flag.NewFlagSet("archive diff", flag.ContinueOnError)
+		device := fs.String("device", defaultArchiveDevice(), "このマシンのデバイス名（push したときの名前）")
+		limit := fs.Int("limit", defaultTimeMachineLimit, "表示する消えた訪問の件数（0で全件）")
+		keyFile := fs.String("key-file", "", "暗号化したアーカイブDBの鍵ファイル（環境変数 "+HistKeyFileEnv+" と同じ）")
+		jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+		noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if err := setKeyFileEnv(*keyFile); err != nil {
+			return err
+		}
+		var redactDomains []string
+		if !*noRedact {
+			var err error
+			if redactDomains, err = LoadRedactList(); err != nil {
+				return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+			}
+		}
+		path, err := getArchivePath()
+		if err != nil {
+			return err
+		}
+		if existingArchivePath(path) == "" {
+			return errors.New("アーカイブDBがありません（hist archive push・pull で作成してください）")
+		}
+		db, err := setupDatabase()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		var diff ArchiveDiff
+		if _, err = withArchiveDB(path, false, func(archive *sql.DB) error {
+			var err error
+			diff, err = diffArchive(archive, db, *device, time.Now(), *limit, redactDomains)
+			return err
+		}); err != nil {
+			return err
+		}
+		if *jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(diff)
+		}
+		if diff.Archived == 0 {
+			fmt.Printf("アーカイブにデバイス %s の訪問がありません（hist archive push・pull の後に実行してください）\n", *device)
+			return nil
+		}
+		printArchiveDiff(os.Stdout, diff)
+		return nil
+
+	default:
+		return errors.New(usage)
+	}
+}
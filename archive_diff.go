@@ -0,0 +1,298 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// archiveDiffDomainLimit は hist archive diff でまとまりごとに表示する主なドメインの数
+const archiveDiffDomainLimit = 5
+
+// ArchiveLostVisit は Safari の履歴DBから消えたアーカイブの訪問（ID はアーカイブDBの訪問ID）
+type ArchiveLostVisit struct {
+	HistoryVisit
+	// LastSeen は Safari の履歴DBにあることを最後に確認した日時（push した日時か、前回の hist archive diff）
+	LastSeen time.Time `json:"last_seen"`
+	// MissingSince は消えていることに初めて気づいた hist archive diff の日時
+	MissingSince time.Time `json:"missing_since"`
+}
+
+// ArchiveLossBatch は同じ hist archive diff で消えていると気づいた訪問のまとまり
+// 履歴の消去や Safari の保存期間による削除は、From〜To の訪問がまとめて消える
+type ArchiveLossBatch struct {
+	MissingSince time.Time `json:"missing_since"`
+	// LastSeen はまとまりの中で最も遅い最後の確認日時（消えたのは LastSeen〜MissingSince の間）
+	LastSeen time.Time `json:"last_seen"`
+	Count    int       `json:"count"`
+	// From・To は消えた訪問の訪問日時の範囲
+	From    time.Time     `json:"from"`
+	To      time.Time     `json:"to"`
+	Domains []DomainStats `json:"domains"`
+}
+
+// ArchiveDiff は hist archive diff の結果
+type ArchiveDiff struct {
+	Device    string    `json:"device"`
+	CheckedAt time.Time `json:"checked_at"`
+	// PreviousCheck は前回の hist archive diff の日時（初回はゼロ値）
+	PreviousCheck time.Time `json:"previous_check,omitzero"`
+	// Archived はこのデバイスから取り込んだ訪問の数、Lost はそのうち Safari の履歴DBにない訪問の数
+	Archived int `json:"archived"`
+	Lost     int `json:"lost"`
+	// NewlyLost は今回初めて消えていると気づいた訪問、Restored は消えた後に戻った訪問（バックアップからの復元など）の数
+	NewlyLost int                `json:"newly_lost"`
+	Restored  int                `json:"restored"`
+	Batches   []ArchiveLossBatch `json:"batches"`
+	// Visits は消えた訪問（気づいた日時・訪問日時の新しい順）
+	Visits []ArchiveLostVisit `json:"visits"`
+}
+
+// diffArchive は device から取り込んだアーカイブの訪問のうち、Safari の履歴DB（src）から消えたものを記録して返す
+// 消えた訪問は archive_missing に、確認した日時は archive_checks に残し、次回の LastSeen に使う
+// Visits には新しく気づいた順に limit 件を入れる（0以下なら全件）
+func diffArchive(archive *sql.DB, src dbQuerier, device string, now time.Time, limit int, redactDomains []string) (ArchiveDiff, error) {
+	diff := ArchiveDiff{Device: device, CheckedAt: now}
+
+	alive := make(map[int64]bool)
+	rows, err := src.Query(`SELECT id FROM history_visits`)
+	if err != nil {
+		return diff, fmt.Errorf("訪問IDの取得に失敗: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return diff, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		alive[id] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return diff, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	tx, err := archive.Begin()
+	if err != nil {
+		return diff, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var previous string
+	err = tx.QueryRow(`SELECT checked_at FROM archive_checks WHERE device = ?`, device).Scan(&previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return diff, fmt.Errorf("前回の確認日時の取得に失敗: %w", err)
+	}
+	if previous != "" {
+		if diff.PreviousCheck, err = time.Parse(time.RFC3339, previous); err != nil {
+			return diff, fmt.Errorf("前回の確認日時が不正です: %w", err)
+		}
+	}
+
+	type missingVisit struct {
+		visitID  int64
+		lastSeen string
+	}
+	var newlyLost []missingVisit
+	var restored []int64
+	rows, err = tx.Query(`SELECT av.visit_id, av.source_id, COALESCE(av.seen_at, ''), am.visit_id IS NOT NULL
+		FROM archive_visits av LEFT JOIN archive_missing am ON am.visit_id = av.visit_id
+		WHERE av.device = ?`, device)
+	if err != nil {
+		return diff, fmt.Errorf("アーカイブの訪問の取得に失敗: %w", err)
+	}
+	for rows.Next() {
+		var visitID, sourceID int64
+		var seenAt string
+		var wasMissing bool
+		if err := rows.Scan(&visitID, &sourceID, &seenAt, &wasMissing); err != nil {
+			_ = rows.Close()
+			return diff, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		diff.Archived++
+		switch present := alive[sourceID]; {
+		case present && wasMissing:
+			restored = append(restored, visitID)
+		case !present && !wasMissing:
+			// push した日時と前回の確認のうち遅いほう（RFC3339 の UTC は文字列で比べられる）
+			newlyLost = append(newlyLost, missingVisit{visitID: visitID, lastSeen: max(seenAt, previous)})
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return diff, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	checkedAt := now.UTC().Format(time.RFC3339)
+	for _, m := range newlyLost {
+		if _, err := tx.Exec(`INSERT INTO archive_missing (visit_id, last_seen, missing_since) VALUES (?, ?, ?)`,
+			m.visitID, m.lastSeen, checkedAt); err != nil {
+			return diff, fmt.Errorf("消えた訪問の記録に失敗: %w", err)
+		}
+	}
+	for _, id := range restored {
+		if _, err := tx.Exec(`DELETE FROM archive_missing WHERE visit_id = ?`, id); err != nil {
+			return diff, fmt.Errorf("戻った訪問の記録に失敗: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO archive_checks (device, checked_at) VALUES (?, ?)
+		ON CONFLICT (device) DO UPDATE SET checked_at = excluded.checked_at`, device, checkedAt); err != nil {
+		return diff, fmt.Errorf("確認日時の記録に失敗: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return diff, fmt.Errorf("記録の確定に失敗: %w", err)
+	}
+	diff.NewlyLost, diff.Restored = len(newlyLost), len(restored)
+
+	lost, err := getArchiveLostVisits(archive, device)
+	if err != nil {
+		return diff, err
+	}
+	diff.Lost = len(lost)
+	diff.Batches = groupArchiveLosses(lost, redactDomains)
+	if limit > 0 && len(lost) > limit {
+		lost = lost[:limit]
+	}
+	diff.Visits = make([]ArchiveLostVisit, 0, len(lost))
+	for _, v := range lost {
+		v.HistoryVisit = redactVisits([]HistoryVisit{v.HistoryVisit}, redactDomains)[0]
+		diff.Visits = append(diff.Visits, v)
+	}
+	return diff, nil
+}
+
+// getArchiveLostVisits は device から取り込んだ訪問のうち、消えていると記録した訪問を気づいた日時・訪問日時の新しい順に返す
+func getArchiveLostVisits(archive *sql.DB, device string) ([]ArchiveLostVisit, error) {
+	type missingTimes struct{ lastSeen, missingSince time.Time }
+	times := make(map[int64]missingTimes)
+	rows, err := archive.Query(`SELECT am.visit_id, am.last_seen, am.missing_since
+		FROM archive_missing am JOIN archive_visits av ON av.visit_id = am.visit_id
+		WHERE av.device = ?`, device)
+	if err != nil {
+		return nil, fmt.Errorf("消えた訪問の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id int64
+		var lastSeen, missingSince string
+		if err := rows.Scan(&id, &lastSeen, &missingSince); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		var t missingTimes
+		// 以前のアーカイブDBから取り込んだ訪問は push した日時がなく、初回の確認では空になる
+		t.lastSeen, _ = time.Parse(time.RFC3339, lastSeen)
+		if t.missingSince, err = time.Parse(time.RFC3339, missingSince); err != nil {
+			return nil, fmt.Errorf("消えた訪問の日時が不正です: %w", err)
+		}
+		times[id] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	_ = rows.Close()
+	if len(times) == 0 {
+		return nil, nil
+	}
+
+	query, args := NewQueryBuilder(historyBaseQuery).
+		WithRawCondition(`hv.id IN (SELECT am.visit_id FROM archive_missing am
+			JOIN archive_visits av ON av.visit_id = am.visit_id WHERE av.device = ?)`, device).
+		OrderByDesc("hv.visit_time DESC, hv.id").Build()
+	visits, err := executeHistoryQuery(archive, query, args)
+	if err != nil {
+		return nil, err
+	}
+	lost := make([]ArchiveLostVisit, 0, len(visits))
+	for _, v := range visits {
+		t := times[v.ID]
+		lost = append(lost, ArchiveLostVisit{HistoryVisit: v, LastSeen: t.lastSeen, MissingSince: t.missingSince})
+	}
+	// 訪問日時の新しい順を保ったまま、気づいた日時の新しい順に並べる
+	sort.SliceStable(lost, func(i, j int) bool { return lost[i].MissingSince.After(lost[j].MissingSince) })
+	return lost, nil
+}
+
+// groupArchiveLosses は消えた訪問を気づいた日時ごとにまとめる（lost は気づいた日時の新しい順）
+func groupArchiveLosses(lost []ArchiveLostVisit, redactDomains []string) []ArchiveLossBatch {
+	var batches []ArchiveLossBatch
+	for start := 0; start < len(lost); {
+		end := start
+		for end < len(lost) && lost[end].MissingSince.Equal(lost[start].MissingSince) {
+			end++
+		}
+		group := lost[start:end]
+		batch := ArchiveLossBatch{MissingSince: group[0].MissingSince, Count: len(group), From: group[0].VisitTime, To: group[0].VisitTime}
+		visits := make([]HistoryVisit, 0, len(group))
+		for _, v := range group {
+			if v.LastSeen.After(batch.LastSeen) {
+				batch.LastSeen = v.LastSeen
+			}
+			if v.VisitTime.Before(batch.From) {
+				batch.From = v.VisitTime
+			}
+			if v.VisitTime.After(batch.To) {
+				batch.To = v.VisitTime
+			}
+			visits = append(visits, v.HistoryVisit)
+		}
+		batch.Domains = countVisitDomains(visits)
+		if len(batch.Domains) > archiveDiffDomainLimit {
+			batch.Domains = batch.Domains[:archiveDiffDomainLimit]
+		}
+		batch.Domains = redactDomainStats(batch.Domains, redactDomains)
+		batches = append(batches, batch)
+		start = end
+	}
+	return batches
+}
+
+// printArchiveDiff は hist archive diff の結果を出力する
+func printArchiveDiff(w io.Writer, diff ArchiveDiff) {
+	_, _ = fmt.Fprintf(w, "\n🔍 Safari の履歴から消えた訪問（%s）\n", diff.Device)
+	_, _ = fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	_, _ = fmt.Fprintf(w, "アーカイブ: %d件（このデバイスから取り込んだ訪問）\n", diff.Archived)
+	_, _ = fmt.Fprintf(w, "消えた訪問: %d件（今回 %d件", diff.Lost, diff.NewlyLost)
+	if diff.Restored > 0 {
+		_, _ = fmt.Fprintf(w, "、戻った訪問 %d件", diff.Restored)
+	}
+	_, _ = fmt.Fprintf(w, "）\n")
+	if !diff.PreviousCheck.IsZero() {
+		_, _ = fmt.Fprintf(w, "前回の確認: %s\n", diff.PreviousCheck.Local().Format(TimeFormatDateTime))
+	}
+	_, _ = fmt.Fprintln(w)
+	if diff.Lost == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "🗓  消えた時期\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, b := range diff.Batches {
+		lastSeen := "不明"
+		if !b.LastSeen.IsZero() {
+			lastSeen = b.LastSeen.Local().Format(TimeFormatDateTime)
+		}
+		_, _ = fmt.Fprintf(w, "  %s 〜 %s に消えた %d件（%s 〜 %s の訪問）\n", lastSeen, b.MissingSince.Local().Format(TimeFormatDateTime),
+			b.Count, b.From.Format(TimeFormatDate), b.To.Format(TimeFormatDate))
+		for _, d := range b.Domains {
+			_, _ = fmt.Fprintf(w, "      %-30s %4d件\n", d.Domain, d.VisitCount)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintf(w, "📜 消えた訪問\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, v := range diff.Visits {
+		title := v.Title
+		if title == "" {
+			title = v.URL
+		}
+		_, _ = fmt.Fprintf(w, "  %s  %-24s %s\n", v.VisitTime.Format(TimeFormatShort), truncateText(extractDomain(v.URL), 24), truncateText(title, MaxTitleLength))
+	}
+	if len(diff.Visits) < diff.Lost {
+		_, _ = fmt.Fprintf(w, "  … ほか %d件（-limit 0 で全件）\n", diff.Lost-len(diff.Visits))
+	}
+	_, _ = fmt.Fprintln(w)
+}
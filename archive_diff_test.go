@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffArchive(t *testing.T) {
+	safari := setupTestDB(t)
+	defer func() { _ = safari.Close() }()
+	insertTestData(t, safari)
+	visits, err := executeHistoryQuery(safari, historyBaseQuery+" ORDER BY hv.id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := openArchiveDB(filepath.Join(t.TempDir(), archiveFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = archive.Close() }()
+	pushed := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)
+	for _, device := range []string{"laptop", "desktop"} {
+		delta := ArchiveDelta{Version: archiveDeltaVersion, Device: device, CreatedAt: pushed, Visits: visits}
+		if _, _, err := importArchiveDelta(archive, device+"-1", delta, pushed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := pushed.Add(24 * time.Hour)
+	diff, err := diffArchive(archive, safari, "laptop", first, 0, nil)
+	if err != nil {
+		t.Fatalf("diffArchive() error = %v", err)
+	}
+	if diff.Archived != 5 || diff.Lost != 0 || diff.NewlyLost != 0 || !diff.PreviousCheck.IsZero() {
+		t.Errorf("初回の diffArchive() = %+v, want 5件・消えた訪問なし", diff)
+	}
+
+	// 2025-01-01 10:00・11:00 の訪問を消す（履歴の消去）
+	if _, err := safari.Exec(`DELETE FROM history_visits WHERE id IN (1, 2)`); err != nil {
+		t.Fatal(err)
+	}
+	second := first.Add(24 * time.Hour)
+	diff, err = diffArchive(archive, safari, "laptop", second, 0, []string{"youtube.com"})
+	if err != nil {
+		t.Fatalf("diffArchive() error = %v", err)
+	}
+	if diff.Lost != 2 || diff.NewlyLost != 2 || !diff.PreviousCheck.Equal(first) || len(diff.Batches) != 1 {
+		t.Fatalf("消した後の diffArchive() = %+v, want 2件", diff)
+	}
+	batch := diff.Batches[0]
+	if !batch.LastSeen.Equal(first) || !batch.MissingSince.Equal(second) || batch.Count != 2 ||
+		!batch.From.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)) || !batch.To.Equal(time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("Batches[0] = %+v", batch)
+	}
+	for _, v := range diff.Visits {
+		if strings.Contains(v.URL, "youtube") {
+			t.Errorf("リダクト対象のURLが出力された: %s", v.URL)
+		}
+	}
+
+	// 別の時期に消えた訪問は別のまとまりにし、新しく気づいたものを先に並べる
+	if _, err := safari.Exec(`DELETE FROM history_visits WHERE id = 5`); err != nil {
+		t.Fatal(err)
+	}
+	third := second.Add(24 * time.Hour)
+	diff, err = diffArchive(archive, safari, "laptop", third, 1, nil)
+	if err != nil {
+		t.Fatalf("diffArchive() error = %v", err)
+	}
+	if diff.Lost != 3 || diff.NewlyLost != 1 || len(diff.Batches) != 2 || len(diff.Visits) != 1 {
+		t.Fatalf("2回目に消した後の diffArchive() = %+v", diff)
+	}
+	if !diff.Batches[0].MissingSince.Equal(third) || !diff.Batches[0].LastSeen.Equal(second) ||
+		!diff.Visits[0].VisitTime.Equal(time.Date(2025, 1, 2, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("新しいまとまり = %+v, 訪問 = %+v", diff.Batches[0], diff.Visits[0])
+	}
+	var buf bytes.Buffer
+	printArchiveDiff(&buf, diff)
+	if out := buf.String(); !strings.Contains(out, "消えた訪問: 3件（今回 1件）") || !strings.Contains(out, "ほか 2件") {
+		t.Errorf("printArchiveDiff() = %q", out)
+	}
+
+	// 戻った訪問（バックアップからの復元）は消えた訪問から外す
+	if _, err := safari.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (1, 1, 757418400, 'GitHub')`); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = diffArchive(archive, safari, "laptop", third.Add(time.Hour), 0, nil)
+	if err != nil {
+		t.Fatalf("diffArchive() error = %v", err)
+	}
+	if diff.Lost != 2 || diff.NewlyLost != 0 || diff.Restored != 1 {
+		t.Errorf("戻した後の diffArchive() = %+v, want 消えた訪問2件・戻った訪問1件", diff)
+	}
+}
+
+func TestOpenArchiveDBAddsSeenAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), archiveFileName)
+	old, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`CREATE TABLE archive_visits (visit_id INTEGER PRIMARY KEY, device TEXT NOT NULL, source_id INTEGER NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	_ = old.Close()
+
+	archive, err := openArchiveDB(path)
+	if err != nil {
+		t.Fatalf("以前のアーカイブDBの openArchiveDB() error = %v", err)
+	}
+	defer func() { _ = archive.Close() }()
+	if ok, err := hasColumn(archive, "archive_visits", "seen_at"); err != nil || !ok {
+		t.Errorf("seen_at 列 = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchivePushPull(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	key, err := loadArchiveKey(true)
+	if err != nil {
+		t.Fatalf("loadArchiveKey() error = %v", err)
+	}
+	if again, err := loadArchiveKey(false); err != nil || string(again) != string(key) {
+		t.Fatalf("保存した鍵を読み込めない: %v", err)
+	}
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)
+
+	laptop := setupTestDB(t)
+	defer func() { _ = laptop.Close() }()
+	insertTestData(t, laptop)
+	path, n, err := pushArchive(laptop, dir, "laptop", key, now)
+	if err != nil || n != 5 || !strings.HasSuffix(path, archiveDeltaExt) {
+		t.Fatalf("pushArchive() = %q, %d, %v, want 5件", path, n, err)
+	}
+	// 前回の push から増えた訪問だけを書き出す
+	if _, n, err := pushArchive(laptop, dir, "laptop", key, now.Add(time.Hour)); err != nil || n != 0 {
+		t.Errorf("2回目の pushArchive() = %d, %v, want 0件", n, err)
+	}
+	if _, err := laptop.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 4, ?, 'Example')`,
+		convertToTimestamp(now)); err != nil {
+		t.Fatal(err)
+	}
+	if _, n, err := pushArchive(laptop, dir, "laptop", key, now.Add(2*time.Hour)); err != nil || n != 1 {
+		t.Errorf("訪問を追加した後の pushArchive() = %d, %v, want 1件", n, err)
+	}
+
+	desktop := setupTestDB(t)
+	defer func() { _ = desktop.Close() }()
+	insertTestData(t, desktop)
+	if _, n, err := pushArchive(desktop, dir, "desktop", key, now); err != nil || n != 5 {
+		t.Fatalf("desktop の pushArchive() = %d, %v", n, err)
+	}
+	if _, _, err := pushArchive(desktop, dir, "Desktop PC", key, now); err == nil {
+		t.Error("不正なデバイス名で push できる")
+	}
+
+	// 同じ訪問を含む差分ファイル（名前だけ違う）は二重に取り込まない
+	copyArchiveDelta(t, path, filepath.Join(dir, "laptop", "20250103T100000Z-5"))
+	// 別の鍵で署名した差分ファイルは取り込まない
+	other := make([]byte, archiveKeySize)
+	forged := ArchiveDelta{Version: archiveDeltaVersion, Device: "intruder", CreatedAt: now, Cursor: "1,1",
+		Visits: []HistoryVisit{{ID: 1, URL: "https://evil.example/", VisitTime: now}}}
+	if _, err := writeArchiveDelta(filepath.Join(dir, "intruder"), forged, other); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := openArchiveDB(filepath.Join(t.TempDir(), archiveFileName))
+	if err != nil {
+		t.Fatalf("openArchiveDB() error = %v", err)
+	}
+	defer func() { _ = archive.Close() }()
+	result, err := pullArchive(archive, dir, key, now)
+	if err != nil {
+		t.Fatalf("pullArchive() error = %v", err)
+	}
+	if result.Deltas != 4 || result.Visits != 11 || result.Skipped != 5 || len(result.Rejected) != 1 ||
+		!strings.Contains(result.Rejected[0], "intruder") {
+		t.Errorf("pullArchive() = %+v, want 4ファイル・11件・取り込み済み5件・拒否1件", result)
+	}
+	if again, err := pullArchive(archive, dir, key, now); err != nil || again.Deltas != 0 || again.Visits != 0 {
+		t.Errorf("2回目の pullArchive() = %+v, %v", again, err)
+	}
+
+	// まとめた履歴は Safari の履歴DBと同じように集計できる
+	total, err := getTotalVisits(archive)
+	if err != nil || total != 11 {
+		t.Errorf("getTotalVisits() = %d, %v, want 11", total, err)
+	}
+	var count int
+	if err := archive.QueryRow(`SELECT visit_count FROM history_items WHERE url = 'https://github.com/test'`).Scan(&count); err != nil || count != 4 {
+		t.Errorf("github.com/test の visit_count = %d, %v, want 4", count, err)
+	}
+	visits, err := getRecentVisits(archive, 1, SearchFilter{})
+	if err != nil || len(visits) != 1 || visits[0].URL != "https://example.com" || !visits[0].VisitTime.Equal(now) {
+		t.Errorf("最新の訪問 = %+v, %v", visits, err)
+	}
+}
+
+func TestReadArchiveDeltaTampered(t *testing.T) {
+	key := make([]byte, archiveKeySize)
+	delta := ArchiveDelta{Version: archiveDeltaVersion, Device: "laptop", Cursor: "1,1",
+		Visits: []HistoryVisit{{ID: 1, URL: "https://go.dev/"}}}
+	path, err := writeArchiveDelta(t.TempDir(), delta, key)
+	if err != nil {
+		t.Fatalf("writeArchiveDelta() error = %v", err)
+	}
+	if got, err := readArchiveDelta(path, key); err != nil || got.Visits[0].URL != "https://go.dev/" {
+		t.Fatalf("readArchiveDelta() = %+v, %v", got, err)
+	}
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, configFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readArchiveDelta(path, key); !errors.Is(err, errArchiveSignature) {
+		t.Errorf("書き換えた差分ファイルの readArchiveDelta() error = %v, want errArchiveSignature", err)
+	}
+}
+
+// copyArchiveDelta は差分ファイルと署名を base の名前でコピーする
+func copyArchiveDelta(t *testing.T, src, base string) {
+	t.Helper()
+	srcBase := strings.TrimSuffix(src, archiveDeltaExt)
+	for _, ext := range []string{archiveDeltaExt, archiveSignatureExt} {
+		data, err := os.ReadFile(srcBase + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(base+ext, data, configFilePerms); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupFileName は hist backup の設定ファイル
+// 1行に「キー = 値」を書く:
+//
+//	dest = ~/Backups/safari    バックアップの保存先（-dest を省略したときに使う）
+//	keep = 10                  残すバックアップの数（既定は 10、0 で数では削除しない）
+//	max_age = 90d              これより古いバックアップを削除する（既定は無期限。期間の書式は retention.txt と同じ）
+const backupFileName = "backup.txt"
+
+// defaultBackupKeep は残すバックアップの既定の数
+const defaultBackupKeep = 10
+
+// backupTimeFormat はバックアップのファイル名の日時（ローカル時刻）
+const backupTimeFormat = "20060102-150405"
+
+// backupSidecarSuffixes は履歴DBと一緒にコピーする WAL・共有メモリのファイルの接尾辞
+var backupSidecarSuffixes = []string{"-wal", "-shm"}
+
+// backupNamePattern はバックアップの履歴DBのファイル名（History-20250103-090000.db）
+var backupNamePattern = regexp.MustCompile(`^History-([0-9]{8}-[0-9]{6})\.db$`)
+
+// BackupConfig は backup.txt の設定
+type BackupConfig struct {
+	Dest string
+	Keep int
+	// MaxAge はこれより古いバックアップを削除する期間（nil なら無期限）
+	MaxAge *RetentionPeriod
+}
+
+// parseBackupConfig は backup.txt の行を解析する
+func parseBackupConfig(lines []string) (BackupConfig, error) {
+	cfg := BackupConfig{Keep: defaultBackupKeep}
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return BackupConfig{}, fmt.Errorf("バックアップの設定は「キー = 値」形式で指定してください: %s", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "dest":
+			cfg.Dest = value
+		case "keep":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return BackupConfig{}, fmt.Errorf("keep には0以上の数を指定してください: %s", value)
+			}
+			cfg.Keep = n
+		case "max_age":
+			period, err := parseRetentionPeriod(value)
+			if err != nil {
+				return BackupConfig{}, fmt.Errorf("max_age: %w", err)
+			}
+			cfg.MaxAge = &period
+		default:
+			return BackupConfig{}, fmt.Errorf("バックアップの設定に不明なキーがあります: %s", key)
+		}
+	}
+	return cfg, nil
+}
+
+// LoadBackupConfig はバックアップの設定を読み込む
+func LoadBackupConfig() (BackupConfig, error) {
+	lines, err := loadDomainListFile(backupFileName, "バックアップの設定")
+	if err != nil {
+		return BackupConfig{}, err
+	}
+	return parseBackupConfig(lines)
+}
+
+// expandHomeDir は先頭の ~/ をホームディレクトリに置き換える（設定ファイルのパスはシェルを通らないため）
+func expandHomeDir(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~")), nil
+}
+
+// BackupFile は保存先にあるバックアップ1つ
+type BackupFile struct {
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	// Size は履歴DBと WAL・共有メモリのファイルの合計バイト数
+	Size int64 `json:"size"`
+}
+
+// BackupResult は hist backup の結果
+type BackupResult struct {
+	Backup BackupFile `json:"backup"`
+	// Files はコピーしたファイル（履歴DBと、あれば WAL・共有メモリ）
+	Files []string `json:"files"`
+	// Integrity は PRAGMA integrity_check の結果（正常なら "ok"）
+	Integrity string `json:"integrity"`
+	// Removed は世代管理で削除したバックアップ
+	Removed []string `json:"removed"`
+}
+
+// backupSafariDB は src の履歴DBを dest に日時付きのファイル名でコピーし、PRAGMA integrity_check で確かめる
+// コピーの間は src の読み取りトランザクションを開いたままにし、Safari のチェックポイントで
+// 履歴DBが書き換わってもコピーした WAL と合わせて一貫した状態になるようにする
+// 確認に失敗したバックアップは削除してエラーを返す
+func backupSafariDB(src, dest string, now time.Time) (BackupResult, error) {
+	var result BackupResult
+	if _, err := os.Stat(src); err != nil {
+		return result, fmt.Errorf("履歴DBが見つかりません: %w", err)
+	}
+	if err := os.MkdirAll(dest, 0o700); err != nil {
+		return result, fmt.Errorf("バックアップ先の作成に失敗: %w", err)
+	}
+	path := filepath.Join(dest, "History-"+now.Format(backupTimeFormat)+".db")
+	if _, err := os.Stat(path); err == nil {
+		return result, fmt.Errorf("同じ時刻のバックアップが既にあります: %s", path)
+	}
+
+	db, err := openDB(src)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = db.Close() }()
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	var tables int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&tables); err != nil {
+		return result, fmt.Errorf("履歴DBを読めませんでした: %w", err)
+	}
+
+	remove := func() {
+		for _, f := range append([]string{path}, backupSidecars(path)...) {
+			_ = os.Remove(f)
+		}
+	}
+	if err := copyBackupFile(src, path); err != nil {
+		remove()
+		return result, err
+	}
+	result.Files = append(result.Files, path)
+	for _, suffix := range backupSidecarSuffixes {
+		if _, err := os.Stat(src + suffix); err != nil {
+			continue
+		}
+		if err := copyBackupFile(src+suffix, path+suffix); err != nil {
+			remove()
+			return result, err
+		}
+		result.Files = append(result.Files, path+suffix)
+	}
+	_ = tx.Rollback()
+
+	if result.Integrity, err = verifyBackup(path); err != nil {
+		remove()
+		return result, err
+	}
+	result.Backup = BackupFile{Path: path, CreatedAt: now, Size: backupSize(path)}
+	return result, nil
+}
+
+// copyBackupFile は src を本人だけが読めるファイルとして dst にコピーする
+func copyBackupFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("コピー元を開けませんでした: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	_, err = io.Copy(out, in)
+	if serr := out.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("コピーに失敗: %w", err)
+	}
+	return nil
+}
+
+// verifyBackup はバックアップを読み取り専用で開いて PRAGMA integrity_check を実行する
+func verifyBackup(path string) (string, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = db.Close() }()
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return "", fmt.Errorf("整合性の確認に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", fmt.Errorf("整合性の確認に失敗: %w", err)
+		}
+		problems = append(problems, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("整合性の確認に失敗: %w", err)
+	}
+	if len(problems) == 1 && problems[0] == "ok" {
+		return "ok", nil
+	}
+	return "", fmt.Errorf("バックアップが壊れています（PRAGMA integrity_check）: %s", strings.Join(problems, "; "))
+}
+
+// backupSidecars は path の WAL・共有メモリのファイルのうち、あるものを返す
+func backupSidecars(path string) []string {
+	var files []string
+	for _, suffix := range backupSidecarSuffixes {
+		if _, err := os.Stat(path + suffix); err == nil {
+			files = append(files, path+suffix)
+		}
+	}
+	return files
+}
+
+// backupSize は履歴DBと WAL・共有メモリのファイルの合計バイト数を返す
+func backupSize(path string) int64 {
+	var size int64
+	for _, f := range append([]string{path}, backupSidecars(path)...) {
+		if info, err := os.Stat(f); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// listBackups は dest にあるバックアップを新しい順に返す（hist backup が作ったファイル名のものだけ）
+func listBackups(dest string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("バックアップ先の読み込みに失敗: %w", err)
+	}
+	var backups []BackupFile
+	for _, e := range entries {
+		m := backupNamePattern.FindStringSubmatch(e.Name())
+		if m == nil || e.IsDir() {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(backupTimeFormat, m[1], time.Local)
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dest, e.Name())
+		backups = append(backups, BackupFile{Path: path, CreatedAt: createdAt, Size: backupSize(path)})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// rotateBackups は keep 個より前のバックアップと、maxAge より古いバックアップを削除し、削除したパスを返す
+// 最新のバックアップは古くても削除しない
+func rotateBackups(dest string, keep int, maxAge *RetentionPeriod, now time.Time) ([]string, error) {
+	backups, err := listBackups(dest)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+		expired := keep > 0 && i >= keep
+		if maxAge != nil && b.CreatedAt.Before(maxAge.Cutoff(now)) {
+			expired = true
+		}
+		if !expired {
+			continue
+		}
+		for _, f := range append(backupSidecars(b.Path), b.Path) {
+			if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return removed, fmt.Errorf("古いバックアップの削除に失敗: %w", err)
+			}
+		}
+		removed = append(removed, b.Path)
+	}
+	return removed, nil
+}
+
+// runBackupCommand は hist backup サブコマンドを実行する
+func runBackupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dest := fs.String("dest", "", "バックアップの保存先（省略時は "+backupFileName+" の dest）")
+	keep := fs.Int("keep", -1, "残すバックアップの数（省略時は "+backupFileName+" の keep、既定は "+strconv.Itoa(defaultBackupKeep)+"。0 で数では削除しない）")
+	list := fs.Bool("list", false, "バックアップせずに、保存先のバックアップを一覧する")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist backup [-dest ~/Backups/safari/] [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  Safari の履歴DB（History.db と -wal・-shm）を日時付きのファイル名でコピーし、\n")
+		fmt.Fprintf(fs.Output(), "  PRAGMA integrity_check で確かめてから古いバックアップを削除します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := LoadBackupConfig()
+	if err != nil {
+		return err
+	}
+	if *dest != "" {
+		cfg.Dest = *dest
+	}
+	if *keep >= 0 {
+		cfg.Keep = *keep
+	}
+	if cfg.Dest == "" {
+		return fmt.Errorf("-dest か %s の dest で保存先を指定してください", backupFileName)
+	}
+	if cfg.Dest, err = expandHomeDir(cfg.Dest); err != nil {
+		return err
+	}
+
+	if *list {
+		backups, err := listBackups(cfg.Dest)
+		if err != nil {
+			return err
+		}
+		if *jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(backups)
+		}
+		if len(backups) == 0 {
+			fmt.Printf("バックアップはありません: %s\n", cfg.Dest)
+			return nil
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %8.1f MB  %s\n", b.CreatedAt.Format(TimeFormatDateTime), float64(b.Size)/(1<<20), b.Path)
+		}
+		return nil
+	}
+
+	src, err := getDBPath()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	result, err := backupSafariDB(src, cfg.Dest, now)
+	if err != nil {
+		return err
+	}
+	if result.Removed, err = rotateBackups(cfg.Dest, cfg.Keep, cfg.MaxAge, now); err != nil {
+		return err
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	fmt.Printf("バックアップしました（integrity_check: %s、%.1f MB）: %s\n", result.Integrity, float64(result.Backup.Size)/(1<<20), result.Backup.Path)
+	for _, path := range result.Removed {
+		fmt.Printf("  古いバックアップを削除しました: %s\n", filepath.Base(path))
+	}
+	fmt.Printf("  hist -db %s -all でバックアップの履歴を表示できます\n", result.Backup.Path)
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createTestHistoryFile はテスト用の履歴DBを WAL モードのファイルとして作る
+func createTestHistoryFile(t *testing.T) (string, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "History.db")
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	// DSN のパラメータはドライバごとに違うため、PRAGMA で WAL にする
+	if _, err := db.Exec(`PRAGMA journal_mode = WAL`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(testSchema); err != nil {
+		t.Fatalf("テーブル作成に失敗: %v", err)
+	}
+	insertTestData(t, db)
+	return path, db
+}
+
+func TestParseBackupConfig(t *testing.T) {
+	cfg, err := parseBackupConfig([]string{"dest = ~/Backups/safari", "keep=3", "max_age = 90d"})
+	if err != nil {
+		t.Fatalf("parseBackupConfig() error = %v", err)
+	}
+	if cfg.Dest != "~/Backups/safari" || cfg.Keep != 3 || cfg.MaxAge == nil || cfg.MaxAge.String() != "90d" {
+		t.Errorf("parseBackupConfig() = %+v", cfg)
+	}
+	if cfg, err := parseBackupConfig(nil); err != nil || cfg.Keep != defaultBackupKeep || cfg.MaxAge != nil {
+		t.Errorf("空の parseBackupConfig() = %+v, %v", cfg, err)
+	}
+	for _, lines := range [][]string{{"dest"}, {"keep = -1"}, {"keep = many"}, {"max_age = forever"}, {"src = /tmp"}} {
+		if _, err := parseBackupConfig(lines); err == nil {
+			t.Errorf("parseBackupConfig(%q) がエラーにならない", lines)
+		}
+	}
+}
+
+func TestExpandHomeDir(t *testing.T) {
+	t.Setenv("HOME", "/home/hist")
+	tests := map[string]string{
+		"~/Backups/safari": "/home/hist/Backups/safari",
+		"~":                "/home/hist",
+		"/tmp/backups":     "/tmp/backups",
+		"~other/backups":   "~other/backups",
+	}
+	for in, want := range tests {
+		if got, err := expandHomeDir(in); err != nil || got != want {
+			t.Errorf("expandHomeDir(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestBackupSafariDB(t *testing.T) {
+	src, db := createTestHistoryFile(t)
+	defer func() { _ = db.Close() }()
+	if _, err := os.Stat(src + "-wal"); err != nil {
+		t.Fatalf("WAL ファイルがない: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "backups")
+	now := time.Date(2025, 1, 3, 9, 0, 0, 0, time.Local)
+	result, err := backupSafariDB(src, dest, now)
+	if err != nil {
+		t.Fatalf("backupSafariDB() error = %v", err)
+	}
+	wantPath := filepath.Join(dest, "History-20250103-090000.db")
+	if result.Backup.Path != wantPath || result.Integrity != "ok" || len(result.Files) < 2 || result.Backup.Size == 0 {
+		t.Errorf("backupSafariDB() = %+v", result)
+	}
+	if info, err := os.Stat(wantPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("バックアップのパーミッション = %v, %v, want 0600", info, err)
+	}
+
+	// WAL の内容も含めて読めること
+	backup, err := openDB(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = backup.Close() }()
+	var count int
+	if err := backup.QueryRow(`SELECT COUNT(*) FROM history_visits`).Scan(&count); err != nil || count != 5 {
+		t.Errorf("バックアップの訪問数 = %d, %v, want 5", count, err)
+	}
+
+	if _, err := backupSafariDB(src, dest, now); err == nil {
+		t.Error("同じ時刻のバックアップがエラーにならない")
+	}
+	if _, err := backupSafariDB(filepath.Join(t.TempDir(), "missing.db"), dest, now.Add(time.Hour)); err == nil {
+		t.Error("履歴DBがなくてもエラーにならない")
+	}
+}
+
+func TestVerifyBackupCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "History-20250103-090000.db")
+	if err := os.WriteFile(path, []byte(strings.Repeat("not a database ", 512)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyBackup(path); err == nil {
+		t.Error("壊れたバックアップの verifyBackup() がエラーにならない")
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	dest := t.TempDir()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	var paths []string
+	for _, days := range []int{0, 1, 2, 40, 100} {
+		path := filepath.Join(dest, "History-"+now.AddDate(0, 0, -days).Format(backupTimeFormat)+".db")
+		for _, f := range []string{path, path + "-wal"} {
+			if err := os.WriteFile(f, []byte("x"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		paths = append(paths, path)
+	}
+	// 名前の形式が違うファイルは消さない
+	other := filepath.Join(dest, "History.db")
+	if err := os.WriteFile(other, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := listBackups(dest)
+	if err != nil || len(backups) != 5 || backups[0].Path != paths[0] || backups[0].Size != 2 {
+		t.Fatalf("listBackups() = %+v, %v", backups, err)
+	}
+
+	maxAge := RetentionPeriod{Count: 30, Unit: 'd'}
+	removed, err := rotateBackups(dest, 4, &maxAge, now)
+	if err != nil {
+		t.Fatalf("rotateBackups() error = %v", err)
+	}
+	if len(removed) != 2 || removed[0] != paths[3] || removed[1] != paths[4] {
+		t.Errorf("rotateBackups() = %v, want 40日前と100日前", removed)
+	}
+	if _, err := os.Stat(paths[3] + "-wal"); !os.IsNotExist(err) {
+		t.Error("削除したバックアップの WAL ファイルが残っている")
+	}
+
+	removed, err = rotateBackups(dest, 1, nil, now)
+	if err != nil || len(removed) != 2 {
+		t.Errorf("rotateBackups(keep=1) = %v, %v, want 2件", removed, err)
+	}
+	// 最新のバックアップは期間を過ぎていても残す
+	removed, err = rotateBackups(dest, 0, &maxAge, now.AddDate(1, 0, 0))
+	if err != nil || len(removed) != 0 {
+		t.Errorf("rotateBackups(最新のみ) = %v, %v, want 0件", removed, err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("バックアップ以外のファイルが消えた: %v", err)
+	}
+	if backups, _ := listBackups(filepath.Join(dest, "missing")); len(backups) != 0 {
+		t.Errorf("存在しない保存先の listBackups() = %v", backups)
+	}
+}
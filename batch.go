@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// batchCommand は hist batch のファイルの1行（分析コマンド1つ）
+type batchCommand struct {
+	Line   int
+	Args   []string
+	Config Config
+}
+
+// batchRejectedFlags は hist batch の各行に指定できないフラグと理由
+// DBは hist batch で1回だけ開き、Webサーバーなどの対話的なモードは1行で終わらないため
+// リストの管理は解析の時点でファイルを書き換えて終了するため、どの行も実行する前に拒否する
+var batchRejectedFlags = map[string]string{
+	"db":            "DBは hist batch -db で指定してください",
+	"browser":       "DBは hist batch -db で指定してください",
+	"serve":         "Webサーバーは起動できません",
+	"interactive":   "インタラクティブモードは起動できません",
+	"ignore-add":    batchListManagementReason,
+	"ignore-remove": batchListManagementReason,
+	"ignore-list":   batchListManagementReason,
+	"redact-add":    batchListManagementReason,
+	"redact-remove": batchListManagementReason,
+	"redact-list":   batchListManagementReason,
+	"docs-add":      batchListManagementReason,
+	"docs-remove":   batchListManagementReason,
+	"docs-list":     batchListManagementReason,
+}
+
+// batchListManagementReason はイグノアリストなどの管理フラグを hist batch の行で拒否する理由
+const batchListManagementReason = "イグノアリスト・リダクトリスト・ドキュメントサイトの管理は hist batch の外で実行してください"
+
+// splitBatchArgs はコマンドの1行をシェルと同じように引数に分ける（'…' と "…" で空白を含められ、\ で次の1文字をそのまま使う）
+func splitBatchArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("引用符が閉じていません")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// readBatchFile は1行に1つの分析コマンド（hist に渡すフラグ）を読む
+// 空行と # で始まる行は読み飛ばし、行頭の hist は省いてもよい
+func readBatchFile(r io.Reader) ([]batchCommand, error) {
+	var commands []batchCommand
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		args, err := splitBatchArgs(text)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %w", line, err)
+		}
+		if len(args) > 0 && args[0] == "hist" {
+			args = args[1:]
+		}
+		commands = append(commands, batchCommand{Line: line, Args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("コマンドファイルの読み込みに失敗: %w", err)
+	}
+	return commands, nil
+}
+
+// parseBatchCommands は各行のフラグを通常の hist と同じく解析する
+// 指定の誤りは exitWithError で「ファイル名:行番号:」を付けて終了するため、どの行も実行する前に全ての行を確かめる
+func parseBatchCommands(path string, commands []batchCommand) error {
+	defer func() { exitErrorPrefix = "" }()
+	for i, c := range commands {
+		for _, arg := range c.Args {
+			name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			if reason, ok := batchRejectedFlags[name]; ok && strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("%s:%d: -%s は指定できません（%s）", path, c.Line, name, reason)
+			}
+		}
+		exitErrorPrefix = fmt.Sprintf("%s:%d: ", path, c.Line)
+		fs := flag.NewFlagSet("hist", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		commands[i].Config = parseFlagSet(fs, c.Args)
+	}
+	return nil
+}
+
+// runBatch は解析済みのコマンドを順に1つのDB接続で実行する
+// 各コマンドの結果は -output の指定先（なければ標準出力）に書き出す。結果が0件のコマンドは続けて次を実行する
+func runBatch(db *sql.DB, path string, commands []batchCommand, index *SearchIndex, progress io.Writer) error {
+	noMatches := 0
+	for i, c := range commands {
+		_, _ = fmt.Fprintf(progress, "[%d/%d] %s\n", i+1, len(commands), strings.Join(c.Args, " "))
+		config := c.Config
+		if index != nil {
+			config.Filter.Index = index
+		}
+		var err error
+		if config.Explain {
+			err = runExplainMode(db, config, os.Stdout)
+		} else {
+			err = runCLIMode(db, config)
+		}
+		if errors.Is(err, errNoMatches) {
+			noMatches++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, c.Line, err)
+		}
+	}
+	if noMatches > 0 {
+		_, _ = fmt.Fprintf(progress, "%d件のコマンドを実行しました（結果が0件: %d件）\n", len(commands), noMatches)
+	} else {
+		_, _ = fmt.Fprintf(progress, "%d件のコマンドを実行しました\n", len(commands))
+	}
+	return nil
+}
+
+// runBatchCommand は hist batch サブコマンドを実行する
+func runBatchCommand(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	dbFile := fs.String("db", "", "Safari の履歴DBの代わりに読み込むファイル（環境変数 "+HistDBEnv+" と同じ）")
+	quiet := fs.Bool("q", false, "実行中のコマンドを標準エラー出力に表示しない")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist batch [オプション] <コマンドファイル>\n\n")
+		fmt.Fprintf(fs.Output(), "  1行に1つずつ書いた分析コマンド（例: -daily -csv -output daily.csv）を、履歴DBを1回だけ開いて順に実行します\n")
+		fmt.Fprintf(fs.Output(), "  空行と # で始まる行は読み飛ばします。指定に誤りがある行があれば、どのコマンドも実行しません\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("コマンドファイルを1つ指定してください")
+	}
+	path := fs.Arg(0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("コマンドファイルを開けませんでした: %w", err)
+	}
+	commands, err := readBatchFile(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(commands) == 0 {
+		return fmt.Errorf("%s: 実行するコマンドがありません", path)
+	}
+	if *dbFile != "" {
+		if err := os.Setenv(HistDBEnv, *dbFile); err != nil {
+			return err
+		}
+	}
+	if err := parseBatchCommands(path, commands); err != nil {
+		return err
+	}
+
+	// 全てのコマンドで1本の接続を共有し、SQLite のページキャッシュを次のコマンドでも使う
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	useSharedConnection(db)
+
+	var index *SearchIndex
+	if os.Getenv(HistDBEnv) == "" {
+		index, err = openDefaultSearchIndex(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 全文検索の索引を使わずに検索します: %v\n", err)
+		}
+	}
+	if index != nil {
+		defer func() { _ = index.Close() }()
+	}
+	// -explain・-now だけのファイルではデータを削除しない
+	for _, c := range commands {
+		if c.Config.appliesRetentionOnStartup() {
+			applyRetentionOnStartup(index)
+			break
+		}
+	}
+
+	progress := io.Writer(os.Stderr)
+	if *quiet {
+		progress = io.Discard
+	}
+	return runBatch(db, path, commands, index, progress)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitBatchArgs(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want string
+	}{
+		{"-daily  -csv\t-output daily.csv", "[-daily -csv -output daily.csv]"},
+		{`-search "go lang" -output 'my report.json'`, "[-search|go lang|-output|my report.json]"},
+		{`-search go\ lang -exclude ""`, "[-search|go lang|-exclude|]"},
+		{`-search 'a\b' -exclude "c\"d"`, `[-search|a\b|-exclude|c"d]`},
+	} {
+		args, err := splitBatchArgs(tt.line)
+		if err != nil {
+			t.Errorf("splitBatchArgs(%q) error = %v", tt.line, err)
+			continue
+		}
+		got := fmt.Sprint(args)
+		if strings.Contains(tt.want, "|") {
+			got = "[" + strings.Join(args, "|") + "]"
+		}
+		if got != tt.want {
+			t.Errorf("splitBatchArgs(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+	if _, err := splitBatchArgs(`-search "go`); err == nil {
+		t.Error("閉じていない引用符がエラーにならない")
+	}
+}
+
+func TestReadBatchFile(t *testing.T) {
+	input := "# 毎週の集計\n\n-domains -csv -output domains.csv\nhist -daily -json\n  # 字下げしたコメント\n-search 'a b'\n"
+	commands, err := readBatchFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readBatchFile: %v", err)
+	}
+	var got []string
+	for _, c := range commands {
+		got = append(got, fmt.Sprintf("%d:%s", c.Line, strings.Join(c.Args, "|")))
+	}
+	if fmt.Sprint(got) != "[3:-domains|-csv|-output|domains.csv 4:-daily|-json 6:-search|a b]" {
+		t.Errorf("commands = %v", got)
+	}
+	if _, err := readBatchFile(strings.NewReader("-daily\n-search \"x\n")); err == nil || !strings.Contains(err.Error(), "2行目") {
+		t.Errorf("閉じていない引用符の error = %v", err)
+	}
+}
+
+func TestParseBatchCommandsRejectsFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, line := range []string{"-daily -db other.db", "--serve=:8080", "-interactive"} {
+		commands, err := readBatchFile(strings.NewReader("-daily\n" + line + "\n"))
+		if err != nil {
+			t.Fatalf("readBatchFile: %v", err)
+		}
+		if err := parseBatchCommands("cmds.txt", commands); err == nil || !strings.HasPrefix(err.Error(), "cmds.txt:2: ") {
+			t.Errorf("%q の error = %v", line, err)
+		}
+	}
+}
+
+// TestParseBatchCommandsRejectsListManagement はリストを管理するフラグの行で、リストを変えずにエラーにするか
+func TestParseBatchCommandsRejectsListManagement(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, line := range []string{"-ignore-add example.com", "--ignore-remove=example.com", "-ignore-list", "-redact-add example.com", "-redact-list", "-docs-add pkg.go.dev", "-docs-list"} {
+		commands, err := readBatchFile(strings.NewReader("-daily -days 3\n" + line + "\n-domain-stats\n"))
+		if err != nil {
+			t.Fatalf("readBatchFile: %v", err)
+		}
+		if err := parseBatchCommands("cmds.txt", commands); err == nil || !strings.HasPrefix(err.Error(), "cmds.txt:2: ") {
+			t.Errorf("%q の error = %v", line, err)
+		}
+	}
+	ignore, err := LoadIgnoreList()
+	if err != nil {
+		t.Fatalf("LoadIgnoreList: %v", err)
+	}
+	if len(ignore) != 0 {
+		t.Errorf("イグノアリスト = %v, want 空", ignore)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	dir := t.TempDir()
+	domainsCSV := filepath.Join(dir, "domains.csv")
+	dailyJSON := filepath.Join(dir, "daily.json")
+	input := fmt.Sprintf("-domains 5 -csv -output %q\n# 0件でも続ける\n-search no-such-page -output %q\n-daily -days 100000 -json -output %q\n",
+		domainsCSV, filepath.Join(dir, "none.txt"), dailyJSON)
+	commands, err := readBatchFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readBatchFile: %v", err)
+	}
+	if err := parseBatchCommands("cmds.txt", commands); err != nil {
+		t.Fatalf("parseBatchCommands: %v", err)
+	}
+
+	var progress bytes.Buffer
+	if err := runBatch(db, "cmds.txt", commands, nil, &progress); err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	if got := progress.String(); !strings.Contains(got, "[1/3] -domains 5 -csv -output") || !strings.HasSuffix(got, "3件のコマンドを実行しました（結果が0件: 1件）\n") {
+		t.Errorf("進捗 = %q", got)
+	}
+
+	domains, err := os.ReadFile(domainsCSV)
+	if err != nil {
+		t.Fatalf("ドメインのCSVがない: %v", err)
+	}
+	if !strings.Contains(string(domains), "youtube.com") {
+		t.Errorf("domains.csv = %s", domains)
+	}
+	daily, err := os.ReadFile(dailyJSON)
+	if err != nil {
+		t.Fatalf("日別のJSONがない: %v", err)
+	}
+	if !strings.Contains(string(daily), `"daily_stats"`) {
+		t.Errorf("daily.json = %s", daily)
+	}
+}
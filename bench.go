@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// benchDriver は読み取った行数を数える hist bench 用のドライバ名
+	benchDriver = "sqlite3_hist_bench"
+	// defaultBenchRuns は hist bench で各クエリを実行する回数
+	defaultBenchRuns = 3
+)
+
+// benchRowCounter は benchDriver で開いた接続が SQLite から読み取った行数
+var benchRowCounter = &rowCountingDriver{Driver: newReadOnlyDriver()}
+
+func init() {
+	sql.Register(benchDriver, benchRowCounter)
+}
+
+// rowCountingDriver は SQLite のドライバ（newReadOnlyDriver）をラップし、クエリの結果から読み取った行数を数える
+type rowCountingDriver struct {
+	driver.Driver
+	rows atomic.Int64
+}
+
+// Open は行数を数える接続を開く
+func (d *rowCountingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rowCountingConn{Conn: conn, rows: &d.rows}, nil
+}
+
+// rowCountingConn は db.Query の結果を行数を数える driver.Rows にする接続
+type rowCountingConn struct {
+	driver.Conn
+	rows *atomic.Int64
+}
+
+// QueryContext は driver.QueryerContext の実装
+func (c *rowCountingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rowCountingRows{Rows: rows, count: c.rows}, nil
+}
+
+// ExecContext は driver.ExecerContext の実装（複数の文をまとめて実行できるようドライバにそのまま渡す）
+func (c *rowCountingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+// rowCountingRows は Next で読み取れた行を数える
+type rowCountingRows struct {
+	driver.Rows
+	count *atomic.Int64
+}
+
+// Next は driver.Rows の実装
+func (r *rowCountingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count.Add(1)
+	}
+	return err
+}
+
+// benchQuery は hist bench で計測する統計の集計
+type benchQuery struct {
+	name string
+	run  func(db dbQuerier, filter SearchFilter) error
+}
+
+// benchQueries は計測する統計（名前は -csv-split のファイル名と同じ）
+var benchQueries = []benchQuery{
+	{"total", func(db dbQuerier, _ SearchFilter) error {
+		_, err := getTotalVisits(db)
+		return err
+	}},
+	{"history", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getRecentVisits(db, DefaultHistoryLimit, filter)
+		return err
+	}},
+	{"domains", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getDomainStats(db, DefaultDomainLimit, filter)
+		return err
+	}},
+	{"paths", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getDomainPathStats(db, DefaultDomainLimit, DefaultPathLimit, filter)
+		return err
+	}},
+	{"hourly", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getHourlyStats(db, filter)
+		return err
+	}},
+	{"hourly_split", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getHourlySplitStats(db, filter, nil)
+		return err
+	}},
+	{"daily", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getDailyStats(db, DefaultDailyDays, time.Now(), filter)
+		return err
+	}},
+	{"title_clusters", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getTitleClusters(db, DefaultHistoryLimit, filter)
+		return err
+	}},
+	{"hourly_profiles", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getHourlyProfiles(db, DefaultDomainLimit, filter)
+		return err
+	}},
+	{"title_languages", func(db dbQuerier, filter SearchFilter) error {
+		_, err := getTitleLanguageStats(db, filter)
+		return err
+	}},
+}
+
+// BenchResult は1つの統計の計測結果（時間はミリ秒、行数は1回あたり）
+type BenchResult struct {
+	Name   string  `json:"name"`
+	MinMs  float64 `json:"min_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	Rows   int64   `json:"rows"`
+}
+
+// BenchReport は hist bench の結果（バージョン間で比較できるよう、バージョンと訪問数を添える）
+type BenchReport struct {
+	Version     string        `json:"version"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	TotalVisits int           `json:"total_visits"`
+	Keyword     string        `json:"keyword,omitempty"`
+	Runs        int           `json:"runs"`
+	Results     []BenchResult `json:"results"`
+}
+
+// runBenchCommand は hist bench サブコマンドを実行する
+func runBenchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	runs := fs.Int("runs", defaultBenchRuns, "各統計を実行する回数")
+	search := fs.String("search", "", "キーワードで絞り込んだ場合を計測")
+	noIgnore := fs.Bool("no-ignore", false, "イグノアリストを適用せずに計測")
+	cpuProfile := fs.String("cpuprofile", "", "CPUプロファイルを書き出すファイル（go tool pprof で表示）")
+	memProfile := fs.String("memprofile", "", "計測後のヒーププロファイルを書き出すファイル")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist bench [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  現在の履歴DBで主な統計の集計にかかる時間と読み取った行数を計測します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("-runs には1以上を指定してください: %d", *runs)
+	}
+
+	filter := SearchFilter{Keyword: *search}
+	if !*noIgnore {
+		entries, err := LoadIgnoreList()
+		if err != nil {
+			return fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+		}
+		filter.IgnoreDomains, filter.IgnoreRules, err = splitIgnoreEntries(entries)
+		if err != nil {
+			return err
+		}
+	}
+
+	dbPath, err := getDBPath()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(benchDriver, readOnlyDSN(dbPath, false))
+	if err != nil {
+		return fmt.Errorf("データベースを開けませんでした: %w", err)
+	}
+	configureDB(db)
+	defer func() { _ = db.Close() }()
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return fmt.Errorf("CPUプロファイルの作成に失敗: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("CPUプロファイルの開始に失敗: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	report, err := runBench(db, &benchRowCounter.rows, filter, *runs)
+	if err != nil {
+		return err
+	}
+
+	if *memProfile != "" {
+		if err := writeHeapProfile(*memProfile); err != nil {
+			return err
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printBenchReport(os.Stdout, report)
+	return nil
+}
+
+// runBench は benchQueries を runs 回ずつ実行し、最短・平均の時間と1回あたりの読み取り行数を返す
+// rows は db の接続が読み取った行数（benchDriver で開いた DB なら benchRowCounter.rows）
+func runBench(db dbQuerier, rows *atomic.Int64, filter SearchFilter, runs int) (BenchReport, error) {
+	report := BenchReport{
+		Version:     toolVersion(),
+		GeneratedAt: time.Now(),
+		Keyword:     filter.Keyword,
+		Runs:        runs,
+	}
+	total, err := getTotalVisits(db)
+	if err != nil {
+		return report, fmt.Errorf("総訪問数の取得に失敗: %w", err)
+	}
+	report.TotalVisits = total
+
+	for _, q := range benchQueries {
+		var sum, fastest time.Duration
+		before := rows.Load()
+		for i := range runs {
+			start := time.Now()
+			if err := q.run(db, filter); err != nil {
+				return report, fmt.Errorf("%s の計測に失敗: %w", q.name, err)
+			}
+			elapsed := time.Since(start)
+			sum += elapsed
+			if i == 0 || elapsed < fastest {
+				fastest = elapsed
+			}
+		}
+		report.Results = append(report.Results, BenchResult{
+			Name:   q.name,
+			MinMs:  durationMs(fastest),
+			MeanMs: durationMs(sum / time.Duration(runs)),
+			Rows:   (rows.Load() - before) / int64(runs),
+		})
+	}
+	return report, nil
+}
+
+// durationMs は時間をミリ秒（小数点以下3桁）にする
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// writeHeapProfile は GC 後のヒーププロファイルをファイルに書き出す
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ヒーププロファイルの作成に失敗: %w", err)
+	}
+	runtime.GC()
+	err = pprof.WriteHeapProfile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("ヒーププロファイルの書き込みに失敗: %w", err)
+	}
+	return nil
+}
+
+// printBenchReport は計測結果を表で出力する
+func printBenchReport(w io.Writer, report BenchReport) {
+	_, _ = fmt.Fprintf(w, "⏱  ベンチマーク (hist %s、総訪問数 %d、各%d回)\n", report.Version, report.TotalVisits, report.Runs)
+	if report.Keyword != "" {
+		_, _ = fmt.Fprintf(w, "   キーワード: %s\n", report.Keyword)
+	}
+	var rows [][]string
+	var sum float64
+	for _, r := range report.Results {
+		rows = append(rows, []string{r.Name, fmt.Sprintf("%.3f", r.MinMs), fmt.Sprintf("%.3f", r.MeanMs), fmt.Sprintf("%d", r.Rows)})
+		sum += r.MeanMs
+	}
+	rows = append(rows, []string{"合計", "", fmt.Sprintf("%.3f", sum), ""})
+	_, _ = fmt.Fprintln(w, renderTable([]string{"統計", "最短(ms)", "平均(ms)", "読み取り行数"}, rows, []int{1, 2, 3}, false))
+}
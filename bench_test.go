@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBench(t *testing.T) {
+	// benchDriver は読み取り以外の文を拒否するため、テストデータは別の接続で書き込む
+	path := filepath.Join(t.TempDir(), "History.db")
+	w, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatalf("テストDB作成に失敗: %v", err)
+	}
+	if _, err := w.Exec(testSchema); err != nil {
+		t.Fatalf("テーブル作成に失敗: %v", err)
+	}
+	insertTestData(t, w)
+	_ = w.Close()
+
+	db, err := sql.Open(benchDriver, readOnlyDSN(path, false))
+	if err != nil {
+		t.Fatalf("テストDBを開けませんでした: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(`DELETE FROM history_visits`); err == nil {
+		t.Error("benchDriver で DELETE を実行できた")
+	}
+
+	report, err := runBench(db, &benchRowCounter.rows, SearchFilter{}, 2)
+	if err != nil {
+		t.Fatalf("runBench() error = %v", err)
+	}
+	if report.TotalVisits != 5 || report.Runs != 2 || report.Version == "" {
+		t.Errorf("runBench() = %+v", report)
+	}
+	if len(report.Results) != len(benchQueries) {
+		t.Fatalf("結果 %d件, want %d", len(report.Results), len(benchQueries))
+	}
+	rows := make(map[string]int64)
+	for _, r := range report.Results {
+		rows[r.Name] = r.Rows
+		if r.MinMs < 0 || r.MeanMs < r.MinMs {
+			t.Errorf("%s: 最短 %.3fms, 平均 %.3fms", r.Name, r.MinMs, r.MeanMs)
+		}
+	}
+	// COUNT(*) は1行、最近の履歴は5件の訪問を1回あたりで数える
+	if rows["total"] != 1 || rows["history"] != 5 {
+		t.Errorf("読み取り行数 = %v", rows)
+	}
+
+	var buf bytes.Buffer
+	printBenchReport(&buf, report)
+	for _, want := range []string{"総訪問数 5、各2回", "domains", "読み取り行数", "合計"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("出力に %q が含まれない:\n%s", want, buf.String())
+		}
+	}
+}
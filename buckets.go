@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SplitWorkPersonal は -split で仕事用・個人用のドメインリストごとに全ての統計を並べて表示する指定
+const SplitWorkPersonal = "work/personal"
+
+// 仕事用・個人用のドメインリスト（1行1ドメイン、ホスト名で完全一致）
+const (
+	workFileName     = "work.txt"
+	personalFileName = "personal.txt"
+)
+
+// DomainBucket は名前付きのドメインリスト
+type DomainBucket struct {
+	Name    string
+	Domains []string
+}
+
+// BucketResult はドメインリストごとの分析結果
+type BucketResult struct {
+	Bucket  string   `json:"bucket"`
+	Domains []string `json:"domains"`
+	AnalysisResult
+}
+
+// SplitResult は -split work/personal の結果
+type SplitResult struct {
+	Buckets []BucketResult `json:"buckets"`
+}
+
+// LoadDomainBuckets は仕事用・個人用のドメインリストを読み込む
+// どちらかが空の場合は比較できないのでエラーにする
+func LoadDomainBuckets() ([]DomainBucket, error) {
+	var buckets []DomainBucket
+	for _, b := range []struct{ name, file string }{
+		{"work", workFileName},
+		{"personal", personalFileName},
+	} {
+		domains, err := loadDomainListFile(b.file, b.name+"リスト")
+		if err != nil {
+			return nil, err
+		}
+		if len(domains) == 0 {
+			path, err := getConfigFilePath(b.file)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%sリストが空です（%s に1行1ドメインで登録してください）", b.name, path)
+		}
+		for i, d := range domains {
+			domains[i] = strings.ToLower(d)
+		}
+		buckets = append(buckets, DomainBucket{Name: b.name, Domains: domains})
+	}
+	return buckets, nil
+}
+
+// runBucketSplit はドメインリストごとに分析し、結果を並べて出力する
+func runBucketSplit(db dbQuerier, config Config) error {
+	var split SplitResult
+	matched := false
+	for _, b := range config.Buckets {
+		c := config
+		c.Filter.Match = &MatchList{Domains: b.Domains}
+		result, err := analyze(db, c)
+		if err != nil {
+			return err
+		}
+		result, err = prepareResult(result, config)
+		if err != nil {
+			return err
+		}
+		matched = matched || result.hasMatches()
+		split.Buckets = append(split.Buckets, BucketResult{Bucket: b.Name, Domains: b.Domains, AnalysisResult: result})
+	}
+
+	output, closeOutput, err := openOutput(config.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	if config.outputFormat() == OutputFormatJSON {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(split); err != nil {
+			_ = closeOutput()
+			return fmt.Errorf("JSON出力エラー: %w", err)
+		}
+	} else {
+		printBucketSplit(output, split, config.TableBorder)
+	}
+	if err := closeOutput(); err != nil {
+		return err
+	}
+
+	if !matched {
+		return errNoMatches
+	}
+	return nil
+}
+
+// printBucketSplit はドメインリストごとの結果を統計ごとに横に並べて出力する
+// 各リストの列は順位ごとに並べ、時間帯別・日別は同じ時刻・日付の行に揃える
+func printBucketSplit(w io.Writer, split SplitResult, border bool) {
+	var names []string
+	for _, b := range split.Buckets {
+		names = append(names, b.Bucket)
+	}
+	_, _ = fmt.Fprintf(w, "\n📊 Safari 履歴分析結果（%s）\n\n", strings.Join(names, " / "))
+
+	// ranked は各リストの行（ラベル・値）を順位ごとに横に並べたセクションを出力する
+	ranked := func(title, label, value string, rows func(r AnalysisResult) [][2]string) {
+		var columns [][][2]string
+		maxRows := 0
+		for _, b := range split.Buckets {
+			col := rows(b.AnalysisResult)
+			columns = append(columns, col)
+			maxRows = max(maxRows, len(col))
+		}
+		if maxRows == 0 {
+			return
+		}
+
+		var headers []string
+		var rightAligned []int
+		for i, b := range split.Buckets {
+			headers = append(headers, b.Bucket+": "+label, value)
+			rightAligned = append(rightAligned, i*2+1)
+		}
+		table := make([][]string, maxRows)
+		for i := range table {
+			for _, col := range columns {
+				if i < len(col) {
+					table[i] = append(table[i], col[i][0], col[i][1])
+				} else {
+					table[i] = append(table[i], "", "")
+				}
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\n", title)
+		_, _ = fmt.Fprintln(w, renderTable(headers, table, rightAligned, border))
+		_, _ = fmt.Fprintln(w)
+	}
+
+	// keyed は時刻・日付などのキーごとに各リストの値を横に並べたセクションを出力する
+	keyed := func(title, key string, descending bool, rows func(r AnalysisResult) map[string]int) {
+		var columns []map[string]int
+		keySet := make(map[string]bool)
+		for _, b := range split.Buckets {
+			col := rows(b.AnalysisResult)
+			columns = append(columns, col)
+			for k := range col {
+				keySet[k] = true
+			}
+		}
+		if len(keySet) == 0 {
+			return
+		}
+
+		var keys []string
+		for k := range keySet {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if descending {
+				return keys[i] > keys[j]
+			}
+			return keys[i] < keys[j]
+		})
+
+		headers := []string{key}
+		var rightAligned []int
+		for i, b := range split.Buckets {
+			headers = append(headers, b.Bucket)
+			rightAligned = append(rightAligned, i+1)
+		}
+		var table [][]string
+		for _, k := range keys {
+			row := []string{k}
+			for _, col := range columns {
+				row = append(row, fmt.Sprintf("%d", col[k]))
+			}
+			table = append(table, row)
+		}
+		_, _ = fmt.Fprintf(w, "%s\n", title)
+		_, _ = fmt.Fprintln(w, renderTable(headers, table, rightAligned, border))
+		_, _ = fmt.Fprintln(w)
+	}
+
+	ranked("📝 最近の訪問履歴", "タイトル", "日時", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, v := range r.RecentVisits {
+			title := v.Title
+			if title == "" {
+				title = "(タイトルなし)"
+			}
+			rows = append(rows, [2]string{truncateText(title, TitleTruncateLength), v.VisitTime.Format(TimeFormatShort)})
+		}
+		return rows
+	})
+
+	ranked("🌐 ドメイン別訪問数", "ドメイン", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, s := range r.DomainStats {
+			rows = append(rows, [2]string{s.Domain, fmt.Sprintf("%d", s.VisitCount)})
+		}
+		return rows
+	})
+
+	ranked("🗂  ドメイン・パス別訪問数", "パス", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, ds := range r.DomainPathStats {
+			for _, p := range ds.Paths {
+				rows = append(rows, [2]string{truncateText(ds.Domain+p.Path, TitleTruncateLength), fmt.Sprintf("%d", p.VisitCount)})
+			}
+		}
+		return rows
+	})
+
+	keyed("⏰ 時間帯別訪問数", "時刻", false, func(r AnalysisResult) map[string]int {
+		counts := make(map[string]int)
+		for _, s := range r.HourlyStats {
+			counts[fmt.Sprintf("%02d:00", s.Hour)] = s.VisitCount
+		}
+		return counts
+	})
+
+	keyed("📅 日別訪問数", "日付", true, func(r AnalysisResult) map[string]int {
+		counts := make(map[string]int)
+		for _, s := range r.DailyStats {
+			counts[s.Date] = s.VisitCount
+		}
+		return counts
+	})
+
+	keyed("🗓  週別訪問数", "週", true, func(r AnalysisResult) map[string]int {
+		counts := make(map[string]int)
+		if r.WeeklyStats != nil {
+			for _, p := range r.WeeklyStats.Periods {
+				counts[p.periodLabel(PeriodUnitWeek)] = p.VisitCount
+			}
+		}
+		return counts
+	})
+
+	keyed("🗓  月別訪問数", "月", true, func(r AnalysisResult) map[string]int {
+		counts := make(map[string]int)
+		if r.MonthlyStats != nil {
+			for _, p := range r.MonthlyStats.Periods {
+				counts[p.periodLabel(PeriodUnitMonth)] = p.VisitCount
+			}
+		}
+		return counts
+	})
+
+	ranked("📰 タイトル別訪問数", "タイトル", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, c := range r.TitleClusters {
+			rows = append(rows, [2]string{truncateText(c.Title, TitleTruncateLength), fmt.Sprintf("%d", c.VisitCount)})
+		}
+		return rows
+	})
+
+	ranked("🎬 動画の視聴", "動画", "セッション", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		if r.Videos != nil {
+			for _, v := range r.Videos.Videos {
+				rows = append(rows, [2]string{truncateText(v.Title, TitleTruncateLength), fmt.Sprintf("%d", v.Sessions)})
+			}
+		}
+		return rows
+	})
+
+	ranked("🐙 GitHub リポジトリ", "リポジトリ", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		if r.GitHub != nil {
+			for _, repo := range r.GitHub.Repos {
+				rows = append(rows, [2]string{repo.Repo, fmt.Sprintf("%d", repo.VisitCount)})
+			}
+		}
+		return rows
+	})
+
+	ranked("📚 調べたドキュメント", "パス", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		if r.Docs != nil {
+			for _, l := range r.Docs.Lookups {
+				rows = append(rows, [2]string{truncateText(l.Path, TitleTruncateLength), fmt.Sprintf("%d", l.VisitCount)})
+			}
+		}
+		return rows
+	})
+
+	ranked("🕰  ドメイン別の時間帯プロファイル", "ドメイン", "ピーク", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, p := range r.HourlyProfiles {
+			rows = append(rows, [2]string{p.Domain, fmt.Sprintf("%02d:00", p.PeakHour)})
+		}
+		return rows
+	})
+
+	ranked("🏠 ローカル・社内ホスト", "ホスト", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, s := range r.LocalHosts {
+			rows = append(rows, [2]string{s.Addr(), fmt.Sprintf("%d", s.VisitCount)})
+		}
+		return rows
+	})
+
+	ranked("🔤 タイトルの言語別訪問数", "言語", "訪問数", func(r AnalysisResult) [][2]string {
+		var rows [][2]string
+		for _, l := range r.TitleLanguages {
+			rows = append(rows, [2]string{l.Label, fmt.Sprintf("%d", l.VisitCount)})
+		}
+		return rows
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadDomainBuckets は仕事用・個人用ドメインリストの読み込みのテスト
+func TestLoadDomainBuckets(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if _, err := LoadDomainBuckets(); err == nil {
+		t.Error("リストが空の場合はエラーにするはず")
+	}
+
+	if err := saveDomainListFile(workFileName, "workリスト", []string{"GitHub.com"}); err != nil {
+		t.Fatalf("workリストの保存に失敗: %v", err)
+	}
+	if _, err := LoadDomainBuckets(); err == nil || !strings.Contains(err.Error(), "personal") {
+		t.Errorf("personalリストが空の場合のエラーが期待値と異なる: %v", err)
+	}
+
+	if err := saveDomainListFile(personalFileName, "personalリスト", []string{"youtube.com"}); err != nil {
+		t.Fatalf("personalリストの保存に失敗: %v", err)
+	}
+	buckets, err := LoadDomainBuckets()
+	if err != nil {
+		t.Fatalf("LoadDomainBuckets失敗: %v", err)
+	}
+	if len(buckets) != 2 || buckets[0].Name != "work" || buckets[0].Domains[0] != "github.com" || buckets[1].Name != "personal" {
+		t.Errorf("ドメインリストが期待値と異なる: %+v", buckets)
+	}
+}
+
+// TestRunBucketSplit はドメインリストごとの分析のテスト
+func TestRunBucketSplit(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	outputFile := filepath.Join(t.TempDir(), "split.json")
+	config := Config{
+		Limit:       10,
+		DomainLimit: 10,
+		ShowHistory: true,
+		ShowDomains: true,
+		ShowHourly:  true,
+		JSONOutput:  true,
+		OutputFile:  outputFile,
+		BucketSplit: true,
+		Buckets: []DomainBucket{
+			{Name: "work", Domains: []string{"github.com"}},
+			{Name: "personal", Domains: []string{"youtube.com"}},
+		},
+	}
+	if err := runBucketSplit(db, config); err != nil {
+		t.Fatalf("runBucketSplit失敗: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("出力ファイルの読み込みに失敗: %v", err)
+	}
+	var split SplitResult
+	if err := json.Unmarshal(data, &split); err != nil {
+		t.Fatalf("JSONのパースに失敗: %v", err)
+	}
+	if len(split.Buckets) != 2 {
+		t.Fatalf("バケット数が期待値と異なる: %+v", split.Buckets)
+	}
+	work, personal := split.Buckets[0], split.Buckets[1]
+	if work.Bucket != "work" || len(work.RecentVisits) != 2 || len(work.DomainStats) != 1 || work.DomainStats[0].Domain != "github.com" {
+		t.Errorf("workの結果が期待値と異なる: %+v", work)
+	}
+	if personal.Bucket != "personal" || len(personal.RecentVisits) != 2 || personal.DomainStats[0].Domain != "youtube.com" {
+		t.Errorf("personalの結果が期待値と異なる: %+v", personal)
+	}
+
+	// どちらのリストにも一致しなければ errNoMatches
+	config.Buckets = []DomainBucket{{Name: "work", Domains: []string{"none.example"}}, {Name: "personal", Domains: []string{"none2.example"}}}
+	if err := runBucketSplit(db, config); err != errNoMatches {
+		t.Errorf("一致なしの場合は errNoMatches のはず: %v", err)
+	}
+}
+
+// TestPrintBucketSplit はドメインリストごとの結果を横に並べる出力のテスト
+func TestPrintBucketSplit(t *testing.T) {
+	split := SplitResult{Buckets: []BucketResult{
+		{Bucket: "work", AnalysisResult: AnalysisResult{
+			DomainStats: []DomainStats{{Domain: "github.com", VisitCount: 10}, {Domain: "slack.com", VisitCount: 4}},
+			HourlyStats: []HourlyStats{{Hour: 10, VisitCount: 7}},
+		}},
+		{Bucket: "personal", AnalysisResult: AnalysisResult{
+			DomainStats: []DomainStats{{Domain: "youtube.com", VisitCount: 25}},
+			HourlyStats: []HourlyStats{{Hour: 22, VisitCount: 9}},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	printBucketSplit(&buf, split, false)
+	out := buf.String()
+
+	lines := strings.Split(out, "\n")
+	found := false
+	for _, line := range lines {
+		if strings.Contains(line, "github.com") && strings.Contains(line, "youtube.com") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("1位のドメインが同じ行に並んでいない:\n%s", out)
+	}
+	for _, want := range []string{"work: ドメイン", "personal: ドメイン", "slack.com", "10:00", "22:00"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれない:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "最近の訪問履歴") {
+		t.Errorf("データのない統計は出力しないはず:\n%s", out)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendarFileName は週の始まり・年度の始まりの設定ファイル
+// 1行に「キー = 値」を書く:
+//
+//	week_start = sunday        週の始まり（sun〜sat、既定は mon）
+//	fiscal_year_start = 4      年度の始まりの月（1〜12、既定は 1）
+const calendarFileName = "calendar.txt"
+
+const (
+	// DefaultWeeklyPeriods は -weekly で表示する週の数
+	DefaultWeeklyPeriods = 12
+	// DefaultMonthlyPeriods は -monthly で表示する月の数
+	DefaultMonthlyPeriods = 12
+)
+
+// 週別・月別統計の集計単位
+const (
+	PeriodUnitWeek  = "week"
+	PeriodUnitMonth = "month"
+)
+
+// Calendar は週別・月別の集計で使う暦の区切り
+type Calendar struct {
+	// WeekStart は週の始まりの曜日
+	WeekStart time.Weekday
+	// FiscalYearStart は年度の始まりの月（1月なら暦年と同じ）
+	FiscalYearStart time.Month
+}
+
+// defaultCalendar は calendar.txt がない場合の区切り（月曜始まり・暦年）
+var defaultCalendar = Calendar{WeekStart: time.Monday, FiscalYearStart: time.January}
+
+// parseCalendar は calendar.txt の行を解析する（書かれていない項目は既定値）
+func parseCalendar(lines []string) (Calendar, error) {
+	cal := defaultCalendar
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Calendar{}, fmt.Errorf("暦の設定は「キー = 値」形式で指定してください: %s", line)
+		}
+		key, value = strings.TrimSpace(key), strings.ToLower(strings.TrimSpace(value))
+		switch key {
+		case "week_start":
+			d := parseWeekday(value)
+			if d == -1 {
+				return Calendar{}, fmt.Errorf("week_start には曜日（sun〜sat）を指定してください: %s", value)
+			}
+			cal.WeekStart = time.Weekday(d)
+		case "fiscal_year_start":
+			m, err := strconv.Atoi(value)
+			if err != nil || m < 1 || m > 12 {
+				return Calendar{}, fmt.Errorf("fiscal_year_start には月（1〜12）を指定してください: %s", value)
+			}
+			cal.FiscalYearStart = time.Month(m)
+		default:
+			return Calendar{}, fmt.Errorf("暦の設定に不明なキーがあります: %s", key)
+		}
+	}
+	return cal, nil
+}
+
+// parseWeekday は曜日の略称（sun）か英語名（sunday）を 0=日曜 のインデックスに変換する
+func parseWeekday(name string) int {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) {
+			return int(d)
+		}
+	}
+	return weekdayIndex(name)
+}
+
+// LoadCalendar は暦の設定を読み込む
+func LoadCalendar() (Calendar, error) {
+	lines, err := loadDomainListFile(calendarFileName, "暦の設定")
+	if err != nil {
+		return Calendar{}, err
+	}
+	return parseCalendar(lines)
+}
+
+// weekStart は t を含む週の始まり（WeekStart の0時）を返す
+func (c Calendar) weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(c.WeekStart) + 7) % 7))
+}
+
+// monthStart は t を含む月の1日0時を返す
+func (c Calendar) monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// fiscalYear は t を含む年度を、年度が始まる年で返す（4月始まりなら 2026年3月は 2025年度）
+func (c Calendar) fiscalYear(t time.Time) int {
+	if t.Month() < c.FiscalYearStart {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// fiscalYearLabel は年度の表示名（1月始まりなら暦年と同じなので「年」）
+func (c Calendar) fiscalYearLabel(year int) string {
+	if c.FiscalYearStart == time.January {
+		return fmt.Sprintf("%d年", year)
+	}
+	return fmt.Sprintf("%d年度", year)
+}
+
+// PeriodStats は週別・月別の1期間の訪問数
+// Start・End は期間の最初と最後の日付、FiscalYear は期間の始まりを含む年度
+type PeriodStats struct {
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	FiscalYear int    `json:"fiscal_year"`
+	VisitCount int    `json:"visit_count"`
+}
+
+// PeriodReport は週別・月別の統計と、集計に使った暦の区切り
+type PeriodReport struct {
+	Unit            string        `json:"unit"`
+	WeekStart       string        `json:"week_start"`
+	FiscalYearStart int           `json:"fiscal_year_start"`
+	Periods         []PeriodStats `json:"periods"`
+}
+
+// calendar は集計に使った暦の区切りを返す
+func (r PeriodReport) calendar() Calendar {
+	return Calendar{WeekStart: time.Weekday(weekdayIndex(r.WeekStart)), FiscalYearStart: time.Month(r.FiscalYearStart)}
+}
+
+// getPeriodStats は now を含む期間から遡って periods 期間分の訪問数を返す（新しい順、訪問のない期間も含む）
+// unit は PeriodUnitWeek か PeriodUnitMonth。時刻は now と同じタイムゾーンで区切る
+func getPeriodStats(db dbQuerier, unit string, periods int, cal Calendar, now time.Time, filter SearchFilter) (*PeriodReport, error) {
+	start, shift := cal.weekStart, func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) }
+	if unit == PeriodUnitMonth {
+		start, shift = cal.monthStart, func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) }
+	}
+
+	report := &PeriodReport{
+		Unit:            unit,
+		WeekStart:       weekdayNames[cal.WeekStart],
+		FiscalYearStart: int(cal.FiscalYearStart),
+		Periods:         make([]PeriodStats, periods),
+	}
+	current := start(now)
+	index := make(map[time.Time]int, periods)
+	for i := range report.Periods {
+		s := shift(current, -i)
+		report.Periods[i] = PeriodStats{
+			Start:      s.Format(TimeFormatDate),
+			End:        shift(s, 1).AddDate(0, 0, -1).Format(TimeFormatDate),
+			FiscalYear: cal.fiscalYear(s),
+		}
+		index[s] = i
+	}
+	if periods == 0 {
+		return report, nil
+	}
+
+	if oldest := shift(current, 1-periods); filter.From.Before(oldest) {
+		filter.From = oldest
+	}
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		if i, ok := index[start(v.VisitTime.In(now.Location()))]; ok {
+			report.Periods[i].VisitCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("期間別統計の取得に失敗: %w", err)
+	}
+	return report, nil
+}
+
+// periodTitle は週別・月別統計の見出し
+func (r PeriodReport) periodTitle() string {
+	if r.Unit == PeriodUnitMonth {
+		return fmt.Sprintf("🗓  月別訪問数 (過去%dか月)", len(r.Periods))
+	}
+	return fmt.Sprintf("🗓  週別訪問数 (過去%d週間、%s始まり)", len(r.Periods), weekdayLabel(r.calendar().WeekStart))
+}
+
+// periodLabel は期間の表示名（週は「2025-01-06〜01-12」、月は「2025-01」）
+func (p PeriodStats) periodLabel(unit string) string {
+	if unit == PeriodUnitMonth {
+		return p.Start[:7]
+	}
+	return p.Start + "〜" + p.End[5:]
+}
+
+// printPeriodStats は週別・月別の訪問数を棒グラフで出力する
+// 月別は年度が変わるところに年度の見出しを入れる
+func printPeriodStats(w io.Writer, report *PeriodReport) {
+	_, _ = fmt.Fprintf(w, "%s\n", report.periodTitle())
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	maxCount := 0
+	for _, p := range report.Periods {
+		maxCount = max(maxCount, p.VisitCount)
+	}
+	cal := report.calendar()
+	fiscalYear := 0
+	for _, p := range report.Periods {
+		barLen := 0
+		if maxCount > 0 {
+			barLen = int(float64(p.VisitCount) / float64(maxCount) * BarChartWidth)
+		}
+		if report.Unit == PeriodUnitMonth && p.FiscalYear != fiscalYear {
+			fiscalYear = p.FiscalYear
+			_, _ = fmt.Fprintf(w, "  %s\n", cal.fiscalYearLabel(fiscalYear))
+		}
+		_, _ = fmt.Fprintf(w, "  %s  %s %d\n", p.periodLabel(report.Unit), strings.Repeat("█", barLen), p.VisitCount)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// weekdayLabel は曜日の表示名
+func weekdayLabel(d time.Weekday) string {
+	return [...]string{"日曜", "月曜", "火曜", "水曜", "木曜", "金曜", "土曜"}[d]
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCalendar(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		want    Calendar
+		wantErr bool
+	}{
+		{nil, defaultCalendar, false},
+		{[]string{"week_start = sunday", "fiscal_year_start = 4"}, Calendar{WeekStart: time.Sunday, FiscalYearStart: time.April}, false},
+		{[]string{"week_start=Sat"}, Calendar{WeekStart: time.Saturday, FiscalYearStart: time.January}, false},
+		{[]string{"week_start = someday"}, Calendar{}, true},
+		{[]string{"fiscal_year_start = 13"}, Calendar{}, true},
+		{[]string{"week_start sunday"}, Calendar{}, true},
+		{[]string{"timezone = UTC"}, Calendar{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCalendar(tt.lines)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCalendar(%q) error = %v, wantErr %v", tt.lines, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("parseCalendar(%q) = %+v, want %+v", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarPeriods(t *testing.T) {
+	// 2025-01-01 は水曜日
+	wed := time.Date(2025, 1, 1, 15, 30, 0, 0, time.UTC)
+	if got := defaultCalendar.weekStart(wed); !got.Equal(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("月曜始まりの週の開始 = %v", got)
+	}
+	sunday := Calendar{WeekStart: time.Sunday, FiscalYearStart: time.April}
+	if got := sunday.weekStart(wed); !got.Equal(time.Date(2024, 12, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("日曜始まりの週の開始 = %v", got)
+	}
+	if got := sunday.fiscalYear(wed); got != 2024 {
+		t.Errorf("4月始まりで 2025-01 の年度 = %d, want 2024", got)
+	}
+	if got := sunday.fiscalYear(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)); got != 2025 {
+		t.Errorf("4月始まりで 2025-04 の年度 = %d, want 2025", got)
+	}
+	if got := defaultCalendar.fiscalYear(wed); got != 2025 {
+		t.Errorf("1月始まりで 2025-01 の年度 = %d, want 2025", got)
+	}
+}
+
+func TestGetPeriodStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// テストデータは 2025-01-01（水）に3件、2025-01-02（木）に2件
+	now := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+
+	// 月曜始まりなら 2025-01-06 の週には訪問がなく、前の週（12-30〜01-05）に5件
+	weekly, err := getPeriodStats(db, PeriodUnitWeek, 3, defaultCalendar, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getPeriodStats() error = %v", err)
+	}
+	if len(weekly.Periods) != 3 || weekly.WeekStart != "mon" {
+		t.Fatalf("週別統計 = %+v", weekly)
+	}
+	if p := weekly.Periods[0]; p.Start != "2025-01-06" || p.End != "2025-01-12" || p.VisitCount != 0 {
+		t.Errorf("今週 = %+v", p)
+	}
+	if p := weekly.Periods[1]; p.Start != "2024-12-30" || p.VisitCount != 5 {
+		t.Errorf("先週 = %+v", p)
+	}
+
+	// 木曜始まりなら 01-01（水）と 01-02（木）は別の週になる
+	thursday := Calendar{WeekStart: time.Thursday, FiscalYearStart: time.January}
+	weekly, err = getPeriodStats(db, PeriodUnitWeek, 2, thursday, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getPeriodStats() error = %v", err)
+	}
+	if weekly.Periods[0].Start != "2025-01-02" || weekly.Periods[0].VisitCount != 2 || weekly.Periods[1].VisitCount != 3 {
+		t.Errorf("木曜始まりの週別統計 = %+v", weekly.Periods)
+	}
+
+	fiscal := Calendar{WeekStart: time.Monday, FiscalYearStart: time.April}
+	monthly, err := getPeriodStats(db, PeriodUnitMonth, 2, fiscal, now, SearchFilter{Keyword: "github"})
+	if err != nil {
+		t.Fatalf("getPeriodStats() error = %v", err)
+	}
+	if p := monthly.Periods[0]; p.Start != "2025-01-01" || p.End != "2025-01-31" || p.FiscalYear != 2024 || p.VisitCount != 2 {
+		t.Errorf("今月 = %+v", p)
+	}
+	if p := monthly.Periods[1]; p.Start != "2024-12-01" || p.VisitCount != 0 {
+		t.Errorf("先月 = %+v", p)
+	}
+
+	var buf bytes.Buffer
+	printPeriodStats(&buf, monthly)
+	if out := buf.String(); !strings.Contains(out, "2024年度") || !strings.Contains(out, "2025-01") {
+		t.Errorf("月別統計の出力に年度の見出しがない:\n%s", out)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 変化点の検出に使う期間と判定の基準
+const (
+	// changepointHistoryDays は -from・-to がないときに調べる過去の日数（今日は途中のため含めない）
+	changepointHistoryDays = 365
+	// changepointWindow は変化点の前後で比べる日数（曜日の偏りが出ないよう1週間の倍数にする）
+	changepointWindow = 14
+	// changepointVolumeScore は1日あたりの訪問数の差を前後のばらつきで割った値の基準（Welch の t 値）
+	changepointVolumeScore = 4.0
+	// changepointMixDistance はドメインの割合の差（全変動距離、0〜1）の基準
+	changepointMixDistance = 0.3
+	// changepointTopDomains は前後それぞれで比べる上位ドメインの数
+	changepointTopDomains = 5
+)
+
+// 変化点の種類
+const (
+	// ChangeVolume は1日あたりの訪問数の変化
+	ChangeVolume = "volume"
+	// ChangeDomains はドメインの割合（よく見るサイトの顔ぶれ）の変化
+	ChangeDomains = "domains"
+)
+
+// ChangepointDomain は変化点の前後の期間でのドメインの訪問の割合
+type ChangepointDomain struct {
+	Domain      string  `json:"domain"`
+	BeforeShare float64 `json:"before_share"`
+	AfterShare  float64 `json:"after_share"`
+}
+
+// Changepoint は検出した変化点（Date からの changepointWindow 日間をその前の同じ日数と比べる）
+type Changepoint struct {
+	Date  string   `json:"date"`
+	Kinds []string `json:"kinds"`
+	// BeforeAvg・AfterAvg は前後の期間の1日あたりの訪問数
+	BeforeAvg   float64 `json:"before_daily_avg"`
+	AfterAvg    float64 `json:"after_daily_avg"`
+	VolumeScore float64 `json:"volume_score"`
+	MixDistance float64 `json:"mix_distance"`
+	// Domains は前後の上位ドメインを割合の差の大きい順に並べたもの
+	Domains []ChangepointDomain `json:"domains"`
+}
+
+// ChangepointReport は -changepoints の結果
+type ChangepointReport struct {
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	Days         int           `json:"days"`
+	Window       int           `json:"window_days"`
+	Changepoints []Changepoint `json:"changepoints"`
+}
+
+// changepointRange は変化点を調べる日付の範囲（両端を含む）
+// -from・-to があればその範囲、なければ昨日までの過去 changepointHistoryDays 日間
+func changepointRange(now time.Time, filter SearchFilter) (time.Time, time.Time) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -1)
+	if !filter.To.IsZero() {
+		to = filter.To
+	}
+	from := to.AddDate(0, 0, 1-changepointHistoryDays)
+	if !filter.From.IsZero() {
+		from = filter.From
+	}
+	return from, to
+}
+
+// getChangepoints は期間内の日別訪問数とドメインの割合から、使い方が大きく変わった日を探す
+// 履歴の最初の日より前は訪問のない日として数えない（Safari を使い始めた日を変化点にしない）
+func getChangepoints(db dbQuerier, redactDomains []string, now time.Time, filter SearchFilter) (*ChangepointReport, error) {
+	from, to := changepointRange(now, filter)
+	if from.After(to) {
+		return nil, fmt.Errorf("変化点を調べる期間が不正です: %s〜%s", from.Format(TimeFormatDate), to.Format(TimeFormatDate))
+	}
+	dim, err := newGroupDimension(GroupByDomain, groupOptions{redactDomains: redactDomains})
+	if err != nil {
+		return nil, err
+	}
+	query, args := NewQueryBuilder(groupVisitsBaseQuery).WithFilter(filter).Build()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("変化点の検出に使う履歴の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	first, last := from.Format(TimeFormatDate), to.Format(TimeFormatDate)
+	earliest := ""
+	domainCounts := make(map[string]map[string]int)
+	for rows.Next() {
+		var v groupVisit
+		var visitTime float64
+		if err := rows.Scan(&v.id, &visitTime, &v.url); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		v.time = convertCoreDataTimestamp(visitTime)
+		date := v.time.Format(TimeFormatDate)
+		if earliest == "" || date < earliest {
+			earliest = date
+		}
+		if date < first || date > last {
+			continue
+		}
+		if domainCounts[date] == nil {
+			domainCounts[date] = make(map[string]int)
+		}
+		domainCounts[date][dim.keyOf(v)]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	var days []map[string]int
+	var dates []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if date := d.Format(TimeFormatDate); earliest != "" && date >= earliest {
+			dates = append(dates, date)
+			days = append(days, domainCounts[date])
+		}
+	}
+	report := &ChangepointReport{From: first, To: last, Days: len(dates), Window: changepointWindow, Changepoints: []Changepoint{}}
+	if len(dates) > 0 {
+		report.From = dates[0]
+	}
+	for _, i := range detectChangepoints(days, changepointWindow) {
+		report.Changepoints = append(report.Changepoints, compareChangepoint(dates[i], days[i-changepointWindow:i], days[i:i+changepointWindow]))
+	}
+	return report, nil
+}
+
+// detectChangepoints は日別のドメインごとの訪問数から変化点の位置を日付順に返す
+// 各日についてその日からの window 日間とその前の window 日間を比べ、基準を超えた日のうち最も変化の大きい日から、
+// すでに選んだ日と window 日以上離れた日を選ぶ（1つの変化を前後の日で何度も数えない）
+func detectChangepoints(days []map[string]int, window int) []int {
+	type candidate struct {
+		index    int
+		strength float64
+	}
+	var candidates []candidate
+	for i := window; i+window <= len(days); i++ {
+		before, after := days[i-window:i], days[i:i+window]
+		strength := max(math.Abs(volumeScore(before, after))/changepointVolumeScore, mixDistance(before, after)/changepointMixDistance)
+		if strength >= 1 {
+			candidates = append(candidates, candidate{i, strength})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].strength > candidates[j].strength
+	})
+	var selected []int
+	for _, c := range candidates {
+		near := false
+		for _, s := range selected {
+			if c.index-s < window && s-c.index < window {
+				near = true
+				break
+			}
+		}
+		if !near {
+			selected = append(selected, c.index)
+		}
+	}
+	sort.Ints(selected)
+	return selected
+}
+
+// dailyTotals は日ごとの訪問数の合計
+func dailyTotals(days []map[string]int) []float64 {
+	totals := make([]float64, len(days))
+	for i, counts := range days {
+		for _, c := range counts {
+			totals[i] += float64(c)
+		}
+	}
+	return totals
+}
+
+// meanAndVariance は平均と不偏分散を返す
+func meanAndVariance(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(values)-1)
+}
+
+// volumeScore は前後の1日あたりの訪問数の差を前後のばらつきで割った値（Welch の t 値、増えたら正）
+// 前後とも毎日同じ件数のときに発散しないよう、ばらつきに1を足す
+func volumeScore(before, after []map[string]int) float64 {
+	beforeMean, beforeVar := meanAndVariance(dailyTotals(before))
+	afterMean, afterVar := meanAndVariance(dailyTotals(after))
+	return (afterMean - beforeMean) / math.Sqrt((beforeVar+afterVar+1)/float64(len(before)))
+}
+
+// domainShares は期間内のドメインごとの訪問の割合と訪問数の合計を返す
+func domainShares(days []map[string]int) (map[string]float64, int) {
+	total := 0
+	counts := make(map[string]int)
+	for _, day := range days {
+		for domain, c := range day {
+			counts[domain] += c
+			total += c
+		}
+	}
+	shares := make(map[string]float64, len(counts))
+	for domain, c := range counts {
+		shares[domain] = float64(c) / float64(total)
+	}
+	return shares, total
+}
+
+// mixDistance は前後のドメインの割合の全変動距離（0〜1、どちらかに訪問がなければ0）
+func mixDistance(before, after []map[string]int) float64 {
+	beforeShares, beforeTotal := domainShares(before)
+	afterShares, afterTotal := domainShares(after)
+	if beforeTotal == 0 || afterTotal == 0 {
+		return 0
+	}
+	distance := 0.0
+	for domain, share := range beforeShares {
+		distance += math.Abs(share - afterShares[domain])
+	}
+	for domain, share := range afterShares {
+		if _, ok := beforeShares[domain]; !ok {
+			distance += share
+		}
+	}
+	return distance / 2
+}
+
+// topShareDomains は割合の大きい順に上位 n 件のドメインを返す
+func topShareDomains(shares map[string]float64, n int) []string {
+	var domains []string
+	for domain := range shares {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if shares[domains[i]] != shares[domains[j]] {
+			return shares[domains[i]] > shares[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	return domains[:min(n, len(domains))]
+}
+
+// compareChangepoint は変化点の前後の期間の訪問数と上位ドメインの割合を並べる
+func compareChangepoint(date string, before, after []map[string]int) Changepoint {
+	cp := Changepoint{
+		Date:        date,
+		Kinds:       []string{},
+		VolumeScore: volumeScore(before, after),
+		MixDistance: mixDistance(before, after),
+		Domains:     []ChangepointDomain{},
+	}
+	cp.BeforeAvg, _ = meanAndVariance(dailyTotals(before))
+	cp.AfterAvg, _ = meanAndVariance(dailyTotals(after))
+	if math.Abs(cp.VolumeScore) >= changepointVolumeScore {
+		cp.Kinds = append(cp.Kinds, ChangeVolume)
+	}
+	if cp.MixDistance >= changepointMixDistance {
+		cp.Kinds = append(cp.Kinds, ChangeDomains)
+	}
+
+	beforeShares, _ := domainShares(before)
+	afterShares, _ := domainShares(after)
+	seen := make(map[string]bool)
+	for _, domain := range append(topShareDomains(beforeShares, changepointTopDomains), topShareDomains(afterShares, changepointTopDomains)...) {
+		if !seen[domain] {
+			seen[domain] = true
+			cp.Domains = append(cp.Domains, ChangepointDomain{Domain: domain, BeforeShare: beforeShares[domain], AfterShare: afterShares[domain]})
+		}
+	}
+	sort.SliceStable(cp.Domains, func(i, j int) bool {
+		return math.Abs(cp.Domains[i].AfterShare-cp.Domains[i].BeforeShare) > math.Abs(cp.Domains[j].AfterShare-cp.Domains[j].BeforeShare)
+	})
+	return cp
+}
+
+// changeKindLabel は変化点の種類の表示名
+func changeKindLabel(kind string) string {
+	switch kind {
+	case ChangeVolume:
+		return "訪問数"
+	case ChangeDomains:
+		return "ドメイン構成"
+	}
+	return kind
+}
+
+// kindsLabel は変化点の種類を「・」でつなげた表示
+func (cp Changepoint) kindsLabel() string {
+	labels := make([]string, len(cp.Kinds))
+	for i, kind := range cp.Kinds {
+		labels[i] = changeKindLabel(kind)
+	}
+	return strings.Join(labels, "・")
+}
+
+// volumeChange は前の期間からの1日あたりの訪問数の増減率の表示（前が0件なら空）
+func (cp Changepoint) volumeChange() string {
+	if cp.BeforeAvg == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.0f%%", (cp.AfterAvg-cp.BeforeAvg)/cp.BeforeAvg*100)
+}
+
+// changepointTitle は -changepoints の見出し
+func changepointTitle(report ChangepointReport) string {
+	return fmt.Sprintf("🔀 ブラウジングの変化点 (%s〜%s、%d日間、前後%d日間で比較)", report.From, report.To, report.Days, report.Window)
+}
+
+// printChangepoints は変化点と前後の上位ドメインの割合をテキストで表示する
+func printChangepoints(w io.Writer, report ChangepointReport) {
+	_, _ = fmt.Fprintln(w, changepointTitle(report))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	if len(report.Changepoints) == 0 {
+		_, _ = fmt.Fprintf(w, "  大きな変化は見つかりませんでした（前後%d日間ずつの履歴が必要です）\n\n", report.Window)
+		return
+	}
+	for _, cp := range report.Changepoints {
+		volume := fmt.Sprintf("1日 %.1f件 → %.1f件", cp.BeforeAvg, cp.AfterAvg)
+		if change := cp.volumeChange(); change != "" {
+			volume += fmt.Sprintf("（%s）", change)
+		}
+		_, _ = fmt.Fprintf(w, "  %s [%s] %s、ドメイン構成の変化 %.0f%%\n", cp.Date, cp.kindsLabel(), volume, cp.MixDistance*100)
+		for _, d := range cp.Domains {
+			_, _ = fmt.Fprintf(w, "    %s %5.1f%% → %5.1f%%\n", padRight(truncateText(d.Domain, 30), 32), d.BeforeShare*100, d.AfterShare*100)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// changepointDays は n 日分の同じドメインの訪問数を並べる（偶数日は jitter 件多くする）
+func changepointDays(n, jitter int, counts map[string]int) []map[string]int {
+	days := make([]map[string]int, n)
+	for i := range days {
+		days[i] = make(map[string]int)
+		for domain, c := range counts {
+			days[i][domain] = c
+			if i%2 == 0 {
+				days[i][domain] += jitter
+			}
+		}
+	}
+	return days
+}
+
+func TestDetectChangepoints(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		days []map[string]int
+		want string
+	}{
+		{"変化なし", changepointDays(60, 3, map[string]int{"a.com": 10, "b.com": 5}), "[]"},
+		{"訪問数の変化", append(changepointDays(30, 2, map[string]int{"a.com": 10}), changepointDays(30, 2, map[string]int{"a.com": 30})...), "[30]"},
+		{"ドメイン構成の変化", append(changepointDays(30, 2, map[string]int{"a.com": 10, "b.com": 10}), changepointDays(30, 2, map[string]int{"a.com": 10, "c.com": 10})...), "[30]"},
+		{"2つの変化", append(append(changepointDays(20, 1, map[string]int{"a.com": 10}), changepointDays(20, 1, map[string]int{"b.com": 10})...), changepointDays(20, 1, map[string]int{"b.com": 30})...), "[20 40]"},
+		// 前後 window 日間ずつの履歴がなければ調べない
+		{"短い履歴", append(changepointDays(10, 0, map[string]int{"a.com": 10}), changepointDays(10, 0, map[string]int{"a.com": 50})...), "[]"},
+	} {
+		if got := fmt.Sprint(detectChangepoints(tt.days, changepointWindow)); got != tt.want {
+			t.Errorf("%s: detectChangepoints() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompareChangepoint(t *testing.T) {
+	before := changepointDays(14, 0, map[string]int{"a.com": 6, "b.com": 4})
+	after := changepointDays(14, 0, map[string]int{"a.com": 6, "c.com": 14})
+	cp := compareChangepoint("2025-03-01", before, after)
+	if fmt.Sprint(cp.Kinds) != "[volume domains]" || cp.BeforeAvg != 10 || cp.AfterAvg != 20 || cp.volumeChange() != "+100%" {
+		t.Errorf("変化点 = %+v", cp)
+	}
+	if got := fmt.Sprintf("%.2f", cp.MixDistance); got != "0.70" {
+		t.Errorf("MixDistance = %s, want 0.70", got)
+	}
+	// 割合の差の大きい順
+	if got := fmt.Sprint(cp.Domains); got != "[{c.com 0 0.7} {b.com 0.4 0} {a.com 0.6 0.3}]" {
+		t.Errorf("Domains = %s", got)
+	}
+	if got := cp.kindsLabel(); got != "訪問数・ドメイン構成" {
+		t.Errorf("kindsLabel() = %s", got)
+	}
+}
+
+func TestGetChangepoints(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(`INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://news.example.com/', NULL, 0), (2, 'https://work.example.com/', NULL, 0)`); err != nil {
+		t.Fatalf("history_items挿入に失敗: %v", err)
+	}
+	// 2025-01-01 から30日間は news を1日5件、その後の30日間は work を1日5件
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	id := 0
+	for day := range 60 {
+		item := 1
+		if day >= 30 {
+			item = 2
+		}
+		for i := range 5 {
+			id++
+			visitTime := convertToTimestamp(start.AddDate(0, 0, day).Add(time.Duration(i) * time.Minute))
+			if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (?, ?, ?, '')`, id, item, visitTime); err != nil {
+				t.Fatalf("history_visits挿入に失敗: %v", err)
+			}
+		}
+	}
+
+	// 履歴の最初の日より前は数えず、今日は途中のため含めない
+	report, err := getChangepoints(db, []string{"news.example.com"}, time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC), SearchFilter{})
+	if err != nil {
+		t.Fatalf("getChangepoints: %v", err)
+	}
+	if report.From != "2025-01-01" || report.To != "2025-03-01" || report.Days != 60 || len(report.Changepoints) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	cp := report.Changepoints[0]
+	if cp.Date != "2025-01-31" || fmt.Sprint(cp.Kinds) != "[domains]" {
+		t.Errorf("変化点 = %+v", cp)
+	}
+	// リダクトリストのドメインはまとめて数える
+	if got := fmt.Sprint(cp.Domains); got != "[{[redacted] 1 0} {work.example.com 0 1}]" {
+		t.Errorf("Domains = %s", got)
+	}
+
+	var buf bytes.Buffer
+	printChangepoints(&buf, *report)
+	for _, want := range []string{"2025-01-01〜2025-03-01、60日間、前後14日間で比較", "2025-01-31 [ドメイン構成] 1日 5.0件 → 5.0件（+0%）、ドメイン構成の変化 100%", "work.example.com"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("出力に %q がない:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "os"
+
+// subcommand はサブコマンドの実行関数（引数はサブコマンド名より後ろ）
+type subcommand func(args []string) error
+
+// subcommands はサブコマンド名と実行関数の対応
+// 例: hist sql "SELECT ..."
+var subcommands = map[string]subcommand{
+	"sql":          runSQLCommand,
+	"query":        runQueryCommand,
+	"info":         runInfoCommand,
+	"show":         runShowCommand,
+	"open":         runOpenCommand,
+	"goals":        runGoalsCommand,
+	"focus":        runFocusCommand,
+	"schema":       runSchemaCommand,
+	"ignore":       runIgnoreCommand,
+	"index":        runIndexCommand,
+	"bench":        runBenchCommand,
+	"gen":          runGenCommand,
+	"check":        runCheckCommand,
+	"wayback":      runWaybackCommand,
+	"later":        runLaterCommand,
+	"tag":          runTagCommand,
+	"note":         runNoteCommand,
+	"star":         runStarCommand,
+	"starred":      runStarredCommand,
+	"at":           runAtCommand,
+	"backup":       runBackupCommand,
+	"fsck":         runFsckCommand,
+	"watch":        runWatchCommand,
+	"shortcut":     runShortcutCommand,
+	"integrations": runIntegrationsCommand,
+	"timesheet":    runTimesheetCommand,
+	"archive":      runArchiveCommand,
+	"gc":           runGcCommand,
+	"decrypt":      runDecryptCommand,
+	"report":       runReportCommand,
+	"batch":        runBatchCommand,
+}
+
+// runSubcommand は os.Args がサブコマンド呼び出しなら実行する
+// サブコマンドでなければ false を返し、通常のフラグ解析に進む
+func runSubcommand() (bool, error) {
+	if len(os.Args) < 2 {
+		return false, nil
+	}
+	cmd, ok := subcommands[os.Args[1]]
+	if !ok {
+		return false, nil
+	}
+	return true, cmd(os.Args[2:])
+}
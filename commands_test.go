@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestUsageErrorExitCode は指定の誤りが ExitCodeNoMatch と区別できる終了コードになるか（hist 自体をサブプロセスで実行する）
+func TestUsageErrorExitCode(t *testing.T) {
+	if args := os.Getenv("HIST_TEST_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"hist"}, strings.Fields(args)...)
+		main()
+		return
+	}
+
+	for _, tt := range []struct {
+		args string
+		want int
+	}{
+		{"-bogus", ExitCodeError},
+		{"-limit abc", ExitCodeError},
+		{"sql -bogus", ExitCodeError},
+		{"info -bogus", ExitCodeError},
+		{"-h", ExitCodeOK},
+		{"sql -h", ExitCodeOK},
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestUsageErrorExitCode$")
+		cmd.Env = append(os.Environ(), "HIST_TEST_MAIN_ARGS="+tt.args, "HOME="+t.TempDir(), HistDBEnv+"="+t.TempDir()+"/missing.db")
+		out, err := cmd.CombinedOutput()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatalf("hist %s: %v", tt.args, err)
+		}
+		if code != tt.want {
+			t.Errorf("hist %s の終了コード = %d, want %d\n%s", tt.args, code, tt.want, out)
+		}
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitCodeOK},
+		{errNoMatches, ExitCodeNoMatch},
+		{errors.New("flag provided but not defined: -bogus"), ExitCodeError},
+	} {
+		if got := exitCodeOf(tt.err); got != tt.want {
+			t.Errorf("exitCodeOf(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 出力ファイルの圧縮形式（-output の拡張子で判定する）
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// compressionExtensions は圧縮形式の拡張子
+var compressionExtensions = map[string]string{
+	".gz":  CompressionGzip,
+	".zst": CompressionZstd,
+}
+
+// splitCompressionExt はパスの最後の拡張子が圧縮形式ならその形式と、圧縮の拡張子を除いたパスを返す
+// visits.ndjson.gz なら "gzip", "visits.ndjson"（暗号化の .enc は先に除く）
+func splitCompressionExt(path string) (compression, base string) {
+	_, path = splitEncryptionExt(path)
+	ext := filepath.Ext(path)
+	if c, ok := compressionExtensions[strings.ToLower(ext)]; ok {
+		return c, strings.TrimSuffix(path, ext)
+	}
+	return "", path
+}
+
+// compressOutput は開いた出力ファイルを、パスの拡張子に応じて圧縮する Writer にする
+// 返り値の関数で圧縮の終端を書いてからファイルを閉じる。追記する場合は新しい gzip メンバー・zstd フレームとして続ける
+// （gzip・zstd とも連結したものを1つのファイルとして展開できる）
+// 拡張子が .enc なら圧縮した後に暗号化する（追記した分は新しいヘッダーから続ける）
+func compressOutput(f io.WriteCloser, path string) (io.Writer, func() error, error) {
+	if encrypted, _ := splitEncryptionExt(path); encrypted {
+		key, err := loadEncryptionKey()
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		ew, err := newEncryptWriter(f, key)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, fmt.Errorf("暗号化の初期化に失敗: %w", err)
+		}
+		f = ew
+	}
+	var w io.WriteCloser
+	switch compression, _ := splitCompressionExt(path); compression {
+	case CompressionGzip:
+		w = gzip.NewWriter(f)
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, fmt.Errorf("zstd の初期化に失敗: %w", err)
+		}
+		w = zw
+	default:
+		return f, f.Close, nil
+	}
+	return w, func() error {
+		err := w.Close()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("ファイル書き込みエラー: %w", err)
+		}
+		return nil
+	}, nil
+}
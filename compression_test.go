@@ -0,0 +1,79 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestSplitCompressionExt(t *testing.T) {
+	tests := []struct {
+		path, compression, base string
+	}{
+		{"visits.ndjson.gz", CompressionGzip, "visits.ndjson"},
+		{"report.CSV.ZST", CompressionZstd, "report.CSV"},
+		{"report.json", "", "report.json"},
+	}
+	for _, tt := range tests {
+		if c, base := splitCompressionExt(tt.path); c != tt.compression || base != tt.base {
+			t.Errorf("splitCompressionExt(%q) = %q, %q, want %q, %q", tt.path, c, base, tt.compression, tt.base)
+		}
+	}
+	if format, ok := outputFormatFromPath("report.csv.gz"); !ok || format != OutputFormatCSV {
+		t.Errorf("outputFormatFromPath(report.csv.gz) = %q, %v", format, ok)
+	}
+	if !isNDJSONPath("visits.ndjson.zst") || isNDJSONPath("visits.json.gz") {
+		t.Error("isNDJSONPath の判定が正しくない")
+	}
+}
+
+func TestCompressedOutput(t *testing.T) {
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		"visits.ndjson.gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"visits.ndjson.zst": func(r io.Reader) (io.Reader, error) {
+			d, err := zstd.NewReader(r)
+			return d, err
+		},
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			write := func(open func(string) (io.Writer, func() error, error), data string) {
+				w, closeOutput, err := open(path)
+				if err != nil {
+					t.Fatalf("出力先を開けない: %v", err)
+				}
+				if _, err := io.WriteString(w, data); err != nil {
+					t.Fatalf("書き込みに失敗: %v", err)
+				}
+				if err := closeOutput(); err != nil {
+					t.Fatalf("閉じるのに失敗: %v", err)
+				}
+			}
+			// 上書きしてから追記すると、続けて展開できる
+			write(openOutput, "{\"id\":1}\n")
+			write(openAppendWriter, "{\"id\":2}\n")
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("ファイルを開けない: %v", err)
+			}
+			defer func() { _ = f.Close() }()
+			r, err := newReader(f)
+			if err != nil {
+				t.Fatalf("展開に失敗: %v", err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("展開に失敗: %v", err)
+			}
+			if want := "{\"id\":1}\n{\"id\":2}\n"; string(data) != want {
+				t.Errorf("展開した内容 = %q, want %q", data, want)
+			}
+		})
+	}
+}
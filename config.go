@@ -11,6 +11,7 @@ import (
 const (
 	configDirName   = "hist"
 	ignoreFileName  = "ignore.txt"
+	redactFileName  = "redact.txt"
 	configDirPerms  = 0755
 	configFilePerms = 0644
 )
@@ -30,11 +31,7 @@ func getConfigDir() (string, error) {
 
 // getIgnoreListPath はイグノアリストファイルのパスを返す
 func getIgnoreListPath() (string, error) {
-	configDir, err := getConfigDir()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Join(configDir, ignoreFileName), nil
+	return getConfigFilePath(ignoreFileName)
 }
 
 // ensureConfigDir は設定ディレクトリが存在することを確認する
@@ -46,9 +43,19 @@ func ensureConfigDir() error {
 	return os.MkdirAll(configDir, configDirPerms)
 }
 
-// LoadIgnoreList はイグノアリストを読み込む
-func LoadIgnoreList() ([]string, error) {
-	path, err := getIgnoreListPath()
+// getConfigFilePath は設定ディレクトリ内のファイルパスを返す
+func getConfigFilePath(fileName string) (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, fileName), nil
+}
+
+// loadDomainListFile は1行1ドメインのリストファイルを読み込む
+// label はエラーメッセージに使うリスト名
+func loadDomainListFile(fileName, label string) ([]string, error) {
+	path, err := getConfigFilePath(fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +65,7 @@ func LoadIgnoreList() ([]string, error) {
 		if os.IsNotExist(err) {
 			return []string{}, nil
 		}
-		return nil, fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+		return nil, fmt.Errorf("%sの読み込みに失敗: %w", label, err)
 	}
 	defer func() { _ = file.Close() }()
 
@@ -74,40 +81,59 @@ func LoadIgnoreList() ([]string, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+		return nil, fmt.Errorf("%sの読み込みに失敗: %w", label, err)
 	}
 
 	return domains, nil
 }
 
-// SaveIgnoreList はイグノアリストを保存する
-func SaveIgnoreList(domains []string) error {
+// saveDomainListFile は1行1ドメインのリストファイルを保存する
+func saveDomainListFile(fileName, label string, domains []string) error {
 	if err := ensureConfigDir(); err != nil {
 		return err
 	}
 
-	path, err := getIgnoreListPath()
+	path, err := getConfigFilePath(fileName)
 	if err != nil {
 		return err
 	}
 
 	file, err := os.Create(path)
 	if err != nil {
-		return fmt.Errorf("イグノアリストの保存に失敗: %w", err)
+		return fmt.Errorf("%sの保存に失敗: %w", label, err)
 	}
 	defer func() { _ = file.Close() }()
 
 	for _, domain := range domains {
 		if _, err := fmt.Fprintln(file, domain); err != nil {
-			return fmt.Errorf("イグノアリストの書き込みに失敗: %w", err)
+			return fmt.Errorf("%sの書き込みに失敗: %w", label, err)
 		}
 	}
 
 	return nil
 }
 
+// LoadIgnoreList はイグノアリストを読み込む
+func LoadIgnoreList() ([]string, error) {
+	return loadDomainListFile(ignoreFileName, "イグノアリスト")
+}
+
+// SaveIgnoreList はイグノアリストを保存する
+func SaveIgnoreList(domains []string) error {
+	return saveDomainListFile(ignoreFileName, "イグノアリスト", domains)
+}
+
 // AddToIgnoreList はドメインをイグノアリストに追加する
+// 「ドメイン @HH:MM-HH:MM [曜日]」形式の時間帯付きルールも受け付ける
 func AddToIgnoreList(domain string) error {
+	if strings.Contains(domain, "@") {
+		rule, err := parseIgnoreRule(domain)
+		if err != nil {
+			return err
+		}
+		domain = rule.String()
+	}
+
 	domains, err := LoadIgnoreList()
 	if err != nil {
 		return err
@@ -159,3 +185,68 @@ func PrintIgnoreList() error {
 	}
 	return nil
 }
+
+// LoadRedactList はリダクトリストを読み込む
+// リダクト対象は集計には含めるが、一覧表示では [redacted] に置き換える
+func LoadRedactList() ([]string, error) {
+	return loadDomainListFile(redactFileName, "リダクトリスト")
+}
+
+// SaveRedactList はリダクトリストを保存する
+func SaveRedactList(domains []string) error {
+	return saveDomainListFile(redactFileName, "リダクトリスト", domains)
+}
+
+// AddToRedactList はドメインをリダクトリストに追加する
+func AddToRedactList(domain string) error {
+	domains, err := LoadRedactList()
+	if err != nil {
+		return err
+	}
+
+	// 重複チェック
+	for _, d := range domains {
+		if d == domain {
+			return nil // 既に存在する
+		}
+	}
+
+	domains = append(domains, domain)
+	return SaveRedactList(domains)
+}
+
+// RemoveFromRedactList はドメインをリダクトリストから削除する
+func RemoveFromRedactList(domain string) error {
+	domains, err := LoadRedactList()
+	if err != nil {
+		return err
+	}
+
+	var newDomains []string
+	for _, d := range domains {
+		if d != domain {
+			newDomains = append(newDomains, d)
+		}
+	}
+
+	return SaveRedactList(newDomains)
+}
+
+// PrintRedactList はリダクトリストを表示する
+func PrintRedactList() error {
+	domains, err := LoadRedactList()
+	if err != nil {
+		return err
+	}
+
+	if len(domains) == 0 {
+		fmt.Println("リダクトリストは空です")
+		return nil
+	}
+
+	fmt.Println("リダクトリスト:")
+	for _, d := range domains {
+		fmt.Printf("  - %s\n", d)
+	}
+	return nil
+}
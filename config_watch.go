@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay は設定ファイルの変更を検知してから再読み込みするまでの待ち時間
+// エディタの保存は作成・書き込み・リネームなど複数のイベントになるため、落ち着いてからまとめて反映する
+const configReloadDelay = 200 * time.Millisecond
+
+// watchConfigDir は設定ディレクトリを監視し、fileNames のいずれかが変更されたら onChange を呼ぶ
+// エディタによるファイルの置き換えも拾えるよう、ファイルではなくディレクトリを監視する
+// 返り値の関数で監視を止める
+func watchConfigDir(fileNames []string, onChange func()) (func() error, error) {
+	if err := ensureConfigDir(); err != nil {
+		return nil, fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+	}
+	dir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("設定ディレクトリの監視を開始できませんでした: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("設定ディレクトリの監視を開始できませんでした: %w", err)
+	}
+
+	watched := make(map[string]bool, len(fileNames))
+	for _, name := range fileNames {
+		watched[name] = true
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// 属性の変更だけでは内容は変わらない
+				if !watched[filepath.Base(event.Name)] || event.Op == fsnotify.Chmod {
+					continue
+				}
+				if timer == nil {
+					timer = time.AfterFunc(configReloadDelay, onChange)
+				} else {
+					timer.Reset(configReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("設定ディレクトリの監視エラー: %v", err)
+			}
+		}
+	}()
+
+	return watcher.Close, nil
+}
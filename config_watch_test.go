@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor は cond が true になるまで待つ（設定の再読み込みは非同期に行われる）
+func waitFor(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestWebServerWatchConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	s := &WebServer{}
+	stop, err := s.WatchConfig(true)
+	if err != nil {
+		t.Fatalf("WatchConfig失敗: %v", err)
+	}
+	defer func() { _ = stop() }()
+
+	if err := AddToIgnoreList("github"); err != nil {
+		t.Fatalf("AddToIgnoreList失敗: %v", err)
+	}
+	if !waitFor(t, func() bool { return len(s.baseFilter().IgnoreDomains) == 1 }) {
+		t.Fatalf("イグノアリストの追加が反映されない: %+v", s.baseFilter())
+	}
+
+	if err := AddToRedactList("bank.example.com"); err != nil {
+		t.Fatalf("AddToRedactList失敗: %v", err)
+	}
+	if !waitFor(t, func() bool { return len(s.redactList()) == 1 }) {
+		t.Fatalf("リダクトリストの追加が反映されない: %v", s.redactList())
+	}
+
+	// エディタのように別ファイルに書いてから置き換えても反映される
+	path, err := getIgnoreListPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := filepath.Join(filepath.Dir(path), "ignore.txt.tmp")
+	if err := os.WriteFile(tmp, []byte("github\nyoutube @09:00-18:00\n"), configFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(t, func() bool { return len(s.baseFilter().IgnoreRules) == 1 }) {
+		t.Fatalf("置き換えたイグノアリストが反映されない: %+v", s.baseFilter())
+	}
+}
+
+func TestWebServerReloadConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	s := &WebServer{ignoreDomains: []string{"github"}, redactDomains: []string{"bank.example.com"}}
+
+	// 不正なルールがある場合は以前の設定のまま
+	if err := SaveIgnoreList([]string{"youtube", "google @25:00-26:00"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reloadConfig(true); err == nil {
+		t.Error("不正なイグノアルールでエラーにならない")
+	}
+	if got := s.baseFilter().IgnoreDomains; len(got) != 1 || got[0] != "github" {
+		t.Errorf("読み込み失敗時に設定が変わった: %v", got)
+	}
+
+	// -no-redact で起動した場合はリダクトリストを読み込み直さない
+	if err := SaveIgnoreList([]string{"youtube"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reloadConfig(false); err != nil {
+		t.Fatalf("reloadConfig失敗: %v", err)
+	}
+	if got := s.baseFilter().IgnoreDomains; len(got) != 1 || got[0] != "youtube" {
+		t.Errorf("IgnoreDomains = %v, want [youtube]", got)
+	}
+	if got := s.redactList(); len(got) != 1 {
+		t.Errorf("redactDomains = %v, want 変更なし", got)
+	}
+}
+
+func TestWatcherWatchConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	var out bytes.Buffer
+	watcher, err := newWatcher(db, SearchFilter{}, nil, nil, &out, false)
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+	stop, err := watcher.WatchConfig(true, true)
+	if err != nil {
+		t.Fatalf("WatchConfig失敗: %v", err)
+	}
+	defer func() { _ = stop() }()
+
+	if err := AddToIgnoreList("google"); err != nil {
+		t.Fatalf("AddToIgnoreList失敗: %v", err)
+	}
+	if err := AddToRedactList("youtube.com"); err != nil {
+		t.Fatalf("AddToRedactList失敗: %v", err)
+	}
+	loaded := func() bool {
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		return len(watcher.filter.IgnoreDomains) == 1 && len(watcher.redactDomains) == 1
+	}
+	if !waitFor(t, loaded) {
+		t.Fatalf("イグノアリスト・リダクトリストの追加が反映されない: %+v", watcher.filter)
+	}
+
+	// 再起動せずに、追加したイグノアリスト・リダクトリストで新しい訪問を絞り込む
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 1, 757598400, 'GitHub - New'), (7, 3, 757598410, 'Google Search'), (8, 2, 757598420, 'YouTube - New')`); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := watcher.poll(); err != nil || n != 2 {
+		t.Fatalf("poll() = %d, %v, want 除外ドメイン以外の2件", n, err)
+	}
+	if s := out.String(); strings.Contains(s, "Google") || strings.Contains(s, "YouTube - New") {
+		t.Errorf("出力 = %q", s)
+	}
+}
+
+func TestWatcherReloadConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	watcher := &Watcher{filter: SearchFilter{IgnoreDomains: []string{"github"}}, redactDomains: []string{"bank.example.com"}}
+
+	// 不正なルールがある場合は以前の設定のまま
+	if err := SaveIgnoreList([]string{"youtube", "google @25:00-26:00"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.reloadConfig(true, true); err == nil {
+		t.Error("不正なイグノアルールでエラーにならない")
+	}
+	if got := watcher.filter.IgnoreDomains; len(got) != 1 || got[0] != "github" {
+		t.Errorf("読み込み失敗時に設定が変わった: %v", got)
+	}
+
+	// -no-redact で起動した場合はリダクトリストを読み込み直さない
+	if err := SaveIgnoreList([]string{"youtube"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.reloadConfig(true, false); err != nil {
+		t.Fatalf("reloadConfig失敗: %v", err)
+	}
+	if got := watcher.filter.IgnoreDomains; len(got) != 1 || got[0] != "youtube" {
+		t.Errorf("IgnoreDomains = %v, want [youtube]", got)
+	}
+	if got := watcher.redactDomains; len(got) != 1 {
+		t.Errorf("redactDomains = %v, want 変更なし", got)
+	}
+}
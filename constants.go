@@ -1,13 +1,51 @@
 package main
 
+import "time"
+
 // データベース関連の定数
 const (
 	// SafariHistoryPath はSafari履歴DBの相対パス（ホームディレクトリからの）
 	SafariHistoryPath = "Library/Safari/History.db"
-	// SQLiteDriver はSQLiteのドライバ名
-	SQLiteDriver = "sqlite3"
-	// SQLiteReadOnlyMode は読み取り専用モードのクエリパラメータ
-	SQLiteReadOnlyMode = "?mode=ro"
+	// HistDBEnv は Safari 以外の履歴DB（hist gen で作ったデモ用DBなど）を使う場合に指定する環境変数
+	HistDBEnv = "HIST_DB"
+	// HistKeyFileEnv は暗号化・復号に使う鍵ファイル（-key-file と同じ）
+	HistKeyFileEnv = "HIST_KEY_FILE"
+	// HistPassphraseEnv は鍵ファイルの代わりに暗号化・復号に使うパスフレーズ
+	HistPassphraseEnv = "HIST_PASSPHRASE"
+	// HistAnonymizeKeyEnv は -anonymize のハッシュの鍵（指定しなければ実行ごとに乱数を使う）
+	HistAnonymizeKeyEnv = "HIST_ANONYMIZE_KEY"
+	// HistFormatEnv は formatters.txt の外部コマンドに渡す出力形式の名前
+	HistFormatEnv = "HIST_FORMAT"
+	// HistEventEnv は hooks.txt のコマンドに渡すイベントの名前
+	HistEventEnv = "HIST_EVENT"
+	// SQLiteDriver はSQLiteのドライバ名（hist_fold などの関数を登録した go-sqlite3）
+	SQLiteDriver = "sqlite3_hist"
+	// SQLiteReadOnlyDriver は履歴DBを読むドライバ名（SQLiteDriver と同じ関数を登録し、読み取り以外の文を拒否する）
+	SQLiteReadOnlyDriver = "sqlite3_hist_ro"
+)
+
+// 接続プール関連の定数
+const (
+	// DBMaxOpenConns は同時に開く接続の最大数
+	DBMaxOpenConns = 4
+	// DBMaxIdleConns はプールに残すアイドル接続の最大数
+	DBMaxIdleConns = 4
+	// DBConnMaxLifetime は接続を再利用する最大時間
+	DBConnMaxLifetime = 30 * time.Minute
+	// StatsParallelism は独立した統計を同時に集計する数（接続の最大数と同じ）
+	StatsParallelism = DBMaxOpenConns
+	// PreparedStmtCacheSize はキャッシュするプリペアドステートメントの最大数
+	PreparedStmtCacheSize = 64
+)
+
+// 終了コード
+const (
+	// ExitCodeOK は結果が1件以上見つかったときの終了コード
+	ExitCodeOK = 0
+	// ExitCodeError はエラー時の終了コード
+	ExitCodeError = 1
+	// ExitCodeNoMatch は条件に一致する結果がなかったときの終了コード
+	ExitCodeNoMatch = 2
 )
 
 // CLI デフォルト値
@@ -32,6 +70,26 @@ const (
 	WebDashboardRecentVisits = 5
 	// WebDefaultDays は統計ページのデフォルト日数
 	WebDefaultDays = 30
+	// WebDrilldownPathLimit はダッシュボードでドメインを展開したときのパス表示件数
+	WebDrilldownPathLimit = 10
+	// WebProfileLimit は時間帯プロファイルAPIのデフォルトのドメイン数
+	WebProfileLimit = 5
+	// WebPanelTimeout はダッシュボードの各パネルの集計を打ち切るまでの時間
+	WebPanelTimeout = 10 * time.Second
+	// WebMaxLimit は API の ?limit= で指定できる最大件数
+	WebMaxLimit = 1000
+	// WebMaxProfileLimit は時間帯プロファイルAPIの ?limit= で指定できる最大のドメイン数
+	WebMaxProfileLimit = 20
+	// WebMaxDays は ?days= で指定できる最大日数（from から数える場合も含む）
+	WebMaxDays = 3660
+	// WebMaxPageSize は履歴一覧の ?per_page= で指定できる最大件数
+	WebMaxPageSize = 500
+	// WebMaxRequestBody は「あとで読む」・スターの POST で読み込む JSON の本文の最大バイト数
+	WebMaxRequestBody = 64 << 10
+	// DefaultWebRateLimit は API のクライアントIPごとの1秒あたりのリクエスト数の既定の上限
+	DefaultWebRateLimit = 20
+	// WebRateBurst は API のクライアントIPごとに続けて受け付けるリクエスト数（ダッシュボードのパネルとファビコンを一度に読み込める数）
+	WebRateBurst = 60
 )
 
 // インタラクティブモード関連の定数
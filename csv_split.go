@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// csvManifestFileName は -csv-split で統計ごとの CSV と一緒に書き出す説明ファイル
+const csvManifestFileName = "manifest.json"
+
+// CSVManifest は -csv-split で書き出した CSV の一覧と、集計に使った条件
+type CSVManifest struct {
+	Query       QueryInfo         `json:"query"`
+	TotalVisits int               `json:"total_visits"`
+	Files       []CSVManifestFile `json:"files"`
+}
+
+// CSVManifestFile は統計1つ分の CSV
+type CSVManifestFile struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	Columns []string `json:"columns"`
+}
+
+// writeCSVSplit は統計ごとに dir/<名前>.csv を書き出し、列と条件を dir/manifest.json にまとめる
+// 以前の実行で書いた CSV のうち今回出力しなかったものは残るため、manifest.json の files を一覧として使う
+func writeCSVSplit(dir string, result AnalysisResult, config Config) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("出力ディレクトリの作成に失敗: %w", err)
+	}
+
+	query := result.Query
+	if query == nil {
+		query = newQueryInfo(config.Filter, time.Now())
+	}
+	manifest := CSVManifest{
+		Query:       *query,
+		TotalVisits: result.TotalVisits,
+		Files:       []CSVManifestFile{},
+	}
+	var current *os.File
+	var writer *csv.Writer
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		writer.Flush()
+		err := writer.Error()
+		if cerr := current.Close(); err == nil {
+			err = cerr
+		}
+		current = nil
+		return err
+	}
+
+	err := emitCSVSections(result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily, func(name string, columns []string) (*csv.Writer, error) {
+		if err := finish(); err != nil {
+			return nil, err
+		}
+		file := name + ".csv"
+		f, err := os.Create(filepath.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("ファイル作成エラー: %w", err)
+		}
+		current, writer = f, csv.NewWriter(f)
+		manifest.Files = append(manifest.Files, CSVManifestFile{Name: name, Path: file, Columns: columns})
+		return writer, writer.Write(columns)
+	})
+	if ferr := finish(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return fmt.Errorf("CSV出力エラー: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("manifest.json の作成に失敗: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, csvManifestFileName), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("manifest.json の書き込みに失敗: %w", err)
+	}
+	return nil
+}
+
+// outputCSVSplit は伏せ字・匿名化を適用した結果を -csv-split のディレクトリに書き出す
+func outputCSVSplit(result AnalysisResult, config Config) error {
+	result, err := prepareResult(result, config)
+	if err != nil {
+		return err
+	}
+	if err := writeCSVSplit(config.CSVSplitDir, result, config); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "CSV と %s を %s に書き出しました\n", csvManifestFileName, config.CSVSplitDir)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCSVSplit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "export")
+	result := AnalysisResult{
+		TotalVisits: 5,
+		DomainStats: []DomainStats{{Domain: "github.com", VisitCount: 3, Percentage: 60}, {Domain: "youtube.com", VisitCount: 2, Percentage: 40}},
+		HourlyStats: []HourlyStats{{Hour: 10, VisitCount: 5}},
+		DailyStats:  []DailyStats{{Date: "2025-01-01", VisitCount: 5}},
+	}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := Config{
+		ShowDomains: true,
+		ShowHourly:  true,
+		ShowDaily:   true,
+		Filter:      SearchFilter{Keyword: "github", From: from, IgnoreDomains: []string{"ads.example", "cdn.example"}},
+	}
+	if err := writeCSVSplit(dir, result, config); err != nil {
+		t.Fatalf("writeCSVSplit() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, csvManifestFileName))
+	if err != nil {
+		t.Fatalf("manifest.json の読み込みに失敗: %v", err)
+	}
+	var manifest CSVManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest.json の解析に失敗: %v", err)
+	}
+	if manifest.TotalVisits != 5 {
+		t.Errorf("TotalVisits = %d, want 5", manifest.TotalVisits)
+	}
+	// 絞り込み条件はそのまま、除外リストは件数だけ残す
+	f := manifest.Query
+	if f.Keyword != "github" || f.From == nil || !f.From.Equal(from) || f.To != nil || f.IgnoreDomains != 2 {
+		t.Errorf("Query = %+v", f)
+	}
+
+	var names []string
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+		data, err := os.ReadFile(filepath.Join(dir, file.Path))
+		if err != nil {
+			t.Fatalf("%s の読み込みに失敗: %v", file.Path, err)
+		}
+		// 各ファイルの1行目は manifest.json の列と一致する
+		header, _, _ := strings.Cut(string(data), "\n")
+		if header != strings.Join(file.Columns, ",") {
+			t.Errorf("%s の見出し行 = %q, want %q", file.Path, header, file.Columns)
+		}
+	}
+	if want := []string{"domains", "hourly", "daily"}; !slices.Equal(names, want) {
+		t.Errorf("Files = %v, want %v", names, want)
+	}
+	domains, _ := os.ReadFile(filepath.Join(dir, "domains.csv"))
+	if !strings.Contains(string(domains), "github.com,3") || strings.Contains(string(domains), "2025-01-01") {
+		t.Errorf("domains.csv が期待値と異なる:\n%s", domains)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// dashboardFileName はダッシュボードに表示するパネルと件数の設定ファイル
+// 1行に「キー = 値」を書く:
+//
+//	panels = summary, domains, recent, heatmap   表示するパネル（既定は summary, domains, recent）
+//	recent_visits = 10                            最近の訪問履歴の件数（既定は 5）
+//	domain_limit = 20                             ドメイン別訪問数のドメイン数（既定は 10）
+//	path_limit = 3                                ドメインごとのパス数（既定は 5）
+const dashboardFileName = "dashboard.txt"
+
+// ダッシュボードのパネル
+const (
+	// DashboardPanelSummary は総訪問数・ドメイン数・トップドメインのカード
+	DashboardPanelSummary = "summary"
+	// DashboardPanelDomains はドメイン別訪問数（パス階層表示）
+	DashboardPanelDomains = "domains"
+	// DashboardPanelRecent は最近の訪問履歴
+	DashboardPanelRecent = "recent"
+	// DashboardPanelHeatmap は曜日×時間帯の訪問数
+	DashboardPanelHeatmap = "heatmap"
+)
+
+// dashboardPanelNames は dashboard.txt の panels に書けるパネル
+var dashboardPanelNames = []string{DashboardPanelSummary, DashboardPanelDomains, DashboardPanelRecent, DashboardPanelHeatmap}
+
+// DashboardConfig はダッシュボードに表示するパネルと件数
+type DashboardConfig struct {
+	Panels       []string
+	RecentVisits int
+	DomainLimit  int
+	PathLimit    int
+}
+
+// defaultDashboardConfig は dashboard.txt がない場合の設定（ヒートマップは表示しない）
+var defaultDashboardConfig = DashboardConfig{
+	Panels:       []string{DashboardPanelSummary, DashboardPanelDomains, DashboardPanelRecent},
+	RecentVisits: WebDashboardRecentVisits,
+	DomainLimit:  DefaultDomainLimit,
+	PathLimit:    DefaultPathLimit,
+}
+
+// parseDashboardConfig は dashboard.txt の行を解析する（書かれていない項目は既定値）
+func parseDashboardConfig(lines []string) (DashboardConfig, error) {
+	cfg := defaultDashboardConfig
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return DashboardConfig{}, fmt.Errorf("ダッシュボードの設定は「キー = 値」形式で指定してください: %s", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "panels":
+			cfg.Panels = []string{}
+			for _, name := range strings.Split(value, ",") {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "" {
+					continue
+				}
+				if !slices.Contains(dashboardPanelNames, name) {
+					return DashboardConfig{}, fmt.Errorf("panels には %s を指定してください: %s", strings.Join(dashboardPanelNames, "・"), name)
+				}
+				if !slices.Contains(cfg.Panels, name) {
+					cfg.Panels = append(cfg.Panels, name)
+				}
+			}
+		case "recent_visits", "domain_limit", "path_limit":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return DashboardConfig{}, fmt.Errorf("%s には1以上の数を指定してください: %s", key, value)
+			}
+			switch key {
+			case "recent_visits":
+				cfg.RecentVisits = n
+			case "domain_limit":
+				cfg.DomainLimit = n
+			default:
+				cfg.PathLimit = n
+			}
+		default:
+			return DashboardConfig{}, fmt.Errorf("ダッシュボードの設定に不明なキーがあります: %s", key)
+		}
+	}
+	return cfg, nil
+}
+
+// LoadDashboardConfig はダッシュボードの設定を読み込む
+func LoadDashboardConfig() (DashboardConfig, error) {
+	lines, err := loadDomainListFile(dashboardFileName, "ダッシュボードの設定")
+	if err != nil {
+		return DashboardConfig{}, err
+	}
+	return parseDashboardConfig(lines)
+}
+
+// Shows はパネルを表示するかを返す（テンプレートから呼ぶ）
+func (c DashboardConfig) Shows(panel string) bool {
+	return slices.Contains(c.Panels, panel)
+}
+
+// servesPanel は GET /api/dashboard/{name} のデータを返すかを返す
+// ドメイン数・トップドメインのカードは domains のデータを使うため、summary だけでも domains を返す
+func (c DashboardConfig) servesPanel(name string) bool {
+	switch name {
+	case "total":
+		return c.Shows(DashboardPanelSummary)
+	case DashboardPanelDomains:
+		return c.Shows(DashboardPanelDomains) || c.Shows(DashboardPanelSummary)
+	}
+	return c.Shows(name)
+}
+
+// DashboardHeatmap は GET /api/dashboard/heatmap のレスポンス
+// Counts[曜日][時] は訪問数（曜日は 0=日曜）
+type DashboardHeatmap struct {
+	Counts [7][24]int `json:"counts"`
+	Max    int        `json:"max"`
+}
+
+// getWeekdayHourCounts は曜日×時間帯ごとの訪問数を数える
+func getWeekdayHourCounts(db dbQuerier, filter SearchFilter) (DashboardHeatmap, error) {
+	var heatmap DashboardHeatmap
+	query, args := buildVisitTimeQuery(filter)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return heatmap, fmt.Errorf("曜日・時間帯別統計の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var visitTime float64
+		if err := rows.Scan(&visitTime); err != nil {
+			return heatmap, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		t := convertCoreDataTimestamp(visitTime)
+		heatmap.Counts[t.Weekday()][t.Hour()]++
+		heatmap.Max = max(heatmap.Max, heatmap.Counts[t.Weekday()][t.Hour()])
+	}
+	if err := rows.Err(); err != nil {
+		return heatmap, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	return heatmap, nil
+}
+
+// DashboardTotal は GET /api/dashboard/total のレスポンス
+type DashboardTotal struct {
+	TotalVisits int `json:"total_visits"`
+}
+
+// dashboardPanel はダッシュボードの1つのパネルのデータを集計する
+// db は WebPanelTimeout が過ぎるとクエリを中断する。件数は cfg に従う
+type dashboardPanel func(s *WebServer, db dbQuerier, cfg DashboardConfig, filter SearchFilter) (any, error)
+
+// dashboardPanels は GET /api/dashboard/{panel} で返すパネル
+// ダッシュボードのページは枠だけを先に返し、各パネルは表示されたときにこれらのAPIで埋める
+var dashboardPanels = map[string]dashboardPanel{
+	"total": func(_ *WebServer, db dbQuerier, _ DashboardConfig, _ SearchFilter) (any, error) {
+		total, err := getTotalVisits(db)
+		return DashboardTotal{TotalVisits: total}, err
+	},
+	DashboardPanelDomains: func(s *WebServer, db dbQuerier, cfg DashboardConfig, filter SearchFilter) (any, error) {
+		stats, err := getDomainPathStats(db, cfg.DomainLimit, cfg.PathLimit, filter)
+		if err != nil {
+			return nil, err
+		}
+		stats = redactDomainPathStats(stats, s.redactList())
+		if stats == nil {
+			stats = []DomainPathStats{}
+		}
+		return stats, nil
+	},
+	DashboardPanelRecent: func(s *WebServer, db dbQuerier, cfg DashboardConfig, filter SearchFilter) (any, error) {
+		visits, err := getRecentVisits(db, cfg.RecentVisits, filter)
+		if err != nil {
+			return nil, err
+		}
+		visits = redactVisits(sanitizeVisits(visits), s.redactList())
+		if visits == nil {
+			visits = []HistoryVisit{}
+		}
+		return visits, nil
+	},
+	DashboardPanelHeatmap: func(_ *WebServer, db dbQuerier, _ DashboardConfig, filter SearchFilter) (any, error) {
+		return getWeekdayHourCounts(db, filter)
+	},
+}
+
+// handleAPIDashboardPanel はダッシュボードの1つのパネルのデータをJSONで返す
+// dashboard.txt で表示しないパネルは 404 を返す
+// 集計が panelTimeout 以内に終わらなければクエリを中断して 504 を返す（他のパネルはそのまま表示できる）
+func (s *WebServer) handleAPIDashboardPanel(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("panel")
+	cfg := s.dashboardConfig()
+	panel, ok := dashboardPanels[name]
+	if !ok || !cfg.servesPanel(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeout := s.panelTimeout
+	if timeout <= 0 {
+		timeout = WebPanelTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	data, err := panel(s, s.db.withContext(ctx), cfg, filter)
+	if ctx.Err() != nil {
+		http.Error(w, fmt.Sprintf("集計が%v以内に終わりませんでした", timeout), http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDashboardConfig(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		want    DashboardConfig
+		wantErr bool
+	}{
+		{nil, defaultDashboardConfig, false},
+		{
+			[]string{"panels = recent, Heatmap, recent", "recent_visits = 20", "domain_limit=3", "path_limit = 1"},
+			DashboardConfig{Panels: []string{"recent", "heatmap"}, RecentVisits: 20, DomainLimit: 3, PathLimit: 1},
+			false,
+		},
+		{
+			[]string{"panels ="},
+			DashboardConfig{Panels: []string{}, RecentVisits: WebDashboardRecentVisits, DomainLimit: DefaultDomainLimit, PathLimit: DefaultPathLimit},
+			false,
+		},
+		{[]string{"panels = summary, chart"}, DashboardConfig{}, true},
+		{[]string{"recent_visits = 0"}, DashboardConfig{}, true},
+		{[]string{"domain_limit = many"}, DashboardConfig{}, true},
+		{[]string{"panels summary"}, DashboardConfig{}, true},
+		{[]string{"theme = dark"}, DashboardConfig{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDashboardConfig(tt.lines)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDashboardConfig(%q) error = %v, wantErr %v", tt.lines, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (!slices.Equal(got.Panels, tt.want.Panels) || got.RecentVisits != tt.want.RecentVisits ||
+			got.DomainLimit != tt.want.DomainLimit || got.PathLimit != tt.want.PathLimit) {
+			t.Errorf("parseDashboardConfig(%q) = %+v, want %+v", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestGetWeekdayHourCounts(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	heatmap, err := getWeekdayHourCounts(db, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getWeekdayHourCounts() error = %v", err)
+	}
+	total := 0
+	for _, hours := range heatmap.Counts {
+		for _, n := range hours {
+			total += n
+		}
+	}
+	if total != 5 || heatmap.Max != 1 {
+		t.Errorf("合計 = %d, 最大 = %d, want 5, 1", total, heatmap.Max)
+	}
+	first := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	if heatmap.Counts[first.Weekday()][first.Hour()] != 1 {
+		t.Errorf("%v の訪問が数えられていない", first)
+	}
+}
+
+// TestDashboardPanelsConfig は dashboard.txt の設定がページとパネルAPIに反映されることのテスト
+func TestDashboardPanelsConfig(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	cfg, err := parseDashboardConfig([]string{"panels = recent, heatmap", "recent_visits = 2"})
+	if err != nil {
+		t.Fatalf("parseDashboardConfig() error = %v", err)
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl, dashboard: &cfg}
+	defer func() { _ = s.db.Close() }()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleDashboard)
+	mux.HandleFunc("GET /api/dashboard/{panel}", s.handleAPIDashboardPanel)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	page := get("/").Body.String()
+	if !strings.Contains(page, `data-panel="heatmap">`) || !strings.Contains(page, `data-panel="recent">`) {
+		t.Error("設定したパネルが表示されていない")
+	}
+	if strings.Contains(page, `data-panel="domains">`) || strings.Contains(page, `data-field="total">`) {
+		t.Error("設定していないパネルが表示されている")
+	}
+
+	if rec := get("/api/dashboard/recent"); rec.Code != http.StatusOK || strings.Count(rec.Body.String(), `"visit_time"`) != 2 {
+		t.Errorf("recent = %d %s, want 2件", rec.Code, rec.Body.String())
+	}
+	if rec := get("/api/dashboard/heatmap"); rec.Code != http.StatusOK {
+		t.Errorf("heatmap のステータス = %d, want 200", rec.Code)
+	}
+	for _, panel := range []string{"total", "domains"} {
+		if rec := get("/api/dashboard/" + panel); rec.Code != http.StatusNotFound {
+			t.Errorf("表示しない %s のステータス = %d, want 404", panel, rec.Code)
+		}
+	}
+}
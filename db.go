@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// readOnlyDSN は path を読み取り専用で開く SQLite の URI を返す
+// go-sqlite3 は file: で始まらない DSN のクエリパラメータを SQLite に渡さないため、必ず file: の URI にする
+// immutable は他のプロセスが書き換えないファイル（復号した一時ファイルなど）に限って指定する
+// （ロックと WAL を読まなくなるため、Safari が使用中の履歴DBに指定すると最近の訪問を読めない）
+func readOnlyDSN(path string, immutable bool) string {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro"
+	if immutable {
+		dsn += "&immutable=1"
+	}
+	return dsn
+}
+
+// readOnlyPragmas は読み取り専用の接続で実行できる PRAGMA（引数は表名などで、設定を変えない）
+var readOnlyPragmas = map[string]bool{
+	"integrity_check": true, "quick_check": true,
+	"table_info": true, "table_xinfo": true, "index_list": true, "index_info": true, "foreign_key_list": true,
+}
+
+// readOnlyQueryPragmas は引数なしで値を読むときだけ実行できる PRAGMA（引数を付けると設定の変更になる）
+var readOnlyQueryPragmas = map[string]bool{
+	"user_version": true, "schema_version": true, "application_id": true,
+	"page_count": true, "page_size": true, "freelist_count": true, "journal_mode": true,
+}
+
+// dbQuerier は読み取りクエリを実行できるもの（*sql.DB や preparedDB）
+// 集計関数はこのインターフェースを受け取り、呼び出し側で接続方式を選べるようにする
+type dbQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// configureDB は接続プールを読み取り用途に合わせて設定する
+func configureDB(db *sql.DB) {
+	db.SetMaxOpenConns(DBMaxOpenConns)
+	db.SetMaxIdleConns(DBMaxIdleConns)
+	db.SetConnMaxLifetime(DBConnMaxLifetime)
+}
+
+// useSharedConnection は全てのクエリで1本の読み取り接続を共有するよう設定する
+// 接続のオープンやスキーマ解析のコストを1回にまとめたい場合に使う
+func useSharedConnection(db *sql.DB) {
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+}
+
+// preparedDB はクエリ文字列ごとにプリペアドステートメントを再利用するラッパー
+// Webサーバーのように同じ形のクエリを繰り返し実行する場合に、SQLの解析を1回で済ませる
+type preparedDB struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// newPreparedDB は新しいpreparedDBを作成
+func newPreparedDB(db *sql.DB) *preparedDB {
+	return &preparedDB{
+		db:    db,
+		stmts: make(map[string]*sql.Stmt),
+	}
+}
+
+// stmt はクエリに対応するステートメントを返す（未作成なら準備してキャッシュする）
+// キャッシュが上限に達している場合は nil を返し、呼び出し側は直接実行する
+func (p *preparedDB) stmt(query string) (*sql.Stmt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if st, ok := p.stmts[query]; ok {
+		return st, nil
+	}
+	if len(p.stmts) >= PreparedStmtCacheSize {
+		return nil, nil
+	}
+	st, err := p.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("クエリの準備に失敗: %w", err)
+	}
+	p.stmts[query] = st
+	return st, nil
+}
+
+// Query はdbQuerierインターフェースの実装
+func (p *preparedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return p.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow はdbQuerierインターフェースの実装
+// 準備に失敗した場合は直接実行し、エラーはScan時に返す
+func (p *preparedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return p.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryContext は ctx が終わると SQLite の実行を中断する Query
+func (p *preparedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	st, err := p.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return p.db.QueryContext(ctx, query, args...)
+	}
+	return st.QueryContext(ctx, args...)
+}
+
+// QueryRowContext は ctx が終わると SQLite の実行を中断する QueryRow
+func (p *preparedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	st, err := p.stmt(query)
+	if err != nil || st == nil {
+		return p.db.QueryRowContext(ctx, query, args...)
+	}
+	return st.QueryRowContext(ctx, args...)
+}
+
+// withContext は ctx が終わると実行中のクエリを中断する dbQuerier を返す
+// 集計関数に context を渡さずに、Webサーバーのパネルごとに時間の上限を設けるために使う
+func (p *preparedDB) withContext(ctx context.Context) dbQuerier {
+	return contextQuerier{db: p, ctx: ctx}
+}
+
+// contextQuerier は全てのクエリを ctx 付きで実行する dbQuerier
+type contextQuerier struct {
+	db  *preparedDB
+	ctx context.Context
+}
+
+// Query はdbQuerierインターフェースの実装
+func (c contextQuerier) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+// QueryRow はdbQuerierインターフェースの実装
+func (c contextQuerier) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+// Close はキャッシュしたステートメントを全て閉じる
+func (p *preparedDB) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for query, st := range p.stmts {
+		if err := st.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(p.stmts, query)
+	}
+	return firstErr
+}
@@ -0,0 +1,72 @@
+//go:build cgo && !purego
+
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverPackage はこのビルドで使う SQLite のドライバ（hist info に表示する）
+// cgo を使わずにビルドする（CGO_ENABLED=0 か -tags purego）と db_purego.go の modernc.org/sqlite になる
+const sqliteDriverPackage = "github.com/mattn/go-sqlite3"
+
+func init() {
+	sql.Register(SQLiteDriver, &sqlite3.SQLiteDriver{ConnectHook: registerSQLFunctions})
+	sql.Register(SQLiteReadOnlyDriver, newReadOnlyDriver())
+}
+
+// newReadOnlyDriver は SQLiteReadOnlyDriver と同じく読み取り以外の文を拒否するドライバを作る（hist bench はこれをラップする）
+func newReadOnlyDriver() driver.Driver {
+	return &sqlite3.SQLiteDriver{ConnectHook: registerReadOnlyConn}
+}
+
+// sqliteRecursive は SQLITE_RECURSIVE（WITH RECURSIVE の認可。go-sqlite3 は定数を定義していない）
+const sqliteRecursive = 33
+
+// registerReadOnlyConn は registerSQLFunctions に加えて、読み取り以外の文を拒否する authorizer を登録する
+// mode=ro はファイルへの書き込みを防ぐが、一時テーブルの作成や ATTACH・PRAGMA による設定の変更は防げないため、
+// 文の準備の段階で SELECT・読み取り・関数の呼び出し・トランザクションと readOnlyPragmas 以外を拒否する
+func registerReadOnlyConn(conn *sqlite3.SQLiteConn) error {
+	if err := registerSQLFunctions(conn); err != nil {
+		return err
+	}
+	conn.RegisterAuthorizer(readOnlyAuthorizer)
+	return nil
+}
+
+// readOnlyAuthorizer は SQLite の authorizer で、読み取り以外の操作を SQLITE_DENY にする
+// 拒否された文は準備の段階で「not authorized」のエラーになり、実行されない
+func readOnlyAuthorizer(op int, arg1, arg2, _ string) int {
+	switch op {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_READ, sqlite3.SQLITE_FUNCTION, sqliteRecursive, sqlite3.SQLITE_TRANSACTION:
+		return sqlite3.SQLITE_OK
+	case sqlite3.SQLITE_PRAGMA:
+		name := strings.ToLower(arg1)
+		if readOnlyPragmas[name] || (readOnlyQueryPragmas[name] && arg2 == "") {
+			return sqlite3.SQLITE_OK
+		}
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+// registerSQLFunctions は接続ごとに hist 独自のSQL関数を登録する
+//
+//	hist_fold(text, fold_kana)          検索用に正規化した文字列（normalizeSearchText）
+//	hist_local_clock(visit_time)        ローカル時刻の "HH:MM"（localClock）
+//	hist_local_weekday(visit_time)      ローカルの曜日（0=日曜、localWeekday）
+func registerSQLFunctions(conn *sqlite3.SQLiteConn) error {
+	if err := conn.RegisterFunc("hist_fold", func(s string, foldKana bool) string {
+		return normalizeSearchText(s, foldKana)
+	}, true); err != nil {
+		return err
+	}
+	// time.Local に従うため、同じ引数でも結果が変わりうる（pure = false）
+	if err := conn.RegisterFunc("hist_local_clock", localClock, false); err != nil {
+		return err
+	}
+	return conn.RegisterFunc("hist_local_weekday", localWeekday, false)
+}
@@ -0,0 +1,329 @@
+//go:build !cgo || purego
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+// sqliteDriverPackage はこのビルドで使う SQLite のドライバ（hist info に表示する）
+// cgo なしでクロスコンパイルできるよう、CGO_ENABLED=0 か -tags purego では go-sqlite3 の代わりに使う
+const sqliteDriverPackage = "modernc.org/sqlite"
+
+// errReadOnlyStatement は読み取り専用の接続で読み取り以外の文を実行しようとした場合のエラー
+// （go-sqlite3 の authorizer が拒否したときの「not authorized」に合わせる）
+var errReadOnlyStatement = errors.New("not authorized: 読み取り専用の接続では実行できない文です")
+
+func init() {
+	// modernc.org/sqlite の関数はパッケージが "sqlite" の名前で登録したドライバの全ての接続に登録される
+	if err := sqlite.RegisterDeterministicScalarFunction("hist_fold", 2, histFold); err != nil {
+		panic(err)
+	}
+	// time.Local に従うため、同じ引数でも結果が変わりうる
+	if err := sqlite.RegisterScalarFunction("hist_local_clock", 1, histLocalClock); err != nil {
+		panic(err)
+	}
+	if err := sqlite.RegisterScalarFunction("hist_local_weekday", 1, histLocalWeekday); err != nil {
+		panic(err)
+	}
+	sql.Register(SQLiteDriver, baseSQLiteDriver())
+	sql.Register(SQLiteReadOnlyDriver, newReadOnlyDriver())
+}
+
+// baseSQLiteDriver は modernc.org/sqlite が "sqlite" の名前で登録したドライバ（hist_fold を登録したもの）
+func baseSQLiteDriver() driver.Driver {
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = db.Close() }()
+	return db.Driver()
+}
+
+// newReadOnlyDriver は SQLiteReadOnlyDriver と同じく読み取り以外の文を拒否するドライバを作る（hist bench はこれをラップする）
+func newReadOnlyDriver() driver.Driver {
+	return &readOnlyDriver{Driver: baseSQLiteDriver()}
+}
+
+// histFold は hist_fold(text, fold_kana)（検索用に正規化した文字列、normalizeSearchText）
+func histFold(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var s string
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	foldKana := false
+	switch v := args[1].(type) {
+	case int64:
+		foldKana = v != 0
+	case float64:
+		foldKana = v != 0
+	case bool:
+		foldKana = v
+	}
+	return normalizeSearchText(s, foldKana), nil
+}
+
+// histLocalClock は hist_local_clock(visit_time)（localClock）
+func histLocalClock(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	visitTime, ok := sqlFloat(args[0])
+	if !ok {
+		return nil, nil
+	}
+	return localClock(visitTime), nil
+}
+
+// histLocalWeekday は hist_local_weekday(visit_time)（localWeekday）
+func histLocalWeekday(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	visitTime, ok := sqlFloat(args[0])
+	if !ok {
+		return nil, nil
+	}
+	return int64(localWeekday(visitTime)), nil
+}
+
+// sqlFloat は SQL 関数の数値の引数を float64 にする（NULL などは ok = false）
+func sqlFloat(v driver.Value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// readOnlyDriver は読み取り以外の文を拒否する接続を開くドライバ
+// modernc.org/sqlite には authorizer がないため、接続ごとに query_only を設定し、
+// 文を準備・実行する前に readOnlyStatement で go-sqlite3 の readOnlyAuthorizer と同じ範囲の文だけを通す
+type readOnlyDriver struct {
+	driver.Driver
+}
+
+// Open は読み取り専用の接続を開く
+func (d *readOnlyDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.New("SQLite の接続が ExecerContext を実装していません")
+	}
+	if _, err := execer.ExecContext(context.Background(), "PRAGMA query_only = ON", nil); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &readOnlyConn{Conn: conn}, nil
+}
+
+// readOnlyConn は readOnlyStatement でない文を準備・実行の前に拒否する接続
+type readOnlyConn struct {
+	driver.Conn
+}
+
+// Prepare は driver.Conn の実装
+func (c *readOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext は driver.ConnPrepareContext の実装
+func (c *readOnlyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if !readOnlyStatement(query) {
+		return nil, errReadOnlyStatement
+	}
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// ExecContext は driver.ExecerContext の実装
+func (c *readOnlyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !readOnlyStatement(query) {
+		return nil, errReadOnlyStatement
+	}
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+// QueryContext は driver.QueryerContext の実装
+func (c *readOnlyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !readOnlyStatement(query) {
+		return nil, errReadOnlyStatement
+	}
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+// BeginTx は driver.ConnBeginTx の実装
+func (c *readOnlyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // ConnBeginTx を実装しないドライバ向け
+}
+
+// Ping は driver.Pinger の実装
+func (c *readOnlyConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// sqlToken は readOnlyStatement で読む SQL の字句（キーワードは大文字）と括弧の深さ
+type sqlToken struct {
+	text  string
+	depth int
+}
+
+// readOnlyStatement は query が読み取りだけの1つの文か
+// go-sqlite3 の readOnlyAuthorizer と同じく、SELECT・VALUES・WITH … SELECT・EXPLAIN・トランザクションと
+// readOnlyPragmas・引数なしの readOnlyQueryPragmas だけを通す（セミコロンで続けた2つ目の文は拒否する）
+func readOnlyStatement(query string) bool {
+	tokens, ok := sqlTokens(query)
+	return ok && readOnlyTokens(tokens)
+}
+
+// readOnlyTokens は字句の並びが読み取りだけの文か
+func readOnlyTokens(tokens []sqlToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[0].text {
+	case "SELECT", "VALUES":
+		return true
+	case "WITH":
+		// 共通テーブル式の後の本体（括弧の外）が書き込みの文でないか
+		for _, t := range tokens {
+			if t.depth == 0 && (t.text == "INSERT" || t.text == "UPDATE" || t.text == "DELETE" || t.text == "REPLACE") {
+				return false
+			}
+		}
+		return true
+	case "EXPLAIN":
+		rest := tokens[1:]
+		if len(rest) >= 2 && rest[0].text == "QUERY" && rest[1].text == "PLAN" {
+			rest = rest[2:]
+		}
+		return readOnlyTokens(rest)
+	case "BEGIN", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE":
+		return true
+	case "PRAGMA":
+		rest := tokens[1:]
+		if len(rest) >= 2 && rest[1].text == "." {
+			rest = rest[2:]
+		}
+		if len(rest) == 0 {
+			return false
+		}
+		name := strings.ToLower(strings.Trim(rest[0].text, "\"`[]"))
+		return readOnlyPragmas[name] || (readOnlyQueryPragmas[name] && len(rest) == 1)
+	}
+	return false
+}
+
+// sqlTokens は SQL をコメント・空白を除いた字句に分ける
+// 引用符で囲んだ文字列・識別子は1つの字句にし、最初のセミコロンの後に文が続けば ok = false を返す
+func sqlTokens(query string) ([]sqlToken, bool) {
+	var tokens []sqlToken
+	depth := 0
+	ended := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+			continue
+		case strings.HasPrefix(query[i:], "--"):
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(query)
+			}
+			continue
+		case strings.HasPrefix(query[i:], "/*"):
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(query)
+			}
+			continue
+		}
+		if ended {
+			if c == ';' {
+				i++
+				continue
+			}
+			return nil, false
+		}
+
+		switch {
+		case c == ';':
+			ended = true
+			i++
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] != closer {
+					continue
+				}
+				// '' のように重ねた引用符は文字列の中の引用符
+				if closer != ']' && j+1 < len(query) && query[j+1] == closer {
+					j++
+					continue
+				}
+				break
+			}
+			if j >= len(query) {
+				return nil, false
+			}
+			tokens = append(tokens, sqlToken{text: query[i : j+1], depth: depth})
+			i = j + 1
+		case c == '(':
+			tokens = append(tokens, sqlToken{text: "(", depth: depth})
+			depth++
+			i++
+		case c == ')':
+			depth--
+			tokens = append(tokens, sqlToken{text: ")", depth: depth})
+			i++
+		case isSQLWordByte(c):
+			j := i
+			for j < len(query) && isSQLWordByte(query[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: strings.ToUpper(query[i:j]), depth: depth})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{text: string(c), depth: depth})
+			i++
+		}
+	}
+	return tokens, true
+}
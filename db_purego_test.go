@@ -0,0 +1,48 @@
+//go:build !cgo || purego
+
+package main
+
+import "testing"
+
+func TestReadOnlyStatement(t *testing.T) {
+	allowed := []string{
+		"SELECT COUNT(*) FROM history_visits",
+		"  select 1 -- コメント",
+		"/* 先頭のコメント */ SELECT 1;",
+		"VALUES (1), (2)",
+		"WITH recent AS (SELECT * FROM history_visits) SELECT * FROM recent",
+		"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 3) SELECT x FROM n",
+		"EXPLAIN QUERY PLAN SELECT * FROM history_items",
+		"SELECT 'a; DELETE FROM history_visits'",
+		"BEGIN",
+		"COMMIT",
+		"PRAGMA integrity_check",
+		"PRAGMA table_info(history_items)",
+		"PRAGMA main.user_version",
+	}
+	for _, query := range allowed {
+		if !readOnlyStatement(query) {
+			t.Errorf("readOnlyStatement(%q) = false, want true", query)
+		}
+	}
+
+	denied := []string{
+		"",
+		"DELETE FROM history_visits",
+		"insert into history_items (url) values ('x')",
+		"CREATE TEMP TABLE t (x)",
+		"ATTACH DATABASE 'other.db' AS other",
+		"SELECT 1; DELETE FROM history_visits",
+		"WITH old AS (SELECT id FROM history_visits) DELETE FROM history_visits WHERE id IN old",
+		"EXPLAIN DELETE FROM history_visits",
+		"PRAGMA user_version = 1",
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA writable_schema",
+		"SELECT 'unterminated",
+	}
+	for _, query := range denied {
+		if readOnlyStatement(query) {
+			t.Errorf("readOnlyStatement(%q) = true, want false", query)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreparedDBReusesStatements(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	p := newPreparedDB(db)
+	defer func() { _ = p.Close() }()
+
+	for i := 0; i < 3; i++ {
+		count, err := getTotalVisits(p)
+		if err != nil {
+			t.Fatalf("getTotalVisits失敗: %v", err)
+		}
+		if count != 5 {
+			t.Errorf("getTotalVisits() = %d, want 5", count)
+		}
+		visits, err := getRecentVisits(p, 2, SearchFilter{})
+		if err != nil {
+			t.Fatalf("getRecentVisits失敗: %v", err)
+		}
+		if len(visits) != 2 {
+			t.Errorf("getRecentVisits(2) returned %d items", len(visits))
+		}
+	}
+
+	// 同じクエリは1つのステートメントにまとまる
+	if len(p.stmts) != 2 {
+		t.Errorf("キャッシュされたステートメント数 = %d, want 2", len(p.stmts))
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close失敗: %v", err)
+	}
+	if len(p.stmts) != 0 {
+		t.Errorf("Close後にステートメントが残っている: %d", len(p.stmts))
+	}
+}
+
+func TestPreparedDBInvalidQuery(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	p := newPreparedDB(db)
+	defer func() { _ = p.Close() }()
+
+	if _, err := p.Query("SELECT * FROM missing_table"); err == nil {
+		t.Error("存在しないテーブルでエラーにならない")
+	}
+	var n int
+	if err := p.QueryRow("SELECT * FROM missing_table").Scan(&n); err == nil {
+		t.Error("QueryRowで存在しないテーブルのエラーが返らない")
+	}
+}
+
+func TestOpenDBRejectsWrites(t *testing.T) {
+	// URI で特別な意味を持つ文字を含むパスも開ける
+	path := filepath.Join(t.TempDir(), "Safari #1", "History?.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sql.Open(SQLiteDriver, readWriteTestDSN(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Exec(testSchema); err != nil {
+		t.Fatalf("テーブル作成に失敗: %v", err)
+	}
+	insertTestData(t, w)
+	_ = w.Close()
+
+	db, err := openDB(path)
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	for _, query := range []string{
+		`SELECT COUNT(*) FROM history_visits`,
+		`SELECT hist_fold('ＧｉｔＨｕｂ', 0)`,
+		`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT COUNT(*) FROM n`,
+		`PRAGMA user_version`,
+		`PRAGMA integrity_check`,
+		`PRAGMA table_info(history_items)`,
+	} {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		_ = rows.Close()
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("読み取りトランザクションを開始できない: %v", err)
+	}
+	_ = tx.Rollback()
+
+	for _, query := range []string{
+		`INSERT INTO history_items (id, url) VALUES (99, 'https://example.com/')`,
+		`UPDATE history_visits SET title = ''`,
+		`DELETE FROM history_visits`,
+		`CREATE TEMP TABLE scratch (x)`,
+		`DROP TABLE history_visits`,
+		`PRAGMA user_version = 1`,
+		`PRAGMA journal_mode = DELETE`,
+		`PRAGMA query_only = 0`,
+		`ATTACH DATABASE ':memory:' AS other`,
+		`ANALYZE`,
+		`VACUUM`,
+	} {
+		if _, err := db.Exec(query); err == nil {
+			t.Errorf("%s を実行できた", query)
+		}
+	}
+
+	var visits int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM history_visits`).Scan(&visits); err != nil || visits != 5 {
+		t.Errorf("訪問数 = %d, %v", visits, err)
+	}
+}
+
+// readWriteTestDSN はテストデータを書き込む接続の URI（readOnlyDSN と同じエスケープで開く）
+func readWriteTestDSN(path string) string {
+	return strings.TrimSuffix(readOnlyDSN(path, false), "?mode=ro")
+}
+
+func TestReadOnlyDSN(t *testing.T) {
+	if got := readOnlyDSN("/Users/me/Library/Safari/History.db", false); got != "file:/Users/me/Library/Safari/History.db?mode=ro" {
+		t.Errorf("readOnlyDSN = %s", got)
+	}
+	if got := readOnlyDSN("/tmp/a b#?.db", true); got != "file:/tmp/a%20b%23%3F.db?mode=ro&immutable=1" {
+		t.Errorf("readOnlyDSN（エスケープ・immutable） = %s", got)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// distributionExtremeDays は -distribution で並べる訪問の最も多い日・少ない日の数
+const distributionExtremeDays = 5
+
+// distributionHistogramBins はヒストグラムの区間の数の目安（区間の幅は 1・2・5×10^n に丸める）
+const distributionHistogramBins = 10
+
+// HistogramBin はヒストグラムの1区間（訪問数が Min〜Max の日数）
+type HistogramBin struct {
+	Min  int `json:"min"`
+	Max  int `json:"max"`
+	Days int `json:"days"`
+}
+
+// DailyDistribution は期間内の1日あたりの訪問数の分布（訪問のない日も0件として数える）
+type DailyDistribution struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Days        int     `json:"days"`
+	TotalVisits int     `json:"total_visits"`
+	Mean        float64 `json:"mean"`
+	P50         float64 `json:"p50"`
+	P90         float64 `json:"p90"`
+	Max         int     `json:"max"`
+	Min         int     `json:"min"`
+	// Busiest・Quietest は訪問の最も多い日・少ない日（同数なら新しい日から）
+	Busiest   []DailyStats   `json:"busiest"`
+	Quietest  []DailyStats   `json:"quietest"`
+	Histogram []HistogramBin `json:"histogram"`
+}
+
+// distributionRange は分布を数える日付の範囲（両端を含む）
+// -from・-to があればその範囲、なければ now の日までの過去N日間
+func distributionRange(days int, now time.Time, filter SearchFilter) (time.Time, time.Time) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !filter.To.IsZero() {
+		to = filter.To
+	}
+	from := to.AddDate(0, 0, 1-max(days, 1))
+	if !filter.From.IsZero() {
+		from = filter.From
+	}
+	return from, to
+}
+
+// getDailyDistribution は期間内の日別訪問数の分位数・最も多い日と少ない日・ヒストグラムを求める
+func getDailyDistribution(db dbQuerier, days int, now time.Time, filter SearchFilter) (*DailyDistribution, error) {
+	from, to := distributionRange(days, now, filter)
+	if from.After(to) {
+		return nil, fmt.Errorf("日別訪問数の分布の期間が不正です: %s〜%s", from.Format(TimeFormatDate), to.Format(TimeFormatDate))
+	}
+	query, args := buildVisitTimeQuery(filter)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("日別訪問数の分布の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	first, last := from.Format(TimeFormatDate), to.Format(TimeFormatDate)
+	dateCounts := make(map[string]int)
+	for rows.Next() {
+		var visitTime float64
+		if err := rows.Scan(&visitTime); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		date := convertCoreDataTimestamp(visitTime).Format(TimeFormatDate)
+		if date >= first && date <= last {
+			dateCounts[date]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	var daily []DailyStats
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format(TimeFormatDate)
+		daily = append(daily, DailyStats{Date: date, VisitCount: dateCounts[date]})
+	}
+	return summarizeDailyDistribution(first, last, daily), nil
+}
+
+// summarizeDailyDistribution は日付順の日別訪問数から分布をまとめる
+func summarizeDailyDistribution(from, to string, daily []DailyStats) *DailyDistribution {
+	dist := &DailyDistribution{From: from, To: to, Days: len(daily), Busiest: []DailyStats{}, Quietest: []DailyStats{}, Histogram: []HistogramBin{}}
+	if len(daily) == 0 {
+		return dist
+	}
+	counts := make([]int, len(daily))
+	for i, d := range daily {
+		counts[i] = d.VisitCount
+		dist.TotalVisits += d.VisitCount
+	}
+	sort.Ints(counts)
+	dist.Mean = float64(dist.TotalVisits) / float64(len(counts))
+	dist.P50 = percentile(counts, 50)
+	dist.P90 = percentile(counts, 90)
+	dist.Min, dist.Max = counts[0], counts[len(counts)-1]
+
+	byCount := make([]DailyStats, len(daily))
+	copy(byCount, daily)
+	sort.SliceStable(byCount, func(i, j int) bool {
+		if byCount[i].VisitCount != byCount[j].VisitCount {
+			return byCount[i].VisitCount > byCount[j].VisitCount
+		}
+		return byCount[i].Date > byCount[j].Date
+	})
+	n := min(distributionExtremeDays, len(byCount))
+	dist.Busiest = append(dist.Busiest, byCount[:n]...)
+	sort.SliceStable(byCount, func(i, j int) bool {
+		if byCount[i].VisitCount != byCount[j].VisitCount {
+			return byCount[i].VisitCount < byCount[j].VisitCount
+		}
+		return byCount[i].Date > byCount[j].Date
+	})
+	dist.Quietest = append(dist.Quietest, byCount[:n]...)
+
+	// 区間は最小の日を含む区間から始める（訪問の少ない側に空の区間を並べない）
+	width := histogramBinWidth(dist.Max)
+	start := dist.Min / width * width
+	for lower := start; lower <= dist.Max; lower += width {
+		dist.Histogram = append(dist.Histogram, HistogramBin{Min: lower, Max: lower + width - 1})
+	}
+	for _, c := range counts {
+		dist.Histogram[(c-start)/width].Days++
+	}
+	return dist
+}
+
+// percentile は昇順に並んだ値の p パーセンタイルを線形補間で求める
+func percentile(sorted []int, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := min(lower+1, len(sorted)-1)
+	return float64(sorted[lower]) + (rank-float64(lower))*float64(sorted[upper]-sorted[lower])
+}
+
+// histogramBinWidth は 0〜maxCount をおよそ distributionHistogramBins 個に分ける区間の幅（1・2・5×10^n）
+func histogramBinWidth(maxCount int) int {
+	raw := float64(maxCount+1) / distributionHistogramBins
+	for scale := 1; ; scale *= 10 {
+		for _, step := range []int{1, 2, 5} {
+			if float64(step*scale) >= raw {
+				return step * scale
+			}
+		}
+	}
+}
+
+// label はヒストグラムの区間の表示名
+func (b HistogramBin) label() string {
+	if b.Min == b.Max {
+		return fmt.Sprintf("%d", b.Min)
+	}
+	return fmt.Sprintf("%d-%d", b.Min, b.Max)
+}
+
+// formatDistributionDays は最も多い日・少ない日を「日付 (訪問数)」で並べる
+func formatDistributionDays(days []DailyStats) string {
+	cells := make([]string, len(days))
+	for i, d := range days {
+		cells[i] = fmt.Sprintf("%s (%d)", d.Date, d.VisitCount)
+	}
+	return strings.Join(cells, ", ")
+}
+
+// distributionTitle は -distribution の見出し
+func distributionTitle(dist DailyDistribution) string {
+	return fmt.Sprintf("📈 日別訪問数の分布 (%s〜%s、%d日間)", dist.From, dist.To, dist.Days)
+}
+
+// printDailyDistribution は日別訪問数の分布をテキストで表示する
+func printDailyDistribution(w io.Writer, dist DailyDistribution) {
+	_, _ = fmt.Fprintln(w, distributionTitle(dist))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	_, _ = fmt.Fprintf(w, "  平均 %.1f  中央値(p50) %.1f  p90 %.1f  最大 %d  最小 %d\n", dist.Mean, dist.P50, dist.P90, dist.Max, dist.Min)
+	_, _ = fmt.Fprintf(w, "  最も多い日: %s\n", formatDistributionDays(dist.Busiest))
+	_, _ = fmt.Fprintf(w, "  最も少ない日: %s\n", formatDistributionDays(dist.Quietest))
+	_, _ = fmt.Fprintf(w, "  訪問数ごとの日数:\n")
+	maxDays := 0
+	for _, b := range dist.Histogram {
+		maxDays = max(maxDays, b.Days)
+	}
+	for _, b := range dist.Histogram {
+		barLen := 0
+		if maxDays > 0 {
+			barLen = int(float64(b.Days) / float64(maxDays) * BarChartWidth)
+		}
+		_, _ = fmt.Fprintf(w, "    %s %s %d日\n", padRight(b.label(), 10), strings.Repeat("█", barLen), b.Days)
+	}
+	_, _ = fmt.Fprintln(w)
+}
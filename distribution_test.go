@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentileAndBinWidth(t *testing.T) {
+	for _, tt := range []struct {
+		values []int
+		p      float64
+		want   float64
+	}{
+		{[]int{}, 50, 0},
+		{[]int{7}, 90, 7},
+		{[]int{1, 2, 3, 4}, 50, 2.5},
+		{[]int{0, 0, 0, 2, 3}, 90, 2.6},
+		{[]int{0, 10}, 100, 10},
+	} {
+		if got := percentile(tt.values, tt.p); fmt.Sprintf("%.2f", got) != fmt.Sprintf("%.2f", tt.want) {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tt.values, tt.p, got, tt.want)
+		}
+	}
+	for max, want := range map[int]int{0: 1, 9: 1, 14: 2, 40: 5, 99: 10, 100: 20} {
+		if got := histogramBinWidth(max); got != want {
+			t.Errorf("histogramBinWidth(%d) = %d, want %d", max, got, want)
+		}
+	}
+}
+
+func TestGetDailyDistribution(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	now := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)
+
+	// 訪問のない日も0件として数える（12/30〜1/3 の5日間: 0, 0, 3, 2, 0）
+	dist, err := getDailyDistribution(db, 5, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getDailyDistribution: %v", err)
+	}
+	if dist.From != "2024-12-30" || dist.To != "2025-01-03" || dist.Days != 5 || dist.TotalVisits != 5 {
+		t.Errorf("期間 = %s〜%s (%d日, %d件)", dist.From, dist.To, dist.Days, dist.TotalVisits)
+	}
+	if dist.Mean != 1 || dist.P50 != 0 || fmt.Sprintf("%.1f", dist.P90) != "2.6" || dist.Max != 3 || dist.Min != 0 {
+		t.Errorf("分布 = %+v", dist)
+	}
+	if got := formatDistributionDays(dist.Busiest); got != "2025-01-01 (3), 2025-01-02 (2), 2025-01-03 (0), 2024-12-31 (0), 2024-12-30 (0)" {
+		t.Errorf("最も多い日 = %s", got)
+	}
+	if got := formatDistributionDays(dist.Quietest[:3]); got != "2025-01-03 (0), 2024-12-31 (0), 2024-12-30 (0)" {
+		t.Errorf("最も少ない日 = %s", got)
+	}
+	if got := fmt.Sprint(dist.Histogram); got != "[{0 0 3} {1 1 0} {2 2 1} {3 3 1}]" {
+		t.Errorf("ヒストグラム = %s", got)
+	}
+
+	// -from・-to があればその範囲
+	dist, err = getDailyDistribution(db, 30, now, SearchFilter{From: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("getDailyDistribution: %v", err)
+	}
+	if dist.Days != 2 || dist.P50 != 2.5 || dist.Min != 2 {
+		t.Errorf("-from/-to の分布 = %+v", dist)
+	}
+
+	var text bytes.Buffer
+	printDailyDistribution(&text, *dist)
+	for _, want := range []string{"📈 日別訪問数の分布 (2025-01-01〜2025-01-02、2日間)", "中央値(p50) 2.5", "最も多い日: 2025-01-01 (3), 2025-01-02 (2)", "2          █"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("テキスト出力に %q がない:\n%s", want, text.String())
+		}
+	}
+
+	result := AnalysisResult{Distribution: dist}
+	var csv bytes.Buffer
+	if err := writeCSV(&csv, result, false, false, false, false, false, ','); err != nil {
+		t.Fatalf("writeCSV: %v", err)
+	}
+	for _, want := range []string{"2025-01-01,2025-01-02,2,5,2.5,2.5,2.9,3,2", "busiest,2025-01-01,3", "2,2,1"} {
+		if !strings.Contains(csv.String(), want) {
+			t.Errorf("CSV の出力に %q がない:\n%s", want, csv.String())
+		}
+	}
+	var quiet bytes.Buffer
+	printQuietOutput(&quiet, result, false, false, false, false, false)
+	if !strings.Contains(quiet.String(), "p90\t2.9\n") || !result.hasMatches() {
+		t.Errorf("-quiet の出力:\n%s", quiet.String())
+	}
+}
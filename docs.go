@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// docsFileName はドキュメントサイトのパターンを保存するファイル
+const docsFileName = "docs.txt"
+
+// defaultDocSites は docs.txt が空のときに使うドキュメントサイトのパターン
+var defaultDocSites = []string{
+	"pkg.go.dev",
+	"developer.apple.com/documentation 2",
+	"developer.mozilla.org/*/docs 3",
+}
+
+// DocSitePattern はドキュメントサイトの登録内容
+// 「ホスト[/接頭辞パス] [階層数]」形式で、接頭辞の後ろのパスを階層数まで使ってまとめる
+// 接頭辞の * は任意の1階層（MDN の en-US / ja など）にマッチする
+type DocSitePattern struct {
+	Host   string
+	Prefix []string
+	// Depth はまとめる階層数（0なら接頭辞以降のパス全体）
+	Depth int
+}
+
+// DocLookup はドキュメントのパッケージ・APIごとの訪問数
+type DocLookup struct {
+	Site       string    `json:"site"`
+	Path       string    `json:"path"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	VisitCount int       `json:"visit_count"`
+	LastVisit  time.Time `json:"last_visit"`
+}
+
+// DocSiteStats はドキュメントサイトごとの合計
+type DocSiteStats struct {
+	Site       string `json:"site"`
+	PageCount  int    `json:"page_count"`
+	VisitCount int    `json:"visit_count"`
+}
+
+// DocsReport は -docs の結果
+type DocsReport struct {
+	Sites   []DocSiteStats `json:"sites"`
+	Lookups []DocLookup    `json:"lookups"`
+}
+
+// parseDocSitePattern は「ホスト[/接頭辞パス] [階層数]」形式のパターンを解析する
+func parseDocSitePattern(s string) (DocSitePattern, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return DocSitePattern{}, fmt.Errorf("ドキュメントサイトは「ホスト[/パス] [階層数]」形式で指定してください: %s", s)
+	}
+
+	host, prefix, _ := strings.Cut(strings.Trim(fields[0], "/"), "/")
+	if host == "" || strings.Contains(fields[0], "://") {
+		return DocSitePattern{}, fmt.Errorf("ドキュメントサイトのホストが不正です: %s", s)
+	}
+	pattern := DocSitePattern{Host: strings.ToLower(strings.TrimPrefix(host, "www."))}
+	if prefix != "" {
+		pattern.Prefix = strings.Split(prefix, "/")
+	}
+
+	if len(fields) == 2 {
+		depth, err := strconv.Atoi(fields[1])
+		if err != nil || depth <= 0 {
+			return DocSitePattern{}, fmt.Errorf("階層数は1以上の整数で指定してください: %s", s)
+		}
+		pattern.Depth = depth
+	}
+	return pattern, nil
+}
+
+// String はパターンを docs.txt の1行の形式で返す
+func (p DocSitePattern) String() string {
+	s := p.Site()
+	if p.Depth > 0 {
+		s += " " + strconv.Itoa(p.Depth)
+	}
+	return s
+}
+
+// Site はレポートに表示するサイト名（ホストと接頭辞）を返す
+func (p DocSitePattern) Site() string {
+	return strings.Join(append([]string{p.Host}, p.Prefix...), "/")
+}
+
+// match はURLがパターンのサイトに含まれていれば、まとめる単位のパスを返す
+// 接頭辞そのもの（トップページ）は ok=false
+func (p DocSitePattern) match(u *url.URL) (string, bool) {
+	if strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") != p.Host {
+		return "", false
+	}
+
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	if len(segments) <= len(p.Prefix) {
+		return "", false
+	}
+	for i, prefix := range p.Prefix {
+		if prefix != "*" && !strings.EqualFold(prefix, segments[i]) {
+			return "", false
+		}
+	}
+
+	segments = segments[len(p.Prefix):]
+	if p.Depth > 0 && len(segments) > p.Depth {
+		segments = segments[:p.Depth]
+	}
+	// pkg.go.dev の @v1.2.3 のようなバージョン指定は同じパッケージとして数える
+	for i, s := range segments {
+		segments[i], _, _ = strings.Cut(s, "@")
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// matchDocSite はURLに最初にマッチしたパターンとまとめる単位のパスを返す
+func matchDocSite(rawURL string, patterns []DocSitePattern) (DocSitePattern, string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return DocSitePattern{}, "", false
+	}
+	for _, p := range patterns {
+		if path, ok := p.match(u); ok {
+			return p, path, true
+		}
+	}
+	return DocSitePattern{}, "", false
+}
+
+// LoadDocSites はドキュメントサイトのパターンを読み込む
+// 登録がなければ defaultDocSites を使う
+func LoadDocSites() ([]DocSitePattern, error) {
+	lines, err := loadDocSiteLines()
+	if err != nil {
+		return nil, err
+	}
+	patterns := make([]DocSitePattern, 0, len(lines))
+	for _, line := range lines {
+		p, err := parseDocSitePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// loadDocSiteLines は docs.txt の行を読み込む（空なら既定のパターン）
+func loadDocSiteLines() ([]string, error) {
+	lines, err := loadDomainListFile(docsFileName, "ドキュメントサイト一覧")
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return append([]string(nil), defaultDocSites...), nil
+	}
+	return lines, nil
+}
+
+// AddToDocSites はドキュメントサイトのパターンを登録する
+func AddToDocSites(s string) error {
+	pattern, err := parseDocSitePattern(s)
+	if err != nil {
+		return err
+	}
+
+	lines, err := loadDocSiteLines()
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if p, err := parseDocSitePattern(line); err == nil && p.Site() == pattern.Site() {
+			if p.Depth == pattern.Depth {
+				return nil // 既に存在する
+			}
+			return fmt.Errorf("%s は既に登録されています（階層数を変えるには一度削除してください）", pattern.Site())
+		}
+	}
+
+	lines = append(lines, pattern.String())
+	return saveDomainListFile(docsFileName, "ドキュメントサイト一覧", lines)
+}
+
+// RemoveFromDocSites はドキュメントサイトのパターンを削除する
+// 階層数を省略してホスト[/接頭辞パス]だけでも指定できる
+func RemoveFromDocSites(s string) error {
+	target, err := parseDocSitePattern(s)
+	if err != nil {
+		return err
+	}
+
+	lines, err := loadDocSiteLines()
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, line := range lines {
+		if p, err := parseDocSitePattern(line); err == nil && p.Site() == target.Site() {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return saveDomainListFile(docsFileName, "ドキュメントサイト一覧", kept)
+}
+
+// PrintDocSites はドキュメントサイトのパターンを表示する
+func PrintDocSites() error {
+	patterns, err := LoadDocSites()
+	if err != nil {
+		return err
+	}
+	fmt.Println("ドキュメントサイト:")
+	for _, p := range patterns {
+		fmt.Printf("  - %s\n", p)
+	}
+	return nil
+}
+
+// getDocsReport はドキュメントサイトへの訪問をパッケージ・APIのパスごとに集計する
+// limit はパスの表示件数（0以下なら全件）
+func getDocsReport(db dbQuerier, limit int, filter SearchFilter, patterns []DocSitePattern) (DocsReport, error) {
+	lookups := make(map[string]*DocLookup)
+	sites := make(map[string]*DocSiteStats)
+	var siteOrder []string
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		pattern, path, ok := matchDocSite(v.URL, patterns)
+		if !ok {
+			return nil
+		}
+		site := pattern.Site()
+		key := site + "\x00" + path
+		l := lookups[key]
+		if l == nil {
+			// 新しい順に流れてくるので、最初の訪問が最新
+			title, _ := sanitizeText(v.Title)
+			l = &DocLookup{Site: site, Path: path, Title: title, URL: v.URL, LastVisit: v.VisitTime}
+			lookups[key] = l
+		}
+		l.VisitCount++
+
+		s := sites[site]
+		if s == nil {
+			s = &DocSiteStats{Site: site}
+			sites[site] = s
+			siteOrder = append(siteOrder, site)
+		}
+		if l.VisitCount == 1 {
+			s.PageCount++
+		}
+		s.VisitCount++
+		return nil
+	})
+	if err != nil {
+		return DocsReport{}, fmt.Errorf("ドキュメントの集計に失敗: %w", err)
+	}
+
+	var report DocsReport
+	for _, l := range lookups {
+		report.Lookups = append(report.Lookups, *l)
+	}
+	sort.Slice(report.Lookups, func(i, j int) bool {
+		a, b := report.Lookups[i], report.Lookups[j]
+		if a.VisitCount != b.VisitCount {
+			return a.VisitCount > b.VisitCount
+		}
+		return a.LastVisit.After(b.LastVisit)
+	})
+	if limit > 0 && len(report.Lookups) > limit {
+		report.Lookups = report.Lookups[:limit]
+	}
+
+	for _, site := range siteOrder {
+		report.Sites = append(report.Sites, *sites[site])
+	}
+	sort.SliceStable(report.Sites, func(i, j int) bool {
+		return report.Sites[i].VisitCount > report.Sites[j].VisitCount
+	})
+	return report, nil
+}
+
+// redactDocsReport はリダクト対象のサイトのパス・タイトル・URLを伏せたコピーを返す
+func redactDocsReport(report *DocsReport, redactDomains []string) *DocsReport {
+	if len(redactDomains) == 0 || report == nil {
+		return report
+	}
+	redacted := *report
+	redacted.Lookups = make([]DocLookup, len(report.Lookups))
+	for i, l := range report.Lookups {
+		if domainMatchesList(extractDomain(l.URL), redactDomains) {
+			l.Path = redactedLabel
+			l.Title = redactedLabel
+			l.URL = redactedLabel
+		}
+		redacted.Lookups[i] = l
+	}
+	return &redacted
+}
+
+// printDocsReport はドキュメントの集計をテキスト形式で出力する
+func printDocsReport(w io.Writer, report DocsReport) {
+	_, _ = fmt.Fprintf(w, "📚 調べたドキュメント (Top %d)\n", len(report.Lookups))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, s := range report.Sites {
+		_, _ = fmt.Fprintf(w, "  %s: %dページ / %d訪問\n", s.Site, s.PageCount, s.VisitCount)
+	}
+	if len(report.Sites) > 0 {
+		_, _ = fmt.Fprintln(w)
+	}
+	for _, l := range report.Lookups {
+		_, _ = fmt.Fprintf(w, "  %4d  [%s] %s\n", l.VisitCount, l.Site, l.Path)
+		if l.Title != "" {
+			_, _ = fmt.Fprintf(w, "        %s\n", truncateText(l.Title, TitleTruncateLength))
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,171 @@
+package main
+
+import "testing"
+
+// TestParseDocSitePattern はドキュメントサイトのパターン解析のテスト
+func TestParseDocSitePattern(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"pkg.go.dev", "pkg.go.dev", false},
+		{"www.Developer.Apple.com/documentation/ 2", "developer.apple.com/documentation 2", false},
+		{"developer.mozilla.org/*/docs 3", "developer.mozilla.org/*/docs 3", false},
+		{"", "", true},
+		{"https://pkg.go.dev", "", true},
+		{"pkg.go.dev 0", "", true},
+		{"pkg.go.dev x", "", true},
+		{"pkg.go.dev 1 2", "", true},
+	}
+
+	for _, tt := range tests {
+		p, err := parseDocSitePattern(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDocSitePattern(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p.String() != tt.want {
+			t.Errorf("parseDocSitePattern(%q) = %q, want %q", tt.input, p.String(), tt.want)
+		}
+	}
+}
+
+// TestMatchDocSite は既定のパターンでのURLのまとめ方のテスト
+func TestMatchDocSite(t *testing.T) {
+	var patterns []DocSitePattern
+	for _, s := range defaultDocSites {
+		p, err := parseDocSitePattern(s)
+		if err != nil {
+			t.Fatalf("既定のパターンが不正: %v", err)
+		}
+		patterns = append(patterns, p)
+	}
+
+	tests := []struct {
+		url    string
+		wantOK bool
+		site   string
+		path   string
+	}{
+		{"https://pkg.go.dev/net/http#Client", true, "pkg.go.dev", "net/http"},
+		{"https://pkg.go.dev/github.com/mattn/go-sqlite3@v1.14.22", true, "pkg.go.dev", "github.com/mattn/go-sqlite3"},
+		{"https://pkg.go.dev/", false, "", ""},
+		{"https://developer.apple.com/documentation/swiftui/view/padding(_:_:)", true, "developer.apple.com/documentation", "swiftui/view"},
+		{"https://developer.apple.com/documentation", false, "", ""},
+		{"https://developer.apple.com/videos/", false, "", ""},
+		{"https://developer.mozilla.org/en-US/docs/Web/API/Fetch_API/Using_Fetch", true, "developer.mozilla.org/*/docs", "Web/API/Fetch_API"},
+		{"https://developer.mozilla.org/ja/docs/Web/API/Fetch_API", true, "developer.mozilla.org/*/docs", "Web/API/Fetch_API"},
+		{"https://example.com/net/http", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		p, path, ok := matchDocSite(tt.url, patterns)
+		if ok != tt.wantOK {
+			t.Errorf("matchDocSite(%q) ok = %v, want %v", tt.url, ok, tt.wantOK)
+			continue
+		}
+		if ok && (p.Site() != tt.site || path != tt.path) {
+			t.Errorf("matchDocSite(%q) = %s %s, want %s %s", tt.url, p.Site(), path, tt.site, tt.path)
+		}
+	}
+}
+
+// TestDocSitesConfig はドキュメントサイトの登録・削除のテスト
+func TestDocSitesConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	patterns, err := LoadDocSites()
+	if err != nil {
+		t.Fatalf("LoadDocSites失敗: %v", err)
+	}
+	if len(patterns) != len(defaultDocSites) {
+		t.Fatalf("未登録時は既定のパターンを使うはず: %v", patterns)
+	}
+
+	if err := AddToDocSites("docs.python.org/3 2"); err != nil {
+		t.Fatalf("AddToDocSites失敗: %v", err)
+	}
+	if err := AddToDocSites("docs.python.org/3 2"); err != nil {
+		t.Fatalf("同じパターンの再登録はエラーにしない: %v", err)
+	}
+	if err := AddToDocSites("docs.python.org/3 1"); err == nil {
+		t.Error("階層数だけ異なるパターンの登録はエラーにするはず")
+	}
+	patterns, err = LoadDocSites()
+	if err != nil {
+		t.Fatalf("LoadDocSites失敗: %v", err)
+	}
+	if len(patterns) != len(defaultDocSites)+1 || patterns[len(patterns)-1].String() != "docs.python.org/3 2" {
+		t.Errorf("登録後のパターンが期待値と異なる: %v", patterns)
+	}
+
+	if err := RemoveFromDocSites("pkg.go.dev"); err != nil {
+		t.Fatalf("RemoveFromDocSites失敗: %v", err)
+	}
+	patterns, err = LoadDocSites()
+	if err != nil {
+		t.Fatalf("LoadDocSites失敗: %v", err)
+	}
+	for _, p := range patterns {
+		if p.Host == "pkg.go.dev" {
+			t.Errorf("削除したパターンが残っている: %v", patterns)
+		}
+	}
+}
+
+// TestGetDocsReport はドキュメントの集計のテスト
+func TestGetDocsReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	base := 757418400.0
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://pkg.go.dev/net/http', 'pkg.go', 1),
+		(2, 'https://pkg.go.dev/net/http#Client', 'pkg.go', 1),
+		(3, 'https://pkg.go.dev/strings', 'pkg.go', 1),
+		(4, 'https://developer.mozilla.org/en-US/docs/Web/API/Fetch_API', 'developer.mozilla', 1),
+		(5, 'https://example.com', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, ?, 'http package - net/http - Go Packages'),
+		(2, 2, ?, 'http package - net/http - Go Packages (latest)'),
+		(3, 3, ?, 'strings package'),
+		(4, 4, ?, 'Fetch API - Web APIs | MDN'),
+		(5, 5, ?, 'Example');
+	`, base, base+60, base+120, base+180, base+240)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	var patterns []DocSitePattern
+	for _, s := range defaultDocSites {
+		p, _ := parseDocSitePattern(s)
+		patterns = append(patterns, p)
+	}
+
+	report, err := getDocsReport(db, 0, SearchFilter{}, patterns)
+	if err != nil {
+		t.Fatalf("getDocsReport失敗: %v", err)
+	}
+	if len(report.Lookups) != 3 {
+		t.Fatalf("パス数が期待値と異なる: %+v", report.Lookups)
+	}
+	top := report.Lookups[0]
+	if top.Path != "net/http" || top.VisitCount != 2 || top.Title != "http package - net/http - Go Packages (latest)" {
+		t.Errorf("net/http の集計が期待値と異なる: %+v", top)
+	}
+	if len(report.Sites) != 2 || report.Sites[0].Site != "pkg.go.dev" || report.Sites[0].PageCount != 2 || report.Sites[0].VisitCount != 3 {
+		t.Errorf("サイトごとの集計が期待値と異なる: %+v", report.Sites)
+	}
+
+	redacted := redactDocsReport(&report, []string{"mozilla.org"})
+	for _, l := range redacted.Lookups {
+		if l.Site == "developer.mozilla.org/*/docs" && l.Path != redactedLabel {
+			t.Errorf("リダクト対象のパスが残っている: %+v", l)
+		}
+		if l.Site == "pkg.go.dev" && l.Path == redactedLabel {
+			t.Errorf("リダクト対象外のパスが伏せられた: %+v", l)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 暗号化したファイルの形式（AES-256-GCM）
+// ヘッダー（encryptionMagic・鍵の種類・ソルト・ノンスの接頭辞）の後に、平文 encryptionChunkSize ごとのレコード
+// （最後かどうかのフラグ・暗号文の長さ・暗号文）が続く。ノンスは接頭辞・レコードの番号・フラグから作るため、
+// レコードの入れ替え・削除や途中での切り詰めは復号の失敗になる。-append で追記した場合はヘッダーから繰り返す
+const (
+	// encryptionExt は暗号化したファイルの拡張子
+	encryptionExt = ".enc"
+	// encryptionMagic は暗号化したファイルの先頭
+	encryptionMagic = "HISTENC1"
+	// encryptionChunkSize はレコード1つの平文の最大バイト数
+	encryptionChunkSize = 64 << 10
+	// encryptionSaltSize はパスフレーズから鍵を作るソルトのバイト数
+	encryptionSaltSize = 16
+	// encryptionNoncePrefixSize はノンスのうちファイルごとに乱数で決める部分のバイト数
+	encryptionNoncePrefixSize = 7
+	// encryptionKeySize は AES-256 の鍵のバイト数
+	encryptionKeySize = 32
+	// encryptionPBKDF2Iterations はパスフレーズから鍵を作る PBKDF2-SHA256 の繰り返し回数
+	encryptionPBKDF2Iterations = 600000
+)
+
+// 鍵の種類（ヘッダーに記録し、復号時に同じ種類の鍵を求める）
+const (
+	encryptionModePassphrase byte = 1
+	encryptionModeKeyFile    byte = 2
+)
+
+// errNotEncrypted は暗号化したファイルでない場合のエラー
+var errNotEncrypted = errors.New("hist で暗号化したファイルではありません")
+
+// errDecrypt は鍵が違うか、ファイルが壊れている・改ざんされている場合のエラー
+var errDecrypt = errors.New("復号に失敗しました（鍵が違うか、ファイルが壊れています）")
+
+// EncryptionKey は暗号化・復号に使うパスフレーズまたは鍵ファイルの鍵
+type EncryptionKey struct {
+	passphrase string
+	key        []byte
+}
+
+// loadEncryptionKey は環境変数 HIST_KEY_FILE の鍵ファイル、なければ HIST_PASSPHRASE のパスフレーズを返す
+// 鍵ファイルは32バイトの16進数（hist archive の archive.key もそのまま使える）
+func loadEncryptionKey() (*EncryptionKey, error) {
+	if path := os.Getenv(HistKeyFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("鍵ファイルの読み込みに失敗: %w", err)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("鍵ファイルは32バイトの16進数にしてください（openssl rand -hex 32 などで作成）: %s", path)
+		}
+		return &EncryptionKey{key: key}, nil
+	}
+	if passphrase := os.Getenv(HistPassphraseEnv); passphrase != "" {
+		return &EncryptionKey{passphrase: passphrase}, nil
+	}
+	return nil, fmt.Errorf("暗号化の鍵がありません（-key-file・環境変数 %s で鍵ファイルを、または環境変数 %s でパスフレーズを指定してください）", HistKeyFileEnv, HistPassphraseEnv)
+}
+
+// setKeyFileEnv は -key-file の値を、loadEncryptionKey で参照できるよう環境変数に設定する
+func setKeyFileEnv(path string) error {
+	if path == "" {
+		return nil
+	}
+	return os.Setenv(HistKeyFileEnv, path)
+}
+
+// mode は鍵の種類を返す
+func (k *EncryptionKey) mode() byte {
+	if k.key != nil {
+		return encryptionModeKeyFile
+	}
+	return encryptionModePassphrase
+}
+
+// aead はヘッダーのソルトから AES-256-GCM を作る
+func (k *EncryptionKey) aead(mode byte, salt []byte) (cipher.AEAD, error) {
+	if mode != k.mode() {
+		if mode == encryptionModeKeyFile {
+			return nil, errors.New("鍵ファイルで暗号化したファイルです（-key-file で鍵ファイルを指定してください）")
+		}
+		return nil, errors.New("パスフレーズで暗号化したファイルです（環境変数 " + HistPassphraseEnv + " を指定してください）")
+	}
+	key := k.key
+	if key == nil {
+		var err error
+		if key, err = pbkdf2.Key(sha256.New, k.passphrase, salt, encryptionPBKDF2Iterations, encryptionKeySize); err != nil {
+			return nil, fmt.Errorf("鍵の生成に失敗: %w", err)
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptionNonce はレコードのノンス（接頭辞・レコードの番号・最後のレコードかのフラグ）を作る
+func encryptionNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, encryptionNoncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptWriter は書き込んだ内容を暗号化して w に書き出す
+type encryptWriter struct {
+	w       io.WriteCloser
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	err     error
+}
+
+// newEncryptWriter は w に暗号化して書き出す Writer を返す
+// Close で最後のレコードを書いてから w を閉じる（閉じないと復号できないファイルになる）
+func newEncryptWriter(w io.WriteCloser, key *EncryptionKey) (io.WriteCloser, error) {
+	salt := make([]byte, encryptionSaltSize)
+	prefix := make([]byte, encryptionNoncePrefixSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	aead, err := key.aead(key.mode(), salt)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(encryptionMagic), key.mode())
+	header = append(append(header, salt...), prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix}, nil
+}
+
+// Write は平文をレコードの大きさに区切って暗号化する（最後のレコードは Close で書く）
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	e.buf = append(e.buf, p...)
+	for len(e.buf) > encryptionChunkSize {
+		if e.err = e.writeRecord(e.buf[:encryptionChunkSize], false); e.err != nil {
+			return 0, e.err
+		}
+		e.buf = e.buf[encryptionChunkSize:]
+	}
+	return len(p), nil
+}
+
+// writeRecord はレコードを1つ暗号化して書き出す
+func (e *encryptWriter) writeRecord(plain []byte, last bool) error {
+	sealed := e.aead.Seal(nil, encryptionNonce(e.prefix, e.counter, last), plain, nil)
+	e.counter++
+	record := make([]byte, 0, 5+len(sealed))
+	if last {
+		record = append(record, 1)
+	} else {
+		record = append(record, 0)
+	}
+	record = binary.BigEndian.AppendUint32(record, uint32(len(sealed)))
+	_, err := e.w.Write(append(record, sealed...))
+	return err
+}
+
+// Close は最後のレコードを書いてから出力先を閉じる
+func (e *encryptWriter) Close() error {
+	err := e.err
+	if err == nil {
+		err = e.writeRecord(e.buf, true)
+		e.err = errors.New("暗号化の出力は閉じられています")
+	}
+	if cerr := e.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// decryptReader は暗号化したファイルを復号しながら読む
+type decryptReader struct {
+	r       io.Reader
+	key     *EncryptionKey
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	// streams は読み終えたヘッダーの数（追記したファイルはヘッダーが続く）
+	streams int
+}
+
+// newDecryptReader は r を復号して読む Reader を返す
+func newDecryptReader(r io.Reader, key *EncryptionKey) io.Reader {
+	return &decryptReader{r: r, key: key}
+}
+
+// Read は復号した平文を読む
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.aead == nil {
+			if err := d.readHeader(); err != nil {
+				return 0, err
+			}
+		}
+		if err := d.readRecord(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// readHeader は次のヘッダーを読む（前のヘッダーの最後のレコードの後でファイルが終われば io.EOF）
+func (d *decryptReader) readHeader() error {
+	header := make([]byte, len(encryptionMagic)+1+encryptionSaltSize+encryptionNoncePrefixSize)
+	n, err := io.ReadFull(d.r, header)
+	if n == 0 && errors.Is(err, io.EOF) && d.streams > 0 {
+		return io.EOF
+	}
+	if err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return errNotEncrypted
+	}
+	rest := header[len(encryptionMagic):]
+	aead, err := d.key.aead(rest[0], rest[1:1+encryptionSaltSize])
+	if err != nil {
+		return err
+	}
+	d.aead, d.prefix, d.counter = aead, rest[1+encryptionSaltSize:], 0
+	return nil
+}
+
+// readRecord は次のレコードを復号する
+func (d *decryptReader) readRecord() error {
+	var head [5]byte
+	if _, err := io.ReadFull(d.r, head[:]); err != nil {
+		return errDecrypt
+	}
+	last := head[0] == 1
+	size := binary.BigEndian.Uint32(head[1:])
+	if head[0] > 1 || size > encryptionChunkSize+uint32(d.aead.Overhead()) {
+		return errDecrypt
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return errDecrypt
+	}
+	plain, err := d.aead.Open(nil, encryptionNonce(d.prefix, d.counter, last), sealed, nil)
+	if err != nil {
+		return errDecrypt
+	}
+	d.counter++
+	d.plain = plain
+	if last {
+		d.aead = nil
+		d.streams++
+	}
+	return nil
+}
+
+// splitEncryptionExt はパスの最後の拡張子が .enc なら true と、.enc を除いたパスを返す
+func splitEncryptionExt(path string) (bool, string) {
+	if strings.EqualFold(filepath.Ext(path), encryptionExt) {
+		return true, path[:len(path)-len(encryptionExt)]
+	}
+	return false, path
+}
+
+// withEncryptionExt はパスが .enc で終わらなければ .enc を付ける
+func withEncryptionExt(path string) string {
+	if encrypted, _ := splitEncryptionExt(path); encrypted {
+		return path
+	}
+	return path + encryptionExt
+}
+
+// isEncryptedFile はファイルの先頭が encryptionMagic か（ファイルがなければ false）
+func isEncryptedFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	magic := make([]byte, len(encryptionMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(magic, []byte(encryptionMagic)), nil
+}
+
+// encryptFile は src を暗号化して dst に書き出す（一時ファイルから置き換える）
+func encryptFile(src, dst string, key *EncryptionKey) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("暗号化するファイルを開けませんでした: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+	tmp := dst + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	w, err := newEncryptWriter(f, key)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("暗号化に失敗: %w", err)
+	}
+	_, err = io.Copy(w, in)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("暗号化に失敗: %w", err)
+	}
+	return nil
+}
+
+// decryptFile は暗号化した src を復号して dst（本人だけが読めるファイル）に書き出す
+func decryptFile(src, dst string, key *EncryptionKey) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("復号するファイルを開けませんでした: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	_, err = io.Copy(out, newDecryptReader(in, key))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// openEncryptedDB は暗号化したSQLiteファイル（アーカイブDBなど）を読み取り専用で開く
+// 一時ファイルに復号して接続を1本開いた後、一時ファイルは削除する（接続を閉じるまで読める）
+func openEncryptedDB(path string) (*sql.DB, error) {
+	key, err := loadEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "hist-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := decryptFile(path, tmp.Name(), key); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// 復号した一時ファイルは hist 以外が書き換えないため immutable で開く（ロックを取らない）
+	db, err := sql.Open(SQLiteReadOnlyDriver, readOnlyDSN(tmp.Name(), true))
+	if err != nil {
+		return nil, fmt.Errorf("データベースを開けませんでした: %w", err)
+	}
+	// 一時ファイルを削除した後も読めるよう、同じ接続を使い続ける
+	useSharedConnection(db)
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("データベースを開けませんでした: %w", err)
+	}
+	return db, nil
+}
+
+// runDecryptCommand は hist decrypt サブコマンドを実行する
+func runDecryptCommand(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	out := fs.String("out", "", "復号したファイルの書き出し先（省略時は .enc を除いたパス、- で標準出力）")
+	keyFile := fs.String("key-file", "", "鍵ファイル（環境変数 "+HistKeyFileEnv+" と同じ。省略時は "+HistPassphraseEnv+" のパスフレーズ）")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist decrypt [オプション] <ファイル.enc>\n\n")
+		fmt.Fprintf(fs.Output(), "  -encrypt で書き出したファイルや暗号化したアーカイブDBを復号します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("復号するファイルを1つ指定してください")
+	}
+	if err := setKeyFileEnv(*keyFile); err != nil {
+		return err
+	}
+	key, err := loadEncryptionKey()
+	if err != nil {
+		return err
+	}
+	src := fs.Arg(0)
+
+	if *out == "-" {
+		in, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf("復号するファイルを開けませんでした: %w", err)
+		}
+		defer func() { _ = in.Close() }()
+		_, err = io.Copy(os.Stdout, newDecryptReader(in, key))
+		return err
+	}
+	dst := *out
+	if dst == "" {
+		encrypted, base := splitEncryptionExt(src)
+		if !encrypted {
+			return errors.New("拡張子が .enc でないファイルは -out で書き出し先を指定してください")
+		}
+		dst = base
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("出力先のファイルが既に存在します: %s", dst)
+	}
+	if err := decryptFile(src, dst, key); err != nil {
+		return err
+	}
+	fmt.Printf("復号しました: %s\n", dst)
+	return nil
+}
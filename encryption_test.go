@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestKeyFile は鍵ファイルを作って HIST_KEY_FILE に設定する
+func writeTestKeyFile(t *testing.T, hexKey string) *EncryptionKey {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hist.key")
+	if err := os.WriteFile(path, []byte(hexKey+"\n"), configFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(HistKeyFileEnv, path)
+	key, err := loadEncryptionKey()
+	if err != nil {
+		t.Fatalf("loadEncryptionKey() error = %v", err)
+	}
+	return key
+}
+
+// encryptBytes は data を暗号化したバイト列を返す
+func encryptBytes(t *testing.T, data []byte, key *EncryptionKey) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newEncryptWriter(nopWriteCloser{&buf}, key)
+	if err != nil {
+		t.Fatalf("newEncryptWriter() error = %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestLoadEncryptionKey(t *testing.T) {
+	t.Setenv(HistKeyFileEnv, "")
+	t.Setenv(HistPassphraseEnv, "")
+	if _, err := loadEncryptionKey(); err == nil {
+		t.Error("鍵がないのにエラーにならない")
+	}
+	t.Setenv(HistPassphraseEnv, "correct horse")
+	if key, err := loadEncryptionKey(); err != nil || key.mode() != encryptionModePassphrase {
+		t.Errorf("パスフレーズの loadEncryptionKey() = %+v, %v", key, err)
+	}
+	// 鍵ファイルがパスフレーズより優先される
+	if key := writeTestKeyFile(t, strings.Repeat("ab", encryptionKeySize)); key.mode() != encryptionModeKeyFile {
+		t.Errorf("鍵ファイルの mode() = %d", key.mode())
+	}
+	path := filepath.Join(t.TempDir(), "short.key")
+	if err := os.WriteFile(path, []byte("abcd"), configFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(HistKeyFileEnv, path)
+	if _, err := loadEncryptionKey(); err == nil {
+		t.Error("短い鍵ファイルがエラーにならない")
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key := writeTestKeyFile(t, strings.Repeat("01", encryptionKeySize))
+	tests := map[string][]byte{
+		"空":        {},
+		"短い":       []byte("https://go.dev/\n"),
+		"レコードちょうど": bytes.Repeat([]byte("a"), encryptionChunkSize),
+		"複数のレコード":  bytes.Repeat([]byte("0123456789"), encryptionChunkSize/3),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			sealed := encryptBytes(t, data, key)
+			if bytes.Contains(sealed, []byte("0123456789")) {
+				t.Error("平文が暗号文に残っている")
+			}
+			got, err := io.ReadAll(newDecryptReader(bytes.NewReader(sealed), key))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("復号 = %d バイト, %v, want %d バイト", len(got), err, len(data))
+			}
+		})
+	}
+
+	// 追記したファイル（暗号文の連結）は続けて復号できる
+	appended := append(encryptBytes(t, []byte("first\n"), key), encryptBytes(t, []byte("second\n"), key)...)
+	if got, err := io.ReadAll(newDecryptReader(bytes.NewReader(appended), key)); err != nil || string(got) != "first\nsecond\n" {
+		t.Errorf("連結した暗号文の復号 = %q, %v", got, err)
+	}
+}
+
+func TestEncryptPassphrase(t *testing.T) {
+	sealed := encryptBytes(t, []byte("secret"), &EncryptionKey{passphrase: "correct horse"})
+	if got, err := io.ReadAll(newDecryptReader(bytes.NewReader(sealed), &EncryptionKey{passphrase: "correct horse"})); err != nil || string(got) != "secret" {
+		t.Errorf("パスフレーズでの復号 = %q, %v", got, err)
+	}
+	if _, err := io.ReadAll(newDecryptReader(bytes.NewReader(sealed), &EncryptionKey{passphrase: "wrong"})); !errors.Is(err, errDecrypt) {
+		t.Errorf("違うパスフレーズでの復号 error = %v, want errDecrypt", err)
+	}
+	// パスフレーズで暗号化したファイルに鍵ファイルは使えない
+	if _, err := io.ReadAll(newDecryptReader(bytes.NewReader(sealed), &EncryptionKey{key: make([]byte, encryptionKeySize)})); err == nil ||
+		!strings.Contains(err.Error(), HistPassphraseEnv) {
+		t.Errorf("鍵の種類が違う復号 error = %v", err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	key := &EncryptionKey{key: make([]byte, encryptionKeySize)}
+	data := bytes.Repeat([]byte("x"), encryptionChunkSize*2+10)
+	sealed := encryptBytes(t, data, key)
+	headerSize := len(encryptionMagic) + 1 + encryptionSaltSize + encryptionNoncePrefixSize
+	recordSize := 5 + encryptionChunkSize + 16
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)-1] ^= 0xff
+	// 最後のレコードを落とすと、途中で切り詰めたファイルになる
+	truncated := sealed[:headerSize+2*recordSize]
+	// 1つ目と2つ目のレコードを入れ替える
+	swapped := append(append(bytes.Clone(sealed[:headerSize]), sealed[headerSize+recordSize:headerSize+2*recordSize]...),
+		sealed[headerSize:headerSize+recordSize]...)
+	swapped = append(swapped, sealed[headerSize+2*recordSize:]...)
+
+	for name, b := range map[string][]byte{"書き換え": flipped, "切り詰め": truncated, "入れ替え": swapped} {
+		if _, err := io.ReadAll(newDecryptReader(bytes.NewReader(b), key)); !errors.Is(err, errDecrypt) {
+			t.Errorf("%s: error = %v, want errDecrypt", name, err)
+		}
+	}
+	if _, err := io.ReadAll(newDecryptReader(strings.NewReader("date,url\n"), key)); !errors.Is(err, errNotEncrypted) {
+		t.Errorf("平文の復号 error = %v, want errNotEncrypted", err)
+	}
+}
+
+func TestEncryptedCompressedOutput(t *testing.T) {
+	key := writeTestKeyFile(t, strings.Repeat("02", encryptionKeySize))
+	path := filepath.Join(t.TempDir(), "visits.ndjson.gz.enc")
+	if c, base := splitCompressionExt(path); c != CompressionGzip || base != strings.TrimSuffix(path, ".gz.enc") {
+		t.Errorf("splitCompressionExt() = %q, %q", c, base)
+	}
+	for _, line := range []string{"{\"id\":1}\n", "{\"id\":2}\n"} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, closeOutput, err := compressOutput(f, path)
+		if err != nil {
+			t.Fatalf("compressOutput() error = %v", err)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatal(err)
+		}
+		if err := closeOutput(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if encrypted, err := isEncryptedFile(path); err != nil || !encrypted {
+		t.Fatalf("isEncryptedFile() = %v, %v", encrypted, err)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = in.Close() }()
+	zr, err := gzip.NewReader(newDecryptReader(in, key))
+	if err != nil {
+		t.Fatalf("復号した gzip を開けない: %v", err)
+	}
+	if got, err := io.ReadAll(zr); err != nil || string(got) != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("追記した暗号化ファイルの内容 = %q, %v", got, err)
+	}
+}
+
+func TestExportSQLiteEncrypted(t *testing.T) {
+	writeTestKeyFile(t, strings.Repeat("03", encryptionKeySize))
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.db.enc")
+	if count, err := exportSQLite(db, path, Config{}); err != nil || count != 5 {
+		t.Fatalf("exportSQLite() = %d, %v", count, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("平文の一時ファイルが残っている: %v", entries)
+	}
+	if encrypted, _ := isEncryptedFile(path); !encrypted {
+		t.Fatal("書き出したファイルが暗号化されていない")
+	}
+	out, err := openDB(path)
+	if err != nil {
+		t.Fatalf("暗号化したデータベースを開けない: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	var count int
+	if err := out.QueryRow("SELECT COUNT(*) FROM visits").Scan(&count); err != nil || count != 5 {
+		t.Errorf("visits = %d, %v, want 5", count, err)
+	}
+}
+
+func TestWithArchiveDBEncrypted(t *testing.T) {
+	writeTestKeyFile(t, strings.Repeat("04", encryptionKeySize))
+	dir := t.TempDir()
+	path := filepath.Join(dir, archiveFileName)
+
+	// 平文のアーカイブDBは暗号化に移行する
+	if _, err := withArchiveDB(path, false, func(*sql.DB) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	insert := func(archive *sql.DB) error {
+		n++
+		_, err := archive.Exec(`INSERT INTO history_items (url, domain_expansion, visit_count) VALUES (?, 'go', 1)`, fmt.Sprintf("https://go.dev/%d", n))
+		return err
+	}
+	got, err := withArchiveDB(path, true, insert)
+	if err != nil || got != path+encryptionExt {
+		t.Fatalf("withArchiveDB() = %q, %v", got, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("平文のアーカイブDBが残っている")
+	}
+	// 暗号化したアーカイブDBがあれば -encrypt なしでも暗号化したまま使う
+	if got, err := withArchiveDB(path, false, insert); err != nil || got != path+encryptionExt {
+		t.Fatalf("2回目の withArchiveDB() = %q, %v", got, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("一時ファイルが残っている: %v", entries)
+	}
+
+	archive, err := openDB(got)
+	if err != nil {
+		t.Fatalf("暗号化したアーカイブDBを開けない: %v", err)
+	}
+	defer func() { _ = archive.Close() }()
+	var count int
+	if err := archive.QueryRow("SELECT COUNT(*) FROM history_items").Scan(&count); err != nil || count != 2 {
+		t.Errorf("history_items = %d, %v, want 2", count, err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExplainedQuery は -explain で表示するクエリの情報
+type ExplainedQuery struct {
+	Name  string
+	Query string
+	Args  []interface{}
+	Plan  []QueryPlanStep
+}
+
+// QueryPlanStep は EXPLAIN QUERY PLAN の1行
+type QueryPlanStep struct {
+	ID     int
+	Parent int
+	Detail string
+}
+
+// collectCLIQueries はCLIモードで実行されるクエリを集める
+func collectCLIQueries(config Config) []ExplainedQuery {
+	queries := []ExplainedQuery{
+		{Name: "総訪問数", Query: totalVisitsQuery},
+	}
+
+	if config.ShowHistory {
+		fetchLimit := config.Limit
+		if len(config.Filter.IgnoreDomains) > 0 {
+			fetchLimit = config.Limit * 3 // getRecentVisits と同じ
+		}
+		query, args := buildRecentVisitsQuery(fetchLimit, config.Filter)
+		queries = append(queries, ExplainedQuery{Name: "最近の訪問履歴", Query: query, Args: args})
+	}
+	if config.ShowDomains {
+		queries = append(queries, ExplainedQuery{Name: "ドメイン別訪問数", Query: domainStatsQuery})
+	}
+	if config.ShowPaths {
+		queries = append(queries, ExplainedQuery{Name: "ドメイン・パス別訪問数", Query: domainPathStatsQuery})
+	}
+	if config.ShowHourly {
+		query, args := buildVisitTimeQuery(config.Filter)
+		queries = append(queries, ExplainedQuery{Name: "時間帯別訪問数", Query: query, Args: args})
+	}
+	if config.ShowDaily {
+		query, args := buildVisitTimeQuery(config.Filter)
+		queries = append(queries, ExplainedQuery{Name: "日別訪問数", Query: query, Args: args})
+	}
+
+	return queries
+}
+
+// explainQueryPlan は EXPLAIN QUERY PLAN でSQLiteの実行計画を取得する
+// クエリ自体は実行しない
+func explainQueryPlan(db *sql.DB, query string, args []interface{}) ([]QueryPlanStep, error) {
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("実行計画の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var steps []QueryPlanStep
+	for rows.Next() {
+		var step QueryPlanStep
+		var notUsed int
+		if err := rows.Scan(&step.ID, &step.Parent, &notUsed, &step.Detail); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+// formatSQLArg はバインドパラメータを表示用に整形する
+func formatSQLArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return "'" + sqlEscapeString(v) + "'"
+	case float64:
+		// visit_time と比較する値は日時も併記する
+		return fmt.Sprintf("%s  -- %s", formatSQLValue(v), convertCoreDataTimestamp(v).Format(TimeFormatFull))
+	default:
+		return formatSQLValue(v)
+	}
+}
+
+// compactSQL はインデントや改行をまとめて1行のSQLにする
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// printExplain はクエリ・パラメータ・実行計画を出力する
+func printExplain(w io.Writer, queries []ExplainedQuery) {
+	for i, q := range queries {
+		if i > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "🔍 %s\n", q.Name)
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		_, _ = fmt.Fprintf(w, "SQL:\n  %s\n", compactSQL(q.Query))
+		if len(q.Args) > 0 {
+			_, _ = fmt.Fprintf(w, "パラメータ:\n")
+			for j, arg := range q.Args {
+				_, _ = fmt.Fprintf(w, "  $%d = %s\n", j+1, formatSQLArg(arg))
+			}
+		}
+		_, _ = fmt.Fprintf(w, "実行計画:\n")
+		depth := make(map[int]int)
+		for _, step := range q.Plan {
+			depth[step.ID] = depth[step.Parent] + 1
+			_, _ = fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", depth[step.ID]), step.Detail)
+		}
+	}
+}
+
+// runExplainMode はCLIモードのクエリを実行せず、SQLと実行計画を表示する
+func runExplainMode(db *sql.DB, config Config, w io.Writer) error {
+	queries := collectCLIQueries(config)
+	for i := range queries {
+		plan, err := explainQueryPlan(db, queries[i].Query, queries[i].Args)
+		if err != nil {
+			return fmt.Errorf("%s: %w", queries[i].Name, err)
+		}
+		queries[i].Plan = plan
+	}
+	printExplain(w, queries)
+	return nil
+}
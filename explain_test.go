@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCollectCLIQueries(t *testing.T) {
+	config := Config{ShowHistory: true, ShowHourly: true, Limit: 10, Filter: SearchFilter{Keyword: "go"}}
+	queries := collectCLIQueries(config)
+
+	// 総訪問数 + 履歴 + 時間帯
+	if len(queries) != 3 {
+		t.Fatalf("collectCLIQueries() returned %d queries, want 3", len(queries))
+	}
+	if queries[1].Name != "最近の訪問履歴" || len(queries[1].Args) != 3 {
+		t.Errorf("履歴クエリが期待と異なる: %+v", queries[1])
+	}
+}
+
+func TestRunExplainMode(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	config := Config{ShowHistory: true, Limit: 5, Filter: SearchFilter{Keyword: "github"}}
+	var buf bytes.Buffer
+	if err := runExplainMode(db, config, &buf); err != nil {
+		t.Fatalf("runExplainMode失敗: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"最近の訪問履歴", "SQL:", "$1 = '%github%'", "実行計画:", "SCAN"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれていない:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// faviconDirName はファビコンのキャッシュを置く設定ディレクトリ内のディレクトリ
+	faviconDirName = "favicons"
+	// faviconMissingSuffix は取得できなかったドメインの目印のファイルの拡張子
+	faviconMissingSuffix = ".missing"
+	// FaviconTimeout はファビコン1件の取得を打ち切るまでの時間
+	FaviconTimeout = 5 * time.Second
+	// FaviconMaxBytes は取得するファビコンの最大サイズ
+	FaviconMaxBytes = 256 << 10
+	// FaviconCacheTTL は取得したファビコンを取り直すまでの期間
+	FaviconCacheTTL = 30 * 24 * time.Hour
+	// FaviconMissingTTL は取得できなかったドメインを再び取りに行くまでの期間
+	FaviconMissingTTL = 24 * time.Hour
+)
+
+// errFaviconNotFound はファビコンがない・画像でない・取得できなかった場合のエラー
+var errFaviconNotFound = errors.New("ファビコンが見つかりません")
+
+// FaviconCache はドメインのファビコンを取得し、設定ディレクトリにキャッシュする
+// -favicons を指定した場合だけ使い、それ以外では hist から外部への通信はしない
+type FaviconCache struct {
+	dir    string
+	client *http.Client
+	// origin はドメインのファビコンを取得するURLの scheme://host 部分（テストで差し替える）
+	origin func(domain string) string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // ドメイン → 同じドメインを同時に取りに行かないためのロック
+}
+
+// newFaviconCache は ~/.config/hist/favicons にキャッシュするファビコンの取得を作る
+func newFaviconCache() (*FaviconCache, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return newFaviconCacheDir(filepath.Join(configDir, faviconDirName)), nil
+}
+
+// errFaviconLocalAddress は名前解決した接続先がループバック・プライベート・リンクローカルのアドレスだった場合のエラー
+var errFaviconLocalAddress = errors.New("ローカル・社内ネットワークのアドレスには接続しません")
+
+// newFaviconCacheDir は dir にキャッシュするファビコンの取得を作る
+func newFaviconCacheDir(dir string) *FaviconCache {
+	// 名前だけの確認（validFaviconDomain）では 127.0.0.1.nip.io や DNS リバインディングのように
+	// 公開の名前がローカルのアドレスを指す場合を防げないため、名前解決した後の接続先のアドレスで拒否する
+	// プロキシを通すと接続先がプロキシになり確かめられないため、環境変数のプロキシは使わない
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: FaviconTimeout, Control: faviconDialControl}).DialContext
+	return &FaviconCache{
+		dir: dir,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   FaviconTimeout,
+			// 転送先がローカル・社内ホストなら取りに行かない
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 || isLocalHost(req.URL.Hostname()) {
+					return errFaviconNotFound
+				}
+				return nil
+			},
+		},
+		origin: func(domain string) string { return "https://" + domain },
+		locks:  make(map[string]*sync.Mutex),
+	}
+}
+
+// validFaviconDomain はファビコンを取りに行けるドメイン名か（IPアドレスやローカル・社内ホストは不可）
+func validFaviconDomain(domain string) bool {
+	if domain == "" || len(domain) > 253 || strings.HasPrefix(domain, ".") || strings.Contains(domain, "..") {
+		return false
+	}
+	for _, r := range domain {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-') {
+			return false
+		}
+	}
+	return net.ParseIP(domain) == nil && !isLocalHost(domain)
+}
+
+// faviconDialControl は net.Dialer の Control で、接続先が公開のアドレスでなければ接続前に拒否する（リダイレクト先も同じ）
+func faviconDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicFaviconIP(ip) {
+		return fmt.Errorf("%w: %s", errFaviconLocalAddress, host)
+	}
+	return nil
+}
+
+// publicFaviconIP はファビコンを取りに行ってよいアドレスか（ループバック・プライベート・リンクローカル・未指定・マルチキャストは不可）
+func publicFaviconIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// lock はドメインごとのロックを返す
+func (c *FaviconCache) lock(domain string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.locks[domain]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[domain] = l
+	}
+	return l
+}
+
+// Get はドメインのファビコンを返す（キャッシュが古いかなければ取得して保存する）
+// ファビコンがなければ errFaviconNotFound を返し、FaviconMissingTTL の間は取りに行かない
+func (c *FaviconCache) Get(ctx context.Context, domain string) ([]byte, error) {
+	if !validFaviconDomain(domain) {
+		return nil, errFaviconNotFound
+	}
+	l := c.lock(domain)
+	l.Lock()
+	defer l.Unlock()
+
+	path := filepath.Join(c.dir, domain+".ico")
+	missingPath := filepath.Join(c.dir, domain+faviconMissingSuffix)
+	cached, cacheErr := os.ReadFile(path)
+	if cacheErr == nil && fileFresh(path, FaviconCacheTTL) {
+		return cached, nil
+	}
+	if cacheErr != nil && fileFresh(missingPath, FaviconMissingTTL) {
+		return nil, errFaviconNotFound
+	}
+
+	data, err := c.fetch(ctx, domain)
+	if err != nil {
+		// 接続を切られた場合は、ファビコンがないとは記録しない
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 取り直せなければ古いキャッシュを使い続ける
+		if cacheErr == nil {
+			return cached, nil
+		}
+		if err := c.save(missingPath, nil); err != nil {
+			return nil, err
+		}
+		return nil, errFaviconNotFound
+	}
+	if err := c.save(path, data); err != nil {
+		return nil, err
+	}
+	_ = os.Remove(missingPath)
+	return data, nil
+}
+
+// fetch はドメインの /favicon.ico を取得する（画像でなければ errFaviconNotFound）
+func (c *FaviconCache) fetch(ctx context.Context, domain string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.origin(domain)+"/favicon.ico", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "hist/"+toolVersion())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errFaviconNotFound
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, FaviconMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > FaviconMaxBytes || !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return nil, errFaviconNotFound
+	}
+	return data, nil
+}
+
+// save はキャッシュのファイルを書き出す（data が nil なら取得できなかった目印の空ファイル）
+func (c *FaviconCache) save(path string, data []byte) error {
+	if err := os.MkdirAll(c.dir, configDirPerms); err != nil {
+		return fmt.Errorf("ファビコンのキャッシュディレクトリの作成に失敗: %w", err)
+	}
+	if err := os.WriteFile(path, data, configFilePerms); err != nil {
+		return fmt.Errorf("ファビコンのキャッシュの書き込みに失敗: %w", err)
+	}
+	return nil
+}
+
+// fileFresh はファイルがあり、更新から ttl 以内かを返す
+func fileFresh(path string, ttl time.Duration) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < ttl
+}
+
+// handleAPIFavicon は GET /api/favicon/{domain} でドメインのファビコンを返す
+// -favicons を指定していない場合とリダクト対象のドメインは 404
+func (s *WebServer) handleAPIFavicon(w http.ResponseWriter, r *http.Request) {
+	if s.favicons == nil {
+		http.Error(w, "ファビコンの取得は無効です（-favicons で有効にできます）", http.StatusNotFound)
+		return
+	}
+	domain := strings.ToLower(r.PathValue("domain"))
+	if !validFaviconDomain(domain) {
+		http.Error(w, fmt.Sprintf("ファビコンを取得できないドメインです: %s", domain), http.StatusBadRequest)
+		return
+	}
+	if domainMatchesList(domain, s.redactList()) {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := s.favicons.Get(r.Context(), domain)
+	if errors.Is(err, errFaviconNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "max-age=86400")
+	_, _ = w.Write(data)
+}
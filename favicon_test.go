@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testPNG は http.DetectContentType で image/png と判定される最小のデータ
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newTestFaviconCache は origin をテスト用のサーバーに向けたファビコンの取得と、リクエスト数を返す
+// github.com は PNG、example.com は HTML を返し、それ以外は 404
+func newTestFaviconCache(t *testing.T) (*FaviconCache, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("domain") {
+		case "github.com":
+			_, _ = w.Write(testPNG)
+		case "example.com":
+			_, _ = w.Write([]byte("<!DOCTYPE html><html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	cache := newFaviconCacheDir(t.TempDir())
+	// 全てのドメインへのリクエストをテスト用のサーバーに送り、ドメインはクエリで渡す
+	cache.origin = func(domain string) string { return "http://" + domain }
+	cache.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.RawQuery = "domain=" + req.URL.Host
+		req.URL.Host = ts.Listener.Addr().String()
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	return cache, &requests
+}
+
+// roundTripFunc は関数を http.RoundTripper にする
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestValidFaviconDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"github.com", true},
+		{"docs.python.org", true},
+		{"", false},
+		{"../etc", false},
+		{".github.com", false},
+		{"GitHub.com", false},
+		{"github.com/evil", false},
+		{"localhost", false},
+		{"printer.local", false},
+		{"192.168.0.1", false},
+		{"8.8.8.8", false},
+	}
+	for _, tt := range tests {
+		if got := validFaviconDomain(tt.domain); got != tt.want {
+			t.Errorf("validFaviconDomain(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconCache(t *testing.T) {
+	cache, requests := newTestFaviconCache(t)
+	ctx := context.Background()
+
+	for range 2 {
+		data, err := cache.Get(ctx, "github.com")
+		if err != nil {
+			t.Fatalf("Get(github.com) error = %v", err)
+		}
+		if string(data) != string(testPNG) {
+			t.Errorf("Get(github.com) = %q", data)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("github.com へのリクエスト = %d回, want 1（2回目はキャッシュ）", n)
+	}
+
+	// 画像でない・見つからないものは取得できなかったと記録し、取りに行かない
+	for _, domain := range []string{"example.com", "missing.example"} {
+		for range 2 {
+			if _, err := cache.Get(ctx, domain); !errors.Is(err, errFaviconNotFound) {
+				t.Errorf("Get(%s) error = %v, want errFaviconNotFound", domain, err)
+			}
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("リクエスト = %d回, want 3", n)
+	}
+
+	// 取りに行けないドメインは通信しない
+	if _, err := cache.Get(ctx, "localhost"); !errors.Is(err, errFaviconNotFound) {
+		t.Errorf("Get(localhost) error = %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("localhost を取りに行った（リクエスト %d回）", n)
+	}
+}
+
+// TestFaviconDialControl は名前解決した接続先のアドレスで、ローカル・社内ネットワークへの接続を拒否するか
+func TestFaviconDialControl(t *testing.T) {
+	for address, wantErr := range map[string]bool{
+		"93.184.216.34:443":     false,
+		"[2606:4700::1111]:443": false,
+		"127.0.0.1:443":         true,
+		"[::1]:443":             true,
+		"10.0.0.1:443":          true,
+		"192.168.1.10:80":       true,
+		"172.16.0.5:443":        true,
+		"169.254.169.254:80":    true,
+		"[fe80::1]:443":         true,
+		"[::ffff:127.0.0.1]:80": true,
+		"0.0.0.0:443":           true,
+	} {
+		err := faviconDialControl("tcp", address, nil)
+		if (err != nil) != wantErr {
+			t.Errorf("faviconDialControl(%s) error = %v, wantErr %v", address, err, wantErr)
+		}
+		if err != nil && !errors.Is(err, errFaviconLocalAddress) {
+			t.Errorf("faviconDialControl(%s) error = %v, want errFaviconLocalAddress", address, err)
+		}
+	}
+
+	// 公開の名前に見えてもループバックに接続するなら取りに行かない（127.0.0.1.nip.io や DNS リバインディング）
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(testPNG)
+	}))
+	defer ts.Close()
+	cache := newFaviconCacheDir(t.TempDir())
+	cache.origin = func(string) string { return ts.URL }
+	if _, err := cache.Get(context.Background(), "rebind.example"); !errors.Is(err, errFaviconNotFound) {
+		t.Errorf("Get(rebind.example) error = %v, want errFaviconNotFound", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("ループバックのサーバーに %d回接続した", n)
+	}
+}
+
+func TestHandleAPIFavicon(t *testing.T) {
+	s := &WebServer{redactDomains: []string{"secret.example"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/favicon/{domain}", s.handleAPIFavicon)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// -favicons なしでは取得しない
+	if rec := get("/api/favicon/github.com"); rec.Code != http.StatusNotFound {
+		t.Errorf("無効時のステータス = %d, want 404", rec.Code)
+	}
+
+	cache, requests := newTestFaviconCache(t)
+	s.favicons = cache
+
+	rec := get("/api/favicon/GitHub.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータス = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", ct)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/favicon/missing.example", http.StatusNotFound},
+		{"/api/favicon/secret.example", http.StatusNotFound},
+		{"/api/favicon/sub.secret.example", http.StatusNotFound},
+		{"/api/favicon/192.168.0.1", http.StatusBadRequest},
+		{"/api/favicon/a_b.example", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := get(tt.path); rec.Code != tt.want {
+			t.Errorf("%s のステータス = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+	// リダクト対象のドメインは取りに行かない
+	if n := requests.Load(); n != 2 {
+		t.Errorf("リクエスト = %d回, want 2", n)
+	}
+}
+
+func TestHistoryPageFavicons(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl}
+	defer func() { _ = s.db.Close() }()
+
+	render := func() string {
+		rec := httptest.NewRecorder()
+		s.handleHistory(rec, httptest.NewRequest("GET", "/history", nil))
+		return rec.Body.String()
+	}
+	if strings.Contains(render(), "/api/favicon/") {
+		t.Error("-favicons なしでファビコンを表示している")
+	}
+	s.favicons = newFaviconCacheDir(t.TempDir())
+	if body := render(); !strings.Contains(body, `src="/api/favicon/github.com"`) {
+		t.Error("-favicons でファビコンを表示していない")
+	}
+}
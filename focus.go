@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 集中時間帯と気が散るドメインの設定ファイル
+const (
+	focusFileName       = "focus.txt"
+	distractionFileName = "distractions.txt"
+)
+
+// defaultFocusDays は hist focus で集計する日数
+const defaultFocusDays = 7
+
+// focusInterruptionGap はこの間隔以内に続いた気が散るドメインへの訪問を1回の中断として数える
+const focusInterruptionGap = 5 * time.Minute
+
+// FocusWindow は「HH:MM-HH:MM [曜日]」形式の集中時間帯（ローカル時刻）
+type FocusWindow struct {
+	Start string
+	End   string
+	// Weekdays は対象曜日（0=日曜）。空の場合は毎日
+	Weekdays []int
+}
+
+// FocusDay は1日分の気が散るドメインへの訪問数
+type FocusDay struct {
+	Date          string `json:"date"`
+	Inside        int    `json:"inside"`
+	Outside       int    `json:"outside"`
+	Interruptions int    `json:"interruptions"`
+	// Holiday は休日の名前（休日は集中時間帯を適用しない）
+	Holiday string `json:"holiday,omitempty"`
+}
+
+// FocusReport は hist focus の結果
+type FocusReport struct {
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	Inside        int           `json:"inside"`
+	Outside       int           `json:"outside"`
+	Interruptions int           `json:"interruptions"`
+	Days          []FocusDay    `json:"days"`
+	TopDomains    []DomainStats `json:"top_domains"`
+}
+
+// parseFocusWindow は「HH:MM-HH:MM [曜日]」形式の集中時間帯を解析する
+// 時刻・曜日の書式はイグノアルールと同じ
+func parseFocusWindow(s string) (FocusWindow, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return FocusWindow{}, fmt.Errorf("集中時間帯は「HH:MM-HH:MM [曜日]」形式で指定してください: %s", s)
+	}
+	rule, err := parseIgnoreRule(ignoreRuleWildcard + " @" + strings.Join(fields, " "))
+	if err != nil {
+		return FocusWindow{}, fmt.Errorf("集中時間帯の形式が不正です（HH:MM-HH:MM [曜日]）: %s", s)
+	}
+	return FocusWindow{Start: rule.Start, End: rule.End, Weekdays: rule.Weekdays}, nil
+}
+
+// String は focus.txt の1行の形式で返す
+func (w FocusWindow) String() string {
+	rule := IgnoreRule{Domain: ignoreRuleWildcard, Start: w.Start, End: w.End, Weekdays: w.Weekdays}
+	return strings.TrimPrefix(rule.String(), ignoreRuleWildcard+" @")
+}
+
+// contains は時刻 t（表示用のタイムゾーンに変換済み）が集中時間帯に入るかを返す
+func (w FocusWindow) contains(t time.Time) bool {
+	if len(w.Weekdays) > 0 {
+		found := false
+		for _, d := range w.Weekdays {
+			if int(t.Weekday()) == d {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	clock := t.Format(ignoreRuleTimeFormat)
+	if w.Start < w.End {
+		return clock >= w.Start && clock < w.End
+	}
+	// 日付をまたぐ時間帯（例: 22:00-02:00）
+	return clock >= w.Start || clock < w.End
+}
+
+// LoadFocusWindows は集中時間帯を読み込む
+func LoadFocusWindows() ([]FocusWindow, error) {
+	lines, err := loadDomainListFile(focusFileName, "集中時間帯")
+	if err != nil {
+		return nil, err
+	}
+	var windows []FocusWindow
+	for _, line := range lines {
+		w, err := parseFocusWindow(line)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// LoadDistractionList は気が散るドメインの一覧を読み込む
+func LoadDistractionList() ([]string, error) {
+	return loadDomainListFile(distractionFileName, "気が散るドメイン一覧")
+}
+
+// addToListFile は1行1項目のリストファイルに重複なく追加する
+func addToListFile(fileName, label, entry string) error {
+	entries, err := loadDomainListFile(fileName, label)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e == entry {
+			return nil // 既に存在する
+		}
+	}
+	return saveDomainListFile(fileName, label, append(entries, entry))
+}
+
+// removeFromListFile は1行1項目のリストファイルから削除する
+func removeFromListFile(fileName, label, entry string) error {
+	entries, err := loadDomainListFile(fileName, label)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, e := range entries {
+		if e != entry {
+			kept = append(kept, e)
+		}
+	}
+	return saveDomainListFile(fileName, label, kept)
+}
+
+// getFocusReport は from〜to（日付単位、両端を含む）の気が散るドメインへの訪問を、集中時間帯の内外に分けて集計する
+// 日付・時刻の判定は loc のタイムゾーンで行う。holidays の休日は全ての訪問を集中時間帯の外として数える
+func getFocusReport(db dbQuerier, windows []FocusWindow, distractions []string, from, to time.Time, loc *time.Location, holidays *Holidays) (FocusReport, error) {
+	report := FocusReport{From: from.Format(TimeFormatDate), To: to.Format(TimeFormatDate)}
+	days := make(map[string]*FocusDay)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format(TimeFormatDate)
+		holiday, _ := holidays.name(d)
+		days[date] = &FocusDay{Date: date, Holiday: holiday}
+		report.Days = append(report.Days, FocusDay{Date: date})
+	}
+
+	type focusVisit struct {
+		time   time.Time
+		inside bool
+	}
+	var visits []focusVisit
+	domainCounts := make(map[string]int)
+
+	// 前後のタイムゾーン差を吸収するため1日広めに取得し、日付は loc で判定する
+	filter := SearchFilter{From: from.AddDate(0, 0, -1), To: to.AddDate(0, 0, 1)}
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		domain := extractDomain(v.URL)
+		if !domainMatchesList(domain, distractions) {
+			return nil
+		}
+		t := v.VisitTime.In(loc)
+		day := days[t.Format(TimeFormatDate)]
+		if day == nil {
+			return nil
+		}
+
+		inside := false
+		for _, w := range windows {
+			if day.Holiday == "" && w.contains(t) {
+				inside = true
+				break
+			}
+		}
+		if inside {
+			day.Inside++
+			domainCounts[domain]++
+		} else {
+			day.Outside++
+		}
+		visits = append(visits, focusVisit{time: t, inside: inside})
+		return nil
+	})
+	if err != nil {
+		return FocusReport{}, fmt.Errorf("集中時間帯の集計に失敗: %w", err)
+	}
+
+	// 集中時間帯の中で、前の気が散る訪問から focusInterruptionGap 以上空いた訪問を中断の始まりとして数える
+	sort.Slice(visits, func(i, j int) bool { return visits[i].time.Before(visits[j].time) })
+	var last time.Time
+	for _, v := range visits {
+		if v.inside && (last.IsZero() || v.time.Sub(last) > focusInterruptionGap) {
+			days[v.time.Format(TimeFormatDate)].Interruptions++
+		}
+		last = v.time
+	}
+
+	for i, d := range report.Days {
+		report.Days[i] = *days[d.Date]
+		report.Inside += days[d.Date].Inside
+		report.Outside += days[d.Date].Outside
+		report.Interruptions += days[d.Date].Interruptions
+	}
+
+	for domain, count := range domainCounts {
+		report.TopDomains = append(report.TopDomains, DomainStats{Domain: domain, VisitCount: count})
+	}
+	sort.Slice(report.TopDomains, func(i, j int) bool {
+		if report.TopDomains[i].VisitCount != report.TopDomains[j].VisitCount {
+			return report.TopDomains[i].VisitCount > report.TopDomains[j].VisitCount
+		}
+		return report.TopDomains[i].Domain < report.TopDomains[j].Domain
+	})
+	return report, nil
+}
+
+// printFocusReport は集中時間帯の内外の訪問数と日別の中断回数を出力する
+func printFocusReport(w io.Writer, report FocusReport, windows []FocusWindow) {
+	var labels []string
+	for _, win := range windows {
+		labels = append(labels, win.String())
+	}
+	_, _ = fmt.Fprintf(w, "🍅 集中時間帯の中断 (%s 〜 %s)\n", report.From, report.To)
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	_, _ = fmt.Fprintf(w, "  集中時間帯: %s\n", strings.Join(labels, " / "))
+	_, _ = fmt.Fprintf(w, "  気が散るドメインへの訪問: 集中時間帯の中 %d回 / 外 %d回\n", report.Inside, report.Outside)
+	_, _ = fmt.Fprintf(w, "  中断: %d回\n\n", report.Interruptions)
+
+	maxCount := 0
+	for _, d := range report.Days {
+		maxCount = max(maxCount, d.Interruptions)
+	}
+	for _, d := range report.Days {
+		barLen := 0
+		if maxCount > 0 {
+			barLen = d.Interruptions * BarChartWidth / maxCount
+		}
+		holiday := ""
+		if d.Holiday != "" {
+			holiday = "  🎌 " + d.Holiday
+		}
+		_, _ = fmt.Fprintf(w, "  %s  %s %d回  (中 %d / 外 %d)%s\n", d.Date, padRight(strings.Repeat("█", barLen), BarChartWidth), d.Interruptions, d.Inside, d.Outside, holiday)
+	}
+
+	if len(report.TopDomains) > 0 {
+		_, _ = fmt.Fprintf(w, "\n  集中時間帯によく開いたドメイン:\n")
+		for _, s := range report.TopDomains {
+			_, _ = fmt.Fprintf(w, "    %4d  %s\n", s.VisitCount, s.Domain)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// runFocusCommand は hist focus サブコマンドを実行する
+func runFocusCommand(args []string) error {
+	fs := flag.NewFlagSet("focus", flag.ContinueOnError)
+	days := fs.Int("days", defaultFocusDays, "今日を含む過去N日間を集計")
+	windowAdd := fs.String("window-add", "", "集中時間帯を追加（\"09:00-12:00 mon-fri\"）")
+	windowRemove := fs.String("window-remove", "", "集中時間帯を削除")
+	distractionAdd := fs.String("distraction-add", "", "気が散るドメインを追加")
+	distractionRemove := fs.String("distraction-remove", "", "気が散るドメインを削除")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist focus [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  集中時間帯は ~/.config/hist/%s、気が散るドメインは ~/.config/hist/%s に保存されます\n\n", focusFileName, distractionFileName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *windowAdd != "":
+		w, err := parseFocusWindow(*windowAdd)
+		if err != nil {
+			return err
+		}
+		if err := addToListFile(focusFileName, "集中時間帯", w.String()); err != nil {
+			return err
+		}
+		fmt.Printf("集中時間帯を追加しました: %s\n", w)
+		return nil
+	case *windowRemove != "":
+		w, err := parseFocusWindow(*windowRemove)
+		if err != nil {
+			return err
+		}
+		if err := removeFromListFile(focusFileName, "集中時間帯", w.String()); err != nil {
+			return err
+		}
+		fmt.Printf("集中時間帯を削除しました: %s\n", w)
+		return nil
+	case *distractionAdd != "":
+		if err := addToListFile(distractionFileName, "気が散るドメイン一覧", *distractionAdd); err != nil {
+			return err
+		}
+		fmt.Printf("気が散るドメインに追加しました: %s\n", *distractionAdd)
+		return nil
+	case *distractionRemove != "":
+		if err := removeFromListFile(distractionFileName, "気が散るドメイン一覧", *distractionRemove); err != nil {
+			return err
+		}
+		fmt.Printf("気が散るドメインから削除しました: %s\n", *distractionRemove)
+		return nil
+	}
+
+	if *days <= 0 {
+		return errors.New("-days は1以上で指定してください")
+	}
+	windows, err := LoadFocusWindows()
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		return errors.New("集中時間帯が設定されていません（hist focus -window-add \"09:00-12:00 mon-fri\" で設定）")
+	}
+	distractions, err := LoadDistractionList()
+	if err != nil {
+		return err
+	}
+	if len(distractions) == 0 {
+		return errors.New("気が散るドメインが設定されていません（hist focus -distraction-add twitter.com で設定）")
+	}
+
+	holidays, err := LoadHolidays()
+	if err != nil {
+		return err
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	report, err := getFocusReport(db, windows, distractions, to.AddDate(0, 0, 1-*days), to, time.Local, holidays)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printFocusReport(os.Stdout, report, windows)
+	return nil
+}
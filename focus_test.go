@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseFocusWindow は集中時間帯の解析のテスト
+func TestParseFocusWindow(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"09:00-12:00", "09:00-12:00", false},
+		{"9:00-12:00 mon-fri", "09:00-12:00 mon,tue,wed,thu,fri", false},
+		{"22:00-02:00 sat,sun", "22:00-02:00 sat,sun", false},
+		{"09:00", "", true},
+		{"09:00-09:00", "", true},
+		{"09:00-12:00 someday", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		w, err := parseFocusWindow(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFocusWindow(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && w.String() != tt.want {
+			t.Errorf("parseFocusWindow(%q) = %q, want %q", tt.input, w.String(), tt.want)
+		}
+	}
+}
+
+// TestFocusWindowContains は集中時間帯の判定のテスト
+func TestFocusWindowContains(t *testing.T) {
+	weekday, _ := parseFocusWindow("09:00-12:00 mon-fri")
+	overnight, _ := parseFocusWindow("22:00-02:00")
+
+	// 2025-01-01 は水曜日、2025-01-04 は土曜日
+	tests := []struct {
+		window FocusWindow
+		t      time.Time
+		want   bool
+	}{
+		{weekday, time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), true},
+		{weekday, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{weekday, time.Date(2025, 1, 4, 10, 0, 0, 0, time.UTC), false},
+		{overnight, time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC), true},
+		{overnight, time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC), true},
+		{overnight, time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := tt.window.contains(tt.t); got != tt.want {
+			t.Errorf("%s contains(%v) = %v, want %v", tt.window, tt.t, got, tt.want)
+		}
+	}
+}
+
+// TestGetFocusReport は集中時間帯の内外の集計のテスト
+func TestGetFocusReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 1/1 11:03 にもう一度 youtube.com を開く（5分以内なので同じ中断）
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 2, ?, 'YouTube')`, 757418400+3600+180); err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	window, _ := parseFocusWindow("10:30-12:30")
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+	report, err := getFocusReport(db, []FocusWindow{window}, []string{"youtube.com", "github.com"}, from, to, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("getFocusReport失敗: %v", err)
+	}
+
+	// テストデータ: github.com は両日10時（外）、youtube.com は両日11時（中）、google.com は対象外
+	if report.Inside != 3 || report.Outside != 2 || report.Interruptions != 2 {
+		t.Errorf("集計が期待値と異なる: %+v", report)
+	}
+	if len(report.Days) != 3 {
+		t.Fatalf("日数が期待値と異なる: %+v", report.Days)
+	}
+	if d := report.Days[0]; d.Date != "2025-01-01" || d.Inside != 2 || d.Outside != 1 || d.Interruptions != 1 {
+		t.Errorf("1/1 の集計が期待値と異なる: %+v", d)
+	}
+	if d := report.Days[2]; d.Inside != 0 || d.Interruptions != 0 {
+		t.Errorf("訪問のない日の集計が期待値と異なる: %+v", d)
+	}
+	if len(report.TopDomains) != 1 || report.TopDomains[0].Domain != "youtube.com" || report.TopDomains[0].VisitCount != 3 {
+		t.Errorf("集中時間帯のドメインが期待値と異なる: %+v", report.TopDomains)
+	}
+
+	var buf bytes.Buffer
+	printFocusReport(&buf, report, []FocusWindow{window})
+	out := buf.String()
+	for _, want := range []string{"集中時間帯: 10:30-12:30", "中 3回 / 外 2回", "中断: 2回", "2025-01-03"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれない:\n%s", want, out)
+		}
+	}
+
+	// 休日（1/1 は元日）は集中時間帯を適用しない
+	report, err = getFocusReport(db, []FocusWindow{window}, []string{"youtube.com", "github.com"}, from, to, time.UTC, &Holidays{Japan: true})
+	if err != nil {
+		t.Fatalf("getFocusReport失敗: %v", err)
+	}
+	if d := report.Days[0]; d.Holiday != "元日" || d.Inside != 0 || d.Outside != 3 || d.Interruptions != 0 {
+		t.Errorf("休日の集計が期待値と異なる: %+v", d)
+	}
+	if report.Inside != 1 || report.Interruptions != 1 {
+		t.Errorf("休日を除いた集計が期待値と異なる: %+v", report)
+	}
+}
+
+// TestFocusConfig は集中時間帯・気が散るドメインの設定のテスト
+func TestFocusConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := addToListFile(focusFileName, "集中時間帯", "09:00-12:00"); err != nil {
+		t.Fatalf("addToListFile失敗: %v", err)
+	}
+	if err := addToListFile(focusFileName, "集中時間帯", "09:00-12:00"); err != nil {
+		t.Fatalf("addToListFile失敗: %v", err)
+	}
+	if err := addToListFile(distractionFileName, "気が散るドメイン一覧", "twitter.com"); err != nil {
+		t.Fatalf("addToListFile失敗: %v", err)
+	}
+
+	windows, err := LoadFocusWindows()
+	if err != nil || len(windows) != 1 {
+		t.Errorf("集中時間帯が期待値と異なる: %v, %v", windows, err)
+	}
+	distractions, err := LoadDistractionList()
+	if err != nil || len(distractions) != 1 {
+		t.Errorf("気が散るドメインが期待値と異なる: %v, %v", distractions, err)
+	}
+
+	if err := removeFromListFile(focusFileName, "集中時間帯", "09:00-12:00"); err != nil {
+		t.Fatalf("removeFromListFile失敗: %v", err)
+	}
+	if windows, _ := LoadFocusWindows(); len(windows) != 0 {
+		t.Errorf("削除した集中時間帯が残っている: %v", windows)
+	}
+}
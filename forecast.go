@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 予測に使う日数と予測する日数
+const (
+	// forecastHistoryDays は予測に使う過去の日数（今日は途中のため含めない）
+	forecastHistoryDays = 8 * forecastSeason
+	// ForecastDays は予測する日数（今日から）
+	ForecastDays = 7
+	// forecastSeason は季節性の周期（曜日ごとの増減を1週間の周期として扱う）
+	forecastSeason = 7
+)
+
+// 予測の方法（履歴の日数によって選ぶ）
+const (
+	// ForecastHoltWinters は加法型の Holt-Winters 法（2週間以上の履歴があるとき）
+	ForecastHoltWinters = "holt-winters"
+	// ForecastSeasonalNaive は直近1週間の同じ曜日の値（1週間以上の履歴があるとき）
+	ForecastSeasonalNaive = "seasonal-naive"
+	// ForecastMean は履歴の1日平均（1週間に満たないとき）
+	ForecastMean = "mean"
+)
+
+// Holt-Winters 法の平滑化係数（水準・傾き・季節性）
+const (
+	holtWintersAlpha = 0.3
+	holtWintersBeta  = 0.05
+	holtWintersGamma = 0.3
+)
+
+// ForecastDay は1日の訪問数の予測
+type ForecastDay struct {
+	Date   string  `json:"date"`
+	Visits float64 `json:"visits"`
+}
+
+// DomainForecast は上位ドメインの今後 ForecastDays 日間の訪問数の予測
+type DomainForecast struct {
+	Domain string `json:"domain"`
+	// LastWeek は直近 ForecastDays 日間（昨日まで）の訪問数
+	LastWeek  int     `json:"last_week"`
+	Projected float64 `json:"projected"`
+}
+
+// VisitForecast は -forecast の結果
+type VisitForecast struct {
+	Method string `json:"method"`
+	// HistoryDays は予測に使った日数（履歴の最初の日より前は使わない）
+	HistoryDays int              `json:"history_days"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Daily       []ForecastDay    `json:"daily"`
+	Total       float64          `json:"total"`
+	LastWeek    int              `json:"last_week"`
+	Domains     []DomainForecast `json:"domains"`
+}
+
+// getVisitForecast は昨日までの過去 forecastHistoryDays 日間の日別訪問数から、今日からの ForecastDays 日間の訪問数を予測する
+// 上位 domainLimit 件のドメインも同じ方法で予測する（リダクトリストのドメインはまとめて数える）
+func getVisitForecast(db dbQuerier, domainLimit int, redactDomains []string, now time.Time, filter SearchFilter) (*VisitForecast, error) {
+	dim, err := newGroupDimension(GroupByDomain, groupOptions{redactDomains: redactDomains})
+	if err != nil {
+		return nil, err
+	}
+	query, args := NewQueryBuilder(groupVisitsBaseQuery).WithFilter(filter).Build()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("訪問数の予測に使う履歴の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := today.AddDate(0, 0, -forecastHistoryDays)
+	first := ""
+	dateCounts := make(map[string]int)
+	domainCounts := make(map[string]map[string]int)
+	domainTotals := make(map[string]int)
+	for rows.Next() {
+		var v groupVisit
+		var visitTime float64
+		if err := rows.Scan(&v.id, &visitTime, &v.url); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		v.time = convertCoreDataTimestamp(visitTime)
+		date := v.time.Format(TimeFormatDate)
+		if first == "" || date < first {
+			first = date
+		}
+		if v.time.Before(start) || !v.time.Before(today) {
+			continue
+		}
+		domain := dim.keyOf(v)
+		dateCounts[date]++
+		if domainCounts[domain] == nil {
+			domainCounts[domain] = make(map[string]int)
+		}
+		domainCounts[domain][date]++
+		domainTotals[domain]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	// 履歴の最初の日より前は0件ではなく履歴がない日として除く
+	var dates []string
+	for d := start; d.Before(today); d = d.AddDate(0, 0, 1) {
+		if date := d.Format(TimeFormatDate); first != "" && date >= first {
+			dates = append(dates, date)
+		}
+	}
+	series := func(counts map[string]int) []float64 {
+		values := make([]float64, len(dates))
+		for i, date := range dates {
+			values[i] = float64(counts[date])
+		}
+		return values
+	}
+
+	forecast := &VisitForecast{
+		Method:      forecastMethod(len(dates)),
+		HistoryDays: len(dates),
+		From:        today.Format(TimeFormatDate),
+		To:          today.AddDate(0, 0, ForecastDays-1).Format(TimeFormatDate),
+		Daily:       []ForecastDay{},
+		Domains:     []DomainForecast{},
+	}
+	for i, v := range forecastSeries(series(dateCounts), ForecastDays) {
+		forecast.Daily = append(forecast.Daily, ForecastDay{Date: today.AddDate(0, 0, i).Format(TimeFormatDate), Visits: v})
+		forecast.Total += v
+	}
+	forecast.LastWeek = lastDaysTotal(dateCounts, dates, ForecastDays)
+
+	var domains []string
+	for domain := range domainTotals {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domainTotals[domains[i]] != domainTotals[domains[j]] {
+			return domainTotals[domains[i]] > domainTotals[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	if domainLimit > 0 && len(domains) > domainLimit {
+		domains = domains[:domainLimit]
+	}
+	for _, domain := range domains {
+		projected := 0.0
+		for _, v := range forecastSeries(series(domainCounts[domain]), ForecastDays) {
+			projected += v
+		}
+		forecast.Domains = append(forecast.Domains, DomainForecast{
+			Domain:    domain,
+			LastWeek:  lastDaysTotal(domainCounts[domain], dates, ForecastDays),
+			Projected: projected,
+		})
+	}
+	return forecast, nil
+}
+
+// lastDaysTotal は dates の最後の n 日間の訪問数の合計を返す
+func lastDaysTotal(counts map[string]int, dates []string, n int) int {
+	total := 0
+	for _, date := range dates[max(len(dates)-n, 0):] {
+		total += counts[date]
+	}
+	return total
+}
+
+// forecastMethod は履歴の日数から予測の方法を選ぶ（履歴がなければ空）
+func forecastMethod(historyDays int) string {
+	switch {
+	case historyDays >= 2*forecastSeason:
+		return ForecastHoltWinters
+	case historyDays >= forecastSeason:
+		return ForecastSeasonalNaive
+	case historyDays > 0:
+		return ForecastMean
+	}
+	return ""
+}
+
+// forecastSeries は日別の値の続く horizon 日間を forecastMethod の方法で予測する（負の値は0にする）
+func forecastSeries(values []float64, horizon int) []float64 {
+	var projected []float64
+	switch forecastMethod(len(values)) {
+	case ForecastHoltWinters:
+		projected = holtWinters(values, forecastSeason, horizon)
+	case ForecastSeasonalNaive:
+		projected = seasonalNaive(values, forecastSeason, horizon)
+	case ForecastMean:
+		mean := 0.0
+		for _, v := range values {
+			mean += v
+		}
+		mean /= float64(len(values))
+		projected = make([]float64, horizon)
+		for i := range projected {
+			projected[i] = mean
+		}
+	default:
+		projected = make([]float64, horizon)
+	}
+	for i, v := range projected {
+		projected[i] = max(v, 0)
+	}
+	return projected
+}
+
+// seasonalNaive は直近の1周期の同じ位置の値を予測値にする
+func seasonalNaive(values []float64, season, horizon int) []float64 {
+	projected := make([]float64, horizon)
+	for h := range projected {
+		projected[h] = values[len(values)-season+h%season]
+	}
+	return projected
+}
+
+// holtWinters は加法型の Holt-Winters 法で予測する（values は2周期以上）
+// 傾きは最初の2周期の平均の差、季節性は最初の1周期の傾きに沿った水準との差、水準は最初の1周期の終わりの値から始める
+func holtWinters(values []float64, season, horizon int) []float64 {
+	mean := func(vs []float64) float64 {
+		sum := 0.0
+		for _, v := range vs {
+			sum += v
+		}
+		return sum / float64(len(vs))
+	}
+	firstMean := mean(values[:season])
+	trend := (mean(values[season:2*season]) - firstMean) / float64(season)
+	center := float64(season-1) / 2
+	seasonal := make([]float64, len(values))
+	for i := range season {
+		seasonal[i] = values[i] - (firstMean + (float64(i)-center)*trend)
+	}
+	level := firstMean + center*trend
+	for t := season; t < len(values); t++ {
+		s := seasonal[t-season]
+		prevLevel := level
+		level = holtWintersAlpha*(values[t]-s) + (1-holtWintersAlpha)*(level+trend)
+		trend = holtWintersBeta*(level-prevLevel) + (1-holtWintersBeta)*trend
+		seasonal[t] = holtWintersGamma*(values[t]-level) + (1-holtWintersGamma)*s
+	}
+	projected := make([]float64, horizon)
+	for h := range projected {
+		projected[h] = level + float64(h+1)*trend + seasonal[len(values)-season+h%season]
+	}
+	return projected
+}
+
+// forecastMethodLabel は予測の方法の表示名
+func forecastMethodLabel(method string) string {
+	switch method {
+	case ForecastHoltWinters:
+		return "Holt-Winters法"
+	case ForecastSeasonalNaive:
+		return "前週の同じ曜日"
+	case ForecastMean:
+		return "1日平均"
+	}
+	return "履歴なし"
+}
+
+// forecastTitle は -forecast の見出し
+func forecastTitle(f VisitForecast) string {
+	return fmt.Sprintf("🔮 訪問数の予測 (%s〜%s、過去%d日間から%s)", f.From, f.To, f.HistoryDays, forecastMethodLabel(f.Method))
+}
+
+// forecastChange は直近の訪問数からの増減率の表示（直近が0件なら空）
+func forecastChange(lastWeek int, projected float64) string {
+	if lastWeek == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.0f%%", (projected-float64(lastWeek))/float64(lastWeek)*100)
+}
+
+// forecastDateLabel は予測する日の表示（日付と曜日）
+func forecastDateLabel(date string) string {
+	t, err := time.Parse(TimeFormatDate, date)
+	if err != nil {
+		return date
+	}
+	return date + " " + weekdayLabel(t.Weekday())
+}
+
+// printVisitForecast は訪問数の予測をテキストで表示する
+func printVisitForecast(w io.Writer, f VisitForecast) {
+	_, _ = fmt.Fprintln(w, forecastTitle(f))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	maxVisits := 0.0
+	for _, d := range f.Daily {
+		maxVisits = max(maxVisits, d.Visits)
+	}
+	for _, d := range f.Daily {
+		barLen := 0
+		if maxVisits > 0 {
+			barLen = int(d.Visits / maxVisits * BarChartWidth)
+		}
+		_, _ = fmt.Fprintf(w, "  %s  %s %.1f\n", forecastDateLabel(d.Date), strings.Repeat("░", barLen), d.Visits)
+	}
+	compared := func(lastWeek int, projected float64) string {
+		if change := forecastChange(lastWeek, projected); change != "" {
+			return fmt.Sprintf("%.0f件（直近%d日間 %d件、%s）", projected, ForecastDays, lastWeek, change)
+		}
+		return fmt.Sprintf("%.0f件（直近%d日間 %d件）", projected, ForecastDays, lastWeek)
+	}
+	_, _ = fmt.Fprintf(w, "  合計 %s\n", compared(f.LastWeek, f.Total))
+	if len(f.Domains) > 0 {
+		_, _ = fmt.Fprintf(w, "  ドメイン別:\n")
+		for _, d := range f.Domains {
+			_, _ = fmt.Fprintf(w, "    %s %s\n", padRight(truncateText(d.Domain, 30), 32), compared(d.LastWeek, d.Projected))
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForecastSeries(t *testing.T) {
+	format := func(values []float64) string {
+		var parts []string
+		for _, v := range values {
+			parts = append(parts, fmt.Sprintf("%.1f", v))
+		}
+		return strings.Join(parts, " ")
+	}
+	week := []float64{10, 12, 14, 16, 18, 4, 2}
+	for _, tt := range []struct {
+		name   string
+		values []float64
+		want   string
+	}{
+		{"履歴なし", nil, "0.0 0.0 0.0"},
+		{"1日平均", []float64{1, 2, 6}, "3.0 3.0 3.0"},
+		{"前週の同じ曜日", week, "10.0 12.0 14.0"},
+		// 同じ週が続けば季節性だけで予測する
+		{"Holt-Winters", append(append([]float64{}, week...), week...), "10.0 12.0 14.0"},
+	} {
+		if got := format(forecastSeries(tt.values, 3)); got != tt.want {
+			t.Errorf("%s: forecastSeries(%v) = %s, want %s", tt.name, tt.values, got, tt.want)
+		}
+	}
+
+	// 増え続ける履歴は傾きを引き継ぎ、負の値は0にする
+	var rising, falling []float64
+	for i := range 14 {
+		rising = append(rising, float64(10+i))
+		falling = append(falling, float64(13-i))
+	}
+	if got := forecastSeries(rising, 1)[0]; got <= 23 {
+		t.Errorf("増え続ける履歴の予測 = %.1f, want > 23", got)
+	}
+	for _, v := range forecastSeries(falling, 7) {
+		if v < 0 {
+			t.Errorf("減り続ける履歴の予測 = %.1f, want >= 0", v)
+		}
+	}
+
+	for days, want := range map[int]string{0: "", 3: ForecastMean, 7: ForecastSeasonalNaive, 13: ForecastSeasonalNaive, 14: ForecastHoltWinters} {
+		if got := forecastMethod(days); got != want {
+			t.Errorf("forecastMethod(%d) = %q, want %q", days, got, want)
+		}
+	}
+}
+
+func TestGetVisitForecast(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 履歴は 2025-01-01 から（1/1: 3件、1/2: 2件）の3日間なので1日平均で予測する
+	now := time.Date(2025, 1, 4, 9, 0, 0, 0, time.UTC)
+	f, err := getVisitForecast(db, 2, nil, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getVisitForecast: %v", err)
+	}
+	if f.Method != ForecastMean || f.HistoryDays != 3 || f.From != "2025-01-04" || f.To != "2025-01-10" {
+		t.Errorf("予測 = %s (%d日, %s〜%s)", f.Method, f.HistoryDays, f.From, f.To)
+	}
+	if len(f.Daily) != ForecastDays || f.Daily[0].Date != "2025-01-04" || fmt.Sprintf("%.2f", f.Daily[0].Visits) != "1.67" {
+		t.Errorf("日別の予測 = %+v", f.Daily)
+	}
+	if fmt.Sprintf("%.1f", f.Total) != "11.7" || f.LastWeek != 5 {
+		t.Errorf("合計 = %.1f（直近 %d件）", f.Total, f.LastWeek)
+	}
+	if got := fmt.Sprint(f.Domains); !strings.HasPrefix(got, "[{github.com 2 4.6") || !strings.Contains(got, "{youtube.com 2 4.6") || len(f.Domains) != 2 {
+		t.Errorf("ドメイン別の予測 = %s", got)
+	}
+
+	// 今日の訪問は途中のため使わない
+	f, err = getVisitForecast(db, 2, nil, time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC), SearchFilter{})
+	if err != nil {
+		t.Fatalf("getVisitForecast: %v", err)
+	}
+	if f.Method != "" || f.HistoryDays != 0 || f.Total != 0 || len(f.Domains) != 0 {
+		t.Errorf("履歴なしの予測 = %+v", f)
+	}
+}
+
+func TestPrintVisitForecast(t *testing.T) {
+	f := VisitForecast{
+		Method:      ForecastSeasonalNaive,
+		HistoryDays: 10,
+		From:        "2025-01-06",
+		To:          "2025-01-12",
+		Daily:       []ForecastDay{{Date: "2025-01-06", Visits: 4}, {Date: "2025-01-07", Visits: 2}},
+		Total:       6,
+		LastWeek:    4,
+		Domains:     []DomainForecast{{Domain: "github.com", LastWeek: 0, Projected: 3}},
+	}
+	var buf bytes.Buffer
+	printVisitForecast(&buf, f)
+	out := buf.String()
+	for _, want := range []string{"2025-01-06〜2025-01-12、過去10日間から前週の同じ曜日", "2025-01-06 月", "合計 6件（直近7日間 4件、+50%）", "github.com", "3件（直近7日間 0件）"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q がない:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// formattersFileName は外部コマンドの出力形式を追加する設定ファイル
+// 1行に「名前 [.拡張子...] = コマンド [引数...]」を書く:
+//
+//	org .org = ~/bin/hist-org
+//	latex .tex = hist-latex --booktabs
+//
+// コマンドは標準入力で -json と同じ分析結果を受け取り、標準出力に書いた内容をそのまま出力する
+// 引数はシェルを通さず空白で区切る（先頭の ~/ はホームディレクトリにする）
+const formattersFileName = "formatters.txt"
+
+// outputFormatNameChars は出力形式の名前に使える文字
+const outputFormatNameChars = "abcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// OutputFormatter は分析結果を1つの出力形式で書き出す
+// header は CSV・TSV の見出し行の書き方（見出し行のない形式は無視してよい）
+type OutputFormatter interface {
+	WriteResult(w io.Writer, result AnalysisResult, config Config, header csvHeaderMode) error
+}
+
+// OutputFormatterFunc は関数を OutputFormatter として使う
+type OutputFormatterFunc func(w io.Writer, result AnalysisResult, config Config, header csvHeaderMode) error
+
+// WriteResult は f を呼ぶ
+func (f OutputFormatterFunc) WriteResult(w io.Writer, result AnalysisResult, config Config, header csvHeaderMode) error {
+	return f(w, result, config, header)
+}
+
+// outputFormatters は名前ごとの出力形式（-format・-output の拡張子で選ぶ）
+// 新しい形式は registerOutputFormatter で追加する
+var outputFormatters = map[string]OutputFormatter{
+	OutputFormatText: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, _ csvHeaderMode) error {
+		printTextOutput(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily)
+		return nil
+	}),
+	OutputFormatJSON: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, _ Config, _ csvHeaderMode) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("JSON出力エラー: %w", err)
+		}
+		return nil
+	}),
+	OutputFormatCSV: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, header csvHeaderMode) error {
+		if err := writeCSVSections(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily, ',', header); err != nil {
+			return fmt.Errorf("CSV出力エラー: %w", err)
+		}
+		return nil
+	}),
+	OutputFormatTSV: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, header csvHeaderMode) error {
+		if err := writeCSVSections(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily, '\t', header); err != nil {
+			return fmt.Errorf("TSV出力エラー: %w", err)
+		}
+		return nil
+	}),
+	OutputFormatMarkdown: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, _ csvHeaderMode) error {
+		printMarkdownOutput(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily)
+		return nil
+	}),
+	OutputFormatHTML: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, _ csvHeaderMode) error {
+		if err := printHTMLReport(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily); err != nil {
+			return fmt.Errorf("HTML出力エラー: %w", err)
+		}
+		return nil
+	}),
+	OutputFormatQuiet: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, _ csvHeaderMode) error {
+		printQuietOutput(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily)
+		return nil
+	}),
+	OutputFormatTable: OutputFormatterFunc(func(w io.Writer, result AnalysisResult, config Config, _ csvHeaderMode) error {
+		printTableOutput(w, result, config.ShowHistory, config.ShowDomains, config.ShowPaths, config.ShowHourly, config.ShowDaily, config.TableBorder)
+		return nil
+	}),
+}
+
+// registerOutputFormatter は出力形式 name を追加し、exts（".org" など）の -output をその形式で書き出す
+func registerOutputFormatter(name string, formatter OutputFormatter, exts ...string) error {
+	if name == "" || strings.Trim(name, outputFormatNameChars) != "" {
+		return fmt.Errorf("出力形式の名前に使えるのは英小文字・数字・-・_ です: %s", name)
+	}
+	if _, ok := outputFormatters[name]; ok {
+		return fmt.Errorf("出力形式 %s は既にあります", name)
+	}
+	for _, ext := range exts {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+			return fmt.Errorf("拡張子は .org のように . から書いてください: %s", ext)
+		}
+		if format, ok := outputFormatExtensions[strings.ToLower(ext)]; ok {
+			return fmt.Errorf("拡張子 %s は出力形式 %s で使われています", ext, format)
+		}
+	}
+	outputFormatters[name] = formatter
+	for _, ext := range exts {
+		outputFormatExtensions[strings.ToLower(ext)] = name
+	}
+	return nil
+}
+
+// outputFormatNames は -format に指定できる出力形式の名前を返す
+func outputFormatNames() []string {
+	names := make([]string, 0, len(outputFormatters))
+	for name := range outputFormatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExternalFormatter は分析結果の JSON を標準入力で渡して外部コマンドに書き出させる出力形式
+type ExternalFormatter struct {
+	Name    string
+	Command []string
+}
+
+// WriteResult はコマンドを実行し、標準出力を w に書き出す
+// コマンドには環境変数 HIST_FORMAT で出力形式の名前を渡す
+func (f ExternalFormatter) WriteResult(w io.Writer, result AnalysisResult, _ Config, _ csvHeaderMode) error {
+	var input bytes.Buffer
+	if err := json.NewEncoder(&input).Encode(result); err != nil {
+		return fmt.Errorf("JSON出力エラー: %w", err)
+	}
+	cmd := exec.Command(f.Command[0], f.Command[1:]...)
+	cmd.Stdin = &input
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), HistFormatEnv+"="+f.Name)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("出力形式 %s のコマンドが失敗しました: %w", f.Name, err)
+	}
+	return nil
+}
+
+// parseExternalFormatter は formatters.txt の1行を解析し、出力形式と拡張子を返す
+func parseExternalFormatter(line string) (ExternalFormatter, []string, error) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return ExternalFormatter{}, nil, fmt.Errorf("出力形式は「名前 [.拡張子...] = コマンド」形式で指定してください: %s", line)
+	}
+	fields := strings.Fields(key)
+	command := strings.Fields(value)
+	if len(fields) == 0 || len(command) == 0 {
+		return ExternalFormatter{}, nil, fmt.Errorf("出力形式は「名前 [.拡張子...] = コマンド」形式で指定してください: %s", line)
+	}
+	path, err := expandHomeDir(command[0])
+	if err != nil {
+		return ExternalFormatter{}, nil, err
+	}
+	command[0] = path
+	return ExternalFormatter{Name: fields[0], Command: command}, fields[1:], nil
+}
+
+// loadExternalFormatters は formatters.txt の外部コマンドを出力形式として登録する
+func loadExternalFormatters() error {
+	lines, err := loadDomainListFile(formattersFileName, "出力形式の設定")
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		formatter, exts, err := parseExternalFormatter(line)
+		if err != nil {
+			return err
+		}
+		if err := registerOutputFormatter(formatter.Name, formatter, exts...); err != nil {
+			return fmt.Errorf("%s: %w", formattersFileName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unregisterOutputFormatter はテストで追加した出力形式を取り除く
+func unregisterOutputFormatter(t *testing.T, name string) {
+	t.Helper()
+	t.Cleanup(func() {
+		delete(outputFormatters, name)
+		for ext, format := range outputFormatExtensions {
+			if format == name {
+				delete(outputFormatExtensions, ext)
+			}
+		}
+	})
+}
+
+func TestRegisterOutputFormatter(t *testing.T) {
+	unregisterOutputFormatter(t, "org")
+	org := OutputFormatterFunc(func(w io.Writer, result AnalysisResult, _ Config, _ csvHeaderMode) error {
+		_, err := fmt.Fprintf(w, "* 訪問数 %d\n", result.TotalVisits)
+		return err
+	})
+	if err := registerOutputFormatter("org", org, ".org"); err != nil {
+		t.Fatalf("registerOutputFormatter() error = %v", err)
+	}
+
+	targets, err := resolveOutputTargets([]string{filepath.Join(t.TempDir(), "report.ORG"), filepath.Join(t.TempDir(), "report.json")}, false)
+	if err != nil || len(targets) != 2 || targets[0].Format != "org" {
+		t.Fatalf("resolveOutputTargets() = %v, %v", targets, err)
+	}
+	if err := writeOutputTargets(targets, AnalysisResult{TotalVisits: 3}, Config{}); err != nil {
+		t.Fatalf("writeOutputTargets() error = %v", err)
+	}
+	if data, err := os.ReadFile(targets[0].Path); err != nil || string(data) != "* 訪問数 3\n" {
+		t.Errorf("org の出力 = %q, %v", data, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeResult(&buf, "org", AnalysisResult{TotalVisits: 5}, Config{}, csvHeaderEachSection); err != nil || buf.String() != "* 訪問数 5\n" {
+		t.Errorf("writeResult(org) = %q, %v", buf.String(), err)
+	}
+	if names := outputFormatNames(); !strings.Contains(strings.Join(names, ","), "org") {
+		t.Errorf("outputFormatNames() = %v", names)
+	}
+
+	tests := []struct {
+		name string
+		exts []string
+	}{
+		{"org", nil},
+		{"json", nil},
+		{"Org", nil},
+		{"", nil},
+		{"latex", []string{".json"}},
+		{"latex", []string{"tex"}},
+	}
+	for _, tt := range tests {
+		if err := registerOutputFormatter(tt.name, org, tt.exts...); err == nil {
+			unregisterOutputFormatter(t, tt.name)
+			t.Errorf("registerOutputFormatter(%q, %v) がエラーにならない", tt.name, tt.exts)
+		}
+	}
+	if _, ok := outputFormatters["latex"]; ok {
+		t.Error("拡張子が不正な出力形式が登録された")
+	}
+}
+
+func TestParseExternalFormatter(t *testing.T) {
+	t.Setenv("HOME", "/home/hist")
+	f, exts, err := parseExternalFormatter("latex .tex .latex = ~/bin/hist-latex --booktabs")
+	if err != nil {
+		t.Fatalf("parseExternalFormatter() error = %v", err)
+	}
+	if f.Name != "latex" || strings.Join(f.Command, " ") != "/home/hist/bin/hist-latex --booktabs" || strings.Join(exts, " ") != ".tex .latex" {
+		t.Errorf("parseExternalFormatter() = %+v, %v", f, exts)
+	}
+	for _, line := range []string{"latex hist-latex", "= hist-latex", "latex ="} {
+		if _, _, err := parseExternalFormatter(line); err == nil {
+			t.Errorf("parseExternalFormatter(%q) がエラーにならない", line)
+		}
+	}
+}
+
+func TestExternalFormatter(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	script := filepath.Join(dir, "hist-org")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"format=$"+HistFormatEnv+" arg=$1\"\ncat\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	configDir, err := getConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(configDir, configDirPerms); err != nil {
+		t.Fatal(err)
+	}
+	config := "# org-mode\norg .org = " + script + " --level=2\nfail = " + filepath.Join(dir, "missing") + "\n"
+	if err := os.WriteFile(filepath.Join(configDir, formattersFileName), []byte(config), configFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	unregisterOutputFormatter(t, "org")
+	unregisterOutputFormatter(t, "fail")
+	if err := loadExternalFormatters(); err != nil {
+		t.Fatalf("loadExternalFormatters() error = %v", err)
+	}
+	if format, ok := outputFormatFromPath("report.org.gz"); !ok || format != "org" {
+		t.Errorf("outputFormatFromPath(report.org.gz) = %q, %v", format, ok)
+	}
+
+	var buf bytes.Buffer
+	if err := writeResult(&buf, "org", AnalysisResult{TotalVisits: 7}, Config{}, csvHeaderEachSection); err != nil {
+		t.Fatalf("writeResult(org) error = %v", err)
+	}
+	if out := buf.String(); !strings.HasPrefix(out, "format=org arg=--level=2\n") || !strings.Contains(out, `"total_visits":7`) {
+		t.Errorf("外部コマンドの出力 = %q", out)
+	}
+	if err := writeResult(io.Discard, "fail", AnalysisResult{}, Config{}, csvHeaderEachSection); err == nil {
+		t.Error("存在しないコマンドの出力形式がエラーにならない")
+	}
+
+	// 同じ名前をもう一度読み込むとエラー
+	if err := loadExternalFormatters(); err == nil {
+		t.Error("重複した出力形式がエラーにならない")
+	}
+}
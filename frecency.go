@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// RankMode は最近の履歴・ピッカーの並び順（-rank）
+type RankMode int
+
+const (
+	// RankRecent は訪問の新しい順（既定）
+	RankRecent RankMode = iota
+	// RankFrecency は訪問の多さと新しさを合わせたスコア順（ブラウザのアドレスバーと同じ考え方）
+	RankFrecency
+)
+
+// String はflag.Valueインターフェースの実装
+func (m *RankMode) String() string {
+	if m != nil && *m == RankFrecency {
+		return "frecency"
+	}
+	return "recent"
+}
+
+// Set はflag.Valueインターフェースの実装
+func (m *RankMode) Set(value string) error {
+	switch value {
+	case "recent":
+		*m = RankRecent
+	case "frecency":
+		*m = RankFrecency
+	default:
+		return fmt.Errorf("不正な並び順: %s（recent, frecency のいずれか）", value)
+	}
+	return nil
+}
+
+// frecencyBuckets は訪問の古さごとの重み（Firefox の frecency と同じ区切り）
+var frecencyBuckets = []struct {
+	within time.Duration
+	weight float64
+}{
+	{4 * 24 * time.Hour, 100},
+	{14 * 24 * time.Hour, 70},
+	{31 * 24 * time.Hour, 50},
+	{90 * 24 * time.Hour, 30},
+}
+
+const (
+	// frecencyOldWeight は frecencyBuckets より古い訪問の重み
+	frecencyOldWeight = 10
+	// frecencySampleSize はスコアの計算に使う、URLごとの新しい訪問の数
+	frecencySampleSize = 10
+)
+
+// frecencyWeight は now から見た訪問の重みを返す
+func frecencyWeight(visitTime, now time.Time) float64 {
+	age := now.Sub(visitTime)
+	for _, b := range frecencyBuckets {
+		if age < b.within {
+			return b.weight
+		}
+	}
+	return frecencyOldWeight
+}
+
+// frecencyEntry はURLごとに集計中の frecency
+type frecencyEntry struct {
+	// latest はURLの最新の訪問
+	latest HistoryVisit
+	count  int
+	// weights は新しい順に最大 frecencySampleSize 件の訪問の重みの合計
+	weights float64
+	sampled int
+}
+
+// score は訪問数に、新しい訪問の重みの平均を掛けたスコアを返す
+func (e *frecencyEntry) score() float64 {
+	if e.sampled == 0 {
+		return 0
+	}
+	return float64(e.count) * e.weights / float64(e.sampled)
+}
+
+// getFrecencyVisits はフィルタに一致する訪問をURLごとにまとめ、frecency の高い順に最新の訪問を最大 limit 件返す
+// fuzzyQuery を指定すると、filter.Keyword の代わりにあいまい一致する訪問だけを数える
+// スコアが同じ場合は最後の訪問が新しいURLを先にする
+func getFrecencyVisits(db dbQuerier, limit int, filter SearchFilter, fuzzyQuery string, now time.Time) ([]HistoryVisit, error) {
+	if fuzzyQuery != "" {
+		filter.Keyword = ""
+	}
+	index := make(map[string]int)
+	var entries []*frecencyEntry
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		if fuzzyQuery != "" {
+			if _, ok := fuzzyMatchVisit(fuzzyQuery, v); !ok {
+				return nil
+			}
+		}
+		i, ok := index[v.URL]
+		if !ok {
+			i = len(entries)
+			index[v.URL] = i
+			entries = append(entries, &frecencyEntry{latest: v})
+		}
+		e := entries[i]
+		e.count++
+		// 訪問は新しい順に届くので、最初の frecencySampleSize 件が新しい訪問
+		if e.sampled < frecencySampleSize {
+			e.weights += frecencyWeight(v.VisitTime, now)
+			e.sampled++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("frecency の集計に失敗: %w", err)
+	}
+
+	// URLは最後の訪問が新しい順に並んでいるので、安定ソートで同点の並びを保つ
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].score() > entries[j].score()
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	visits := make([]HistoryVisit, len(entries))
+	for i, e := range entries {
+		visits[i] = e.latest
+	}
+	return visits, nil
+}
+
+// writeFrecencyPickerLines は writePickerLines と同じ形式で、URLを frecency の高い順に出力する
+// 全ての訪問を集計してから出力するため、逐次出力にはならない
+func writeFrecencyPickerLines(w io.Writer, db dbQuerier, limit int, filter SearchFilter, redactDomains []string, now time.Time) (int, error) {
+	visits, err := getFrecencyVisits(db, 0, filter, "", now)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, v := range visits {
+		if !isOpenableURL(v.URL) || isRedactedVisit(v, redactDomains) {
+			continue
+		}
+		if err := writePickerLine(w, v); err != nil {
+			return count, err
+		}
+		count++
+		if limit > 0 && count >= limit {
+			break
+		}
+	}
+	return count, nil
+}
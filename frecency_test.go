@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRankModeSet(t *testing.T) {
+	var m RankMode
+	if err := m.Set("frecency"); err != nil || m != RankFrecency || m.String() != "frecency" {
+		t.Errorf("Set(frecency) = %v, %v", m, err)
+	}
+	if err := m.Set("recent"); err != nil || m != RankRecent {
+		t.Errorf("Set(recent) = %v, %v", m, err)
+	}
+	if err := m.Set("popular"); err == nil {
+		t.Error("不正な値でエラーにならない")
+	}
+}
+
+func TestFrecencyWeight(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  time.Duration
+		want float64
+	}{
+		{time.Hour, 100},
+		{5 * 24 * time.Hour, 70},
+		{20 * 24 * time.Hour, 50},
+		{60 * 24 * time.Hour, 30},
+		{365 * 24 * time.Hour, 10},
+		// -now より後の訪問は最も新しい扱い
+		{-time.Hour, 100},
+	}
+	for _, tt := range tests {
+		if got := frecencyWeight(now.Add(-tt.age), now); got != tt.want {
+			t.Errorf("frecencyWeight(%v前) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+}
+
+// insertFrecencyTestData は example.com に20日前の訪問を30件追加する
+// 最後の訪問は古いが、訪問が多いため frecency では先頭になる
+func insertFrecencyTestData(t *testing.T, db *sql.DB) {
+	t.Helper()
+	old := convertToTimestamp(time.Date(2024, 12, 14, 9, 0, 0, 0, time.UTC))
+	for i := range 30 {
+		if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (?, 4, ?, 'Example')`,
+			100+i, old+float64(i)); err != nil {
+			t.Fatalf("テストデータ挿入に失敗: %v", err)
+		}
+	}
+}
+
+func TestGetFrecencyVisits(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	insertFrecencyTestData(t, db)
+	now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	visits, err := getFrecencyVisits(db, 0, SearchFilter{}, "", now)
+	if err != nil {
+		t.Fatalf("getFrecencyVisits失敗: %v", err)
+	}
+	// example.com: 30×50、youtube・github: 2×100（最後の訪問が新しい youtube が先）、google: 1×100
+	var urls []string
+	for _, v := range visits {
+		urls = append(urls, v.URL)
+	}
+	want := "https://example.com https://youtube.com/watch https://github.com/test https://google.com/search"
+	if got := strings.Join(urls, " "); got != want {
+		t.Errorf("並び = %s, want %s", got, want)
+	}
+	if visits[1].Title != "YouTube - Music" {
+		t.Errorf("URLの最新の訪問ではない: %+v", visits[1])
+	}
+
+	visits, err = getFrecencyVisits(db, 2, SearchFilter{Keyword: "github"}, "", now)
+	if err != nil || len(visits) != 1 || visits[0].URL != "https://github.com/test" {
+		t.Errorf("キーワードで絞り込んだ結果 = %+v, %v", visits, err)
+	}
+
+	// あいまい検索では filter.Keyword の代わりに fuzzyQuery で絞り込む
+	visits, err = getFrecencyVisits(db, 0, SearchFilter{Keyword: "ytmsc"}, "ytmsc", now)
+	if err != nil || len(visits) != 1 || visits[0].URL != "https://youtube.com/watch" {
+		t.Errorf("あいまい検索の結果 = %+v, %v", visits, err)
+	}
+}
+
+func TestWriteFrecencyPickerLines(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	insertFrecencyTestData(t, db)
+	now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	count, err := writeFrecencyPickerLines(&buf, db, 2, SearchFilter{}, []string{"example.com"}, now)
+	if err != nil {
+		t.Fatalf("writeFrecencyPickerLines失敗: %v", err)
+	}
+	// リダクト対象（example.com）は出さずに limit まで出力する
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if count != 2 || len(lines) != 2 || !strings.HasPrefix(lines[0], "https://youtube.com/watch\t") || !strings.HasPrefix(lines[1], "https://github.com/test\t") {
+		t.Errorf("出力 = %d件 %q", count, lines)
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fsckIntegrityLimit は PRAGMA integrity_check で報告させる問題の上限
+const fsckIntegrityLimit = 100
+
+// fsckMaxSkip は読めない行を飛ばすときに rowid を進める幅の上限
+// 幅を倍にしながら読める行を探し、これを超えたらテーブルの残りは読めないとみなす
+const fsckMaxSkip = 1 << 20
+
+// FsckTable は復旧したテーブル1つの結果
+type FsckTable struct {
+	Name string `json:"name"`
+	// Rows は復旧した行の数
+	Rows int `json:"rows"`
+	// Unreadable は元のDBで数えた行のうち読めなかった行の数（数えられなかった場合は -1）
+	Unreadable int `json:"unreadable"`
+	// Errors は読み取りに失敗した回数（失敗のたびに rowid を飛ばして読み直す）
+	Errors int    `json:"errors"`
+	Error  string `json:"error,omitempty"`
+}
+
+// FsckResult は hist fsck で確かめたDB1つの結果
+type FsckResult struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Skipped はDBがなく確かめなかった理由
+	Skipped string `json:"skipped,omitempty"`
+	// Problems は PRAGMA integrity_check が報告した問題（空なら正常）
+	Problems []string `json:"problems"`
+	// RecoveredPath は読める行を書き出した新しいファイル（復旧しなかった場合は空）
+	RecoveredPath string      `json:"recovered_path,omitempty"`
+	Tables        []FsckTable `json:"tables,omitempty"`
+	// Warnings は復旧したファイルに作れなかった索引・ビュー・トリガー
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// OK はDBに問題がなかったかを返す
+func (r FsckResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Unreadable は読めなかった行の合計を返す（数えられなかったテーブルは含まない）
+func (r FsckResult) Unreadable() int {
+	total := 0
+	for _, t := range r.Tables {
+		if t.Unreadable > 0 {
+			total += t.Unreadable
+		}
+	}
+	return total
+}
+
+// checkDatabase は PRAGMA integrity_check で db を確かめ、問題の一覧を返す
+// 開けない・読めないDBもその理由を問題として返す
+func checkDatabase(db *sql.DB) []string {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA integrity_check(%d)`, fsckIntegrityLimit))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer func() { _ = rows.Close() }()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return append(problems, err.Error())
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// fsckSchemaEntry は sqlite_master の1行
+type fsckSchemaEntry struct {
+	kind, name, sql string
+}
+
+// recoverDatabase は src の読める行を dst の新しいDBに書き出す（sqlite3 の .recover の簡易版）
+// テーブルは rowid の順に読み、読み取りに失敗したら rowid を飛ばして読み直す
+// 仮想テーブル（全文検索など）と sqlite_ で始まる内部テーブルは書き出さない
+func recoverDatabase(src *sql.DB, dst string) ([]FsckTable, []string, error) {
+	var schema []fsckSchemaEntry
+	rows, err := src.Query(`SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL ORDER BY rowid`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("スキーマを読めませんでした: %w", err)
+	}
+	for rows.Next() {
+		var e fsckSchemaEntry
+		if err := rows.Scan(&e.kind, &e.name, &e.sql); err != nil {
+			_ = rows.Close()
+			return nil, nil, fmt.Errorf("スキーマを読めませんでした: %w", err)
+		}
+		schema = append(schema, e)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("スキーマを読めませんでした: %w", err)
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		return nil, nil, fmt.Errorf("復旧先のファイルが既にあります: %s", dst)
+	}
+	// 履歴を含むため本人だけが読めるファイルにする
+	if err := os.WriteFile(dst, nil, 0o600); err != nil {
+		return nil, nil, fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	out, err := sql.Open(SQLiteDriver, dst)
+	if err != nil {
+		return nil, nil, fmt.Errorf("復旧先のDBを作れませんでした: %w", err)
+	}
+	defer func() { _ = out.Close() }()
+	out.SetMaxOpenConns(1)
+
+	var virtual []string
+	isSkipped := func(e fsckSchemaEntry) bool {
+		if strings.HasPrefix(e.name, "sqlite_") {
+			return true
+		}
+		for _, v := range virtual {
+			if strings.HasPrefix(e.name, v+"_") {
+				return true
+			}
+		}
+		return false
+	}
+	var tables []FsckTable
+	var warnings []string
+	for _, e := range schema {
+		if e.kind != "table" || isSkipped(e) {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(e.sql), "CREATE VIRTUAL TABLE") {
+			virtual = append(virtual, e.name)
+			warnings = append(warnings, fmt.Sprintf("仮想テーブル %s は復旧しません（索引は作り直してください）", e.name))
+			continue
+		}
+		if _, err := out.Exec(e.sql); err != nil {
+			tables = append(tables, FsckTable{Name: e.name, Unreadable: -1, Error: fmt.Sprintf("テーブルを作れませんでした: %v", err)})
+			continue
+		}
+		tables = append(tables, recoverTable(src, out, e.name))
+	}
+	// 索引・ビュー・トリガーは行を書き出した後に作る（重複した行で一意索引が作れなければ警告にする）
+	for _, e := range schema {
+		if e.kind == "table" || isSkipped(e) {
+			continue
+		}
+		if _, err := out.Exec(e.sql); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %s を作れませんでした: %v", e.kind, e.name, err))
+		}
+	}
+	return tables, warnings, nil
+}
+
+// recoverTable は src のテーブル name の読める行を out の同じテーブルに書き出す
+func recoverTable(src, out *sql.DB, name string) FsckTable {
+	result := FsckTable{Name: name, Unreadable: -1}
+	table := quoteIdentifier(name)
+	columns, err := tableColumns(src, name)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdentifier(c)
+	}
+	list := strings.Join(quoted, ", ")
+	insert := fmt.Sprintf(`INSERT OR IGNORE INTO %s (%s) VALUES (%s)`, table, list, strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+
+	tx, err := out.Begin()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer func() { _ = stmt.Close() }()
+
+	// copyRows は query の行を書き出し、最後に読めた rowid を返す（withRowid でなければ rowid は読まない）
+	copyRows := func(query string, withRowid bool, args ...any) (int64, bool, error) {
+		rows, err := src.Query(query, args...)
+		if err != nil {
+			return 0, false, err
+		}
+		defer func() { _ = rows.Close() }()
+		var last int64
+		read := false
+		values := make([]any, len(columns))
+		dest := make([]any, 0, len(columns)+1)
+		var rowid int64
+		if withRowid {
+			dest = append(dest, &rowid)
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				return last, read, err
+			}
+			if _, err := stmt.Exec(values...); err != nil {
+				return last, read, err
+			}
+			result.Rows++
+			last, read = rowid, true
+		}
+		return last, read, rows.Err()
+	}
+
+	if withoutRowid, _ := isWithoutRowidTable(src, name); withoutRowid {
+		// rowid がないテーブルは飛ばして読み直せないため、読めたところまでにする
+		if _, _, err := copyRows(fmt.Sprintf(`SELECT %s FROM %s`, list, table), false); err != nil {
+			result.Errors++
+			result.Error = err.Error()
+		}
+	} else {
+		query := fmt.Sprintf(`SELECT rowid, %s FROM %s WHERE rowid > ? ORDER BY rowid`, list, table)
+		cursor, skip := int64(math.MinInt64), int64(1)
+		for {
+			last, read, err := copyRows(query, true, cursor)
+			if err == nil {
+				break
+			}
+			result.Errors++
+			result.Error = err.Error()
+			if read {
+				cursor, skip = last, 1
+			}
+			// 読めない行を飛ばす（続けて失敗したら幅を倍にする）
+			if skip > fsckMaxSkip || cursor > math.MaxInt64-skip {
+				break
+			}
+			cursor += skip
+			skip *= 2
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// 件数は索引から数えられることが多く、テーブル本体が壊れていても読めなかった行の数がわかる
+	var count int
+	if err := src.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s`, table)).Scan(&count); err == nil {
+		result.Unreadable = max(count-result.Rows, 0)
+	}
+	return result
+}
+
+// tableColumns はテーブルの列名を返す
+func tableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, quoteIdentifier(table)))
+	if err != nil {
+		return nil, fmt.Errorf("列を読めませんでした: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return nil, fmt.Errorf("列を読めませんでした: %w", err)
+		}
+		columns = append(columns, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("列を読めませんでした: %w", err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("列を読めませんでした: %s", table)
+	}
+	return columns, nil
+}
+
+// isWithoutRowidTable はテーブルが WITHOUT ROWID で作られているかを返す
+func isWithoutRowidTable(db *sql.DB, table string) (bool, error) {
+	var wr int
+	err := db.QueryRow(`SELECT wr FROM pragma_table_list WHERE name = ?`, table).Scan(&wr)
+	return wr == 1, err
+}
+
+// quoteIdentifier は SQLite の識別子をダブルクォートで囲む
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// fsckDatabase は path のDBを確かめ、問題があり recoverDir が空でなければ読める行を新しいファイルに書き出す
+// 暗号化したDBは同じ鍵で暗号化したファイルに書き出す
+func fsckDatabase(name, path, recoverDir string, now time.Time) FsckResult {
+	result := FsckResult{Name: name, Path: path}
+	db, err := openDB(path)
+	if err != nil {
+		result.Problems = []string{err.Error()}
+		return result
+	}
+	defer func() { _ = db.Close() }()
+	result.Problems = checkDatabase(db)
+	if result.OK() || recoverDir == "" {
+		return result
+	}
+
+	encrypted, base := splitEncryptionExt(filepath.Base(path))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	dst := filepath.Join(recoverDir, fmt.Sprintf("%s-recovered-%s.db", base, now.Format(backupTimeFormat)))
+	if err := os.MkdirAll(recoverDir, 0o700); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("復旧先の作成に失敗: %v", err))
+		return result
+	}
+	plain := dst
+	if encrypted {
+		plain = dst + ".tmp"
+	}
+	result.Tables, result.Warnings, err = recoverDatabase(db, plain)
+	if err != nil {
+		_ = os.Remove(plain)
+		result.Warnings = append(result.Warnings, err.Error())
+		return result
+	}
+	if encrypted {
+		defer func() { _ = os.Remove(plain) }()
+		key, err := loadEncryptionKey()
+		if err == nil {
+			err = encryptFile(plain, withEncryptionExt(dst), key)
+		}
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("復旧したファイルの暗号化に失敗: %v", err))
+			return result
+		}
+		dst = withEncryptionExt(dst)
+	}
+	result.RecoveredPath = dst
+	return result
+}
+
+// printFsckResults は hist fsck の結果を出力する
+func printFsckResults(w io.Writer, results []FsckResult) {
+	for _, r := range results {
+		switch {
+		case r.Skipped != "":
+			_, _ = fmt.Fprintf(w, "➖ %s: %s\n", r.Name, r.Skipped)
+			continue
+		case r.OK():
+			_, _ = fmt.Fprintf(w, "✅ %s: 問題ありません（%s）\n", r.Name, r.Path)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "❌ %s: %d件の問題があります（%s）\n", r.Name, len(r.Problems), r.Path)
+		for _, p := range r.Problems {
+			_, _ = fmt.Fprintf(w, "    %s\n", p)
+		}
+		for _, t := range r.Tables {
+			unreadable := "不明"
+			if t.Unreadable >= 0 {
+				unreadable = fmt.Sprintf("%d行", t.Unreadable)
+			}
+			_, _ = fmt.Fprintf(w, "  %-24s 復旧 %d行・読めなかった行 %s", t.Name, t.Rows, unreadable)
+			if t.Errors > 0 {
+				_, _ = fmt.Fprintf(w, "（読み取りエラー %d回）", t.Errors)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		for _, warning := range r.Warnings {
+			_, _ = fmt.Fprintf(w, "  ⚠️  %s\n", warning)
+		}
+		if r.RecoveredPath != "" {
+			_, _ = fmt.Fprintf(w, "  読める行を書き出しました: %s（読めなかった行 %d行）\n", r.RecoveredPath, r.Unreadable())
+		}
+	}
+}
+
+// runFsckCommand は hist fsck サブコマンドを実行する
+func runFsckCommand(args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	dest := fs.String("dest", ".", "壊れたDBの読める行を書き出すディレクトリ")
+	noRecover := fs.Bool("no-recover", false, "確かめるだけで、読める行を書き出さない")
+	keyFile := fs.String("key-file", "", "暗号化したアーカイブDBの鍵ファイル（環境変数 "+HistKeyFileEnv+" と同じ）")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist fsck [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  Safari の履歴DBとアーカイブDBを PRAGMA integrity_check で確かめ、\n")
+		fmt.Fprintf(fs.Output(), "  壊れていれば読める行を新しいファイルに書き出します（元のファイルは変更しません）\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := setKeyFileEnv(*keyFile); err != nil {
+		return err
+	}
+	recoverDir := *dest
+	if *noRecover {
+		recoverDir = ""
+	}
+
+	now := time.Now()
+	var results []FsckResult
+	src, err := getDBPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); err != nil {
+		results = append(results, FsckResult{Name: "履歴DB", Path: src, Skipped: "履歴DBが見つかりません"})
+	} else {
+		results = append(results, fsckDatabase("履歴DB", src, recoverDir, now))
+	}
+	archivePath, err := getArchivePath()
+	if err != nil {
+		return err
+	}
+	if path := existingArchivePath(archivePath); path == "" {
+		results = append(results, FsckResult{Name: "アーカイブDB", Path: archivePath, Skipped: "アーカイブDBはありません"})
+	} else {
+		results = append(results, fsckDatabase("アーカイブDB", path, recoverDir, now))
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printFsckResults(os.Stdout, results)
+	}
+	for _, r := range results {
+		if !r.OK() {
+			return errors.New("壊れたDBがあります")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createCorruptDB は visits テーブルの途中のページを壊したDBを作り、元の行数を返す
+// 索引は行を入れた後に作るため、索引のページはファイルの末尾にまとまり壊れない
+func createCorruptDB(t *testing.T, path string) int {
+	t.Helper()
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const rows = 2000
+	if _, err := db.Exec(`PRAGMA page_size = 4096; CREATE TABLE visits (id INTEGER PRIMARY KEY, url TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= rows; i++ {
+		url := fmt.Sprintf("https://example.com/%04d/%s", i, strings.Repeat("x", 150))
+		if _, err := tx.Exec(`INSERT INTO visits (id, url) VALUES (?, ?)`, i, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE INDEX idx_visits_id ON visits (id); CREATE VIEW recent AS SELECT * FROM visits WHERE id > 1900`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xff}, 4096), 30*4096); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestFsckDatabaseRecovers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "History.db")
+	rows := createCorruptDB(t, path)
+
+	now := time.Date(2025, 1, 3, 9, 0, 0, 0, time.Local)
+	result := fsckDatabase("履歴DB", path, filepath.Join(dir, "recovered"), now)
+	if result.OK() {
+		t.Fatal("壊れたDBの fsckDatabase() に問題がない")
+	}
+	wantPath := filepath.Join(dir, "recovered", "History-recovered-20250103-090000.db")
+	if result.RecoveredPath != wantPath || len(result.Tables) != 1 {
+		t.Fatalf("fsckDatabase() = %+v", result)
+	}
+	table := result.Tables[0]
+	if table.Errors == 0 || table.Rows == 0 || table.Unreadable <= 0 || table.Rows+table.Unreadable != rows {
+		t.Errorf("visits = %+v, want 復旧した行と読めなかった行の合計が %d", table, rows)
+	}
+	if result.Unreadable() != table.Unreadable {
+		t.Errorf("Unreadable() = %d, want %d", result.Unreadable(), table.Unreadable)
+	}
+
+	recovered, err := openDB(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = recovered.Close() }()
+	if problems := checkDatabase(recovered); len(problems) != 0 {
+		t.Errorf("復旧したDBの問題 = %v", problems)
+	}
+	var count, last int
+	if err := recovered.QueryRow(`SELECT COUNT(*), MAX(id) FROM recent`).Scan(&count, &last); err != nil || last != rows {
+		t.Errorf("復旧したDBのビュー = %d件・最後のID %d, %v, want 壊れたページより後の行も読める", count, last, err)
+	}
+	var buf bytes.Buffer
+	printFsckResults(&buf, []FsckResult{result})
+	if out := buf.String(); !strings.Contains(out, "読める行を書き出しました") || !strings.Contains(out, "visits") {
+		t.Errorf("printFsckResults() = %q", out)
+	}
+
+	// 同じ時刻に書き出したファイルは上書きしない
+	again := fsckDatabase("履歴DB", path, filepath.Join(dir, "recovered"), now)
+	if again.RecoveredPath != "" || len(again.Warnings) == 0 {
+		t.Errorf("2回目の fsckDatabase() = %+v, want 警告", again)
+	}
+}
+
+func TestFsckDatabaseHealthy(t *testing.T) {
+	src, db := createTestHistoryFile(t)
+	defer func() { _ = db.Close() }()
+	dir := t.TempDir()
+	result := fsckDatabase("履歴DB", src, dir, time.Now())
+	if !result.OK() || result.RecoveredPath != "" {
+		t.Errorf("正常なDBの fsckDatabase() = %+v", result)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("正常なDBで書き出した: %v", entries)
+	}
+
+	// DBではないファイルは問題として報告する
+	notDB := filepath.Join(dir, "History.db")
+	if err := os.WriteFile(notDB, []byte(strings.Repeat("not a database ", 512)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if result := fsckDatabase("履歴DB", notDB, "", time.Now()); result.OK() {
+		t.Error("DBではないファイルの fsckDatabase() に問題がない")
+	}
+}
+
+func TestFsckDatabaseEncrypted(t *testing.T) {
+	t.Setenv(HistPassphraseEnv, "correct horse battery staple")
+	t.Setenv(HistKeyFileEnv, "")
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.db")
+	rows := createCorruptDB(t, plain)
+	key, err := loadEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, archiveFileName+encryptionExt)
+	if err := encryptFile(plain, path, key); err != nil {
+		t.Fatal(err)
+	}
+
+	result := fsckDatabase("アーカイブDB", path, dir, time.Date(2025, 1, 3, 9, 0, 0, 0, time.Local))
+	if result.OK() || !strings.HasSuffix(result.RecoveredPath, "archive-recovered-20250103-090000.db"+encryptionExt) {
+		t.Fatalf("暗号化したDBの fsckDatabase() = %+v", result)
+	}
+	if encrypted, err := isEncryptedFile(result.RecoveredPath); err != nil || !encrypted {
+		t.Errorf("復旧したファイルが暗号化されていない: %v", err)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(result.RecoveredPath, encryptionExt) + ".tmp"); !os.IsNotExist(err) {
+		t.Error("復号した一時ファイルが残っている")
+	}
+	if n := result.Tables[0].Rows + result.Tables[0].Unreadable; n != rows {
+		t.Errorf("復旧した行と読めなかった行の合計 = %d, want %d", n, rows)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// あいまい検索のスコア（fzf の v1 アルゴリズムと同じ配点）
+// 連続した一致や単語の先頭での一致ほど高く、一致の間の隙間は減点する
+const (
+	fuzzyScoreMatch        = 16
+	fuzzyScoreGapStart     = -3
+	fuzzyScoreGapExtension = -1
+
+	// fuzzyBonusBoundary は区切り文字（/ や空白など）の直後での一致
+	fuzzyBonusBoundary = fuzzyScoreMatch / 2
+	// fuzzyBonusNonWord は区切り文字そのものとの一致
+	fuzzyBonusNonWord = fuzzyScoreMatch / 2
+	// fuzzyBonusCamel123 は camelCase の大文字や英字の後の数字での一致
+	fuzzyBonusCamel123 = fuzzyBonusBoundary + fuzzyScoreGapExtension
+	// fuzzyBonusConsecutive は連続した一致（隙間の減点を打ち消す）
+	fuzzyBonusConsecutive = -(fuzzyScoreGapStart + fuzzyScoreGapExtension)
+	// fuzzyBonusFirstCharMultiplier はパターンの1文字目のボーナスにかける倍率
+	fuzzyBonusFirstCharMultiplier = 2
+)
+
+// fuzzyCharClass は一致のボーナスを決める文字の種類
+type fuzzyCharClass int
+
+const (
+	fuzzyCharNonWord fuzzyCharClass = iota
+	fuzzyCharLower
+	fuzzyCharUpper
+	fuzzyCharLetter
+	fuzzyCharNumber
+)
+
+func fuzzyCharClassOf(r rune) fuzzyCharClass {
+	switch {
+	case unicode.IsLower(r):
+		return fuzzyCharLower
+	case unicode.IsUpper(r):
+		return fuzzyCharUpper
+	case unicode.IsNumber(r):
+		return fuzzyCharNumber
+	case unicode.IsLetter(r):
+		return fuzzyCharLetter
+	}
+	return fuzzyCharNonWord
+}
+
+// fuzzyBonus は直前の文字の種類から、その位置で一致したときのボーナスを返す
+func fuzzyBonus(prev, class fuzzyCharClass) int {
+	switch {
+	case prev == fuzzyCharNonWord && class != fuzzyCharNonWord:
+		return fuzzyBonusBoundary
+	case prev == fuzzyCharLower && class == fuzzyCharUpper,
+		prev != fuzzyCharNumber && class == fuzzyCharNumber:
+		return fuzzyBonusCamel123
+	case class == fuzzyCharNonWord:
+		return fuzzyBonusNonWord
+	}
+	return 0
+}
+
+// fuzzyMatch はパターンの文字が順に text に現れるかを調べ、一致のスコアを返す
+// パターンに大文字を含む場合だけ大文字・小文字を区別する（fzf のスマートケース）
+// 前から最初に全ての文字が揃う位置を探し、そこから後ろ向きに最も短い範囲を選んでスコアを付ける
+func fuzzyMatch(pattern, text string) (int, bool) {
+	p := []rune(pattern)
+	if len(p) == 0 {
+		return 0, true
+	}
+	caseSensitive := strings.ToLower(pattern) != pattern
+	t := []rune(text)
+	fold := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+
+	// 前向き: パターンの最後の文字が一致する位置
+	pidx, end := 0, -1
+	for i, r := range t {
+		if fold(r) == p[pidx] {
+			pidx++
+			if pidx == len(p) {
+				end = i + 1
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, false
+	}
+
+	// 後ろ向き: 同じ終わりで最も短い範囲の始まり
+	start := 0
+	pidx = len(p) - 1
+	for i := end - 1; i >= 0; i-- {
+		if fold(t[i]) == p[pidx] {
+			pidx--
+			if pidx < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	score, consecutive, firstBonus := 0, 0, 0
+	inGap := false
+	prevClass := fuzzyCharNonWord
+	if start > 0 {
+		prevClass = fuzzyCharClassOf(t[start-1])
+	}
+	pidx = 0
+	for i := start; i < end; i++ {
+		class := fuzzyCharClassOf(t[i])
+		if pidx < len(p) && fold(t[i]) == p[pidx] {
+			score += fuzzyScoreMatch
+			bonus := fuzzyBonus(prevClass, class)
+			if consecutive == 0 {
+				firstBonus = bonus
+			} else {
+				// 連続した一致では、その塊の先頭のボーナスを引き継ぐ
+				if bonus >= fuzzyBonusBoundary && bonus > firstBonus {
+					firstBonus = bonus
+				}
+				bonus = max(bonus, firstBonus, fuzzyBonusConsecutive)
+			}
+			if pidx == 0 {
+				score += bonus * fuzzyBonusFirstCharMultiplier
+			} else {
+				score += bonus
+			}
+			inGap = false
+			consecutive++
+			pidx++
+		} else {
+			if inGap {
+				score += fuzzyScoreGapExtension
+			} else {
+				score += fuzzyScoreGapStart
+			}
+			inGap = true
+			consecutive = 0
+			firstBonus = 0
+		}
+		prevClass = class
+	}
+	return score, true
+}
+
+// fuzzyMatchVisit は訪問のタイトルとURLに対するあいまい検索のスコアを返す
+// 空白で区切った語は全て一致する必要があり（AND）、語ごとにタイトルとURLの高い方のスコアを足す
+// 全角・半角とアクセントの違いはならして比べる（foldSearchText）
+func fuzzyMatchVisit(query string, v HistoryVisit) (int, bool) {
+	terms := strings.Fields(foldSearchText(query))
+	title, rawURL := foldSearchText(v.Title), foldSearchText(v.URL)
+	total := 0
+	for _, term := range terms {
+		titleScore, titleOK := fuzzyMatch(term, title)
+		urlScore, urlOK := fuzzyMatch(term, rawURL)
+		switch {
+		case titleOK && urlOK:
+			total += max(titleScore, urlScore)
+		case titleOK:
+			total += titleScore
+		case urlOK:
+			total += urlScore
+		default:
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+// getFuzzyVisits は query にあいまい一致する訪問をスコアの高い順に最大 limit 件返す
+// スコアが同じ場合は新しい訪問を先にする。filter.Keyword は使わない
+func getFuzzyVisits(db dbQuerier, limit int, query string, filter SearchFilter) ([]HistoryVisit, error) {
+	filter.Keyword = ""
+	type scoredVisit struct {
+		visit HistoryVisit
+		score int
+	}
+	var matches []scoredVisit
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		if score, ok := fuzzyMatchVisit(query, v); ok {
+			matches = append(matches, scoredVisit{v, score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("あいまい検索に失敗: %w", err)
+	}
+
+	// 訪問は新しい順に届くので、安定ソートで同点の並びを保つ
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	visits := make([]HistoryVisit, len(matches))
+	for i, m := range matches {
+		visits[i] = m.visit
+	}
+	return visits, nil
+}
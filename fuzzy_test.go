@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    bool
+	}{
+		{"gtr", "GitHub - Test Repo", true},
+		{"ghtr", "GitHub - Test Repo", true},
+		{"", "anything", true},
+		{"rtg", "GitHub - Test Repo", false},
+		{"xyz", "GitHub - Test Repo", false},
+		// パターンに大文字を含む場合だけ大文字・小文字を区別する
+		{"gt", "GitHub Test", true},
+		{"GT", "GitHub Test", true},
+		{"GT", "github test", false},
+		{"ドキュ", "Go言語 ドキュメント", true},
+	}
+	for _, tt := range tests {
+		if _, ok := fuzzyMatch(tt.pattern, tt.text); ok != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) ok = %v, want %v", tt.pattern, tt.text, ok, tt.want)
+		}
+	}
+}
+
+func TestFuzzyMatchScore(t *testing.T) {
+	score := func(pattern, text string) int {
+		t.Helper()
+		s, ok := fuzzyMatch(pattern, text)
+		if !ok {
+			t.Fatalf("fuzzyMatch(%q, %q) が一致しない", pattern, text)
+		}
+		return s
+	}
+
+	// 連続した一致は飛び飛びの一致より高い
+	if a, b := score("git", "github"), score("git", "gxixtx"); a <= b {
+		t.Errorf("連続した一致 %d <= 飛び飛びの一致 %d", a, b)
+	}
+	// 単語の先頭での一致は途中での一致より高い
+	if a, b := score("tr", "Test Repo"), score("tr", "Another"); a <= b {
+		t.Errorf("単語の先頭での一致 %d <= 途中での一致 %d", a, b)
+	}
+	// 最も短い範囲でスコアを付ける
+	if a, b := score("ab", "a a ab"), score("ab", "a    b"); a <= b {
+		t.Errorf("短い範囲の一致 %d <= 長い範囲の一致 %d", a, b)
+	}
+}
+
+func TestFuzzyMatchVisit(t *testing.T) {
+	v := HistoryVisit{URL: "https://github.com/test", Title: "GitHub - Another Page"}
+
+	// 語ごとにタイトルかURLのどちらかに一致すればよい
+	if _, ok := fuzzyMatchVisit("gh test", v); !ok {
+		t.Error("タイトルとURLにそれぞれ一致する語で一致しない")
+	}
+	// 全ての語が一致する必要がある
+	if _, ok := fuzzyMatchVisit("gh music", v); ok {
+		t.Error("一致しない語を含むのに一致した")
+	}
+}
+
+func TestGetFuzzyVisits(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	titles := func(visits []HistoryVisit) []string {
+		var ts []string
+		for _, v := range visits {
+			ts = append(ts, v.Title)
+		}
+		return ts
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		limit  int
+		filter SearchFilter
+		want   []string
+	}{
+		// 古い訪問でも一致度が高ければ先になる
+		{"スコア順", "gtr", 10, SearchFilter{}, []string{"GitHub - Test Repo", "GitHub - Another Page"}},
+		// 同点なら新しい訪問が先
+		{"同点は新しい順", "yt", 10, SearchFilter{}, []string{"YouTube - Music", "YouTube Video"}},
+		{"件数の上限", "gtr", 1, SearchFilter{}, []string{"GitHub - Test Repo"}},
+		// Keyword は部分一致の絞り込みに使わない
+		{"Keyword を無視", "gtr", 10, SearchFilter{Keyword: "gtr"}, []string{"GitHub - Test Repo", "GitHub - Another Page"}},
+		{"他の条件と併用", "yt", 10, SearchFilter{Domain: "github"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visits, err := getFuzzyVisits(db, tt.limit, tt.query, tt.filter)
+			if err != nil {
+				t.Fatalf("getFuzzyVisits() error = %v", err)
+			}
+			got := titles(visits)
+			if len(got) != len(tt.want) {
+				t.Fatalf("getFuzzyVisits(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("getFuzzyVisits(%q)[%d] = %q, want %q", tt.query, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInteractiveModelFuzzySearch(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	m := newInteractiveModel(db)
+	m.fuzzy = true
+	m.filter.Keyword = "gtr"
+
+	msg := m.loadVisits()()
+	loaded, ok := msg.(visitsLoadedMsg)
+	if !ok {
+		t.Fatalf("loadVisits() = %T, want visitsLoadedMsg", msg)
+	}
+	if len(loaded.visits) != 2 || loaded.visits[0].Title != "GitHub - Test Repo" {
+		t.Errorf("あいまい検索の結果が一致度順でない: %+v", loaded.visits)
+	}
+
+	m.windowWidth = 80
+	if view := m.View(); !contains(view, "あいまい検索中") {
+		t.Error("あいまい検索中の表示がない")
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultGenVisits は hist gen で作る訪問数
+	defaultGenVisits = 100000
+	// defaultGenDays は hist gen で訪問を散らす日数（今日まで）
+	defaultGenDays = 365
+	// genPageVariants は番号付きのページ（Issue や動画など）の種類の数
+	genPageVariants = 200
+)
+
+// genSchema は hist gen で作るデータベースのテーブル定義
+// Safari の History.db のうち hist が参照するテーブル・カラムと同じ形にする
+const genSchema = `
+CREATE TABLE history_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL UNIQUE,
+	domain_expansion TEXT NULL,
+	visit_count INTEGER NOT NULL
+);
+CREATE TABLE history_visits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	history_item INTEGER NOT NULL REFERENCES history_items(id) ON DELETE CASCADE,
+	visit_time REAL NOT NULL,
+	title TEXT NULL,
+	load_successful BOOLEAN NOT NULL DEFAULT 1,
+	redirect_source INTEGER NULL UNIQUE REFERENCES history_visits(id) ON DELETE CASCADE,
+	redirect_destination INTEGER NULL UNIQUE REFERENCES history_visits(id) ON DELETE CASCADE
+);
+CREATE INDEX history_items__domain_expansion ON history_items (domain_expansion);
+CREATE INDEX history_visits__last_visit ON history_visits (history_item, visit_time DESC);`
+
+// genSite は合成する履歴のサイト
+// weight は訪問の多さ、leisure は夜・週末に多く見るサイト
+// ページの "%d" は番号に置き換え、番号の小さいページほど多く訪問する
+type genSite struct {
+	host    string
+	weight  int
+	leisure bool
+	pages   [][2]string
+}
+
+// genSites は合成する履歴のサイトとページ（パスとタイトル）
+var genSites = []genSite{
+	{"github.com", 20, false, [][2]string{
+		{"/", "GitHub"},
+		{"/nyasuto/hist", "nyasuto/hist: Safari の閲覧履歴を分析する CLI"},
+		{"/nyasuto/hist/pull/%d", "Pull Request #%d · nyasuto/hist"},
+		{"/nyasuto/hist/issues/%d", "Issue #%d · nyasuto/hist"},
+		{"/golang/go/issues/%d", "Issue #%d · golang/go"},
+	}},
+	{"www.google.com", 18, false, [][2]string{
+		{"/search?q=golang+%d", "golang %d - Google 検索"},
+		{"/search?q=sqlite+%d", "sqlite %d - Google 検索"},
+	}},
+	{"www.youtube.com", 14, true, [][2]string{
+		{"/", "YouTube"},
+		{"/watch?v=demo%d", "デモ動画 %d - YouTube"},
+	}},
+	{"pkg.go.dev", 8, false, [][2]string{
+		{"/net/http", "http package - net/http - Go Packages"},
+		{"/database/sql", "sql package - database/sql - Go Packages"},
+		{"/time", "time package - time - Go Packages"},
+		{"/github.com/mattn/go-sqlite3", "sqlite3 package - github.com/mattn/go-sqlite3 - Go Packages"},
+	}},
+	{"stackoverflow.com", 7, false, [][2]string{
+		{"/questions/%d", "質問 %d - Stack Overflow"},
+	}},
+	{"developer.mozilla.org", 5, false, [][2]string{
+		{"/ja/docs/Web/JavaScript", "JavaScript | MDN"},
+		{"/ja/docs/Web/CSS/grid", "grid - CSS: カスケーディングスタイルシート | MDN"},
+		{"/ja/docs/Web/API/Fetch_API", "フェッチ API - Web API | MDN"},
+	}},
+	{"mail.google.com", 9, false, [][2]string{
+		{"/mail/u/0/#inbox", "受信トレイ - Gmail"},
+	}},
+	{"calendar.google.com", 4, false, [][2]string{
+		{"/calendar/u/0/r/week", "Google カレンダー - 週"},
+	}},
+	{"docs.google.com", 6, false, [][2]string{
+		{"/document/d/doc%d/edit", "議事録 %d - Google ドキュメント"},
+		{"/spreadsheets/d/sheet%d/edit", "集計表 %d - Google スプレッドシート"},
+	}},
+	{"app.slack.com", 8, false, [][2]string{
+		{"/client/T0000/C%d", "Slack"},
+	}},
+	{"localhost:3000", 5, false, [][2]string{
+		{"/", "開発サーバー"},
+		{"/admin", "管理画面 - 開発サーバー"},
+	}},
+	{"qiita.com", 4, false, [][2]string{
+		{"/items/%d", "記事 %d - Qiita"},
+	}},
+	{"zenn.dev", 4, false, [][2]string{
+		{"/articles/%d", "記事 %d | Zenn"},
+	}},
+	{"news.yahoo.co.jp", 7, true, [][2]string{
+		{"/", "Yahoo!ニュース"},
+		{"/articles/%d", "ニュース %d - Yahoo!ニュース"},
+	}},
+	{"x.com", 9, true, [][2]string{
+		{"/home", "ホーム / X"},
+		{"/user%d/status/%d", "ポスト %d / X"},
+	}},
+	{"www.amazon.co.jp", 4, true, [][2]string{
+		{"/dp/B0%d", "商品 %d - Amazon.co.jp"},
+	}},
+	{"ja.wikipedia.org", 4, true, [][2]string{
+		{"/wiki/Page_%d", "項目 %d - Wikipedia"},
+	}},
+	{"www.netflix.com", 3, true, [][2]string{
+		{"/browse", "ホーム - Netflix"},
+		{"/watch/%d", "Netflix"},
+	}},
+	{"tenki.jp", 2, true, [][2]string{
+		{"/", "天気予報 - tenki.jp"},
+	}},
+}
+
+// 時間帯ごとの訪問の重み（0時〜23時）
+// 平日の仕事のサイトは日中、それ以外（平日の趣味のサイトと週末）は夜に多い
+var (
+	genWorkHours    = [24]int{0, 0, 0, 0, 0, 0, 1, 3, 6, 9, 10, 10, 7, 9, 10, 10, 9, 8, 5, 3, 2, 1, 1, 0}
+	genLeisureHours = [24]int{3, 2, 1, 0, 0, 0, 1, 3, 4, 3, 2, 2, 4, 3, 2, 2, 2, 3, 5, 7, 9, 10, 9, 6}
+)
+
+// genVisit は合成した訪問1件
+type genVisit struct {
+	item  int
+	title string
+	time  time.Time
+}
+
+// runGenCommand は hist gen サブコマンドを実行する
+func runGenCommand(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	visits := fs.Int("visits", defaultGenVisits, "作成する訪問数")
+	days := fs.Int("days", defaultGenDays, "訪問を散らす日数（今日まで）")
+	out := fs.String("out", "", "作成するデータベースファイル（既存のファイルには書き込まない）")
+	seed := fs.Uint64("seed", 1, "乱数のシード（同じ値なら同じ履歴を作る）")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist gen -out demo.db [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  Safari の履歴DBと同じ形の合成データを作成します。%s=demo.db を指定すると hist で読み込めます\n\n", HistDBEnv)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		fs.Usage()
+		return fmt.Errorf("-out で作成するファイルを指定してください")
+	}
+	if *visits < 1 || *days < 1 {
+		return fmt.Errorf("-visits と -days には1以上を指定してください")
+	}
+
+	items, err := generateHistory(*out, *visits, *days, time.Now(), *seed)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d件の訪問（URL %d件、%d日間）を %s に作成しました\n", *visits, items, *days, *out)
+	fmt.Printf("  %s=%s hist -all で表示できます\n", HistDBEnv, *out)
+	return nil
+}
+
+// generateHistory は now までの days 日間に visits 件の訪問を合成し、新しいデータベースファイルに書き出す
+// 作成したURLの数を返す
+func generateHistory(path string, visits, days int, now time.Time, seed uint64) (int, error) {
+	if _, err := os.Stat(path); err == nil {
+		return 0, fmt.Errorf("出力先のファイルが既に存在します: %s", path)
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+	urls, generated := generateVisits(rng, visits, days, now)
+
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return 0, fmt.Errorf("出力先のデータベースを開けませんでした: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(genSchema); err != nil {
+		return 0, fmt.Errorf("テーブルの作成に失敗: %w", err)
+	}
+	if err := writeGeneratedHistory(tx, urls, generated); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("書き出しの確定に失敗: %w", err)
+	}
+	return len(urls), nil
+}
+
+// generateVisits は訪問を時刻順に合成し、訪問したURLの一覧と訪問を返す
+func generateVisits(rng *rand.Rand, visits, days int, now time.Time) ([]string, []genVisit) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var urls []string
+	itemIndex := make(map[string]int)
+	generated := make([]genVisit, 0, visits)
+
+	for range visits {
+		day := today.AddDate(0, 0, -rng.IntN(days))
+		weekend := isWeekend(day)
+		site := pickGenSite(rng, weekend)
+		hours := &genWorkHours
+		if weekend || site.leisure {
+			hours = &genLeisureHours
+		}
+		t := day.Add(time.Duration(pickWeighted(rng, hours[:]))*time.Hour + time.Duration(rng.Int64N(int64(time.Hour))))
+		// 今日のまだ来ていない時刻は前日にずらす
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+
+		// 番号の小さいページほど多く訪問する（2乗で偏らせる）
+		page := site.pages[int(float64(len(site.pages))*rng.Float64()*rng.Float64())]
+		path, title := page[0], page[1]
+		if strings.Contains(path, "%d") {
+			n := 1 + int(genPageVariants*rng.Float64()*rng.Float64())
+			path = strings.ReplaceAll(path, "%d", fmt.Sprint(n))
+			title = strings.ReplaceAll(title, "%d", fmt.Sprint(n))
+		}
+		scheme := "https://"
+		if strings.HasPrefix(site.host, "localhost") {
+			scheme = "http://"
+		}
+		url := scheme + site.host + path
+
+		i, ok := itemIndex[url]
+		if !ok {
+			i = len(urls)
+			itemIndex[url] = i
+			urls = append(urls, url)
+		}
+		generated = append(generated, genVisit{item: i, title: title, time: t})
+	}
+	sort.Slice(generated, func(i, j int) bool { return generated[i].time.Before(generated[j].time) })
+	return urls, generated
+}
+
+// pickGenSite は重みに従ってサイトを選ぶ（平日は仕事のサイト、週末は趣味のサイトを3倍選びやすくする）
+func pickGenSite(rng *rand.Rand, weekend bool) genSite {
+	weights := make([]int, len(genSites))
+	for i, s := range genSites {
+		weights[i] = s.weight
+		if s.leisure == weekend {
+			weights[i] *= 3
+		}
+	}
+	return genSites[pickWeighted(rng, weights)]
+}
+
+// pickWeighted は重みに比例した確率でインデックスを選ぶ
+func pickWeighted(rng *rand.Rand, weights []int) int {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	n := rng.IntN(total)
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(weights) - 1
+}
+
+// genDomainExpansion は Safari の domain_expansion と同じく、ホスト名から www. とトップレベルドメインを除く
+// （learn.microsoft.com → learn.microsoft、news.yahoo.co.jp → news.yahoo）。localhost などは NULL
+func genDomainExpansion(url string) any {
+	host := extractDomain(url)
+	host = strings.TrimPrefix(host, "www.")
+	i := strings.LastIndex(host, ".")
+	if i < 0 {
+		return nil
+	}
+	host = strings.TrimSuffix(host[:i], ".co")
+	return host
+}
+
+// writeGeneratedHistory は URL と時刻順の訪問を history_items・history_visits に挿入する
+func writeGeneratedHistory(tx *sql.Tx, urls []string, visits []genVisit) error {
+	counts := make([]int, len(urls))
+	for _, v := range visits {
+		counts[v.item]++
+	}
+
+	itemStmt, err := tx.Prepare(`INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("URLの書き出しの準備に失敗: %w", err)
+	}
+	defer func() { _ = itemStmt.Close() }()
+	for i, url := range urls {
+		if _, err := itemStmt.Exec(i+1, url, genDomainExpansion(url), counts[i]); err != nil {
+			return fmt.Errorf("URLの書き出しに失敗: %w", err)
+		}
+	}
+
+	visitStmt, err := tx.Prepare(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("訪問の書き出しの準備に失敗: %w", err)
+	}
+	defer func() { _ = visitStmt.Close() }()
+	for i, v := range visits {
+		if _, err := visitStmt.Exec(i+1, v.item+1, convertToTimestamp(v.time), v.title); err != nil {
+			return fmt.Errorf("訪問の書き出しに失敗: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateHistory(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.Local)
+	path := filepath.Join(t.TempDir(), "demo.db")
+	items, err := generateHistory(path, 2000, 30, now, 1)
+	if err != nil {
+		t.Fatalf("generateHistory() error = %v", err)
+	}
+	if items == 0 {
+		t.Fatal("URL が作成されていない")
+	}
+	if _, err := generateHistory(path, 10, 1, now, 1); err == nil {
+		t.Error("既存のファイルに書き込んだ")
+	}
+
+	db, err := openDB(path)
+	if err != nil {
+		t.Fatalf("openDB() error = %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	total, err := getTotalVisits(db)
+	if err != nil || total != 2000 {
+		t.Fatalf("getTotalVisits() = %d, %v, want 2000", total, err)
+	}
+	var countSum, urls int
+	if err := db.QueryRow("SELECT SUM(visit_count), COUNT(*) FROM history_items").Scan(&countSum, &urls); err != nil {
+		t.Fatalf("history_items の集計に失敗: %v", err)
+	}
+	if countSum != 2000 || urls != items {
+		t.Errorf("visit_count の合計 = %d, URL数 = %d, want 2000, %d", countSum, urls, items)
+	}
+
+	// 訪問は期間内に収まり、ID は時刻順
+	visits, err := getRecentVisits(db, 2000, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getRecentVisits() error = %v", err)
+	}
+	if len(visits) != 2000 {
+		t.Fatalf("getRecentVisits() = %d件, want 2000", len(visits))
+	}
+	oldest := now.AddDate(0, 0, -30)
+	for i, v := range visits {
+		if v.VisitTime.After(now) || v.VisitTime.Before(oldest) {
+			t.Fatalf("期間外の訪問: %v", v.VisitTime)
+		}
+		if i > 0 && v.ID > visits[i-1].ID && !v.VisitTime.Equal(visits[i-1].VisitTime) {
+			t.Fatalf("ID が時刻順でない: %d の後に %d", visits[i-1].ID, v.ID)
+		}
+	}
+
+	// 重みの大きいサイトが上位になり、ドメインで絞り込める
+	domains, err := getDomainStats(db, 3, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getDomainStats() error = %v", err)
+	}
+	if len(domains) != 3 || domains[0].Domain != "github.com" && domains[0].Domain != "www.google.com" {
+		t.Errorf("上位ドメイン = %+v", domains)
+	}
+	github, err := getRecentVisits(db, 2000, SearchFilter{Domain: "github.com"})
+	if err != nil || len(github) == 0 {
+		t.Errorf("github.com の訪問 = %d件, %v", len(github), err)
+	}
+
+	// 仕事のサイトは深夜より日中に多い
+	hourly, err := getHourlyStats(db, SearchFilter{Domain: "mail.google.com"})
+	if err != nil {
+		t.Fatalf("getHourlyStats() error = %v", err)
+	}
+	byHour := make(map[int]int)
+	for _, h := range hourly {
+		byHour[h.Hour] = h.VisitCount
+	}
+	if byHour[10] <= byHour[3] {
+		t.Errorf("10時 %d件, 3時 %d件", byHour[10], byHour[3])
+	}
+}
+
+func TestGenerateHistorySeed(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	var counts []int
+	for i, seed := range []uint64{7, 7, 8} {
+		items, err := generateHistory(filepath.Join(dir, string(rune('a'+i))+".db"), 500, 10, now, seed)
+		if err != nil {
+			t.Fatalf("generateHistory() error = %v", err)
+		}
+		counts = append(counts, items)
+	}
+	if counts[0] != counts[1] {
+		t.Errorf("同じシードで URL数が異なる: %v", counts)
+	}
+}
+
+func TestGenDomainExpansion(t *testing.T) {
+	tests := []struct {
+		url  string
+		want any
+	}{
+		{"https://www.github.com/nyasuto", "github"},
+		{"https://learn.microsoft.com/ja-jp/", "learn.microsoft"},
+		{"https://news.yahoo.co.jp/", "news.yahoo"},
+		{"http://localhost:3000/", nil},
+	}
+	for _, tt := range tests {
+		if got := genDomainExpansion(tt.url); got != tt.want {
+			t.Errorf("genDomainExpansion(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitHubのページ種別
+const (
+	GitHubKindRepo  = "repo"  // リポジトリのトップ
+	GitHubKindPull  = "pull"  // プルリクエスト
+	GitHubKindIssue = "issue" // イシュー
+	GitHubKindCode  = "code"  // コード・コミット（blob / tree / commit など）
+	GitHubKindOther = "other" // Actions・Releases・Wiki など
+)
+
+// githubKindOrder はテキスト出力で種別を並べる順序
+var githubKindOrder = []string{GitHubKindRepo, GitHubKindPull, GitHubKindIssue, GitHubKindCode, GitHubKindOther}
+
+// githubReservedOwners はリポジトリのオーナーではないGitHubのトップレベルのパス
+var githubReservedOwners = map[string]bool{
+	"settings": true, "notifications": true, "orgs": true, "organizations": true, "marketplace": true,
+	"explore": true, "topics": true, "search": true, "login": true, "logout": true, "signup": true,
+	"sponsors": true, "features": true, "pulls": true, "issues": true, "new": true, "codespaces": true,
+	"collections": true, "trending": true, "about": true, "pricing": true, "apps": true, "dashboard": true,
+	"enterprise": true, "security": true, "stars": true, "watching": true, "users": true, "site": true,
+}
+
+// githubKinds はリポジトリ配下のパスの1段目とページ種別の対応
+var githubKinds = map[string]string{
+	"pull": GitHubKindPull, "pulls": GitHubKindPull, "compare": GitHubKindPull,
+	"issues": GitHubKindIssue, "discussions": GitHubKindIssue,
+	"blob": GitHubKindCode, "tree": GitHubKindCode, "commit": GitHubKindCode, "commits": GitHubKindCode,
+	"blame": GitHubKindCode, "raw": GitHubKindCode, "find": GitHubKindCode,
+}
+
+// GitHubPage はURLから取り出したリポジトリとページ種別
+type GitHubPage struct {
+	Repo string // owner/repo
+	Kind string
+}
+
+// GitHubRepoStats はリポジトリごとの種別別訪問数
+type GitHubRepoStats struct {
+	Repo       string         `json:"repo"`
+	VisitCount int            `json:"visit_count"`
+	Kinds      map[string]int `json:"kinds"`
+	LastVisit  time.Time      `json:"last_visit"`
+}
+
+// GitHubKindStats はページ種別ごとの合計
+type GitHubKindStats struct {
+	Kind       string `json:"kind"`
+	VisitCount int    `json:"visit_count"`
+}
+
+// GitHubReport は -github の結果
+type GitHubReport struct {
+	Kinds []GitHubKindStats `json:"kinds"`
+	Repos []GitHubRepoStats `json:"repos"`
+}
+
+// parseGitHubURL は github.com のURLからリポジトリとページ種別を取り出す
+// リポジトリ配下のページでなければ ok=false を返す
+func parseGitHubURL(rawURL string) (GitHubPage, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return GitHubPage{}, false
+	}
+	if host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."); host != "github.com" {
+		return GitHubPage{}, false
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" || githubReservedOwners[strings.ToLower(segments[0])] {
+		return GitHubPage{}, false
+	}
+
+	// GitHubのオーナー名・リポジトリ名は大文字小文字を区別しない
+	page := GitHubPage{
+		Repo: strings.ToLower(segments[0] + "/" + strings.TrimSuffix(segments[1], ".git")),
+		Kind: GitHubKindRepo,
+	}
+	if len(segments) > 2 {
+		page.Kind = GitHubKindOther
+		if kind, ok := githubKinds[segments[2]]; ok {
+			page.Kind = kind
+		}
+	}
+	return page, true
+}
+
+// getGitHubReport はGitHubのリポジトリ配下のページへの訪問をリポジトリ・種別ごとに集計する
+// limit はリポジトリの表示件数（0以下なら全件）
+func getGitHubReport(db dbQuerier, limit int, filter SearchFilter) (GitHubReport, error) {
+	repos := make(map[string]*GitHubRepoStats)
+	kindCounts := make(map[string]int)
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		page, ok := parseGitHubURL(v.URL)
+		if !ok {
+			return nil
+		}
+		stats := repos[page.Repo]
+		if stats == nil {
+			stats = &GitHubRepoStats{Repo: page.Repo, Kinds: make(map[string]int)}
+			repos[page.Repo] = stats
+		}
+		stats.VisitCount++
+		stats.Kinds[page.Kind]++
+		if v.VisitTime.After(stats.LastVisit) {
+			stats.LastVisit = v.VisitTime
+		}
+		kindCounts[page.Kind]++
+		return nil
+	})
+	if err != nil {
+		return GitHubReport{}, fmt.Errorf("GitHubの集計に失敗: %w", err)
+	}
+
+	var report GitHubReport
+	for _, kind := range githubKindOrder {
+		if kindCounts[kind] > 0 {
+			report.Kinds = append(report.Kinds, GitHubKindStats{Kind: kind, VisitCount: kindCounts[kind]})
+		}
+	}
+	for _, stats := range repos {
+		report.Repos = append(report.Repos, *stats)
+	}
+	sort.Slice(report.Repos, func(i, j int) bool {
+		if report.Repos[i].VisitCount != report.Repos[j].VisitCount {
+			return report.Repos[i].VisitCount > report.Repos[j].VisitCount
+		}
+		return report.Repos[i].Repo < report.Repos[j].Repo
+	})
+	if limit > 0 && len(report.Repos) > limit {
+		report.Repos = report.Repos[:limit]
+	}
+	return report, nil
+}
+
+// redactGitHubReport は github.com がリダクト対象の場合にリポジトリ名を伏せたコピーを返す
+func redactGitHubReport(report *GitHubReport, redactDomains []string) *GitHubReport {
+	if report == nil || !domainMatchesList("github.com", redactDomains) {
+		return report
+	}
+	redacted := *report
+	redacted.Repos = make([]GitHubRepoStats, len(report.Repos))
+	for i, r := range report.Repos {
+		r.Repo = redactedLabel
+		redacted.Repos[i] = r
+	}
+	return &redacted
+}
+
+// githubKindLabel はページ種別の表示名
+func githubKindLabel(kind string) string {
+	switch kind {
+	case GitHubKindRepo:
+		return "トップ"
+	case GitHubKindPull:
+		return "PR"
+	case GitHubKindIssue:
+		return "Issue"
+	case GitHubKindCode:
+		return "コード"
+	default:
+		return "その他"
+	}
+}
+
+// printGitHubReport はGitHubの集計をテキスト形式で出力する
+func printGitHubReport(w io.Writer, report GitHubReport) {
+	_, _ = fmt.Fprintf(w, "🐙 GitHub リポジトリ (Top %d)\n", len(report.Repos))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	var kinds []string
+	for _, k := range report.Kinds {
+		kinds = append(kinds, fmt.Sprintf("%s %d", githubKindLabel(k.Kind), k.VisitCount))
+	}
+	if len(kinds) > 0 {
+		_, _ = fmt.Fprintf(w, "  種別: %s\n\n", strings.Join(kinds, " / "))
+	}
+
+	maxCount := 0
+	for _, r := range report.Repos {
+		maxCount = max(maxCount, r.VisitCount)
+	}
+	for _, r := range report.Repos {
+		barLen := int(float64(r.VisitCount) / float64(maxCount) * BarChartWidth)
+		_, _ = fmt.Fprintf(w, "  %s %s %d\n", padRight(r.Repo, 30), strings.Repeat("█", barLen), r.VisitCount)
+		var breakdown []string
+		for _, kind := range githubKindOrder {
+			if n := r.Kinds[kind]; n > 0 {
+				breakdown = append(breakdown, fmt.Sprintf("%s %d", githubKindLabel(kind), n))
+			}
+		}
+		_, _ = fmt.Fprintf(w, "      %s\n", strings.Join(breakdown, " / "))
+	}
+	_, _ = fmt.Fprintln(w)
+}
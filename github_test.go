@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+// TestParseGitHubURL はGitHubのURLの解析のテスト
+func TestParseGitHubURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		wantOK bool
+		repo   string
+		kind   string
+	}{
+		{"https://github.com/golang/go", true, "golang/go", GitHubKindRepo},
+		{"https://github.com/Golang/Go.git", true, "golang/go", GitHubKindRepo},
+		{"https://github.com/golang/go/pull/123/files", true, "golang/go", GitHubKindPull},
+		{"https://github.com/golang/go/issues/456", true, "golang/go", GitHubKindIssue},
+		{"https://github.com/golang/go/blob/master/README.md", true, "golang/go", GitHubKindCode},
+		{"https://github.com/golang/go/commit/abc", true, "golang/go", GitHubKindCode},
+		{"https://github.com/golang/go/actions", true, "golang/go", GitHubKindOther},
+		{"https://www.github.com/golang/go", true, "golang/go", GitHubKindRepo},
+		{"https://github.com/golang", false, "", ""},
+		{"https://github.com/settings/profile", false, "", ""},
+		{"https://github.com/orgs/golang/repositories", false, "", ""},
+		{"https://gist.github.com/user/abc", false, "", ""},
+		{"https://example.com/golang/go", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		page, ok := parseGitHubURL(tt.url)
+		if ok != tt.wantOK {
+			t.Errorf("parseGitHubURL(%q) ok = %v, want %v", tt.url, ok, tt.wantOK)
+			continue
+		}
+		if ok && (page.Repo != tt.repo || page.Kind != tt.kind) {
+			t.Errorf("parseGitHubURL(%q) = %+v, want %s (%s)", tt.url, page, tt.repo, tt.kind)
+		}
+	}
+}
+
+// TestGetGitHubReport はGitHubの集計のテスト
+func TestGetGitHubReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	base := 757418400.0
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://github.com/golang/go', 'github', 1),
+		(2, 'https://github.com/golang/go/pull/1', 'github', 2),
+		(3, 'https://github.com/golang/go/issues/2', 'github', 1),
+		(4, 'https://github.com/nyasuto/hist/blob/main/main.go', 'github', 1),
+		(5, 'https://github.com/notifications', 'github', 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, ?, 'golang/go'),
+		(2, 2, ?, 'PR'),
+		(3, 2, ?, 'PR'),
+		(4, 3, ?, 'Issue'),
+		(5, 4, ?, 'main.go'),
+		(6, 5, ?, 'Notifications');
+	`, base, base+60, base+120, base+180, base+240, base+300)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	report, err := getGitHubReport(db, 0, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getGitHubReport失敗: %v", err)
+	}
+	if len(report.Repos) != 2 {
+		t.Fatalf("リポジトリ数が期待値と異なる: %+v", report.Repos)
+	}
+
+	top := report.Repos[0]
+	if top.Repo != "golang/go" || top.VisitCount != 4 || top.Kinds[GitHubKindPull] != 2 || top.Kinds[GitHubKindIssue] != 1 {
+		t.Errorf("golang/go の集計が期待値と異なる: %+v", top)
+	}
+	if report.Repos[1].Repo != "nyasuto/hist" || report.Repos[1].Kinds[GitHubKindCode] != 1 {
+		t.Errorf("nyasuto/hist の集計が期待値と異なる: %+v", report.Repos[1])
+	}
+	if len(report.Kinds) != 4 || report.Kinds[1].Kind != GitHubKindPull || report.Kinds[1].VisitCount != 2 {
+		t.Errorf("種別ごとの集計が期待値と異なる: %+v", report.Kinds)
+	}
+
+	limited, err := getGitHubReport(db, 1, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getGitHubReport失敗: %v", err)
+	}
+	if len(limited.Repos) != 1 || limited.Kinds[1].VisitCount != 2 {
+		t.Errorf("limit 指定時の集計が期待値と異なる: %+v", limited)
+	}
+
+	redacted := redactGitHubReport(&report, []string{"github.com"})
+	if redacted.Repos[0].Repo != redactedLabel || report.Repos[0].Repo != "golang/go" {
+		t.Errorf("リダクトが期待値と異なる: %+v / %+v", redacted.Repos[0], report.Repos[0])
+	}
+}
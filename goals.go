@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// goalsFileName は訪問数の削減目標を保存するファイル
+const goalsFileName = "goals.txt"
+
+// defaultGoalHistory は hist goals で達成状況を表示する過去の期間数
+const defaultGoalHistory = 8
+
+// 目標の期間
+const (
+	GoalPeriodDay  = "day"
+	GoalPeriodWeek = "week"
+)
+
+// Goal は「ドメイン 上限回数/期間」形式の訪問数の削減目標
+// 例: "twitter.com 20/week" は twitter.com（サブドメインを含む）への訪問を週20回までに抑える
+type Goal struct {
+	Domain string `json:"domain"`
+	Limit  int    `json:"limit"`
+	Period string `json:"period"`
+}
+
+// GoalPeriod は1期間分の訪問数と達成したか
+type GoalPeriod struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Met   bool      `json:"met"`
+}
+
+// GoalProgress は目標ごとの今期の進捗と過去の達成状況
+type GoalProgress struct {
+	Goal
+	Current GoalPeriod   `json:"current"`
+	History []GoalPeriod `json:"history"`
+	MetRate float64      `json:"met_rate"`
+}
+
+// parseGoal は「ドメイン 上限回数/期間」形式の目標を解析する（期間は day または week）
+func parseGoal(s string) (Goal, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Goal{}, fmt.Errorf("目標は「ドメイン 回数/week」形式で指定してください: %s", s)
+	}
+	countStr, period, ok := strings.Cut(fields[1], "/")
+	if !ok {
+		return Goal{}, fmt.Errorf("目標の期間を /day または /week で指定してください: %s", s)
+	}
+	limit, err := strconv.Atoi(strings.TrimPrefix(countStr, "<"))
+	if err != nil || limit <= 0 {
+		return Goal{}, fmt.Errorf("目標の回数は1以上の整数で指定してください: %s", s)
+	}
+	if period != GoalPeriodDay && period != GoalPeriodWeek {
+		return Goal{}, fmt.Errorf("目標の期間は day または week で指定してください: %s", s)
+	}
+	return Goal{Domain: strings.ToLower(fields[0]), Limit: limit, Period: period}, nil
+}
+
+// String は目標を goals.txt の1行の形式で返す
+func (g Goal) String() string {
+	return fmt.Sprintf("%s %d/%s", g.Domain, g.Limit, g.Period)
+}
+
+// periodLabel は期間の表示名
+func (g Goal) periodLabel() string {
+	if g.Period == GoalPeriodDay {
+		return "日"
+	}
+	return "週"
+}
+
+// periodStart は t を含む期間の開始時刻（日は0時、週は cal の週の始まりの0時）を返す
+func (g Goal) periodStart(t time.Time, cal Calendar) time.Time {
+	if g.Period == GoalPeriodDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return cal.weekStart(t)
+}
+
+// shiftPeriod は期間の開始時刻を n 期間ずらす
+func (g Goal) shiftPeriod(start time.Time, n int) time.Time {
+	if g.Period == GoalPeriodDay {
+		return start.AddDate(0, 0, n)
+	}
+	return start.AddDate(0, 0, 7*n)
+}
+
+// LoadGoals は削減目標を読み込む
+func LoadGoals() ([]Goal, error) {
+	lines, err := loadDomainListFile(goalsFileName, "目標一覧")
+	if err != nil {
+		return nil, err
+	}
+	var goals []Goal
+	for _, line := range lines {
+		g, err := parseGoal(line)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+	return goals, nil
+}
+
+// SaveGoals は削減目標を保存する
+func SaveGoals(goals []Goal) error {
+	lines := make([]string, len(goals))
+	for i, g := range goals {
+		lines[i] = g.String()
+	}
+	return saveDomainListFile(goalsFileName, "目標一覧", lines)
+}
+
+// SetGoal は削減目標を追加する（同じドメイン・期間の目標があれば上書きする）
+func SetGoal(s string) error {
+	goal, err := parseGoal(s)
+	if err != nil {
+		return err
+	}
+	goals, err := LoadGoals()
+	if err != nil {
+		return err
+	}
+	for i, g := range goals {
+		if g.Domain == goal.Domain && g.Period == goal.Period {
+			goals[i] = goal
+			return SaveGoals(goals)
+		}
+	}
+	return SaveGoals(append(goals, goal))
+}
+
+// RemoveGoal はドメインの削減目標を削除する
+func RemoveGoal(domain string) error {
+	goals, err := LoadGoals()
+	if err != nil {
+		return err
+	}
+	var kept []Goal
+	for _, g := range goals {
+		if g.Domain != strings.ToLower(domain) {
+			kept = append(kept, g)
+		}
+	}
+	return SaveGoals(kept)
+}
+
+// getGoalProgress は各目標について now を含む期間と、その前の history 期間分の訪問数を集計する
+// 目標の判定にはイグノアリストを適用しない（除外したドメインも目標の対象にできるようにする）
+func getGoalProgress(db dbQuerier, goals []Goal, history int, now time.Time, cal Calendar) ([]GoalProgress, error) {
+	if len(goals) == 0 {
+		return nil, nil
+	}
+
+	progress := make([]GoalProgress, len(goals))
+	oldest := now
+	for i, g := range goals {
+		current := g.periodStart(now, cal)
+		progress[i] = GoalProgress{Goal: g, Current: GoalPeriod{Start: current}}
+		for n := history; n >= 1; n-- {
+			progress[i].History = append(progress[i].History, GoalPeriod{Start: g.shiftPeriod(current, -n)})
+		}
+		if start := g.shiftPeriod(current, -history); start.Before(oldest) {
+			oldest = start
+		}
+	}
+
+	err := streamRecentVisits(db, 0, SearchFilter{From: oldest}, func(v HistoryVisit) error {
+		domain := extractDomain(v.URL)
+		for i := range progress {
+			p := &progress[i]
+			if v.VisitTime.After(now) || !domainMatchesList(domain, []string{p.Domain}) {
+				continue
+			}
+			start := p.periodStart(v.VisitTime, cal)
+			if start.Equal(p.Current.Start) {
+				p.Current.Count++
+				continue
+			}
+			for j := range p.History {
+				if start.Equal(p.History[j].Start) {
+					p.History[j].Count++
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("目標の集計に失敗: %w", err)
+	}
+
+	for i := range progress {
+		p := &progress[i]
+		p.Current.Met = p.Current.Count <= p.Limit
+		met := 0
+		for j := range p.History {
+			p.History[j].Met = p.History[j].Count <= p.Limit
+			if p.History[j].Met {
+				met++
+			}
+		}
+		if len(p.History) > 0 {
+			p.MetRate = roundPercentage(float64(met) / float64(len(p.History)) * 100)
+		}
+	}
+	return progress, nil
+}
+
+// printGoalProgress は今期の進捗バーと過去の達成状況を出力する
+func printGoalProgress(w io.Writer, progress []GoalProgress) {
+	_, _ = fmt.Fprintf(w, "🎯 目標の進捗\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, p := range progress {
+		label := p.periodLabel()
+		_, _ = fmt.Fprintf(w, "  %s  %d回まで/%s\n", p.Domain, p.Limit, label)
+
+		filled := min(p.Current.Count*BarChartWidth/p.Limit, BarChartWidth)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", BarChartWidth-filled)
+		status := fmt.Sprintf("残り%d回", p.Limit-p.Current.Count)
+		if !p.Current.Met {
+			status = fmt.Sprintf("⚠️  超過 +%d", p.Current.Count-p.Limit)
+		}
+		_, _ = fmt.Fprintf(w, "    今%s: %s %d/%d  %s\n", label, bar, p.Current.Count, p.Limit, status)
+
+		if len(p.History) > 0 {
+			var marks strings.Builder
+			met := 0
+			for _, h := range p.History {
+				if h.Met {
+					marks.WriteString("✅")
+					met++
+				} else {
+					marks.WriteString("❌")
+				}
+			}
+			_, _ = fmt.Fprintf(w, "    過去%d%s: %s  達成 %d/%d (%.0f%%)\n", len(p.History), label, marks.String(), met, len(p.History), p.MetRate)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// runGoalsCommand は hist goals サブコマンドを実行する
+func runGoalsCommand(args []string) error {
+	fs := flag.NewFlagSet("goals", flag.ContinueOnError)
+	set := fs.String("set", "", "目標を追加・更新（\"ドメイン 回数/week\" または \"ドメイン 回数/day\"）")
+	remove := fs.String("remove", "", "ドメインの目標を削除")
+	history := fs.Int("history", defaultGoalHistory, "達成状況を表示する過去の期間数")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist goals [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  目標は ~/.config/hist/%s に「ドメイン 回数/week」形式で保存されます\n\n", goalsFileName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *set != "" {
+		if err := SetGoal(*set); err != nil {
+			return err
+		}
+		fmt.Printf("目標を設定しました: %s\n", *set)
+		return nil
+	}
+	if *remove != "" {
+		if err := RemoveGoal(*remove); err != nil {
+			return err
+		}
+		fmt.Printf("目標を削除しました: %s\n", *remove)
+		return nil
+	}
+	if *history < 0 {
+		return errors.New("-history は0以上で指定してください")
+	}
+
+	goals, err := LoadGoals()
+	if err != nil {
+		return err
+	}
+	if len(goals) == 0 {
+		return errors.New("目標が設定されていません（hist goals -set \"twitter.com 20/week\" で設定）")
+	}
+
+	cal, err := LoadCalendar()
+	if err != nil {
+		return err
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	progress, err := getGoalProgress(db, goals, *history, time.Now(), cal)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(progress)
+	}
+	printGoalProgress(os.Stdout, progress)
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseGoal は削減目標の解析のテスト
+func TestParseGoal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"twitter.com 20/week", "twitter.com 20/week", false},
+		{"Twitter.com <20/week", "twitter.com 20/week", false},
+		{"youtube.com 3/day", "youtube.com 3/day", false},
+		{"twitter.com 20", "", true},
+		{"twitter.com 20/month", "", true},
+		{"twitter.com 0/week", "", true},
+		{"twitter.com", "", true},
+	}
+
+	for _, tt := range tests {
+		g, err := parseGoal(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGoal(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && g.String() != tt.want {
+			t.Errorf("parseGoal(%q) = %q, want %q", tt.input, g.String(), tt.want)
+		}
+	}
+}
+
+// TestGoalPeriodStart は期間の開始時刻のテスト
+func TestGoalPeriodStart(t *testing.T) {
+	// 2025-01-01 は水曜日
+	wed := time.Date(2025, 1, 1, 15, 30, 0, 0, time.UTC)
+	week := Goal{Period: GoalPeriodWeek}
+	if got := week.periodStart(wed, defaultCalendar); !got.Equal(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("週の開始が月曜0時ではない: %v", got)
+	}
+	sun := time.Date(2025, 1, 5, 23, 0, 0, 0, time.UTC)
+	if got := week.periodStart(sun, defaultCalendar); !got.Equal(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("日曜は前の月曜から始まる週に含まれるはず: %v", got)
+	}
+	day := Goal{Period: GoalPeriodDay}
+	if got := day.periodStart(wed, defaultCalendar); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("日の開始が0時ではない: %v", got)
+	}
+	// 日曜始まりの暦では日曜がその週の最初の日
+	sunday := Calendar{WeekStart: time.Sunday, FiscalYearStart: time.January}
+	if got := week.periodStart(sun, sunday); !got.Equal(time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("日曜始まりの週の開始が日曜0時ではない: %v", got)
+	}
+}
+
+// TestGoalsConfig は削減目標の設定・削除のテスト
+func TestGoalsConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := SetGoal("twitter.com 20/week"); err != nil {
+		t.Fatalf("SetGoal失敗: %v", err)
+	}
+	if err := SetGoal("twitter.com 10/week"); err != nil {
+		t.Fatalf("SetGoal失敗: %v", err)
+	}
+	if err := SetGoal("youtube.com 3/day"); err != nil {
+		t.Fatalf("SetGoal失敗: %v", err)
+	}
+	goals, err := LoadGoals()
+	if err != nil {
+		t.Fatalf("LoadGoals失敗: %v", err)
+	}
+	if len(goals) != 2 || goals[0].Limit != 10 {
+		t.Errorf("同じドメイン・期間の目標は上書きされるはず: %+v", goals)
+	}
+
+	if err := RemoveGoal("Twitter.com"); err != nil {
+		t.Fatalf("RemoveGoal失敗: %v", err)
+	}
+	goals, err = LoadGoals()
+	if err != nil {
+		t.Fatalf("LoadGoals失敗: %v", err)
+	}
+	if len(goals) != 1 || goals[0].Domain != "youtube.com" {
+		t.Errorf("削除後の目標が期待値と異なる: %+v", goals)
+	}
+}
+
+// TestGetGoalProgress は目標の進捗集計のテスト
+func TestGetGoalProgress(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// テストデータ: github.com は 1/1 と 1/2 の10時、youtube.com は 1/1 と 1/2 の11時
+	goals := []Goal{
+		{Domain: "github.com", Limit: 1, Period: GoalPeriodWeek},
+		{Domain: "youtube.com", Limit: 1, Period: GoalPeriodDay},
+	}
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	progress, err := getGoalProgress(db, goals, 2, now, defaultCalendar)
+	if err != nil {
+		t.Fatalf("getGoalProgress失敗: %v", err)
+	}
+
+	github := progress[0]
+	if github.Current.Count != 2 || github.Current.Met {
+		t.Errorf("github.com の今週の進捗が期待値と異なる: %+v", github.Current)
+	}
+	if len(github.History) != 2 || github.History[0].Count != 0 || !github.History[1].Met || github.MetRate != 100 {
+		t.Errorf("github.com の過去の達成状況が期待値と異なる: %+v", github)
+	}
+
+	youtube := progress[1]
+	if youtube.Current.Count != 1 || !youtube.Current.Met {
+		t.Errorf("youtube.com の今日の進捗が期待値と異なる: %+v", youtube.Current)
+	}
+	if youtube.History[1].Count != 1 || youtube.History[0].Count != 0 {
+		t.Errorf("youtube.com の過去の訪問数が期待値と異なる: %+v", youtube.History)
+	}
+
+	var buf bytes.Buffer
+	printGoalProgress(&buf, progress)
+	out := buf.String()
+	for _, want := range []string{"github.com  1回まで/週", "超過 +1", "達成 2/2", "youtube.com  1回まで/日", "残り0回"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれない:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// -group-by で指定できる集計の軸
+const (
+	GroupByDomain   = "domain"
+	GroupByCategory = "category"
+	GroupBySource   = "source"
+	GroupByWeekday  = "weekday"
+	GroupByHour     = "hour"
+)
+
+// groupByNames は -group-by に指定できる軸
+var groupByNames = []string{GroupByDomain, GroupByCategory, GroupBySource, GroupByWeekday, GroupByHour}
+
+// groupByLabels は軸ごとの表の見出し
+var groupByLabels = map[string]string{
+	GroupByDomain:   "ドメイン",
+	GroupByCategory: "カテゴリ",
+	GroupBySource:   "ソース",
+	GroupByWeekday:  "曜日",
+	GroupByHour:     "時間帯",
+}
+
+// groupOtherLabel は -domains の件数に入らなかったドメインをまとめたグループ
+const groupOtherLabel = "その他"
+
+// groupVisit はグループに振り分ける訪問1件
+type groupVisit struct {
+	id   int64
+	url  string
+	time time.Time
+}
+
+// groupDimension は -group-by の軸1つ
+type groupDimension struct {
+	name string
+	// keys は全てのグループを決まった順に並べる軸（曜日・時間帯）のグループ。nil なら訪問数の多い順に並べる
+	keys []string
+	// limit は訪問数の多い順に並べる軸で残すグループ数（0 なら全て、残らなかったグループは「その他」にまとめる）
+	limit int
+	// daily は日別の内訳も集計するか（曜日・時間帯は1日の中で分かれるため集計しない）
+	daily bool
+	keyOf func(v groupVisit) string
+}
+
+// groupOptions は訪問をグループに振り分けるための設定
+type groupOptions struct {
+	sources       *visitSources
+	categories    []DomainCategory
+	redactDomains []string
+	domainLimit   int
+}
+
+// newGroupDimension は -group-by の軸を作成する
+func newGroupDimension(name string, opts groupOptions) (groupDimension, error) {
+	dim := groupDimension{name: name}
+	switch name {
+	case GroupByDomain:
+		dim.limit = opts.domainLimit
+		dim.daily = true
+		dim.keyOf = func(v groupVisit) string {
+			domain := extractDomain(v.url)
+			if domainMatchesList(domain, opts.redactDomains) {
+				return redactedLabel
+			}
+			return domain
+		}
+	case GroupByCategory:
+		dim.daily = true
+		dim.keyOf = func(v groupVisit) string {
+			return categoryForDomain(extractDomain(v.url), opts.categories)
+		}
+	case GroupBySource:
+		dim.daily = true
+		dim.keyOf = func(v groupVisit) string {
+			return opts.sources.sourceOf(v.id)
+		}
+	case GroupByWeekday:
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			dim.keys = append(dim.keys, weekdayLabel(d))
+		}
+		dim.keyOf = func(v groupVisit) string {
+			return weekdayLabel(v.time.Weekday())
+		}
+	case GroupByHour:
+		for h := range 24 {
+			dim.keys = append(dim.keys, hourLabel(h))
+		}
+		dim.keyOf = func(v groupVisit) string {
+			return hourLabel(v.time.Hour())
+		}
+	default:
+		return groupDimension{}, fmt.Errorf("-group-by に指定できるのは %s です: %s", strings.Join(groupByNames, "・"), name)
+	}
+	return dim, nil
+}
+
+// hourLabel は時間帯のグループ名
+func hourLabel(hour int) string {
+	return fmt.Sprintf("%02d:00", hour)
+}
+
+// GroupTotal はグループ1つの訪問数と、同じ期間の全ての訪問に対する割合（%）
+type GroupTotal struct {
+	Group      string  `json:"group"`
+	VisitCount int     `json:"visit_count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// GroupDayStats は1日のグループごとの訪問数（Groups は GroupReport.Groups と同じ順で、訪問のないグループも含む）
+type GroupDayStats struct {
+	Date       string       `json:"date"`
+	VisitCount int          `json:"visit_count"`
+	Groups     []GroupTotal `json:"groups"`
+}
+
+// GroupReport は -group-by の結果（軸によらず同じ形）
+// Groups は期間全体のグループごとの訪問数（曜日・時間帯は決まった順、それ以外は多い順）、
+// Daily は日別の内訳（新しい順、曜日・時間帯では省略）
+type GroupReport struct {
+	GroupBy string          `json:"group_by"`
+	Days    int             `json:"days"`
+	Groups  []GroupTotal    `json:"groups"`
+	Daily   []GroupDayStats `json:"daily,omitempty"`
+}
+
+// empty は期間内に訪問がないかを返す
+func (r *GroupReport) empty() bool {
+	for _, g := range r.Groups {
+		if g.VisitCount > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// groupVisitsBaseQuery はフィルタに一致する訪問のID・時刻・URLを取得するクエリ
+const groupVisitsBaseQuery = `
+	SELECT hv.id, hv.visit_time, hi.url FROM history_visits hv
+	JOIN history_items hi ON hv.history_item = hi.id
+	WHERE 1=1`
+
+// getGroupStats は now までの過去N日間の訪問を dim のグループごと（と日ごと）に数える
+func getGroupStats(db dbQuerier, dim groupDimension, days int, now time.Time, filter SearchFilter) (*GroupReport, error) {
+	query, args := NewQueryBuilder(groupVisitsBaseQuery).WithFilter(filter).Build()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("グループ別統計の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	cutoff := now.AddDate(0, 0, -days)
+	totals := make(map[string]int)
+	daily := make(map[string]map[string]int)
+	for rows.Next() {
+		var v groupVisit
+		var visitTime float64
+		if err := rows.Scan(&v.id, &visitTime, &v.url); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		v.time = convertCoreDataTimestamp(visitTime)
+		if !v.time.After(cutoff) {
+			continue
+		}
+		key := dim.keyOf(v)
+		totals[key]++
+		if dim.daily {
+			date := v.time.Format(TimeFormatDate)
+			if daily[date] == nil {
+				daily[date] = make(map[string]int)
+			}
+			daily[date][key]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	order, fold := dim.groupOrder(totals)
+	report := &GroupReport{GroupBy: dim.name, Days: days, Groups: groupTotals(fold(totals), order)}
+	for date, counts := range daily {
+		day := GroupDayStats{Date: date, Groups: groupTotals(fold(counts), order)}
+		for _, g := range day.Groups {
+			day.VisitCount += g.VisitCount
+		}
+		report.Daily = append(report.Daily, day)
+	}
+	sort.Slice(report.Daily, func(i, j int) bool {
+		return report.Daily[i].Date > report.Daily[j].Date
+	})
+	return report, nil
+}
+
+// groupOrder は期間全体の訪問数からグループの並び順と、残らなかったグループを「その他」にまとめる関数を返す
+func (dim groupDimension) groupOrder(totals map[string]int) ([]string, func(map[string]int) map[string]int) {
+	noFold := func(counts map[string]int) map[string]int { return counts }
+	if dim.keys != nil {
+		return dim.keys, noFold
+	}
+
+	order := make([]string, 0, len(totals))
+	for key := range totals {
+		order = append(order, key)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if totals[order[i]] != totals[order[j]] {
+			return totals[order[i]] > totals[order[j]]
+		}
+		return order[i] < order[j]
+	})
+	if dim.limit <= 0 || len(order) <= dim.limit {
+		return order, noFold
+	}
+
+	kept := make(map[string]bool, dim.limit)
+	for _, key := range order[:dim.limit] {
+		kept[key] = true
+	}
+	fold := func(counts map[string]int) map[string]int {
+		folded := make(map[string]int, dim.limit+1)
+		for key, c := range counts {
+			if !kept[key] {
+				key = groupOtherLabel
+			}
+			folded[key] += c
+		}
+		return folded
+	}
+	return append(order[:dim.limit:dim.limit], groupOtherLabel), fold
+}
+
+// groupTotals は order の順にグループごとの訪問数と割合を並べる（訪問のないグループは0件）
+func groupTotals(counts map[string]int, order []string) []GroupTotal {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	totals := make([]GroupTotal, 0, len(order))
+	for _, key := range order {
+		g := GroupTotal{Group: key, VisitCount: counts[key]}
+		if total > 0 {
+			g.Percentage = float64(g.VisitCount) / float64(total) * 100
+		}
+		totals = append(totals, g)
+	}
+	return totals
+}
+
+// groupCell は日別の表の1マス（訪問数と割合）
+func groupCell(g GroupTotal) string {
+	return fmt.Sprintf("%d (%.1f%%)", g.VisitCount, g.Percentage)
+}
+
+// groupTableRows は -group-by の表の見出し・行・右揃えにする列を返す
+// 日別の内訳があればグループを列に並べて日ごとの行（1行目は期間全体）に、なければグループごとの行にする
+func groupTableRows(report GroupReport) ([]string, [][]string, []int) {
+	if len(report.Daily) == 0 {
+		var rows [][]string
+		for _, g := range report.Groups {
+			rows = append(rows, []string{g.Group, fmt.Sprintf("%d", g.VisitCount), fmt.Sprintf("%.1f%%", g.Percentage)})
+		}
+		return []string{groupByLabels[report.GroupBy], "訪問数", "割合"}, rows, []int{1, 2}
+	}
+
+	headers := []string{"日付"}
+	rightAligned := make([]int, 0, len(report.Groups)+1)
+	total := 0
+	for i, g := range report.Groups {
+		headers = append(headers, g.Group)
+		rightAligned = append(rightAligned, i+1)
+		total += g.VisitCount
+	}
+	headers = append(headers, "合計")
+	rightAligned = append(rightAligned, len(report.Groups)+1)
+
+	row := func(label string, groups []GroupTotal, count int) []string {
+		cells := []string{label}
+		for _, g := range groups {
+			cells = append(cells, groupCell(g))
+		}
+		return append(cells, fmt.Sprintf("%d", count))
+	}
+	rows := [][]string{row("合計", report.Groups, total)}
+	for _, d := range report.Daily {
+		rows = append(rows, row(d.Date, d.Groups, d.VisitCount))
+	}
+	return headers, rows, rightAligned
+}
+
+// groupReportTitle は -group-by の見出し
+func groupReportTitle(report GroupReport) string {
+	if len(report.Daily) > 0 {
+		return fmt.Sprintf("🧭 %s別の日別訪問数 (過去%d日間)", groupByLabels[report.GroupBy], report.Days)
+	}
+	return fmt.Sprintf("🧭 %s別の訪問数 (過去%d日間)", groupByLabels[report.GroupBy], report.Days)
+}
+
+// printGroupReport は -group-by の結果を表にして表示する
+func printGroupReport(w io.Writer, report GroupReport) {
+	_, _ = fmt.Fprintln(w, groupReportTitle(report))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	headers, rows, rightAligned := groupTableRows(report)
+	_, _ = fmt.Fprintln(w, renderTable(headers, rows, rightAligned, false))
+	_, _ = fmt.Fprintln(w)
+}
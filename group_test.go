@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetGroupStats(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+	opts := groupOptions{
+		sources:       &visitSources{label: "chrome", devices: map[int64]string{1: "safari", 2: "safari", 4: "safari"}},
+		categories:    []DomainCategory{{Domain: "github.com", Category: "開発"}},
+		redactDomains: []string{"google.com"},
+		domainLimit:   1,
+	}
+	groups := func(report *GroupReport) string {
+		var cells []string
+		for _, g := range report.Groups {
+			cells = append(cells, g.Group+"="+groupCell(g))
+		}
+		return strings.Join(cells, " ")
+	}
+
+	for _, tt := range []struct {
+		groupBy string
+		want    string
+		daily   bool
+	}{
+		// 上位 -domains 件より後のドメインは「その他」、伏せたドメインはまとめて数える
+		{GroupByDomain, "github.com=2 (40.0%) その他=3 (60.0%)", true},
+		{GroupByCategory, "未分類=3 (60.0%) 開発=2 (40.0%)", true},
+		{GroupBySource, "safari=3 (60.0%) chrome=2 (40.0%)", true},
+		// 曜日・時間帯は訪問のないグループも決まった順に並べる
+		{GroupByWeekday, "日曜=0 (0.0%) 月曜=0 (0.0%) 火曜=0 (0.0%) 水曜=3 (60.0%) 木曜=2 (40.0%) 金曜=0 (0.0%) 土曜=0 (0.0%)", false},
+	} {
+		dim, err := newGroupDimension(tt.groupBy, opts)
+		if err != nil {
+			t.Fatalf("newGroupDimension(%q): %v", tt.groupBy, err)
+		}
+		report, err := getGroupStats(db, dim, 7, now, SearchFilter{})
+		if err != nil {
+			t.Fatalf("getGroupStats(%q): %v", tt.groupBy, err)
+		}
+		if got := groups(report); got != tt.want {
+			t.Errorf("-group-by %s = %s, want %s", tt.groupBy, got, tt.want)
+		}
+		if (len(report.Daily) > 0) != tt.daily {
+			t.Errorf("-group-by %s の日別の内訳 = %+v", tt.groupBy, report.Daily)
+		}
+	}
+
+	dim, _ := newGroupDimension(GroupByHour, opts)
+	report, err := getGroupStats(db, dim, 7, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getGroupStats: %v", err)
+	}
+	if len(report.Groups) != 24 || report.Groups[10].Group != "10:00" || report.Groups[10].VisitCount != 2 || report.Groups[12].VisitCount != 1 {
+		t.Errorf("-group-by hour = %+v", report.Groups)
+	}
+
+	// 日別は新しい順で、訪問のないグループも0件で並べる（グループの順は期間全体と同じ）
+	dim, _ = newGroupDimension(GroupBySource, opts)
+	report, err = getGroupStats(db, dim, 7, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getGroupStats: %v", err)
+	}
+	if len(report.Daily) != 2 || report.Daily[0].Date != "2025-01-02" || report.Daily[1].Date != "2025-01-01" {
+		t.Fatalf("日別 = %+v", report.Daily)
+	}
+	first := report.Daily[1]
+	if first.VisitCount != 3 || first.Groups[0].VisitCount != 2 || first.Groups[1].Group != "chrome" || first.Groups[1].VisitCount != 1 {
+		t.Errorf("2025-01-01 = %+v", first)
+	}
+
+	// 期間外の訪問は数えない
+	report, err = getGroupStats(db, dim, 1, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getGroupStats: %v", err)
+	}
+	if len(report.Daily) != 1 || report.Daily[0].VisitCount != 2 {
+		t.Errorf("過去1日間 = %+v", report.Daily)
+	}
+
+	if _, err := newGroupDimension("browser", opts); err == nil {
+		t.Error("不正な軸でエラーにならない")
+	}
+}
+
+func TestAnalyzeGroupBy(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	config := Config{
+		Limit:       10,
+		DomainLimit: 10,
+		Days:        7,
+		ShowHistory: true,
+		Source:      "chrome",
+		GroupBy:     GroupBySource,
+		Now:         time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	result, err := analyze(db, config)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, v := range result.RecentVisits {
+		if v.Source != "chrome" {
+			t.Errorf("訪問 %d のソース = %q", v.ID, v.Source)
+		}
+	}
+	if result.GroupStats == nil || len(result.GroupStats.Groups) != 1 || result.GroupStats.Groups[0].Percentage != 100 {
+		t.Fatalf("GroupStats = %+v", result.GroupStats)
+	}
+
+	var text bytes.Buffer
+	printGroupReport(&text, *result.GroupStats)
+	if !strings.Contains(text.String(), "ソース別の日別訪問数 (過去7日間)") || !strings.Contains(text.String(), "5 (100.0%)") {
+		t.Errorf("テキスト出力:\n%s", text.String())
+	}
+
+	var quiet bytes.Buffer
+	printQuietOutput(&quiet, result, true, false, false, false, false)
+	if !strings.Contains(quiet.String(), "2025-01-02\tchrome\t2\n") {
+		t.Errorf("-quiet の出力:\n%s", quiet.String())
+	}
+
+	var csv bytes.Buffer
+	if err := writeCSV(&csv, result, true, false, false, false, false, ','); err != nil {
+		t.Fatalf("writeCSV: %v", err)
+	}
+	for _, want := range []string{"source,chrome,5,100.0", "2025-01-01,chrome,3,100.0", "https://github.com/test,chrome"} {
+		if !strings.Contains(csv.String(), want) {
+			t.Errorf("CSV の出力に %q がない:\n%s", want, csv.String())
+		}
+	}
+
+	// 日別の内訳がない軸はグループごとの行にする
+	config.GroupBy = GroupByWeekday
+	result, err = analyze(db, config)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	headers, rows, _ := groupTableRows(*result.GroupStats)
+	if headers[0] != "曜日" || len(rows) != 7 || rows[3][0] != "水曜" || rows[3][1] != "3" {
+		t.Errorf("曜日別の表 = %v %v", headers, rows)
+	}
+
+	// -anonymize=strict ではドメインのグループ名もハッシュ化する
+	config.GroupBy = GroupByDomain
+	result, err = analyze(db, config)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	anonymized := anonymizeResult(result, AnonymizeStrict)
+	if g := anonymized.GroupStats.Groups[0]; g.Group != hashValue("github.com") || g.VisitCount != 2 {
+		t.Errorf("匿名化したグループ = %+v", g)
+	}
+	if result.GroupStats.Groups[0].Group != "github.com" {
+		t.Errorf("匿名化で元の結果が変わった: %+v", result.GroupStats.Groups[0])
+	}
+}
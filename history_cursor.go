@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HistoryCursor は /api/history のキーセット・ページングで次のページの開始位置を表す
+// 新しい順に並べたときの直前の訪問の visit_time（Core Data 秒）と history_visits.id を持つ
+// オフセットと違い、Safari が新しい訪問を書き込んでも同じ訪問が重複・欠落しない
+type HistoryCursor struct {
+	VisitTime float64
+	ID        int64
+}
+
+// HistoryPage はカーソル指定時の /api/history のレスポンス
+// NextCursor は続きがない場合は空
+type HistoryPage struct {
+	Visits     []HistoryVisit `json:"visits"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// parseHistoryCursor は "<visit_time>,<id>" 形式のカーソルを解析する
+func parseHistoryCursor(s string) (HistoryCursor, error) {
+	timeStr, idStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return HistoryCursor{}, fmt.Errorf("カーソルは \"<visit_time>,<id>\" 形式で指定してください: %s", s)
+	}
+	visitTime, err := strconv.ParseFloat(timeStr, 64)
+	if err != nil {
+		return HistoryCursor{}, fmt.Errorf("カーソルの visit_time が不正です: %s", timeStr)
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return HistoryCursor{}, fmt.Errorf("カーソルの id が不正です: %s", idStr)
+	}
+	return HistoryCursor{VisitTime: visitTime, ID: id}, nil
+}
+
+// String はカーソルを "<visit_time>,<id>" 形式で返す（visit_time は元の値に戻せる桁数で出す）
+func (c HistoryCursor) String() string {
+	return strconv.FormatFloat(c.VisitTime, 'f', -1, 64) + "," + strconv.FormatInt(c.ID, 10)
+}
+
+// getVisitsPage はカーソルより後（古い側）の訪問を新しい順に最大 limit 件返す
+// cursor が nil の場合は最新から返す。続きがあれば次のページのカーソルも返す
+func getVisitsPage(db dbQuerier, limit int, cursor *HistoryCursor, filter SearchFilter) (HistoryPage, error) {
+	qb := NewQueryBuilder(historyBaseQuery).WithFilter(filter)
+	if cursor != nil {
+		qb.WithRawCondition("hv.visit_time < ? OR (hv.visit_time = ? AND hv.id < ?)", cursor.VisitTime, cursor.VisitTime, cursor.ID)
+	}
+	// 同じ時刻の訪問も順序が決まるよう id でも並べ、続きがあるかを知るため1件多く取得する
+	query, args := qb.OrderByDesc("hv.visit_time DESC, hv.id").Limit(limit + 1).Build()
+	visits, err := executeHistoryQuery(db, query, args)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	page := HistoryPage{Visits: visits}
+	if len(visits) <= limit {
+		return page, nil
+	}
+	page.Visits = visits[:limit]
+
+	// HistoryVisit の時刻は変換済みなので、カーソルには DB の値をそのまま使う
+	last := page.Visits[limit-1]
+	next := HistoryCursor{ID: last.ID}
+	err = db.QueryRow(`SELECT visit_time FROM history_visits WHERE id = ?`, last.ID).Scan(&next.VisitTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return page, nil
+	}
+	if err != nil {
+		return HistoryPage{}, fmt.Errorf("カーソルの取得に失敗: %w", err)
+	}
+	page.NextCursor = next.String()
+	return page, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseHistoryCursor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    HistoryCursor
+		wantErr bool
+	}{
+		{"757418400.123456,42", HistoryCursor{VisitTime: 757418400.123456, ID: 42}, false},
+		{"757418400,1", HistoryCursor{VisitTime: 757418400, ID: 1}, false},
+		{"757418400", HistoryCursor{}, true},
+		{"abc,1", HistoryCursor{}, true},
+		{"757418400,0", HistoryCursor{}, true},
+		{"757418400,x", HistoryCursor{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseHistoryCursor(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHistoryCursor(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHistoryCursor(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+			if !tt.wantErr && got.String() != tt.in {
+				t.Errorf("String() = %q, want %q", got.String(), tt.in)
+			}
+		})
+	}
+}
+
+func TestGetVisitsPage(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 最新の訪問と同じ時刻の訪問を足し、同時刻でも id で順序が決まることを確かめる
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 3, ?, 'Google Again')`, 757418400.0+90000); err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	var ids []int64
+	var cursor *HistoryCursor
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("ページングが終わらない")
+		}
+		page, err := getVisitsPage(db, 2, cursor, SearchFilter{})
+		if err != nil {
+			t.Fatalf("getVisitsPage失敗: %v", err)
+		}
+		for _, v := range page.Visits {
+			ids = append(ids, v.ID)
+		}
+
+		if pages == 0 {
+			// 1ページ目を読んだ後に新しい訪問が書き込まれても、続きのページはずれない
+			if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (7, 1, ?, 'Newest')`, 757418400.0+200000); err != nil {
+				t.Fatalf("テストデータ挿入に失敗: %v", err)
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		c, err := parseHistoryCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("next_cursor が解析できない: %v", err)
+		}
+		cursor = &c
+	}
+
+	want := []int64{6, 5, 4, 3, 2, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("ids = %v, want %v", ids, want)
+			break
+		}
+	}
+}
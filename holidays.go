@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// holidaysFileName は祝日・休日の設定ファイル
+// 1行に次のどちらかを書く:
+//
+//	jp                     組み込みの日本の祝日（2000年以降）を使う
+//	2025-12-29 年末休暇    日付と名前（名前は省略可）を休日として追加する
+const holidaysFileName = "holidays.txt"
+
+// holidaySetJapan は組み込みの日本の祝日を使う行
+const holidaySetJapan = "jp"
+
+// Holidays は休日として扱う日（nil なら休日なし）
+type Holidays struct {
+	// Japan は組み込みの日本の祝日を使うか
+	Japan bool
+	// dates は holidays.txt で追加した日付（YYYY-MM-DD）と名前
+	dates map[string]string
+}
+
+// parseHolidays は holidays.txt の行を解析する（行がなければ nil）
+func parseHolidays(lines []string) (*Holidays, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	h := &Holidays{dates: make(map[string]string)}
+	for _, line := range lines {
+		if strings.EqualFold(line, holidaySetJapan) {
+			h.Japan = true
+			continue
+		}
+		date, name, _ := strings.Cut(line, " ")
+		if _, err := time.Parse(TimeFormatDate, date); err != nil {
+			return nil, fmt.Errorf("休日は「YYYY-MM-DD [名前]」形式か %s で指定してください: %s", holidaySetJapan, line)
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "休日"
+		}
+		h.dates[date] = name
+	}
+	return h, nil
+}
+
+// LoadHolidays は祝日・休日の設定を読み込む（設定がなければ nil）
+func LoadHolidays() (*Holidays, error) {
+	lines, err := loadDomainListFile(holidaysFileName, "休日一覧")
+	if err != nil {
+		return nil, err
+	}
+	return parseHolidays(lines)
+}
+
+// name は t の日付が休日ならその名前を返す（holidays.txt の日付を組み込みの祝日より優先する）
+func (h *Holidays) name(t time.Time) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	if name, ok := h.dates[t.Format(TimeFormatDate)]; ok {
+		return name, true
+	}
+	if h.Japan {
+		return japaneseHoliday(t)
+	}
+	return "", false
+}
+
+// isDayOff は土曜・日曜か休日かを返す
+func (h *Holidays) isDayOff(t time.Time) bool {
+	if isWeekend(t) {
+		return true
+	}
+	_, ok := h.name(t)
+	return ok
+}
+
+// annotateDaily は日別統計の休日に名前を付ける
+func (h *Holidays) annotateDaily(stats []DailyStats) {
+	for i, s := range stats {
+		day, err := time.Parse(TimeFormatDate, s.Date)
+		if err != nil {
+			continue
+		}
+		stats[i].Holiday, _ = h.name(day)
+	}
+}
+
+// japaneseHoliday は t の日付が日本の祝日・振替休日・国民の休日ならその名前を返す
+// 祝日法の2000年以降の規定（ハッピーマンデー、2019〜2021年の特例を含む）に従う
+func japaneseHoliday(t time.Time) (string, bool) {
+	y, m, d := t.Date()
+	if name, ok := japaneseNationalHoliday(y, m, d); ok {
+		return name, true
+	}
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	// 振替休日: 日曜の祝日の後、最初の祝日でない日（2006年までは翌月曜のみ）
+	for prev := day.AddDate(0, 0, -1); ; prev = prev.AddDate(0, 0, -1) {
+		if _, ok := japaneseNationalHoliday(prev.Date()); !ok {
+			break
+		}
+		if prev.Weekday() == time.Sunday {
+			if y >= 2007 || prev.Equal(day.AddDate(0, 0, -1)) {
+				return "振替休日", true
+			}
+			break
+		}
+	}
+
+	// 国民の休日: 前日と翌日が祝日の平日
+	_, before := japaneseNationalHoliday(day.AddDate(0, 0, -1).Date())
+	_, after := japaneseNationalHoliday(day.AddDate(0, 0, 1).Date())
+	if before && after && day.Weekday() != time.Sunday {
+		return "国民の休日", true
+	}
+	return "", false
+}
+
+// japaneseNationalHoliday は祝日法で定める「国民の祝日」（振替休日・国民の休日を除く）の名前を返す
+func japaneseNationalHoliday(y int, m time.Month, d int) (string, bool) {
+	if y < 2000 || y > 2099 {
+		return "", false
+	}
+	// 第n月曜日かどうか
+	nthMonday := func(n int) bool {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Weekday() == time.Monday && (d-1)/7 == n-1
+	}
+	switch m {
+	case time.January:
+		if d == 1 {
+			return "元日", true
+		}
+		if nthMonday(2) {
+			return "成人の日", true
+		}
+	case time.February:
+		if d == 11 {
+			return "建国記念の日", true
+		}
+		if d == 23 && y >= 2020 {
+			return "天皇誕生日", true
+		}
+	case time.March:
+		if d == vernalEquinoxDay(y) {
+			return "春分の日", true
+		}
+	case time.April:
+		if d == 29 {
+			if y >= 2007 {
+				return "昭和の日", true
+			}
+			return "みどりの日", true
+		}
+		if d == 30 && y == 2019 {
+			return "国民の休日", true
+		}
+	case time.May:
+		switch {
+		case d == 1 && y == 2019:
+			return "天皇の即位の日", true
+		case d == 2 && y == 2019:
+			return "国民の休日", true
+		case d == 3:
+			return "憲法記念日", true
+		case d == 4 && y >= 2007:
+			return "みどりの日", true
+		case d == 5:
+			return "こどもの日", true
+		}
+	case time.July:
+		switch y {
+		case 2020:
+			if d == 23 {
+				return "海の日", true
+			}
+			if d == 24 {
+				return "スポーツの日", true
+			}
+		case 2021:
+			if d == 22 {
+				return "海の日", true
+			}
+			if d == 23 {
+				return "スポーツの日", true
+			}
+		default:
+			if y >= 2003 && nthMonday(3) || y < 2003 && d == 20 {
+				return "海の日", true
+			}
+		}
+	case time.August:
+		if y == 2020 && d == 10 || y == 2021 && d == 8 || y >= 2016 && y != 2020 && y != 2021 && d == 11 {
+			return "山の日", true
+		}
+	case time.September:
+		if y >= 2003 && nthMonday(3) || y < 2003 && d == 15 {
+			return "敬老の日", true
+		}
+		if d == autumnalEquinoxDay(y) {
+			return "秋分の日", true
+		}
+	case time.October:
+		if d == 22 && y == 2019 {
+			return "即位礼正殿の儀の行われる日", true
+		}
+		if nthMonday(2) && y != 2020 && y != 2021 {
+			if y >= 2020 {
+				return "スポーツの日", true
+			}
+			return "体育の日", true
+		}
+	case time.November:
+		if d == 3 {
+			return "文化の日", true
+		}
+		if d == 23 {
+			return "勤労感謝の日", true
+		}
+	case time.December:
+		if d == 23 && y <= 2018 {
+			return "天皇誕生日", true
+		}
+	}
+	return "", false
+}
+
+// vernalEquinoxDay は春分の日（3月の日）を近似式で求める（1980〜2099年で有効）
+func vernalEquinoxDay(y int) int {
+	return int(20.8431+0.242194*float64(y-1980)) - (y-1980)/4
+}
+
+// autumnalEquinoxDay は秋分の日（9月の日）を近似式で求める（1980〜2099年で有効）
+func autumnalEquinoxDay(y int) int {
+	return int(23.2488+0.242194*float64(y-1980)) - (y-1980)/4
+}
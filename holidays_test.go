@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJapaneseHoliday(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2025-01-01", "元日"},
+		{"2025-01-13", "成人の日"},
+		{"2025-02-24", "振替休日"},
+		{"2025-03-20", "春分の日"},
+		{"2025-05-06", "振替休日"},
+		{"2025-07-21", "海の日"},
+		{"2025-08-11", "山の日"},
+		{"2025-09-15", "敬老の日"},
+		{"2025-09-23", "秋分の日"},
+		{"2025-10-13", "スポーツの日"},
+		{"2025-11-24", "振替休日"},
+		{"2026-09-22", "国民の休日"},
+		{"2019-05-01", "天皇の即位の日"},
+		{"2019-04-30", "国民の休日"},
+		{"2018-12-23", "天皇誕生日"},
+		{"2015-10-12", "体育の日"},
+		{"2020-07-24", "スポーツの日"},
+		{"2021-08-09", "振替休日"},
+		{"2025-01-02", ""},
+		{"2025-12-23", ""},
+		{"2021-08-11", ""},
+		{"2020-10-12", ""},
+	}
+	for _, tt := range tests {
+		day, _ := time.Parse(TimeFormatDate, tt.date)
+		got, ok := japaneseHoliday(day)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("japaneseHoliday(%s) = %q, %v, want %q", tt.date, got, ok, tt.want)
+		}
+	}
+}
+
+func TestParseHolidays(t *testing.T) {
+	h, err := parseHolidays(nil)
+	if err != nil || h != nil {
+		t.Errorf("parseHolidays(nil) = %v, %v, want nil", h, err)
+	}
+	if _, ok := h.name(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); ok {
+		t.Error("設定がないのに休日になった")
+	}
+
+	h, err = parseHolidays([]string{"jp", "2025-12-29 年末休暇", "2025-01-01 お正月", "2025-08-15"})
+	if err != nil {
+		t.Fatalf("parseHolidays() error = %v", err)
+	}
+	for date, want := range map[string]string{
+		"2025-12-29": "年末休暇",
+		"2025-01-01": "お正月",
+		"2025-08-15": "休日",
+		"2025-11-03": "文化の日",
+		"2025-12-30": "",
+	} {
+		day, _ := time.Parse(TimeFormatDate, date)
+		if got, _ := h.name(day); got != want {
+			t.Errorf("name(%s) = %q, want %q", date, got, want)
+		}
+	}
+
+	if _, err := parseHolidays([]string{"12/29 年末休暇"}); err == nil {
+		t.Error("不正な日付でエラーにならない")
+	}
+}
+
+func TestAnnotateDaily(t *testing.T) {
+	stats := []DailyStats{{Date: "2025-01-02", VisitCount: 2}, {Date: "2025-01-01", VisitCount: 3}}
+	(&Holidays{Japan: true}).annotateDaily(stats)
+	if stats[0].Holiday != "" || stats[1].Holiday != "元日" {
+		t.Errorf("annotateDaily() = %+v", stats)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hooksFileName はイベントごとに実行するコマンドの設定ファイル
+// 1行に「イベント = コマンド」を書く（同じイベントに複数書くと上から順に実行する）:
+//
+//	on_new_visit = ~/bin/journal-visit >> ~/journal/visits.log
+//	on_report_generated = shortcuts run "履歴レポート"
+//
+// コマンドは sh -c で実行し、標準入力でイベントの内容を JSON で受け取る
+const hooksFileName = "hooks.txt"
+
+// フックのイベント
+const (
+	// HookOnNewVisit は hist watch が新しい訪問を見つけたとき（訪問1件ごと）
+	HookOnNewVisit = "on_new_visit"
+	// HookOnReportGenerated は -output のファイルを書き出したとき（1回の実行ごと）
+	HookOnReportGenerated = "on_report_generated"
+)
+
+// hookEvents はフックを設定できるイベント
+var hookEvents = []string{HookOnNewVisit, HookOnReportGenerated}
+
+// hookTimeout はフックのコマンド1つの実行時間の上限
+const hookTimeout = 30 * time.Second
+
+// Hooks はイベントごとに実行するコマンド
+type Hooks map[string][]string
+
+// parseHooks は hooks.txt の行を解析する
+func parseHooks(lines []string) (Hooks, error) {
+	hooks := make(Hooks)
+	for _, line := range lines {
+		event, command, ok := strings.Cut(line, "=")
+		event, command = strings.TrimSpace(event), strings.TrimSpace(command)
+		if !ok || command == "" {
+			return nil, fmt.Errorf("フックは「イベント = コマンド」形式で指定してください: %s", line)
+		}
+		known := false
+		for _, e := range hookEvents {
+			known = known || e == event
+		}
+		if !known {
+			return nil, fmt.Errorf("フックのイベントは %s のいずれかで指定してください: %s", strings.Join(hookEvents, "・"), event)
+		}
+		hooks[event] = append(hooks[event], command)
+	}
+	return hooks, nil
+}
+
+// LoadHooks はフックの設定を読み込む
+func LoadHooks() (Hooks, error) {
+	lines, err := loadDomainListFile(hooksFileName, "フックの設定")
+	if err != nil {
+		return nil, err
+	}
+	return parseHooks(lines)
+}
+
+// NewVisitHookPayload は on_new_visit のコマンドに渡す内容
+type NewVisitHookPayload struct {
+	Event string       `json:"event"`
+	Visit HistoryVisit `json:"visit"`
+}
+
+// ReportHookOutput は書き出したファイル1つ
+type ReportHookOutput struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+}
+
+// ReportHookPayload は on_report_generated のコマンドに渡す内容
+type ReportHookPayload struct {
+	Event       string             `json:"event"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Outputs     []ReportHookOutput `json:"outputs"`
+	// Result は書き出した分析結果（-json の出力と同じ）
+	Result AnalysisResult `json:"result"`
+}
+
+// run は event のコマンドを順に実行し、payload を JSON で標準入力に渡す
+// コマンドの標準出力・標準エラー出力は hist の標準エラー出力に流す（レポートの出力と混ざらないようにする）
+// 失敗したコマンドがあっても残りのコマンドは実行し、まとめてエラーを返す
+func (h Hooks) run(event string, payload any) error {
+	commands := h[event]
+	if len(commands) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("フックの内容のJSON変換に失敗: %w", err)
+	}
+	var errs []error
+	for _, command := range commands {
+		ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), HistEventEnv+"="+event)
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				err = fmt.Errorf("%s 以内に終わりませんでした", hookTimeout)
+			}
+			errs = append(errs, fmt.Errorf("フック %s（%s）が失敗しました: %w", event, command, err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// runReportHooks は -output のファイルを書き出した後に on_report_generated のフックを実行する
+// 標準出力だけに出力した場合は実行しない
+func runReportHooks(hooks Hooks, targets []OutputTarget, result AnalysisResult, now time.Time) error {
+	payload := ReportHookPayload{Event: HookOnReportGenerated, GeneratedAt: now, Result: result}
+	for _, t := range targets {
+		if t.Path != "" {
+			payload.Outputs = append(payload.Outputs, ReportHookOutput{Path: t.Path, Format: t.Format})
+		}
+	}
+	if len(payload.Outputs) == 0 {
+		return nil
+	}
+	return hooks.run(HookOnReportGenerated, payload)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHooks(t *testing.T) {
+	hooks, err := parseHooks([]string{
+		"on_new_visit = cat >> visits.log",
+		`on_report_generated = shortcuts run "履歴レポート"`,
+		"on_new_visit=echo done",
+	})
+	if err != nil {
+		t.Fatalf("parseHooks() error = %v", err)
+	}
+	if len(hooks[HookOnNewVisit]) != 2 || hooks[HookOnNewVisit][1] != "echo done" ||
+		hooks[HookOnReportGenerated][0] != `shortcuts run "履歴レポート"` {
+		t.Errorf("parseHooks() = %v", hooks)
+	}
+	for _, lines := range [][]string{{"on_new_visit"}, {"on_new_visit ="}, {"on_exit = echo"}} {
+		if _, err := parseHooks(lines); err == nil {
+			t.Errorf("parseHooks(%q) がエラーにならない", lines)
+		}
+	}
+}
+
+func TestHooksRun(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "hook.log")
+	hooks := Hooks{HookOnReportGenerated: {
+		"exit 3",
+		`printf '%s ' "$` + HistEventEnv + `" >> ` + log + ` && cat >> ` + log,
+	}}
+	targets := []OutputTarget{{Path: "", Format: OutputFormatText}, {Path: "report.json", Format: OutputFormatJSON}}
+	now := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)
+
+	// 失敗したコマンドがあっても残りのコマンドは実行する
+	err := runReportHooks(hooks, targets, AnalysisResult{TotalVisits: 5}, now)
+	if err == nil || !strings.Contains(err.Error(), "exit 3") {
+		t.Errorf("runReportHooks() error = %v, want exit 3 の失敗", err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, body, _ := strings.Cut(string(data), " ")
+	if event != HookOnReportGenerated {
+		t.Errorf("%s = %q, want %s", HistEventEnv, event, HookOnReportGenerated)
+	}
+	var payload ReportHookPayload
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("フックに渡した JSON = %q: %v", body, err)
+	}
+	if payload.Event != HookOnReportGenerated || !payload.GeneratedAt.Equal(now) || len(payload.Outputs) != 1 ||
+		payload.Outputs[0] != (ReportHookOutput{Path: "report.json", Format: OutputFormatJSON}) || payload.Result.TotalVisits != 5 {
+		t.Errorf("フックに渡した内容 = %+v", payload)
+	}
+
+	// 標準出力だけに出力した場合や、フックがない場合は実行しない
+	if err := runReportHooks(hooks, targets[:1], AnalysisResult{}, now); err != nil {
+		t.Errorf("標準出力だけの runReportHooks() error = %v", err)
+	}
+	if err := runReportHooks(nil, targets, AnalysisResult{}, now); err != nil {
+		t.Errorf("フックなしの runReportHooks() error = %v", err)
+	}
+}
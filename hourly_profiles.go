@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// sparkBlocks は時間帯の分布を1文字ずつの高さで表す文字
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// HourlyProfile はドメイン（カテゴリ別のプロファイルでは categories.txt のカテゴリ）ごとの時間帯別の訪問分布
+type HourlyProfile struct {
+	Domain     string  `json:"domain,omitempty"`
+	Category   string  `json:"category,omitempty"`
+	VisitCount int     `json:"visit_count"`
+	Hours      []int   `json:"hours"`
+	PeakHour   int     `json:"peak_hour"`
+	PeakShare  float64 `json:"peak_share"`
+}
+
+// Label はプロファイルの表示名（ドメインかカテゴリ）
+func (p HourlyProfile) Label() string {
+	if p.Category != "" {
+		return p.Category
+	}
+	return p.Domain
+}
+
+// getHourlyProfiles は訪問数の多い上位 limit 件のドメインについて、時間帯別の訪問分布を返す
+// limit が0以下の場合は全ドメインを返す
+func getHourlyProfiles(db dbQuerier, limit int, filter SearchFilter) ([]HourlyProfile, error) {
+	return collectHourlyProfiles(db, limit, filter, func(domain string) HourlyProfile {
+		return HourlyProfile{Domain: domain}
+	})
+}
+
+// getCategoryHourlyProfiles は categories.txt のカテゴリ（categoryForDomain、どれにも一致しなければ未分類）ごとの時間帯別の訪問分布を訪問数の多い順に返す
+// 「SNS は 22:00、仕事は 10:00 にピーク」のように、ドメインをまとめた単位で時間帯の傾向を比べる
+func getCategoryHourlyProfiles(db dbQuerier, filter SearchFilter, categories []DomainCategory) ([]HourlyProfile, error) {
+	byDomain := make(map[string]string)
+	return collectHourlyProfiles(db, 0, filter, func(domain string) HourlyProfile {
+		category, ok := byDomain[domain]
+		if !ok {
+			category = categoryForDomain(domain, categories)
+			byDomain[domain] = category
+		}
+		return HourlyProfile{Category: category}
+	})
+}
+
+// collectHourlyProfiles は訪問を group（ドメインから決めたプロファイルの表示名）ごとに時間帯別に数え、訪問数の多い上位 limit 件を返す
+func collectHourlyProfiles(db dbQuerier, limit int, filter SearchFilter, group func(domain string) HourlyProfile) ([]HourlyProfile, error) {
+	profiles := make(map[string]*HourlyProfile)
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		domain := extractDomain(v.URL)
+		if domain == "" {
+			return nil
+		}
+		g := group(domain)
+		p := profiles[g.Label()]
+		if p == nil {
+			p = &g
+			p.Hours = make([]int, 24)
+			profiles[g.Label()] = p
+		}
+		p.VisitCount++
+		p.Hours[v.VisitTime.Hour()]++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("時間帯プロファイルの集計に失敗: %w", err)
+	}
+
+	var result []HourlyProfile
+	for _, p := range profiles {
+		for hour, count := range p.Hours {
+			if count > p.Hours[p.PeakHour] {
+				p.PeakHour = hour
+			}
+		}
+		p.PeakShare = roundPercentage(float64(p.Hours[p.PeakHour]) / float64(p.VisitCount) * 100)
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].VisitCount != result[j].VisitCount {
+			return result[i].VisitCount > result[j].VisitCount
+		}
+		return result[i].Label() < result[j].Label()
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// redactHourlyProfiles はリダクト対象のドメイン名を伏せたコピーを返す
+func redactHourlyProfiles(profiles []HourlyProfile, redactDomains []string) []HourlyProfile {
+	if len(redactDomains) == 0 || profiles == nil {
+		return profiles
+	}
+	redacted := make([]HourlyProfile, len(profiles))
+	for i, p := range profiles {
+		if p.Domain != "" && domainMatchesList(p.Domain, redactDomains) {
+			p.Domain = redactedLabel
+		}
+		redacted[i] = p
+	}
+	return redacted
+}
+
+// sparkline は時間帯別の訪問数を最大値を基準にした24文字のグラフにする
+func sparkline(hours []int) string {
+	maxCount := 0
+	for _, c := range hours {
+		maxCount = max(maxCount, c)
+	}
+	var b strings.Builder
+	for _, c := range hours {
+		if c == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkBlocks[(c*len(sparkBlocks)-1)/maxCount])
+	}
+	return b.String()
+}
+
+// summarizePeaks は「github.com は 10:00、youtube.com は 22:00 にピーク」の形で各ドメイン（カテゴリ）のピークを1文にまとめる
+func summarizePeaks(profiles []HourlyProfile) string {
+	var parts []string
+	for _, p := range profiles {
+		parts = append(parts, fmt.Sprintf("%s は %02d:00", p.Label(), p.PeakHour))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "、") + " にピーク"
+}
+
+// hourlyProfilesTitle はプロファイルの見出し（ドメイン別かカテゴリ別か）
+func hourlyProfilesTitle(profiles []HourlyProfile) string {
+	if len(profiles) > 0 && profiles[0].Category != "" {
+		return fmt.Sprintf("🕰  カテゴリ別の時間帯プロファイル (%dカテゴリ)", len(profiles))
+	}
+	return fmt.Sprintf("🕰  ドメイン別の時間帯プロファイル (Top %d)", len(profiles))
+}
+
+// printHourlyProfiles はドメイン（カテゴリ）ごとの時間帯別の分布を1行ずつ並べて出力する
+func printHourlyProfiles(w io.Writer, profiles []HourlyProfile) {
+	_, _ = fmt.Fprintf(w, "%s\n", hourlyProfilesTitle(profiles))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	_, _ = fmt.Fprintf(w, "  %s %s\n", padRight("", 24), "0     6     12    18   23")
+	for _, p := range profiles {
+		_, _ = fmt.Fprintf(w, "  %s %s  ピーク %02d:00 (%.1f%%) / %d訪問\n",
+			padRight(truncateText(p.Label(), 24), 24), sparkline(p.Hours), p.PeakHour, p.PeakShare, p.VisitCount)
+	}
+	if summary := summarizePeaks(profiles); summary != "" {
+		_, _ = fmt.Fprintf(w, "\n  %s\n", summary)
+	}
+	_, _ = fmt.Fprintln(w)
+}
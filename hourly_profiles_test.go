@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetHourlyProfiles はドメインごとの時間帯プロファイルのテスト
+func TestGetHourlyProfiles(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	profiles, err := getHourlyProfiles(db, 2, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getHourlyProfiles失敗: %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("ドメイン数が期待値と異なる: %+v", profiles)
+	}
+
+	// テストデータは github.com が10時に2回、youtube.com が11時に2回
+	if profiles[0].Domain != "github.com" || profiles[0].PeakHour != 10 || profiles[0].PeakShare != 100 || profiles[0].Hours[10] != 2 {
+		t.Errorf("github.com のプロファイルが期待値と異なる: %+v", profiles[0])
+	}
+	if profiles[1].Domain != "youtube.com" || profiles[1].PeakHour != 11 {
+		t.Errorf("youtube.com のプロファイルが期待値と異なる: %+v", profiles[1])
+	}
+
+	if got := summarizePeaks(profiles); got != "github.com は 10:00、youtube.com は 11:00 にピーク" {
+		t.Errorf("summarizePeaks() = %q", got)
+	}
+}
+
+// TestGetCategoryHourlyProfiles は categories.txt のカテゴリごとの時間帯プロファイルのテスト
+func TestGetCategoryHourlyProfiles(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// github.com（10時に2回）と google.com（12時に1回）は仕事、youtube.com（11時に2回）は動画
+	categories := []DomainCategory{{Domain: "github.com", Category: "仕事"}, {Domain: "google.com", Category: "仕事"}, {Domain: "youtube.com", Category: "動画"}}
+	profiles, err := getCategoryHourlyProfiles(db, SearchFilter{}, categories)
+	if err != nil {
+		t.Fatalf("getCategoryHourlyProfiles失敗: %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("カテゴリ数が期待値と異なる: %+v", profiles)
+	}
+	if p := profiles[0]; p.Category != "仕事" || p.Domain != "" || p.VisitCount != 3 || p.PeakHour != 10 || p.Hours[12] != 1 {
+		t.Errorf("仕事のプロファイルが期待値と異なる: %+v", p)
+	}
+	if p := profiles[1]; p.Category != "動画" || p.PeakHour != 11 || p.PeakShare != 100 {
+		t.Errorf("動画のプロファイルが期待値と異なる: %+v", p)
+	}
+	if got := summarizePeaks(profiles); got != "仕事 は 10:00、動画 は 11:00 にピーク" {
+		t.Errorf("summarizePeaks() = %q", got)
+	}
+
+	// どのカテゴリにも一致しないドメインは未分類にまとめる
+	profiles, err = getCategoryHourlyProfiles(db, SearchFilter{}, categories[:1])
+	if err != nil {
+		t.Fatalf("getCategoryHourlyProfiles失敗: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].Category != uncategorizedLabel || profiles[0].VisitCount != 3 {
+		t.Errorf("未分類のプロファイルが期待値と異なる: %+v", profiles)
+	}
+
+	var buf bytes.Buffer
+	printHourlyProfiles(&buf, profiles)
+	if !strings.Contains(buf.String(), "カテゴリ別の時間帯プロファイル") {
+		t.Errorf("見出しがカテゴリ別でない:\n%s", buf.String())
+	}
+}
+
+// TestSparkline は時間帯の分布グラフのテスト
+func TestSparkline(t *testing.T) {
+	hours := make([]int, 24)
+	hours[0] = 1
+	hours[12] = 8
+	got := []rune(sparkline(hours))
+	if len(got) != 24 {
+		t.Fatalf("24文字のはず: %q", string(got))
+	}
+	if got[0] != '▁' || got[12] != '█' || got[6] != ' ' {
+		t.Errorf("sparkline() = %q", string(got))
+	}
+}
+
+// TestPrintHourlyProfiles は時間帯プロファイルのテキスト出力のテスト
+func TestPrintHourlyProfiles(t *testing.T) {
+	hours := make([]int, 24)
+	hours[22] = 3
+	var buf bytes.Buffer
+	printHourlyProfiles(&buf, []HourlyProfile{{Domain: "twitter.com", VisitCount: 3, Hours: hours, PeakHour: 22, PeakShare: 100}})
+	out := buf.String()
+	for _, want := range []string{"twitter.com", "ピーク 22:00 (100.0%)", "twitter.com は 22:00 にピーク"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれない:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+)
+
+// OutputFormatHTML は -output report.html で書き出す印刷できるHTMLレポート
+const OutputFormatHTML = "html"
+
+// reportTemplate は HTML レポートのテンプレート（web/templates/report.html）
+var reportTemplate = template.Must(template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/report.html"))
+
+// htmlReportTable は HTML レポートの表の1節（-table・Markdown と同じ内容）
+type htmlReportTable struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+	// RightAligned は右揃えにする列（0始まり）
+	RightAligned []int
+}
+
+// AlignRight は列 i を右揃えにするか（テンプレートから呼ぶ）
+func (t htmlReportTable) AlignRight(i int) bool {
+	return slices.Contains(t.RightAligned, i)
+}
+
+// htmlReportChart は HTML レポートのグラフ
+type htmlReportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+// htmlReportData は HTML レポートに渡すデータ
+type htmlReportData struct {
+	TotalVisits int
+	Query       *QueryInfo
+	Charts      []htmlReportChart
+	Tables      []htmlReportTable
+}
+
+// printHTMLReport は結果を1枚のHTMLレポートとして出力する
+// グラフはSVGで埋め込むため、JavaScript や外部のファイルなしで表示・印刷できる
+func printHTMLReport(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool) error {
+	data := htmlReportData{TotalVisits: result.TotalVisits, Query: result.Query}
+	if showDomains && len(result.DomainStats) > 0 {
+		data.Charts = append(data.Charts, htmlReportChart{fmt.Sprintf("ドメイン別訪問数 (Top %d)", len(result.DomainStats)), domainChart(result.DomainStats)})
+	}
+	if showHourly && len(result.HourlyStats) > 0 {
+		data.Charts = append(data.Charts, htmlReportChart{"時間帯別訪問数", hourlyChart(result.HourlyStats)})
+	}
+	if showDaily && len(result.DailyStats) > 0 {
+		data.Charts = append(data.Charts, htmlReportChart{"日別訪問数", dailyChart(result.DailyStats)})
+	}
+	writeTableSections(result, showHistory, showDomains, showPaths, showHourly, showDaily, func(title string, headers []string, rows [][]string, rightAligned ...int) {
+		data.Tables = append(data.Tables, htmlReportTable{Title: title, Headers: headers, Rows: rows, RightAligned: rightAligned})
+	})
+	return reportTemplate.ExecuteTemplate(w, "report.html", data)
+}
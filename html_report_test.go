@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLReport(t *testing.T) {
+	result := AnalysisResult{
+		TotalVisits:  5,
+		DomainStats:  []DomainStats{{Domain: "github.com", VisitCount: 3, Percentage: 60, CumulativePercentage: 60}},
+		HourlyStats:  []HourlyStats{{Hour: 10, VisitCount: 2}},
+		DailyStats:   []DailyStats{{Date: "2025-01-01", VisitCount: 5}},
+		RecentVisits: []HistoryVisit{{Title: "<b>太字</b>", Domain: "github.com"}},
+	}
+	var sb strings.Builder
+	if err := writeResult(&sb, OutputFormatHTML, result, Config{ShowHistory: true, ShowDomains: true, ShowHourly: true, ShowDaily: true}, csvHeaderEachSection); err != nil {
+		t.Fatalf("writeResult() error = %v", err)
+	}
+	report := sb.String()
+	if strings.Count(report, "<svg") != 3 {
+		t.Errorf("グラフの数 = %d, want 3", strings.Count(report, "<svg"))
+	}
+	if strings.Contains(report, "<b>太字</b>") || !strings.Contains(report, "&lt;b&gt;太字&lt;/b&gt;") {
+		t.Error("タイトルがエスケープされていない")
+	}
+	// 単体で開いて印刷できるよう、スクリプトや外部のファイルを読み込まない
+	if strings.Contains(report, "<script") || strings.Contains(report, `src="`) || strings.Contains(report, "<link") {
+		t.Error("スクリプトや外部のファイルを読み込んでいる")
+	}
+	if format, ok := outputFormatFromPath("report.html.gz"); !ok || format != OutputFormatHTML {
+		t.Errorf("report.html.gz の形式 = %q, %v", format, ok)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ignoreRuleWildcard は全ドメインを対象とするルールのドメイン指定
+const ignoreRuleWildcard = "*"
+
+// ignoreRuleTimeFormat は時間帯指定のフォーマット（HH:MM）
+const ignoreRuleTimeFormat = "15:04"
+
+// weekdayNames は曜日指定で使う略称（time.Weekday の順）
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// IgnoreRule は時間帯付きのイグノアルール
+// イグノアリストに「ドメイン @開始-終了 [曜日]」の形式で記述する
+//
+//	youtube.com @09:00-18:00 mon-fri   平日の勤務時間のみ除外
+//	* @00:00-06:00                     深夜帯は全ドメイン除外
+type IgnoreRule struct {
+	// Domain は対象ドメイン（"*" は全ドメイン）
+	Domain string
+	// Start, End は時間帯（"HH:MM"、ローカル時刻）。Start > End は日付をまたぐ
+	Start string
+	End   string
+	// Weekdays は対象曜日（0=日曜）。空の場合は毎日
+	Weekdays []int
+}
+
+// matchesAllDomains は全ドメインが対象かどうか
+func (r IgnoreRule) matchesAllDomains() bool {
+	return r.Domain == ignoreRuleWildcard
+}
+
+// String はイグノアリストに書く形式で返す
+func (r IgnoreRule) String() string {
+	s := fmt.Sprintf("%s @%s-%s", r.Domain, r.Start, r.End)
+	if len(r.Weekdays) > 0 {
+		var days []string
+		for _, d := range r.Weekdays {
+			days = append(days, weekdayNames[d])
+		}
+		s += " " + strings.Join(days, ",")
+	}
+	return s
+}
+
+// splitIgnoreEntries はイグノアリストの行を通常のドメインと時間帯付きルールに分ける
+func splitIgnoreEntries(entries []string) ([]string, []IgnoreRule, error) {
+	var domains []string
+	var rules []IgnoreRule
+	for _, entry := range entries {
+		if !strings.Contains(entry, "@") {
+			domains = append(domains, entry)
+			continue
+		}
+		rule, err := parseIgnoreRule(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return domains, rules, nil
+}
+
+// parseIgnoreRule は「ドメイン @HH:MM-HH:MM [曜日]」形式の行を解析する
+func parseIgnoreRule(entry string) (IgnoreRule, error) {
+	fields := strings.Fields(entry)
+	if len(fields) < 2 || len(fields) > 3 || !strings.HasPrefix(fields[1], "@") {
+		return IgnoreRule{}, fmt.Errorf("イグノアルールの形式が不正です（ドメイン @HH:MM-HH:MM [曜日]）: %s", entry)
+	}
+
+	rule := IgnoreRule{Domain: fields[0]}
+
+	window := strings.SplitN(strings.TrimPrefix(fields[1], "@"), "-", 2)
+	if len(window) != 2 {
+		return IgnoreRule{}, fmt.Errorf("時間帯の形式が不正です（HH:MM-HH:MM）: %s", entry)
+	}
+	for i, v := range window {
+		t, err := parseClock(v)
+		if err != nil {
+			return IgnoreRule{}, fmt.Errorf("時間帯の形式が不正です（HH:MM-HH:MM）: %s", entry)
+		}
+		if i == 0 {
+			rule.Start = t
+		} else {
+			rule.End = t
+		}
+	}
+	if rule.Start == rule.End {
+		return IgnoreRule{}, fmt.Errorf("開始時刻と終了時刻が同じです: %s", entry)
+	}
+
+	if len(fields) == 3 {
+		days, err := parseWeekdays(fields[2])
+		if err != nil {
+			return IgnoreRule{}, fmt.Errorf("%w: %s", err, entry)
+		}
+		rule.Weekdays = days
+	}
+
+	return rule, nil
+}
+
+// parseClock は "H:MM" / "HH:MM" を "HH:MM" に正規化する
+// 終了時刻として "24:00" も受け付ける
+func parseClock(s string) (string, error) {
+	if s == "24:00" {
+		return s, nil
+	}
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+		return "", err
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return "", fmt.Errorf("範囲外の時刻: %s", s)
+	}
+	return fmt.Sprintf("%02d:%02d", h, m), nil
+}
+
+// parseWeekdays は "mon-fri" や "sat,sun" 形式の曜日指定を解析する
+func parseWeekdays(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	var days []int
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		start := weekdayIndex(bounds[0])
+		end := start
+		if len(bounds) == 2 {
+			end = weekdayIndex(bounds[1])
+		}
+		if start == -1 || end == -1 {
+			return nil, fmt.Errorf("曜日の指定が不正です（例: mon-fri, sat,sun）")
+		}
+		// 範囲指定は週をまたいでもよい（例: fri-mon）
+		for d := start; ; d = (d + 1) % 7 {
+			if !seen[d] {
+				seen[d] = true
+				days = append(days, d)
+			}
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// weekdayIndex は曜日の略称を 0=日曜 のインデックスに変換する
+func weekdayIndex(name string) int {
+	for i, n := range weekdayNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
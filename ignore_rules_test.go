@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		want    IgnoreRule
+		wantErr bool
+	}{
+		{"ドメイン+時間帯", "youtube.com @09:00-18:00", IgnoreRule{Domain: "youtube.com", Start: "09:00", End: "18:00"}, false},
+		{"全ドメイン", "* @0:00-6:00", IgnoreRule{Domain: "*", Start: "00:00", End: "06:00"}, false},
+		{"曜日範囲", "x.com @09:00-18:00 mon-fri", IgnoreRule{Domain: "x.com", Start: "09:00", End: "18:00", Weekdays: []int{1, 2, 3, 4, 5}}, false},
+		{"曜日列挙", "x.com @22:00-02:00 sat,sun", IgnoreRule{Domain: "x.com", Start: "22:00", End: "02:00", Weekdays: []int{6, 0}}, false},
+		{"24:00終了", "* @18:00-24:00", IgnoreRule{Domain: "*", Start: "18:00", End: "24:00"}, false},
+		{"時間帯なし", "youtube.com @", IgnoreRule{}, true},
+		{"不正な時刻", "youtube.com @25:00-26:00", IgnoreRule{}, true},
+		{"同一時刻", "youtube.com @09:00-09:00", IgnoreRule{}, true},
+		{"不正な曜日", "youtube.com @09:00-18:00 foo", IgnoreRule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIgnoreRule(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIgnoreRule(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIgnoreRule(%q) = %+v, want %+v", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreRuleStringRoundTrip(t *testing.T) {
+	entry := "x.com @09:00-18:00 mon,tue,wed,thu,fri"
+	rule, err := parseIgnoreRule(entry)
+	if err != nil {
+		t.Fatalf("parseIgnoreRule失敗: %v", err)
+	}
+	if rule.String() != entry {
+		t.Errorf("String() = %q, want %q", rule.String(), entry)
+	}
+}
+
+func TestSplitIgnoreEntries(t *testing.T) {
+	domains, rules, err := splitIgnoreEntries([]string{"google", "youtube.com @09:00-18:00"})
+	if err != nil {
+		t.Fatalf("splitIgnoreEntries失敗: %v", err)
+	}
+	if len(domains) != 1 || domains[0] != "google" {
+		t.Errorf("domains = %v, want [google]", domains)
+	}
+	if len(rules) != 1 || rules[0].Domain != "youtube.com" {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	if _, _, err := splitIgnoreEntries([]string{"bad @entry"}); err == nil {
+		t.Error("不正なルールでエラーにならない")
+	}
+}
+
+func TestGetFilteredVisitCountWithIgnoreRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tests := []struct {
+		name  string
+		rules []IgnoreRule
+		want  int
+	}{
+		{"ルールなし", nil, 5},
+		{"終日・全ドメイン", []IgnoreRule{{Domain: "*", Start: "00:00", End: "24:00"}}, 0},
+		{"終日・github", []IgnoreRule{{Domain: "github.com", Start: "00:00", End: "24:00"}}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := getFilteredVisitCount(db, SearchFilter{IgnoreRules: tt.rules})
+			if err != nil {
+				t.Fatalf("getFilteredVisitCount失敗: %v", err)
+			}
+			if count != tt.want {
+				t.Errorf("count = %d, want %d", count, tt.want)
+			}
+		})
+	}
+}
+
+// TestIgnoreRulesUseLocalZone は時間帯付きルールを時間帯別の集計と同じローカルタイムゾーンで判定するか
+func TestIgnoreRulesUseLocalZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("タイムゾーンのデータがない: %v", err)
+	}
+	setLocalZone(t, tokyo)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	// 訪問は日本時間で 1/1（水）19:00・20:00・21:00 と 1/2（木）19:00・20:00
+	insertTestData(t, db)
+
+	hourly := func(rules []IgnoreRule) map[int]int {
+		t.Helper()
+		stats, err := getHourlyStats(db, SearchFilter{IgnoreRules: rules})
+		if err != nil {
+			t.Fatalf("getHourlyStats失敗: %v", err)
+		}
+		counts := make(map[int]int)
+		for _, s := range stats {
+			counts[s.Hour] += s.VisitCount
+		}
+		return counts
+	}
+	if got := hourly(nil); got[19] != 2 || got[20] != 2 || got[21] != 1 {
+		t.Fatalf("時間帯別 = %v, want 19時 2・20時 2・21時 1", got)
+	}
+	// 19時台の訪問を除くルールで、時間帯別の19時がちょうど0になる
+	if got := hourly([]IgnoreRule{{Domain: "*", Start: "19:00", End: "20:00"}}); got[19] != 0 || got[20] != 2 || got[21] != 1 {
+		t.Errorf("@19:00-20:00 で除いた時間帯別 = %v", got)
+	}
+	// 曜日も日本時間で判定する（水曜の訪問だけを除く）
+	if got := hourly([]IgnoreRule{{Domain: "*", Start: "00:00", End: "24:00", Weekdays: []int{3}}}); got[19] != 1 || got[20] != 1 || got[21] != 0 {
+		t.Errorf("水曜を除いた時間帯別 = %v", got)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// イグノアリストの候補を挙げる理由
+const (
+	SuggestReasonTracker    = "tracker"
+	SuggestReasonRedirect   = "redirect"
+	SuggestReasonLowVariety = "low_variety"
+)
+
+// suggestReasonLabels は理由の表示名
+var suggestReasonLabels = map[string]string{
+	SuggestReasonTracker:    "広告・トラッキング",
+	SuggestReasonRedirect:   "リダイレクトの中継",
+	SuggestReasonLowVariety: "少数のパスへの大量の訪問",
+}
+
+// suggestReasonOrder は候補を並べるときの理由の順序
+var suggestReasonOrder = map[string]int{
+	SuggestReasonTracker:    0,
+	SuggestReasonRedirect:   1,
+	SuggestReasonLowVariety: 2,
+}
+
+const (
+	// defaultSuggestDays は hist ignore suggest で調べる直近の日数
+	defaultSuggestDays = 90
+	// defaultSuggestMaxPaths 個以下のパスに defaultSuggestMinVisits 回以上訪問したドメインを候補にする
+	defaultSuggestMinVisits = 50
+	defaultSuggestMaxPaths  = 2
+
+	// redirectHopWindow 以内に別ドメインへ移った訪問をリダイレクトによる通過とみなす
+	redirectHopWindow = 3 * time.Second
+	// redirectMinVisits 回以上訪問し、redirectMinShare 以上が通過だったドメインを中継とみなす
+	redirectMinVisits = 3
+	redirectMinShare  = 0.8
+)
+
+// trackerHostLabels はホスト名の先頭がこれらのラベルなら広告・トラッキング用とみなす
+var trackerHostLabels = map[string]bool{
+	"ad": true, "ads": true, "adserver": true, "adservice": true,
+	"analytics": true, "tracking": true, "tracker": true,
+	"pixel": true, "telemetry": true, "beacon": true,
+}
+
+// IgnoreSuggestion はイグノアリストに追加する候補のドメイン
+// Domain はイグノアリストに書く形式（Safari のドメイン、なければホスト名）
+type IgnoreSuggestion struct {
+	Domain string `json:"domain"`
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+	Visits int    `json:"visits"`
+	Paths  int    `json:"paths"`
+}
+
+// ignoreSuggestOptions は候補の判定のしきい値
+type ignoreSuggestOptions struct {
+	MinVisits int
+	MaxPaths  int
+}
+
+// isTrackerHost はホスト名が広告・トラッキング用に見えるか
+// 同梱のトラッカー・広告ドメインリストに加え、ホスト名の先頭のラベルでも判定する
+func isTrackerHost(host string) bool {
+	if isTrackerDomain(host) {
+		return true
+	}
+	label, _, _ := strings.Cut(host, ".")
+	return trackerHostLabels[label]
+}
+
+// hasEmbeddedURL はURLのクエリに転送先のURLが含まれているか（/url?q=https://... など）
+func hasEmbeddedURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	for _, values := range u.Query() {
+		for _, v := range values {
+			if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getIgnoreSuggestions はフィルタに一致する訪問を調べ、イグノアリストの候補を返す
+// ignoreList に既に含まれるドメインは候補にしない
+func getIgnoreSuggestions(db dbQuerier, filter SearchFilter, ignoreList []string, opts ignoreSuggestOptions) ([]IgnoreSuggestion, error) {
+	type domainInfo struct {
+		host     string
+		visits   int
+		paths    map[string]bool
+		hops     int
+		embedded int
+	}
+	domains := make(map[string]*domainInfo)
+
+	// 訪問は新しい順に届くので、直前に見た訪問が時系列では次の訪問
+	var nextKey string
+	var nextTime time.Time
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		host := extractDomain(v.URL)
+		key := v.Domain
+		if key == "" {
+			key = host
+		}
+		if key == "" {
+			return nil
+		}
+
+		d := domains[key]
+		if d == nil {
+			d = &domainInfo{host: host, paths: make(map[string]bool)}
+			domains[key] = d
+		}
+		d.visits++
+		d.paths[extractPath(v.URL)] = true
+		if nextKey != "" && nextKey != key && nextTime.Sub(v.VisitTime) <= redirectHopWindow {
+			d.hops++
+		}
+		if hasEmbeddedURL(v.URL) {
+			d.embedded++
+		}
+		nextKey, nextTime = key, v.VisitTime
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("訪問履歴の取得に失敗: %w", err)
+	}
+
+	var suggestions []IgnoreSuggestion
+	for key, d := range domains {
+		if shouldIgnoreDomain(key, ignoreList) || shouldIgnoreDomain(d.host, ignoreList) {
+			continue
+		}
+		s := IgnoreSuggestion{Domain: key, Host: d.host, Visits: d.visits, Paths: len(d.paths)}
+		hopShare := float64(d.hops) / float64(d.visits)
+		embeddedShare := float64(d.embedded) / float64(d.visits)
+		switch {
+		case isTrackerHost(d.host):
+			s.Reason = SuggestReasonTracker
+			s.Detail = "広告・トラッキング用のドメイン"
+		case isRedirectorHost(d.host):
+			s.Reason = SuggestReasonRedirect
+			s.Detail = "短縮URL・リンクの中継ページのドメイン"
+		case d.visits >= redirectMinVisits && hopShare >= redirectMinShare:
+			s.Reason = SuggestReasonRedirect
+			s.Detail = fmt.Sprintf("訪問の%.0f%%が%d秒以内に別のドメインへ移動", hopShare*100, int(redirectHopWindow/time.Second))
+		case d.visits >= redirectMinVisits && embeddedShare >= redirectMinShare:
+			s.Reason = SuggestReasonRedirect
+			s.Detail = fmt.Sprintf("訪問の%.0f%%がURLに転送先を含む", embeddedShare*100)
+		case d.visits >= opts.MinVisits && len(d.paths) <= opts.MaxPaths:
+			s.Reason = SuggestReasonLowVariety
+			s.Detail = fmt.Sprintf("%d回の訪問が%dパスのみ", d.visits, len(d.paths))
+		default:
+			continue
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Reason != b.Reason {
+			return suggestReasonOrder[a.Reason] < suggestReasonOrder[b.Reason]
+		}
+		if a.Visits != b.Visits {
+			return a.Visits > b.Visits
+		}
+		return a.Domain < b.Domain
+	})
+	return suggestions, nil
+}
+
+// printIgnoreSuggestion は候補を1件表示する
+func printIgnoreSuggestion(w io.Writer, s IgnoreSuggestion) {
+	fmt.Fprintf(w, "%s（%s）: %d回・%dパス", s.Domain, suggestReasonLabels[s.Reason], s.Visits, s.Paths)
+	if s.Host != "" && s.Host != s.Domain {
+		fmt.Fprintf(w, "  [%s]", s.Host)
+	}
+	fmt.Fprintf(w, "\n  %s\n", s.Detail)
+}
+
+// reviewIgnoreSuggestions は候補を1件ずつ表示し、承認されたものに accept を呼ぶ
+// y で追加、n（または空行）で見送り、q か入力の終わりで残りを見送る。追加した件数を返す
+func reviewIgnoreSuggestions(in io.Reader, out io.Writer, suggestions []IgnoreSuggestion, accept func(domain string) error) (int, error) {
+	scanner := bufio.NewScanner(in)
+	added := 0
+	for i, s := range suggestions {
+		fmt.Fprintf(out, "[%d/%d] ", i+1, len(suggestions))
+		printIgnoreSuggestion(out, s)
+		fmt.Fprint(out, "  イグノアリストに追加しますか？ [y/N/q]: ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			break
+		}
+		answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if answer == "q" {
+			break
+		}
+		if answer != "y" && answer != "yes" {
+			continue
+		}
+		if err := accept(s.Domain); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, scanner.Err()
+}
+
+// runIgnoreCommand は hist ignore サブコマンドを実行する
+// イグノアリストの追加・削除・表示は -ignore-add などのフラグで行う
+func runIgnoreCommand(args []string) error {
+	if len(args) == 0 || args[0] != "suggest" {
+		return errors.New("使い方: hist ignore suggest [オプション]（追加・削除は -ignore-add / -ignore-remove）")
+	}
+
+	fs := flag.NewFlagSet("ignore suggest", flag.ContinueOnError)
+	days := fs.Int("days", defaultSuggestDays, "直近何日分の訪問から候補を探すか（0 で全期間）")
+	minVisits := fs.Int("min-visits", defaultSuggestMinVisits, "少数のパスへの大量の訪問とみなす最低訪問数")
+	maxPaths := fs.Int("max-paths", defaultSuggestMaxPaths, "少数のパスへの大量の訪問とみなす最大パス数")
+	yes := fs.Bool("yes", false, "確認せずに全ての候補を追加")
+	list := fs.Bool("list", false, "候補を表示するだけで追加しない")
+	jsonOutput := fs.Bool("json", false, "候補をJSON形式で出力（追加しない）")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist ignore suggest [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  広告・トラッキング、リダイレクトの中継、少数のパスへの大量の訪問を\n")
+		fmt.Fprintf(fs.Output(), "  イグノアリストの候補として挙げ、1件ずつ追加するか確認します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *days < 0 || *minVisits < 1 || *maxPaths < 1 {
+		return errors.New("-days は0以上、-min-visits と -max-paths は1以上で指定してください")
+	}
+
+	ignoreList, err := LoadIgnoreList()
+	if err != nil {
+		return err
+	}
+	ignoreDomains, _, err := splitIgnoreEntries(ignoreList)
+	if err != nil {
+		return err
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	var filter SearchFilter
+	if *days > 0 {
+		filter.From = time.Now().UTC().AddDate(0, 0, -*days)
+	}
+	suggestions, err := getIgnoreSuggestions(db, filter, ignoreDomains, ignoreSuggestOptions{MinVisits: *minVisits, MaxPaths: *maxPaths})
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if suggestions == nil {
+			suggestions = []IgnoreSuggestion{}
+		}
+		return encoder.Encode(suggestions)
+	}
+	if len(suggestions) == 0 {
+		fmt.Println("イグノアリストの候補はありません")
+		return nil
+	}
+	if *list {
+		for _, s := range suggestions {
+			printIgnoreSuggestion(os.Stdout, s)
+		}
+		return nil
+	}
+
+	var added int
+	if *yes {
+		for _, s := range suggestions {
+			if err := AddToIgnoreList(s.Domain); err != nil {
+				return err
+			}
+			printIgnoreSuggestion(os.Stdout, s)
+		}
+		added = len(suggestions)
+	} else {
+		added, err = reviewIgnoreSuggestions(os.Stdin, os.Stdout, suggestions, AddToIgnoreList)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d件のドメインをイグノアリストに追加しました\n", added)
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIsTrackerHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"doubleclick.net", true},
+		{"ad.doubleclick.net", true},
+		{"www.googletagmanager.com", true},
+		{"ads.example.com", true},
+		{"analytics.example.com", true},
+		{"github.com", false},
+		{"adobe.com", false},
+		{"headless.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := isTrackerHost(tt.host); got != tt.want {
+			t.Errorf("isTrackerHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHasEmbeddedURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://www.google.com/url?q=https://example.com/&sa=D", true},
+		{"https://l.facebook.com/l.php?u=http%3A%2F%2Fexample.com%2F", true},
+		{"https://www.google.com/search?q=https+example", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		if got := hasEmbeddedURL(tt.url); got != tt.want {
+			t.Errorf("hasEmbeddedURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGetIgnoreSuggestions(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://ad.doubleclick.net/click', 'ad.doubleclick', 1),
+		(2, 'https://t.co/abc', NULL, 3),
+		(3, 'https://example.com/article', NULL, 3),
+		(4, 'https://mail.example.org/inbox', 'mail.example', 4),
+		(5, 'https://github.com/a', 'github', 1),
+		(6, 'https://github.com/b', 'github', 1),
+		(7, 'https://github.com/c', 'github', 1);
+	`)
+	if err != nil {
+		t.Fatalf("history_items挿入に失敗: %v", err)
+	}
+	// t.co は毎回1秒後に example.com へ移る（リダイレクトの中継）
+	// mail.example は1パスに4回、github は3パスに3回
+	base := 757418400.0
+	_, err = db.Exec(`
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, ?, ''),
+		(2, 2, ?, ''), (3, 3, ?, 'Article'),
+		(4, 2, ?, ''), (5, 3, ?, 'Article'),
+		(6, 2, ?, ''), (7, 3, ?, 'Article'),
+		(8, 4, ?, 'Inbox'), (9, 4, ?, 'Inbox'), (10, 4, ?, 'Inbox'), (11, 4, ?, 'Inbox'),
+		(12, 5, ?, 'A'), (13, 6, ?, 'B'), (14, 7, ?, 'C');
+	`, base,
+		base+100, base+101,
+		base+200, base+201,
+		base+300, base+301,
+		base+400, base+500, base+600, base+700,
+		base+800, base+900, base+1000)
+	if err != nil {
+		t.Fatalf("history_visits挿入に失敗: %v", err)
+	}
+
+	opts := ignoreSuggestOptions{MinVisits: 4, MaxPaths: 1}
+	got, err := getIgnoreSuggestions(db, SearchFilter{}, nil, opts)
+	if err != nil {
+		t.Fatalf("getIgnoreSuggestions失敗: %v", err)
+	}
+	want := []struct{ domain, reason string }{
+		{"ad.doubleclick", SuggestReasonTracker},
+		{"t.co", SuggestReasonRedirect},
+		{"mail.example", SuggestReasonLowVariety},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("getIgnoreSuggestions() = %+v", got)
+	}
+	for i, w := range want {
+		if got[i].Domain != w.domain || got[i].Reason != w.reason {
+			t.Errorf("suggestions[%d] = %+v, want %s (%s)", i, got[i], w.domain, w.reason)
+		}
+	}
+	if got[1].Host != "t.co" || got[1].Visits != 3 {
+		t.Errorf("t.co の候補 = %+v", got[1])
+	}
+
+	// 既にイグノアリストにあるドメインは候補にしない
+	got, err = getIgnoreSuggestions(db, SearchFilter{}, []string{"doubleclick.net", "t.co"}, opts)
+	if err != nil {
+		t.Fatalf("getIgnoreSuggestions失敗: %v", err)
+	}
+	if len(got) != 1 || got[0].Domain != "mail.example" {
+		t.Errorf("イグノアリスト適用後の候補 = %+v", got)
+	}
+}
+
+func TestReviewIgnoreSuggestions(t *testing.T) {
+	suggestions := []IgnoreSuggestion{
+		{Domain: "ad.doubleclick", Host: "ad.doubleclick.net", Reason: SuggestReasonTracker, Visits: 1, Paths: 1},
+		{Domain: "t.co", Host: "t.co", Reason: SuggestReasonRedirect, Visits: 3, Paths: 1},
+		{Domain: "mail.example", Reason: SuggestReasonLowVariety, Visits: 4, Paths: 1},
+		{Domain: "never.example", Reason: SuggestReasonLowVariety, Visits: 4, Paths: 1},
+	}
+
+	var accepted []string
+	accept := func(domain string) error {
+		accepted = append(accepted, domain)
+		return nil
+	}
+	var out bytes.Buffer
+	added, err := reviewIgnoreSuggestions(strings.NewReader("y\n\nYES\nq\n"), &out, suggestions, accept)
+	if err != nil {
+		t.Fatalf("reviewIgnoreSuggestions失敗: %v", err)
+	}
+	if added != 2 || len(accepted) != 2 || accepted[0] != "ad.doubleclick" || accepted[1] != "mail.example" {
+		t.Errorf("added = %d, accepted = %v", added, accepted)
+	}
+	if !strings.Contains(out.String(), "[1/4] ad.doubleclick（広告・トラッキング）") || !strings.Contains(out.String(), "[ad.doubleclick.net]") {
+		t.Errorf("候補の表示が期待と異なる:\n%s", out.String())
+	}
+
+	// q で残りの候補を表示せずに終わる
+	out.Reset()
+	added, err = reviewIgnoreSuggestions(strings.NewReader("q\n"), &out, suggestions, accept)
+	if err != nil || added != 0 || strings.Contains(out.String(), "[2/4]") {
+		t.Errorf("added = %d, err = %v, output:\n%s", added, err, out.String())
+	}
+
+	// 入力が途中で終わったら残りは見送る
+	accepted = nil
+	added, err = reviewIgnoreSuggestions(strings.NewReader("y\n"), &out, suggestions, accept)
+	if err != nil || added != 1 {
+		t.Errorf("added = %d, err = %v", added, err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// infoTopDomains は hist info で表示する行数の多いドメインの件数
+const infoTopDomains = 5
+
+// DBInfo は履歴DBの概要
+type DBInfo struct {
+	Path          string        `json:"path"`
+	FileSize      int64         `json:"file_size"`
+	WALPresent    bool          `json:"wal_present"`
+	WALSize       int64         `json:"wal_size,omitempty"`
+	SchemaVersion int           `json:"schema_version"`
+	TotalItems    int           `json:"total_items"`
+	TotalVisits   int           `json:"total_visits"`
+	FirstVisit    *time.Time    `json:"first_visit,omitempty"`
+	LastVisit     *time.Time    `json:"last_visit,omitempty"`
+	TopDomains    []DomainStats `json:"top_domains_by_rows,omitempty"`
+	Driver        string        `json:"driver"`
+}
+
+// runInfoCommand は hist info サブコマンドを実行する
+func runInfoCommand(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dbPath, err := getDBPath()
+	if err != nil {
+		return err
+	}
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	info, err := getDBInfo(db, dbPath)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	printDBInfo(os.Stdout, info)
+	return nil
+}
+
+// getDBInfo は履歴DBのファイル情報と内容の概要を取得する
+func getDBInfo(db dbQuerier, dbPath string) (DBInfo, error) {
+	info := DBInfo{Path: dbPath, Driver: sqliteDriverPackage}
+
+	stat, err := os.Stat(dbPath)
+	if err != nil {
+		return info, fmt.Errorf("データベースファイルの情報取得に失敗: %w", err)
+	}
+	info.FileSize = stat.Size()
+
+	// WAL（先行書き込みログ）にはSafariがまだチェックポイントしていない訪問が残っている
+	if walStat, err := os.Stat(dbPath + "-wal"); err == nil {
+		info.WALPresent = true
+		info.WALSize = walStat.Size()
+	}
+
+	if err := db.QueryRow("PRAGMA user_version").Scan(&info.SchemaVersion); err != nil {
+		return info, fmt.Errorf("スキーマバージョンの取得に失敗: %w", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM history_items").Scan(&info.TotalItems); err != nil {
+		return info, fmt.Errorf("URL数の取得に失敗: %w", err)
+	}
+
+	var first, last *float64
+	err = db.QueryRow("SELECT COUNT(*), MIN(visit_time), MAX(visit_time) FROM history_visits").
+		Scan(&info.TotalVisits, &first, &last)
+	if err != nil {
+		return info, fmt.Errorf("訪問期間の取得に失敗: %w", err)
+	}
+	if first != nil && last != nil {
+		f := convertCoreDataTimestamp(*first)
+		l := convertCoreDataTimestamp(*last)
+		info.FirstVisit = &f
+		info.LastVisit = &l
+	}
+
+	info.TopDomains, err = getDomainsByRows(db, infoTopDomains)
+	if err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+// getDomainsByRows はhistory_itemsの行数（URL数）が多いドメインを返す
+func getDomainsByRows(db dbQuerier, limit int) ([]DomainStats, error) {
+	rows, err := db.Query(`SELECT hi.url FROM history_items hi`)
+	if err != nil {
+		return nil, fmt.Errorf("ドメイン別行数の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		domain := extractDomain(url)
+		if domain == "" {
+			domain = "不明"
+		}
+		counts[domain]++
+	}
+
+	var stats []DomainStats
+	for domain, count := range counts {
+		stats = append(stats, DomainStats{Domain: domain, VisitCount: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].VisitCount != stats[j].VisitCount {
+			return stats[i].VisitCount > stats[j].VisitCount
+		}
+		return stats[i].Domain < stats[j].Domain
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// formatBytes はバイト数を読みやすい単位に変換する
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printDBInfo はDBの概要をテキスト形式で出力する
+func printDBInfo(w io.Writer, info DBInfo) {
+	_, _ = fmt.Fprintf(w, "\n🗄  履歴データベース情報\n")
+	_, _ = fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	_, _ = fmt.Fprintf(w, "パス:             %s\n", info.Path)
+	_, _ = fmt.Fprintf(w, "ファイルサイズ:   %s\n", formatBytes(info.FileSize))
+	if info.WALPresent {
+		_, _ = fmt.Fprintf(w, "WAL:              あり（%s）\n", formatBytes(info.WALSize))
+	} else {
+		_, _ = fmt.Fprintf(w, "WAL:              なし\n")
+	}
+	_, _ = fmt.Fprintf(w, "スキーマバージョン: %d\n", info.SchemaVersion)
+	_, _ = fmt.Fprintf(w, "URL数:            %d\n", info.TotalItems)
+	_, _ = fmt.Fprintf(w, "総訪問数:         %d\n", info.TotalVisits)
+	_, _ = fmt.Fprintf(w, "SQLiteドライバ:   %s\n", info.Driver)
+	if info.FirstVisit != nil && info.LastVisit != nil {
+		_, _ = fmt.Fprintf(w, "期間:             %s 〜 %s\n",
+			info.FirstVisit.Format(TimeFormatDateTime), info.LastVisit.Format(TimeFormatDateTime))
+	}
+
+	if len(info.TopDomains) > 0 {
+		_, _ = fmt.Fprintf(w, "\n📦 URL数の多いドメイン\n")
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		for _, d := range info.TopDomains {
+			_, _ = fmt.Fprintf(w, "  %s %d\n", padRight(d.Domain, 30), d.VisitCount)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetDBInfo(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	dbPath := filepath.Join(t.TempDir(), "History.db")
+	db, err := sql.Open(SQLiteDriver, dbPath)
+	if err != nil {
+		t.Fatalf("テストDB作成に失敗: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(testSchema); err != nil {
+		t.Fatalf("テーブル作成に失敗: %v", err)
+	}
+	insertTestData(t, db)
+
+	info, err := getDBInfo(db, dbPath)
+	if err != nil {
+		t.Fatalf("getDBInfo失敗: %v", err)
+	}
+
+	if info.FileSize == 0 {
+		t.Error("FileSizeが0")
+	}
+	if info.TotalItems != 4 || info.TotalVisits != 5 {
+		t.Errorf("TotalItems = %d, TotalVisits = %d, want 4, 5", info.TotalItems, info.TotalVisits)
+	}
+	if info.FirstVisit == nil || info.FirstVisit.Format(TimeFormatDate) != "2025-01-01" {
+		t.Errorf("FirstVisit = %v, want 2025-01-01", info.FirstVisit)
+	}
+	if info.LastVisit == nil || info.LastVisit.Format(TimeFormatDate) != "2025-01-02" {
+		t.Errorf("LastVisit = %v, want 2025-01-02", info.LastVisit)
+	}
+	if len(info.TopDomains) != 4 {
+		t.Errorf("TopDomains = %v", info.TopDomains)
+	}
+
+	var buf bytes.Buffer
+	printDBInfo(&buf, info)
+	if !strings.Contains(buf.String(), dbPath) || !strings.Contains(buf.String(), "総訪問数:         5") {
+		t.Errorf("出力が期待と異なる:\n%s", buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{512, "512 B"},
+		{2048, "2.0 KB"},
+		{5 * 1024 * 1024, "5.0 MB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultSwiftBarInterval は SwiftBar・xbar のプラグインが /api/summary を取得する既定の間隔
+const defaultSwiftBarInterval = time.Minute
+
+// swiftBarScript は SwiftBar・xbar のプラグイン（hist -serve の /api/summary を表示する）
+// JSON は macOS に付属の plutil で読むため、curl 以外の追加のツールは要らない
+var swiftBarScript = template.Must(template.New("swiftbar").Parse(`#!/bin/bash
+# <xbar.title>hist</xbar.title>
+# <xbar.version>v1.0</xbar.version>
+# <xbar.author>hist</xbar.author>
+# <xbar.desc>Safari の今日の訪問数・上位ドメイン・集中スコア（hist -serve の /api/summary）</xbar.desc>
+# <xbar.dependencies>hist,curl</xbar.dependencies>
+# <swiftbar.hideRunInTerminal>true</swiftbar.hideRunInTerminal>
+# <swiftbar.hideLastUpdated>false</swiftbar.hideLastUpdated>
+#
+# hist integrations swiftbar で生成（{{.Interval}} ごとに更新）
+# hist -serve -port {{.Port}} を起動しておくと表示します
+
+HIST_URL="${HIST_URL:-http://localhost:{{.Port}}}"
+
+if ! json=$(curl -fsS --max-time 5 "$HIST_URL/api/summary" 2>/dev/null); then
+  echo "🧭 –"
+  echo "---"
+  echo "hist -serve に接続できません | color=gray"
+  echo "$HIST_URL | color=gray"
+  exit 0
+fi
+
+get() {
+  printf '%s' "$json" | plutil -extract "$1" raw -o - - 2>/dev/null
+}
+
+visits=$(get visits)
+score=$(get focus_score)
+if [ -n "$score" ]; then
+  echo "🧭 $visits · $score"
+else
+  echo "🧭 $visits"
+fi
+echo "---"
+echo "今日の訪問: $visits"
+if [ -n "$score" ]; then
+  echo "集中スコア: $score（気が散る訪問 $(get distraction_visits)・中断 $(get interruptions)回）"
+fi
+echo "---"
+for i in{{range .Indexes}} {{.}}{{end}}; do
+  domain=$(get "top_domains.$i.domain") || break
+  [ -n "$domain" ] || break
+  count=$(get "top_domains.$i.visit_count")
+  if [ "$domain" = "{{.Redacted}}" ]; then
+    echo "$domain  $count | color=gray"
+  else
+    echo "$domain  $count | href=$HIST_URL/domain?d=$domain"
+  fi
+done
+echo "---"
+echo "ダッシュボードを開く | href=$HIST_URL/"
+echo "更新 | refresh=true"
+`))
+
+// swiftBarIntervalSuffix は SwiftBar・xbar のファイル名に付ける更新間隔（30s・1m・2h など）を返す
+func swiftBarIntervalSuffix(d time.Duration) (string, error) {
+	switch {
+	case d < time.Second || d%time.Second != 0:
+		return "", fmt.Errorf("-interval は1秒以上の秒単位で指定してください: %s", d)
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour), nil
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute), nil
+	default:
+		return fmt.Sprintf("%ds", d/time.Second), nil
+	}
+}
+
+// writeSwiftBarScript は port の hist -serve を interval ごとに取得するプラグインを書き出す
+func writeSwiftBarScript(w io.Writer, port int, interval string) error {
+	indexes := make([]int, summaryTopDomains)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return swiftBarScript.Execute(w, struct {
+		Port     int
+		Interval string
+		Indexes  []int
+		Redacted string
+	}{port, interval, indexes, redactedLabel})
+}
+
+// runIntegrationsCommand は hist integrations サブコマンドを実行する
+func runIntegrationsCommand(args []string) error {
+	usage := "使い方: hist integrations swiftbar [-port 8080] [-interval 1m] [-out ディレクトリ]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	name, args := args[0], args[1:]
+	if name != "swiftbar" && name != "xbar" {
+		return fmt.Errorf("連携先は swiftbar（xbar）で指定してください: %s\n%s", name, usage)
+	}
+
+	fs := flag.NewFlagSet("integrations swiftbar", flag.ContinueOnError)
+	port := fs.Int("port", DefaultWebPort, "hist -serve のポート番号")
+	interval := fs.Duration("interval", defaultSwiftBarInterval, "メニューバーの表示を更新する間隔")
+	outDir := fs.String("out", "", "プラグインを書き出すディレクトリ（SwiftBar・xbar のプラグインフォルダ。省略時は標準出力）")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\n", usage)
+		fmt.Fprintf(fs.Output(), "  SwiftBar・xbar のメニューバーに今日の訪問数・上位ドメイン・集中スコアを表示するプラグインを生成します\n")
+		fmt.Fprintf(fs.Output(), "  プラグインは hist -serve の /api/summary を取得します（xbar でも同じプラグインを使えます）\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New(usage)
+	}
+	if *port <= 0 || *port > 65535 {
+		return fmt.Errorf("-port は1〜65535で指定してください: %d", *port)
+	}
+	suffix, err := swiftBarIntervalSuffix(*interval)
+	if err != nil {
+		return err
+	}
+
+	var script strings.Builder
+	if err := writeSwiftBarScript(&script, *port, suffix); err != nil {
+		return fmt.Errorf("プラグインの生成に失敗: %w", err)
+	}
+	if *outDir == "" {
+		fmt.Print(script.String())
+		return nil
+	}
+	dir, err := expandHomeDir(*outDir)
+	if err != nil {
+		return err
+	}
+	// SwiftBar・xbar はファイル名の「名前.間隔.拡張子」から更新間隔を決める
+	path := filepath.Join(dir, fmt.Sprintf("hist.%s.sh", suffix))
+	if err := os.WriteFile(path, []byte(script.String()), 0o755); err != nil {
+		return fmt.Errorf("プラグインの書き出しに失敗: %w", err)
+	}
+	fmt.Printf("プラグインを書き出しました: %s\n", path)
+	return nil
+}
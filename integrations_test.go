@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwiftBarIntervalSuffix(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{30 * time.Second, "30s"},
+		{time.Minute, "1m"},
+		{90 * time.Second, "90s"},
+		{2 * time.Hour, "2h"},
+	}
+	for _, tt := range tests {
+		got, err := swiftBarIntervalSuffix(tt.interval)
+		if err != nil || got != tt.want {
+			t.Errorf("swiftBarIntervalSuffix(%s) = %q, %v; want %q", tt.interval, got, err, tt.want)
+		}
+	}
+	for _, d := range []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond} {
+		if _, err := swiftBarIntervalSuffix(d); err == nil {
+			t.Errorf("swiftBarIntervalSuffix(%s) がエラーにならなかった", d)
+		}
+	}
+}
+
+func TestWriteSwiftBarScript(t *testing.T) {
+	var script strings.Builder
+	if err := writeSwiftBarScript(&script, 9000, "1m"); err != nil {
+		t.Fatalf("writeSwiftBarScript: %v", err)
+	}
+	s := script.String()
+	for _, want := range []string{"#!/bin/bash", "<xbar.title>hist</xbar.title>", "http://localhost:9000", "/api/summary", "for i in 0 1 2; do", "/domain?d=$domain"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("プラグインに %q がない:\n%s", want, s)
+		}
+	}
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash がない")
+	}
+	path := filepath.Join(t.TempDir(), "hist.1m.sh")
+	if err := os.WriteFile(path, []byte(s), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("bash", "-n", path).CombinedOutput(); err != nil {
+		t.Errorf("プラグインの構文エラー: %v\n%s", err, out)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"os/exec"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -35,16 +36,24 @@ var (
 
 // interactiveModel はインタラクティブモードのモデル
 type interactiveModel struct {
-	db           *sql.DB
-	visits       []HistoryVisit
-	cursor       int
-	pageSize     int
-	totalVisits  int
-	filter       SearchFilter
-	searchMode   bool
-	searchInput  string
-	showDetail   bool
-	detailVisit  *HistoryVisit
+	db          *sql.DB
+	visits      []HistoryVisit
+	cursor      int
+	pageSize    int
+	totalVisits int
+	filter      SearchFilter
+	redact      []string
+	fuzzy       bool
+	searchMode  bool
+	searchInput string
+	showDetail  bool
+	detailVisit *HistoryVisit
+	// detailNotes は詳細表示中の訪問のURLに付けたタグ・メモ
+	detailNotes []Annotation
+	// detailStarred は詳細表示中の訪問のURLにスターを付けたか
+	detailStarred bool
+	// status はフッターに一時的に表示するメッセージ（「あとで読む」に追加した結果など）
+	status       string
 	err          error
 	windowHeight int
 	windowWidth  int
@@ -62,10 +71,17 @@ func newInteractiveModel(db *sql.DB) interactiveModel {
 // loadVisits は履歴を読み込む
 func (m *interactiveModel) loadVisits() tea.Cmd {
 	return func() tea.Msg {
-		visits, err := getRecentVisits(m.db, m.pageSize, m.filter)
+		var visits []HistoryVisit
+		var err error
+		if m.fuzzy && m.filter.Keyword != "" {
+			visits, err = getFuzzyVisits(m.db, m.pageSize, m.filter.Keyword, m.filter)
+		} else {
+			visits, err = getRecentVisits(m.db, m.pageSize, m.filter)
+		}
 		if err != nil {
 			return errMsg{err}
 		}
+		visits = redactVisits(sanitizeVisits(visits), m.redact)
 		total, err := getTotalVisits(m.db)
 		if err != nil {
 			return errMsg{err}
@@ -84,6 +100,94 @@ type errMsg struct {
 	err error
 }
 
+// statusMsg はフッターに表示するメッセージ
+type statusMsg string
+
+// saveForLater は訪問を「あとで読む」に追加する
+func (m *interactiveModel) saveForLater(v HistoryVisit) tea.Cmd {
+	return func() tea.Msg {
+		added, err := addToLater(nil, v.URL, v.Title)
+		switch {
+		case err != nil:
+			return statusMsg(fmt.Sprintf("追加できませんでした: %v", err))
+		case added:
+			return statusMsg("「あとで読む」に追加しました")
+		default:
+			return statusMsg("「あとで読む」にすでにあります")
+		}
+	}
+}
+
+// annotationsLoadedMsg は詳細表示する訪問のタグ・メモとスター
+type annotationsLoadedMsg struct {
+	url         string
+	annotations []Annotation
+	starred     bool
+}
+
+// loadAnnotations は訪問のURLに付けたタグ・メモとスターを読み込む（読めなければ表示しない）
+func (m *interactiveModel) loadAnnotations(v HistoryVisit) tea.Cmd {
+	return func() tea.Msg {
+		path, err := getSidecarPath()
+		if err != nil || v.URL == redactedLabel {
+			return nil
+		}
+		sidecar, err := openExistingSidecarDB(path)
+		if err != nil || sidecar == nil {
+			return nil
+		}
+		defer func() { _ = sidecar.Close() }()
+		annotations, err := sidecar.Annotations(v.URL)
+		if err != nil {
+			return nil
+		}
+		starred, err := sidecar.IsStarred(v.URL)
+		if err != nil {
+			return nil
+		}
+		return annotationsLoadedMsg{url: v.URL, annotations: annotations, starred: starred}
+	}
+}
+
+// starToggledMsg はスターを付け外しした結果
+type starToggledMsg struct {
+	url     string
+	starred bool
+}
+
+// toggleStar は訪問のURLのスターを付け外しする
+func (m *interactiveModel) toggleStar(v HistoryVisit) tea.Cmd {
+	return func() tea.Msg {
+		starred, err := toggleStar(v.URL, v.Title)
+		if err != nil {
+			return statusMsg(fmt.Sprintf("スターを付け外しできませんでした: %v", err))
+		}
+		return starToggledMsg{url: v.URL, starred: starred}
+	}
+}
+
+// timelineLink は訪問のURLの訪問タイムライン（hist -serve の /url?u=）のURLを返す（リダクトした訪問は空）
+func timelineLink(v HistoryVisit) string {
+	if v.URL == "" || v.URL == redactedLabel {
+		return ""
+	}
+	return fmt.Sprintf("http://localhost:%d%s", DefaultWebPort, urlTimelinePath(v.URL))
+}
+
+// openTimeline は訪問のURLの訪問タイムラインをブラウザで開く
+func (m *interactiveModel) openTimeline(v HistoryVisit) tea.Cmd {
+	return func() tea.Msg {
+		link := timelineLink(v)
+		if link == "" {
+			return statusMsg("リダクトした訪問のタイムラインは開けません")
+		}
+		if err := exec.Command(openCommand, link).Run(); err != nil {
+			return statusMsg(fmt.Sprintf("開けませんでした: %v", err))
+		}
+		return statusMsg("訪問タイムラインを開きました（hist -serve の起動が必要です）")
+	}
+}
+
 // Init は初期化コマンドを返す
 func (m interactiveModel) Init() tea.Cmd {
 	return m.loadVisits()
@@ -109,7 +213,31 @@ func (m interactiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.err = msg.err
 		return m, nil
 
+	case statusMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case annotationsLoadedMsg:
+		// 読み込み中に別の訪問に移っていたら捨てる
+		if m.detailVisit != nil && m.detailVisit.URL == msg.url {
+			m.detailNotes = msg.annotations
+			m.detailStarred = msg.starred
+		}
+		return m, nil
+
+	case starToggledMsg:
+		if msg.starred {
+			m.status = "スターを付けました"
+		} else {
+			m.status = "スターを外しました"
+		}
+		if m.detailVisit != nil && m.detailVisit.URL == msg.url {
+			m.detailStarred = msg.starred
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		m.status = ""
 		// 検索モード中のキー処理
 		if m.searchMode {
 			return m.handleSearchInput(msg)
@@ -121,6 +249,14 @@ func (m interactiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "esc", "q", "enter":
 				m.showDetail = false
 				m.detailVisit = nil
+				m.detailNotes = nil
+				m.detailStarred = false
+			case "l":
+				return m, m.saveForLater(*m.detailVisit)
+			case "*":
+				return m, m.toggleStar(*m.detailVisit)
+			case "t":
+				return m, m.openTimeline(*m.detailVisit)
 			}
 			return m, nil
 		}
@@ -144,6 +280,7 @@ func (m interactiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if len(m.visits) > 0 && m.cursor < len(m.visits) {
 				m.showDetail = true
 				m.detailVisit = &m.visits[m.cursor]
+				return m, m.loadAnnotations(*m.detailVisit)
 			}
 
 		case "/":
@@ -158,6 +295,18 @@ func (m interactiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, m.loadVisits()
 			}
 
+		case "l":
+			// 選択中の訪問を「あとで読む」に追加
+			if len(m.visits) > 0 && m.cursor < len(m.visits) {
+				return m, m.saveForLater(m.visits[m.cursor])
+			}
+
+		case "*":
+			// 選択中の訪問のURLにスターを付け外しする
+			if len(m.visits) > 0 && m.cursor < len(m.visits) {
+				return m, m.toggleStar(m.visits[m.cursor])
+			}
+
 		case "r":
 			// リロード
 			return m, m.loadVisits()
@@ -221,12 +370,16 @@ func (m interactiveModel) View() string {
 	}
 
 	// 検索モード表示
+	searchLabel := "検索"
+	if m.fuzzy {
+		searchLabel = "あいまい検索"
+	}
 	if m.searchMode {
-		b.WriteString(searchPromptStyle.Render("検索: "))
+		b.WriteString(searchPromptStyle.Render(searchLabel + ": "))
 		b.WriteString(m.searchInput)
 		b.WriteString("_\n\n")
 	} else if m.filter.Keyword != "" {
-		fmt.Fprintf(&b, "検索中: %q (Escでクリア)\n\n", m.filter.Keyword)
+		fmt.Fprintf(&b, "%s中: %q (Escでクリア)\n\n", searchLabel, m.filter.Keyword)
 	}
 
 	// 履歴一覧
@@ -245,9 +398,7 @@ func (m interactiveModel) View() string {
 			}
 			// タイトルを切り詰め
 			maxTitleLen := min(MaxTitleLength, m.windowWidth-20)
-			if len(title) > maxTitleLen {
-				title = title[:maxTitleLen-3] + "..."
-			}
+			title = truncateText(title, maxTitleLen)
 
 			line := fmt.Sprintf("%s%s  %s",
 				cursor,
@@ -276,7 +427,10 @@ func (m interactiveModel) View() string {
 	b.WriteString(strings.Repeat("─", min(SeparatorWidth, m.windowWidth)))
 	b.WriteString("\n")
 	fmt.Fprintf(&b, "総訪問数: %d\n", m.totalVisits)
-	b.WriteString(helpStyle.Render("↑/↓:移動  Enter:詳細  /:検索  r:更新  q:終了"))
+	if m.status != "" {
+		b.WriteString(m.status + "\n")
+	}
+	b.WriteString(helpStyle.Render("↑/↓:移動  Enter:詳細  /:検索  l:あとで読む  *:スター  r:更新  q:終了"))
 	b.WriteString("\n")
 
 	return b.String()
@@ -301,19 +455,46 @@ func (m interactiveModel) renderDetail() string {
 	fmt.Fprintf(&b, "URL: %s\n\n", v.URL)
 	fmt.Fprintf(&b, "ドメイン: %s\n\n", v.Domain)
 	fmt.Fprintf(&b, "訪問日時: %s\n\n", v.VisitTime.Format(TimeFormatFull))
+	if link := timelineLink(*v); link != "" {
+		fmt.Fprintf(&b, "タイムライン: %s\n\n", link)
+	}
+	if m.detailStarred {
+		b.WriteString("スター: ★\n\n")
+	}
+	for _, a := range m.detailNotes {
+		// URL全体と、この訪問に付けたものだけを表示する
+		if a.VisitID != 0 && a.VisitID != v.ID {
+			continue
+		}
+		if len(a.Tags) > 0 {
+			fmt.Fprintf(&b, "タグ: #%s\n\n", strings.Join(a.Tags, " #"))
+		}
+		if a.Note != "" {
+			fmt.Fprintf(&b, "メモ: %s\n\n", a.Note)
+		}
+	}
 
 	b.WriteString("\n")
 	b.WriteString(strings.Repeat("─", min(SeparatorWidth, m.windowWidth)))
 	b.WriteString("\n")
-	b.WriteString(helpStyle.Render("Enter/Esc/q:戻る"))
+	if m.status != "" {
+		b.WriteString(m.status + "\n")
+	}
+	b.WriteString(helpStyle.Render("l:あとで読む  *:スター  t:タイムライン  Enter/Esc/q:戻る"))
 	b.WriteString("\n")
 
 	return b.String()
 }
 
 // runInteractiveMode はインタラクティブモードを実行
-func runInteractiveMode(db *sql.DB) error {
+// redactDomains に一致する訪問は [redacted] として表示する
+// fuzzy が true なら / 検索をあいまい検索にし、一致度の高い順に表示する
+// search は / 検索の設定（全文検索の索引・ひらがなとカタカナを区別しないか・ローマ字を広げるか）
+func runInteractiveMode(db *sql.DB, redactDomains []string, fuzzy bool, search SearchFilter) error {
 	m := newInteractiveModel(db)
+	m.redact = redactDomains
+	m.fuzzy = fuzzy
+	m.filter = search
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	_, err := p.Run()
 	return err
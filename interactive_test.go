@@ -219,6 +219,15 @@ func TestInteractiveModelViewDetail(t *testing.T) {
 	if !contains(view, "https://example.com") {
 		t.Error("詳細ViewにURLが含まれていない")
 	}
+
+	// 訪問タイムラインへのリンクが含まれているか（リダクトした訪問には付けない）
+	if !contains(view, "http://localhost:8080/url?u=https%3A%2F%2Fexample.com") {
+		t.Error("詳細Viewに訪問タイムラインのリンクが含まれていない")
+	}
+	m.detailVisit = &HistoryVisit{Title: redactedLabel, Domain: redactedLabel, URL: redactedLabel}
+	if contains(m.View(), "/url?u=") {
+		t.Error("リダクトした訪問に訪問タイムラインのリンクが含まれている")
+	}
 }
 
 // TestInteractiveModelViewSearch は検索モードの表示テスト
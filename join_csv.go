@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultJoinOn は -join-csv で日付を読む列の既定の名前
+const DefaultJoinOn = "date"
+
+// joinMinDays は相関を求めるのに必要な、指標と訪問数の揃った日数
+const joinMinDays = 3
+
+// joinDateLayouts は -join-csv の日付の列で受け付ける書式
+var joinDateLayouts = []string{TimeFormatDate, "2006/01/02", "2006/1/2"}
+
+// JoinedMetrics は -join-csv で読み込んだ日ごとの指標
+type JoinedMetrics struct {
+	Path   string
+	JoinOn string
+	// Metrics は数値として読めた列（CSVの列の順）
+	Metrics []string
+	// Values は日付ごとの指標の値（空のセルは含めない）
+	Values map[string]map[string]float64
+}
+
+// JoinedDay は指標と訪問数を日付で揃えた1日分
+type JoinedDay struct {
+	Date    string             `json:"date"`
+	Visits  int                `json:"visits"`
+	Metrics map[string]float64 `json:"metrics"`
+	// Categories はカテゴリごとの訪問数（categories.txt があるとき）
+	Categories map[string]int `json:"categories,omitempty"`
+}
+
+// MetricCorrelation は指標1つと日別訪問数（またはカテゴリの訪問数）の相関
+type MetricCorrelation struct {
+	Metric string `json:"metric"`
+	// Target は比べた訪問数（全体なら visits、カテゴリならカテゴリ名）
+	Target   string  `json:"target"`
+	Days     int     `json:"days"`
+	Pearson  float64 `json:"pearson"`
+	Spearman float64 `json:"spearman"`
+}
+
+// JoinReport は -join-csv の結果
+type JoinReport struct {
+	File         string              `json:"file"`
+	JoinOn       string              `json:"join_on"`
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	Metrics      []string            `json:"metrics"`
+	Correlations []MetricCorrelation `json:"correlations"`
+	Daily        []JoinedDay         `json:"daily"`
+}
+
+// joinVisitsTarget は日別訪問数の全体と比べた相関の Target
+const joinVisitsTarget = "visits"
+
+// LoadJoinedMetrics は1行1日のCSV（1行目は見出し）から joinOn の列を日付として、残りの数値の列を指標として読み込む
+// 数値として読めないセルを含む列（メモなど）は指標にしない。同じ日付が複数行あれば後の行で上書きする
+func LoadJoinedMetrics(path, joinOn string) (*JoinedMetrics, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("結合するCSVを開けませんでした: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	metrics, err := parseJoinedMetrics(f, joinOn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	metrics.Path = path
+	return metrics, nil
+}
+
+// parseJoinedMetrics は LoadJoinedMetrics のCSVを解析する
+func parseJoinedMetrics(r io.Reader, joinOn string) (*JoinedMetrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("見出しの行がありません")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSVの読み取りに失敗: %w", err)
+	}
+	if len(header) > 0 {
+		// Excel などが先頭に付ける BOM は列名に含めない
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	dateColumn := -1
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		if strings.EqualFold(header[i], joinOn) {
+			dateColumn = i
+		}
+	}
+	if dateColumn < 0 {
+		return nil, fmt.Errorf("日付の列 %q がありません（-join-on で列名を指定してください）", joinOn)
+	}
+
+	numeric := make([]bool, len(header))
+	for i := range numeric {
+		numeric[i] = i != dateColumn && header[i] != ""
+	}
+	cells := make(map[string][]string)
+	var dates []string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSVの読み取りに失敗: %w", err)
+		}
+		if dateColumn >= len(record) || strings.TrimSpace(record[dateColumn]) == "" {
+			continue
+		}
+		date, err := parseJoinDate(record[dateColumn])
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %w", line, err)
+		}
+		if _, ok := cells[date]; !ok {
+			dates = append(dates, date)
+		}
+		row := make([]string, len(header))
+		for i := range header {
+			if i < len(record) {
+				row[i] = strings.TrimSpace(record[i])
+			}
+			if _, err := strconv.ParseFloat(row[i], 64); row[i] != "" && err != nil {
+				numeric[i] = false
+			}
+		}
+		cells[date] = row
+	}
+
+	metrics := &JoinedMetrics{JoinOn: header[dateColumn], Metrics: []string{}, Values: make(map[string]map[string]float64)}
+	for i, name := range header {
+		if numeric[i] {
+			metrics.Metrics = append(metrics.Metrics, name)
+		}
+	}
+	for _, date := range dates {
+		values := make(map[string]float64)
+		for i, name := range header {
+			if numeric[i] && cells[date][i] != "" {
+				values[name], _ = strconv.ParseFloat(cells[date][i], 64)
+			}
+		}
+		metrics.Values[date] = values
+	}
+	return metrics, nil
+}
+
+// parseJoinDate は -join-csv の日付のセルを YYYY-MM-DD にする（日時なら日付の部分だけを使う）
+func parseJoinDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if date, _, ok := strings.Cut(s, "T"); ok {
+		s = date
+	} else if date, _, ok := strings.Cut(s, " "); ok {
+		s = date
+	}
+	for _, layout := range joinDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(TimeFormatDate), nil
+		}
+	}
+	return "", fmt.Errorf("日付として読めません: %s", s)
+}
+
+// getJoinReport は指標のある日の訪問数（とカテゴリごとの訪問数）を数え、指標ごとに相関を求める
+// 履歴の最初の日より前と今日以降（途中の日）は比べない。訪問のない日は0件として数える
+func getJoinReport(db dbQuerier, metrics *JoinedMetrics, categories []DomainCategory, now time.Time, filter SearchFilter) (*JoinReport, error) {
+	query, args := NewQueryBuilder(groupVisitsBaseQuery).WithFilter(filter).Build()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("結合する日の訪問数の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	earliest := ""
+	visits := make(map[string]int)
+	categoryVisits := make(map[string]map[string]int)
+	categoryTotals := make(map[string]int)
+	for rows.Next() {
+		var visitTime float64
+		var id int64
+		var url string
+		if err := rows.Scan(&id, &visitTime, &url); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		date := convertCoreDataTimestamp(visitTime).Format(TimeFormatDate)
+		if earliest == "" || date < earliest {
+			earliest = date
+		}
+		if _, ok := metrics.Values[date]; !ok {
+			continue
+		}
+		visits[date]++
+		if len(categories) > 0 {
+			category := categoryForDomain(extractDomain(url), categories)
+			if categoryVisits[date] == nil {
+				categoryVisits[date] = make(map[string]int)
+			}
+			categoryVisits[date][category]++
+			categoryTotals[category]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Format(TimeFormatDate)
+	report := &JoinReport{File: metrics.Path, JoinOn: metrics.JoinOn, Metrics: metrics.Metrics, Correlations: []MetricCorrelation{}, Daily: []JoinedDay{}}
+	for date := range metrics.Values {
+		if earliest == "" || date < earliest || date >= today {
+			continue
+		}
+		if (!filter.From.IsZero() && date < filter.From.Format(TimeFormatDate)) || (!filter.To.IsZero() && date > filter.To.Format(TimeFormatDate)) {
+			continue
+		}
+		report.Daily = append(report.Daily, JoinedDay{Date: date, Visits: visits[date], Metrics: metrics.Values[date], Categories: categoryVisits[date]})
+	}
+	sort.Slice(report.Daily, func(i, j int) bool { return report.Daily[i].Date < report.Daily[j].Date })
+	if len(report.Daily) > 0 {
+		report.From, report.To = report.Daily[0].Date, report.Daily[len(report.Daily)-1].Date
+	}
+
+	// カテゴリは訪問の多い順に並べる
+	var targets []string
+	for category := range categoryTotals {
+		targets = append(targets, category)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if categoryTotals[targets[i]] != categoryTotals[targets[j]] {
+			return categoryTotals[targets[i]] > categoryTotals[targets[j]]
+		}
+		return targets[i] < targets[j]
+	})
+	targets = append([]string{joinVisitsTarget}, targets...)
+	for _, metric := range metrics.Metrics {
+		for _, target := range targets {
+			var xs, ys []float64
+			for _, d := range report.Daily {
+				value, ok := d.Metrics[metric]
+				if !ok {
+					continue
+				}
+				count := d.Visits
+				if target != joinVisitsTarget {
+					count = d.Categories[target]
+				}
+				xs = append(xs, value)
+				ys = append(ys, float64(count))
+			}
+			// 日数が足りないか、どちらかが毎日同じ値なら相関は求められない
+			pearson := pearsonCorrelation(xs, ys)
+			if len(xs) < joinMinDays || math.IsNaN(pearson) {
+				continue
+			}
+			report.Correlations = append(report.Correlations, MetricCorrelation{
+				Metric:   metric,
+				Target:   target,
+				Days:     len(xs),
+				Pearson:  pearson,
+				Spearman: pearsonCorrelation(ranks(xs), ranks(ys)),
+			})
+		}
+	}
+	return report, nil
+}
+
+// pearsonCorrelation はピアソンの相関係数（どちらかの分散が0なら NaN）
+func pearsonCorrelation(xs, ys []float64) float64 {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return math.NaN()
+	}
+	xMean, _ := meanAndVariance(xs)
+	yMean, _ := meanAndVariance(ys)
+	var cov, xVar, yVar float64
+	for i := range xs {
+		cov += (xs[i] - xMean) * (ys[i] - yMean)
+		xVar += (xs[i] - xMean) * (xs[i] - xMean)
+		yVar += (ys[i] - yMean) * (ys[i] - yMean)
+	}
+	if xVar == 0 || yVar == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(xVar*yVar)
+}
+
+// ranks は値の順位（1から、同じ値には平均の順位）を返す（スピアマンの順位相関に使う）
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+	result := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start
+		for end+1 < len(order) && values[order[end+1]] == values[order[start]] {
+			end++
+		}
+		rank := float64(start+end)/2 + 1
+		for _, i := range order[start : end+1] {
+			result[i] = rank
+		}
+		start = end + 1
+	}
+	return result
+}
+
+// correlationStrength は相関係数の強さの目安
+func correlationStrength(r float64) string {
+	switch a := math.Abs(r); {
+	case a >= 0.7:
+		return "強い"
+	case a >= 0.4:
+		return "中程度"
+	case a >= 0.2:
+		return "弱い"
+	}
+	return "ほぼなし"
+}
+
+// targetLabel は相関を求めた訪問数の表示名
+func (c MetricCorrelation) targetLabel() string {
+	if c.Target == joinVisitsTarget {
+		return "訪問数"
+	}
+	return "カテゴリ: " + c.Target
+}
+
+// joinReportTitle は -join-csv の見出し
+func joinReportTitle(report JoinReport) string {
+	if len(report.Daily) == 0 {
+		return fmt.Sprintf("🔗 %s との相関 (履歴と重なる日なし)", report.File)
+	}
+	return fmt.Sprintf("🔗 %s との相関 (%s〜%s、%d日間)", report.File, report.From, report.To, len(report.Daily))
+}
+
+// printJoinReport は指標ごとの訪問数との相関をテキストで表示する
+func printJoinReport(w io.Writer, report JoinReport) {
+	_, _ = fmt.Fprintln(w, joinReportTitle(report))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	if len(report.Correlations) == 0 {
+		_, _ = fmt.Fprintf(w, "  相関を求められる指標がありません（指標と訪問数の揃った日が%d日以上必要です）\n\n", joinMinDays)
+		return
+	}
+	metric := ""
+	for _, c := range report.Correlations {
+		if c.Metric != metric {
+			metric = c.Metric
+			_, _ = fmt.Fprintf(w, "  %s:\n", metric)
+		}
+		_, _ = fmt.Fprintf(w, "    %s r=%+.2f ρ=%+.2f（%s、%d日）\n", padRight(truncateText(c.targetLabel(), 30), 32), c.Pearson, c.Spearman, correlationStrength(c.Pearson), c.Days)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJoinedMetrics(t *testing.T) {
+	input := "\ufeffDate,sleep_hours,steps,note\n" +
+		"2025-01-01,7.5,8000,よく寝た\n" +
+		"2025/01/02,6,,\n" +
+		"2025-01-03T00:00:00Z,8,12000,\n" +
+		",5,5,\n"
+	metrics, err := parseJoinedMetrics(strings.NewReader(input), "date")
+	if err != nil {
+		t.Fatalf("parseJoinedMetrics: %v", err)
+	}
+	// 数値として読めない列は指標にせず、日付のない行は読み飛ばす
+	if metrics.JoinOn != "Date" || fmt.Sprint(metrics.Metrics) != "[sleep_hours steps]" || len(metrics.Values) != 3 {
+		t.Errorf("metrics = %+v", metrics)
+	}
+	if got := fmt.Sprint(metrics.Values["2025-01-02"]); got != "map[sleep_hours:6]" {
+		t.Errorf("2025-01-02 = %s", got)
+	}
+	if got := metrics.Values["2025-01-03"]["steps"]; got != 12000 {
+		t.Errorf("2025-01-03 steps = %v", got)
+	}
+
+	for _, tt := range []struct {
+		input string
+		want  string
+	}{
+		{"", "見出しの行がありません"},
+		{"day,sleep\n2025-01-01,7\n", "日付の列 \"date\" がありません"},
+		{"date,sleep\n2025-13-01,7\n", "2行目: 日付として読めません: 2025-13-01"},
+	} {
+		if _, err := parseJoinedMetrics(strings.NewReader(tt.input), "date"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseJoinedMetrics(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+
+	if _, err := LoadJoinedMetrics(filepath.Join(t.TempDir(), "missing.csv"), "date"); err == nil {
+		t.Error("存在しないCSVでエラーにならない")
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	for _, tt := range []struct {
+		xs, ys []float64
+		want   string
+	}{
+		{[]float64{1, 2, 3, 4}, []float64{2, 4, 6, 8}, "1.000"},
+		{[]float64{1, 2, 3, 4}, []float64{8, 6, 4, 2}, "-1.000"},
+		{[]float64{1, 2, 3, 4}, []float64{1, 3, 2, 4}, "0.800"},
+	} {
+		if got := fmt.Sprintf("%.3f", pearsonCorrelation(tt.xs, tt.ys)); got != tt.want {
+			t.Errorf("pearsonCorrelation(%v, %v) = %s, want %s", tt.xs, tt.ys, got, tt.want)
+		}
+	}
+	if r := pearsonCorrelation([]float64{1, 2, 3}, []float64{5, 5, 5}); !math.IsNaN(r) {
+		t.Errorf("分散0の相関 = %v, want NaN", r)
+	}
+	// 同じ値には平均の順位を付ける
+	if got := fmt.Sprint(ranks([]float64{10, 30, 20, 30})); got != "[1 3.5 2 3.5]" {
+		t.Errorf("ranks = %s", got)
+	}
+	for r, want := range map[float64]string{0.75: "強い", -0.5: "中程度", 0.2: "弱い", -0.1: "ほぼなし"} {
+		if got := correlationStrength(r); got != want {
+			t.Errorf("correlationStrength(%v) = %s, want %s", r, got, want)
+		}
+	}
+}
+
+func TestGetJoinReport(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 訪問は 1/1: 3件（github・youtube・google）、1/2: 2件（github・youtube）
+	path := filepath.Join(t.TempDir(), "sleep.csv")
+	csv := "date,sleep,mood\n2024-12-31,9,3\n2025-01-01,6,3\n2025-01-02,7,3\n2025-01-03,8,3\n2025-01-04,5,3\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatalf("CSVの書き込みに失敗: %v", err)
+	}
+	metrics, err := LoadJoinedMetrics(path, "date")
+	if err != nil {
+		t.Fatalf("LoadJoinedMetrics: %v", err)
+	}
+	categories := []DomainCategory{{Domain: "github.com", Category: "開発"}, {Domain: "youtube.com", Category: "動画"}}
+	now := time.Date(2025, 1, 4, 9, 0, 0, 0, time.UTC)
+	report, err := getJoinReport(db, metrics, categories, now, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getJoinReport: %v", err)
+	}
+	// 履歴の最初の日より前と今日は比べない。訪問のない日は0件
+	if report.From != "2025-01-01" || report.To != "2025-01-03" || len(report.Daily) != 3 || report.Daily[2].Visits != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	// 毎日同じ値の mood は相関を求められない
+	var got []string
+	for _, c := range report.Correlations {
+		got = append(got, fmt.Sprintf("%s/%s/%d/%.2f/%.2f", c.Metric, c.Target, c.Days, c.Pearson, c.Spearman))
+	}
+	want := "[sleep/visits/3/-0.98/-1.00 sleep/動画/3/-0.87/-0.87 sleep/開発/3/-0.87/-0.87 sleep/未分類/3/-0.87/-0.87]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Correlations = %v, want %s", got, want)
+	}
+
+	var buf bytes.Buffer
+	printJoinReport(&buf, *report)
+	for _, s := range []string{"sleep.csv との相関 (2025-01-01〜2025-01-03、3日間)", "sleep:", "訪問数", "r=-0.98 ρ=-1.00（強い、3日）", "カテゴリ: 開発"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("出力に %q がない:\n%s", s, buf.String())
+		}
+	}
+}
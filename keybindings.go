@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// keysFileName はWeb UIのキーボード操作のキーの設定ファイル
+// 1行に「操作 = キー」を書く（書かない操作は既定のキーのまま）:
+//
+//	next = n
+//	prev = p
+//	detail = Enter
+//
+// キーは KeyboardEvent.key の値で、英字の大文字・小文字は区別する
+const keysFileName = "keys.txt"
+
+// Web UI のキーボード操作
+const (
+	// KeyActionNext は次の訪問を選ぶ
+	KeyActionNext = "next"
+	// KeyActionPrev は前の訪問を選ぶ
+	KeyActionPrev = "prev"
+	// KeyActionSearch は検索欄に移動する
+	KeyActionSearch = "search"
+	// KeyActionOpen は選んだ訪問のURLを新しいタブで開く
+	KeyActionOpen = "open"
+	// KeyActionDetail は選んだ訪問の詳細を表示する
+	KeyActionDetail = "detail"
+)
+
+// keyBindingNamedKeys は1文字以外で指定できるキー（Escape は詳細や候補を閉じるのに使うため割り当てられない）
+var keyBindingNamedKeys = []string{"Enter", "ArrowUp", "ArrowDown", "ArrowLeft", "ArrowRight", "Home", "End", "PageUp", "PageDown"}
+
+// KeyBinding は操作1つとそのキー
+type KeyBinding struct {
+	Action      string `json:"action"`
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+// defaultKeyBindings は keys.txt がない場合のキー（この順で画面のヒントに表示する）
+var defaultKeyBindings = []KeyBinding{
+	{KeyActionNext, "j", "次の訪問"},
+	{KeyActionPrev, "k", "前の訪問"},
+	{KeyActionOpen, "o", "開く"},
+	{KeyActionDetail, "Enter", "詳細"},
+	{KeyActionSearch, "/", "検索"},
+}
+
+// parseKeyBindings は keys.txt の行を解析する（書かれていない操作は既定のキー）
+func parseKeyBindings(lines []string) ([]KeyBinding, error) {
+	bindings := slices.Clone(defaultKeyBindings)
+	for _, line := range lines {
+		action, key, ok := strings.Cut(line, "=")
+		action, key = strings.ToLower(strings.TrimSpace(action)), strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("キーの設定は「操作 = キー」形式で指定してください: %s", line)
+		}
+		i := slices.IndexFunc(bindings, func(b KeyBinding) bool { return b.Action == action })
+		if i < 0 {
+			var actions []string
+			for _, b := range defaultKeyBindings {
+				actions = append(actions, b.Action)
+			}
+			return nil, fmt.Errorf("キーの操作は %s のいずれかで指定してください: %s", strings.Join(actions, "・"), action)
+		}
+		if utf8.RuneCountInString(key) != 1 && !slices.Contains(keyBindingNamedKeys, key) {
+			return nil, fmt.Errorf("キーは1文字か %s のいずれかで指定してください: %s", strings.Join(keyBindingNamedKeys, "・"), key)
+		}
+		bindings[i].Key = key
+	}
+	for i, b := range bindings {
+		for _, other := range bindings[:i] {
+			if b.Key == other.Key {
+				return nil, fmt.Errorf("キー %s が %s と %s の両方に割り当てられています", b.Key, other.Action, b.Action)
+			}
+		}
+	}
+	return bindings, nil
+}
+
+// LoadKeyBindings はWeb UIのキーの設定を読み込む
+func LoadKeyBindings() ([]KeyBinding, error) {
+	lines, err := loadDomainListFile(keysFileName, "キーの設定")
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyBindings(lines)
+}
+
+// handleAPIKeyBindings はWeb UIのキーボード操作のキーをJSONで返す（web/static/keys.js が読み込む）
+func (s *WebServer) handleAPIKeyBindings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.keyBindingList()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
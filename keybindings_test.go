@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyBindings(t *testing.T) {
+	bindings, err := parseKeyBindings(nil)
+	if err != nil || len(bindings) != len(defaultKeyBindings) || bindings[0].Key != "j" {
+		t.Fatalf("parseKeyBindings(nil) = %+v, %v", bindings, err)
+	}
+
+	bindings, err = parseKeyBindings([]string{"next = n", "PREV = p", "open = ArrowRight"})
+	if err != nil {
+		t.Fatalf("parseKeyBindings: %v", err)
+	}
+	keys := make(map[string]string)
+	for _, b := range bindings {
+		keys[b.Action] = b.Key
+	}
+	if keys[KeyActionNext] != "n" || keys[KeyActionPrev] != "p" || keys[KeyActionOpen] != "ArrowRight" || keys[KeyActionSearch] != "/" {
+		t.Errorf("キー = %v", keys)
+	}
+	if defaultKeyBindings[0].Key != "j" {
+		t.Error("既定のキーが書き換えられた")
+	}
+
+	for _, lines := range [][]string{
+		{"next"},
+		{"next ="},
+		{"scroll = s"},
+		{"next = ab"},
+		{"next = Escape"},
+		{"next = k"},
+	} {
+		if _, err := parseKeyBindings(lines); err == nil {
+			t.Errorf("parseKeyBindings(%q) がエラーにならなかった", lines)
+		}
+	}
+}
+
+func TestHandleAPIKeyBindings(t *testing.T) {
+	s := &WebServer{}
+	get := func() []KeyBinding {
+		rec := httptest.NewRecorder()
+		s.handleAPIKeyBindings(rec, httptest.NewRequest("GET", "/api/keybindings", nil))
+		var bindings []KeyBinding
+		if err := json.Unmarshal(rec.Body.Bytes(), &bindings); err != nil {
+			t.Fatalf("JSON の解析に失敗: %v", err)
+		}
+		return bindings
+	}
+	if bindings := get(); len(bindings) != len(defaultKeyBindings) || bindings[0].Action != KeyActionNext {
+		t.Errorf("既定のキー = %+v", bindings)
+	}
+	s.keyBindings = []KeyBinding{{KeyActionNext, "n", "次の訪問"}}
+	if bindings := get(); len(bindings) != 1 || bindings[0].Key != "n" {
+		t.Errorf("設定したキー = %+v", bindings)
+	}
+}
+
+func TestHistoryPageKeyboard(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl}
+	defer func() { _ = s.db.Close() }()
+
+	rec := httptest.NewRecorder()
+	s.handleHistory(rec, httptest.NewRequest("GET", "/history", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `src="/static/keys.js"`) || !strings.Contains(body, `data-url="https://github.com/test"`) || !strings.Contains(body, `id="keyHints"`) {
+		t.Error("履歴ページにキーボード操作のスクリプト・行のURLがない")
+	}
+
+	static, err := staticHandler()
+	if err != nil {
+		t.Fatalf("staticHandler() error = %v", err)
+	}
+	rec = httptest.NewRecorder()
+	static.ServeHTTP(rec, httptest.NewRequest("GET", "/static/keys.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/api/keybindings") {
+		t.Errorf("/static/keys.js = %d", rec.Code)
+	}
+}
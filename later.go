@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// laterFileName は「あとで読む」キューのファイル（設定ディレクトリに置く）
+const laterFileName = "later.json"
+
+// laterMu は Web サーバーの複数のリクエストが同時にキューを書き換えないためのロック
+var laterMu sync.Mutex
+
+// LaterItem は「あとで読む」キューの1件
+type LaterItem struct {
+	URL     string    `json:"url"`
+	Title   string    `json:"title,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+	// DoneAt は読み終えた日時（未読なら nil）
+	DoneAt *time.Time `json:"done_at,omitempty"`
+}
+
+// LaterQueue は「あとで読む」キュー（追加した順）
+type LaterQueue struct {
+	Items []LaterItem `json:"items"`
+}
+
+// errLaterNotFound はキューにないURLを指定した場合のエラー
+var errLaterNotFound = errors.New("「あとで読む」にないURLです")
+
+// getLaterPath は「あとで読む」キューのファイルパスを返す
+func getLaterPath() (string, error) {
+	return getConfigFilePath(laterFileName)
+}
+
+// loadLaterQueue は path のキューを読み込む（ファイルがなければ空のキュー）
+func loadLaterQueue(path string) (*LaterQueue, error) {
+	q := &LaterQueue{Items: []LaterItem{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("「あとで読む」の読み込みに失敗: %w", err)
+	}
+	if err := json.Unmarshal(data, q); err != nil {
+		return nil, fmt.Errorf("「あとで読む」の解析に失敗（%s）: %w", path, err)
+	}
+	return q, nil
+}
+
+// save はキューを path に書き出す（書きかけのファイルを読まれないよう一時ファイルから置き換える）
+func (q *LaterQueue) save(path string) error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerms); err != nil {
+		return fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), configFilePerms); err != nil {
+		return fmt.Errorf("「あとで読む」の書き込みに失敗: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("「あとで読む」の書き込みに失敗: %w", err)
+	}
+	return nil
+}
+
+// updateLaterQueue は path のキューを読み込んで fn で書き換え、書き出す
+func updateLaterQueue(path string, fn func(q *LaterQueue) error) error {
+	laterMu.Lock()
+	defer laterMu.Unlock()
+	q, err := loadLaterQueue(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(q); err != nil {
+		return err
+	}
+	return q.save(path)
+}
+
+// find は rawURL の項目の位置を返す（なければ -1）
+func (q *LaterQueue) find(rawURL string) int {
+	for i, item := range q.Items {
+		if item.URL == rawURL {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add は rawURL を未読として追加する（すでにあれば未読に戻し、タイトルを補う）
+// 新しく追加した場合は true
+func (q *LaterQueue) Add(rawURL, title string, now time.Time) bool {
+	if i := q.find(rawURL); i >= 0 {
+		q.Items[i].DoneAt = nil
+		if q.Items[i].Title == "" {
+			q.Items[i].Title = title
+		}
+		return false
+	}
+	q.Items = append(q.Items, LaterItem{URL: rawURL, Title: title, AddedAt: now})
+	return true
+}
+
+// SetDone は rawURL を既読（done が false なら未読）にする
+func (q *LaterQueue) SetDone(rawURL string, done bool, now time.Time) error {
+	i := q.find(rawURL)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", errLaterNotFound, rawURL)
+	}
+	q.Items[i].DoneAt = nil
+	if done {
+		q.Items[i].DoneAt = &now
+	}
+	return nil
+}
+
+// Remove は rawURL をキューから削除する
+func (q *LaterQueue) Remove(rawURL string) error {
+	i := q.find(rawURL)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", errLaterNotFound, rawURL)
+	}
+	q.Items = append(q.Items[:i], q.Items[i+1:]...)
+	return nil
+}
+
+// Pending は未読の項目を追加した順に返す
+func (q *LaterQueue) Pending() []LaterItem {
+	var pending []LaterItem
+	for _, item := range q.Items {
+		if item.DoneAt == nil {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// validLaterURL はキューに追加できるURLか（http(s) のみ。リダクトで伏せたURLも不可）
+func validLaterURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("「あとで読む」に追加できるのは http(s) のURLだけです: %s", rawURL)
+	}
+	return nil
+}
+
+// addToLater は rawURL を「あとで読む」に追加する（title が空なら履歴の最後の訪問のタイトルを使う）
+// 新しく追加した場合は true
+func addToLater(db dbQuerier, rawURL, title string) (bool, error) {
+	if err := validLaterURL(rawURL); err != nil {
+		return false, err
+	}
+	if title == "" && db != nil {
+		if v, ok, err := latestVisit(db, rawURL); err == nil && ok {
+			title = v.Title
+		}
+	}
+	path, err := getLaterPath()
+	if err != nil {
+		return false, err
+	}
+	var added bool
+	err = updateLaterQueue(path, func(q *LaterQueue) error {
+		added = q.Add(rawURL, title, time.Now())
+		return nil
+	})
+	return added, err
+}
+
+// printLaterItems は「あとで読む」の項目を出力する
+func printLaterItems(w io.Writer, items []LaterItem) {
+	if len(items) == 0 {
+		_, _ = fmt.Fprintln(w, "「あとで読む」は空です")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "📌 あとで読む (%d件)\n", len(items))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, item := range items {
+		mark := "[ ]"
+		if item.DoneAt != nil {
+			mark = "[x]"
+		}
+		title := item.Title
+		if title == "" {
+			title = "(タイトルなし)"
+		}
+		_, _ = fmt.Fprintf(w, "  %s %s  %s\n", mark, item.AddedAt.Local().Format(TimeFormatDate), truncateText(title, MaxTitleLength))
+		_, _ = fmt.Fprintf(w, "                     %s\n", item.URL)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// runLaterCommand は hist later サブコマンドを実行する
+func runLaterCommand(args []string) error {
+	usage := "使い方: hist later add [-title タイトル] <URL> | list [-all] [-json] | done <URL> | undone <URL> | remove <URL>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	path, err := getLaterPath()
+	if err != nil {
+		return err
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "add":
+		fs := flag.NewFlagSet("later add", flag.ContinueOnError)
+		title := fs.String("title", "", "タイトル（省略時は履歴の最後の訪問のタイトル）")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("追加するURLを1つ指定してください")
+		}
+		// 履歴DBを開けなくても、タイトルなしで追加できる
+		var db dbQuerier
+		if conn, err := setupDatabase(); err == nil {
+			defer func() { _ = conn.Close() }()
+			db = conn
+		}
+		added, err := addToLater(db, fs.Arg(0), *title)
+		if err != nil {
+			return err
+		}
+		if added {
+			fmt.Printf("「あとで読む」に追加しました: %s\n", fs.Arg(0))
+		} else {
+			fmt.Printf("「あとで読む」にすでにあります（未読に戻しました）: %s\n", fs.Arg(0))
+		}
+		return nil
+
+	case "list":
+		fs := flag.NewFlagSet("later list", flag.ContinueOnError)
+		all := fs.Bool("all", false, "読み終えた項目も表示")
+		jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		q, err := loadLaterQueue(path)
+		if err != nil {
+			return err
+		}
+		items := q.Items
+		if !*all {
+			items = q.Pending()
+		}
+		if *jsonOutput {
+			if items == nil {
+				items = []LaterItem{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(items)
+		}
+		printLaterItems(os.Stdout, items)
+		return nil
+
+	case "done", "undone", "remove":
+		if len(args) != 1 {
+			return fmt.Errorf("hist later %s にはURLを1つ指定してください", action)
+		}
+		err := updateLaterQueue(path, func(q *LaterQueue) error {
+			if action == "remove" {
+				return q.Remove(args[0])
+			}
+			return q.SetDone(args[0], action == "done", time.Now())
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", map[string]string{"done": "既読にしました", "undone": "未読に戻しました", "remove": "削除しました"}[action], args[0])
+		return nil
+	}
+	return errors.New(usage)
+}
+
+// LaterPageData は「あとで読む」ページ用のデータ
+type LaterPageData struct {
+	Theme   PageTheme
+	Pending []LaterItem
+	Done    []LaterItem
+}
+
+// handleLaterPage は「あとで読む」ページを表示
+func (s *WebServer) handleLaterPage(w http.ResponseWriter, r *http.Request) {
+	q, err := loadLaterQueue(s.laterPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := LaterPageData{Theme: requestTheme(w, r)}
+	for _, item := range q.Items {
+		if item.DoneAt == nil {
+			data.Pending = append(data.Pending, item)
+		} else {
+			data.Done = append(data.Done, item)
+		}
+	}
+	if err := s.templates.ExecuteTemplate(w, "later.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// LaterRequest は POST /api/later のリクエスト
+// Done を省略すると追加、指定すると既読・未読の切り替え
+type LaterRequest struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	Done  *bool  `json:"done,omitempty"`
+}
+
+// handleAPILater は「あとで読む」の API
+//
+//	GET    /api/later          キューの全項目
+//	POST   /api/later          {"url": ..., "title": ...} で追加、{"url": ..., "done": true} で既読・未読の切り替え
+//	DELETE /api/later?url=...  削除
+//
+// 書き換えは Content-Type: application/json のリクエストだけを受け付ける（他のサイトのフォームから送らせないため）
+func (s *WebServer) handleAPILater(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q, err := loadLaterQueue(s.laterPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(q.Items); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			http.Error(w, "Content-Type は application/json にしてください", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req LaterRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, WebMaxRequestBody)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("リクエストの解析に失敗: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validLaterURL(req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := updateLaterQueue(s.laterPath, func(q *LaterQueue) error {
+			if req.Done != nil {
+				return q.SetDone(req.URL, *req.Done, time.Now())
+			}
+			q.Add(req.URL, req.Title, time.Now())
+			return nil
+		})
+		s.writeLaterResult(w, err)
+
+	case http.MethodDelete:
+		err := updateLaterQueue(s.laterPath, func(q *LaterQueue) error {
+			return q.Remove(r.URL.Query().Get("url"))
+		})
+		s.writeLaterResult(w, err)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "許可されていないメソッドです", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeLaterResult はキューを書き換えた結果を返す（成功なら 204）
+func (s *WebServer) writeLaterResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errLaterNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
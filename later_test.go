@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestLaterQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "later.json")
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	err := updateLaterQueue(path, func(q *LaterQueue) error {
+		if !q.Add("https://example.com/a", "A", now) || !q.Add("https://example.com/b", "", now) {
+			t.Error("新しいURLの Add() が false")
+		}
+		return q.SetDone("https://example.com/a", true, now)
+	})
+	if err != nil {
+		t.Fatalf("updateLaterQueue() error = %v", err)
+	}
+
+	q, err := loadLaterQueue(path)
+	if err != nil {
+		t.Fatalf("loadLaterQueue() error = %v", err)
+	}
+	if len(q.Items) != 2 || q.Items[0].DoneAt == nil || !q.Items[0].DoneAt.Equal(now) {
+		t.Fatalf("Items = %+v", q.Items)
+	}
+	if pending := q.Pending(); len(pending) != 1 || pending[0].URL != "https://example.com/b" {
+		t.Errorf("Pending() = %+v", pending)
+	}
+
+	// 追加し直すと未読に戻り、空のタイトルを補う
+	if q.Add("https://example.com/a", "", now) || q.Add("https://example.com/b", "B", now) {
+		t.Error("すでにあるURLの Add() が true")
+	}
+	if q.Items[0].DoneAt != nil || q.Items[0].Title != "A" || q.Items[1].Title != "B" {
+		t.Errorf("追加し直した後の Items = %+v", q.Items)
+	}
+
+	if err := q.Remove("https://example.com/a"); err != nil || len(q.Items) != 1 {
+		t.Errorf("Remove() = %v, Items = %+v", err, q.Items)
+	}
+	if err := q.SetDone("https://example.com/none", true, now); !errors.Is(err, errLaterNotFound) {
+		t.Errorf("キューにないURLの SetDone() error = %v", err)
+	}
+	if err := validLaterURL(redactedLabel); err == nil {
+		t.Error("リダクトしたURLを追加できる")
+	}
+}
+
+func TestHandleAPILater(t *testing.T) {
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{templates: tmpl, laterPath: filepath.Join(t.TempDir(), "later.json")}
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		s.handleAPILater(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"追加", "POST", "/api/later", "application/json", `{"url": "https://example.com/a", "title": "A"}`, http.StatusNoContent},
+		{"追加", "POST", "/api/later", "application/json", `{"url": "https://example.com/b"}`, http.StatusNoContent},
+		{"フォームからは送れない", "POST", "/api/later", "application/x-www-form-urlencoded", `url=https://example.com/c`, http.StatusUnsupportedMediaType},
+		{"http(s) 以外", "POST", "/api/later", "application/json", `{"url": "javascript:alert(1)"}`, http.StatusBadRequest},
+		{"既読", "POST", "/api/later", "application/json", `{"url": "https://example.com/a", "done": true}`, http.StatusNoContent},
+		{"キューにない", "POST", "/api/later", "application/json", `{"url": "https://example.com/none", "done": true}`, http.StatusNotFound},
+		{"削除", "DELETE", "/api/later?url=https://example.com/b", "", "", http.StatusNoContent},
+		{"PUT は不可", "PUT", "/api/later", "application/json", `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := send(tt.method, tt.target, tt.contentType, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: ステータス = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	rec := send("GET", "/api/later", "", "")
+	if body := rec.Body.String(); !strings.Contains(body, `"url":"https://example.com/a"`) || !strings.Contains(body, `"done_at"`) || strings.Contains(body, "example.com/b") {
+		t.Errorf("GET /api/later = %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleLaterPage(rec, httptest.NewRequest("GET", "/later", nil))
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "既読 1件") || !strings.Contains(body, `data-url="https://example.com/a"`) {
+		t.Errorf("/later のステータス = %d\n%s", rec.Code, body)
+	}
+}
+
+func TestInteractiveSaveForLater(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	m := newInteractiveModel(db)
+	m.visits = []HistoryVisit{{URL: "https://example.com/a", Title: "A"}}
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	if cmd == nil {
+		t.Fatal("l キーでコマンドが返らない")
+	}
+	updated, _ := m.Update(cmd())
+	if status := updated.(interactiveModel).status; status != "「あとで読む」に追加しました" {
+		t.Errorf("status = %q", status)
+	}
+
+	path, err := getLaterPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := loadLaterQueue(path)
+	if err != nil {
+		t.Fatalf("loadLaterQueue() error = %v", err)
+	}
+	if len(q.Items) != 1 || q.Items[0].Title != "A" {
+		t.Errorf("Items = %+v", q.Items)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCheckLimit は hist check で確認するURLの数
+	defaultCheckLimit = 100
+	// defaultCheckRate は hist check で1秒あたりに送るリクエストの数
+	defaultCheckRate = 2.0
+	// checkWorkers は hist check で同時に待つリクエストの数（間隔は -rate で決まる）
+	checkWorkers = 4
+	// LinkCheckTimeout はURL1件の確認を打ち切るまでの時間
+	LinkCheckTimeout = 10 * time.Second
+)
+
+// リンクの確認結果
+const (
+	LinkStatusOK         = "ok"         // 2xx
+	LinkStatusRedirected = "redirected" // 転送された先で 2xx
+	LinkStatusDead       = "dead"       // 404・410、またはホストが存在しない
+	LinkStatusError      = "error"      // タイムアウト・5xx・アクセス拒否など、リンク切れとは言えないもの
+)
+
+// linkStatusOrder はテキスト出力で結果を並べる順序
+var linkStatusOrder = []string{LinkStatusDead, LinkStatusError, LinkStatusRedirected, LinkStatusOK}
+
+// linkStatusLabels は結果の表示名
+var linkStatusLabels = map[string]string{
+	LinkStatusOK:         "正常",
+	LinkStatusRedirected: "転送",
+	LinkStatusDead:       "リンク切れ",
+	LinkStatusError:      "確認できず",
+}
+
+// LinkCheckResult は履歴のURL1件の確認結果
+type LinkCheckResult struct {
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	VisitCount int       `json:"visit_count"`
+	LastVisit  time.Time `json:"last_visit"`
+	Status     string    `json:"status"`
+	StatusCode int       `json:"status_code,omitempty"`
+	// FinalURL は転送された場合の転送先
+	FinalURL string `json:"final_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Wayback はリンク切れのURLの、最後の訪問に最も近い Wayback Machine のスナップショット（-wayback）
+	Wayback *WaybackSnapshot `json:"wayback,omitempty"`
+}
+
+// LinkCheckReport は hist check の結果
+type LinkCheckReport struct {
+	Checked int               `json:"checked"`
+	Counts  map[string]int    `json:"counts"`
+	Links   []LinkCheckResult `json:"links"`
+}
+
+// collectCheckLinks は確認する http(s) のURLを最後の訪問が新しい順に limit 件集める（0 なら全件）
+// ローカル・社内ホストは手元のネットワーク次第で結果が変わるため確認しない
+func collectCheckLinks(db dbQuerier, limit int, filter SearchFilter) ([]LinkCheckResult, error) {
+	var links []LinkCheckResult
+	index := make(map[string]int)
+	errLimit := errors.New("limit")
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		if i, ok := index[v.URL]; ok {
+			links[i].VisitCount++
+			return nil
+		}
+		u, err := url.Parse(v.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || isLocalHost(u.Hostname()) {
+			return nil
+		}
+		if limit > 0 && len(links) == limit {
+			return errLimit
+		}
+		index[v.URL] = len(links)
+		links = append(links, LinkCheckResult{URL: v.URL, Title: v.Title, VisitCount: 1, LastVisit: v.VisitTime})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return nil, fmt.Errorf("確認するURLの取得に失敗: %w", err)
+	}
+	return links, nil
+}
+
+// linkChecker はURLが今も開けるかを確認する
+type linkChecker struct {
+	client *http.Client
+	// interval はリクエストを送る間隔（同じサイトに負荷をかけないため）
+	interval time.Duration
+}
+
+// newLinkChecker は1秒あたり rate 件までリクエストを送る linkChecker を作る
+func newLinkChecker(rate float64, timeout time.Duration) *linkChecker {
+	return &linkChecker{
+		client:   &http.Client{Timeout: timeout},
+		interval: time.Duration(float64(time.Second) / rate),
+	}
+}
+
+// checkAll は links を確認して結果を書き込む（progress は1件確認するごとに呼ぶ、nil 可）
+func (c *linkChecker) checkAll(ctx context.Context, links []LinkCheckResult, progress func(done int)) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	var mu sync.Mutex
+	done := 0
+	g := newTaskGroup(checkWorkers)
+	for i := range links {
+		g.Go(func() error {
+			defer func() {
+				mu.Lock()
+				defer mu.Unlock()
+				done++
+				if progress != nil {
+					progress(done)
+				}
+			}()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				links[i].Status, links[i].Error = LinkStatusError, ctx.Err().Error()
+				return nil
+			}
+			c.check(ctx, &links[i])
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// check は HEAD で1件を確認する（HEAD を受け付けないサーバーには GET で確かめる）
+func (c *linkChecker) check(ctx context.Context, link *LinkCheckResult) {
+	resp, err := c.request(ctx, http.MethodHead, link.URL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusForbidden) {
+		_ = resp.Body.Close()
+		resp, err = c.request(ctx, http.MethodGet, link.URL)
+	}
+	if err != nil {
+		link.Status, link.Error = LinkStatusError, err.Error()
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			link.Status = LinkStatusDead
+		}
+		return
+	}
+	_ = resp.Body.Close()
+
+	link.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		link.Status = LinkStatusDead
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		link.Status = LinkStatusOK
+		if final := resp.Request.URL.String(); final != link.URL {
+			link.Status, link.FinalURL = LinkStatusRedirected, final
+		}
+	default:
+		link.Status = LinkStatusError
+	}
+}
+
+// request は本文を読まずにリクエストを送る（GET は先頭1バイトだけを求める）
+func (c *linkChecker) request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "hist/"+toolVersion())
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	return c.client.Do(req)
+}
+
+// addWaybackSnapshots はリンク切れのURLに、最後の訪問に最も近い Wayback Machine のスナップショットを添える
+// 可用性APIには interval の間隔で問い合わせる
+func addWaybackSnapshots(ctx context.Context, client *WaybackClient, links []LinkCheckResult, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := range links {
+		if links[i].Status != LinkStatusDead {
+			continue
+		}
+		snapshot, err := client.Lookup(ctx, links[i].URL, links[i].LastVisit)
+		if err != nil {
+			return err
+		}
+		links[i].Wayback = snapshot
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// newLinkCheckReport は確認結果を状態ごとに数える
+func newLinkCheckReport(links []LinkCheckResult) LinkCheckReport {
+	report := LinkCheckReport{Checked: len(links), Counts: make(map[string]int), Links: links}
+	for _, status := range linkStatusOrder {
+		report.Counts[status] = 0
+	}
+	for _, l := range links {
+		report.Counts[l.Status]++
+	}
+	if report.Links == nil {
+		report.Links = []LinkCheckResult{}
+	}
+	return report
+}
+
+// printLinkCheckReport は確認結果を出力する（all でなければ正常・転送のURLは件数だけ）
+func printLinkCheckReport(w io.Writer, report LinkCheckReport, all bool) {
+	_, _ = fmt.Fprintf(w, "🔗 リンクの確認 (%d件)\n", report.Checked)
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	var counts []string
+	for _, status := range linkStatusOrder {
+		counts = append(counts, fmt.Sprintf("%s %d", linkStatusLabels[status], report.Counts[status]))
+	}
+	_, _ = fmt.Fprintf(w, "  %s\n", strings.Join(counts, " / "))
+
+	for _, status := range linkStatusOrder {
+		if !all && (status == LinkStatusOK || status == LinkStatusRedirected) {
+			continue
+		}
+		header := false
+		for _, l := range report.Links {
+			if l.Status != status {
+				continue
+			}
+			if !header {
+				_, _ = fmt.Fprintf(w, "\n  %s:\n", linkStatusLabels[status])
+				header = true
+			}
+			detail := l.Error
+			if l.StatusCode != 0 {
+				detail = fmt.Sprintf("%d", l.StatusCode)
+			}
+			_, _ = fmt.Fprintf(w, "    %s  %s  (%s)\n", l.LastVisit.Local().Format(TimeFormatDate), l.URL, detail)
+			if l.Title != "" {
+				_, _ = fmt.Fprintf(w, "                %s\n", truncateText(l.Title, 60))
+			}
+			if l.FinalURL != "" {
+				_, _ = fmt.Fprintf(w, "                → %s\n", l.FinalURL)
+			}
+			if l.Wayback != nil {
+				_, _ = fmt.Fprintf(w, "                🗄  %s\n", l.Wayback.URL)
+			}
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// runCheckCommand は hist check サブコマンドを実行する
+func runCheckCommand(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	domain := fs.String("domain", "", "ドメインで絞り込み")
+	search := fs.String("search", "", "キーワードで絞り込み")
+	from := fs.String("from", "", "開始日（YYYY-MM-DD）")
+	to := fs.String("to", "", "終了日（YYYY-MM-DD）")
+	limit := fs.Int("limit", defaultCheckLimit, "確認するURLの数（最後の訪問が新しい順、0 で全て）")
+	rate := fs.Float64("rate", defaultCheckRate, "1秒あたりに送るリクエストの数")
+	timeout := fs.Duration("timeout", LinkCheckTimeout, "URL1件の確認を打ち切るまでの時間")
+	noIgnore := fs.Bool("no-ignore", false, "イグノアリストを適用せずに確認")
+	all := fs.Bool("all", false, "正常・転送のURLも表示")
+	wayback := fs.Bool("wayback", false, "リンク切れのURLに Wayback Machine のスナップショットを添える")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist check [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  履歴のURLに HEAD リクエストを送り、リンク切れ（404・410・存在しないホスト）を探します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *limit < 0 || *rate <= 0 || *timeout <= 0 {
+		return errors.New("-limit は0以上、-rate と -timeout は正の値で指定してください")
+	}
+
+	filter := SearchFilter{Keyword: *search, Domain: *domain}
+	for _, d := range []struct {
+		value string
+		dst   *time.Time
+	}{{*from, &filter.From}, {*to, &filter.To}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := parseLocalDate(d.value)
+		if err != nil {
+			return fmt.Errorf("日付の形式が不正です（YYYY-MM-DD）: %s", d.value)
+		}
+		*d.dst = t
+	}
+	if !*noIgnore {
+		entries, err := LoadIgnoreList()
+		if err != nil {
+			return fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+		}
+		filter.IgnoreDomains, filter.IgnoreRules, err = splitIgnoreEntries(entries)
+		if err != nil {
+			return err
+		}
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	links, err := collectCheckLinks(db, *limit, filter)
+	if err != nil {
+		return err
+	}
+
+	checker := newLinkChecker(*rate, *timeout)
+	checker.checkAll(context.Background(), links, func(done int) {
+		fmt.Fprintf(os.Stderr, "\r確認中 %d/%d", done, len(links))
+		if done == len(links) {
+			fmt.Fprintln(os.Stderr)
+		}
+	})
+	if *wayback {
+		client, err := newWaybackClient()
+		if err != nil {
+			return err
+		}
+		// 見つかった分はキャッシュに残し、問い合わせに失敗しても確認結果は出力する
+		if err := addWaybackSnapshots(context.Background(), client, links, checker.interval); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		}
+		if err := client.Save(); err != nil {
+			return err
+		}
+	}
+	report := newLinkCheckReport(links)
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printLinkCheckReport(os.Stdout, report, *all)
+	return nil
+}
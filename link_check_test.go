@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectCheckLinks(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'http://localhost:3000/app', NULL, 1),
+		(6, 'file:///tmp/a.html', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757418400 + 100, 'ローカル'),
+		(7, 6, 757418400 + 200, 'ファイル');
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	links, err := collectCheckLinks(db, 0, SearchFilter{})
+	if err != nil {
+		t.Fatalf("collectCheckLinks() error = %v", err)
+	}
+	// ローカルホストと http(s) 以外は除き、同じURLは1件にまとめる
+	var urls []string
+	for _, l := range links {
+		urls = append(urls, l.URL)
+	}
+	want := "https://youtube.com/watch,https://github.com/test,https://google.com/search"
+	if got := strings.Join(urls, ","); got != want {
+		t.Errorf("URL = %s, want %s", got, want)
+	}
+	if links[0].VisitCount != 2 || links[0].Title != "YouTube - Music" {
+		t.Errorf("youtube.com/watch = %+v, want 最後の訪問のタイトルで2回", links[0])
+	}
+
+	limited, err := collectCheckLinks(db, 2, SearchFilter{Domain: "github.com"})
+	if err != nil {
+		t.Fatalf("collectCheckLinks(limit) error = %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("github.com で %d件, want 1", len(limited))
+	}
+}
+
+func TestLinkCheckerCheckAll(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	paths := []string{"/ok", "/moved", "/gone", "/missing", "/no-head", "/broken"}
+	links := make([]LinkCheckResult, len(paths))
+	for i, p := range paths {
+		links[i].URL = ts.URL + p
+	}
+	checker := newLinkChecker(100, time.Second)
+	var calls int
+	start := time.Now()
+	checker.checkAll(context.Background(), links, func(int) { calls++ })
+	// 1秒あたり100件なので、6件の確認には少なくとも60ミリ秒かかる
+	if elapsed := time.Since(start); elapsed < 6*checker.interval {
+		t.Errorf("確認に %v, want %v 以上", elapsed, 6*checker.interval)
+	}
+	if calls != len(paths) {
+		t.Errorf("progress の呼び出し = %d回, want %d", calls, len(paths))
+	}
+
+	want := []struct {
+		status string
+		code   int
+	}{
+		{LinkStatusOK, 200},
+		{LinkStatusRedirected, 200},
+		{LinkStatusDead, 410},
+		{LinkStatusDead, 404},
+		{LinkStatusOK, 200},
+		{LinkStatusError, 500},
+	}
+	for i, w := range want {
+		if links[i].Status != w.status || links[i].StatusCode != w.code {
+			t.Errorf("%s = %s %d, want %s %d", paths[i], links[i].Status, links[i].StatusCode, w.status, w.code)
+		}
+	}
+	if links[1].FinalURL != ts.URL+"/ok" {
+		t.Errorf("転送先 = %q", links[1].FinalURL)
+	}
+
+	report := newLinkCheckReport(links)
+	if report.Counts[LinkStatusDead] != 2 || report.Counts[LinkStatusOK] != 2 {
+		t.Errorf("Counts = %v", report.Counts)
+	}
+	var sb strings.Builder
+	printLinkCheckReport(&sb, report, false)
+	if out := sb.String(); !strings.Contains(out, "/gone") || strings.Contains(out, "/moved") {
+		t.Errorf("-all なしでリンク切れだけを表示していない:\n%s", out)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// localHostSuffixes はローカル・社内ネットワークで使われるホスト名の末尾
+var localHostSuffixes = []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"}
+
+// LocalHostStats はローカル・社内ホスト（ホスト名とポートの組）ごとの訪問数
+type LocalHostStats struct {
+	Host       string      `json:"host"`
+	Port       string      `json:"port"`
+	VisitCount int         `json:"visit_count"`
+	Paths      []PathStats `json:"paths,omitempty"`
+	OtherCount int         `json:"other_count,omitempty"`
+	LastVisit  time.Time   `json:"last_visit"`
+}
+
+// Addr は「ホスト:ポート」形式の表示名を返す
+func (s LocalHostStats) Addr() string {
+	return net.JoinHostPort(s.Host, s.Port)
+}
+
+// isLocalHost は localhost・ループバック・プライベートIP・.local や .internal などの社内向けホストかを返す
+func isLocalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	for _, suffix := range localHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast()
+	}
+	return false
+}
+
+// parseLocalURL はローカル・社内ホストのURLからホスト・ポート・パスを取り出す
+// ポートが省略されていればスキームの既定ポートを補う
+func parseLocalURL(rawURL string) (host, port, path string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", "", false
+	}
+	host = strings.ToLower(u.Hostname())
+	if !isLocalHost(host) {
+		return "", "", "", false
+	}
+	port = u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	path = u.Path
+	if path == "" {
+		path = "/"
+	}
+	return host, port, path, true
+}
+
+// getLocalHostStats はローカル・社内ホストへの訪問をホスト・ポートごとに集計し、訪問数の多い順に返す
+// limit はホストの表示件数、pathLimit は各ホストに表示するパス数（いずれも0以下なら全件）
+func getLocalHostStats(db dbQuerier, limit, pathLimit int, filter SearchFilter) ([]LocalHostStats, error) {
+	type hostInfo struct {
+		stats LocalHostStats
+		paths map[string]*PathStats
+	}
+	hosts := make(map[string]*hostInfo)
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		host, port, path, ok := parseLocalURL(v.URL)
+		if !ok {
+			return nil
+		}
+		key := net.JoinHostPort(host, port)
+		info := hosts[key]
+		if info == nil {
+			info = &hostInfo{stats: LocalHostStats{Host: host, Port: port}, paths: make(map[string]*PathStats)}
+			hosts[key] = info
+		}
+		info.stats.VisitCount++
+		if v.VisitTime.After(info.stats.LastVisit) {
+			info.stats.LastVisit = v.VisitTime
+		}
+
+		p := info.paths[path]
+		if p == nil {
+			// 新しい順に流れてくるので、最初に見つかったタイトルが最新
+			title, _ := sanitizeText(v.Title)
+			p = &PathStats{Path: path, Title: title}
+			info.paths[path] = p
+		}
+		p.VisitCount++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ローカルホストの集計に失敗: %w", err)
+	}
+
+	var result []LocalHostStats
+	for _, info := range hosts {
+		stats := info.stats
+		for _, p := range info.paths {
+			stats.Paths = append(stats.Paths, *p)
+		}
+		sort.Slice(stats.Paths, func(i, j int) bool {
+			if stats.Paths[i].VisitCount != stats.Paths[j].VisitCount {
+				return stats.Paths[i].VisitCount > stats.Paths[j].VisitCount
+			}
+			return stats.Paths[i].Path < stats.Paths[j].Path
+		})
+		if pathLimit > 0 && len(stats.Paths) > pathLimit {
+			for _, p := range stats.Paths[pathLimit:] {
+				stats.OtherCount += p.VisitCount
+			}
+			stats.Paths = stats.Paths[:pathLimit]
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].VisitCount != result[j].VisitCount {
+			return result[i].VisitCount > result[j].VisitCount
+		}
+		return result[i].Addr() < result[j].Addr()
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// redactLocalHostStats はリダクト対象のホストのホスト名とパスを伏せたコピーを返す
+func redactLocalHostStats(stats []LocalHostStats, redactDomains []string) []LocalHostStats {
+	if len(redactDomains) == 0 || stats == nil {
+		return stats
+	}
+	redacted := make([]LocalHostStats, len(stats))
+	for i, s := range stats {
+		if domainMatchesList(s.Host, redactDomains) {
+			s.Host = redactedLabel
+			s.Paths = nil
+		}
+		redacted[i] = s
+	}
+	return redacted
+}
+
+// printLocalHostStats はローカル・社内ホストの集計をテキスト形式で出力する
+func printLocalHostStats(w io.Writer, stats []LocalHostStats) {
+	_, _ = fmt.Fprintf(w, "🏠 ローカル・社内ホスト (Top %d)\n", len(stats))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, s := range stats {
+		_, _ = fmt.Fprintf(w, "  %4d  %s  (最終: %s)\n", s.VisitCount, s.Addr(), s.LastVisit.Format(TimeFormatDateTime))
+		for _, p := range s.Paths {
+			title := ""
+			if p.Title != "" {
+				title = "  " + truncateText(p.Title, TitleTruncateLength)
+			}
+			_, _ = fmt.Fprintf(w, "        %4d  %s%s\n", p.VisitCount, truncateText(p.Path, MaxTitleLength), title)
+		}
+		if s.OtherCount > 0 {
+			_, _ = fmt.Fprintf(w, "        %4d  (その他)\n", s.OtherCount)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
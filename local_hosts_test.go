@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+// TestParseLocalURL はローカル・社内ホストの判定のテスト
+func TestParseLocalURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		wantOK bool
+		host   string
+		port   string
+		path   string
+	}{
+		{"http://localhost:3000/dashboard?x=1", true, "localhost", "3000", "/dashboard"},
+		{"http://127.0.0.1:8080", true, "127.0.0.1", "8080", "/"},
+		{"http://[::1]:5173/", true, "::1", "5173", "/"},
+		{"https://grafana.internal/d/abc", true, "grafana.internal", "443", "/d/abc"},
+		{"http://nas.local/", true, "nas.local", "80", "/"},
+		{"http://192.168.1.1/admin", true, "192.168.1.1", "80", "/admin"},
+		{"http://10.0.0.5:9000/", true, "10.0.0.5", "9000", "/"},
+		{"https://github.com/", false, "", "", ""},
+		{"https://8.8.8.8/", false, "", "", ""},
+		{"https://localhost.example.com/", false, "", "", ""},
+		{"file:///Users/me/index.html", false, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		host, port, path, ok := parseLocalURL(tt.url)
+		if ok != tt.wantOK {
+			t.Errorf("parseLocalURL(%q) ok = %v, want %v", tt.url, ok, tt.wantOK)
+			continue
+		}
+		if ok && (host != tt.host || port != tt.port || path != tt.path) {
+			t.Errorf("parseLocalURL(%q) = %s %s %s, want %s %s %s", tt.url, host, port, path, tt.host, tt.port, tt.path)
+		}
+	}
+}
+
+// TestGetLocalHostStats はローカル・社内ホストの集計のテスト
+func TestGetLocalHostStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	base := 757418400.0
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'http://localhost:3000/', 'localhost', 2),
+		(2, 'http://localhost:3000/users', 'localhost', 1),
+		(3, 'http://localhost:8080/', 'localhost', 1),
+		(4, 'https://wiki.internal/page', 'wiki.internal', 1),
+		(5, 'https://example.com', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, ?, 'App'),
+		(2, 1, ?, 'App (dev)'),
+		(3, 2, ?, 'Users'),
+		(4, 3, ?, 'API'),
+		(5, 4, ?, 'Wiki'),
+		(6, 5, ?, 'Example');
+	`, base, base+60, base+120, base+180, base+240, base+300)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	stats, err := getLocalHostStats(db, 0, 1, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getLocalHostStats失敗: %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("ホスト数が期待値と異なる: %+v", stats)
+	}
+
+	top := stats[0]
+	if top.Addr() != "localhost:3000" || top.VisitCount != 3 || top.OtherCount != 1 {
+		t.Errorf("localhost:3000 の集計が期待値と異なる: %+v", top)
+	}
+	if len(top.Paths) != 1 || top.Paths[0].Path != "/" || top.Paths[0].VisitCount != 2 || top.Paths[0].Title != "App (dev)" {
+		t.Errorf("localhost:3000 のパスが期待値と異なる: %+v", top.Paths)
+	}
+
+	limited, err := getLocalHostStats(db, 1, 0, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getLocalHostStats失敗: %v", err)
+	}
+	if len(limited) != 1 || len(limited[0].Paths) != 2 {
+		t.Errorf("limit 指定時の集計が期待値と異なる: %+v", limited)
+	}
+
+	redacted := redactLocalHostStats(stats, []string{"wiki.internal"})
+	for _, s := range redacted {
+		if s.Port == "443" && (s.Host != redactedLabel || s.Paths != nil) {
+			t.Errorf("リダクト対象のホストが残っている: %+v", s)
+		}
+	}
+}
@@ -3,34 +3,41 @@ package main
 import (
 	"database/sql"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // Core Data timestamp の基準日（2001年1月1日）
 var coreDataEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
 
 // HistoryVisit は個別の訪問記録を表す
+// ID は history_visits.id で、エクスポートを繰り返しても同じ訪問には同じ値が付く
 type HistoryVisit struct {
+	ID        int64     `json:"id"`
 	URL       string    `json:"url"`
 	Title     string    `json:"title"`
 	Domain    string    `json:"domain"`
 	VisitTime time.Time `json:"visit_time"`
+	// Source は訪問のソース（履歴DBのソース名、アーカイブDBでは元のデバイス。hist の出力でだけ付ける）
+	Source string `json:"source,omitempty"`
 }
 
 // DomainStats はドメイン別の統計情報
+// Percentage は全ドメインの訪問数に対する割合（%）、CumulativePercentage は上位からの累積割合（%）
 type DomainStats struct {
-	Domain     string `json:"domain"`
-	VisitCount int    `json:"visit_count"`
+	Domain               string  `json:"domain"`
+	VisitCount           int     `json:"visit_count"`
+	Percentage           float64 `json:"percentage"`
+	CumulativePercentage float64 `json:"cumulative_percentage"`
 }
 
 // HourlyStats は時間帯別の統計情報
@@ -43,6 +50,16 @@ type HourlyStats struct {
 type DailyStats struct {
 	Date       string `json:"date"`
 	VisitCount int    `json:"visit_count"`
+	// Holiday は holidays.txt で設定した休日の名前
+	Holiday string `json:"holiday,omitempty"`
+	// Avg7・Avg30 はその日までの7日間・30日間の1日あたりの訪問数の平均（訪問のない日も0件として数える）
+	Avg7  float64 `json:"avg_7d"`
+	Avg30 float64 `json:"avg_30d"`
+	// ActiveHours はその日の最初の訪問から最後の訪問までの時間から、オフラインの時間を除いた時間
+	ActiveHours float64 `json:"active_hours"`
+	// OfflineHours・Offline は最初と最後の訪問の間で OfflineGap 以上訪問のなかった時間（通勤・外出など）
+	OfflineHours float64         `json:"offline_hours"`
+	Offline      []OfflinePeriod `json:"offline,omitempty"`
 }
 
 // PathStats はパス別の統計情報
@@ -73,18 +90,61 @@ type ContentStats struct {
 type SearchFilter struct {
 	Keyword       string
 	Domain        string
+	Domains       []string
 	From          time.Time
 	To            time.Time
 	IgnoreDomains []string
+	// IgnoreRules は時間帯付きの除外ルール（訪問単位の集計にのみ適用）
+	IgnoreRules []IgnoreRule
+	// Match は -match-stdin で渡されたURL・ドメイン（nil なら絞り込まない）
+	Match *MatchList
+	// Tag は -tag で指定したタグを付けたURL・訪問（nil なら絞り込まない）
+	Tag *TagMatch
+	// Starred は -starred のときのスターを付けたURL（nil なら絞り込まない）
+	Starred *MatchList
+	// ExcludeTrackers は同梱のトラッカー・広告ドメインへの訪問を除外する（-filter-trackers）
+	ExcludeTrackers bool
+	// CollapseRedirects はドメイン別統計で短縮URL・中継ページへの訪問を転送先のドメインに数える（-collapse-redirects）
+	CollapseRedirects bool
+	// TitleLang はタイトルの言語（ja, en など、空なら絞り込まない）
+	TitleLang string
+	// FoldKana は Keyword の検索でひらがなとカタカナを区別しない（-fold-kana）
+	FoldKana bool
+	// Romaji はローマ字の Keyword をひらがな・カタカナ・辞書の語にも広げて検索する（-romaji）
+	Romaji bool
+	// Index は Keyword の検索に使う全文検索の索引（hist index build で作成、nil なら LIKE のみ）
+	Index *SearchIndex
+	// MemoryLimit はドメイン・パス別の集計でメモリに持つ部分集計の上限（バイト、-max-memory）。超えたら一時 SQLite に書き出す（0 なら上限なし）
+	MemoryLimit int64
 }
 
 // AnalysisResult は分析結果全体を表す
 type AnalysisResult struct {
-	TotalVisits  int            `json:"total_visits"`
-	RecentVisits []HistoryVisit `json:"recent_visits,omitempty"`
-	DomainStats  []DomainStats  `json:"domain_stats,omitempty"`
-	HourlyStats  []HourlyStats  `json:"hourly_stats,omitempty"`
-	DailyStats   []DailyStats   `json:"daily_stats,omitempty"`
+	TotalVisits     int               `json:"total_visits"`
+	RecentVisits    []HistoryVisit    `json:"recent_visits,omitempty"`
+	DomainStats     []DomainStats     `json:"domain_stats,omitempty"`
+	DomainPathStats []DomainPathStats `json:"domain_path_stats,omitempty"`
+	HourlyStats     []HourlyStats     `json:"hourly_stats,omitempty"`
+	HourlySplit     *HourlySplitStats `json:"hourly_split_stats,omitempty"`
+	DailyStats      []DailyStats      `json:"daily_stats,omitempty"`
+	TitleClusters   []TitleCluster    `json:"title_clusters,omitempty"`
+	Videos          *VideoReport      `json:"videos,omitempty"`
+	GitHub          *GitHubReport     `json:"github,omitempty"`
+	Docs            *DocsReport       `json:"docs,omitempty"`
+	LocalHosts      []LocalHostStats  `json:"local_hosts,omitempty"`
+	HourlyProfiles  []HourlyProfile   `json:"hourly_profiles,omitempty"`
+	// CategoryProfiles は categories.txt のカテゴリごとの時間帯プロファイル（-profiles で categories.txt があるとき）
+	CategoryProfiles []HourlyProfile      `json:"category_profiles,omitempty"`
+	TitleLanguages   []TitleLanguageStats `json:"title_languages,omitempty"`
+	WeeklyStats      *PeriodReport        `json:"weekly_stats,omitempty"`
+	MonthlyStats     *PeriodReport        `json:"monthly_stats,omitempty"`
+	GroupStats       *GroupReport         `json:"group_stats,omitempty"`
+	Distribution     *DailyDistribution   `json:"daily_distribution,omitempty"`
+	Forecast         *VisitForecast       `json:"forecast,omitempty"`
+	Changepoints     *ChangepointReport   `json:"changepoints,omitempty"`
+	Joined           *JoinReport          `json:"joined_csv,omitempty"`
+	ScreenTime       *ScreenTimeReport    `json:"screen_time,omitempty"`
+	Query            *QueryInfo           `json:"query,omitempty"`
 }
 
 // Config はアプリケーション設定を表す
@@ -92,42 +152,156 @@ type Config struct {
 	// 表示件数
 	Limit       int
 	DomainLimit int
+	PathLimit   int
 	Days        int
 
 	// 表示オプション
-	ShowHistory bool
-	ShowDomains bool
-	ShowHourly  bool
-	ShowDaily   bool
+	ShowHistory  bool
+	ShowDomains  bool
+	ShowPaths    bool
+	ShowHourly   bool
+	ShowDaily    bool
+	ShowTitles   bool
+	ShowVideos   bool
+	ShowGitHub   bool
+	ShowDocs     bool
+	ShowLocal    bool
+	ShowProfiles bool
+	ShowLangs    bool
+	ShowWeekly   bool
+	ShowMonthly  bool
+	// ShowDistribution は日別訪問数の分布（分位数・最も多い日と少ない日・ヒストグラム）を表示する
+	ShowDistribution bool
+	// ShowForecast は今日からの1週間の訪問数と上位ドメインの訪問数の予測を表示する
+	ShowForecast bool
+	// ShowChangepoints は日別訪問数やドメインの割合が大きく変わった日と前後の上位ドメインを表示する
+	ShowChangepoints bool
+
+	// -weekly・-monthly の週の始まりと年度の始まり（calendar.txt）
+	Calendar Calendar
+
+	// 日別統計で名前を付け、-split weekpart で週末に数える休日（holidays.txt、なければ nil）
+	Holidays *Holidays
+
+	// -docs で集計するドキュメントサイト
+	DocSites []DocSitePattern
+
+	// 時間帯別統計の分割方法（"" または "weekpart"）
+	HourlySplit string
+
+	// 仕事用・個人用のドメインリストごとに並べて表示する（-split work/personal）
+	BucketSplit bool
+	Buckets     []DomainBucket
+
+	// 訪問のソース名（-source、アーカイブDBの訪問は元のデバイス）
+	Source string
+	// DBSources はまとめて集計する複数の履歴DB（-db の繰り返し・-browser all、1つなら nil）
+	DBSources []DBSource
+	// 訪問数をグループごとに数える軸（-group-by、空なら数えない）と、-group-by category・-join-csv・-profiles のカテゴリ（categories.txt）
+	GroupBy    string
+	Categories []DomainCategory
+	// JoinMetrics は日別訪問数との相関を求める日ごとの指標（-join-csv、なければ nil）
+	JoinMetrics *JoinedMetrics
+	// ScreenTime は Safari の訪問数と比べる日ごとのスクリーンタイム（-screentime、なければ nil）
+	ScreenTime *JoinedMetrics
 
 	// フィルタ
 	Filter SearchFilter
 
+	// 集計の基準時刻（-now、ゼロなら実行時の時刻）
+	Now time.Time
+
+	// -search のキーワードをあいまい検索（fzf 方式）にし、最近の履歴をスコア順に並べる
+	Fuzzy bool
+
+	// 最近の履歴・ピッカーの並び順（-rank）
+	Rank RankMode
+
+	// 一覧表示で伏せるドメイン（集計には含める）
+	RedactDomains []string
+	// -no-redact 指定時は Web サーバーでもリダクトリストを再読み込みしない
+	NoRedact bool
+
 	// 出力形式
-	JSONOutput bool
-	CSVOutput  bool
-	TSVOutput  bool
-	OutputFile string
+	// Format は -format で指定した出力形式（outputFormatters の名前。空なら形式のフラグに従う）
+	Format       string
+	JSONOutput   bool
+	CSVOutput    bool
+	TSVOutput    bool
+	NDJSONOutput bool
+	PickerOutput bool
+	SQLiteOutput string
+	TableOutput  bool
+	TableBorder  bool
+	OutputFile   string
+	// 拡張子から形式を判定した出力先（-output を複数指定した場合など）。空なら形式のフラグに従い OutputFile に書き出す
+	Outputs []OutputTarget
+	// CSV・TSV・NDJSON の出力ファイルに追記する（-append）
+	Append bool
+	// -output のファイルを書き出した後に実行するフック（hooks.txt の on_report_generated）
+	Hooks Hooks
+	// 統計ごとの CSV と manifest.json を書き出すディレクトリ（-csv-split）
+	CSVSplitDir string
+	Anonymize   AnonymizeMode
+
+	// 実行せずにクエリと実行計画を表示
+	Explain bool
+
+	// 見出し・罫線・グラフを出さず、データ行だけを出力する
+	Quiet bool
+
+	// 不正なデータをサニタイズせずエラーにする
+	Strict bool
 
 	// モード
 	Interactive bool
 	Serve       bool
 	Port        int
+	SharedConn  bool
+	Favicons    bool
+	// RateLimit は API のクライアントIPごとの1秒あたりのリクエスト数の上限（0 なら制限しない）
+	RateLimit float64
+}
+
+// exitErrorPrefix は exitWithError のメッセージの前に付ける文字列（hist batch で何行目のコマンドかを示す）
+var exitErrorPrefix string
+
+// exitCodeOf はサブコマンドが返したエラーの終了コード
+// フラグは ContinueOnError で解析する（flag.ExitOnError は指定の誤りを ExitCodeNoMatch と同じ 2 で終了するため）。
+// -h・-help は ExitCodeOK、指定の誤りを含むその他のエラーは ExitCodeError にする
+func exitCodeOf(err error) int {
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return ExitCodeOK
+	case errors.Is(err, errNoMatches):
+		return ExitCodeNoMatch
+	}
+	return ExitCodeError
 }
 
 // exitWithError はエラーメッセージを出力して終了する
 func exitWithError(format string, args ...interface{}) {
+	fmt.Fprint(os.Stderr, exitErrorPrefix)
 	fmt.Fprintf(os.Stderr, format, args...)
-	os.Exit(1)
+	os.Exit(ExitCodeError)
 }
 
 // visit_time を通常の時刻に変換
+// 時間帯・日付・曜日の集計と無視ルールの時間帯をそろえるため、ローカルタイムゾーン（TZ）の時刻にする
 func convertCoreDataTimestamp(timestamp float64) time.Time {
-	return coreDataEpoch.Add(time.Duration(timestamp * float64(time.Second)))
+	return coreDataEpoch.Add(time.Duration(timestamp * float64(time.Second))).In(time.Local)
+}
+
+// parseLocalDate は -from・-to などの "YYYY-MM-DD" を、集計と同じローカルタイムゾーンのその日の0時にする
+func parseLocalDate(s string) (time.Time, error) {
+	return time.ParseInLocation(TimeFormatDate, s, time.Local)
 }
 
-// getDBPath はSafari履歴DBのパスを取得
+// getDBPath はSafari履歴DBのパスを取得（環境変数 HIST_DB があればそのパス）
 func getDBPath() (string, error) {
+	if path := os.Getenv(HistDBEnv); path != "" {
+		return path, nil
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
@@ -136,12 +310,17 @@ func getDBPath() (string, error) {
 }
 
 // openDB はSafari履歴DBを開く（読み取り専用）
+// 暗号化したファイル（-encrypt の -sqlite 出力やアーカイブDB）は復号して開く
 func openDB(dbPath string) (*sql.DB, error) {
-	// 読み取り専用モードで開く
-	db, err := sql.Open(SQLiteDriver, dbPath+SQLiteReadOnlyMode)
+	if encrypted, err := isEncryptedFile(dbPath); err == nil && encrypted {
+		return openEncryptedDB(dbPath)
+	}
+	// 読み取り専用モードで開き、読み取り以外の文は接続ごとに拒否する
+	db, err := sql.Open(SQLiteReadOnlyDriver, readOnlyDSN(dbPath, false))
 	if err != nil {
 		return nil, fmt.Errorf("データベースを開けませんでした: %w", err)
 	}
+	configureDB(db)
 	return db, nil
 }
 
@@ -176,6 +355,7 @@ func extractDomain(urlStr string) string {
 // 履歴取得用のベースクエリ
 const historyBaseQuery = `
 	SELECT
+		hv.id,
 		hi.url,
 		COALESCE(hv.title, '') as title,
 		COALESCE(hi.domain_expansion, '') as domain,
@@ -184,20 +364,24 @@ const historyBaseQuery = `
 	JOIN history_items hi ON hv.history_item = hi.id
 	WHERE 1=1`
 
+// buildRecentVisitsQuery は最近の訪問履歴を取得するクエリを構築
+func buildRecentVisitsQuery(limit int, filter SearchFilter) (string, []interface{}) {
+	return NewQueryBuilder(historyBaseQuery).
+		WithFilter(filter).
+		OrderByDesc("hv.visit_time").
+		Limit(limit).
+		Build()
+}
+
 // getRecentVisits は最近の訪問履歴を取得
-func getRecentVisits(db *sql.DB, limit int, filter SearchFilter) ([]HistoryVisit, error) {
+func getRecentVisits(db dbQuerier, limit int, filter SearchFilter) ([]HistoryVisit, error) {
 	// イグノアリストがある場合、多めに取得してGoでフィルタ
 	fetchLimit := limit
 	if len(filter.IgnoreDomains) > 0 {
 		fetchLimit = limit * 3 // フィルタ後にlimit件取得できるよう多めに
 	}
 
-	qb := NewQueryBuilder(historyBaseQuery).
-		WithFilter(filter).
-		OrderByDesc("hv.visit_time").
-		Limit(fetchLimit)
-
-	query, args := qb.Build()
+	query, args := buildRecentVisitsQuery(fetchLimit, filter)
 	visits, err := executeHistoryQuery(db, query, args)
 	if err != nil {
 		return nil, err
@@ -221,43 +405,40 @@ func getRecentVisits(db *sql.DB, limit int, filter SearchFilter) ([]HistoryVisit
 }
 
 // executeHistoryQuery は履歴クエリを実行して結果を返す
-func executeHistoryQuery(db *sql.DB, query string, args []interface{}) ([]HistoryVisit, error) {
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("履歴の取得に失敗: %w", err)
-	}
-	defer func() { _ = rows.Close() }()
-
+func executeHistoryQuery(db dbQuerier, query string, args []interface{}) ([]HistoryVisit, error) {
 	var visits []HistoryVisit
-	for rows.Next() {
-		var v HistoryVisit
-		var visitTime float64
-		if err := rows.Scan(&v.URL, &v.Title, &v.Domain, &visitTime); err != nil {
-			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
-		}
-		v.VisitTime = convertCoreDataTimestamp(visitTime)
-		// domain_expansionが空の場合、URLからドメインを抽出
-		if v.Domain == "" {
-			v.Domain = extractDomain(v.URL)
-		}
+	err := streamHistoryQuery(db, query, args, func(v HistoryVisit) error {
 		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return visits, nil
 }
 
+// ドメイン統計用のクエリ（全てのURLとvisit_countを取得）
+const domainStatsQuery = `SELECT hi.url, hi.visit_count FROM history_items hi`
+
 // getDomainStats はドメイン別の訪問統計を取得（URLからドメインを抽出）
-func getDomainStats(db *sql.DB, limit int, filter SearchFilter) ([]DomainStats, error) {
-	// 全てのURLとvisit_countを取得
-	query := `SELECT hi.url, hi.visit_count FROM history_items hi`
+func getDomainStats(db dbQuerier, limit int, filter SearchFilter) ([]DomainStats, error) {
+	var redirects map[string]string
+	if filter.CollapseRedirects {
+		var err error
+		if redirects, err = getRedirectDestinations(db); err != nil {
+			return nil, err
+		}
+	}
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(domainStatsQuery)
 	if err != nil {
 		return nil, fmt.Errorf("ドメイン統計の取得に失敗: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	// URLからドメインを抽出して集計
-	domainCounts := make(map[string]int)
+	// URLからドメインを抽出して集計（-max-memory を超えたら部分集計を一時 SQLite に書き出す）
+	counts := newSpillAggregator(filter.MemoryLimit)
+	defer counts.close()
 	for rows.Next() {
 		var url string
 		var visitCount int
@@ -265,6 +446,9 @@ func getDomainStats(db *sql.DB, limit int, filter SearchFilter) ([]DomainStats,
 			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
 		}
 		domain := extractDomain(url)
+		if dest, ok := redirects[url]; ok {
+			domain = dest
+		}
 		if domain == "" {
 			domain = "不明"
 		}
@@ -273,19 +457,42 @@ func getDomainStats(db *sql.DB, limit int, filter SearchFilter) ([]DomainStats,
 		if shouldIgnoreDomain(domain, filter.IgnoreDomains) {
 			continue
 		}
+		if filter.Match != nil && !filter.Match.matchesURL(url) {
+			continue
+		}
+		if filter.Tag != nil && !filter.Tag.matchesURL(url) {
+			continue
+		}
+		if filter.Starred != nil && !filter.Starred.matchesURL(url) {
+			continue
+		}
+		if filter.ExcludeTrackers && isTrackerDomain(domain) {
+			continue
+		}
 
-		domainCounts[domain] += visitCount
+		if err := counts.add(domain, "", visitCount, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	if counts.spilled() {
+		return counts.spilledDomainStats(limit)
 	}
 
 	// スライスに変換してソート
 	var stats []DomainStats
-	for domain, count := range domainCounts {
-		stats = append(stats, DomainStats{Domain: domain, VisitCount: count})
+	for k, e := range counts.entries {
+		stats = append(stats, DomainStats{Domain: k.Group, VisitCount: e.Count})
 	}
 	sort.Slice(stats, func(i, j int) bool {
 		return stats[i].VisitCount > stats[j].VisitCount
 	})
 
+	// 割合は limit で切る前の全ドメインの合計に対して計算する
+	setDomainShares(stats)
+
 	// limitで制限
 	if limit > 0 && len(stats) > limit {
 		stats = stats[:limit]
@@ -294,9 +501,42 @@ func getDomainStats(db *sql.DB, limit int, filter SearchFilter) ([]DomainStats,
 	return stats, nil
 }
 
+// setDomainShares は訪問数の降順に並んだドメイン統計に割合と累積割合を設定する
+func setDomainShares(stats []DomainStats) {
+	total := 0
+	for _, s := range stats {
+		total += s.VisitCount
+	}
+	setDomainSharesOf(stats, total)
+}
+
+// setDomainSharesOf は total に対する割合と累積割合を設定する（stats は total の一部の上位でもよい）
+func setDomainSharesOf(stats []DomainStats, total int) {
+	if total == 0 {
+		return
+	}
+	cumulative := 0
+	for i := range stats {
+		cumulative += stats[i].VisitCount
+		stats[i].Percentage = roundPercentage(float64(stats[i].VisitCount) / float64(total) * 100)
+		stats[i].CumulativePercentage = roundPercentage(float64(cumulative) / float64(total) * 100)
+	}
+}
+
+// roundPercentage は割合を小数点以下2桁に丸める
+func roundPercentage(p float64) float64 {
+	return math.Round(p*100) / 100
+}
+
 // shouldIgnoreDomain はドメインがイグノアリストに含まれるかチェック
 func shouldIgnoreDomain(domain string, ignoreDomains []string) bool {
-	for _, ignored := range ignoreDomains {
+	return domainMatchesList(domain, ignoreDomains)
+}
+
+// domainMatchesList はドメインがリストのいずれかのエントリにマッチするかチェック
+// イグノアリスト・リダクトリストで共通のマッチング規則を使う
+func domainMatchesList(domain string, list []string) bool {
+	for _, ignored := range list {
 		if ignored == "" {
 			continue
 		}
@@ -390,29 +630,25 @@ func extractBaseDomain(domain string) string {
 	return strings.Join(parts[len(parts)-2:], ".")
 }
 
-// getDomainPathStats はドメイン・パス別の訪問統計を取得
-func getDomainPathStats(db *sql.DB, limit, pathLimit int, filter SearchFilter) ([]DomainPathStats, error) {
-	// URLとvisit_count、最新タイトルを取得
-	query := `
-		SELECT hi.url, hi.visit_count,
-			COALESCE((SELECT hv.title FROM history_visits hv
-				WHERE hv.history_item = hi.id
-				ORDER BY hv.visit_time DESC LIMIT 1), '') as latest_title
-		FROM history_items hi`
+// domainPathStatsQuery はURLとvisit_count、最新タイトルを取得するクエリ
+const domainPathStatsQuery = `
+	SELECT hi.url, hi.visit_count,
+		COALESCE((SELECT hv.title FROM history_visits hv
+			WHERE hv.history_item = hi.id
+			ORDER BY hv.visit_time DESC LIMIT 1), '') as latest_title
+	FROM history_items hi`
 
-	rows, err := db.Query(query)
+// getDomainPathStats はドメイン・パス別の訪問統計を取得
+func getDomainPathStats(db dbQuerier, limit, pathLimit int, filter SearchFilter) ([]DomainPathStats, error) {
+	rows, err := db.Query(domainPathStatsQuery)
 	if err != nil {
 		return nil, fmt.Errorf("ドメイン・パス統計の取得に失敗: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	// ドメインごとにパス統計を集計
-	type pathInfo struct {
-		title      string
-		visitCount int
-	}
-	domainPaths := make(map[string]map[string]*pathInfo)
-	domainTotals := make(map[string]int)
+	// ドメインごとにパス統計を集計（-max-memory を超えたら部分集計を一時 SQLite に書き出す）
+	counts := newSpillAggregator(filter.MemoryLimit)
+	defer counts.close()
 
 	for rows.Next() {
 		var url string
@@ -432,41 +668,48 @@ func getDomainPathStats(db *sql.DB, limit, pathLimit int, filter SearchFilter) (
 		if shouldIgnoreDomain(baseDomain, filter.IgnoreDomains) {
 			continue
 		}
-
-		path := extractPath(url)
-
-		if domainPaths[baseDomain] == nil {
-			domainPaths[baseDomain] = make(map[string]*pathInfo)
+		if filter.Match != nil && !filter.Match.matchesURL(url) {
+			continue
+		}
+		if filter.Tag != nil && !filter.Tag.matchesURL(url) {
+			continue
+		}
+		if filter.Starred != nil && !filter.Starred.matchesURL(url) {
+			continue
 		}
-		if domainPaths[baseDomain][path] == nil {
-			domainPaths[baseDomain][path] = &pathInfo{title: title}
+		if filter.ExcludeTrackers && isTrackerDomain(domain) {
+			continue
 		}
-		domainPaths[baseDomain][path].visitCount += visitCount
-		// タイトルが空でなければ更新
-		if title != "" {
-			domainPaths[baseDomain][path].title = title
+
+		// タイトルが空でなければ最新のタイトルとして更新
+		if err := counts.add(baseDomain, extractPath(url), visitCount, title); err != nil {
+			return nil, err
 		}
-		domainTotals[baseDomain] += visitCount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	if counts.spilled() {
+		return counts.spilledDomainPathStats(limit, pathLimit)
+	}
+
+	domainPaths := make(map[string][]PathStats)
+	domainTotals := make(map[string]int)
+	for k, e := range counts.entries {
+		domainPaths[k.Group] = append(domainPaths[k.Group], PathStats{Path: k.Key, Title: e.Title, VisitCount: e.Count})
+		domainTotals[k.Group] += e.Count
 	}
 
 	// DomainPathStats形式に変換
 	var stats []DomainPathStats
-	for domain, paths := range domainPaths {
+	for domain, pathStats := range domainPaths {
 		ds := DomainPathStats{
 			Domain:     domain,
 			TotalCount: domainTotals[domain],
-			HasPaths:   len(paths) > 1,
+			HasPaths:   len(pathStats) > 1,
 		}
 
-		// パスをスライスに変換してソート
-		var pathStats []PathStats
-		for path, info := range paths {
-			pathStats = append(pathStats, PathStats{
-				Path:       path,
-				Title:      info.title,
-				VisitCount: info.visitCount,
-			})
-		}
+		// パスをソート
 		sort.Slice(pathStats, func(i, j int) bool {
 			return pathStats[i].VisitCount > pathStats[j].VisitCount
 		})
@@ -497,7 +740,7 @@ func getDomainPathStats(db *sql.DB, limit, pathLimit int, filter SearchFilter) (
 }
 
 // getContentStatsByDomain は指定ドメインのコンテンツ統計を取得
-func getContentStatsByDomain(db *sql.DB, domain string, limit int) ([]ContentStats, int, error) {
+func getContentStatsByDomain(db dbQuerier, domain string, limit int) ([]ContentStats, int, error) {
 	// URLとvisit_count、最新タイトルを取得
 	query := `
 		SELECT hi.url, hi.visit_count,
@@ -563,10 +806,14 @@ const visitTimeBaseQuery = `
 	JOIN history_items hi ON hv.history_item = hi.id
 	WHERE 1=1`
 
+// buildVisitTimeQuery はフィルタ条件に一致する訪問時刻を取得するクエリを構築
+func buildVisitTimeQuery(filter SearchFilter) (string, []interface{}) {
+	return NewQueryBuilder(visitTimeBaseQuery).WithFilter(filter).Build()
+}
+
 // getHourlyStats は時間帯別の訪問統計を取得
-func getHourlyStats(db *sql.DB, filter SearchFilter) ([]HourlyStats, error) {
-	qb := NewQueryBuilder(visitTimeBaseQuery).WithFilter(filter)
-	query, args := qb.Build()
+func getHourlyStats(db dbQuerier, filter SearchFilter) ([]HourlyStats, error) {
+	query, args := buildVisitTimeQuery(filter)
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -594,10 +841,9 @@ func getHourlyStats(db *sql.DB, filter SearchFilter) ([]HourlyStats, error) {
 	return stats, nil
 }
 
-// getDailyStats は日別の訪問統計を取得（過去N日間）
-func getDailyStats(db *sql.DB, days int, filter SearchFilter) ([]DailyStats, error) {
-	qb := NewQueryBuilder(visitTimeBaseQuery).WithFilter(filter)
-	query, args := qb.Build()
+// getDailyStats は日別の訪問統計を取得（now までの過去N日間）
+func getDailyStats(db dbQuerier, days int, now time.Time, filter SearchFilter) ([]DailyStats, error) {
+	query, args := buildVisitTimeQuery(filter)
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -605,26 +851,40 @@ func getDailyStats(db *sql.DB, days int, filter SearchFilter) ([]DailyStats, err
 	}
 	defer func() { _ = rows.Close() }()
 
+	// 移動平均のため、期間より前の日の訪問数と履歴の最初の日も求める
 	dateCounts := make(map[string]int)
-	cutoff := time.Now().AddDate(0, 0, -days)
+	dayTimes := make(map[string][]time.Time)
+	first := ""
+	cutoff := now.AddDate(0, 0, -days)
+	averageFrom := cutoff.AddDate(0, 0, -RollingLongDays)
 
 	for rows.Next() {
 		var visitTime float64
 		if err := rows.Scan(&visitTime); err != nil {
 			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
 		}
+		// 日付とオフラインの間は表示（formatOfflineHours）と同じローカルタイムゾーンの日で区切る
 		t := convertCoreDataTimestamp(visitTime)
-		if t.After(cutoff) {
-			dateStr := t.Format(TimeFormatDate)
+		dateStr := t.Format(TimeFormatDate)
+		if first == "" || dateStr < first {
+			first = dateStr
+		}
+		if t.After(averageFrom) {
 			dateCounts[dateStr]++
 		}
+		if t.After(cutoff) {
+			dayTimes[dateStr] = append(dayTimes[dateStr], t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
 	}
 
 	var stats []DailyStats
-	for date, count := range dateCounts {
+	for date := range dayTimes {
 		stats = append(stats, DailyStats{
 			Date:       date,
-			VisitCount: count,
+			VisitCount: dateCounts[date],
 		})
 	}
 
@@ -632,14 +892,19 @@ func getDailyStats(db *sql.DB, days int, filter SearchFilter) ([]DailyStats, err
 	sort.Slice(stats, func(i, j int) bool {
 		return stats[i].Date > stats[j].Date
 	})
+	annotateRollingAverages(stats, dateCounts, first)
+	annotateOfflineHours(stats, dayTimes)
 
 	return stats, nil
 }
 
+// 総訪問数取得用のクエリ
+const totalVisitsQuery = "SELECT COUNT(*) FROM history_visits"
+
 // getTotalVisits は総訪問数を取得
-func getTotalVisits(db *sql.DB) (int, error) {
+func getTotalVisits(db dbQuerier) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM history_visits").Scan(&count)
+	err := db.QueryRow(totalVisitsQuery).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("総訪問数の取得に失敗: %w", err)
 	}
@@ -647,22 +912,71 @@ func getTotalVisits(db *sql.DB) (int, error) {
 }
 
 // writeCSV はCSV/TSV形式で結果を出力
-func writeCSV(w io.Writer, result AnalysisResult, showHistory, showDomains, showHourly, showDaily bool, delimiter rune) error {
+// 複数のセクションを出力する場合は空行で区切る
+func writeCSV(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool, delimiter rune) error {
+	return writeCSVSections(w, result, showHistory, showDomains, showPaths, showHourly, showDaily, delimiter, csvHeaderEachSection)
+}
+
+// csvHeaderMode は CSV の各統計の見出し行の書き方
+type csvHeaderMode int
+
+const (
+	// csvHeaderEachSection は統計ごとに見出し行を書き、空行で区切る
+	csvHeaderEachSection csvHeaderMode = iota
+	// csvHeaderSingleSection は統計を1つに限り、見出し行を書く（-append で空のファイルに書く場合）
+	csvHeaderSingleSection
+	// csvHeaderNone は統計を1つに限り、見出し行を書かない（-append で既存のファイルに追記する場合）
+	csvHeaderNone
+)
+
+// errCSVAppendSections は -append で CSV に複数の統計を書こうとした場合のエラー
+var errCSVAppendSections = errors.New("-append で追記できる CSV・TSV は統計1つ分だけです（-history のみなどに絞ってください）")
+
+// writeCSVSections は header の方法で見出し行を書きながら結果を CSV 形式で出力する
+func writeCSVSections(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool, delimiter rune, header csvHeaderMode) error {
 	writer := csv.NewWriter(w)
 	writer.Comma = delimiter
 	defer writer.Flush()
 
+	wroteSection := false
+	return emitCSVSections(result, showHistory, showDomains, showPaths, showHourly, showDaily, func(name string, columns []string) (*csv.Writer, error) {
+		if wroteSection {
+			if header != csvHeaderEachSection {
+				return nil, errCSVAppendSections
+			}
+			if err := writer.Write([]string{}); err != nil {
+				return nil, err
+			}
+		}
+		wroteSection = true
+		if header == csvHeaderNone {
+			return writer, nil
+		}
+		return writer, writer.Write(columns)
+	})
+}
+
+// csvSectionStarter は統計1つ分の CSV を書き始める
+// name は統計の名前（-csv-split のファイル名）、columns は見出し行。返り値の Writer にデータ行を書く
+type csvSectionStarter func(name string, columns []string) (*csv.Writer, error)
+
+// emitCSVSections は表示する統計を1つずつ startSection で始めてデータ行を書く
+func emitCSVSections(result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool, startSection csvSectionStarter) error {
+
 	// 履歴一覧
 	if showHistory && len(result.RecentVisits) > 0 {
-		if err := writer.Write([]string{"visit_time", "title", "domain", "url"}); err != nil {
+		writer, err := startSection("history", []string{"id", "visit_time", "title", "domain", "url", "source"})
+		if err != nil {
 			return err
 		}
 		for _, v := range result.RecentVisits {
 			record := []string{
+				fmt.Sprintf("%d", v.ID),
 				v.VisitTime.Format(TimeFormatFull),
 				v.Title,
 				v.Domain,
 				v.URL,
+				v.Source,
 			}
 			if err := writer.Write(record); err != nil {
 				return err
@@ -672,30 +986,54 @@ func writeCSV(w io.Writer, result AnalysisResult, showHistory, showDomains, show
 
 	// ドメイン統計
 	if showDomains && len(result.DomainStats) > 0 {
-		if showHistory && len(result.RecentVisits) > 0 {
-			if err := writer.Write([]string{}); err != nil {
-				return err
-			}
-		}
-		if err := writer.Write([]string{"domain", "visit_count"}); err != nil {
+		writer, err := startSection("domains", []string{"domain", "visit_count", "percentage", "cumulative_percentage"})
+		if err != nil {
 			return err
 		}
 		for _, s := range result.DomainStats {
-			record := []string{s.Domain, fmt.Sprintf("%d", s.VisitCount)}
+			record := []string{
+				s.Domain,
+				fmt.Sprintf("%d", s.VisitCount),
+				fmt.Sprintf("%.2f", s.Percentage),
+				fmt.Sprintf("%.2f", s.CumulativePercentage),
+			}
 			if err := writer.Write(record); err != nil {
 				return err
 			}
 		}
 	}
 
-	// 時間帯統計
-	if showHourly && len(result.HourlyStats) > 0 {
-		if (showHistory && len(result.RecentVisits) > 0) || (showDomains && len(result.DomainStats) > 0) {
-			if err := writer.Write([]string{}); err != nil {
-				return err
+	// ドメイン・パス統計（上位以外のパスは path を (other) として1行にまとめる）
+	if showPaths && len(result.DomainPathStats) > 0 {
+		writer, err := startSection("paths", []string{"domain", "path", "title", "visit_count"})
+		if err != nil {
+			return err
+		}
+		for _, ds := range result.DomainPathStats {
+			for _, p := range ds.Paths {
+				record := []string{ds.Domain, p.Path, p.Title, fmt.Sprintf("%d", p.VisitCount)}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+			if ds.OtherCount > 0 || len(ds.Paths) == 0 {
+				other := ds.OtherCount
+				if len(ds.Paths) == 0 {
+					// リダクト済みなどでパスの内訳がない場合は合計をまとめて出す
+					other = ds.TotalCount
+				}
+				record := []string{ds.Domain, "(other)", "", fmt.Sprintf("%d", other)}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
 			}
 		}
-		if err := writer.Write([]string{"hour", "visit_count"}); err != nil {
+	}
+
+	// 時間帯統計
+	if showHourly && len(result.HourlyStats) > 0 {
+		writer, err := startSection("hourly", []string{"hour", "visit_count"})
+		if err != nil {
 			return err
 		}
 		for _, s := range result.HourlyStats {
@@ -706,233 +1044,1136 @@ func writeCSV(w io.Writer, result AnalysisResult, showHistory, showDomains, show
 		}
 	}
 
-	// 日別統計
-	if showDaily && len(result.DailyStats) > 0 {
-		if (showHistory && len(result.RecentVisits) > 0) || (showDomains && len(result.DomainStats) > 0) || (showHourly && len(result.HourlyStats) > 0) {
-			if err := writer.Write([]string{}); err != nil {
+	// 平日・週末別の時間帯統計（1日あたりの平均）
+	if showHourly && result.HourlySplit != nil {
+		writer, err := startSection("hourly_split", []string{"hour", "weekday_average", "weekend_average"})
+		if err != nil {
+			return err
+		}
+		for i, wd := range result.HourlySplit.Weekday {
+			we := result.HourlySplit.Weekend[i]
+			record := []string{fmt.Sprintf("%02d:00", wd.Hour), fmt.Sprintf("%.2f", wd.Average), fmt.Sprintf("%.2f", we.Average)}
+			if err := writer.Write(record); err != nil {
 				return err
 			}
 		}
-		if err := writer.Write([]string{"date", "visit_count"}); err != nil {
+	}
+
+	// 日別統計
+	if showDaily && len(result.DailyStats) > 0 {
+		writer, err := startSection("daily", []string{"date", "visit_count", "holiday", "avg_7d", "avg_30d", "active_hours", "offline_hours"})
+		if err != nil {
 			return err
 		}
 		for _, s := range result.DailyStats {
-			record := []string{s.Date, fmt.Sprintf("%d", s.VisitCount)}
+			record := []string{s.Date, fmt.Sprintf("%d", s.VisitCount), s.Holiday, fmt.Sprintf("%.2f", s.Avg7), fmt.Sprintf("%.2f", s.Avg30), fmt.Sprintf("%.2f", s.ActiveHours), fmt.Sprintf("%.2f", s.OfflineHours)}
 			if err := writer.Write(record); err != nil {
 				return err
 			}
 		}
 	}
 
-	return nil
-}
-
-// printTextOutput はテキスト形式で結果を出力
-func printTextOutput(result AnalysisResult, showHistory, showDomains, showHourly, showDaily bool) {
-	fmt.Printf("\n📊 Safari 履歴分析結果\n")
-	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	fmt.Printf("総訪問数: %d\n\n", result.TotalVisits)
-
-	if showHistory && len(result.RecentVisits) > 0 {
-		fmt.Printf("📝 最近の訪問履歴\n")
-		fmt.Printf("─────────────────────────────────────────\n")
-		for _, v := range result.RecentVisits {
-			title := v.Title
-			if title == "" {
-				title = "(タイトルなし)"
-			}
-			if len(title) > TitleTruncateLength {
-				title = title[:TitleTruncateLength-3] + "..."
+	// 訪問数の予測（日別と上位ドメイン別）
+	if f := result.Forecast; f != nil {
+		writer, err := startSection("forecast", []string{"date", "visits", "method"})
+		if err != nil {
+			return err
+		}
+		for _, d := range f.Daily {
+			if err := writer.Write([]string{d.Date, fmt.Sprintf("%.1f", d.Visits), f.Method}); err != nil {
+				return err
 			}
-			fmt.Printf("  %s  %s\n", v.VisitTime.Format(TimeFormatDateTime), title)
-			if v.Domain != "" {
-				fmt.Printf("              📍 %s\n", v.Domain)
+		}
+		writer, err = startSection("forecast_domains", []string{"domain", "last_week", "projected"})
+		if err != nil {
+			return err
+		}
+		for _, d := range f.Domains {
+			if err := writer.Write([]string{d.Domain, fmt.Sprintf("%d", d.LastWeek), fmt.Sprintf("%.1f", d.Projected)}); err != nil {
+				return err
 			}
 		}
-		fmt.Println()
 	}
 
-	if showDomains && len(result.DomainStats) > 0 {
-		fmt.Printf("🌐 ドメイン別訪問数 (Top %d)\n", len(result.DomainStats))
-		fmt.Printf("─────────────────────────────────────────\n")
-		maxCount := result.DomainStats[0].VisitCount
-		for _, s := range result.DomainStats {
-			barLen := int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
-			bar := strings.Repeat("█", barLen)
-			fmt.Printf("  %-20s %s %d\n", s.Domain, bar, s.VisitCount)
+	// 変化点（1行ずつと前後の上位ドメインの割合）
+	if report := result.Changepoints; report != nil {
+		writer, err := startSection("changepoints", []string{"date", "kinds", "before_daily_avg", "after_daily_avg", "volume_score", "mix_distance"})
+		if err != nil {
+			return err
+		}
+		for _, cp := range report.Changepoints {
+			if err := writer.Write([]string{cp.Date, strings.Join(cp.Kinds, " "), fmt.Sprintf("%.1f", cp.BeforeAvg), fmt.Sprintf("%.1f", cp.AfterAvg), fmt.Sprintf("%.2f", cp.VolumeScore), fmt.Sprintf("%.3f", cp.MixDistance)}); err != nil {
+				return err
+			}
+		}
+		writer, err = startSection("changepoint_domains", []string{"date", "domain", "before_share", "after_share"})
+		if err != nil {
+			return err
+		}
+		for _, cp := range report.Changepoints {
+			for _, d := range cp.Domains {
+				if err := writer.Write([]string{cp.Date, d.Domain, fmt.Sprintf("%.3f", d.BeforeShare), fmt.Sprintf("%.3f", d.AfterShare)}); err != nil {
+					return err
+				}
+			}
 		}
-		fmt.Println()
 	}
 
-	if showHourly && len(result.HourlyStats) > 0 {
-		fmt.Printf("⏰ 時間帯別訪問数\n")
-		fmt.Printf("─────────────────────────────────────────\n")
-		maxCount := 0
-		for _, s := range result.HourlyStats {
-			if s.VisitCount > maxCount {
-				maxCount = s.VisitCount
+	// 結合したCSVの指標との相関と、日付で揃えた指標と訪問数
+	if report := result.Joined; report != nil {
+		writer, err := startSection("joined_correlations", []string{"metric", "target", "days", "pearson", "spearman"})
+		if err != nil {
+			return err
+		}
+		for _, c := range report.Correlations {
+			if err := writer.Write([]string{c.Metric, c.Target, fmt.Sprintf("%d", c.Days), fmt.Sprintf("%.3f", c.Pearson), fmt.Sprintf("%.3f", c.Spearman)}); err != nil {
+				return err
 			}
 		}
-		for _, s := range result.HourlyStats {
-			barLen := 0
-			if maxCount > 0 {
-				barLen = int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
+		writer, err = startSection("joined_daily", append([]string{"date", "visits"}, report.Metrics...))
+		if err != nil {
+			return err
+		}
+		for _, d := range report.Daily {
+			record := []string{d.Date, fmt.Sprintf("%d", d.Visits)}
+			for _, metric := range report.Metrics {
+				value := ""
+				if v, ok := d.Metrics[metric]; ok {
+					value = strconv.FormatFloat(v, 'f', -1, 64)
+				}
+				record = append(record, value)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
 			}
-			bar := strings.Repeat("█", barLen)
-			fmt.Printf("  %02d:00  %s %d\n", s.Hour, bar, s.VisitCount)
 		}
-		fmt.Println()
 	}
 
-	if showDaily && len(result.DailyStats) > 0 {
-		fmt.Printf("📅 日別訪問数 (過去%d日間)\n", len(result.DailyStats))
-		fmt.Printf("─────────────────────────────────────────\n")
-		maxCount := 0
-		for _, s := range result.DailyStats {
-			if s.VisitCount > maxCount {
-				maxCount = s.VisitCount
+	// スクリーンタイムと訪問数（日別と相関）
+	if report := result.ScreenTime; report != nil {
+		writer, err := startSection("screen_time", []string{"date", "screen_minutes", "safari_minutes", "visits", "visits_per_hour"})
+		if err != nil {
+			return err
+		}
+		for _, d := range report.Days {
+			if err := writer.Write([]string{d.Date, fmt.Sprintf("%.1f", d.ScreenMinutes), fmt.Sprintf("%.1f", d.SafariMinutes), fmt.Sprintf("%d", d.Visits), fmt.Sprintf("%.1f", d.VisitsPerHour)}); err != nil {
+				return err
 			}
 		}
-		for _, s := range result.DailyStats {
-			barLen := 0
-			if maxCount > 0 {
-				barLen = int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
+		writer, err = startSection("screen_time_correlations", []string{"metric", "days", "pearson", "spearman"})
+		if err != nil {
+			return err
+		}
+		for _, c := range report.Correlations {
+			if err := writer.Write([]string{c.Metric, fmt.Sprintf("%d", c.Days), fmt.Sprintf("%.3f", c.Pearson), fmt.Sprintf("%.3f", c.Spearman)}); err != nil {
+				return err
 			}
-			bar := strings.Repeat("█", barLen)
-			fmt.Printf("  %s  %s %d\n", s.Date, bar, s.VisitCount)
 		}
-		fmt.Println()
 	}
-}
-
-// parseFlags はコマンドラインフラグを解析してConfigを返す
-func parseFlags() Config {
-	// コマンドラインフラグの定義
-	jsonOutput := flag.Bool("json", false, "JSON形式で出力")
-	limit := flag.Int("limit", DefaultHistoryLimit, "表示する履歴の件数")
-	domainLimit := flag.Int("domains", DefaultDomainLimit, "表示するドメイン統計の件数")
-	days := flag.Int("days", DefaultDailyDays, "日別統計の対象日数")
-
-	showHistory := flag.Bool("history", false, "履歴一覧を表示")
-	showDomains := flag.Bool("domain-stats", false, "ドメイン別統計を表示")
-	showHourly := flag.Bool("hourly", false, "時間帯別統計を表示")
-	showDaily := flag.Bool("daily", false, "日別統計を表示")
-	showAll := flag.Bool("all", false, "全ての分析結果を表示")
 
-	// 検索・フィルタオプション
-	search := flag.String("search", "", "キーワード検索（URL・タイトル）")
-	domain := flag.String("domain", "", "ドメインでフィルタ")
-	fromDate := flag.String("from", "", "開始日（YYYY-MM-DD）")
-	toDate := flag.String("to", "", "終了日（YYYY-MM-DD）")
-
-	// エクスポートオプション
-	csvOutput := flag.Bool("csv", false, "CSV形式で出力")
-	tsvOutput := flag.Bool("tsv", false, "TSV形式で出力")
-	outputFile := flag.String("output", "", "出力ファイルパス")
+	// タイトル別（URLは空白区切りで1列にまとめる）
+	if len(result.TitleClusters) > 0 {
+		writer, err := startSection("title_clusters", []string{"title", "visit_count", "url_count", "urls"})
+		if err != nil {
+			return err
+		}
+		for _, c := range result.TitleClusters {
+			record := []string{c.Title, fmt.Sprintf("%d", c.VisitCount), fmt.Sprintf("%d", len(c.URLs)), strings.Join(c.URLs, " ")}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
 
-	// インタラクティブモード
-	interactive := flag.Bool("interactive", false, "インタラクティブモードで起動")
-	flag.BoolVar(interactive, "i", false, "インタラクティブモードで起動（-interactiveの短縮形）")
+	// 動画
+	if result.Videos != nil && len(result.Videos.Videos) > 0 {
+		writer, err := startSection("videos", []string{"platform", "video_id", "title", "visit_count", "sessions", "last_visit", "url"})
+		if err != nil {
+			return err
+		}
+		for _, v := range result.Videos.Videos {
+			record := []string{v.Platform, v.ID, v.Title, fmt.Sprintf("%d", v.VisitCount), fmt.Sprintf("%d", v.Sessions), v.LastVisit.Format(TimeFormatFull), v.URL}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	// GitHubリポジトリ
+	if result.GitHub != nil && len(result.GitHub.Repos) > 0 {
+		header := append([]string{"repo", "visit_count"}, githubKindOrder...)
+		writer, err := startSection("github", append(header, "last_visit"))
+		if err != nil {
+			return err
+		}
+		for _, r := range result.GitHub.Repos {
+			record := []string{r.Repo, fmt.Sprintf("%d", r.VisitCount)}
+			for _, kind := range githubKindOrder {
+				record = append(record, fmt.Sprintf("%d", r.Kinds[kind]))
+			}
+			record = append(record, r.LastVisit.Format(TimeFormatFull))
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ドキュメント
+	if result.Docs != nil && len(result.Docs.Lookups) > 0 {
+		writer, err := startSection("docs", []string{"site", "path", "title", "url", "visit_count", "last_visit"})
+		if err != nil {
+			return err
+		}
+		for _, l := range result.Docs.Lookups {
+			record := []string{l.Site, l.Path, l.Title, l.URL, fmt.Sprintf("%d", l.VisitCount), l.LastVisit.Format(TimeFormatFull)}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ローカル・社内ホスト
+	if len(result.LocalHosts) > 0 {
+		writer, err := startSection("local_hosts", []string{"host", "port", "path", "title", "visit_count"})
+		if err != nil {
+			return err
+		}
+		for _, s := range result.LocalHosts {
+			for _, p := range s.Paths {
+				if err := writer.Write([]string{s.Host, s.Port, p.Path, p.Title, fmt.Sprintf("%d", p.VisitCount)}); err != nil {
+					return err
+				}
+			}
+			if s.OtherCount > 0 {
+				if err := writer.Write([]string{s.Host, s.Port, "", "", fmt.Sprintf("%d", s.OtherCount)}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// ドメイン別・カテゴリ別の時間帯プロファイル
+	for _, section := range []struct {
+		name, column string
+		profiles     []HourlyProfile
+	}{
+		{"hourly_profiles", "domain", result.HourlyProfiles},
+		{"category_profiles", "category", result.CategoryProfiles},
+	} {
+		if len(section.profiles) == 0 {
+			continue
+		}
+		header := []string{section.column, "visit_count", "peak_hour", "peak_share"}
+		for hour := 0; hour < 24; hour++ {
+			header = append(header, fmt.Sprintf("h%02d", hour))
+		}
+		writer, err := startSection(section.name, header)
+		if err != nil {
+			return err
+		}
+		for _, p := range section.profiles {
+			record := []string{p.Label(), fmt.Sprintf("%d", p.VisitCount), fmt.Sprintf("%d", p.PeakHour), fmt.Sprintf("%.1f", p.PeakShare)}
+			for _, c := range p.Hours {
+				record = append(record, fmt.Sprintf("%d", c))
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	// タイトルの言語
+	if len(result.TitleLanguages) > 0 {
+		writer, err := startSection("title_languages", []string{"lang", "label", "visit_count", "percentage"})
+		if err != nil {
+			return err
+		}
+		for _, s := range result.TitleLanguages {
+			if err := writer.Write([]string{s.Lang, s.Label, fmt.Sprintf("%d", s.VisitCount), fmt.Sprintf("%.1f", s.Percentage)}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 週別・月別統計
+	periods := []struct {
+		name   string
+		report *PeriodReport
+	}{{"weekly", result.WeeklyStats}, {"monthly", result.MonthlyStats}}
+	for _, period := range periods {
+		report := period.report
+		if report == nil {
+			continue
+		}
+		writer, err := startSection(period.name, []string{"unit", "start", "end", "fiscal_year", "visit_count"})
+		if err != nil {
+			return err
+		}
+		for _, p := range report.Periods {
+			if err := writer.Write([]string{report.Unit, p.Start, p.End, fmt.Sprintf("%d", p.FiscalYear), fmt.Sprintf("%d", p.VisitCount)}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 日別訪問数の分布（要約1行・最も多い日と少ない日・ヒストグラム）
+	if dist := result.Distribution; dist != nil {
+		writer, err := startSection("daily_distribution", []string{"from", "to", "days", "total_visits", "mean", "p50", "p90", "max", "min"})
+		if err != nil {
+			return err
+		}
+		if err := writer.Write([]string{dist.From, dist.To, fmt.Sprintf("%d", dist.Days), fmt.Sprintf("%d", dist.TotalVisits), fmt.Sprintf("%.1f", dist.Mean), fmt.Sprintf("%.1f", dist.P50), fmt.Sprintf("%.1f", dist.P90), fmt.Sprintf("%d", dist.Max), fmt.Sprintf("%d", dist.Min)}); err != nil {
+			return err
+		}
+		writer, err = startSection("daily_extremes", []string{"kind", "date", "visit_count"})
+		if err != nil {
+			return err
+		}
+		for _, e := range []struct {
+			kind string
+			days []DailyStats
+		}{{"busiest", dist.Busiest}, {"quietest", dist.Quietest}} {
+			for _, d := range e.days {
+				if err := writer.Write([]string{e.kind, d.Date, fmt.Sprintf("%d", d.VisitCount)}); err != nil {
+					return err
+				}
+			}
+		}
+		writer, err = startSection("daily_histogram", []string{"min", "max", "days"})
+		if err != nil {
+			return err
+		}
+		for _, b := range dist.Histogram {
+			if err := writer.Write([]string{fmt.Sprintf("%d", b.Min), fmt.Sprintf("%d", b.Max), fmt.Sprintf("%d", b.Days)}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// -group-by のグループ別統計と日別の内訳（1行に日付・グループ1つ）
+	if report := result.GroupStats; report != nil {
+		writer, err := startSection("groups", []string{"group_by", "group", "visit_count", "percentage"})
+		if err != nil {
+			return err
+		}
+		for _, g := range report.Groups {
+			if err := writer.Write([]string{report.GroupBy, g.Group, fmt.Sprintf("%d", g.VisitCount), fmt.Sprintf("%.1f", g.Percentage)}); err != nil {
+				return err
+			}
+		}
+		if len(report.Daily) > 0 {
+			writer, err := startSection("groups_daily", []string{"date", "group", "visit_count", "percentage"})
+			if err != nil {
+				return err
+			}
+			for _, d := range report.Daily {
+				for _, g := range d.Groups {
+					if err := writer.Write([]string{d.Date, g.Group, fmt.Sprintf("%d", g.VisitCount), fmt.Sprintf("%.1f", g.Percentage)}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// printTextOutput はテキスト形式で結果を出力
+func printTextOutput(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool) {
+	_, _ = fmt.Fprintf(w, "\n📊 Safari 履歴分析結果\n")
+	_, _ = fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	_, _ = fmt.Fprintf(w, "総訪問数: %d\n\n", result.TotalVisits)
+
+	if showHistory && len(result.RecentVisits) > 0 {
+		_, _ = fmt.Fprintf(w, "📝 最近の訪問履歴\n")
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		for _, v := range result.RecentVisits {
+			title := v.Title
+			if title == "" {
+				title = "(タイトルなし)"
+			}
+			title = truncateText(title, TitleTruncateLength)
+			_, _ = fmt.Fprintf(w, "  %s  %s\n", v.VisitTime.Format(TimeFormatDateTime), title)
+			if v.Domain != "" {
+				_, _ = fmt.Fprintf(w, "              📍 %s\n", v.Domain)
+			}
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if showDomains && len(result.DomainStats) > 0 {
+		_, _ = fmt.Fprintf(w, "🌐 ドメイン別訪問数 (Top %d)\n", len(result.DomainStats))
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		maxCount := result.DomainStats[0].VisitCount
+		for _, s := range result.DomainStats {
+			barLen := int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
+			bar := strings.Repeat("█", barLen)
+			_, _ = fmt.Fprintf(w, "  %s %s %d (%.1f%%, 累積 %.1f%%)\n", padRight(s.Domain, 20), bar, s.VisitCount, s.Percentage, s.CumulativePercentage)
+		}
+		last := result.DomainStats[len(result.DomainStats)-1]
+		_, _ = fmt.Fprintf(w, "  上位%dドメインで全体の %.1f%%\n", len(result.DomainStats), last.CumulativePercentage)
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if showPaths && len(result.DomainPathStats) > 0 {
+		_, _ = fmt.Fprintf(w, "🗂  ドメイン・パス別訪問数 (Top %d)\n", len(result.DomainPathStats))
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		maxCount := result.DomainPathStats[0].TotalCount
+		for _, ds := range result.DomainPathStats {
+			barLen := 0
+			if maxCount > 0 {
+				barLen = int(float64(ds.TotalCount) / float64(maxCount) * BarChartWidth)
+			}
+			_, _ = fmt.Fprintf(w, "  %s %s %d\n", padRight(ds.Domain, 20), strings.Repeat("█", barLen), ds.TotalCount)
+			for _, p := range ds.Paths {
+				label := p.Path
+				if p.Title != "" {
+					label = p.Title
+				}
+				_, _ = fmt.Fprintf(w, "      %s %d\n", padRight(truncateText(label, TitleTruncateLength), 30), p.VisitCount)
+				if p.Title != "" {
+					_, _ = fmt.Fprintf(w, "        📍 %s\n", truncateText(p.Path, TitleTruncateLength))
+				}
+			}
+			if ds.OtherCount > 0 {
+				_, _ = fmt.Fprintf(w, "      %s %d\n", padRight("その他", 30), ds.OtherCount)
+			}
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if showHourly && len(result.HourlyStats) > 0 {
+		_, _ = fmt.Fprintf(w, "⏰ 時間帯別訪問数\n")
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		maxCount := 0
+		for _, s := range result.HourlyStats {
+			if s.VisitCount > maxCount {
+				maxCount = s.VisitCount
+			}
+		}
+		for _, s := range result.HourlyStats {
+			barLen := 0
+			if maxCount > 0 {
+				barLen = int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
+			}
+			bar := strings.Repeat("█", barLen)
+			_, _ = fmt.Fprintf(w, "  %02d:00  %s %d\n", s.Hour, bar, s.VisitCount)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if showHourly && result.HourlySplit != nil {
+		printHourlySplit(w, *result.HourlySplit)
+	}
+
+	if len(result.TitleClusters) > 0 {
+		printTitleClusters(w, result.TitleClusters)
+	}
+
+	if result.Videos != nil && len(result.Videos.Videos) > 0 {
+		printVideoReport(w, *result.Videos)
+	}
+
+	if result.GitHub != nil && len(result.GitHub.Repos) > 0 {
+		printGitHubReport(w, *result.GitHub)
+	}
+
+	if result.Docs != nil && len(result.Docs.Lookups) > 0 {
+		printDocsReport(w, *result.Docs)
+	}
+
+	if len(result.LocalHosts) > 0 {
+		printLocalHostStats(w, result.LocalHosts)
+	}
+
+	if len(result.HourlyProfiles) > 0 {
+		printHourlyProfiles(w, result.HourlyProfiles)
+	}
+
+	if len(result.CategoryProfiles) > 0 {
+		printHourlyProfiles(w, result.CategoryProfiles)
+	}
+
+	if len(result.TitleLanguages) > 0 {
+		printTitleLanguageStats(w, result.TitleLanguages)
+	}
+
+	if showDaily && len(result.DailyStats) > 0 {
+		_, _ = fmt.Fprintf(w, "📅 日別訪問数 (過去%d日間)\n", len(result.DailyStats))
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		maxCount := 0
+		for _, s := range result.DailyStats {
+			if s.VisitCount > maxCount {
+				maxCount = s.VisitCount
+			}
+		}
+		for _, s := range result.DailyStats {
+			barLen := 0
+			if maxCount > 0 {
+				barLen = int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
+			}
+			bar := strings.Repeat("█", barLen)
+			line := fmt.Sprintf("  %s  %s %d  (%s)", s.Date, bar, s.VisitCount, formatRollingAverages(s))
+			if s.Holiday != "" {
+				line += "  🎌 " + s.Holiday
+			}
+			if offline := formatOfflineHours(s, time.Local); offline != "" {
+				line += "  " + offline
+			}
+			_, _ = fmt.Fprintln(w, line)
+		}
+		if hours := commonOfflineHours(result.DailyStats, time.Local); len(hours) > 0 {
+			_, _ = fmt.Fprintf(w, "  🚶 オフラインになりやすい時間帯: %s（通勤・外出など）\n", formatHourRanges(hours))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if result.Forecast != nil {
+		printVisitForecast(w, *result.Forecast)
+	}
+
+	if result.Changepoints != nil {
+		printChangepoints(w, *result.Changepoints)
+	}
+
+	if result.Joined != nil {
+		printJoinReport(w, *result.Joined)
+	}
+
+	if result.ScreenTime != nil {
+		printScreenTimeReport(w, *result.ScreenTime)
+	}
+
+	for _, report := range []*PeriodReport{result.WeeklyStats, result.MonthlyStats} {
+		if report != nil {
+			printPeriodStats(w, report)
+		}
+	}
+
+	if result.Distribution != nil {
+		printDailyDistribution(w, *result.Distribution)
+	}
+
+	if result.GroupStats != nil && !result.GroupStats.empty() {
+		printGroupReport(w, *result.GroupStats)
+	}
+}
+
+// parseFlags はコマンドラインフラグを解析してConfigを返す
+func parseFlags() Config {
+	// ExitOnError のままだと指定の誤りが ExitCodeNoMatch と同じ 2 で終了する
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	return parseFlagSet(flag.CommandLine, os.Args[1:])
+}
+
+// parseFlagSet は args を fs で解析してConfigを返す（hist batch の各行もこれで解析する）
+// 指定の誤りは exitWithError（ExitCodeError）で終了する。hist -h・-help は使い方を表示して ExitCodeOK で終了する
+func parseFlagSet(fs *flag.FlagSet, args []string) Config {
+	// コマンドラインフラグの定義
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	limit := fs.Int("limit", DefaultHistoryLimit, "表示する履歴の件数")
+	domainLimit := fs.Int("domains", DefaultDomainLimit, "表示するドメイン統計の件数")
+	days := fs.Int("days", DefaultDailyDays, "日別統計の対象日数")
+
+	showHistory := fs.Bool("history", false, "履歴一覧を表示")
+	showDomains := fs.Bool("domain-stats", false, "ドメイン別統計を表示")
+	showPaths := fs.Bool("paths", false, "ドメインごとの上位パスを表示")
+	pathLimit := fs.Int("path-limit", DefaultPathLimit, "-paths で各ドメインに表示するパスの件数")
+	showHourly := fs.Bool("hourly", false, "時間帯別統計を表示")
+	showDaily := fs.Bool("daily", false, "日別統計を表示")
+	showTitles := fs.Bool("title-clusters", false, "同じタイトルの訪問をまとめた訪問数ランキングを表示（件数は -limit）")
+	showVideos := fs.Bool("videos", false, "動画（YouTube・Netflix・Twitch）ごとの訪問数と推定視聴セッション数を表示（件数は -limit）")
+	showGitHub := fs.Bool("github", false, "GitHubのリポジトリごとの訪問数とPR・Issue・コード閲覧の内訳を表示（件数は -limit）")
+	showDocs := fs.Bool("docs", false, "登録したドキュメントサイトで調べたパッケージ・APIを表示（件数は -limit）")
+	showLocal := fs.Bool("local", false, "localhost・プライベートIP・.local/.internal などへの訪問をホスト・ポート・パス別に表示（件数は -limit / -path-limit）")
+	showProfiles := fs.Bool("profiles", false, "上位ドメインごとの時間帯別の分布とピークを並べて表示（件数は -domains。categories.txt があればカテゴリごとの分布も表示）")
+	showLangs := fs.Bool("title-langs", false, "タイトルの言語（日本語・英語など）別の訪問数を表示")
+	showWeekly := fs.Bool("weekly", false, "週別統計を表示（過去12週間、週の始まりは calendar.txt の week_start）")
+	showMonthly := fs.Bool("monthly", false, "月別統計を表示（過去12か月、年度の区切りは calendar.txt の fiscal_year_start）")
+	showDistribution := fs.Bool("distribution", false, "日別訪問数の分布（p50・p90・最大、最も多い日と少ない日、ヒストグラム）を表示（期間は -days か -from/-to）")
+	showForecast := fs.Bool("forecast", false, "今日からの1週間の日別訪問数と上位ドメインの訪問数を過去8週間から予測して表示")
+	joinCSV := fs.String("join-csv", "", "日ごとの指標のCSV（睡眠時間など）を日付で結合し、日別訪問数とカテゴリごとの訪問数との相関を表示")
+	joinOn := fs.String("join-on", DefaultJoinOn, "-join-csv で日付を読む列の名前")
+	screenTimePath := fs.String("screentime", "", "スクリーンタイムの使用時間（knowledgeC.db のコピーか date・app・minutes のCSV）と日別の訪問数を並べて相関を表示")
+	showChangepoints := fs.Bool("changepoints", false, "日別訪問数やドメインの割合が大きく変わった日を前後の上位ドメインと並べて表示（期間は -from/-to、なければ過去1年）")
+	showAll := fs.Bool("all", false, "全ての分析結果を表示")
+	source := fs.String("source", SourceSafari, "履歴DBのソース名（-group-by source・履歴の出力に使う。アーカイブDBの訪問は元のデバイス名）")
+	groupBy := fs.String("group-by", "", "過去 -days 日間の訪問数と割合を数える軸（domain・category・source・weekday・hour。domain・category・source は日別の内訳も並べる）")
+	split := fs.String("split", "", "分割表示（weekpart: 時間帯別統計を平日・週末の1日平均に分ける、work/personal: 仕事用・個人用のドメインリストごとに全ての統計を並べる）")
+
+	// 検索・フィルタオプション
+	search := fs.String("search", "", "キーワード検索（URL・タイトル）")
+	domain := fs.String("domain", "", "ドメインでフィルタ（カンマ区切りで複数指定可）")
+	fromDate := fs.String("from", "", "開始日（YYYY-MM-DD）")
+	toDate := fs.String("to", "", "終了日（YYYY-MM-DD）")
+	matchStdin := fs.Bool("match-stdin", false, "標準入力の URL・ドメイン（1行1件）に一致する訪問だけを対象にする")
+	tag := fs.String("tag", "", "hist tag でこのタグを付けたURL・訪問だけを対象にする")
+	starred := fs.Bool("starred", false, "hist star でスターを付けたページだけを対象にする")
+	titleLang := fs.String("title-lang", "", "タイトルの言語でフィルタ（ja, en, ko, zh, ru, und）")
+	foldKana := fs.Bool("fold-kana", false, "-search でひらがなとカタカナを区別しない")
+	romaji := fs.Bool("romaji", false, "ローマ字の -search をひらがな・カタカナと読みの辞書の語（nikki → 日記）にも広げて検索")
+	var rank RankMode
+	fs.Var(&rank, "rank", "最近の履歴（-history）と -picker の並び順（recent: 新しい順、frecency: 訪問の多さと新しさを合わせたスコア順で、URLごとに1件）")
+	fuzzy := fs.Bool("fuzzy", false, "-search をあいまい検索にし、最近の履歴を一致度の高い順に表示（インタラクティブモードの / 検索にも適用）")
+
+	// エクスポートオプション
+	csvOutput := fs.Bool("csv", false, "CSV形式で出力")
+	tsvOutput := fs.Bool("tsv", false, "TSV形式で出力")
+	ndjsonOutput := fs.Bool("ndjson", false, "履歴を1行1件のJSON（NDJSON）で逐次出力（-limit 0 で全件）")
+	sqliteOutput := fs.String("sqlite", "", "訪問・ドメイン・日別統計・セッションの各テーブルを新しいSQLiteファイルに書き出す（-limit によらず全件）")
+	pickerOutput := fs.Bool("picker", false, "fzf 向けに「URL\tタイトル\t日時」を1行ずつ出力（-limit 未指定時は全件）")
+	tableOutput := fs.Bool("table", false, "表形式で出力（全角文字を考慮して列を揃える）")
+	tableBorder := fs.Bool("table-border", false, "-table で罫線を表示")
+	format := fs.String("format", "", "出力形式の名前（text・json・csv・tsv・markdown・html・table・quiet と、"+formattersFileName+" で追加した形式）")
+	var outputFiles outputPaths
+	fs.Var(&outputFiles, "output", "出力ファイルパス（繰り返し指定すると拡張子 .json・.csv・.tsv・.md・.txt の形式でそれぞれに書き出す。{{date}}・{{month}}・{{year}}・{{time}} は実行日時に置き換える）")
+	csvSplit := fs.String("csv-split", "", "統計ごとに history.csv・domains.csv などを指定したディレクトリに書き出し、列と絞り込み条件を manifest.json にまとめる（{{date}} などは -output と同じ）")
+	appendOutput := fs.Bool("append", false, "-output の CSV・TSV・NDJSON のファイルを上書きせずに追記する（CSV・TSV は空のファイルにだけ見出し行を書く）")
+	encryptOutput := fs.Bool("encrypt", false, "-output・-sqlite のファイルを AES-256-GCM で暗号化する（拡張子 .enc を付ける。鍵は -key-file か環境変数 "+HistPassphraseEnv+" のパスフレーズ）")
+	keyFile := fs.String("key-file", "", "暗号化・復号に使う鍵ファイル（32バイトの16進数。環境変数 "+HistKeyFileEnv+" と同じ）")
+	noHooks := fs.Bool("no-hooks", false, "-output のファイルを書き出した後に hooks.txt の on_report_generated を実行しない")
+	var anonymize AnonymizeMode
+	fs.Var(&anonymize, "anonymize", "URL・タイトルをハッシュ化して出力（=strict でドメインもハッシュ化）")
+
+	// デバッグ
+	strict := fs.Bool("strict", false, "不正なタイトル・URL（不正なUTF-8、制御文字、data:/javascript: URL）をサニタイズせずエラーにする")
+	quiet := fs.Bool("quiet", false, "見出し・罫線・グラフを省き、データ行だけをタブ区切りで出力")
+	fs.BoolVar(quiet, "q", false, "-quiet の短縮形")
+	explain := fs.Bool("explain", false, "クエリを実行せず、生成されたSQL・パラメータ・実行計画を表示")
+
+	// インタラクティブモード
+	interactive := fs.Bool("interactive", false, "インタラクティブモードで起動")
+	fs.BoolVar(interactive, "i", false, "インタラクティブモードで起動（-interactiveの短縮形）")
 
 	// Webサーバーモード
-	serve := flag.Bool("serve", false, "Webサーバーモードで起動")
-	port := flag.Int("port", DefaultWebPort, "Webサーバーのポート番号")
+	serve := fs.Bool("serve", false, "Webサーバーモードで起動")
+	port := fs.Int("port", DefaultWebPort, "Webサーバーのポート番号")
+	maxMemory := fs.String("max-memory", "", "ドメイン・パス別の集計でメモリに持つ部分集計の上限（512MB・2GB など）。超えた分は一時 SQLite に書き出して集計する（数百万件の訪問をまとめたアーカイブDB向け）")
+	sharedConn := fs.Bool("shared-conn", false, "全てのクエリで1本の読み取り接続を共有")
+	favicons := fs.Bool("favicons", false, "Web UI でドメインのファビコンを取得して表示（~/.config/hist/favicons にキャッシュ）")
+	rateLimit := fs.Float64("rate-limit", DefaultWebRateLimit, "Web API のクライアントIPごとの1秒あたりのリクエスト数の上限（0で無制限）")
 
 	// イグノアリスト管理
-	ignoreAdd := flag.String("ignore-add", "", "ドメインをイグノアリストに追加")
-	ignoreRemove := flag.String("ignore-remove", "", "ドメインをイグノアリストから削除")
-	ignoreList := flag.Bool("ignore-list", false, "イグノアリストを表示")
-	noIgnore := flag.Bool("no-ignore", false, "イグノアリストを無視して実行")
+	ignoreAdd := fs.String("ignore-add", "", "ドメインをイグノアリストに追加")
+	ignoreRemove := fs.String("ignore-remove", "", "ドメインをイグノアリストから削除")
+	ignoreList := fs.Bool("ignore-list", false, "イグノアリストを表示")
+	noIgnore := fs.Bool("no-ignore", false, "イグノアリストを無視して実行")
+	filterTrackers := fs.Bool("filter-trackers", false, "同梱のトラッカー・広告ドメインリストに一致する訪問を除外")
+	collapseRedirects := fs.Bool("collapse-redirects", false, "ドメイン別統計で短縮URL・中継ページ（t.co など）への訪問を転送先のドメインに数える")
+
+	// リダクトリスト管理
+	redactAdd := fs.String("redact-add", "", "ドメインをリダクトリストに追加")
+	redactRemove := fs.String("redact-remove", "", "ドメインをリダクトリストから削除")
+	redactList := fs.Bool("redact-list", false, "リダクトリストを表示")
+	noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+
+	// ドキュメントサイト管理
+	docsAdd := fs.String("docs-add", "", "ドキュメントサイトを登録（\"ホスト[/パス] [階層数]\" 形式）")
+	docsRemove := fs.String("docs-remove", "", "ドキュメントサイトの登録を削除")
+	docsList := fs.Bool("docs-list", false, "登録済みのドキュメントサイトを表示")
+
+	// 対象の履歴DB・基準日
+	var dbFiles dbPaths
+	fs.Var(&dbFiles, "db", "Safari の履歴DBの代わりに読み込むファイル（hist gen で作成したDBやバックアップなど。環境変数 "+HistDBEnv+" と同じ。繰り返し指定すると並行に読み込んでまとめ、\"名前=パス\" の名前を -group-by source のソース名にする）")
+	browser := fs.String("browser", "", "読み込むブラウザの履歴DB（safari・safari-tp、all はインストールされている全てのブラウザをまとめる）")
+	nowDate := fs.String("now", "", "集計の基準日（YYYY-MM-DD）。その日の終わりに実行したものとして、以降の訪問を除いて -days・-weekly などを数える")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) && fs == flag.CommandLine {
+			os.Exit(ExitCodeOK)
+		}
+		exitWithError("エラー: %v\n", err)
+	}
+
+	dbSources, err := resolveDBSources(dbFiles, *browser)
+	if err != nil {
+		exitWithError("エラー: %v\n", err)
+	}
+	// 1つだけならサブコマンドと同じく getDBPath で参照できるよう環境変数に設定し、複数なら openMergedDB でまとめる
+	if len(dbSources) == 1 {
+		if len(dbFiles) > 0 || dbSources[0].Label != BrowserSafari {
+			if err := os.Setenv(HistDBEnv, dbSources[0].Path); err != nil {
+				exitWithError("エラー: %v\n", err)
+			}
+		}
+		dbSources = nil
+	}
+	if err := setKeyFileEnv(*keyFile); err != nil {
+		exitWithError("エラー: %v\n", err)
+	}
+
+	// ピッカーは -limit を指定しなければ全件を対象にする
+	limitSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "limit" {
+			limitSet = true
+		}
+	})
+	if *pickerOutput && !limitSet {
+		*limit = 0
+	}
+
+	// イグノアリスト管理コマンドの処理
+	if *ignoreList {
+		if err := PrintIgnoreList(); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		os.Exit(0)
+	}
+	if *ignoreAdd != "" {
+		if err := AddToIgnoreList(*ignoreAdd); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		fmt.Printf("イグノアリストに追加しました: %s\n", *ignoreAdd)
+		os.Exit(0)
+	}
+	if *ignoreRemove != "" {
+		if err := RemoveFromIgnoreList(*ignoreRemove); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		fmt.Printf("イグノアリストから削除しました: %s\n", *ignoreRemove)
+		os.Exit(0)
+	}
+
+	// リダクトリスト管理コマンドの処理
+	if *redactList {
+		if err := PrintRedactList(); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		os.Exit(0)
+	}
+	if *redactAdd != "" {
+		if err := AddToRedactList(*redactAdd); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		fmt.Printf("リダクトリストに追加しました: %s\n", *redactAdd)
+		os.Exit(0)
+	}
+	if *redactRemove != "" {
+		if err := RemoveFromRedactList(*redactRemove); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		fmt.Printf("リダクトリストから削除しました: %s\n", *redactRemove)
+		os.Exit(0)
+	}
+
+	// ドキュメントサイト管理コマンドの処理
+	if *docsList {
+		if err := PrintDocSites(); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		os.Exit(0)
+	}
+	if *docsAdd != "" {
+		if err := AddToDocSites(*docsAdd); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		fmt.Printf("ドキュメントサイトを登録しました: %s\n", *docsAdd)
+		os.Exit(0)
+	}
+	if *docsRemove != "" {
+		if err := RemoveFromDocSites(*docsRemove); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		fmt.Printf("ドキュメントサイトの登録を削除しました: %s\n", *docsRemove)
+		os.Exit(0)
+	}
+
+	// -now の日の終わりを基準時刻にする（-output の {{date}} などもその日付になる）
+	var replayDay, replayAt time.Time
+	now := time.Now()
+	if *nowDate != "" {
+		var err error
+		replayDay, err = parseLocalDate(*nowDate)
+		if err != nil {
+			exitWithError("エラー: 基準日の形式が不正です（YYYY-MM-DD）: %v\n", err)
+		}
+		replayAt = replayDay.Add(24*time.Hour - time.Second)
+		now = replayAt
+	}
+
+	// formatters.txt の外部コマンドを -format・-output の拡張子で選べるようにする
+	if err := loadExternalFormatters(); err != nil {
+		exitWithError("エラー: %v\n", err)
+	}
+	if *format != "" {
+		if _, ok := outputFormatters[*format]; !ok {
+			exitWithError("エラー: -format に指定できるのは %s です: %s\n", strings.Join(outputFormatNames(), "・"), *format)
+		}
+	}
+
+	// 出力先（複数指定した場合は拡張子で形式を判定する）
+	for i, path := range outputFiles {
+		expanded, err := expandOutputPath(path, now)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		outputFiles[i] = expanded
+	}
+	// -encrypt は全ての出力ファイルに .enc を付けて暗号化する（.enc で終わるパスは -encrypt なしでも暗号化する）
+	if *encryptOutput {
+		if len(outputFiles) == 0 && *sqliteOutput == "" {
+			exitWithError("エラー: -encrypt は -output・-sqlite のファイルにのみ指定できます\n")
+		}
+		if *csvSplit != "" {
+			exitWithError("エラー: -encrypt は -csv-split と併用できません\n")
+		}
+		for i, path := range outputFiles {
+			outputFiles[i] = withEncryptionExt(path)
+		}
+		if *sqliteOutput != "" {
+			*sqliteOutput = withEncryptionExt(*sqliteOutput)
+		}
+		// 集計してから鍵がないことに気づかないよう、先に確かめる
+		if _, err := loadEncryptionKey(); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+	}
+	formatFlagged := *format != "" || *jsonOutput || *csvOutput || *tsvOutput || *tableOutput || *tableBorder || *quiet
+	// -output visits.ndjson.gz のように拡張子が .ndjson なら -ndjson を指定したものとする
+	ndjson := *ndjsonOutput || len(outputFiles) == 1 && !formatFlagged && isNDJSONPath(outputFiles[0])
+	if len(outputFiles) > 1 && (formatFlagged || ndjson || *sqliteOutput != "" || *pickerOutput || *split == SplitWorkPersonal) {
+		exitWithError("エラー: 複数の -output は拡張子で形式を判定するため、-format・-json・-csv・-tsv・-table・-quiet・-ndjson・-sqlite・-picker・-split %s と併用できません\n", SplitWorkPersonal)
+	}
+	csvSplitDir := ""
+	if *csvSplit != "" {
+		if len(outputFiles) > 0 || formatFlagged || ndjson || *sqliteOutput != "" || *pickerOutput || *split == SplitWorkPersonal || *appendOutput {
+			exitWithError("エラー: -csv-split は -output・-format・-json・-csv・-tsv・-table・-quiet・-ndjson・-sqlite・-picker・-append・-split %s と併用できません\n", SplitWorkPersonal)
+		}
+		var err error
+		csvSplitDir, err = expandOutputPath(*csvSplit, now)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+	}
+	outputTargets, err := resolveOutputTargets(outputFiles, formatFlagged || ndjson)
+	if err != nil {
+		exitWithError("エラー: %v\n", err)
+	}
+	outputFile := ""
+	if len(outputFiles) == 1 {
+		outputFile = outputFiles[0]
+	}
+	if *appendOutput {
+		if err := checkAppendTargets(outputTargets); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		appendable := outputTargets != nil || outputFile != "" && (ndjson || *csvOutput || *tsvOutput || canAppend(*format))
+		if !appendable {
+			exitWithError("エラー: -append は -output の CSV・TSV・NDJSON のファイルにのみ指定できます\n")
+		}
+	}
 
-	flag.Parse()
+	hourlySplit := ""
+	var buckets []DomainBucket
+	switch *split {
+	case "":
+	case HourlySplitWeekpart:
+		hourlySplit = *split
+	case SplitWorkPersonal:
+		bucketFormat := *format == "" || *format == OutputFormatText || *format == OutputFormatJSON || *format == OutputFormatTable
+		if *matchStdin || ndjson || *sqliteOutput != "" || *pickerOutput || *csvOutput || *tsvOutput || *quiet || !bucketFormat {
+			exitWithError("エラー: -split %s は -match-stdin・-ndjson・-sqlite・-picker・-csv・-tsv・-quiet・-format（text・json・table 以外）と併用できません\n", SplitWorkPersonal)
+		}
+		var err error
+		buckets, err = LoadDomainBuckets()
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+	default:
+		exitWithError("エラー: -split に指定できるのは %s または %s です: %s\n", HourlySplitWeekpart, SplitWorkPersonal, *split)
+	}
 
-	// イグノアリスト管理コマンドの処理
-	if *ignoreList {
-		if err := PrintIgnoreList(); err != nil {
+	var categories []DomainCategory
+	if *groupBy != "" {
+		if _, err := newGroupDimension(*groupBy, groupOptions{}); err != nil {
 			exitWithError("エラー: %v\n", err)
 		}
-		os.Exit(0)
 	}
-	if *ignoreAdd != "" {
-		if err := AddToIgnoreList(*ignoreAdd); err != nil {
+	// -profiles は categories.txt があればカテゴリ別のプロファイルも並べる
+	if *groupBy == GroupByCategory || *joinCSV != "" || *showProfiles || *showAll {
+		var err error
+		categories, err = LoadDomainCategories()
+		if err != nil {
+			exitWithError("エラー: カテゴリ一覧の読み込みに失敗: %v\n", err)
+		}
+	}
+	var joinMetrics *JoinedMetrics
+	if *joinCSV != "" {
+		var err error
+		joinMetrics, err = LoadJoinedMetrics(*joinCSV, *joinOn)
+		if err != nil {
 			exitWithError("エラー: %v\n", err)
 		}
-		fmt.Printf("イグノアリストに追加しました: %s\n", *ignoreAdd)
-		os.Exit(0)
 	}
-	if *ignoreRemove != "" {
-		if err := RemoveFromIgnoreList(*ignoreRemove); err != nil {
+	var screenTime *JoinedMetrics
+	if *screenTimePath != "" {
+		var err error
+		screenTime, err = LoadScreenTime(*screenTimePath)
+		if err != nil {
 			exitWithError("エラー: %v\n", err)
 		}
-		fmt.Printf("イグノアリストから削除しました: %s\n", *ignoreRemove)
-		os.Exit(0)
 	}
 
 	// フィルタ条件を構築
 	var filter SearchFilter
 	filter.Keyword = *search
-	filter.Domain = *domain
+	filter.FoldKana = *foldKana
+	filter.Romaji = *romaji
+	if strings.Contains(*domain, ",") {
+		// 複数ドメインはいずれかに一致する訪問を対象にする
+		for _, d := range strings.Split(*domain, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				filter.Domains = append(filter.Domains, d)
+			}
+		}
+	} else {
+		filter.Domain = *domain
+	}
 
 	if *fromDate != "" {
-		t, err := time.Parse(TimeFormatDate, *fromDate)
+		t, err := parseLocalDate(*fromDate)
 		if err != nil {
 			exitWithError("エラー: 開始日の形式が不正です（YYYY-MM-DD）: %v\n", err)
 		}
 		filter.From = t
 	}
 	if *toDate != "" {
-		t, err := time.Parse(TimeFormatDate, *toDate)
+		t, err := parseLocalDate(*toDate)
 		if err != nil {
 			exitWithError("エラー: 終了日の形式が不正です（YYYY-MM-DD）: %v\n", err)
 		}
 		filter.To = t
 	}
+	// 基準日より後の訪問は、その日に実行した時点ではまだないため除く
+	if !replayDay.IsZero() && (filter.To.IsZero() || filter.To.After(replayDay)) {
+		filter.To = replayDay
+	}
+
+	if *matchStdin {
+		match, err := parseMatchList(os.Stdin)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		filter.Match = match
+	}
+	if *tag != "" {
+		path, err := getSidecarPath()
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		match, err := loadTagMatch(path, *tag)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		filter.Tag = match
+	}
+	if *starred {
+		path, err := getSidecarPath()
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		match, err := loadStarredMatch(path)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		filter.Starred = match
+	}
+	filter.ExcludeTrackers = *filterTrackers
+	if *titleLang != "" {
+		lang, err := parseTitleLang(*titleLang)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		filter.TitleLang = lang
+	}
+	filter.CollapseRedirects = *collapseRedirects
+	if filter.MemoryLimit, err = parseByteSize(*maxMemory); err != nil {
+		exitWithError("エラー: -max-memory: %v\n", err)
+	}
 
 	// イグノアリストを読み込み
 	if !*noIgnore {
-		ignoreDomains, err := LoadIgnoreList()
+		entries, err := LoadIgnoreList()
 		if err != nil {
 			exitWithError("エラー: イグノアリストの読み込みに失敗: %v\n", err)
 		}
+		ignoreDomains, ignoreRules, err := splitIgnoreEntries(entries)
+		if err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
 		filter.IgnoreDomains = ignoreDomains
+		filter.IgnoreRules = ignoreRules
+	}
+
+	// フックを読み込み
+	var hooks Hooks
+	if !*noHooks {
+		var err error
+		if hooks, err = LoadHooks(); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+	}
+
+	// リダクトリストを読み込み
+	var redactDomains []string
+	if !*noRedact {
+		var err error
+		redactDomains, err = LoadRedactList()
+		if err != nil {
+			exitWithError("エラー: リダクトリストの読み込みに失敗: %v\n", err)
+		}
 	}
 
 	// 表示オプションの正規化
 	history := *showHistory
 	domains := *showDomains
+	paths := *showPaths
 	hourly := *showHourly
 	daily := *showDaily
+	titles := *showTitles
+	videos := *showVideos
+	github := *showGitHub
+	docs := *showDocs
+	local := *showLocal
+	profiles := *showProfiles
+	langs := *showLangs
+	weekly := *showWeekly
+	monthly := *showMonthly
+	distribution := *showDistribution
+	forecast := *showForecast
+	changepoints := *showChangepoints
 
 	// -all が指定された場合は全て表示
 	if *showAll {
 		history = true
 		domains = true
+		paths = true
 		hourly = true
 		daily = true
+		titles = true
+		videos = true
+		github = true
+		docs = true
+		local = true
+		profiles = true
+		langs = true
+		weekly = true
+		monthly = true
+		distribution = true
+		forecast = true
+		changepoints = true
+	}
+
+	// ドキュメントサイトの登録を読み込み
+	var docSites []DocSitePattern
+	if docs {
+		var err error
+		docSites, err = LoadDocSites()
+		if err != nil {
+			exitWithError("エラー: ドキュメントサイト一覧の読み込みに失敗: %v\n", err)
+		}
+	}
+
+	// 週の始まり・年度の始まりを読み込み
+	cal := defaultCalendar
+	if weekly || monthly {
+		var err error
+		cal, err = LoadCalendar()
+		if err != nil {
+			exitWithError("エラー: 暦の設定の読み込みに失敗: %v\n", err)
+		}
+	}
+
+	// 休日の設定を読み込み
+	var holidays *Holidays
+	if daily || hourly && hourlySplit == HourlySplitWeekpart {
+		var err error
+		holidays, err = LoadHolidays()
+		if err != nil {
+			exitWithError("エラー: 休日一覧の読み込みに失敗: %v\n", err)
+		}
 	}
 
 	// 何も指定されていない場合はデフォルトで履歴を表示
-	if !history && !domains && !hourly && !daily {
+	if !history && !domains && !paths && !hourly && !daily && !titles && !videos && !github && !docs && !local && !profiles && !langs && !weekly && !monthly && !distribution && !forecast && !changepoints && joinMetrics == nil && screenTime == nil && *groupBy == "" {
 		history = true
 	}
 
 	return Config{
-		Limit:       *limit,
-		DomainLimit: *domainLimit,
-		Days:        *days,
-		ShowHistory: history,
-		ShowDomains: domains,
-		ShowHourly:  hourly,
-		ShowDaily:   daily,
-		Filter:      filter,
-		JSONOutput:  *jsonOutput,
-		CSVOutput:   *csvOutput,
-		TSVOutput:   *tsvOutput,
-		OutputFile:  *outputFile,
-		Interactive: *interactive,
-		Serve:       *serve,
-		Port:        *port,
+		Limit:            *limit,
+		DomainLimit:      *domainLimit,
+		PathLimit:        *pathLimit,
+		Days:             *days,
+		ShowHistory:      history,
+		ShowDomains:      domains,
+		ShowPaths:        paths,
+		ShowHourly:       hourly,
+		ShowDaily:        daily,
+		ShowTitles:       titles,
+		ShowVideos:       videos,
+		ShowGitHub:       github,
+		ShowDocs:         docs,
+		DocSites:         docSites,
+		ShowLocal:        local,
+		ShowProfiles:     profiles,
+		ShowLangs:        langs,
+		ShowWeekly:       weekly,
+		ShowMonthly:      monthly,
+		ShowDistribution: distribution,
+		ShowForecast:     forecast,
+		ShowChangepoints: changepoints,
+		Calendar:         cal,
+		Holidays:         holidays,
+		HourlySplit:      hourlySplit,
+		BucketSplit:      buckets != nil,
+		Buckets:          buckets,
+		Source:           *source,
+		DBSources:        dbSources,
+		GroupBy:          *groupBy,
+		Categories:       categories,
+		JoinMetrics:      joinMetrics,
+		ScreenTime:       screenTime,
+		Filter:           filter,
+		Now:              replayAt,
+		Fuzzy:            *fuzzy,
+		Rank:             rank,
+		RedactDomains:    redactDomains,
+		NoRedact:         *noRedact,
+		Format:           *format,
+		JSONOutput:       *jsonOutput,
+		CSVOutput:        *csvOutput,
+		TSVOutput:        *tsvOutput,
+		NDJSONOutput:     ndjson,
+		PickerOutput:     *pickerOutput,
+		SQLiteOutput:     *sqliteOutput,
+		TableOutput:      *tableOutput || *tableBorder,
+		TableBorder:      *tableBorder,
+		OutputFile:       outputFile,
+		Outputs:          outputTargets,
+		Append:           *appendOutput,
+		Hooks:            hooks,
+		CSVSplitDir:      csvSplitDir,
+		Anonymize:        anonymize,
+		Explain:          *explain,
+		Quiet:            *quiet,
+		Strict:           *strict,
+		Interactive:      *interactive,
+		Serve:            *serve,
+		Port:             *port,
+		SharedConn:       *sharedConn,
+		Favicons:         *favicons,
+		RateLimit:        *rateLimit,
+	}
+}
+
+// now は集計の基準時刻を返す（-now がなければ現在時刻）
+func (c Config) now() time.Time {
+	if c.Now.IsZero() {
+		return time.Now()
 	}
+	return c.Now
 }
 
 // setupDatabase はデータベース接続を確立する
@@ -947,13 +2188,31 @@ func setupDatabase() (*sql.DB, error) {
 // runInteractiveOrWebMode はインタラクティブまたはWebモードを実行する
 func runInteractiveOrWebMode(db *sql.DB, config Config) error {
 	if config.Interactive {
-		return runInteractiveMode(db)
+		return runInteractiveMode(db, config.RedactDomains, config.Fuzzy, SearchFilter{
+			Index:    config.Filter.Index,
+			FoldKana: config.Filter.FoldKana,
+			Romaji:   config.Filter.Romaji,
+		})
 	}
 	if config.Serve {
-		server, err := NewWebServer(db, config.Port)
+		server, err := NewWebServer(db, config.Port, config.RedactDomains)
 		if err != nil {
 			return err
 		}
+		server.searchIndex = config.Filter.Index
+		server.rateLimiter = newRateLimiter(config.RateLimit, WebRateBurst)
+		if config.Favicons {
+			if server.favicons, err = newFaviconCache(); err != nil {
+				return err
+			}
+		}
+		// 監視できない環境でもサーバーは起動する（設定の変更には再起動が必要）
+		stop, err := server.WatchConfig(!config.NoRedact)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 設定ファイルの自動再読み込みを無効化: %v\n", err)
+		} else {
+			defer func() { _ = stop() }()
+		}
 		return server.Start()
 	}
 	return nil
@@ -961,92 +2220,432 @@ func runInteractiveOrWebMode(db *sql.DB, config Config) error {
 
 // runCLIMode はCLIモードで分析を実行する
 func runCLIMode(db *sql.DB, config Config) error {
-	var result AnalysisResult
-	var err error
-
-	// 総訪問数を取得
-	result.TotalVisits, err = getTotalVisits(db)
-	if err != nil {
-		return fmt.Errorf("総訪問数の取得に失敗: %w", err)
-	}
-
-	// 各種統計を取得
-	if config.ShowHistory {
-		result.RecentVisits, err = getRecentVisits(db, config.Limit, config.Filter)
+	// ピッカーは fzf ですぐに絞り込めるよう、結果を溜めずに標準出力へ逐次出力する（frecency 順は集計してから出力）
+	if config.PickerOutput {
+		var count int
+		var err error
+		if config.Rank == RankFrecency {
+			count, err = writeFrecencyPickerLines(os.Stdout, db, config.Limit, config.Filter, config.RedactDomains, config.now())
+		} else {
+			count, err = writePickerLines(os.Stdout, db, config.Limit, config.Filter, config.RedactDomains)
+		}
 		if err != nil {
-			return fmt.Errorf("履歴の取得に失敗: %w", err)
+			return fmt.Errorf("ピッカー出力エラー: %w", err)
+		}
+		if count == 0 {
+			return errNoMatches
 		}
+		return nil
 	}
 
-	if config.ShowDomains {
-		result.DomainStats, err = getDomainStats(db, config.DomainLimit, config.Filter)
+	// NDJSONは結果を溜めずに履歴を逐次出力する
+	if config.NDJSONOutput {
+		openNDJSON := openOutput
+		if config.Append {
+			openNDJSON = openAppendWriter
+		}
+		output, closeOutput, err := openNDJSON(config.OutputFile)
 		if err != nil {
-			return fmt.Errorf("ドメイン統計の取得に失敗: %w", err)
+			return err
+		}
+		count, err := writeNDJSON(output, db, config)
+		if cerr := closeOutput(); err == nil {
+			err = cerr
 		}
-	}
-
-	if config.ShowHourly {
-		result.HourlyStats, err = getHourlyStats(db, config.Filter)
 		if err != nil {
-			return fmt.Errorf("時間帯統計の取得に失敗: %w", err)
+			return fmt.Errorf("NDJSON出力エラー: %w", err)
 		}
+		if count == 0 {
+			return errNoMatches
+		}
+		return nil
 	}
 
-	if config.ShowDaily {
-		result.DailyStats, err = getDailyStats(db, config.Days, config.Filter)
+	// SQLiteへの書き出しは表示用の集計を行わず、全件を正規化したテーブルにする
+	if config.SQLiteOutput != "" {
+		count, err := exportSQLite(db, config.SQLiteOutput, config)
 		if err != nil {
-			return fmt.Errorf("日別統計の取得に失敗: %w", err)
+			return fmt.Errorf("SQLite出力エラー: %w", err)
 		}
+		if count == 0 {
+			return errNoMatches
+		}
+		fmt.Fprintf(os.Stderr, "%d件の訪問を %s に書き出しました\n", count, config.SQLiteOutput)
+		return nil
+	}
+
+	if config.BucketSplit {
+		return runBucketSplit(db, config)
+	}
+
+	result, err := analyze(db, config)
+	if err != nil {
+		return err
 	}
 
 	// 出力処理
-	return outputResult(result, config)
+	if config.CSVSplitDir != "" {
+		err = outputCSVSplit(result, config)
+	} else {
+		err = outputResult(result, config)
+	}
+	if err != nil {
+		return err
+	}
+	if !result.hasMatches() {
+		return errNoMatches
+	}
+	return nil
 }
 
-// outputResult は結果を指定された形式で出力する
-func outputResult(result AnalysisResult, config Config) error {
-	// 出力先を決定
-	var output io.Writer = os.Stdout
-	if config.OutputFile != "" {
-		f, err := os.Create(config.OutputFile)
+// analyze は設定で指定された統計を集計する
+// 統計どうしは独立しているため、StatsParallelism 個ずつ並行に集計する（それぞれ result の別のフィールドに書き込む）
+func analyze(db dbQuerier, config Config) (AnalysisResult, error) {
+	result := AnalysisResult{Query: newQueryInfo(config.Filter, time.Now())}
+	if !config.Now.IsZero() {
+		result.Query.Now = &config.Now
+	}
+	// 履歴の出力・ソース別統計で使う訪問のソース
+	var sources *visitSources
+	if config.ShowHistory || config.GroupBy == GroupBySource {
+		var err error
+		sources, err = loadVisitSources(db, config.Source)
 		if err != nil {
-			return fmt.Errorf("ファイル作成エラー: %w", err)
+			return AnalysisResult{}, err
 		}
-		defer func() { _ = f.Close() }()
-		output = f
 	}
+	g := newTaskGroup(StatsParallelism)
 
-	// 出力形式に応じて出力
-	switch {
-	case config.JSONOutput:
-		encoder := json.NewEncoder(output)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(result); err != nil {
-			return fmt.Errorf("JSON出力エラー: %w", err)
+	// 総訪問数を取得
+	g.Go(func() error {
+		var err error
+		result.TotalVisits, err = getTotalVisits(db)
+		if err != nil {
+			return fmt.Errorf("総訪問数の取得に失敗: %w", err)
 		}
-	case config.CSVOutput:
-		if err := writeCSV(output, result, config.ShowHistory, config.ShowDomains, config.ShowHourly, config.ShowDaily, ','); err != nil {
-			return fmt.Errorf("CSV出力エラー: %w", err)
+		return nil
+	})
+
+	// 各種統計を取得
+	if config.ShowHistory {
+		g.Go(func() error {
+			var err error
+			// あいまい検索・frecency 順は最近の履歴だけに適用し、他の統計はキーワードの部分一致で絞り込む
+			switch {
+			case config.Rank == RankFrecency:
+				fuzzyQuery := ""
+				if config.Fuzzy {
+					fuzzyQuery = config.Filter.Keyword
+				}
+				result.RecentVisits, err = getFrecencyVisits(db, config.Limit, config.Filter, fuzzyQuery, config.now())
+			case config.Fuzzy && config.Filter.Keyword != "":
+				result.RecentVisits, err = getFuzzyVisits(db, config.Limit, config.Filter.Keyword, config.Filter)
+			default:
+				result.RecentVisits, err = getRecentVisits(db, config.Limit, config.Filter)
+			}
+			if err != nil {
+				return fmt.Errorf("履歴の取得に失敗: %w", err)
+			}
+			return nil
+		})
+	}
+
+	if config.ShowDomains {
+		g.Go(func() error {
+			var err error
+			result.DomainStats, err = getDomainStats(db, config.DomainLimit, config.Filter)
+			if err != nil {
+				return fmt.Errorf("ドメイン統計の取得に失敗: %w", err)
+			}
+			return nil
+		})
+	}
+
+	if config.ShowPaths {
+		g.Go(func() error {
+			var err error
+			result.DomainPathStats, err = getDomainPathStats(db, config.DomainLimit, config.PathLimit, config.Filter)
+			if err != nil {
+				return fmt.Errorf("ドメイン・パス統計の取得に失敗: %w", err)
+			}
+			return nil
+		})
+	}
+
+	if config.ShowHourly && config.HourlySplit == HourlySplitWeekpart {
+		g.Go(func() error {
+			split, err := getHourlySplitStats(db, config.Filter, config.Holidays)
+			if err != nil {
+				return fmt.Errorf("時間帯統計の取得に失敗: %w", err)
+			}
+			result.HourlySplit = &split
+			return nil
+		})
+	} else if config.ShowHourly {
+		g.Go(func() error {
+			var err error
+			result.HourlyStats, err = getHourlyStats(db, config.Filter)
+			if err != nil {
+				return fmt.Errorf("時間帯統計の取得に失敗: %w", err)
+			}
+			return nil
+		})
+	}
+
+	if config.ShowDaily {
+		g.Go(func() error {
+			var err error
+			result.DailyStats, err = getDailyStats(db, config.Days, config.now(), config.Filter)
+			if err != nil {
+				return fmt.Errorf("日別統計の取得に失敗: %w", err)
+			}
+			config.Holidays.annotateDaily(result.DailyStats)
+			return nil
+		})
+	}
+
+	if config.ShowTitles {
+		g.Go(func() error {
+			var err error
+			result.TitleClusters, err = getTitleClusters(db, config.Limit, config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowVideos {
+		g.Go(func() error {
+			videos, err := getVideoReport(db, config.Limit, config.Filter)
+			if err != nil {
+				return err
+			}
+			result.Videos = &videos
+			return nil
+		})
+	}
+
+	if config.ShowGitHub {
+		g.Go(func() error {
+			github, err := getGitHubReport(db, config.Limit, config.Filter)
+			if err != nil {
+				return err
+			}
+			result.GitHub = &github
+			return nil
+		})
+	}
+
+	if config.ShowDocs {
+		g.Go(func() error {
+			docs, err := getDocsReport(db, config.Limit, config.Filter, config.DocSites)
+			if err != nil {
+				return err
+			}
+			result.Docs = &docs
+			return nil
+		})
+	}
+
+	if config.ShowLocal {
+		g.Go(func() error {
+			var err error
+			result.LocalHosts, err = getLocalHostStats(db, config.Limit, config.PathLimit, config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowProfiles {
+		g.Go(func() error {
+			var err error
+			result.HourlyProfiles, err = getHourlyProfiles(db, config.DomainLimit, config.Filter)
+			return err
+		})
+		if len(config.Categories) > 0 {
+			g.Go(func() error {
+				var err error
+				result.CategoryProfiles, err = getCategoryHourlyProfiles(db, config.Filter, config.Categories)
+				return err
+			})
 		}
-	case config.TSVOutput:
-		if err := writeCSV(output, result, config.ShowHistory, config.ShowDomains, config.ShowHourly, config.ShowDaily, '\t'); err != nil {
-			return fmt.Errorf("TSV出力エラー: %w", err)
+	}
+
+	if config.ShowLangs {
+		g.Go(func() error {
+			var err error
+			result.TitleLanguages, err = getTitleLanguageStats(db, config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowWeekly {
+		g.Go(func() error {
+			var err error
+			result.WeeklyStats, err = getPeriodStats(db, PeriodUnitWeek, DefaultWeeklyPeriods, config.Calendar, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowMonthly {
+		g.Go(func() error {
+			var err error
+			result.MonthlyStats, err = getPeriodStats(db, PeriodUnitMonth, DefaultMonthlyPeriods, config.Calendar, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowDistribution {
+		g.Go(func() error {
+			var err error
+			result.Distribution, err = getDailyDistribution(db, config.Days, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowForecast {
+		g.Go(func() error {
+			var err error
+			result.Forecast, err = getVisitForecast(db, config.DomainLimit, config.RedactDomains, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.ShowChangepoints {
+		g.Go(func() error {
+			var err error
+			result.Changepoints, err = getChangepoints(db, config.RedactDomains, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.JoinMetrics != nil {
+		g.Go(func() error {
+			var err error
+			result.Joined, err = getJoinReport(db, config.JoinMetrics, config.Categories, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.ScreenTime != nil {
+		g.Go(func() error {
+			var err error
+			result.ScreenTime, err = getScreenTimeReport(db, config.ScreenTime, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if config.GroupBy != "" {
+		dim, err := newGroupDimension(config.GroupBy, groupOptions{
+			sources:       sources,
+			categories:    config.Categories,
+			redactDomains: config.RedactDomains,
+			domainLimit:   config.DomainLimit,
+		})
+		if err != nil {
+			return AnalysisResult{}, err
 		}
-	default:
-		printTextOutput(result, config.ShowHistory, config.ShowDomains, config.ShowHourly, config.ShowDaily)
+		g.Go(func() error {
+			var err error
+			result.GroupStats, err = getGroupStats(db, dim, config.Days, config.now(), config.Filter)
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return AnalysisResult{}, err
+	}
+	if sources != nil {
+		sources.tagVisits(result.RecentVisits)
+	}
+	return result, nil
+}
+
+// openOutput は出力先を開く（パスが空なら標準出力）
+// 拡張子が .gz・.zst なら圧縮して書き出す。返り値の関数で出力先を閉じる
+func openOutput(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ファイル作成エラー: %w", err)
 	}
+	return compressOutput(f, path)
+}
+
+// prepareResult は不正な文字列を整え、リダクト対象を伏せ、匿名化モードの場合は出力前にハッシュ化する
+func prepareResult(result AnalysisResult, config Config) (AnalysisResult, error) {
+	result, err := sanitizeResult(result, config.Strict)
+	if err != nil {
+		return result, err
+	}
+	result = redactResult(result, config.RedactDomains)
+	return anonymizeResult(result, config.Anonymize), nil
+}
 
+// outputResult は結果を指定された形式で出力する
+// 拡張子つきの -output があれば、それぞれの形式で全てのファイルに書き出す
+func outputResult(result AnalysisResult, config Config) error {
+	result, err := prepareResult(result, config)
+	if err != nil {
+		return err
+	}
+	targets := config.Outputs
+	if len(targets) == 0 {
+		targets = []OutputTarget{{Path: config.OutputFile, Format: config.outputFormat()}}
+	}
+	if err := writeOutputTargets(targets, result, config); err != nil {
+		return err
+	}
+	// フックの失敗ではレポートの書き出しを失敗にしない
+	if err := runReportHooks(config.Hooks, targets, result, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
 	return nil
 }
 
 func main() {
+	// サブコマンド（hist sql など）
+	if handled, err := runSubcommand(); handled {
+		if code := exitCodeOf(err); code != ExitCodeError {
+			os.Exit(code)
+		}
+		exitWithError("エラー: %v\n", err)
+	}
+
 	config := parseFlags()
 
-	db, err := setupDatabase()
+	var db *sql.DB
+	var err error
+	if len(config.DBSources) > 0 {
+		db, err = openMergedDB(config.DBSources)
+	} else {
+		db, err = setupDatabase()
+	}
 	if err != nil {
 		exitWithError("エラー: %v\n", err)
 	}
 	defer func() { _ = db.Close() }()
+	if config.SharedConn {
+		useSharedConnection(db)
+	}
+
+	// 全文検索の索引があればキーワード検索に使う（開けなくても LIKE で検索できる）
+	// 索引は Safari の履歴DBから作るため、-db・HIST_DB・-browser で別のDBを読む場合は使わない
+	var index *SearchIndex
+	if os.Getenv(HistDBEnv) == "" && len(config.DBSources) == 0 {
+		index, err = openDefaultSearchIndex(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 全文検索の索引を使わずに検索します: %v\n", err)
+		}
+	}
+	if index != nil {
+		defer func() { _ = index.Close() }()
+		config.Filter.Index = index
+	}
+
+	// retention.txt に auto = true があれば保持期間を1日1回適用する（hist gc と同じ）
+	if config.appliesRetentionOnStartup() {
+		applyRetentionOnStartup(index)
+	}
 
 	// インタラクティブまたはWebモード
 	if config.Interactive || config.Serve {
@@ -1056,8 +2655,21 @@ func main() {
 		return
 	}
 
+	// 実行計画の表示
+	if config.Explain {
+		if err := runExplainMode(db, config, os.Stdout); err != nil {
+			exitWithError("エラー: %v\n", err)
+		}
+		return
+	}
+
 	// CLIモード
 	if err := runCLIMode(db, config); err != nil {
+		if errors.Is(err, errNoMatches) {
+			// 結果が0件の場合はメッセージを出さず終了コードだけで伝える
+			_ = db.Close()
+			os.Exit(ExitCodeNoMatch)
+		}
 		exitWithError("エラー: %v\n", err)
 	}
 }
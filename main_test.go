@@ -1,13 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // TestExtractDomain はURLからドメイン抽出のテスト
@@ -77,29 +77,74 @@ func TestConvertCoreDataTimestamp(t *testing.T) {
 	}
 }
 
+// setLocalZone はテストの間だけ time.Local を loc にする
+// 集計と時間帯付きルールはローカルタイムゾーンで行うため、時刻・日付を確かめるテストは実行環境の TZ によらないよう固定する
+func setLocalZone(t *testing.T, loc *time.Location) {
+	t.Helper()
+	orig := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = orig })
+}
+
+// TestParseLocalDate は -from などの日付がローカルタイムゾーンのその日の0時になるか（hist check・hist wayback も同じ）
+func TestParseLocalDate(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("タイムゾーンのデータがない: %v", err)
+	}
+	setLocalZone(t, tokyo)
+
+	got, err := parseLocalDate("2024-05-01")
+	if err != nil {
+		t.Fatalf("parseLocalDate: %v", err)
+	}
+	if want := time.Date(2024, 4, 30, 15, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("parseLocalDate(2024-05-01) = %v, want %v", got, want)
+	}
+	if _, err := parseLocalDate("2024/05/01"); err == nil {
+		t.Error("形式の誤りがエラーにならない")
+	}
+}
+
+// TestConvertCoreDataTimestampLocal は訪問時刻が時間帯・日付の集計に使うローカルタイムゾーンになるか
+func TestConvertCoreDataTimestampLocal(t *testing.T) {
+	setLocalZone(t, time.FixedZone("JST", 9*60*60))
+
+	// 2025-01-01 23:30 UTC は日本時間では 2025-01-02 08:30
+	got := convertCoreDataTimestamp(757382400 + 23*3600 + 30*60)
+	if got.Hour() != 8 || got.Format(TimeFormatDate) != "2025-01-02" {
+		t.Errorf("convertCoreDataTimestamp() = %v, want 2025-01-02 08:30 JST", got)
+	}
+}
+
+// testSchema はテスト用DBのスキーマ（Safari履歴DBの主要カラムのみ）
+const testSchema = `
+	CREATE TABLE history_items (
+		id INTEGER PRIMARY KEY,
+		url TEXT NOT NULL UNIQUE,
+		domain_expansion TEXT,
+		visit_count INTEGER DEFAULT 0
+	);
+	CREATE TABLE history_visits (
+		id INTEGER PRIMARY KEY,
+		history_item INTEGER,
+		visit_time REAL,
+		title TEXT,
+		FOREIGN KEY (history_item) REFERENCES history_items(id)
+	);
+`
+
 // setupTestDB はテスト用のインメモリDBを作成
 func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite3", ":memory:")
+	db, err := sql.Open(SQLiteDriver, ":memory:")
 	if err != nil {
 		t.Fatalf("テストDB作成に失敗: %v", err)
 	}
+	// :memory: のDBは接続ごとに別になるため、並行に集計しても同じ接続を使わせる
+	db.SetMaxOpenConns(1)
 
 	// テーブル作成
-	_, err = db.Exec(`
-		CREATE TABLE history_items (
-			id INTEGER PRIMARY KEY,
-			url TEXT NOT NULL UNIQUE,
-			domain_expansion TEXT,
-			visit_count INTEGER DEFAULT 0
-		);
-		CREATE TABLE history_visits (
-			id INTEGER PRIMARY KEY,
-			history_item INTEGER,
-			visit_time REAL,
-			title TEXT,
-			FOREIGN KEY (history_item) REFERENCES history_items(id)
-		);
-	`)
+	_, err = db.Exec(testSchema)
 	if err != nil {
 		t.Fatalf("テーブル作成に失敗: %v", err)
 	}
@@ -172,6 +217,10 @@ func TestGetRecentVisits(t *testing.T) {
 	if visits[0].Title != "YouTube - Music" {
 		t.Errorf("最新の訪問タイトルが期待と異なる: got %s", visits[0].Title)
 	}
+	// ID は history_visits.id
+	if visits[0].ID != 5 {
+		t.Errorf("最新の訪問IDが期待と異なる: got %d, want 5", visits[0].ID)
+	}
 }
 
 // TestGetRecentVisitsWithKeywordFilter はキーワード検索のテスト
@@ -208,6 +257,24 @@ func TestGetRecentVisitsWithDomainFilter(t *testing.T) {
 	}
 }
 
+// TestGetRecentVisitsWithMultipleDomains は複数ドメインフィルタのテスト
+func TestGetRecentVisitsWithMultipleDomains(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	filter := SearchFilter{Domains: []string{"github", "google.com"}}
+	visits, err := getRecentVisits(db, 10, filter)
+	if err != nil {
+		t.Fatalf("getRecentVisits失敗: %v", err)
+	}
+
+	// github: 2件、google.com: 1件
+	if len(visits) != 3 {
+		t.Errorf("ドメイン[github, google.com]で%d件、期待は3件", len(visits))
+	}
+}
+
 // TestGetDomainStats はドメイン別統計取得のテスト
 func TestGetDomainStats(t *testing.T) {
 	db := setupTestDB(t)
@@ -230,6 +297,30 @@ func TestGetDomainStats(t *testing.T) {
 	}
 }
 
+// TestGetDomainStatsShares はドメイン統計の割合・累積割合のテスト
+func TestGetDomainStatsShares(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 合計55訪問（youtube 25, google 15, github 10, example 5）
+	stats, err := getDomainStats(db, 2, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("getDomainStats() returned %d items, want 2", len(stats))
+	}
+
+	// limit で切っても割合は全ドメインの合計に対して計算される
+	if stats[0].Percentage != 45.45 || stats[0].CumulativePercentage != 45.45 {
+		t.Errorf("1位の割合が期待値と異なる: %+v", stats[0])
+	}
+	if stats[1].Percentage != 27.27 || stats[1].CumulativePercentage != 72.73 {
+		t.Errorf("2位の割合が期待値と異なる: %+v", stats[1])
+	}
+}
+
 // TestGetDomainStatsWithIgnoreList はイグノアリスト付きドメイン統計取得のテスト
 func TestGetDomainStatsWithIgnoreList(t *testing.T) {
 	db := setupTestDB(t)
@@ -258,6 +349,7 @@ func TestGetDomainStatsWithIgnoreList(t *testing.T) {
 
 // TestGetHourlyStats は時間帯別統計取得のテスト
 func TestGetHourlyStats(t *testing.T) {
+	setLocalZone(t, time.UTC)
 	db := setupTestDB(t)
 	defer func() { _ = db.Close() }()
 	insertTestData(t, db)
@@ -307,7 +399,7 @@ func TestGetDailyStats(t *testing.T) {
 	insertTestData(t, db)
 
 	// 過去30日間の統計
-	stats, err := getDailyStats(db, 30, SearchFilter{})
+	stats, err := getDailyStats(db, 30, time.Now(), SearchFilter{})
 	if err != nil {
 		t.Fatalf("getDailyStats失敗: %v", err)
 	}
@@ -317,6 +409,69 @@ func TestGetDailyStats(t *testing.T) {
 	t.Logf("getDailyStats returned %d days", len(stats))
 }
 
+// TestGetDailyStatsOfflineAcrossUTCMidnight は UTC の日付をまたぐオフラインの間を表示と同じタイムゾーンの日で数えるか
+func TestGetDailyStatsOfflineAcrossUTCMidnight(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	setLocalZone(t, jst)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Exec(`INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES (1, 'https://github.com/test', 'github', 4)`); err != nil {
+		t.Fatalf("history_items挿入に失敗: %v", err)
+	}
+	// 日本時間 2025-01-02 の 07:00・08:00・10:30・11:00（08:00-10:30 の間は UTC では 1/1 23:00 から 1/2 01:30）
+	for i, clock := range []string{"07:00", "08:00", "10:30", "11:00"} {
+		at, _ := time.ParseInLocation(TimeFormatDate+" 15:04", "2025-01-02 "+clock, jst)
+		if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time) VALUES (?, 1, ?)`, i+1, convertToTimestamp(at)); err != nil {
+			t.Fatalf("history_visits挿入に失敗: %v", err)
+		}
+	}
+
+	stats, err := getDailyStats(db, 7, time.Date(2025, 1, 3, 0, 0, 0, 0, jst), SearchFilter{})
+	if err != nil {
+		t.Fatalf("getDailyStats失敗: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Date != "2025-01-02" || stats[0].VisitCount != 4 {
+		t.Fatalf("stats = %+v, want 2025-01-02 の4件", stats)
+	}
+	if got := formatOfflineHours(stats[0], time.Local); got != "活動 1.5時間 / オフライン 2.5時間 (08:00-10:30)" {
+		t.Errorf("formatOfflineHours = %s", got)
+	}
+}
+
+// TestAnalyzeReplay は -now で過去の日を基準に集計できることを確認する
+func TestAnalyzeReplay(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// -now 2025-01-01 は、その日の終わりに実行したものとして翌日の訪問を除く
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := Config{
+		ShowDaily:  true,
+		ShowWeekly: true,
+		Days:       7,
+		Calendar:   defaultCalendar,
+		Filter:     SearchFilter{To: day},
+		Now:        day.Add(24*time.Hour - time.Second),
+	}
+	result, err := analyze(db, config)
+	if err != nil {
+		t.Fatalf("analyze() error = %v", err)
+	}
+	if len(result.DailyStats) != 1 || result.DailyStats[0].Date != "2025-01-01" || result.DailyStats[0].VisitCount != 3 {
+		t.Errorf("DailyStats = %+v, want 2025-01-01 の3件だけ", result.DailyStats)
+	}
+	// 2025-01-01 は水曜なので、月曜始まりの週は 2024-12-30 から
+	if w := result.WeeklyStats.Periods[0]; w.Start != "2024-12-30" || w.VisitCount != 3 {
+		t.Errorf("WeeklyStats[0] = %+v", w)
+	}
+	if result.Query.Now == nil || !result.Query.Now.Equal(config.Now) {
+		t.Errorf("Query.Now = %v, want %v", result.Query.Now, config.Now)
+	}
+}
+
 // TestAnalysisResultJSON はJSON出力フォーマットのテスト
 func TestAnalysisResultJSON(t *testing.T) {
 	result := AnalysisResult{
@@ -386,10 +541,52 @@ func TestAnalysisResultJSONOmitEmpty(t *testing.T) {
 	if contains(jsonStr, "domain_stats") {
 		t.Error("空のdomain_statsがJSONに含まれている")
 	}
+	if contains(jsonStr, "domain_path_stats") {
+		t.Error("空のdomain_path_statsがJSONに含まれている")
+	}
+}
+
+// TestWriteCSVWithPaths はドメイン・パス統計のCSV出力のテスト
+func TestWriteCSVWithPaths(t *testing.T) {
+	result := AnalysisResult{
+		DomainStats: []DomainStats{{Domain: "github.com", VisitCount: 12, Percentage: 60, CumulativePercentage: 60}},
+		DomainPathStats: []DomainPathStats{
+			{
+				Domain:     "github.com",
+				TotalCount: 12,
+				Paths: []PathStats{
+					{Path: "/nyasuto/hist", Title: "hist", VisitCount: 10},
+				},
+				OtherCount: 2,
+				HasPaths:   true,
+			},
+			{Domain: redactedLabel, TotalCount: 4},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, result, false, true, true, false, false, ','); err != nil {
+		t.Fatalf("CSV出力に失敗: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"domain,visit_count,percentage,cumulative_percentage",
+		"github.com,12,60.00,60.00",
+		"",
+		"domain,path,title,visit_count",
+		"github.com,/nyasuto/hist,hist,10",
+		"github.com,(other),,2",
+		"[redacted],(other),,4",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("CSV出力が期待値と異なる:\ngot:\n%s\nwant:\n%s", got, want)
+	}
 }
 
 // TestGetDBPath はDBパス取得のテスト
 func TestGetDBPath(t *testing.T) {
+	t.Setenv(HistDBEnv, "")
 	path, err := getDBPath()
 	if err != nil {
 		t.Fatalf("getDBPath失敗: %v", err)
@@ -401,6 +598,12 @@ func TestGetDBPath(t *testing.T) {
 	if path != expected {
 		t.Errorf("getDBPath() = %s, want %s", path, expected)
 	}
+
+	// HIST_DB で別の履歴DBを使える
+	t.Setenv(HistDBEnv, "/tmp/demo.db")
+	if path, _ := getDBPath(); path != "/tmp/demo.db" {
+		t.Errorf("HIST_DB 指定時の getDBPath() = %s", path)
+	}
 }
 
 // contains は文字列に部分文字列が含まれるかチェック
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// printMarkdownOutput は結果を Markdown（見出しと GitHub 形式の表）で出力する
+// 表の内容は -table と同じ
+func printMarkdownOutput(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool) {
+	_, _ = fmt.Fprintf(w, "# 📊 Safari 履歴分析結果\n\n総訪問数: %d\n\n", result.TotalVisits)
+	writeTableSections(result, showHistory, showDomains, showPaths, showHourly, showDaily, func(title string, headers []string, rows [][]string, rightAligned ...int) {
+		_, _ = fmt.Fprintf(w, "## %s\n\n", strings.TrimSpace(title))
+		_, _ = fmt.Fprintln(w, markdownRow(headers))
+		separators := make([]string, len(headers))
+		for i := range separators {
+			separators[i] = "---"
+		}
+		for _, c := range rightAligned {
+			if c < len(separators) {
+				separators[c] = "---:"
+			}
+		}
+		_, _ = fmt.Fprintln(w, "| "+strings.Join(separators, " | ")+" |")
+		for _, row := range rows {
+			_, _ = fmt.Fprintln(w, markdownRow(row))
+		}
+		_, _ = fmt.Fprintln(w)
+	})
+}
+
+// markdownRow は表の1行を返す（セル内の | と改行はエスケープする）
+func markdownRow(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		c = strings.ReplaceAll(c, `\`, `\\`)
+		c = strings.ReplaceAll(c, "|", `\|`)
+		escaped[i] = strings.Join(strings.Fields(c), " ")
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxMatchEntries は -match-stdin で受け付けるURL・ドメインの最大件数
+// SQLiteのプレースホルダ数の上限（32766）を超えないようにする
+const maxMatchEntries = 5000
+
+// MatchList は -match-stdin で渡された、分析対象に絞り込むURLとドメインの一覧
+type MatchList struct {
+	URLs    []string
+	Domains []string
+}
+
+// parseMatchList は1行に1つのURLまたはドメインを読み込む
+// "://" を含む行はURL（完全一致）、それ以外はドメインとして扱う
+// 空行と "#" で始まる行は無視する
+func parseMatchList(r io.Reader) (*MatchList, error) {
+	m := &MatchList{}
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	// リンク集には長いURLも含まれるため、1行の上限を広げる
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+
+		if strings.Contains(line, "://") {
+			m.URLs = append(m.URLs, line)
+		} else {
+			m.Domains = append(m.Domains, strings.ToLower(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("標準入力の読み込みに失敗: %w", err)
+	}
+
+	n := len(m.URLs) + len(m.Domains)
+	if n == 0 {
+		return nil, errors.New("標準入力にURL・ドメインがありません")
+	}
+	if n > maxMatchEntries {
+		return nil, fmt.Errorf("標準入力のURL・ドメインが多すぎます（%d件、最大%d件）", n, maxMatchEntries)
+	}
+	return m, nil
+}
+
+// urlVariants は末尾のスラッシュの有無を区別せずに照合するためのURLの候補を返す
+// 例: "https://example.com" → "https://example.com", "https://example.com/"
+func urlVariants(u string) []string {
+	if trimmed := strings.TrimSuffix(u, "/"); trimmed != u {
+		return []string{u, trimmed}
+	}
+	return []string{u, u + "/"}
+}
+
+// matchesURL はURLが一覧のURLまたはドメインに一致するかを返す
+// SQLを使わずGo側で集計する統計（ドメイン別など）で使う
+func (m *MatchList) matchesURL(u string) bool {
+	for _, candidate := range m.URLs {
+		for _, v := range urlVariants(candidate) {
+			if u == v {
+				return true
+			}
+		}
+	}
+	domain := strings.ToLower(extractDomain(u))
+	for _, d := range m.Domains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+// WithMatchList は一覧のURL・ドメインのいずれかに一致する条件を追加
+// ドメインの判定はWithDomainと同じ（domain_expansion、またはURLのホスト部分）
+func (qb *QueryBuilder) WithMatchList(m *MatchList) *QueryBuilder {
+	if m == nil {
+		return qb
+	}
+
+	var conds []string
+	if len(m.URLs) > 0 {
+		var urls []interface{}
+		for _, u := range m.URLs {
+			for _, v := range urlVariants(u) {
+				urls = append(urls, v)
+			}
+		}
+		conds = append(conds, `hi.url IN (`+inPlaceholders(len(urls))+`)`)
+		qb.args = append(qb.args, urls...)
+	}
+	for _, d := range m.Domains {
+		conds = append(conds, `hi.domain_expansion = ? OR hi.url LIKE ? OR hi.url LIKE ?`)
+		qb.args = append(qb.args, d, "%://"+d+"/%", "%://"+d)
+	}
+	if len(conds) == 0 {
+		// 空の一覧（スターを付けたページがないなど）には何も一致しない
+		qb.where.WriteString(` AND 0`)
+		return qb
+	}
+	qb.where.WriteString(` AND (` + strings.Join(conds, ` OR `) + `)`)
+	return qb
+}
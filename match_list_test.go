@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseMatchList は標準入力のURL・ドメイン一覧の読み込みのテスト
+func TestParseMatchList(t *testing.T) {
+	input := `
+# 読んだリンク
+https://github.com/test
+GitHub.com
+https://github.com/test
+
+youtube.com
+`
+	m, err := parseMatchList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseMatchList失敗: %v", err)
+	}
+	if len(m.URLs) != 1 || m.URLs[0] != "https://github.com/test" {
+		t.Errorf("URLが期待値と異なる: %v", m.URLs)
+	}
+	if len(m.Domains) != 2 || m.Domains[0] != "github.com" || m.Domains[1] != "youtube.com" {
+		t.Errorf("ドメインが期待値と異なる: %v", m.Domains)
+	}
+
+	if _, err := parseMatchList(strings.NewReader("\n# コメントのみ\n")); err == nil {
+		t.Error("空の入力でエラーにならない")
+	}
+}
+
+// TestMatchListMatchesURL はURL・ドメインの照合のテスト
+func TestMatchListMatchesURL(t *testing.T) {
+	m := &MatchList{URLs: []string{"https://example.com/page/"}, Domains: []string{"github.com"}}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/page/", true},
+		{"https://example.com/page", true},
+		{"https://example.com/other", false},
+		{"https://github.com/nyasuto/hist", true},
+		{"https://GitHub.com/", true},
+		{"https://gist.github.com/x", false},
+	}
+	for _, tt := range tests {
+		if got := m.matchesURL(tt.url); got != tt.want {
+			t.Errorf("matchesURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+// TestGetRecentVisitsWithMatchList は一覧による絞り込みのテスト
+func TestGetRecentVisitsWithMatchList(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	filter := SearchFilter{Match: &MatchList{
+		URLs:    []string{"https://google.com/search/"},
+		Domains: []string{"github.com"},
+	}}
+	visits, err := getRecentVisits(db, 10, filter)
+	if err != nil {
+		t.Fatalf("getRecentVisits失敗: %v", err)
+	}
+	// github.com の2件 + google.com/search の1件
+	if len(visits) != 3 {
+		t.Fatalf("getRecentVisits() returned %d items, want 3", len(visits))
+	}
+	for _, v := range visits {
+		if !filter.Match.matchesURL(v.URL) {
+			t.Errorf("一覧にないURLが含まれている: %s", v.URL)
+		}
+	}
+
+	stats, err := getDomainStats(db, 10, filter)
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Errorf("getDomainStats() returned %d items, want 2", len(stats))
+	}
+}
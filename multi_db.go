@@ -0,0 +1,351 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ブラウザ（-browser）の名前。Safari と Safari Technology Preview は同じ形の履歴DBを使う
+const (
+	BrowserSafari   = "safari"
+	BrowserSafariTP = "safari-tp"
+	BrowserAll      = "all"
+)
+
+// browserHistoryPaths はブラウザごとの履歴DBの相対パス（ホームディレクトリからの）
+var browserHistoryPaths = []struct {
+	Name string
+	Path string
+}{
+	{BrowserSafari, SafariHistoryPath},
+	{BrowserSafariTP, "Library/SafariTechnologyPreview/History.db"},
+}
+
+// DBSource はまとめて集計する履歴DBの1つ（Label は -group-by source のソース名）
+type DBSource struct {
+	Label string
+	Path  string
+}
+
+// dbPaths は繰り返し指定できる -db の値（"名前=パス" でソース名を付けられる）
+type dbPaths []string
+
+func (p *dbPaths) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *dbPaths) Set(value string) error {
+	if value == "" {
+		return errors.New("履歴DBのパスが空です")
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+// resolveDBSources は -db と -browser の指定からまとめて集計する履歴DBを返す
+// ソース名は "名前=パス" の名前か、なければファイル名（拡張子を除く）で、重複すれば -2・-3 を付ける
+func resolveDBSources(dbs []string, browser string) ([]DBSource, error) {
+	if len(dbs) > 0 && browser != "" {
+		return nil, errors.New("-db と -browser は同時に指定できません")
+	}
+	var sources []DBSource
+	if browser != "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
+		}
+		known := false
+		for _, b := range browserHistoryPaths {
+			if browser != BrowserAll && browser != b.Name {
+				continue
+			}
+			known = true
+			path := filepath.Join(homeDir, b.Path)
+			// all ではインストールされているブラウザだけを集計する
+			if _, err := os.Stat(path); err != nil && browser == BrowserAll {
+				continue
+			}
+			sources = append(sources, DBSource{Label: b.Name, Path: path})
+		}
+		if !known && browser != BrowserAll {
+			return nil, fmt.Errorf("不明なブラウザです: %s（%s・%s・%s）", browser, BrowserSafari, BrowserSafariTP, BrowserAll)
+		}
+		if len(sources) == 0 {
+			return nil, errors.New("履歴DBのあるブラウザが見つかりません")
+		}
+		return sources, nil
+	}
+
+	seen := make(map[string]int)
+	for _, value := range dbs {
+		label, path, ok := strings.Cut(value, "=")
+		if _, err := os.Stat(value); !ok || err == nil || label == "" {
+			// "=" を含むファイル名はそのままパスとして扱う
+			path = value
+			base := filepath.Base(path)
+			label = strings.TrimSuffix(strings.TrimSuffix(base, ".enc"), filepath.Ext(strings.TrimSuffix(base, ".enc")))
+		}
+		seen[label]++
+		if n := seen[label]; n > 1 {
+			label = fmt.Sprintf("%s-%d", label, n)
+		}
+		sources = append(sources, DBSource{Label: label, Path: path})
+	}
+	return sources, nil
+}
+
+// mergeBatchSize は openMergedDB がまとめたDBに1回のトランザクションで書き込む行数
+// 読み込んだ行はこの件数ずつ書き込み側に渡すため、メモリに置くのは数バッチ分だけになる（テストではバッチの境目を確かめるため小さくする）
+var mergeBatchSize = 5000
+
+// errMergeAborted はまとめたDBへの書き込みが失敗し、読み込みを途中でやめたことを示す
+var errMergeAborted = errors.New("まとめたDBへの書き込みが中断されました")
+
+// sourceItem は1つの履歴DBの URL（訪問のあるものだけ）
+type sourceItem struct {
+	URL        string
+	Domain     sql.NullString
+	VisitCount int64
+}
+
+// sourceVisit は1つの履歴DBの訪問（Device はソース名）
+type sourceVisit struct {
+	ID            int64
+	URL           string
+	VisitTime     float64
+	Title         sql.NullString
+	LoadSucceeded bool
+	Device        string
+}
+
+// mergeBatch は書き込み側に渡す URL か訪問のまとまり（どちらか一方）
+type mergeBatch struct {
+	items  []sourceItem
+	visits []sourceVisit
+}
+
+// streamSourceRows は1つの履歴DBの URL と訪問を mergeBatchSize 件ずつ send に渡す
+// 同じソースの URL は訪問より先に渡す。アーカイブDBの訪問は元のデバイス名、それ以外は source.Label をソース名にする
+func streamSourceRows(source DBSource, send func(mergeBatch) error) error {
+	db, err := openDB(source.Path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	loadSuccessful := "1"
+	if ok, err := hasColumn(db, "history_visits", "load_successful"); err != nil {
+		return err
+	} else if ok {
+		loadSuccessful = "hv.load_successful"
+	}
+	device, deviceJoin := "?", ""
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'archive_visits'`).Scan(&tables); err != nil {
+		return fmt.Errorf("ソースの確認に失敗: %w", err)
+	}
+	if tables > 0 {
+		device, deviceJoin = "COALESCE(av.device, ?)", "LEFT JOIN archive_visits av ON av.visit_id = hv.id"
+	}
+
+	rows, err := db.Query(`
+		SELECT hi.url, hi.domain_expansion, hi.visit_count FROM history_items hi
+		WHERE EXISTS (SELECT 1 FROM history_visits hv WHERE hv.history_item = hi.id)`)
+	if err != nil {
+		return fmt.Errorf("URLの取得に失敗: %w", err)
+	}
+	var items []sourceItem
+	for rows.Next() {
+		var item sourceItem
+		if err := rows.Scan(&item.URL, &item.Domain, &item.VisitCount); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if items = append(items, item); len(items) == mergeBatchSize {
+			if err := send(mergeBatch{items: items}); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			items = nil
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	if len(items) > 0 {
+		if err := send(mergeBatch{items: items}); err != nil {
+			return err
+		}
+	}
+
+	rows, err = db.Query(`
+		SELECT hv.id, hi.url, hv.visit_time, hv.title, `+loadSuccessful+`, `+device+`
+		FROM history_visits hv
+		JOIN history_items hi ON hv.history_item = hi.id
+		`+deviceJoin+`
+		ORDER BY hv.visit_time`, source.Label)
+	if err != nil {
+		return fmt.Errorf("訪問の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var visits []sourceVisit
+	for rows.Next() {
+		var v sourceVisit
+		if err := rows.Scan(&v.ID, &v.URL, &v.VisitTime, &v.Title, &v.LoadSucceeded, &v.Device); err != nil {
+			return fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if visits = append(visits, v); len(visits) == mergeBatchSize {
+			if err := send(mergeBatch{visits: visits}); err != nil {
+				return err
+			}
+			visits = nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	if len(visits) > 0 {
+		return send(mergeBatch{visits: visits})
+	}
+	return nil
+}
+
+// openMergedDB は複数の履歴DBを並行に読み込み、1つの読み取り専用のDBにまとめて開く
+// まとめたDBはアーカイブDBと同じ形で、訪問の元のソースを archive_visits に記録するため、
+// どの統計もそのまま集計でき、-group-by source でソースごとに分けられる
+// 各ソースの行は mergeBatchSize 件ずつ書き込み側に流すため、履歴全体をメモリに読み込まない
+// 同じ訪問を含むDB（アーカイブDBとこのマシンの Safari など）を並べると二重に数える
+func openMergedDB(sources []DBSource) (*sql.DB, error) {
+	tmp, err := os.CreateTemp("", "hist-merged-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	// SQLite の書き込みは1本にまとめ、読み込みだけを並行にする
+	batches := make(chan mergeBatch, StatsParallelism)
+	aborted := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeMergedDB(tmp.Name(), batches)
+		if err != nil {
+			close(aborted)
+		}
+		writeErr <- err
+	}()
+	send := func(b mergeBatch) error {
+		select {
+		case batches <- b:
+			return nil
+		case <-aborted:
+			return errMergeAborted
+		}
+	}
+
+	g := newTaskGroup(StatsParallelism)
+	for _, source := range sources {
+		g.Go(func() error {
+			if err := streamSourceRows(source, send); err != nil {
+				return fmt.Errorf("%s: %w", source.Path, err)
+			}
+			return nil
+		})
+	}
+	readErr := g.Wait()
+	close(batches)
+	if err := <-writeErr; err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	// まとめた一時ファイルは hist 以外が書き換えないため immutable で開く（openEncryptedDB と同じ）
+	db, err := sql.Open(SQLiteReadOnlyDriver, readOnlyDSN(tmp.Name(), true))
+	if err != nil {
+		return nil, fmt.Errorf("データベースを開けませんでした: %w", err)
+	}
+	// 一時ファイルを削除した後も読めるよう、同じ接続を使い続ける
+	useSharedConnection(db)
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("データベースを開けませんでした: %w", err)
+	}
+	return db, nil
+}
+
+// writeMergedDB は batches の URL と訪問を path のDBに書き込む（バッチごとに1つのトランザクション）
+// 同じURLは1つの history_items にまとめ、visit_count はソースごとの値を足す
+func writeMergedDB(path string, batches <-chan mergeBatch) error {
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return fmt.Errorf("データベースを開けませんでした: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+	// last_insert_rowid() を同じ接続で読むため、1本の接続だけを使う
+	useSharedConnection(db)
+	if _, err := db.Exec(archiveSchema); err != nil {
+		return fmt.Errorf("スキーマの作成に失敗: %w", err)
+	}
+	for b := range batches {
+		if err := writeMergeBatch(db, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMergeBatch は1つのバッチを1つのトランザクションで書き込む
+func writeMergeBatch(db *sql.DB, b mergeBatch) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if len(b.items) > 0 {
+		stmt, err := tx.Prepare(`INSERT INTO history_items (url, domain_expansion, visit_count) VALUES (?, ?, ?)
+			ON CONFLICT(url) DO UPDATE SET visit_count = visit_count + excluded.visit_count`)
+		if err != nil {
+			return fmt.Errorf("URLの書き込みに失敗: %w", err)
+		}
+		defer func() { _ = stmt.Close() }()
+		for _, item := range b.items {
+			if _, err := stmt.Exec(item.URL, item.Domain, item.VisitCount); err != nil {
+				return fmt.Errorf("URLの書き込みに失敗: %w", err)
+			}
+		}
+	}
+	if len(b.visits) > 0 {
+		visitStmt, err := tx.Prepare(`INSERT INTO history_visits (history_item, visit_time, title, load_successful)
+			SELECT id, ?, ?, ? FROM history_items WHERE url = ?`)
+		if err != nil {
+			return fmt.Errorf("訪問の書き込みに失敗: %w", err)
+		}
+		defer func() { _ = visitStmt.Close() }()
+		sourceStmt, err := tx.Prepare(`INSERT INTO archive_visits (visit_id, device, source_id) VALUES (last_insert_rowid(), ?, ?)
+			ON CONFLICT(device, source_id) DO NOTHING`)
+		if err != nil {
+			return fmt.Errorf("訪問の書き込みに失敗: %w", err)
+		}
+		defer func() { _ = sourceStmt.Close() }()
+		for _, v := range b.visits {
+			if _, err := visitStmt.Exec(v.VisitTime, v.Title, v.LoadSucceeded, v.URL); err != nil {
+				return fmt.Errorf("訪問の書き込みに失敗: %w", err)
+			}
+			if _, err := sourceStmt.Exec(v.Device, v.ID); err != nil {
+				return fmt.Errorf("訪問の書き込みに失敗: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("書き込みの確定に失敗: %w", err)
+	}
+	return nil
+}
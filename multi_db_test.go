@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestHistoryDB は testSchema の履歴DBを path に作り、insertTestData の訪問を書き込む
+func writeTestHistoryDB(t *testing.T, path string) {
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatalf("DB作成に失敗: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(testSchema); err != nil {
+		t.Fatalf("テーブル作成に失敗: %v", err)
+	}
+	insertTestData(t, db)
+}
+
+func TestResolveDBSources(t *testing.T) {
+	sources, err := resolveDBSources([]string{"work=/backup/History.db", "/backup/History.db", "/a/History.db", "/b/archive.db.enc"}, "")
+	if err != nil {
+		t.Fatalf("resolveDBSources: %v", err)
+	}
+	if got := fmt.Sprint(sources); got != "[{work /backup/History.db} {History /backup/History.db} {History-2 /a/History.db} {archive /b/archive.db.enc}]" {
+		t.Errorf("sources = %s", got)
+	}
+
+	// "=" を含む既存のファイルはそのままパスとして扱う
+	path := filepath.Join(t.TempDir(), "a=b.db")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if sources, err := resolveDBSources([]string{path}, ""); err != nil || sources[0].Path != path || sources[0].Label != "a=b" {
+		t.Errorf("sources = %v, %v", sources, err)
+	}
+
+	if _, err := resolveDBSources([]string{"x.db"}, BrowserAll); err == nil {
+		t.Error("-db と -browser を同時に指定してもエラーにならない")
+	}
+	if _, err := resolveDBSources(nil, "chrome"); err == nil {
+		t.Error("不明なブラウザがエラーにならない")
+	}
+	t.Setenv("HOME", t.TempDir())
+	if _, err := resolveDBSources(nil, BrowserAll); err == nil {
+		t.Error("履歴DBがなくてもエラーにならない")
+	}
+}
+
+func TestOpenMergedDB(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work.db")
+	home := filepath.Join(dir, "home.db")
+	writeTestHistoryDB(t, work)
+	writeTestHistoryDB(t, home)
+
+	// URL・訪問が複数のバッチ・トランザクションに分かれても同じ結果になる
+	defer func(size int) { mergeBatchSize = size }(mergeBatchSize)
+	mergeBatchSize = 2
+
+	db, err := openMergedDB([]DBSource{{Label: "work", Path: work}, {Label: "home", Path: home}})
+	if err != nil {
+		t.Fatalf("openMergedDB: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if count, err := getTotalVisits(db); err != nil || count != 10 {
+		t.Errorf("getTotalVisits = %d, %v, want 10", count, err)
+	}
+	// 同じURLは1つにまとめ、visit_count を足す（訪問のないURLは含めない）
+	var items, youtube int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(CASE WHEN url = 'https://youtube.com/watch' THEN visit_count END) FROM history_items`).Scan(&items, &youtube); err != nil {
+		t.Fatal(err)
+	}
+	if items != 3 || youtube != 50 {
+		t.Errorf("history_items = %d, youtube の visit_count = %d", items, youtube)
+	}
+
+	// 訪問のソースはそれぞれの履歴DBのソース名
+	sources, err := loadVisitSources(db, SourceSafari)
+	if err != nil {
+		t.Fatalf("loadVisitSources: %v", err)
+	}
+	counts := make(map[string]int)
+	for id := int64(1); id <= 10; id++ {
+		counts[sources.sourceOf(id)]++
+	}
+	if fmt.Sprint(counts) != "map[home:5 work:5]" {
+		t.Errorf("ソースごとの訪問数 = %v", counts)
+	}
+
+	// まとめたDBも読み取り専用
+	if _, err := db.Exec(`DELETE FROM history_visits`); err == nil {
+		t.Error("まとめたDBに書き込めてしまう")
+	}
+
+	if _, err := openMergedDB([]DBSource{{Label: "work", Path: work}, {Label: "missing", Path: filepath.Join(dir, "missing.db")}}); err == nil {
+		t.Error("存在しない履歴DBがエラーにならない")
+	}
+}
+
+func TestOpenMergedDBArchiveDevices(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work.db")
+	writeTestHistoryDB(t, work)
+
+	// アーカイブDBの訪問は archive_visits のデバイス名、記録のない訪問はソース名にする
+	archivePath := filepath.Join(dir, "archive.db")
+	archive, err := sql.Open(SQLiteDriver, archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Exec(archiveSchema + `
+		INSERT INTO history_items (id, url, visit_count) VALUES (1, 'https://github.com/', 2);
+		INSERT INTO history_visits (id, history_item, visit_time) VALUES (1, 1, 100), (2, 1, 200);
+		INSERT INTO archive_visits (visit_id, device, source_id) VALUES (1, 'laptop', 42);
+	`); err != nil {
+		t.Fatalf("アーカイブDBの作成に失敗: %v", err)
+	}
+	_ = archive.Close()
+
+	db, err := openMergedDB([]DBSource{{Label: "work", Path: work}, {Label: "archive", Path: archivePath}})
+	if err != nil {
+		t.Fatalf("openMergedDB: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(`SELECT device, COUNT(*) FROM archive_visits GROUP BY device ORDER BY device`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rows.Close() }()
+	var got []string
+	for rows.Next() {
+		var device string
+		var count int
+		if err := rows.Scan(&device, &count); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d", device, count))
+	}
+	if fmt.Sprint(got) != "[archive:1 laptop:1 work:5]" {
+		t.Errorf("デバイスごとの訪問数 = %v", got)
+	}
+}
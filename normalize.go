@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 検索で使う文字列の正規化
+// キーワードと履歴のURL・タイトルの両方を同じ規則で変換してから比べるため、
+// 半角カナ（ｶﾌｪ）と全角カナ（カフェ）、アクセントの有無（café と Cafe）、大文字・小文字の違いを区別しない
+
+// isLatinCombiningMark はラテン文字などのアクセント記号（結合文字）か
+// 濁点・半濁点（U+3099・U+309A）は含めない（ガとカを区別するため）
+func isLatinCombiningMark(r rune) bool {
+	return r >= 0x0300 && r <= 0x036F
+}
+
+// foldSearchText は全角・半角の違いとアクセントの有無をならす（大文字・小文字は保つ）
+// NFKD で分解してアクセント記号を除き、NFC で組み立て直す（互換文字は NFKC と同じ形になる）
+func foldSearchText(s string) string {
+	if isPlainASCII(s) {
+		return s
+	}
+	decomposed := norm.NFKD.String(s)
+	stripped := strings.Map(func(r rune) rune {
+		if isLatinCombiningMark(r) {
+			return -1
+		}
+		return r
+	}, decomposed)
+	return norm.NFC.String(stripped)
+}
+
+// normalizeSearchText は検索で比べる形に正規化する
+// foldSearchText に加えて大文字・小文字を区別せず、foldKana ならカタカナをひらがなにそろえる
+func normalizeSearchText(s string, foldKana bool) string {
+	if isPlainASCII(s) {
+		return strings.ToLower(s)
+	}
+	// cases.Caser は goroutine 間で共有できないため、呼び出しごとに作る（SQL関数・並行集計から呼ばれる）
+	s = cases.Fold().String(foldSearchText(s))
+	if foldKana {
+		s = strings.Map(katakanaToHiragana, s)
+	}
+	return s
+}
+
+// katakanaToHiragana はカタカナ（ァ〜ヶ）を対応するひらがなにする
+func katakanaToHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - ('ァ' - 'ぁ')
+	}
+	return r
+}
+
+// isPlainASCII は文字列がASCIIだけか（正規化しても変わらないので変換を省く）
+func isPlainASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
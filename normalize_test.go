@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizeSearchText(t *testing.T) {
+	tests := []struct {
+		in       string
+		foldKana bool
+		want     string
+	}{
+		{"GitHub", false, "github"},
+		{"ｶﾌｪ", false, "カフェ"},
+		{"ｶﾞｲﾄﾞ", false, "ガイド"},
+		{"Café", false, "cafe"},
+		{"ＧＩＴＨＵＢ　１２３", false, "github 123"},
+		{"Straße", false, "strasse"},
+		// 濁点は残す
+		{"ガイド", false, "ガイド"},
+		{"カフェ", true, "かふぇ"},
+		{"ｶﾌｪ", true, "かふぇ"},
+		{"ヴ", true, "ゔ"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSearchText(tt.in, tt.foldKana); got != tt.want {
+			t.Errorf("normalizeSearchText(%q, %v) = %q, want %q", tt.in, tt.foldKana, got, tt.want)
+		}
+	}
+}
+
+func TestFoldSearchText(t *testing.T) {
+	// 大文字・小文字は保つ（あいまい検索のスマートケースのため）
+	if got := foldSearchText("Ｃafé"); got != "Cafe" {
+		t.Errorf("foldSearchText() = %q, want %q", got, "Cafe")
+	}
+}
+
+func TestKeywordSearchNormalization(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'https://example.jp/cafe', NULL, 1),
+		(6, 'https://example.fr/menu', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757418400 + 100, 'カフェ巡り'),
+		(7, 6, 757418400 + 200, 'Café de Flore'),
+		(8, 6, 757418400 + 300, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	tests := []struct {
+		keyword  string
+		foldKana bool
+		want     int
+	}{
+		{"ｶﾌｪ", false, 1},
+		{"Cafe de", false, 1},
+		{"CAFÉ", false, 2}, // URL の cafe とタイトルの Café
+		{"かふぇ", false, 0},
+		{"かふぇ", true, 1},
+		{"ＧＩＴＨＵＢ", false, 2},
+	}
+	for _, tt := range tests {
+		visits, err := getRecentVisits(db, 100, SearchFilter{Keyword: tt.keyword, FoldKana: tt.foldKana})
+		if err != nil {
+			t.Fatalf("getRecentVisits(%q) error = %v", tt.keyword, err)
+		}
+		if len(visits) != tt.want {
+			t.Errorf("キーワード %q (foldKana=%v): %d件, want %d", tt.keyword, tt.foldKana, len(visits), tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OfflineGap はこれ以上訪問のない時間を、その日のオフライン（通勤・外出・会議など）とみなす長さ
+const OfflineGap = 2 * time.Hour
+
+// offlineCommonMinDays はオフラインになりやすい時間帯を求めるのに必要な訪問のある日数
+const offlineCommonMinDays = 3
+
+// OfflinePeriod は同じ日の訪問と訪問の間で OfflineGap 以上訪問のなかった時間
+type OfflinePeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// hours はオフラインの時間の長さ（時間）
+func (p OfflinePeriod) hours() float64 {
+	return p.End.Sub(p.Start).Hours()
+}
+
+// annotateOfflineHours は日別統計に、その日の最初と最後の訪問の間のオフラインの時間と、それを除いた活動時間を付ける
+// dayTimes は日付ごとの訪問時刻（順不同）。最初の訪問より前と最後の訪問より後（睡眠など）は数えない
+func annotateOfflineHours(stats []DailyStats, dayTimes map[string][]time.Time) {
+	for i := range stats {
+		times := dayTimes[stats[i].Date]
+		if len(times) == 0 {
+			continue
+		}
+		sort.Slice(times, func(a, b int) bool { return times[a].Before(times[b]) })
+		span := times[len(times)-1].Sub(times[0]).Hours()
+		for j := 1; j < len(times); j++ {
+			if times[j].Sub(times[j-1]) >= OfflineGap {
+				p := OfflinePeriod{Start: times[j-1], End: times[j]}
+				stats[i].Offline = append(stats[i].Offline, p)
+				stats[i].OfflineHours += p.hours()
+			}
+		}
+		stats[i].ActiveHours = span - stats[i].OfflineHours
+	}
+}
+
+// commonOfflineHours は訪問のある日の半分以上でオフラインだった時間帯（loc の時計の0〜23時）を返す
+// 毎日同じ時間帯に訪問が途切れるなら通勤や定例の外出とみなせる。訪問のある日が少なければ nil
+func commonOfflineHours(stats []DailyStats, loc *time.Location) []int {
+	var counts [24]int
+	days := 0
+	for _, s := range stats {
+		if s.VisitCount == 0 {
+			continue
+		}
+		days++
+		var covered [24]bool
+		for _, p := range s.Offline {
+			// 時間帯の半分以上がオフラインなら、その時間帯をオフラインとして数える
+			start := p.Start.In(loc)
+			for t := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, loc); t.Before(p.End); t = t.Add(time.Hour) {
+				overlap := time.Duration(min(t.Add(time.Hour).UnixNano(), p.End.UnixNano()) - max(t.UnixNano(), p.Start.UnixNano()))
+				if overlap >= 30*time.Minute {
+					covered[t.Hour()] = true
+				}
+			}
+		}
+		for h, c := range covered {
+			if c {
+				counts[h]++
+			}
+		}
+	}
+	if days < offlineCommonMinDays {
+		return nil
+	}
+	var hours []int
+	for h, c := range counts {
+		if c*2 >= days {
+			hours = append(hours, h)
+		}
+	}
+	return hours
+}
+
+// formatHourRanges は連続する時間帯をまとめて「8〜10時・18〜19時」のように表示する
+func formatHourRanges(hours []int) string {
+	var ranges []string
+	for i := 0; i < len(hours); {
+		j := i
+		for j+1 < len(hours) && hours[j+1] == hours[j]+1 {
+			j++
+		}
+		ranges = append(ranges, fmt.Sprintf("%d〜%d時", hours[i], hours[j]+1))
+		i = j + 1
+	}
+	return strings.Join(ranges, "・")
+}
+
+// formatOfflinePeriods はオフラインの時間を loc の時計で「12:00-14:30」のように並べる
+func formatOfflinePeriods(periods []OfflinePeriod, loc *time.Location) string {
+	cells := make([]string, len(periods))
+	for i, p := range periods {
+		cells[i] = p.Start.In(loc).Format("15:04") + "-" + p.End.In(loc).Format("15:04")
+	}
+	return strings.Join(cells, ", ")
+}
+
+// formatOfflineHours は日別統計の活動時間とオフラインの時間の表示（訪問が1件以下なら空）
+func formatOfflineHours(s DailyStats, loc *time.Location) string {
+	if s.VisitCount < 2 {
+		return ""
+	}
+	if len(s.Offline) == 0 {
+		return fmt.Sprintf("活動 %.1f時間", s.ActiveHours)
+	}
+	return fmt.Sprintf("活動 %.1f時間 / オフライン %.1f時間 (%s)", s.ActiveHours, s.OfflineHours, formatOfflinePeriods(s.Offline, loc))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// offlineDay は date の訪問時刻（UTC の「時:分」）の日別統計と訪問時刻を作る
+func offlineDay(date string, clocks ...string) (DailyStats, []time.Time) {
+	var times []time.Time
+	for _, c := range clocks {
+		t, _ := time.Parse(TimeFormatDate+" 15:04", date+" "+c)
+		times = append(times, t)
+	}
+	return DailyStats{Date: date, VisitCount: len(times)}, times
+}
+
+func TestAnnotateOfflineHours(t *testing.T) {
+	dayTimes := make(map[string][]time.Time)
+	var stats []DailyStats
+	for _, clocks := range [][]string{
+		// 訪問時刻は順不同。2時間以上空いた間がオフライン
+		{"19:00", "07:00", "10:00", "11:00", "12:00", "20:00"},
+		{"07:30", "10:00", "12:00", "13:00", "18:00"},
+		{"08:00", "09:00", "10:00", "11:00"},
+		// 1件だけの日は活動時間もオフラインもなし
+		{"12:00"},
+	} {
+		date := fmt.Sprintf("2025-01-%02d", len(stats)+6)
+		s, times := offlineDay(date, clocks...)
+		stats = append(stats, s)
+		dayTimes[date] = times
+	}
+	annotateOfflineHours(stats, dayTimes)
+
+	for i, want := range []string{
+		"3.0 10.0 07:00-10:00, 12:00-19:00",
+		"1.0 9.5 07:30-10:00, 10:00-12:00, 13:00-18:00",
+		"3.0 0.0 ",
+		"0.0 0.0 ",
+	} {
+		s := stats[i]
+		if got := fmt.Sprintf("%.1f %.1f %s", s.ActiveHours, s.OfflineHours, formatOfflinePeriods(s.Offline, time.UTC)); got != want {
+			t.Errorf("%s: %s, want %s", s.Date, got, want)
+		}
+	}
+	if got := formatOfflineHours(stats[0], time.UTC); got != "活動 3.0時間 / オフライン 10.0時間 (07:00-10:00, 12:00-19:00)" {
+		t.Errorf("formatOfflineHours = %s", got)
+	}
+	if got := formatOfflineHours(stats[2], time.UTC); got != "活動 3.0時間" {
+		t.Errorf("オフラインのない日の formatOfflineHours = %s", got)
+	}
+	if got := formatOfflineHours(stats[3], time.UTC); got != "" {
+		t.Errorf("1件の日の formatOfflineHours = %q", got)
+	}
+
+	// 訪問のある日の半分以上でオフラインだった時間帯（30分以上重なれば数える）
+	if got := formatHourRanges(commonOfflineHours(stats, time.UTC)); got != "7〜10時・13〜18時" {
+		t.Errorf("commonOfflineHours = %s", got)
+	}
+	if hours := commonOfflineHours(stats[:2], time.UTC); hours != nil {
+		t.Errorf("2日分の commonOfflineHours = %v, want nil", hours)
+	}
+	// 時計の時間帯は loc で数える
+	if got := formatHourRanges(commonOfflineHours(stats, time.FixedZone("JST", 9*60*60))); got != "0〜3時・16〜19時・22〜24時" {
+		t.Errorf("JST の commonOfflineHours = %s", got)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 分析結果の出力形式
+const (
+	OutputFormatText     = "text"
+	OutputFormatJSON     = "json"
+	OutputFormatCSV      = "csv"
+	OutputFormatTSV      = "tsv"
+	OutputFormatMarkdown = "markdown"
+	OutputFormatTable    = "table"
+	OutputFormatQuiet    = "quiet"
+)
+
+// outputFormatExtensions は -output の拡張子と出力形式の対応
+var outputFormatExtensions = map[string]string{
+	".txt":      OutputFormatText,
+	".json":     OutputFormatJSON,
+	".csv":      OutputFormatCSV,
+	".tsv":      OutputFormatTSV,
+	".md":       OutputFormatMarkdown,
+	".markdown": OutputFormatMarkdown,
+	".html":     OutputFormatHTML,
+	".htm":      OutputFormatHTML,
+}
+
+// outputPathTemplates は -output のパスに書ける日付の置き換え（実行時のローカル時刻）
+var outputPathTemplates = map[string]string{
+	"date":  TimeFormatDate,
+	"month": "2006-01",
+	"year":  "2006",
+	"time":  "150405",
+}
+
+// outputPathTemplatePattern は -output のパスの {{名前}}
+var outputPathTemplatePattern = regexp.MustCompile(`\{\{\s*([a-z]*)\s*\}\}`)
+
+// expandOutputPath は -output のパスの {{date}} などを now の日付に置き換える（cron で日ごとのファイルに書き出す用）
+func expandOutputPath(path string, now time.Time) (string, error) {
+	var unknown string
+	expanded := outputPathTemplatePattern.ReplaceAllStringFunc(path, func(m string) string {
+		name := outputPathTemplatePattern.FindStringSubmatch(m)[1]
+		layout, ok := outputPathTemplates[name]
+		if !ok {
+			unknown = m
+			return m
+		}
+		return now.Format(layout)
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("-output に使えるのは {{date}}・{{month}}・{{year}}・{{time}} です: %s", unknown)
+	}
+	return expanded, nil
+}
+
+// canAppend は -append で追記できる出力形式か（NDJSON は形式ではなく -ndjson の逐次出力で追記する）
+func canAppend(format string) bool {
+	return format == OutputFormatCSV || format == OutputFormatTSV
+}
+
+// errAppendFormat は -append で追記できない形式のファイルを指定した場合のエラー
+var errAppendFormat = errors.New("-append で追記できるのは CSV・TSV・NDJSON のファイルだけです（上書きしないよう中止しました）")
+
+// checkAppendTargets は追記できない形式の出力先があればエラーを返す（黙って上書きしないよう書き出す前に確かめる）
+func checkAppendTargets(targets []OutputTarget) error {
+	for _, t := range targets {
+		if !canAppend(t.Format) {
+			return fmt.Errorf("%s: %w", t.Path, errAppendFormat)
+		}
+	}
+	return nil
+}
+
+// openAppendOutput は追記用に出力先を開く（なければ作成する）
+// existing はファイルに既に内容があるか（CSV の見出し行を書くかの判定に使う）
+func openAppendOutput(path string) (f *os.File, existing bool, err error) {
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, false, fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	return f, info.Size() > 0, nil
+}
+
+// openAppendWriter は openOutput の追記版（パスが空なら標準出力）
+func openAppendWriter(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, _, err := openAppendOutput(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return compressOutput(f, path)
+}
+
+// OutputTarget は出力先のファイルと、拡張子から判定した出力形式
+type OutputTarget struct {
+	Path   string
+	Format string
+}
+
+// outputPaths は繰り返し指定できる -output の値
+type outputPaths []string
+
+func (p *outputPaths) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *outputPaths) Set(value string) error {
+	if value == "" {
+		return errors.New("出力ファイルパスが空です")
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+// outputFormatFromPath は拡張子から出力形式を判定する（report.csv.gz など圧縮の拡張子は除いて判定する）
+func outputFormatFromPath(path string) (string, bool) {
+	_, base := splitCompressionExt(path)
+	format, ok := outputFormatExtensions[strings.ToLower(filepath.Ext(base))]
+	return format, ok
+}
+
+// isNDJSONPath は拡張子が .ndjson・.jsonl（圧縮の拡張子を除いて判定）か
+func isNDJSONPath(path string) bool {
+	_, base := splitCompressionExt(path)
+	ext := strings.ToLower(filepath.Ext(base))
+	return ext == ".ndjson" || ext == ".jsonl"
+}
+
+// resolveOutputTargets は -output の値を拡張子から判定した出力形式つきの出力先にする
+// 複数指定した場合は全てのファイルの拡張子が必要。1つだけで形式のフラグ（-json など）がある場合や、
+// 拡張子から判定できない場合は nil を返し、従来どおり形式のフラグに従って書き出す
+func resolveOutputTargets(paths []string, formatFlagged bool) ([]OutputTarget, error) {
+	if len(paths) == 1 {
+		format, ok := outputFormatFromPath(paths[0])
+		if formatFlagged || !ok {
+			return nil, nil
+		}
+		return []OutputTarget{{Path: paths[0], Format: format}}, nil
+	}
+
+	var targets []OutputTarget
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		format, ok := outputFormatFromPath(path)
+		if !ok {
+			return nil, fmt.Errorf("拡張子から出力形式を判定できません（.json・.csv・.tsv・.md・.html・.txt、圧縮する場合は続けて .gz・.zst）: %s", path)
+		}
+		if seen[filepath.Clean(path)] {
+			return nil, fmt.Errorf("同じファイルが複数の -output に指定されています: %s", path)
+		}
+		seen[filepath.Clean(path)] = true
+		targets = append(targets, OutputTarget{Path: path, Format: format})
+	}
+	return targets, nil
+}
+
+// outputFormat は形式のフラグ（-format・-json・-csv など）から出力形式を返す
+func (c Config) outputFormat() string {
+	switch {
+	case c.Format != "":
+		return c.Format
+	case c.JSONOutput:
+		return OutputFormatJSON
+	case c.CSVOutput:
+		return OutputFormatCSV
+	case c.TSVOutput:
+		return OutputFormatTSV
+	case c.Quiet:
+		return OutputFormatQuiet
+	case c.TableOutput:
+		return OutputFormatTable
+	default:
+		return OutputFormatText
+	}
+}
+
+// writeResult は結果を format の形式で w に書き出す（形式は outputFormatters から選び、不明なら text）
+// header は CSV・TSV の見出し行の書き方（-append で追記する場合に見出し行を省く）
+func writeResult(w io.Writer, format string, result AnalysisResult, config Config, header csvHeaderMode) error {
+	formatter, ok := outputFormatters[format]
+	if !ok {
+		formatter = outputFormatters[OutputFormatText]
+	}
+	return formatter.WriteResult(w, result, config, header)
+}
+
+// writeOutputTargets は1回の分析結果を全ての出力先にそれぞれの形式で書き出す
+// config.Append なら CSV・TSV のファイルに追記する（追記できない形式があればどのファイルも書かずにエラーにする）
+func writeOutputTargets(targets []OutputTarget, result AnalysisResult, config Config) error {
+	if config.Append {
+		if err := checkAppendTargets(targets); err != nil {
+			return err
+		}
+	}
+	for _, t := range targets {
+		var err error
+		if config.Append && t.Path != "" && canAppend(t.Format) {
+			err = appendOutput(t, result, config)
+		} else {
+			var output io.Writer
+			var closeOutput func() error
+			output, closeOutput, err = openOutput(t.Path)
+			if err != nil {
+				return err
+			}
+			err = writeResult(output, t.Format, result, config, csvHeaderEachSection)
+			if cerr := closeOutput(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			if t.Path == "" {
+				return err
+			}
+			return fmt.Errorf("%s: %w", t.Path, err)
+		}
+	}
+	return nil
+}
+
+// appendOutput は CSV・TSV のファイルに追記する（空のファイルにだけ見出し行を書く）
+// 途中で失敗しても既存のファイルを壊さないよう、書き出す内容を揃えてから追記する
+func appendOutput(t OutputTarget, result AnalysisResult, config Config) error {
+	f, existing, err := openAppendOutput(t.Path)
+	if err != nil {
+		return err
+	}
+
+	header := csvHeaderSingleSection
+	if existing {
+		header = csvHeaderNone
+	}
+	var buf bytes.Buffer
+	if err := writeResult(&buf, t.Format, result, config, header); err != nil {
+		_ = f.Close()
+		return err
+	}
+	w, closeOutput, err := compressOutput(f, t.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		_ = closeOutput()
+		return fmt.Errorf("ファイル書き込みエラー: %w", err)
+	}
+	return closeOutput()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveOutputTargets(t *testing.T) {
+	tests := []struct {
+		name          string
+		paths         []string
+		formatFlagged bool
+		want          []OutputTarget
+		wantErr       bool
+	}{
+		{"指定なし", nil, false, nil, false},
+		{"1つで拡張子から判定", []string{"report.JSON"}, false, []OutputTarget{{"report.JSON", OutputFormatJSON}}, false},
+		{"1つで形式のフラグあり", []string{"report.json"}, true, nil, false},
+		{"1つで拡張子なし", []string{"report"}, false, nil, false},
+		{"複数", []string{"a.json", "b.csv", "c.md", "d.txt"}, false, []OutputTarget{
+			{"a.json", OutputFormatJSON}, {"b.csv", OutputFormatCSV}, {"c.md", OutputFormatMarkdown}, {"d.txt", OutputFormatText},
+		}, false},
+		{"複数で拡張子なし", []string{"a.json", "b"}, false, nil, true},
+		{"同じファイル", []string{"a.json", "./a.json"}, false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveOutputTargets(tt.paths, tt.formatFlagged)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveOutputTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("resolveOutputTargets() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteOutputTargets(t *testing.T) {
+	dir := t.TempDir()
+	targets, err := resolveOutputTargets([]string{
+		filepath.Join(dir, "report.json"),
+		filepath.Join(dir, "report.csv"),
+		filepath.Join(dir, "report.md"),
+		filepath.Join(dir, "report.txt"),
+	}, false)
+	if err != nil {
+		t.Fatalf("resolveOutputTargets() error = %v", err)
+	}
+	result := AnalysisResult{
+		TotalVisits: 5,
+		DomainStats: []DomainStats{{Domain: "github.com", VisitCount: 3, Percentage: 60}, {Domain: "a|b.example", VisitCount: 2, Percentage: 40}},
+	}
+	config := Config{ShowDomains: true}
+	if err := writeOutputTargets(targets, result, config); err != nil {
+		t.Fatalf("writeOutputTargets() error = %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s の読み込みに失敗: %v", name, err)
+		}
+		return string(data)
+	}
+	var decoded AnalysisResult
+	if err := json.Unmarshal([]byte(read("report.json")), &decoded); err != nil || decoded.TotalVisits != 5 || len(decoded.DomainStats) != 2 {
+		t.Errorf("JSON の出力が期待値と異なる: %+v, %v", decoded, err)
+	}
+	if csv := read("report.csv"); !strings.Contains(csv, "github.com,3") {
+		t.Errorf("CSV の出力が期待値と異なる:\n%s", csv)
+	}
+	md := read("report.md")
+	for _, want := range []string{"# 📊 Safari 履歴分析結果", "## 🌐 ドメイン別訪問数 (Top 2)", "| # | ドメイン | 訪問数 | 割合 | 累積 |", "| ---: | --- | ---: | ---: | ---: |", `a\|b.example`} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown の出力に %q が含まれない:\n%s", want, md)
+		}
+	}
+	if txt := read("report.txt"); !strings.Contains(txt, "総訪問数: 5") {
+		t.Errorf("テキストの出力が期待値と異なる:\n%s", txt)
+	}
+}
+
+func TestExpandOutputPath(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local)
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"visits-{{date}}.csv", "visits-2025-01-02.csv", false},
+		{"{{ year }}/{{month}}/visits-{{time}}.ndjson", "2025/2025-01/visits-030405.ndjson", false},
+		{"visits.csv", "visits.csv", false},
+		{"visits-{{day}}.csv", "", true},
+	}
+	for _, tt := range tests {
+		got, err := expandOutputPath(tt.path, now)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expandOutputPath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestAppendOutput(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "visits.csv")
+	tsvPath := filepath.Join(dir, "visits.tsv")
+	targets := []OutputTarget{{csvPath, OutputFormatCSV}, {tsvPath, OutputFormatTSV}}
+	config := Config{ShowDomains: true, Append: true}
+
+	// 空のファイルには見出し行を書き、2回目以降はデータ行だけを追記する
+	for _, domain := range []string{"github.com", "example.com"} {
+		result := AnalysisResult{TotalVisits: 1, DomainStats: []DomainStats{{Domain: domain, VisitCount: 1}}}
+		if err := writeOutputTargets(targets, result, config); err != nil {
+			t.Fatalf("writeOutputTargets() error = %v", err)
+		}
+	}
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatalf("CSV の読み込みに失敗: %v", err)
+	}
+	want := "domain,visit_count,percentage,cumulative_percentage\ngithub.com,1,0.00,0.00\nexample.com,1,0.00,0.00\n"
+	if string(data) != want {
+		t.Errorf("追記した CSV = %q, want %q", data, want)
+	}
+	if tsv, _ := os.ReadFile(tsvPath); strings.Count(string(tsv), "\n") != 3 {
+		t.Errorf("追記した TSV = %q, want 見出し行と2行", tsv)
+	}
+
+	// 追記できない形式（Markdown）があれば、どのファイルも書かずにエラーにする
+	mdPath := filepath.Join(dir, "report.md")
+	if err := os.WriteFile(mdPath, []byte("既存の内容\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result := AnalysisResult{TotalVisits: 1, DomainStats: []DomainStats{{Domain: "example.org", VisitCount: 1}}}
+	err = writeOutputTargets([]OutputTarget{{csvPath, OutputFormatCSV}, {mdPath, OutputFormatMarkdown}}, result, config)
+	if !errors.Is(err, errAppendFormat) {
+		t.Errorf("Markdown への追記で error = %v, want errAppendFormat", err)
+	}
+	if md, _ := os.ReadFile(mdPath); string(md) != "既存の内容\n" {
+		t.Errorf("追記できない形式のファイルが上書きされた: %q", md)
+	}
+	if after, _ := os.ReadFile(csvPath); string(after) != want {
+		t.Errorf("エラーのときに CSV が変わった: %q", after)
+	}
+
+	// 複数の統計は追記できず、既存のファイルも変えない
+	result = AnalysisResult{
+		DomainStats: []DomainStats{{Domain: "github.com", VisitCount: 1}},
+		HourlyStats: []HourlyStats{{Hour: 1, VisitCount: 1}},
+	}
+	config.ShowHourly = true
+	err = writeOutputTargets(targets[:1], result, config)
+	if !errors.Is(err, errCSVAppendSections) {
+		t.Errorf("複数の統計の追記で error = %v, want errCSVAppendSections", err)
+	}
+	if after, _ := os.ReadFile(csvPath); string(after) != want {
+		t.Errorf("失敗した追記でファイルが変わった: %q", after)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "sync"
+
+// taskGroup は errgroup.Group と同じく、タスクを並行に実行して最初のエラーを返す
+// 同時に実行するタスクの数を limit までに抑える（読み取り接続の数を超えて待たせないため）
+type taskGroup struct {
+	wg   sync.WaitGroup
+	sem  chan struct{}
+	once sync.Once
+	err  error
+}
+
+// newTaskGroup は同時に limit 個までタスクを実行する taskGroup を作る
+func newTaskGroup(limit int) *taskGroup {
+	return &taskGroup{sem: make(chan struct{}, max(limit, 1))}
+}
+
+// Go はタスクを別の goroutine で実行する（空きがなければ他のタスクが終わるまで待ってから始める）
+func (g *taskGroup) Go(task func() error) {
+	g.wg.Go(func() {
+		g.sem <- struct{}{}
+		defer func() { <-g.sem }()
+		if err := task(); err != nil {
+			g.once.Do(func() { g.err = err })
+		}
+	})
+}
+
+// Wait は全てのタスクの終了を待ち、最初に失敗したタスクのエラーを返す
+func (g *taskGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
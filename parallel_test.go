@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTaskGroup(t *testing.T) {
+	g := newTaskGroup(2)
+	var running, peak atomic.Int32
+	for range 8 {
+		g.Go(func() error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("同時に %d 個実行した, want 2以下", p)
+	}
+
+	// 失敗したタスクのエラーを返し、他のタスクも最後まで実行する
+	errFailed := errors.New("failed")
+	g = newTaskGroup(1)
+	var done atomic.Int32
+	g.Go(func() error { done.Add(1); return nil })
+	g.Go(func() error { done.Add(1); return errFailed })
+	g.Go(func() error { done.Add(1); return nil })
+	if err := g.Wait(); !errors.Is(err, errFailed) {
+		t.Errorf("Wait() error = %v, want %v", err, errFailed)
+	}
+	if done.Load() != 3 {
+		t.Errorf("実行したタスク = %d, want 3", done.Load())
+	}
+}
+
+func TestAnalyzeConcurrent(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "demo.db")
+	if _, err := generateHistory(path, 3000, 30, now, 1); err != nil {
+		t.Fatalf("generateHistory() error = %v", err)
+	}
+	config := Config{
+		Limit: DefaultHistoryLimit, DomainLimit: DefaultDomainLimit, PathLimit: DefaultPathLimit, Days: DefaultDailyDays,
+		Now: now, Calendar: defaultCalendar, Filter: SearchFilter{Keyword: "Go"},
+		ShowHistory: true, ShowDomains: true, ShowHourly: true, ShowDaily: true,
+		ShowTitles: true, ShowProfiles: true, ShowLangs: true, ShowWeekly: true, ShowMonthly: true,
+	}
+
+	// 複数の接続で並行に集計した結果は、1本の接続で順に集計した結果と同じ
+	// （-paths は同数のパスの順序が決まらないため比べない）
+	db, err := openDB(path)
+	if err != nil {
+		t.Fatalf("openDB() error = %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	got, err := analyze(db, config)
+	if err != nil {
+		t.Fatalf("analyze() error = %v", err)
+	}
+
+	shared, err := openDB(path)
+	if err != nil {
+		t.Fatalf("openDB() error = %v", err)
+	}
+	defer func() { _ = shared.Close() }()
+	useSharedConnection(shared)
+	want, err := analyze(shared, config)
+	if err != nil {
+		t.Fatalf("analyze(shared) error = %v", err)
+	}
+
+	got.Query, want.Query = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Error("並行に集計した結果が1本の接続で集計した結果と異なる")
+	}
+	if got.TotalVisits != 3000 || len(got.DomainStats) == 0 || len(got.DailyStats) == 0 {
+		t.Errorf("集計されていない統計がある: total=%d, domains=%d, daily=%d", got.TotalVisits, len(got.DomainStats), len(got.DailyStats))
+	}
+}
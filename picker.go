@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// openCommand はURLをブラウザで開くコマンド（macOS の open）
+var openCommand = "open"
+
+// writePickerLines は fzf などに渡すため「URL \t タイトル \t 日時」を1行ずつ出力する
+// 同じURLは最新の訪問だけを出し、ブラウザで開けないURLやリダクト対象は出さない
+// limit が0以下の場合は全件を出力し、出力した件数を返す
+func writePickerLines(w io.Writer, db dbQuerier, limit int, filter SearchFilter, redactDomains []string) (int, error) {
+	seen := make(map[string]bool)
+	count := 0
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		if seen[v.URL] || !isOpenableURL(v.URL) || isRedactedVisit(v, redactDomains) {
+			return nil
+		}
+		seen[v.URL] = true
+
+		if err := writePickerLine(w, v); err != nil {
+			return err
+		}
+		count++
+		if limit > 0 && count >= limit {
+			return errStopStream
+		}
+		return nil
+	})
+	return count, err
+}
+
+// writePickerLine はピッカーの1行「URL \t タイトル \t 日時」を出力する
+func writePickerLine(w io.Writer, v HistoryVisit) error {
+	// タブ・改行は sanitizeText で空白になるので、列がずれることはない
+	title, _ := sanitizeText(v.Title)
+	_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", v.URL, title, v.VisitTime.Format(TimeFormatDateTime))
+	return err
+}
+
+// isOpenableURL はブラウザで開いてよいURL（http / https）かを返す
+func isOpenableURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// parsePickedURL はピッカーの出力行（またはURLそのもの）からURLを取り出す
+func parsePickedURL(line string) (string, error) {
+	u, _, _ := strings.Cut(strings.TrimSpace(line), "\t")
+	u = strings.TrimSpace(u)
+	if u == "" {
+		return "", errors.New("URLが指定されていません")
+	}
+	if !isOpenableURL(u) {
+		return "", fmt.Errorf("開けないURLです: %s", u)
+	}
+	return u, nil
+}
+
+// runOpenCommand は hist open サブコマンドを実行する
+// 例: hist -picker | fzf | hist open -
+func runOpenCommand(args []string) error {
+	fs := flag.NewFlagSet("open", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist open <URL または - >\n\n")
+		fmt.Fprintf(fs.Output(), "  - を指定すると標準入力の1行目（hist -picker の出力形式）を読み込みます\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("URLまたは - を1つ指定してください")
+	}
+
+	line := fs.Arg(0)
+	if line == "-" {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("標準入力の読み込みに失敗: %w", err)
+			}
+			// fzf をキャンセルした場合など、何も選ばれていない
+			return errNoMatches
+		}
+		line = scanner.Text()
+	}
+
+	u, err := parsePickedURL(line)
+	if err != nil {
+		return err
+	}
+	if err := exec.Command(openCommand, u).Run(); err != nil {
+		return fmt.Errorf("URLを開けませんでした: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestWritePickerLines はピッカー出力のテスト
+func TestWritePickerLines(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'javascript:void(0)', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757600000, 'JS');
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	var buf bytes.Buffer
+	count, err := writePickerLines(&buf, db, 0, SearchFilter{}, []string{"google.com"})
+	if err != nil {
+		t.Fatalf("writePickerLines失敗: %v", err)
+	}
+
+	// 同じURLは1行にまとめ、javascript: とリダクト対象（google.com）は出さない
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if count != 2 || len(lines) != 2 {
+		t.Fatalf("出力件数が期待値と異なる: count=%d, lines=%q", count, lines)
+	}
+	fields := strings.Split(lines[0], "\t")
+	if len(fields) != 3 || fields[0] != "https://youtube.com/watch" || fields[1] != "YouTube - Music" {
+		t.Errorf("1行目が期待値と異なる: %q", lines[0])
+	}
+
+	buf.Reset()
+	count, err = writePickerLines(&buf, db, 1, SearchFilter{}, nil)
+	if err != nil || count != 1 {
+		t.Errorf("limit指定時の件数が期待値と異なる: count=%d, err=%v", count, err)
+	}
+}
+
+// TestParsePickedURL はピッカーの選択行からのURL取り出しのテスト
+func TestParsePickedURL(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{"https://github.com/test\tGitHub\t2025-01-01 10:00", "https://github.com/test", false},
+		{"https://example.com\n", "https://example.com", false},
+		{"", "", true},
+		{"javascript:alert(1)\tJS\t2025-01-01 10:00", "", true},
+		{"file:///etc/passwd", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePickedURL(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePickedURL(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePickedURL(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// version はリリース時に -ldflags "-X main.version=v1.2.3" で埋め込むバージョン
+var version = ""
+
+// QueryInfo は結果を集計した条件（-json の query、-csv-split の manifest.json）
+// 出力ファイルだけで、いつ・どの条件・どのバージョンで作ったかが分かるようにする
+type QueryInfo struct {
+	FilterSummary
+	// Now は -now で指定した集計の基準時刻（指定しなければ省く）
+	Now         *time.Time `json:"now,omitempty"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Version     string     `json:"version"`
+}
+
+// FilterSummary は出力に添える絞り込み条件（同じ結果を再現できるようフラグの値を残す）
+type FilterSummary struct {
+	Keyword           string     `json:"keyword,omitempty"`
+	Domain            string     `json:"domain,omitempty"`
+	Domains           []string   `json:"domains,omitempty"`
+	From              *time.Time `json:"from,omitempty"`
+	To                *time.Time `json:"to,omitempty"`
+	IgnoreDomains     int        `json:"ignore_domains"`
+	IgnoreRules       int        `json:"ignore_rules"`
+	MatchStdin        bool       `json:"match_stdin,omitempty"`
+	Tag               string     `json:"tag,omitempty"`
+	Starred           bool       `json:"starred,omitempty"`
+	ExcludeTrackers   bool       `json:"exclude_trackers,omitempty"`
+	CollapseRedirects bool       `json:"collapse_redirects,omitempty"`
+	TitleLang         string     `json:"title_lang,omitempty"`
+	FoldKana          bool       `json:"fold_kana,omitempty"`
+	Romaji            bool       `json:"romaji,omitempty"`
+}
+
+// newQueryInfo は now に filter で集計した結果の QueryInfo を作る
+func newQueryInfo(filter SearchFilter, now time.Time) *QueryInfo {
+	return &QueryInfo{
+		FilterSummary: summarizeFilter(filter),
+		GeneratedAt:   now,
+		Version:       toolVersion(),
+	}
+}
+
+// summarizeFilter は絞り込み条件を出力に添える形にする（除外リストは件数だけ残す）
+func summarizeFilter(filter SearchFilter) FilterSummary {
+	summary := FilterSummary{
+		Keyword:           filter.Keyword,
+		Domain:            filter.Domain,
+		Domains:           filter.Domains,
+		IgnoreDomains:     len(filter.IgnoreDomains),
+		IgnoreRules:       len(filter.IgnoreRules),
+		MatchStdin:        filter.Match != nil,
+		Starred:           filter.Starred != nil,
+		ExcludeTrackers:   filter.ExcludeTrackers,
+		CollapseRedirects: filter.CollapseRedirects,
+		TitleLang:         filter.TitleLang,
+		FoldKana:          filter.FoldKana,
+		Romaji:            filter.Romaji,
+	}
+	if filter.Tag != nil {
+		summary.Tag = filter.Tag.Tag
+	}
+	if !filter.From.IsZero() {
+		summary.From = &filter.From
+	}
+	if !filter.To.IsZero() {
+		summary.To = &filter.To
+	}
+	return summary
+}
+
+// anonymizeQueryInfo は条件のキーワード・タグをハッシュ化し、strict ならドメインもハッシュ化したコピーを返す
+func anonymizeQueryInfo(query *QueryInfo, mode AnonymizeMode) *QueryInfo {
+	if query == nil {
+		return nil
+	}
+	anonymized := *query
+	anonymized.Keyword = hashValue(query.Keyword)
+	anonymized.Tag = hashValue(query.Tag)
+	anonymized.Domain = anonymizeDomain(query.Domain, mode)
+	if query.Domains != nil {
+		anonymized.Domains = make([]string, len(query.Domains))
+		for i, d := range query.Domains {
+			anonymized.Domains[i] = anonymizeDomain(d, mode)
+		}
+	}
+	return &anonymized
+}
+
+// toolVersion は hist のバージョンを返す
+// -ldflags で埋め込んだ値、go install したモジュールのバージョン、ビルド元のコミットの順に使う
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return "devel-" + revision
+}
@@ -21,12 +21,34 @@ func NewQueryBuilder(baseQuery string) *QueryBuilder {
 }
 
 // WithKeyword はキーワード検索条件を追加（URL・タイトルの部分一致）
-func (qb *QueryBuilder) WithKeyword(keyword string) *QueryBuilder {
-	if keyword != "" {
-		qb.where.WriteString(` AND (hi.url LIKE ? OR hv.title LIKE ?)`)
-		likePattern := "%" + keyword + "%"
+// キーワードと履歴の両方を hist_fold で正規化して比べる（全角・半角、アクセント、大文字・小文字を区別しない）
+// foldKana ならひらがなとカタカナも区別しない
+func (qb *QueryBuilder) WithKeyword(keyword string, foldKana bool) *QueryBuilder {
+	if keyword == "" {
+		return qb
+	}
+	return qb.WithKeywords([]string{keyword}, foldKana)
+}
+
+// WithKeywords はいずれかのキーワードを含む条件を追加（-romaji で広げた候補など）
+// 各キーワードの判定は WithKeyword と同じ
+func (qb *QueryBuilder) WithKeywords(keywords []string, foldKana bool) *QueryBuilder {
+	fold := "0"
+	if foldKana {
+		fold = "1"
+	}
+	var conds []string
+	for _, k := range keywords {
+		if k == "" {
+			continue
+		}
+		conds = append(conds, `hist_fold(hi.url, `+fold+`) LIKE ? OR hist_fold(COALESCE(hv.title, ''), `+fold+`) LIKE ?`)
+		likePattern := "%" + normalizeSearchText(k, foldKana) + "%"
 		qb.args = append(qb.args, likePattern, likePattern)
 	}
+	if len(conds) > 0 {
+		qb.where.WriteString(` AND (` + strings.Join(conds, ` OR `) + `)`)
+	}
 	return qb
 }
 
@@ -46,6 +68,40 @@ func (qb *QueryBuilder) WithDomain(domain string) *QueryBuilder {
 	return qb
 }
 
+// WithDomains は複数ドメインのいずれかに一致する条件を追加
+// 各ドメインの判定はWithDomainと同じ（domain_expansion、またはURLのホスト部分）
+func (qb *QueryBuilder) WithDomains(domains []string) *QueryBuilder {
+	var conds []string
+	for _, d := range domains {
+		if d == "" {
+			continue
+		}
+		conds = append(conds, `hi.domain_expansion = ? OR hi.url LIKE ? OR hi.url LIKE ?`)
+		qb.args = append(qb.args, d, "%://"+d+"/%", "%://"+d)
+	}
+	if len(conds) > 0 {
+		qb.where.WriteString(` AND (` + strings.Join(conds, ` OR `) + `)`)
+	}
+	return qb
+}
+
+// In はカラムの値が候補のいずれかに一致する条件を追加（IN句のプレースホルダを展開）
+// 候補が空の場合は何にも一致しない条件になる
+func (qb *QueryBuilder) In(column string, values []interface{}) *QueryBuilder {
+	if len(values) == 0 {
+		qb.where.WriteString(` AND 0`)
+		return qb
+	}
+	qb.where.WriteString(` AND ` + column + ` IN (` + inPlaceholders(len(values)) + `)`)
+	qb.args = append(qb.args, values...)
+	return qb
+}
+
+// inPlaceholders はIN句用に n 個のプレースホルダ（?, ?, ...）を返す
+func inPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
 // WithIgnoreDomains は除外ドメイン条件を追加
 // サブドメインも含めて除外（例: "google" → "google", "accounts.google", "docs.google" 等を除外）
 // domain_expansionがNULL/空の場合はURLからドメインを判定
@@ -67,6 +123,61 @@ func (qb *QueryBuilder) WithIgnoreDomains(domains []string) *QueryBuilder {
 	return qb
 }
 
+// visitLocalClockExpr は訪問時刻をローカル時刻の "HH:MM" に変換するSQL式
+// SQLite の 'localtime' ではなく Go の time.Local で変換する関数を使い、集計（convertCoreDataTimestamp）と同じタイムゾーンにする
+const visitLocalClockExpr = `hist_local_clock(hv.visit_time)`
+
+// visitLocalWeekdayExpr は訪問時刻のローカル曜日（0=日曜）を返すSQL式
+const visitLocalWeekdayExpr = `hist_local_weekday(hv.visit_time)`
+
+// localClock は visit_time（Core Data timestamp）のローカル時刻の "HH:MM"（SQL関数 hist_local_clock）
+func localClock(visitTime float64) string {
+	return convertCoreDataTimestamp(visitTime).Format("15:04")
+}
+
+// localWeekday は visit_time のローカルの曜日（0=日曜、SQL関数 hist_local_weekday）
+func localWeekday(visitTime float64) int {
+	return int(convertCoreDataTimestamp(visitTime).Weekday())
+}
+
+// WithIgnoreRules は時間帯付きの除外条件を追加
+// ルールごとに「ドメインが一致し、かつ時間帯（と曜日）に入る訪問」を除外する
+func (qb *QueryBuilder) WithIgnoreRules(rules []IgnoreRule) *QueryBuilder {
+	for _, r := range rules {
+		var conds []string
+
+		if !r.matchesAllDomains() {
+			d := r.Domain
+			// ドメイン部分にマッチ: domain_expansion、または ://domain/ ://domain. ://sub.domain/ 等
+			conds = append(conds, `(COALESCE(hi.domain_expansion, '') = ?`+
+				` OR COALESCE(hi.domain_expansion, '') LIKE ?`+
+				` OR hi.url LIKE ? OR hi.url LIKE ? OR hi.url LIKE ?`+
+				` OR hi.url LIKE ? OR hi.url LIKE ?)`)
+			qb.args = append(qb.args, d, "%."+d,
+				"%://"+d, "%://"+d+"/%", "%://"+d+".%",
+				"%://%."+d, "%://%."+d+"/%")
+		}
+
+		if r.Start < r.End {
+			conds = append(conds, `(`+visitLocalClockExpr+` >= ? AND `+visitLocalClockExpr+` < ?)`)
+		} else {
+			// 日付をまたぐ時間帯（例: 22:00-02:00）
+			conds = append(conds, `(`+visitLocalClockExpr+` >= ? OR `+visitLocalClockExpr+` < ?)`)
+		}
+		qb.args = append(qb.args, r.Start, r.End)
+
+		if len(r.Weekdays) > 0 {
+			conds = append(conds, visitLocalWeekdayExpr+` IN (`+inPlaceholders(len(r.Weekdays))+`)`)
+			for _, d := range r.Weekdays {
+				qb.args = append(qb.args, d)
+			}
+		}
+
+		qb.where.WriteString(` AND NOT (` + strings.Join(conds, ` AND `) + `)`)
+	}
+	return qb
+}
+
 // WithDateRange は日付範囲フィルタ条件を追加
 func (qb *QueryBuilder) WithDateRange(from, to time.Time) *QueryBuilder {
 	if !from.IsZero() {
@@ -83,10 +194,44 @@ func (qb *QueryBuilder) WithDateRange(from, to time.Time) *QueryBuilder {
 
 // WithFilter はSearchFilter全体を適用
 func (qb *QueryBuilder) WithFilter(filter SearchFilter) *QueryBuilder {
-	return qb.WithKeyword(filter.Keyword).
+	return qb.WithIndexedKeywords(searchKeywords(filter), filter.FoldKana, filter.Index).
 		WithDomain(filter.Domain).
+		WithDomains(filter.Domains).
 		WithDateRange(filter.From, filter.To).
-		WithIgnoreDomains(filter.IgnoreDomains)
+		WithMatchList(filter.Match).
+		WithTagMatch(filter.Tag).
+		WithMatchList(filter.Starred).
+		WithIgnoreDomains(filter.IgnoreDomains).
+		WithIgnoreRules(filter.IgnoreRules).
+		WithoutTrackers(filter.ExcludeTrackers).
+		WithTitleLang(filter.TitleLang)
+}
+
+// WithRawCondition は任意の条件式をAND条件として追加（プレースホルダの値はargsで渡す）
+// 例: WithRawCondition("hi.visit_count >= ?", 10)
+func (qb *QueryBuilder) WithRawCondition(condition string, args ...interface{}) *QueryBuilder {
+	if condition != "" {
+		qb.where.WriteString(` AND (` + condition + `)`)
+		qb.args = append(qb.args, args...)
+	}
+	return qb
+}
+
+// GroupBy はGROUP BY句を追加
+func (qb *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
+	if len(columns) > 0 {
+		qb.where.WriteString(` GROUP BY ` + strings.Join(columns, ", "))
+	}
+	return qb
+}
+
+// Having はHAVING句を追加（GroupByの後に呼ぶ）
+func (qb *QueryBuilder) Having(condition string, args ...interface{}) *QueryBuilder {
+	if condition != "" {
+		qb.where.WriteString(` HAVING ` + condition)
+		qb.args = append(qb.args, args...)
+	}
+	return qb
 }
 
 // OrderByDesc はORDER BY DESC句を追加
@@ -95,6 +240,12 @@ func (qb *QueryBuilder) OrderByDesc(column string) *QueryBuilder {
 	return qb
 }
 
+// OrderByAsc はORDER BY ASC句を追加
+func (qb *QueryBuilder) OrderByAsc(column string) *QueryBuilder {
+	qb.where.WriteString(` ORDER BY ` + column + ` ASC`)
+	return qb
+}
+
 // Limit はLIMIT句を追加
 func (qb *QueryBuilder) Limit(limit int) *QueryBuilder {
 	qb.where.WriteString(` LIMIT ?`)
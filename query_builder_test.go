@@ -20,10 +20,10 @@ func TestNewQueryBuilder(t *testing.T) {
 
 func TestQueryBuilderWithKeyword(t *testing.T) {
 	baseQuery := "SELECT * FROM test WHERE 1=1"
-	qb := NewQueryBuilder(baseQuery).WithKeyword("test")
+	qb := NewQueryBuilder(baseQuery).WithKeyword("test", false)
 
 	query, args := qb.Build()
-	expectedQuery := baseQuery + ` AND (hi.url LIKE ? OR hv.title LIKE ?)`
+	expectedQuery := baseQuery + ` AND (hist_fold(hi.url, 0) LIKE ? OR hist_fold(COALESCE(hv.title, ''), 0) LIKE ?)`
 	if query != expectedQuery {
 		t.Errorf("期待値 %q, 実際 %q", expectedQuery, query)
 	}
@@ -37,7 +37,7 @@ func TestQueryBuilderWithKeyword(t *testing.T) {
 
 func TestQueryBuilderWithEmptyKeyword(t *testing.T) {
 	baseQuery := "SELECT * FROM test WHERE 1=1"
-	qb := NewQueryBuilder(baseQuery).WithKeyword("")
+	qb := NewQueryBuilder(baseQuery).WithKeyword("", false)
 
 	query, args := qb.Build()
 	if query != baseQuery {
@@ -198,7 +198,7 @@ func TestQueryBuilderOffset(t *testing.T) {
 func TestQueryBuilderChaining(t *testing.T) {
 	baseQuery := "SELECT * FROM test WHERE 1=1"
 	qb := NewQueryBuilder(baseQuery).
-		WithKeyword("test").
+		WithKeyword("test", false).
 		WithDomain("example.com").
 		OrderByDesc("visit_time").
 		Limit(10).
@@ -208,7 +208,7 @@ func TestQueryBuilderChaining(t *testing.T) {
 
 	// すべての条件が含まれているか確認
 	expectedParts := []string{
-		"AND (hi.url LIKE ? OR hv.title LIKE ?)",
+		"AND (hist_fold(hi.url, 0) LIKE ? OR hist_fold(COALESCE(hv.title, ''), 0) LIKE ?)",
 		"AND (hi.domain_expansion = ? OR hi.url LIKE ? OR hi.url LIKE ?)",
 		"ORDER BY visit_time DESC",
 		"LIMIT ?",
@@ -228,7 +228,7 @@ func TestQueryBuilderChaining(t *testing.T) {
 
 func TestQueryBuilderArgs(t *testing.T) {
 	baseQuery := "SELECT * FROM test WHERE 1=1"
-	qb := NewQueryBuilder(baseQuery).WithKeyword("test").WithDomain("example.com")
+	qb := NewQueryBuilder(baseQuery).WithKeyword("test", false).WithDomain("example.com")
 
 	args := qb.Args()
 	if len(args) != 5 { // 2 (keyword) + 3 (domain)
@@ -299,6 +299,123 @@ func TestQueryBuilderWithFilterIncludesIgnoreDomains(t *testing.T) {
 	}
 }
 
+func TestQueryBuilderWithIgnoreRules(t *testing.T) {
+	baseQuery := "SELECT * FROM test WHERE 1=1"
+	rules := []IgnoreRule{
+		{Domain: "*", Start: "00:00", End: "06:00"},
+		{Domain: "youtube.com", Start: "22:00", End: "02:00", Weekdays: []int{1, 2}},
+	}
+	qb := NewQueryBuilder(baseQuery).WithIgnoreRules(rules)
+
+	query, args := qb.Build()
+	if !containsString(query, "AND NOT (("+visitLocalClockExpr+" >= ? AND "+visitLocalClockExpr+" < ?))") {
+		t.Errorf("全ドメインの時間帯条件が含まれていない: %q", query)
+	}
+	// 日付をまたぐ時間帯はOR条件
+	if !containsString(query, visitLocalClockExpr+" >= ? OR "+visitLocalClockExpr+" < ?") {
+		t.Errorf("日付をまたぐ時間帯条件が含まれていない: %q", query)
+	}
+	if !containsString(query, visitLocalWeekdayExpr+" IN (?, ?)") {
+		t.Errorf("曜日条件が含まれていない: %q", query)
+	}
+	// 全ドメイン: 時刻2、youtube.com: ドメイン7 + 時刻2 + 曜日2
+	if len(args) != 13 {
+		t.Errorf("期待値 13個の引数, 実際 %d個: %v", len(args), args)
+	}
+}
+
+func TestQueryBuilderOrderByAsc(t *testing.T) {
+	baseQuery := "SELECT * FROM test WHERE 1=1"
+	qb := NewQueryBuilder(baseQuery).OrderByAsc("hv.visit_time")
+
+	query, _ := qb.Build()
+	expectedQuery := baseQuery + ` ORDER BY hv.visit_time ASC`
+	if query != expectedQuery {
+		t.Errorf("期待値 %q, 実際 %q", expectedQuery, query)
+	}
+}
+
+func TestQueryBuilderWithRawCondition(t *testing.T) {
+	baseQuery := "SELECT * FROM test WHERE 1=1"
+	qb := NewQueryBuilder(baseQuery).
+		WithKeyword("go", false).
+		WithRawCondition("hi.visit_count BETWEEN ? AND ?", 5, 10).
+		WithRawCondition("")
+
+	query, args := qb.Build()
+	expectedQuery := baseQuery + ` AND (hist_fold(hi.url, 0) LIKE ? OR hist_fold(COALESCE(hv.title, ''), 0) LIKE ?) AND (hi.visit_count BETWEEN ? AND ?)`
+	if query != expectedQuery {
+		t.Errorf("期待値 %q, 実際 %q", expectedQuery, query)
+	}
+	if len(args) != 4 || args[2] != 5 || args[3] != 10 {
+		t.Errorf("期待値 [%%go%% %%go%% 5 10], 実際 %v", args)
+	}
+}
+
+func TestQueryBuilderGroupByHaving(t *testing.T) {
+	baseQuery := "SELECT hi.domain_expansion, COUNT(*) FROM test WHERE 1=1"
+	qb := NewQueryBuilder(baseQuery).
+		GroupBy("hi.domain_expansion").
+		Having("COUNT(*) >= ?", 3).
+		OrderByDesc("COUNT(*)").
+		Limit(5)
+
+	query, args := qb.Build()
+	expectedQuery := baseQuery + ` GROUP BY hi.domain_expansion HAVING COUNT(*) >= ? ORDER BY COUNT(*) DESC LIMIT ?`
+	if query != expectedQuery {
+		t.Errorf("期待値 %q, 実際 %q", expectedQuery, query)
+	}
+	if len(args) != 2 || args[0] != 3 || args[1] != 5 {
+		t.Errorf("期待値 [3 5], 実際 %v", args)
+	}
+
+	// 空のGROUP BYは何も追加しない
+	empty, _ := NewQueryBuilder(baseQuery).GroupBy().Build()
+	if empty != baseQuery {
+		t.Errorf("空のGroupByでクエリが変更された: %q", empty)
+	}
+}
+
+func TestQueryBuilderWithDomains(t *testing.T) {
+	baseQuery := "SELECT * FROM test WHERE 1=1"
+	qb := NewQueryBuilder(baseQuery).WithDomains([]string{"github.com", "", "youtube"})
+
+	query, args := qb.Build()
+	expectedQuery := baseQuery + ` AND (hi.domain_expansion = ? OR hi.url LIKE ? OR hi.url LIKE ?` +
+		` OR hi.domain_expansion = ? OR hi.url LIKE ? OR hi.url LIKE ?)`
+	if query != expectedQuery {
+		t.Errorf("期待値 %q, 実際 %q", expectedQuery, query)
+	}
+	if len(args) != 6 || args[0] != "github.com" || args[3] != "youtube" {
+		t.Errorf("期待値 6個の引数, 実際 %v", args)
+	}
+
+	empty, emptyArgs := NewQueryBuilder(baseQuery).WithDomains(nil).Build()
+	if empty != baseQuery || len(emptyArgs) != 0 {
+		t.Errorf("空のドメインリストでクエリが変更された: %q %v", empty, emptyArgs)
+	}
+}
+
+func TestQueryBuilderIn(t *testing.T) {
+	baseQuery := "SELECT * FROM test WHERE 1=1"
+	qb := NewQueryBuilder(baseQuery).In("hi.id", []interface{}{1, 2, 3})
+
+	query, args := qb.Build()
+	expectedQuery := baseQuery + ` AND hi.id IN (?, ?, ?)`
+	if query != expectedQuery {
+		t.Errorf("期待値 %q, 実際 %q", expectedQuery, query)
+	}
+	if len(args) != 3 {
+		t.Errorf("期待値 3個の引数, 実際 %d個", len(args))
+	}
+
+	// 空の候補は何にも一致しない
+	empty, _ := NewQueryBuilder(baseQuery).In("hi.id", nil).Build()
+	if empty != baseQuery+` AND 0` {
+		t.Errorf("空のIN句が期待と異なる: %q", empty)
+	}
+}
+
 // containsString はsがsubstrを含むかをチェック
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsStringHelper(s, substr))
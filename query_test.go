@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewQueryInfo(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	filter := SearchFilter{
+		Keyword:       "github",
+		Domains:       []string{"github.com", "gitlab.com"},
+		From:          from,
+		IgnoreDomains: []string{"ads.example"},
+		IgnoreRules:   []IgnoreRule{{Domain: "youtube.com"}, {Domain: "x.com"}},
+	}
+	query := newQueryInfo(filter, now)
+	if query.Keyword != "github" || len(query.Domains) != 2 || !query.From.Equal(from) || query.To != nil {
+		t.Errorf("newQueryInfo() = %+v", query)
+	}
+	if query.IgnoreDomains != 1 || query.IgnoreRules != 2 || !query.GeneratedAt.Equal(now) || query.Version == "" {
+		t.Errorf("newQueryInfo() = %+v", query)
+	}
+
+	// JSON では条件のフィールドを query の直下に並べ、指定していない条件は省く
+	data, err := json.Marshal(AnalysisResult{Query: query})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, want := range []string{`"query":{"keyword":"github"`, `"from":"2025-01-01T00:00:00Z"`, `"ignore_domains":1`, `"generated_at":"2025-02-01T09:00:00Z"`, `"version":`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON に %s が含まれない: %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), `"to"`) {
+		t.Errorf("指定していない to が含まれる: %s", data)
+	}
+}
+
+func TestAnonymizeQueryInfo(t *testing.T) {
+	query := newQueryInfo(SearchFilter{Keyword: "secret", Domain: "example.com"}, time.Now())
+
+	normal := anonymizeResult(AnalysisResult{Query: query}, AnonymizeNormal).Query
+	if normal.Keyword == "secret" || normal.Domain != "example.com" {
+		t.Errorf("-anonymize の query = %+v", normal)
+	}
+	strict := anonymizeResult(AnalysisResult{Query: query}, AnonymizeStrict).Query
+	if strict.Keyword == "secret" || strict.Domain == "example.com" {
+		t.Errorf("-anonymize=strict の query = %+v", strict)
+	}
+	if query.Keyword != "secret" {
+		t.Error("元の query が書き換えられた")
+	}
+}
+
+func TestToolVersion(t *testing.T) {
+	old := version
+	t.Cleanup(func() { version = old })
+	version = "v1.2.3"
+	if got := toolVersion(); got != "v1.2.3" {
+		t.Errorf("toolVersion() = %q, want v1.2.3", got)
+	}
+	version = ""
+	if got := toolVersion(); got == "" {
+		t.Error("toolVersion() が空")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errNoMatches はCLIモードで表示する結果が1件もなかったことを表す
+// main で ExitCodeNoMatch に変換する
+var errNoMatches = errors.New("一致する結果がありません")
+
+// hasMatches は表示対象の結果が1件以上あるかを返す
+// 時間帯・日別統計は全て0件なら一致なしとみなす（総訪問数は条件によらないので見ない）
+func (r AnalysisResult) hasMatches() bool {
+	if len(r.RecentVisits) > 0 || len(r.DomainStats) > 0 || len(r.DomainPathStats) > 0 || len(r.TitleClusters) > 0 {
+		return true
+	}
+	if r.Videos != nil && len(r.Videos.Videos) > 0 {
+		return true
+	}
+	if r.GitHub != nil && len(r.GitHub.Repos) > 0 {
+		return true
+	}
+	if r.Docs != nil && len(r.Docs.Lookups) > 0 {
+		return true
+	}
+	if len(r.LocalHosts) > 0 {
+		return true
+	}
+	if len(r.HourlyProfiles) > 0 || len(r.CategoryProfiles) > 0 {
+		return true
+	}
+	if len(r.TitleLanguages) > 0 {
+		return true
+	}
+	if r.Distribution != nil && r.Distribution.TotalVisits > 0 {
+		return true
+	}
+	if r.Forecast != nil && r.Forecast.Method != "" {
+		return true
+	}
+	if r.Changepoints != nil && len(r.Changepoints.Changepoints) > 0 {
+		return true
+	}
+	if r.Joined != nil && len(r.Joined.Correlations) > 0 {
+		return true
+	}
+	if r.ScreenTime != nil && len(r.ScreenTime.Days) > 0 {
+		return true
+	}
+	if r.GroupStats != nil && !r.GroupStats.empty() {
+		return true
+	}
+	for _, report := range []*PeriodReport{r.WeeklyStats, r.MonthlyStats} {
+		if report == nil {
+			continue
+		}
+		for _, p := range report.Periods {
+			if p.VisitCount > 0 {
+				return true
+			}
+		}
+	}
+	for _, s := range r.HourlyStats {
+		if s.VisitCount > 0 {
+			return true
+		}
+	}
+	if r.HourlySplit != nil {
+		for i := range r.HourlySplit.Weekday {
+			if r.HourlySplit.Weekday[i].VisitCount > 0 || r.HourlySplit.Weekend[i].VisitCount > 0 {
+				return true
+			}
+		}
+	}
+	for _, s := range r.DailyStats {
+		if s.VisitCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printQuietOutput は見出しや装飾を省き、データ行だけをタブ区切りで出力する
+// 複数のセクションを出力する場合は空行で区切る
+func printQuietOutput(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool) {
+	wroteSection := false
+	startSection := func() {
+		if wroteSection {
+			_, _ = fmt.Fprintln(w)
+		}
+		wroteSection = true
+	}
+
+	if showHistory && len(result.RecentVisits) > 0 {
+		startSection()
+		for _, v := range result.RecentVisits {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", v.VisitTime.Format(TimeFormatFull), v.Title, v.URL)
+		}
+	}
+
+	if showDomains && len(result.DomainStats) > 0 {
+		startSection()
+		for _, s := range result.DomainStats {
+			_, _ = fmt.Fprintf(w, "%s\t%d\n", s.Domain, s.VisitCount)
+		}
+	}
+
+	if showPaths && len(result.DomainPathStats) > 0 {
+		startSection()
+		for _, ds := range result.DomainPathStats {
+			for _, p := range ds.Paths {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", ds.Domain, p.Path, p.VisitCount)
+			}
+		}
+	}
+
+	if showHourly && len(result.HourlyStats) > 0 {
+		startSection()
+		for _, s := range result.HourlyStats {
+			_, _ = fmt.Fprintf(w, "%02d:00\t%d\n", s.Hour, s.VisitCount)
+		}
+	}
+
+	if showHourly && result.HourlySplit != nil {
+		startSection()
+		for i, wd := range result.HourlySplit.Weekday {
+			_, _ = fmt.Fprintf(w, "%02d:00\t%.2f\t%.2f\n", wd.Hour, wd.Average, result.HourlySplit.Weekend[i].Average)
+		}
+	}
+
+	if len(result.TitleClusters) > 0 {
+		startSection()
+		for _, c := range result.TitleClusters {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\n", c.Title, c.VisitCount, len(c.URLs))
+		}
+	}
+
+	if result.Videos != nil && len(result.Videos.Videos) > 0 {
+		startSection()
+		for _, v := range result.Videos.Videos {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", v.Platform, v.Title, v.VisitCount, v.Sessions, v.URL)
+		}
+	}
+
+	if result.GitHub != nil && len(result.GitHub.Repos) > 0 {
+		startSection()
+		for _, r := range result.GitHub.Repos {
+			_, _ = fmt.Fprintf(w, "%s\t%d\n", r.Repo, r.VisitCount)
+		}
+	}
+
+	if result.Docs != nil && len(result.Docs.Lookups) > 0 {
+		startSection()
+		for _, l := range result.Docs.Lookups {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", l.Site, l.Path, l.VisitCount)
+		}
+	}
+
+	if len(result.LocalHosts) > 0 {
+		startSection()
+		for _, s := range result.LocalHosts {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", s.Host, s.Port, s.VisitCount)
+		}
+	}
+
+	if len(result.HourlyProfiles) > 0 {
+		startSection()
+		for _, p := range result.HourlyProfiles {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\n", p.Domain, p.PeakHour, p.VisitCount)
+		}
+	}
+
+	if len(result.CategoryProfiles) > 0 {
+		startSection()
+		for _, p := range result.CategoryProfiles {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\n", p.Category, p.PeakHour, p.VisitCount)
+		}
+	}
+
+	if len(result.TitleLanguages) > 0 {
+		startSection()
+		for _, l := range result.TitleLanguages {
+			_, _ = fmt.Fprintf(w, "%s\t%d\n", l.Lang, l.VisitCount)
+		}
+	}
+
+	if showDaily && len(result.DailyStats) > 0 {
+		startSection()
+		for _, s := range result.DailyStats {
+			_, _ = fmt.Fprintf(w, "%s\t%d\n", s.Date, s.VisitCount)
+		}
+	}
+
+	if f := result.Forecast; f != nil {
+		startSection()
+		for _, d := range f.Daily {
+			_, _ = fmt.Fprintf(w, "%s\t%.1f\n", d.Date, d.Visits)
+		}
+	}
+
+	if report := result.Changepoints; report != nil && len(report.Changepoints) > 0 {
+		startSection()
+		for _, cp := range report.Changepoints {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", cp.Date, strings.Join(cp.Kinds, ","))
+		}
+	}
+
+	if report := result.Joined; report != nil && len(report.Correlations) > 0 {
+		startSection()
+		for _, c := range report.Correlations {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%.3f\n", c.Metric, c.Target, c.Pearson)
+		}
+	}
+
+	if report := result.ScreenTime; report != nil && len(report.Days) > 0 {
+		startSection()
+		for _, d := range report.Days {
+			_, _ = fmt.Fprintf(w, "%s\t%.0f\t%d\n", d.Date, d.ScreenMinutes, d.Visits)
+		}
+	}
+
+	for _, report := range []*PeriodReport{result.WeeklyStats, result.MonthlyStats} {
+		if report == nil {
+			continue
+		}
+		startSection()
+		for _, p := range report.Periods {
+			_, _ = fmt.Fprintf(w, "%s\t%d\n", p.Start, p.VisitCount)
+		}
+	}
+
+	if dist := result.Distribution; dist != nil {
+		startSection()
+		_, _ = fmt.Fprintf(w, "mean\t%.1f\np50\t%.1f\np90\t%.1f\nmax\t%d\nmin\t%d\n", dist.Mean, dist.P50, dist.P90, dist.Max, dist.Min)
+	}
+
+	if report := result.GroupStats; report != nil && !report.empty() {
+		startSection()
+		if len(report.Daily) > 0 {
+			for _, d := range report.Daily {
+				for _, g := range d.Groups {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", d.Date, g.Group, g.VisitCount)
+				}
+			}
+		} else {
+			for _, g := range report.Groups {
+				_, _ = fmt.Fprintf(w, "%s\t%d\n", g.Group, g.VisitCount)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestHasMatches は結果の有無判定のテスト
+func TestHasMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		result AnalysisResult
+		want   bool
+	}{
+		{"空", AnalysisResult{TotalVisits: 100}, false},
+		{"時間帯が全て0件", AnalysisResult{HourlyStats: []HourlyStats{{Hour: 0}, {Hour: 1}}}, false},
+		{"履歴あり", AnalysisResult{RecentVisits: []HistoryVisit{{URL: "https://example.com"}}}, true},
+		{"日別に訪問あり", AnalysisResult{DailyStats: []DailyStats{{Date: "2025-01-01", VisitCount: 1}}}, true},
+		{"平日・週末に訪問あり", AnalysisResult{HourlySplit: &HourlySplitStats{
+			Weekday: []HourlyAverage{{Hour: 0}},
+			Weekend: []HourlyAverage{{Hour: 0, VisitCount: 1}},
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.hasMatches(); got != tt.want {
+				t.Errorf("hasMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPrintQuietOutput は装飾なし出力のテスト
+func TestPrintQuietOutput(t *testing.T) {
+	result := AnalysisResult{
+		TotalVisits: 10,
+		RecentVisits: []HistoryVisit{
+			{URL: "https://github.com/test", Title: "Test", VisitTime: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		DomainStats: []DomainStats{{Domain: "github.com", VisitCount: 10}},
+	}
+
+	var buf bytes.Buffer
+	printQuietOutput(&buf, result, true, true, false, false, false)
+
+	want := "2025-01-01 10:00:00\tTest\thttps://github.com/test\n\ngithub.com\t10\n"
+	if got := buf.String(); got != want {
+		t.Errorf("printQuietOutput() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterMaxClients は古いバケットを捨て始めるクライアント数
+const rateLimiterMaxClients = 1024
+
+// tokenBucket はクライアント1つのトークンバケット
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter はクライアントIPごとのトークンバケットでリクエストを制限する
+// 1秒に rate 個ずつトークンが溜まり（最大 burst 個）、リクエストごとに1個使う
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	mu    sync.Mutex
+	// buckets はクライアントIPごとのバケット（mu で守る）
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter は1秒あたり rate 回・連続 burst 回までのリクエストを許す rateLimiter を作成する
+// rate が 0 以下なら nil（制限しない）を返す
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate, burst: float64(max(burst, 1)), buckets: make(map[string]*tokenBucket)}
+}
+
+// allow は client のリクエストを now に受け付けてよいかを返す
+// 受け付けない場合は、次のトークンが溜まるまでの時間も返す
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= rateLimiterMaxClients {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune はトークンが満タンまで溜まったバケット（しばらくリクエストのないクライアント）を捨てる
+func (l *rateLimiter) prune(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// clientIP はリクエストを送ったクライアントのIPアドレスを返す
+// hist はローカルで動かすため、X-Forwarded-For などのヘッダーは信用しない
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitAPI は /api/ へのリクエストをクライアントIPごとに制限するハンドラーを返す
+// 上限を超えたリクエストは SQLite に届く前に 429 と Retry-After を返す（ページと静的ファイルは制限しない）
+func (l *rateLimiter) limitAPI(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, wait := l.allow(clientIP(r), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "リクエストが多すぎます。しばらく待ってから再試行してください", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// queryInt はクエリパラメータ name の正の整数を upper を上限にして返す（なければ・不正なら def）
+func queryInt(r *http.Request, name string, def, upper int) int {
+	if v := r.URL.Query().Get(name); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			return min(parsed, upper)
+		}
+	}
+	return def
+}
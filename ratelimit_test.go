@@ -0,0 +1,117 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// 続けて burst 回までは受け付け、その後は次のトークンまで待たせる
+	for i := range 3 {
+		if ok, _ := l.allow("192.0.2.1", now); !ok {
+			t.Fatalf("%d回目を受け付けなかった", i+1)
+		}
+	}
+	ok, wait := l.allow("192.0.2.1", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("4回目 = %v, 待ち時間 %v", ok, wait)
+	}
+	// 他のクライアントは別のバケット
+	if ok, _ := l.allow("192.0.2.2", now); !ok {
+		t.Error("別のクライアントを受け付けなかった")
+	}
+	// 0.5秒でトークンが1個溜まる
+	if ok, _ := l.allow("192.0.2.1", now.Add(500*time.Millisecond)); !ok {
+		t.Error("トークンが溜まった後に受け付けなかった")
+	}
+
+	// 満タンまで溜まったバケットは捨てる
+	l.prune(now.Add(time.Hour))
+	if len(l.buckets) != 0 {
+		t.Errorf("prune 後のバケット = %d", len(l.buckets))
+	}
+
+	if newRateLimiter(0, 10) != nil {
+		t.Error("rate が 0 なのに制限している")
+	}
+}
+
+func TestLimitAPI(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	noContent := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := l.limitAPI(noContent)
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/api/stats", "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+		t.Fatalf("1回目のステータス = %d", rec.Code)
+	}
+	// ポートが違っても同じクライアント
+	rec := get("/api/history", "192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("上限を超えたときのステータス = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// ページは制限しない
+	if rec := get("/history", "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("ページのステータス = %d", rec.Code)
+	}
+
+	// rateLimiter が nil なら制限しない
+	var unlimited *rateLimiter
+	handler = unlimited.limitAPI(noContent)
+	for range 3 {
+		if rec := get("/api/stats", "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+			t.Fatalf("制限しない場合のステータス = %d", rec.Code)
+		}
+	}
+}
+
+func TestQueryParamLimits(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl}
+	defer func() { _ = s.db.Close() }()
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"", 50},
+		{"limit=10", 10},
+		{"limit=1000000", WebMaxLimit},
+		{"limit=-1", 50},
+		{"limit=abc", 50},
+	} {
+		r := httptest.NewRequest("GET", "/api/history?"+tt.query, nil)
+		if got := queryInt(r, "limit", WebPageSize, WebMaxLimit); got != tt.want {
+			t.Errorf("queryInt(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+
+	// 履歴一覧の per_page はページ送りのリンクに引き継ぐ
+	rec := httptest.NewRecorder()
+	s.handleHistory(rec, httptest.NewRequest("GET", "/history?per_page=2", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "page=2&per_page=2") || strings.Count(body, "<tr class=\"hover:bg-gray-50 cursor-pointer\" data-url=") != 2 {
+		t.Errorf("per_page=2 の履歴一覧:\n%s", body)
+	}
+}
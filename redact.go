@@ -0,0 +1,117 @@
+package main
+
+// redactedLabel はリダクト対象の表示に使う文字列
+const redactedLabel = "[redacted]"
+
+// isRedactedVisit は訪問がリダクト対象かチェック
+// domain_expansion とURLから抽出したホスト名の両方で判定する
+func isRedactedVisit(v HistoryVisit, redactDomains []string) bool {
+	if len(redactDomains) == 0 {
+		return false
+	}
+	return domainMatchesList(v.Domain, redactDomains) ||
+		domainMatchesList(extractDomain(v.URL), redactDomains)
+}
+
+// redactVisits はリダクト対象の訪問のURL・タイトル・ドメインを伏せたコピーを返す
+// 訪問時刻は残すので件数や時間帯の集計には影響しない
+func redactVisits(visits []HistoryVisit, redactDomains []string) []HistoryVisit {
+	if len(redactDomains) == 0 || visits == nil {
+		return visits
+	}
+	redacted := make([]HistoryVisit, len(visits))
+	for i, v := range visits {
+		if isRedactedVisit(v, redactDomains) {
+			v.URL = redactedLabel
+			v.Title = redactedLabel
+			v.Domain = redactedLabel
+		}
+		redacted[i] = v
+	}
+	return redacted
+}
+
+// redactDomainStats はリダクト対象のドメイン名を伏せたコピーを返す
+func redactDomainStats(stats []DomainStats, redactDomains []string) []DomainStats {
+	if len(redactDomains) == 0 || stats == nil {
+		return stats
+	}
+	redacted := make([]DomainStats, len(stats))
+	for i, s := range stats {
+		if domainMatchesList(s.Domain, redactDomains) {
+			s.Domain = redactedLabel
+		}
+		redacted[i] = s
+	}
+	return redacted
+}
+
+// redactDomainPathStats はリダクト対象のドメインについてドメイン名とパスを伏せたコピーを返す
+func redactDomainPathStats(stats []DomainPathStats, redactDomains []string) []DomainPathStats {
+	if len(redactDomains) == 0 || stats == nil {
+		return stats
+	}
+	redacted := make([]DomainPathStats, len(stats))
+	for i, s := range stats {
+		if domainMatchesList(s.Domain, redactDomains) {
+			s.Domain = redactedLabel
+			// パスの内訳は出さず、合計値だけ残す
+			s.Paths = nil
+			s.HasPaths = false
+			s.OtherCount = 0
+		}
+		redacted[i] = s
+	}
+	return redacted
+}
+
+// redactContentStats はリダクト対象のURL・タイトル・パスを伏せたコピーを返す
+func redactContentStats(contents []ContentStats, redactDomains []string) []ContentStats {
+	if len(redactDomains) == 0 || contents == nil {
+		return contents
+	}
+	redacted := make([]ContentStats, len(contents))
+	for i, c := range contents {
+		if domainMatchesList(extractDomain(c.URL), redactDomains) {
+			c.URL = redactedLabel
+			c.Title = redactedLabel
+			c.Path = redactedLabel
+		}
+		redacted[i] = c
+	}
+	return redacted
+}
+
+// redactResult は分析結果の一覧部分をリダクトしたコピーを返す
+// 総訪問数や時間帯・日別統計はそのまま残す
+func redactResult(result AnalysisResult, redactDomains []string) AnalysisResult {
+	if len(redactDomains) == 0 {
+		return result
+	}
+	redacted := result
+	redacted.RecentVisits = redactVisits(result.RecentVisits, redactDomains)
+	redacted.DomainStats = redactDomainStats(result.DomainStats, redactDomains)
+	redacted.DomainPathStats = redactDomainPathStats(result.DomainPathStats, redactDomains)
+	redacted.TitleClusters = redactTitleClusters(result.TitleClusters, redactDomains)
+	redacted.Videos = redactVideoReport(result.Videos, redactDomains)
+	redacted.GitHub = redactGitHubReport(result.GitHub, redactDomains)
+	redacted.Docs = redactDocsReport(result.Docs, redactDomains)
+	redacted.LocalHosts = redactLocalHostStats(result.LocalHosts, redactDomains)
+	redacted.HourlyProfiles = redactHourlyProfiles(result.HourlyProfiles, redactDomains)
+	return redacted
+}
+
+// removeRedactedDomains はドメイン一覧からリダクト対象を取り除く
+// 選択肢として表示するだけの一覧なので、伏せ字ではなく除外する
+func removeRedactedDomains(domains []string, redactDomains []string) []string {
+	if len(redactDomains) == 0 {
+		return domains
+	}
+	var kept []string
+	for _, d := range domains {
+		if !domainMatchesList(d, redactDomains) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestRedactVisits(t *testing.T) {
+	visits := []HistoryVisit{
+		{URL: "https://bank.example.com/account", Title: "My Account", Domain: "bank.example"},
+		{URL: "https://github.com/test", Title: "GitHub", Domain: "github"},
+	}
+
+	redacted := redactVisits(visits, []string{"example.com"})
+	if redacted[0].URL != redactedLabel || redacted[0].Title != redactedLabel || redacted[0].Domain != redactedLabel {
+		t.Errorf("リダクト対象が伏せられていない: %+v", redacted[0])
+	}
+	if redacted[1].URL != "https://github.com/test" {
+		t.Errorf("対象外の訪問が変更された: %+v", redacted[1])
+	}
+	if visits[0].URL != "https://bank.example.com/account" {
+		t.Error("元のスライスが変更された")
+	}
+	if len(redactVisits(visits, nil)) != 2 {
+		t.Error("リストが空の場合はそのまま返すべき")
+	}
+}
+
+func TestRedactResultKeepsCounts(t *testing.T) {
+	result := AnalysisResult{
+		TotalVisits: 30,
+		DomainStats: []DomainStats{
+			{Domain: "secret.com", VisitCount: 20},
+			{Domain: "github.com", VisitCount: 10},
+		},
+	}
+
+	redacted := redactResult(result, []string{"secret.com"})
+	if redacted.TotalVisits != 30 {
+		t.Errorf("TotalVisits = %d, want 30", redacted.TotalVisits)
+	}
+	if redacted.DomainStats[0].Domain != redactedLabel || redacted.DomainStats[0].VisitCount != 20 {
+		t.Errorf("DomainStats[0] = %+v, want [redacted] with 20 visits", redacted.DomainStats[0])
+	}
+	if redacted.DomainStats[1].Domain != "github.com" {
+		t.Errorf("DomainStats[1].Domain = %s, want github.com", redacted.DomainStats[1].Domain)
+	}
+}
+
+func TestRedactDomainPathStats(t *testing.T) {
+	stats := []DomainPathStats{
+		{Domain: "secret.com", TotalCount: 5, HasPaths: true, Paths: []PathStats{{Path: "/private", VisitCount: 5}}},
+	}
+	redacted := redactDomainPathStats(stats, []string{"secret.com"})
+	if redacted[0].Domain != redactedLabel || redacted[0].Paths != nil || redacted[0].TotalCount != 5 {
+		t.Errorf("redactDomainPathStats = %+v", redacted[0])
+	}
+}
+
+func TestRemoveRedactedDomains(t *testing.T) {
+	got := removeRedactedDomains([]string{"github", "secret", "youtube"}, []string{"secret"})
+	if len(got) != 2 || got[0] != "github" || got[1] != "youtube" {
+		t.Errorf("removeRedactedDomains = %v", got)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redirectorDomains は短縮URL・リンクの中継ページとして使われる代表的なドメイン（サブドメインにもマッチ）
+var redirectorDomains = []string{
+	"t.co",
+	"bit.ly",
+	"goo.gl",
+	"ow.ly",
+	"buff.ly",
+	"dlvr.it",
+	"ift.tt",
+	"trib.al",
+	"tinyurl.com",
+	"is.gd",
+	"t.ly",
+	"x.gd",
+	"shorturl.at",
+	"rebrand.ly",
+	"lnkd.in",
+	"amzn.to",
+	"amzn.asia",
+	"youtu.be",
+	"htn.to",
+	"nico.ms",
+	"l.facebook.com",
+	"lm.facebook.com",
+	"l.instagram.com",
+	"l.messenger.com",
+	"l.threads.net",
+	"out.reddit.com",
+	"href.li",
+	"news.google.com",
+	"feedproxy.google.com",
+	"safelinks.protection.outlook.com",
+	"urldefense.com",
+	"slack-redir.net",
+	"away.vk.com",
+}
+
+// redirectorPaths はクエリに転送先のURLを含む場合に中継ページとみなすパス（google.com/url?q=... など）
+var redirectorPaths = map[string]bool{
+	"/url": true, "/l.php": true, "/redirect": true, "/out": true, "/away": true, "/link": true, "/r": true,
+}
+
+// maxRedirectHops は転送先をたどる最大の段数（短縮URLの先がさらに短縮URLの場合など）
+const maxRedirectHops = 5
+
+// isRedirectorHost はホスト名が短縮URL・リンクの中継ページのドメインか
+func isRedirectorHost(host string) bool {
+	return domainMatchesList(strings.ToLower(host), redirectorDomains)
+}
+
+// isRedirectorURL はURLが短縮URL・リンクの中継ページか
+func isRedirectorURL(rawURL string) bool {
+	if isRedirectorHost(extractDomain(rawURL)) {
+		return true
+	}
+	_, ok := embeddedRedirectTarget(rawURL)
+	return ok
+}
+
+// embeddedRedirectTarget は中継ページのURLのクエリに含まれる転送先のURLを返す
+// パスが redirectorPaths のいずれかで、http(s) のURLを値に持つパラメータがある場合のみ
+func embeddedRedirectTarget(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !redirectorPaths[u.Path] {
+		return "", false
+	}
+	for _, values := range u.Query() {
+		for _, v := range values {
+			if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// getRedirectDestinations は中継ページのURLごとに、最終的な転送先のドメインを返す
+// 転送先は次の順で決める:
+//  1. URLのクエリに含まれる転送先（google.com/url?q=... など）
+//  2. Safari が記録したリダイレクト先の訪問（history_visits.redirect_destination）
+//  3. redirectHopWindow 以内に続いた別ドメインへの訪問（パスのあるURLのみ）
+func getRedirectDestinations(db dbQuerier) (map[string]string, error) {
+	hasDestination, err := hasColumn(db, "history_visits", "redirect_destination")
+	if err != nil {
+		return nil, err
+	}
+	destColumn := "NULL"
+	if hasDestination {
+		destColumn = "hv.redirect_destination"
+	}
+	rows, err := db.Query(`
+		SELECT hv.id, hi.url, hv.visit_time, ` + destColumn + `
+		FROM history_visits hv
+		JOIN history_items hi ON hv.history_item = hi.id
+		ORDER BY hv.visit_time, hv.id`)
+	if err != nil {
+		return nil, fmt.Errorf("リダイレクトの取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	next := make(map[string]string)   // 中継ページのURL → 転送先のURL
+	waiting := make(map[int64]string) // リダイレクト先の訪問ID → 中継ページのURL
+	var pendingURL string             // 転送先がまだ決まっていない直前の中継ページ
+	var pendingTime time.Time
+
+	for rows.Next() {
+		var id int64
+		var rawURL string
+		var visitTime float64
+		var dest sql.NullInt64
+		if err := rows.Scan(&id, &rawURL, &visitTime, &dest); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		t := convertCoreDataTimestamp(visitTime)
+
+		if src, ok := waiting[id]; ok {
+			if next[src] == "" {
+				next[src] = rawURL
+			}
+			delete(waiting, id)
+		}
+		if pendingURL != "" {
+			if next[pendingURL] == "" && t.Sub(pendingTime) <= redirectHopWindow &&
+				extractDomain(rawURL) != extractDomain(pendingURL) {
+				next[pendingURL] = rawURL
+			}
+			pendingURL = ""
+		}
+
+		if !isRedirectorURL(rawURL) {
+			continue
+		}
+		if target, ok := embeddedRedirectTarget(rawURL); ok {
+			next[rawURL] = target
+			continue
+		}
+		if dest.Valid {
+			waiting[dest.Int64] = rawURL
+		}
+		if extractPath(rawURL) != "/" {
+			pendingURL, pendingTime = rawURL, t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	destinations := make(map[string]string, len(next))
+	for src := range next {
+		u := src
+		for i := 0; i < maxRedirectHops; i++ {
+			n, ok := next[u]
+			if !ok {
+				break
+			}
+			u = n
+		}
+		if domain := extractDomain(u); domain != "" {
+			destinations[src] = domain
+		}
+	}
+	return destinations, nil
+}
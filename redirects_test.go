@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestIsRedirectorURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://t.co/abc", true},
+		{"https://bit.ly/xyz", true},
+		{"https://l.facebook.com/l.php?u=https%3A%2F%2Fexample.com%2F", true},
+		{"https://news.google.com/articles/CBMi", true},
+		{"https://www.google.com/url?q=https://go.dev/&sa=D", true},
+		{"https://www.google.com/search?q=https://go.dev/", false},
+		{"https://accounts.example.com/login?return_to=https://example.com/", false},
+		{"https://github.com/golang/go", false},
+	}
+	for _, tt := range tests {
+		if got := isRedirectorURL(tt.url); got != tt.want {
+			t.Errorf("isRedirectorURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGetRedirectDestinations(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec(`
+		ALTER TABLE history_visits ADD COLUMN redirect_destination INTEGER;
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://t.co/abc', NULL, 2),
+		(2, 'https://example.com/article', NULL, 2),
+		(3, 'https://www.google.com/url?q=https://go.dev/doc/&sa=D', 'google', 1),
+		(4, 'https://bit.ly/rec', NULL, 1),
+		(5, 'https://github.com/golang/go', 'github', 5),
+		(6, 'https://t.co/chain', NULL, 1),
+		(7, 'https://bit.ly/next', NULL, 1),
+		(8, 'https://pkg.go.dev/net/http', NULL, 1),
+		(9, 'https://news.google.com/', NULL, 1),
+		(10, 'https://t.co/slow', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title, redirect_destination) VALUES
+		(1, 1, 757418400, '', NULL),
+		(2, 2, 757418401, 'Article', NULL),
+		(3, 3, 757418500, '', NULL),
+		(4, 4, 757418600, '', 6),
+		(5, 6, 757418700, '', NULL),
+		(6, 5, 757418610, 'Go', NULL),
+		(7, 7, 757418701, '', NULL),
+		(8, 8, 757418702, 'http', NULL),
+		(9, 9, 757418800, 'News', NULL),
+		(10, 2, 757418801, 'Article', NULL),
+		(11, 10, 757418900, '', NULL),
+		(12, 2, 757418960, 'Article', NULL);
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	got, err := getRedirectDestinations(db)
+	if err != nil {
+		t.Fatalf("getRedirectDestinations失敗: %v", err)
+	}
+	want := map[string]string{
+		"https://t.co/abc": "example.com",
+		"https://www.google.com/url?q=https://go.dev/doc/&sa=D": "go.dev",
+		// Safari のリダイレクト先は時間が空いていても使う
+		"https://bit.ly/rec": "github.com",
+		// 短縮URLの先の短縮URLもたどる
+		"https://t.co/chain":  "pkg.go.dev",
+		"https://bit.ly/next": "pkg.go.dev",
+	}
+	if len(got) != len(want) {
+		t.Errorf("getRedirectDestinations() = %v", got)
+	}
+	for src, domain := range want {
+		if got[src] != domain {
+			t.Errorf("destinations[%q] = %q, want %q", src, got[src], domain)
+		}
+	}
+
+	// ドメイン別統計では転送先のドメインに数える
+	stats, err := getDomainStats(db, 0, SearchFilter{CollapseRedirects: true})
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, s := range stats {
+		counts[s.Domain] = s.VisitCount
+	}
+	if counts["example.com"] != 4 || counts["github.com"] != 6 || counts["t.co"] != 1 || counts["bit.ly"] != 0 {
+		t.Errorf("getDomainStats(CollapseRedirects) = %v", counts)
+	}
+}
+
+func TestGetRedirectDestinationsWithoutColumn(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	got, err := getRedirectDestinations(db)
+	if err != nil {
+		t.Fatalf("getRedirectDestinations失敗: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("getRedirectDestinations() = %v, want empty", got)
+	}
+}
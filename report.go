@@ -0,0 +1,728 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reportsDirName はレポート定義を置くディレクトリ（設定ディレクトリに置く）
+const reportsDirName = "reports"
+
+// reportFileExt はレポート定義ファイルの拡張子
+const reportFileExt = ".yaml"
+
+// レポート定義の dimensions に指定できる軸（-group-by の軸に加えて）
+const (
+	ReportDimensionDate  = "date"
+	ReportDimensionMonth = "month"
+)
+
+// レポート定義の metrics に指定できる集計値
+const (
+	ReportMetricVisits        = "visits"
+	ReportMetricShare         = "share"
+	ReportMetricUniqueURLs    = "unique_urls"
+	ReportMetricUniqueDomains = "unique_domains"
+	ReportMetricActiveDays    = "active_days"
+	ReportMetricFirstVisit    = "first_visit"
+	ReportMetricLastVisit     = "last_visit"
+)
+
+// reportMetrics はレポート定義の metrics に指定できる集計値
+var reportMetrics = []string{
+	ReportMetricVisits, ReportMetricShare, ReportMetricUniqueURLs, ReportMetricUniqueDomains,
+	ReportMetricActiveDays, ReportMetricFirstVisit, ReportMetricLastVisit,
+}
+
+// reportCountMetrics は pivot の各列に並べられる集計値（数で表せるもの）
+var reportCountMetrics = []string{
+	ReportMetricVisits, ReportMetricShare, ReportMetricUniqueURLs, ReportMetricUniqueDomains, ReportMetricActiveDays,
+}
+
+// ReportDefinition は ~/.config/hist/reports/<名前>.yaml のレポート定義
+//
+//	title: 平日の開発サイト
+//	dimensions: [domain]
+//	pivot: weekday
+//	metrics: [visits]
+//	filters:
+//	  days: 30
+//	  categories: [開発]
+//	sort: [-visits]
+//	limit: 10
+type ReportDefinition struct {
+	Title string `yaml:"title"`
+	// Dimensions は行をまとめる軸（domain・category・source・weekday・hour・date・month）
+	Dimensions []string `yaml:"dimensions"`
+	// Pivot は値を列に並べる軸（省略すると集計値を列に並べる）
+	Pivot   string        `yaml:"pivot"`
+	Metrics []string      `yaml:"metrics"`
+	Filters ReportFilters `yaml:"filters"`
+	// Sort は並べ替える列（先頭に - を付けると降順）。省略すると visits の多い順か軸の順
+	Sort  []string `yaml:"sort"`
+	Limit int      `yaml:"limit"`
+}
+
+// ReportFilters はレポート定義の filters（省略した条件では絞り込まない）
+type ReportFilters struct {
+	// Days は実行時から過去N日間に絞り込む
+	Days   int    `yaml:"days"`
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Search string `yaml:"search"`
+	// Domains は -domain と同じく一致するドメインに絞り込み、Exclude はサブドメインも含めて除く
+	Domains    []string `yaml:"domains"`
+	Exclude    []string `yaml:"exclude"`
+	Categories []string `yaml:"categories"`
+	Sources    []string `yaml:"sources"`
+	// Weekdays は曜日の略称（sun〜sat）、Hours は時（0〜23）
+	Weekdays []string `yaml:"weekdays"`
+	Hours    []int    `yaml:"hours"`
+}
+
+// ReportResult はレポートの実行結果
+// Rows の各行は Columns と同じ順の値（軸は文字列、集計値は数、first_visit・last_visit は時刻）
+type ReportResult struct {
+	Name    string   `json:"name"`
+	Title   string   `json:"title,omitempty"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// getReportsDir はレポート定義のディレクトリのパスを返す
+func getReportsDir() (string, error) {
+	return getConfigFilePath(reportsDirName)
+}
+
+// getReportPath はレポート定義のファイルパスを返す
+func getReportPath(name string) (string, error) {
+	if !queryNamePattern.MatchString(name) {
+		return "", fmt.Errorf("レポート名には英数字・ハイフン・アンダースコアのみ使用できます: %s", name)
+	}
+	dir, err := getReportsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+reportFileExt), nil
+}
+
+// ListReports はレポート定義の名前の一覧を返す
+func ListReports() ([]string, error) {
+	dir, err := getReportsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("レポート一覧の取得に失敗: %w", err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != reportFileExt {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), reportFileExt))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadReport は名前を指定してレポート定義を読み込む
+func LoadReport(name string) (ReportDefinition, error) {
+	path, err := getReportPath(name)
+	if err != nil {
+		return ReportDefinition{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ReportDefinition{}, fmt.Errorf("レポート定義が見つかりません: %s（%s）", name, path)
+		}
+		return ReportDefinition{}, fmt.Errorf("レポート定義の読み込みに失敗: %w", err)
+	}
+	return parseReportDefinition(data)
+}
+
+// parseReportDefinition はレポート定義の YAML を解析して検証する（知らないキーはエラーにする）
+func parseReportDefinition(data []byte) (ReportDefinition, error) {
+	var def ReportDefinition
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&def); err != nil && !errors.Is(err, io.EOF) {
+		return ReportDefinition{}, fmt.Errorf("レポート定義の解析に失敗: %w", err)
+	}
+	if err := def.validate(); err != nil {
+		return ReportDefinition{}, err
+	}
+	return def, nil
+}
+
+// isReportDimension は name がレポートの軸かを返す
+func isReportDimension(name string) bool {
+	return name == ReportDimensionDate || name == ReportDimensionMonth || slices.Contains(groupByNames, name)
+}
+
+// validate はレポート定義の軸・集計値・並べ替え・絞り込みを検証する
+func (def *ReportDefinition) validate() error {
+	dimensionNames := strings.Join(append(slices.Clone(groupByNames), ReportDimensionDate, ReportDimensionMonth), "・")
+	if len(def.Dimensions) == 0 {
+		return fmt.Errorf("dimensions に軸を1つ以上指定してください（%s）", dimensionNames)
+	}
+	for _, d := range append(slices.Clone(def.Dimensions), def.Pivot) {
+		if d != "" && !isReportDimension(d) {
+			return fmt.Errorf("不明な軸です: %s（%s）", d, dimensionNames)
+		}
+	}
+	if slices.Contains(def.Dimensions, def.Pivot) {
+		return fmt.Errorf("pivot の軸は dimensions に含めないでください: %s", def.Pivot)
+	}
+	if len(def.Metrics) == 0 {
+		def.Metrics = []string{ReportMetricVisits}
+	}
+	for _, m := range def.Metrics {
+		if !slices.Contains(reportMetrics, m) {
+			return fmt.Errorf("不明な集計値です: %s（%s）", m, strings.Join(reportMetrics, "・"))
+		}
+	}
+	if def.Pivot != "" && (len(def.Metrics) != 1 || !slices.Contains(reportCountMetrics, def.Metrics[0])) {
+		return fmt.Errorf("pivot を指定した場合は metrics に %s のいずれか1つを指定してください", strings.Join(reportCountMetrics, "・"))
+	}
+	for _, s := range def.Sort {
+		column := strings.TrimPrefix(s, "-")
+		if !slices.Contains(def.Dimensions, column) && (def.Pivot != "" || !slices.Contains(def.Metrics, column)) {
+			return fmt.Errorf("sort には dimensions か metrics の列を指定してください（pivot を指定した場合は dimensions のみ）: %s", s)
+		}
+	}
+	if def.Limit < 0 {
+		return errors.New("limit は0以上で指定してください")
+	}
+
+	f := def.Filters
+	if f.Days < 0 {
+		return errors.New("filters.days は0以上で指定してください")
+	}
+	for _, d := range []string{f.From, f.To} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(TimeFormatDate, d); err != nil {
+			return fmt.Errorf("filters の日付の形式が不正です（YYYY-MM-DD）: %s", d)
+		}
+	}
+	for _, w := range f.Weekdays {
+		if parseWeekday(strings.ToLower(w)) == -1 {
+			return fmt.Errorf("filters.weekdays には曜日（sun〜sat）を指定してください: %s", w)
+		}
+	}
+	for _, h := range f.Hours {
+		if h < 0 || h > 23 {
+			return fmt.Errorf("filters.hours には0〜23を指定してください: %d", h)
+		}
+	}
+	return nil
+}
+
+// needsCategories はカテゴリ（categories.txt）を使うレポートかを返す
+func (def ReportDefinition) needsCategories() bool {
+	return slices.Contains(def.Dimensions, GroupByCategory) || def.Pivot == GroupByCategory || len(def.Filters.Categories) > 0
+}
+
+// needsSources は訪問のソースを使うレポートかを返す
+func (def ReportDefinition) needsSources() bool {
+	return slices.Contains(def.Dimensions, GroupBySource) || def.Pivot == GroupBySource || len(def.Filters.Sources) > 0
+}
+
+// searchFilter は filters のうちクエリで絞り込める条件を SearchFilter にする
+func (f ReportFilters) searchFilter(now time.Time) SearchFilter {
+	filter := SearchFilter{Keyword: f.Search, Domains: f.Domains}
+	if f.From != "" {
+		filter.From, _ = parseLocalDate(f.From)
+	}
+	if f.To != "" {
+		filter.To, _ = parseLocalDate(f.To)
+	}
+	if f.Days > 0 {
+		if from := now.AddDate(0, 0, -f.Days); from.After(filter.From) {
+			filter.From = from
+		}
+	}
+	return filter
+}
+
+// reportAxis はレポートの軸1つ（訪問の値と、値の並び順）
+type reportAxis struct {
+	name string
+	// keys は決まった順に並べる軸（曜日・時間帯）の値。nil なら文字列の順に並べる
+	keys  []string
+	keyOf func(v groupVisit) string
+}
+
+// newReportAxis はレポートの軸を作成する（date・month 以外は -group-by と同じ値）
+func newReportAxis(name string, opts groupOptions) (reportAxis, error) {
+	switch name {
+	case ReportDimensionDate:
+		return reportAxis{name: name, keyOf: func(v groupVisit) string { return v.time.Format(TimeFormatDate) }}, nil
+	case ReportDimensionMonth:
+		return reportAxis{name: name, keyOf: func(v groupVisit) string { return v.time.Format("2006-01") }}, nil
+	}
+	dim, err := newGroupDimension(name, opts)
+	if err != nil {
+		return reportAxis{}, err
+	}
+	return reportAxis{name: name, keys: dim.keys, keyOf: dim.keyOf}, nil
+}
+
+// less は軸の値 a が b より前に並ぶかを返す
+func (a reportAxis) less(x, y string) bool {
+	if a.keys != nil {
+		return slices.Index(a.keys, x) < slices.Index(a.keys, y)
+	}
+	return x < y
+}
+
+// reportAggregate は行（とピボットの列）ごとの集計の途中経過
+type reportAggregate struct {
+	visits  int
+	urls    map[string]bool
+	domains map[string]bool
+	days    map[string]bool
+	first   time.Time
+	last    time.Time
+}
+
+// add は訪問を集計に加える
+func (agg *reportAggregate) add(v groupVisit) {
+	if agg.urls == nil {
+		agg.urls, agg.domains, agg.days = make(map[string]bool), make(map[string]bool), make(map[string]bool)
+		agg.first, agg.last = v.time, v.time
+	}
+	agg.visits++
+	agg.urls[v.url] = true
+	agg.domains[extractDomain(v.url)] = true
+	agg.days[v.time.Format(TimeFormatDate)] = true
+	if v.time.Before(agg.first) {
+		agg.first = v.time
+	}
+	if v.time.After(agg.last) {
+		agg.last = v.time
+	}
+}
+
+// value は集計値 metric を返す（total は share の分母）
+func (agg *reportAggregate) value(metric string, total int) any {
+	switch metric {
+	case ReportMetricVisits:
+		return agg.visits
+	case ReportMetricShare:
+		if total == 0 {
+			return 0.0
+		}
+		return float64(agg.visits) / float64(total) * 100
+	case ReportMetricUniqueURLs:
+		return len(agg.urls)
+	case ReportMetricUniqueDomains:
+		return len(agg.domains)
+	case ReportMetricActiveDays:
+		return len(agg.days)
+	case ReportMetricFirstVisit:
+		return agg.first
+	case ReportMetricLastVisit:
+		return agg.last
+	}
+	return nil
+}
+
+// runReport はレポート定義に従って訪問を集計する
+func runReport(db dbQuerier, name string, def ReportDefinition, opts groupOptions, now time.Time) (ReportResult, error) {
+	axes := make([]reportAxis, 0, len(def.Dimensions))
+	for _, d := range def.Dimensions {
+		axis, err := newReportAxis(d, opts)
+		if err != nil {
+			return ReportResult{}, err
+		}
+		axes = append(axes, axis)
+	}
+	var pivot *reportAxis
+	if def.Pivot != "" {
+		axis, err := newReportAxis(def.Pivot, opts)
+		if err != nil {
+			return ReportResult{}, err
+		}
+		pivot = &axis
+	}
+	keep, err := def.Filters.visitFilter(opts)
+	if err != nil {
+		return ReportResult{}, err
+	}
+
+	query, args := NewQueryBuilder(groupVisitsBaseQuery).WithFilter(def.Filters.searchFilter(now)).Build()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return ReportResult{}, fmt.Errorf("レポートの集計に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	type reportRow struct {
+		keys  []string
+		cells map[string]*reportAggregate
+	}
+	groups := make(map[string]*reportRow)
+	pivotKeys := make(map[string]bool)
+	total := 0
+	for rows.Next() {
+		var v groupVisit
+		var visitTime float64
+		if err := rows.Scan(&v.id, &visitTime, &v.url); err != nil {
+			return ReportResult{}, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		v.time = convertCoreDataTimestamp(visitTime)
+		if !keep(v) {
+			continue
+		}
+		keys := make([]string, len(axes))
+		for i, axis := range axes {
+			keys[i] = axis.keyOf(v)
+		}
+		id := strings.Join(keys, "\x00")
+		row := groups[id]
+		if row == nil {
+			row = &reportRow{keys: keys, cells: make(map[string]*reportAggregate)}
+			groups[id] = row
+		}
+		cell := ""
+		if pivot != nil {
+			cell = pivot.keyOf(v)
+			pivotKeys[cell] = true
+		}
+		if row.cells[cell] == nil {
+			row.cells[cell] = &reportAggregate{}
+		}
+		row.cells[cell].add(v)
+		total++
+	}
+	if err := rows.Err(); err != nil {
+		return ReportResult{}, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	result := ReportResult{Name: name, Title: def.Title, Columns: slices.Clone(def.Dimensions), Rows: [][]any{}}
+	var cells []string
+	if pivot != nil {
+		if pivot.keys != nil {
+			cells = pivot.keys
+		} else {
+			for k := range pivotKeys {
+				cells = append(cells, k)
+			}
+			sort.Strings(cells)
+		}
+		result.Columns = append(result.Columns, cells...)
+	} else {
+		cells = []string{""}
+		result.Columns = append(result.Columns, def.Metrics...)
+	}
+
+	for _, row := range groups {
+		values := make([]any, 0, len(result.Columns))
+		for _, k := range row.keys {
+			values = append(values, k)
+		}
+		for _, cell := range cells {
+			agg := row.cells[cell]
+			if agg == nil {
+				agg = &reportAggregate{}
+			}
+			if pivot != nil {
+				values = append(values, agg.value(def.Metrics[0], total))
+				continue
+			}
+			for _, m := range def.Metrics {
+				values = append(values, agg.value(m, total))
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+
+	sortReportRows(result, def, axes)
+	if def.Limit > 0 && len(result.Rows) > def.Limit {
+		result.Rows = result.Rows[:def.Limit]
+	}
+	return result, nil
+}
+
+// visitFilter は filters のうちクエリで絞り込めない条件（除外ドメイン・曜日・時・カテゴリ・ソース）で訪問を残すかを判定する関数を返す
+func (f ReportFilters) visitFilter(opts groupOptions) (func(v groupVisit) bool, error) {
+	weekdays := make(map[time.Weekday]bool)
+	for _, w := range f.Weekdays {
+		weekdays[time.Weekday(parseWeekday(strings.ToLower(w)))] = true
+	}
+	hours := make(map[int]bool)
+	for _, h := range f.Hours {
+		hours[h] = true
+	}
+	var category, source func(v groupVisit) string
+	if len(f.Categories) > 0 {
+		dim, err := newGroupDimension(GroupByCategory, opts)
+		if err != nil {
+			return nil, err
+		}
+		category = dim.keyOf
+	}
+	if len(f.Sources) > 0 {
+		dim, err := newGroupDimension(GroupBySource, opts)
+		if err != nil {
+			return nil, err
+		}
+		source = dim.keyOf
+	}
+	return func(v groupVisit) bool {
+		switch {
+		case len(f.Exclude) > 0 && domainMatchesList(extractDomain(v.url), f.Exclude):
+			return false
+		case len(weekdays) > 0 && !weekdays[v.time.Weekday()]:
+			return false
+		case len(hours) > 0 && !hours[v.time.Hour()]:
+			return false
+		case category != nil && !slices.Contains(f.Categories, category(v)):
+			return false
+		case source != nil && !slices.Contains(f.Sources, source(v)):
+			return false
+		}
+		return true
+	}, nil
+}
+
+// sortReportRows は sort の指定順に行を並べ替える
+// 指定がなければ visits の多い順（visits がないかピボットなら軸の順）に並べ、最後は軸の順で決める
+func sortReportRows(result ReportResult, def ReportDefinition, axes []reportAxis) {
+	sortKeys := def.Sort
+	if len(sortKeys) == 0 && def.Pivot == "" && slices.Contains(def.Metrics, ReportMetricVisits) {
+		sortKeys = []string{"-" + ReportMetricVisits}
+	}
+	compare := func(column int, x, y any) int {
+		if column < len(axes) {
+			a, b := x.(string), y.(string)
+			switch {
+			case a == b:
+				return 0
+			case axes[column].less(a, b):
+				return -1
+			}
+			return 1
+		}
+		switch a := x.(type) {
+		case int:
+			return a - y.(int)
+		case float64:
+			b := y.(float64)
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+		case time.Time:
+			return a.Compare(y.(time.Time))
+		}
+		return 0
+	}
+	sort.SliceStable(result.Rows, func(i, j int) bool {
+		for _, s := range sortKeys {
+			column := slices.Index(result.Columns, strings.TrimPrefix(s, "-"))
+			c := compare(column, result.Rows[i][column], result.Rows[j][column])
+			if strings.HasPrefix(s, "-") {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		for column := range axes {
+			if c := compare(column, result.Rows[i][column], result.Rows[j][column]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// formatReportValue はレポートの値を表・CSV 用の文字列にする
+func formatReportValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return fmt.Sprintf("%.1f", v)
+	case time.Time:
+		return v.Format(TimeFormatFull)
+	}
+	return fmt.Sprint(v)
+}
+
+// reportTableRows はレポートの行を文字列にする（右揃えにする列も返す）
+func reportTableRows(result ReportResult, dimensions int) ([][]string, []int) {
+	var rows [][]string
+	for _, r := range result.Rows {
+		cells := make([]string, len(r))
+		for i, v := range r {
+			cells[i] = formatReportValue(v)
+		}
+		rows = append(rows, cells)
+	}
+	var rightAligned []int
+	for i := dimensions; i < len(result.Columns); i++ {
+		rightAligned = append(rightAligned, i)
+	}
+	return rows, rightAligned
+}
+
+// printReport はレポートを表にして表示する
+func printReport(w io.Writer, result ReportResult, dimensions int) {
+	title := result.Title
+	if title == "" {
+		title = result.Name
+	}
+	_, _ = fmt.Fprintf(w, "📋 %s\n", title)
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	if len(result.Rows) == 0 {
+		_, _ = fmt.Fprintln(w, "該当する訪問はありません")
+		return
+	}
+	rows, rightAligned := reportTableRows(result, dimensions)
+	_, _ = fmt.Fprintln(w, renderTable(result.Columns, rows, rightAligned, false))
+}
+
+// writeReportCSV はレポートを CSV で書き出す（1行目は列名）
+func writeReportCSV(w io.Writer, result ReportResult, dimensions int) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(result.Columns); err != nil {
+		return err
+	}
+	rows, _ := reportTableRows(result, dimensions)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// runReportCommand は hist report サブコマンドを実行する
+func runReportCommand(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	list := fs.Bool("list", false, "レポート定義の一覧を表示")
+	file := fs.String("file", "", "設定ディレクトリの代わりに指定したファイルのレポート定義を実行")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	csvOutput := fs.Bool("csv", false, "CSV形式で出力")
+	source := fs.String("source", SourceSafari, "履歴DBのソース名（source の軸・絞り込みに使う）")
+	noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist report [オプション] <名前>\n")
+		fmt.Fprintf(fs.Output(), "       hist report -file <レポート定義.yaml>\n")
+		fmt.Fprintf(fs.Output(), "       hist report -list\n\n")
+		fmt.Fprintf(fs.Output(), "  ~/.config/hist/%s/<名前>%s の軸・集計値・絞り込み・並べ替えに従って訪問を集計します\n\n", reportsDirName, reportFileExt)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *jsonOutput && *csvOutput {
+		return errors.New("-json と -csv は同時に指定できません")
+	}
+
+	if *list {
+		names, err := ListReports()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("レポート定義はありません")
+			return nil
+		}
+		fmt.Println("レポート定義:")
+		for _, n := range names {
+			fmt.Printf("  - %s\n", n)
+		}
+		return nil
+	}
+
+	var name string
+	var def ReportDefinition
+	switch {
+	case *file != "":
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return fmt.Errorf("レポート定義の読み込みに失敗: %w", err)
+		}
+		if def, err = parseReportDefinition(data); err != nil {
+			return err
+		}
+		name = strings.TrimSuffix(filepath.Base(*file), filepath.Ext(*file))
+	case fs.NArg() == 1:
+		var err error
+		name = fs.Arg(0)
+		if def, err = LoadReport(name); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return errors.New("実行するレポート名を1つ指定してください")
+	}
+
+	var opts groupOptions
+	if def.needsCategories() {
+		var err error
+		if opts.categories, err = LoadDomainCategories(); err != nil {
+			return err
+		}
+	}
+	if !*noRedact {
+		var err error
+		if opts.redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	opts.sources = &visitSources{label: *source}
+	if def.needsSources() {
+		if opts.sources, err = loadVisitSources(db, *source); err != nil {
+			return err
+		}
+	}
+	result, err := runReport(db, name, def, opts, time.Now())
+	if err != nil {
+		return err
+	}
+	switch {
+	case *jsonOutput:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case *csvOutput:
+		return writeReportCSV(os.Stdout, result, len(def.Dimensions))
+	}
+	printReport(os.Stdout, result, len(def.Dimensions))
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReportDefinition(t *testing.T) {
+	def, err := parseReportDefinition([]byte(`
+title: 開発サイト
+dimensions: [domain]
+filters:
+  days: 30
+  weekdays: [mon, Fri]
+sort: [domain]
+`))
+	if err != nil {
+		t.Fatalf("parseReportDefinition: %v", err)
+	}
+	// metrics を省略すると visits
+	if def.Title != "開発サイト" || len(def.Metrics) != 1 || def.Metrics[0] != ReportMetricVisits || def.Filters.Days != 30 {
+		t.Errorf("レポート定義 = %+v", def)
+	}
+
+	for _, tt := range []struct {
+		yaml string
+		want string
+	}{
+		{"metrics: [visits]", "dimensions"},
+		{"dimensions: [browser]", "不明な軸"},
+		{"dimensions: [domain]\nmetrics: [clicks]", "不明な集計値"},
+		{"dimensions: [domain]\npivot: domain", "pivot の軸"},
+		{"dimensions: [domain]\npivot: weekday\nmetrics: [visits, unique_urls]", "pivot を指定した場合"},
+		{"dimensions: [domain]\npivot: weekday\nmetrics: [last_visit]", "pivot を指定した場合"},
+		{"dimensions: [domain]\nsort: [-unique_urls]", "sort"},
+		{"dimensions: [domain]\nfilters:\n  hours: [24]", "filters.hours"},
+		{"dimensions: [domain]\nfilters:\n  weekdays: [someday]", "filters.weekdays"},
+		{"dimensions: [domain]\nfilters:\n  from: 2025/01/01", "日付の形式"},
+		// 綴りを間違えたキーは黙って無視しない
+		{"dimensions: [domain]\nfilter:\n  days: 7", "field filter not found"},
+	} {
+		if _, err := parseReportDefinition([]byte(tt.yaml)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseReportDefinition(%q) = %v, want %q", tt.yaml, err, tt.want)
+		}
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+	opts := groupOptions{
+		sources:    &visitSources{label: SourceSafari},
+		categories: []DomainCategory{{Domain: "github.com", Category: "開発"}, {Domain: "youtube.com", Category: "動画"}},
+	}
+	run := func(yaml string) ReportResult {
+		t.Helper()
+		def, err := parseReportDefinition([]byte(yaml))
+		if err != nil {
+			t.Fatalf("parseReportDefinition: %v", err)
+		}
+		result, err := runReport(db, "test", def, opts, now)
+		if err != nil {
+			t.Fatalf("runReport: %v", err)
+		}
+		return result
+	}
+	format := func(result ReportResult) string {
+		rows, _ := reportTableRows(result, 0)
+		lines := []string{strings.Join(result.Columns, ",")}
+		for _, r := range rows {
+			lines = append(lines, strings.Join(r, ","))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for _, tt := range []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			// 既定は visits の多い順、同数なら軸の順
+			"集計値",
+			"dimensions: [domain]\nmetrics: [visits, share, active_days, last_visit]",
+			"domain,visits,share,active_days,last_visit\n" +
+				"github.com,2,40.0,2,2025-01-02 10:00:00\n" +
+				"youtube.com,2,40.0,2,2025-01-02 11:00:00\n" +
+				"google.com,1,20.0,1,2025-01-01 12:00:00",
+		},
+		{
+			"複数の軸と絞り込み・並べ替え・件数",
+			"dimensions: [category, date]\nfilters:\n  hours: [10, 11]\nsort: [-date, category]\nlimit: 3",
+			"category,date,visits\n動画,2025-01-02,1\n開発,2025-01-02,1\n動画,2025-01-01,1",
+		},
+		{
+			// ピボットの列は曜日の順に全て並べる
+			"ピボット",
+			"dimensions: [domain]\npivot: weekday\nfilters:\n  categories: [開発, 動画]",
+			"domain,日曜,月曜,火曜,水曜,木曜,金曜,土曜\ngithub.com,0,0,0,1,1,0,0\nyoutube.com,0,0,0,1,1,0,0",
+		},
+		{
+			"期間と曜日の絞り込み",
+			"dimensions: [source]\nmetrics: [unique_urls, unique_domains]\nfilters:\n  days: 1\n  weekdays: [thu]",
+			"source,unique_urls,unique_domains\nsafari,2,2",
+		},
+	} {
+		if got := format(run(tt.yaml)); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	result := run("title: ドメイン\ndimensions: [domain]\nfilters:\n  exclude: [google.com]")
+	var text, csv bytes.Buffer
+	printReport(&text, result, 1)
+	if !strings.Contains(text.String(), "📋 ドメイン") || !strings.Contains(text.String(), "github.com") || strings.Contains(text.String(), "google.com") {
+		t.Errorf("テキスト出力:\n%s", text.String())
+	}
+	if err := writeReportCSV(&csv, result, 1); err != nil {
+		t.Fatalf("writeReportCSV: %v", err)
+	}
+	if csv.String() != "domain,visits\ngithub.com,2\nyoutube.com,2\n" {
+		t.Errorf("CSV の出力:\n%s", csv.String())
+	}
+	if got := fmt.Sprint(run("dimensions: [hour]\nfilters:\n  search: nothing-matches").Rows); got != "[]" {
+		t.Errorf("一致しないレポートの行 = %s", got)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retentionFileName は hist 自身のデータの保持期間の設定ファイル
+// 1行に「キー = 値」を書く（期間は 90d・12w・18m・2y。書かない対象は無期限に残す）:
+//
+//	archive = 2y        アーカイブDB（hist archive pull）の訪問
+//	index = 2y          全文検索の索引（hist index build）の訪問
+//	annotations = 2y    タグ・メモ（付けた・最後に更新した日時で判定。スターは残す）
+//	auto = true         起動時に1日1回自動で適用する（既定は hist gc を実行したときだけ削除する）
+const retentionFileName = "retention.txt"
+
+// retentionStampFileName は最後に保持期間を自動で適用した日時を更新日時で記録するファイル（キャッシュディレクトリに置く）
+const retentionStampFileName = "retention.stamp"
+
+// retentionAutoInterval は起動時に保持期間を自動で適用する間隔
+const retentionAutoInterval = 24 * time.Hour
+
+// 保持期間を適用する対象
+const (
+	RetentionTargetArchive     = "archive"
+	RetentionTargetIndex       = "index"
+	RetentionTargetAnnotations = "annotations"
+)
+
+// retentionTargets は hist gc で適用する順の対象
+var retentionTargets = []string{RetentionTargetArchive, RetentionTargetIndex, RetentionTargetAnnotations}
+
+// retentionDeletedLabels は削除件数の種類の表示名
+var retentionDeletedLabels = map[string]string{
+	"visits": "訪問",
+	"urls":   "URL",
+	"tags":   "タグ",
+	"notes":  "メモ",
+}
+
+// retentionPeriodPattern は保持期間の書式（数字と単位 d・w・m・y）
+var retentionPeriodPattern = regexp.MustCompile(`^([0-9]+)([dwmy])$`)
+
+// RetentionPeriod は保持期間（Count 日・週・か月・年）
+type RetentionPeriod struct {
+	Count int
+	Unit  byte
+}
+
+// parseRetentionPeriod は "2y" のような保持期間を解析する
+func parseRetentionPeriod(s string) (RetentionPeriod, error) {
+	m := retentionPeriodPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return RetentionPeriod{}, fmt.Errorf("保持期間は数字と単位（d・w・m・y）で指定してください（例: 90d, 18m, 2y）: %s", s)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return RetentionPeriod{}, fmt.Errorf("保持期間には1以上の数を指定してください: %s", s)
+	}
+	return RetentionPeriod{Count: n, Unit: m[2][0]}, nil
+}
+
+// String は "2y" の形式で返す
+func (p RetentionPeriod) String() string {
+	return strconv.Itoa(p.Count) + string(p.Unit)
+}
+
+// Cutoff は now から保持期間を遡った日時を返す（これより前のデータを削除する）
+func (p RetentionPeriod) Cutoff(now time.Time) time.Time {
+	switch p.Unit {
+	case 'w':
+		return now.AddDate(0, 0, -7*p.Count)
+	case 'm':
+		return now.AddDate(0, -p.Count, 0)
+	case 'y':
+		return now.AddDate(-p.Count, 0, 0)
+	}
+	return now.AddDate(0, 0, -p.Count)
+}
+
+// RetentionPolicy は retention.txt の設定
+type RetentionPolicy struct {
+	// Periods は対象ごとの保持期間（ない対象は無期限）
+	Periods map[string]RetentionPeriod
+	// Auto は起動時に自動で適用するか（auto = true のときだけ。既定は適用しない）
+	Auto bool
+}
+
+// parseRetentionPolicy は retention.txt の行を解析する
+func parseRetentionPolicy(lines []string) (RetentionPolicy, error) {
+	policy := RetentionPolicy{Periods: make(map[string]RetentionPeriod)}
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return RetentionPolicy{}, fmt.Errorf("保持期間の設定は「キー = 値」形式で指定してください: %s", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case RetentionTargetArchive, RetentionTargetIndex, RetentionTargetAnnotations:
+			period, err := parseRetentionPeriod(value)
+			if err != nil {
+				return RetentionPolicy{}, fmt.Errorf("%s: %w", key, err)
+			}
+			policy.Periods[key] = period
+		case "auto":
+			auto, err := strconv.ParseBool(value)
+			if err != nil {
+				return RetentionPolicy{}, fmt.Errorf("auto には true か false を指定してください: %s", value)
+			}
+			policy.Auto = auto
+		default:
+			return RetentionPolicy{}, fmt.Errorf("保持期間の設定に不明なキーがあります: %s", key)
+		}
+	}
+	return policy, nil
+}
+
+// LoadRetentionPolicy は保持期間の設定を読み込む
+func LoadRetentionPolicy() (RetentionPolicy, error) {
+	lines, err := loadDomainListFile(retentionFileName, "保持期間の設定")
+	if err != nil {
+		return RetentionPolicy{}, err
+	}
+	return parseRetentionPolicy(lines)
+}
+
+// RetentionResult は1つの対象に保持期間を適用した結果
+type RetentionResult struct {
+	Target string `json:"target"`
+	// Keep は保持期間（空なら無期限）、Before はこれより前のデータを削除する日時
+	Keep   string    `json:"keep,omitempty"`
+	Before time.Time `json:"before,omitzero"`
+	// Deleted は種類（visits・urls・tags・notes）ごとの削除した件数（-dry-run なら削除する件数）
+	Deleted map[string]int `json:"deleted,omitempty"`
+	// Skipped は適用しなかった理由
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// RetentionReport は hist gc の結果
+type RetentionReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Results []RetentionResult `json:"results"`
+}
+
+// Total は全ての対象の削除件数の合計を返す
+func (r RetentionReport) Total() int {
+	total := 0
+	for _, result := range r.Results {
+		for _, n := range result.Deleted {
+			total += n
+		}
+	}
+	return total
+}
+
+// applyRetention は保持期間より古い hist 自身のデータを削除する（dryRun なら件数だけ数える）
+// index は Safari の履歴DBの全文検索の索引（nil なら索引は対象外）
+func applyRetention(policy RetentionPolicy, index *SearchIndex, now time.Time, dryRun bool) (RetentionReport, error) {
+	report := RetentionReport{DryRun: dryRun}
+	for _, target := range retentionTargets {
+		result := RetentionResult{Target: target}
+		period, ok := policy.Periods[target]
+		if !ok {
+			result.Skipped = "保持期間の設定なし（無期限）"
+			report.Results = append(report.Results, result)
+			continue
+		}
+		result.Keep, result.Before = period.String(), period.Cutoff(now)
+
+		var err error
+		switch target {
+		case RetentionTargetArchive:
+			err = expireArchive(&result, dryRun)
+		case RetentionTargetIndex:
+			if index == nil {
+				result.Skipped = "索引がありません"
+				break
+			}
+			var n int
+			if n, err = index.Expire(result.Before, dryRun); err == nil {
+				result.Deleted = map[string]int{"visits": n}
+			}
+		case RetentionTargetAnnotations:
+			err = expireAnnotations(&result, dryRun)
+		}
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", target, err)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// expireArchive はアーカイブDB（暗号化したものを含む）に保持期間を適用する
+// -dry-run では読み取り専用で開き、暗号化したアーカイブDBを書き直さない
+func expireArchive(result *RetentionResult, dryRun bool) error {
+	path, err := getArchivePath()
+	if err != nil {
+		return err
+	}
+	existing := existingArchivePath(path)
+	if existing == "" {
+		result.Skipped = "アーカイブDBがありません"
+		return nil
+	}
+
+	var visits, urls int
+	if dryRun {
+		archive, err := openDB(existing)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = archive.Close() }()
+		visits, urls, err = expireArchiveVisits(archive, result.Before, true)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err = withArchiveDB(path, false, func(archive *sql.DB) error {
+			var err error
+			visits, urls, err = expireArchiveVisits(archive, result.Before, false)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	result.Deleted = map[string]int{"visits": visits, "urls": urls}
+	return nil
+}
+
+// expireAnnotations はサイドカーDBのタグ・メモに保持期間を適用する
+func expireAnnotations(result *RetentionResult, dryRun bool) error {
+	path, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+	sidecar, err := openExistingSidecarDB(path)
+	if err != nil {
+		return err
+	}
+	if sidecar == nil {
+		result.Skipped = "タグ・メモがありません"
+		return nil
+	}
+	defer func() { _ = sidecar.Close() }()
+	tags, notes, err := sidecar.ExpireAnnotations(result.Before, dryRun)
+	if err != nil {
+		return err
+	}
+	result.Deleted = map[string]int{"tags": tags, "notes": notes}
+	return nil
+}
+
+// getRetentionStampPath は保持期間を最後に自動で適用した日時を記録するファイルのパスを返す
+func getRetentionStampPath() (string, error) {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, retentionStampFileName), nil
+}
+
+// appliesRetentionOnStartup は起動時に保持期間を自動で適用してよい実行か
+// -explain（クエリを実行せずに実行計画を表示する）と -now（過去の時点を再現する）ではデータを削除しない
+func (c Config) appliesRetentionOnStartup() bool {
+	return !c.Explain && c.Now.IsZero()
+}
+
+// applyRetentionOnStartup は retention.txt に保持期間と auto = true があれば、前回の自動適用から retentionAutoInterval 以上経っていれば適用する
+// 失敗しても本来の処理は続ける（警告だけ表示する）
+func applyRetentionOnStartup(index *SearchIndex) {
+	policy, err := LoadRetentionPolicy()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 保持期間を適用できません: %v\n", err)
+		return
+	}
+	if !policy.Auto || len(policy.Periods) == 0 {
+		return
+	}
+	stamp, err := getRetentionStampPath()
+	if err != nil || fileFresh(stamp, retentionAutoInterval) {
+		return
+	}
+	// 失敗した場合（暗号化したアーカイブDBの鍵がないなど）も、次に試すのは翌日にする
+	if err := os.MkdirAll(filepath.Dir(stamp), configDirPerms); err == nil {
+		_ = os.WriteFile(stamp, []byte(time.Now().Format(time.RFC3339)+"\n"), configFilePerms)
+	}
+	report, err := applyRetention(policy, index, time.Now(), false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 保持期間を適用できません（hist gc で再実行できます）: %v\n", err)
+		return
+	}
+	if n := report.Total(); n > 0 {
+		fmt.Fprintf(os.Stderr, "保持期間（%s）を過ぎたデータを%d件削除しました\n", retentionFileName, n)
+	}
+}
+
+// printRetentionReport は hist gc の結果を出力する
+func printRetentionReport(w io.Writer, report RetentionReport) {
+	if report.DryRun {
+		_, _ = fmt.Fprintf(w, "\n🧹 保持期間を過ぎたデータ（-dry-run: 削除しません）\n")
+	} else {
+		_, _ = fmt.Fprintf(w, "\n🧹 保持期間を過ぎたデータを削除しました\n")
+	}
+	_, _ = fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	for _, r := range report.Results {
+		if r.Skipped != "" && r.Keep == "" {
+			_, _ = fmt.Fprintf(w, "  %-12s %s\n", r.Target, r.Skipped)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  %-12s %-4s %s より前: ", r.Target, r.Keep, r.Before.Format(TimeFormatDate))
+		if r.Skipped != "" {
+			_, _ = fmt.Fprintf(w, "%s\n", r.Skipped)
+			continue
+		}
+		var parts []string
+		for _, kind := range []string{"visits", "urls", "tags", "notes"} {
+			if n, ok := r.Deleted[kind]; ok {
+				parts = append(parts, fmt.Sprintf("%s %d件", retentionDeletedLabels[kind], n))
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\n", strings.Join(parts, "・"))
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// runGcCommand は hist gc サブコマンドを実行する
+func runGcCommand(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "削除せずに、削除する件数だけを表示する")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	overrides := make(map[string]*string)
+	for _, target := range retentionTargets {
+		overrides[target] = fs.String(target, "", target+" の保持期間（"+retentionFileName+" の設定より優先。例: 2y）")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist gc [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  %s の保持期間より古いアーカイブDB・全文検索の索引・タグ・メモを削除します\n\n", retentionFileName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	policy, err := LoadRetentionPolicy()
+	if err != nil {
+		return err
+	}
+	for target, value := range overrides {
+		if *value == "" {
+			continue
+		}
+		period, err := parseRetentionPeriod(*value)
+		if err != nil {
+			return fmt.Errorf("-%s: %w", target, err)
+		}
+		policy.Periods[target] = period
+	}
+	if len(policy.Periods) == 0 {
+		return fmt.Errorf("保持期間が設定されていません（%s に「archive = 2y」のように書くか、-archive・-index・-annotations で指定してください）", retentionFileName)
+	}
+
+	// 索引は Safari の履歴DBから作るため、-db・HIST_DB で別のDBを読む場合は対象外
+	var index *SearchIndex
+	if _, ok := policy.Periods[RetentionTargetIndex]; ok && os.Getenv(HistDBEnv) == "" {
+		path, err := getSearchIndexPath()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil {
+			db, err := setupDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = db.Close() }()
+			if index, err = openSearchIndex(path, db); err != nil {
+				return err
+			}
+			defer func() { _ = index.Close() }()
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("索引DBの確認に失敗: %w", err)
+		}
+	}
+
+	report, err := applyRetention(policy, index, time.Now(), *dryRun)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printRetentionReport(os.Stdout, report)
+	return nil
+}
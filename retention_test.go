@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionPeriod(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"90d", now.AddDate(0, 0, -90)},
+		{"2w", time.Date(2025, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"18m", now.AddDate(0, -18, 0)},
+		{" 2Y ", time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		p, err := parseRetentionPeriod(tt.in)
+		if err != nil {
+			t.Errorf("parseRetentionPeriod(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := p.Cutoff(now); !got.Equal(tt.want) {
+			t.Errorf("parseRetentionPeriod(%q).Cutoff() = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if p, _ := parseRetentionPeriod("2y"); p.String() != "2y" {
+		t.Errorf("String() = %q, want 2y", p.String())
+	}
+	for _, in := range []string{"", "2", "y", "0d", "-1y", "2h", "1.5y"} {
+		if _, err := parseRetentionPeriod(in); err == nil {
+			t.Errorf("parseRetentionPeriod(%q) がエラーにならない", in)
+		}
+	}
+}
+
+func TestParseRetentionPolicy(t *testing.T) {
+	policy, err := parseRetentionPolicy([]string{"archive = 2y", "annotations=6m", "auto = true"})
+	if err != nil {
+		t.Fatalf("parseRetentionPolicy() error = %v", err)
+	}
+	if !policy.Auto || len(policy.Periods) != 2 || policy.Periods[RetentionTargetArchive].String() != "2y" ||
+		policy.Periods[RetentionTargetAnnotations].String() != "6m" {
+		t.Errorf("parseRetentionPolicy() = %+v", policy)
+	}
+	// 自動の適用は auto = true を書いた場合だけ
+	if policy, err := parseRetentionPolicy(nil); err != nil || policy.Auto || len(policy.Periods) != 0 {
+		t.Errorf("空の parseRetentionPolicy() = %+v, %v, want 自動適用なし・保持期間なし", policy, err)
+	}
+	for _, lines := range [][]string{{"archive 2y"}, {"history = 2y"}, {"index = forever"}, {"auto = sometimes"}} {
+		if _, err := parseRetentionPolicy(lines); err == nil {
+			t.Errorf("parseRetentionPolicy(%q) がエラーにならない", lines)
+		}
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	old, recent := now.AddDate(-3, 0, 0), now.AddDate(0, -1, 0)
+
+	archivePath, err := getArchivePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := openArchiveDB(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta := ArchiveDelta{Version: archiveDeltaVersion, Device: "laptop", Visits: []HistoryVisit{
+		{ID: 1, URL: "https://old.example/", VisitTime: old},
+		{ID: 2, URL: "https://go.dev/", VisitTime: old},
+		{ID: 3, URL: "https://go.dev/", VisitTime: recent},
+	}}
+	if _, _, err := importArchiveDelta(archive, "laptop-1", delta, now); err != nil {
+		t.Fatal(err)
+	}
+	_ = archive.Close()
+
+	sidecarPath, err := getSidecarPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := openSidecarDB(sidecarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sidecar.AddTags("https://old.example/", 0, []string{"old"}, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sidecar.AddTags("https://go.dev/", 0, []string{"go"}, recent); err != nil {
+		t.Fatal(err)
+	}
+	if err := sidecar.SetNote("https://old.example/", 0, "昔のメモ", old); err != nil {
+		t.Fatal(err)
+	}
+	_ = sidecar.Close()
+
+	policy := RetentionPolicy{Periods: map[string]RetentionPeriod{
+		RetentionTargetArchive:     {Count: 2, Unit: 'y'},
+		RetentionTargetIndex:       {Count: 2, Unit: 'y'},
+		RetentionTargetAnnotations: {Count: 2, Unit: 'y'},
+	}}
+	wantDeleted := map[string]map[string]int{
+		RetentionTargetArchive:     {"visits": 2, "urls": 1},
+		RetentionTargetAnnotations: {"tags": 1, "notes": 1},
+	}
+	check := func(report RetentionReport) {
+		t.Helper()
+		if len(report.Results) != len(retentionTargets) || report.Total() != 5 {
+			t.Fatalf("applyRetention() = %+v, want 合計5件", report)
+		}
+		for _, r := range report.Results {
+			want, ok := wantDeleted[r.Target]
+			if !ok {
+				// 索引を渡さなければ対象外
+				if r.Skipped == "" {
+					t.Errorf("%s: Skipped が空", r.Target)
+				}
+				continue
+			}
+			for kind, n := range want {
+				if r.Deleted[kind] != n {
+					t.Errorf("%s の %s = %d, want %d", r.Target, kind, r.Deleted[kind], n)
+				}
+			}
+		}
+	}
+
+	// -dry-run では数えるだけで削除しない
+	report, err := applyRetention(policy, nil, now, true)
+	if err != nil {
+		t.Fatalf("applyRetention(dryRun) error = %v", err)
+	}
+	check(report)
+	report, err = applyRetention(policy, nil, now, false)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	check(report)
+	var buf bytes.Buffer
+	printRetentionReport(&buf, report)
+	if out := buf.String(); !strings.Contains(out, "訪問 2件・URL 1件") || !strings.Contains(out, "タグ 1件・メモ 1件") {
+		t.Errorf("printRetentionReport() = %q", out)
+	}
+
+	if again, err := applyRetention(policy, nil, now, false); err != nil || again.Total() != 0 {
+		t.Errorf("2回目の applyRetention() = %+v, %v, want 0件", again, err)
+	}
+	archive, err = openArchiveDB(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = archive.Close() }()
+	var count int
+	if err := archive.QueryRow(`SELECT visit_count FROM history_items WHERE url = 'https://go.dev/'`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("go.dev の visit_count = %d, %v, want 1", count, err)
+	}
+}
+
+func TestApplyRetentionNoData(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	policy := RetentionPolicy{Periods: map[string]RetentionPeriod{RetentionTargetArchive: {Count: 1, Unit: 'y'}}}
+	report, err := applyRetention(policy, nil, time.Now(), false)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	for _, r := range report.Results {
+		if r.Skipped == "" || len(r.Deleted) != 0 {
+			t.Errorf("%s = %+v, want 対象外", r.Target, r)
+		}
+	}
+	// データがなければアーカイブDBを作らない
+	path, _ := getArchivePath()
+	if fileFresh(path, time.Hour) {
+		t.Error("アーカイブDBが作られた")
+	}
+}
+
+func TestAppliesRetentionOnStartup(t *testing.T) {
+	for _, tt := range []struct {
+		config Config
+		want   bool
+	}{
+		{Config{}, true},
+		{Config{Explain: true}, false},
+		{Config{Now: time.Date(2025, 1, 2, 23, 59, 59, 0, time.Local)}, false},
+	} {
+		if got := tt.config.appliesRetentionOnStartup(); got != tt.want {
+			t.Errorf("appliesRetentionOnStartup(Explain=%v, Now=%v) = %v, want %v", tt.config.Explain, tt.config.Now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 日別統計に添える移動平均の日数
+const (
+	RollingShortDays = 7
+	RollingLongDays  = 30
+)
+
+// rollingAverage は date までの window 日間の1日あたりの訪問数の平均を返す
+// 訪問のない日は0件として数え、first（履歴の最初の日）より前の日は数えない
+func rollingAverage(dateCounts map[string]int, first, date string, window int) float64 {
+	day, err := time.Parse(TimeFormatDate, date)
+	if err != nil {
+		return 0
+	}
+	total, days := 0, 0
+	for i := 0; i < window; i++ {
+		d := day.AddDate(0, 0, -i).Format(TimeFormatDate)
+		if d < first {
+			break
+		}
+		total += dateCounts[d]
+		days++
+	}
+	if days == 0 {
+		return 0
+	}
+	return float64(total) / float64(days)
+}
+
+// annotateRollingAverages は日別統計に7日・30日の移動平均を付ける
+// dateCounts は期間より前の日も含めた日ごとの訪問数、first は履歴の最初の日
+func annotateRollingAverages(stats []DailyStats, dateCounts map[string]int, first string) {
+	for i := range stats {
+		stats[i].Avg7 = rollingAverage(dateCounts, first, stats[i].Date, RollingShortDays)
+		stats[i].Avg30 = rollingAverage(dateCounts, first, stats[i].Date, RollingLongDays)
+	}
+}
+
+// formatRollingAverages は日別統計の移動平均の表示
+func formatRollingAverages(s DailyStats) string {
+	return fmt.Sprintf("%d日平均 %.1f / %d日平均 %.1f", RollingShortDays, s.Avg7, RollingLongDays, s.Avg30)
+}
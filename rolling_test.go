@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRollingAverage(t *testing.T) {
+	counts := map[string]int{"2025-01-01": 3, "2025-01-02": 2, "2025-01-10": 7}
+	for _, tt := range []struct {
+		date   string
+		window int
+		want   string
+	}{
+		// 履歴の最初の日より前は数えない
+		{"2025-01-01", 7, "3.00"},
+		{"2025-01-02", 7, "2.50"},
+		// 訪問のない日は0件として数える
+		{"2025-01-05", 7, "1.00"},
+		{"2025-01-10", 7, "1.00"},
+		{"2025-01-10", 30, "1.20"},
+	} {
+		if got := fmt.Sprintf("%.2f", rollingAverage(counts, "2025-01-01", tt.date, tt.window)); got != tt.want {
+			t.Errorf("rollingAverage(%s, %d) = %s, want %s", tt.date, tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestGetDailyStatsRollingAverages(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 期間より前の日の訪問も移動平均に含める（1/2 の平均は 1/1〜1/2 の5件を2日で割る）
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	result, err := analyze(db, Config{ShowDaily: true, Days: 1, Now: now})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	stats := result.DailyStats
+	if len(stats) != 1 || stats[0].Date != "2025-01-02" || stats[0].VisitCount != 2 || stats[0].Avg7 != 2.5 || stats[0].Avg30 != 2.5 {
+		t.Fatalf("DailyStats = %+v", stats)
+	}
+
+	var text bytes.Buffer
+	printTextOutput(&text, result, false, false, false, false, true)
+	if !strings.Contains(text.String(), " 2  (7日平均 2.5 / 30日平均 2.5)") {
+		t.Errorf("テキスト出力:\n%s", text.String())
+	}
+	var csv bytes.Buffer
+	if err := writeCSV(&csv, result, false, false, false, false, true, ','); err != nil {
+		t.Fatalf("writeCSV: %v", err)
+	}
+	if !strings.Contains(csv.String(), "date,visit_count,holiday,avg_7d,avg_30d,active_hours,offline_hours\n2025-01-02,2,,2.50,2.50,1.00,0.00\n") {
+		t.Errorf("CSV の出力:\n%s", csv.String())
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// readingListData は同梱の読みの辞書（lists/readings.txt）
+//
+//go:embed lists/readings.txt
+var readingListData string
+
+// readingDictionary は読み（ひらがな）から、その読みの語（漢字など）への対応
+// 長音を省いたローマ字（tokyo → ときょ）でも引けるよう、キーは collapseLongVowels した読み
+var readingDictionary = parseReadingList(readingListData)
+
+// parseReadingList は1行に「読み 語」を書いた辞書を読み込む（空行と "#" で始まる行は無視）
+// 同じ読みの語は複数行に分けて書ける
+func parseReadingList(data string) map[string][]string {
+	dict := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		key := collapseLongVowels(fields[0])
+		if seen[key+" "+fields[1]] {
+			continue
+		}
+		seen[key+" "+fields[1]] = true
+		dict[key] = append(dict[key], fields[1])
+	}
+	return dict
+}
+
+// romajiTable はローマ字（ヘボン式・訓令式）とひらがなの対応
+// 長いつづりから順に照合するため、3文字・2文字・1文字の表に分けている
+var romajiTable = [...]map[string]string{
+	{
+		"kya": "きゃ", "kyu": "きゅ", "kyo": "きょ", "gya": "ぎゃ", "gyu": "ぎゅ", "gyo": "ぎょ",
+		"sha": "しゃ", "shi": "し", "shu": "しゅ", "she": "しぇ", "sho": "しょ",
+		"sya": "しゃ", "syu": "しゅ", "syo": "しょ", "jya": "じゃ", "jyu": "じゅ", "jyo": "じょ",
+		"zya": "じゃ", "zyu": "じゅ", "zyo": "じょ",
+		"cha": "ちゃ", "chi": "ち", "chu": "ちゅ", "che": "ちぇ", "cho": "ちょ",
+		"tya": "ちゃ", "tyu": "ちゅ", "tyo": "ちょ", "tsu": "つ",
+		"nya": "にゃ", "nyu": "にゅ", "nyo": "にょ", "hya": "ひゃ", "hyu": "ひゅ", "hyo": "ひょ",
+		"bya": "びゃ", "byu": "びゅ", "byo": "びょ", "pya": "ぴゃ", "pyu": "ぴゅ", "pyo": "ぴょ",
+		"mya": "みゃ", "myu": "みゅ", "myo": "みょ", "rya": "りゃ", "ryu": "りゅ", "ryo": "りょ",
+		"xtu": "っ", "ltu": "っ", "xya": "ゃ", "xyu": "ゅ", "xyo": "ょ",
+	},
+	{
+		"ka": "か", "ki": "き", "ku": "く", "ke": "け", "ko": "こ",
+		"ga": "が", "gi": "ぎ", "gu": "ぐ", "ge": "げ", "go": "ご",
+		"sa": "さ", "si": "し", "su": "す", "se": "せ", "so": "そ",
+		"za": "ざ", "zi": "じ", "zu": "ず", "ze": "ぜ", "zo": "ぞ",
+		"ja": "じゃ", "ji": "じ", "ju": "じゅ", "je": "じぇ", "jo": "じょ",
+		"ta": "た", "ti": "ち", "tu": "つ", "te": "て", "to": "と",
+		"da": "だ", "di": "ぢ", "du": "づ", "de": "で", "do": "ど",
+		"na": "な", "ni": "に", "nu": "ぬ", "ne": "ね", "no": "の",
+		"ha": "は", "hi": "ひ", "hu": "ふ", "fu": "ふ", "he": "へ", "ho": "ほ",
+		"fa": "ふぁ", "fi": "ふぃ", "fe": "ふぇ", "fo": "ふぉ",
+		"ba": "ば", "bi": "び", "bu": "ぶ", "be": "べ", "bo": "ぼ",
+		"pa": "ぱ", "pi": "ぴ", "pu": "ぷ", "pe": "ぺ", "po": "ぽ",
+		"ma": "ま", "mi": "み", "mu": "む", "me": "め", "mo": "も",
+		"ya": "や", "yu": "ゆ", "yo": "よ",
+		"ra": "ら", "ri": "り", "ru": "る", "re": "れ", "ro": "ろ",
+		"wa": "わ", "wi": "うぃ", "we": "うぇ", "wo": "を", "vu": "ゔ",
+		"n'": "ん",
+		"xa": "ぁ", "xi": "ぃ", "xu": "ぅ", "xe": "ぇ", "xo": "ぉ",
+		"la": "ぁ", "li": "ぃ", "lu": "ぅ", "le": "ぇ", "lo": "ぉ",
+	},
+	{
+		"a": "あ", "i": "い", "u": "う", "e": "え", "o": "お", "-": "ー",
+	},
+}
+
+// romajiToHiragana はローマ字をひらがなに変換する
+// ローマ字として読めない文字が残る場合（英単語など）は false を返す
+func romajiToHiragana(s string) (string, bool) {
+	s = strings.ToLower(s)
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == ' ' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		// nn は撥音。母音・y が続く場合は後ろの n を次の音に使う（konnichiwa → こんにちわ）
+		if c == 'n' && i+1 < len(s) && s[i+1] == 'n' {
+			b.WriteString("ん")
+			if i+2 < len(s) && strings.IndexByte("aiueoy", s[i+2]) >= 0 {
+				i++
+			} else {
+				i += 2
+			}
+			continue
+		}
+		// 子音の重なり（nikki → にっき、matcha → まっちゃ）は促音にする
+		if i+1 < len(s) && isRomajiConsonant(c) && (c == s[i+1] || c == 't' && strings.HasPrefix(s[i+1:], "ch")) {
+			b.WriteString("っ")
+			i++
+			continue
+		}
+		matched := false
+		for n := 3; n >= 1; n-- {
+			if i+n > len(s) {
+				continue
+			}
+			if kana, ok := romajiTable[3-n][s[i:i+n]]; ok {
+				b.WriteString(kana)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		// 母音・y が続かない n は撥音（kanji → かんじ）
+		if c == 'n' {
+			b.WriteString("ん")
+			i++
+			continue
+		}
+		return "", false
+	}
+	return b.String(), true
+}
+
+// isRomajiConsonant は促音になり得る子音か
+func isRomajiConsonant(c byte) bool {
+	return c >= 'a' && c <= 'z' && strings.IndexByte("aiueon", c) < 0
+}
+
+// longVowelBases は直後の う・お を長音とみなす（省いても同じ読みとする）かなと、省く文字
+var longVowelBases = func() map[rune]string {
+	bases := make(map[rune]string)
+	for _, r := range "おこごそぞとどのほぼぽもよろょを" {
+		bases[r] = "うお"
+	}
+	for _, r := range "うくぐすずつづぬふぶぷむゆるゅ" {
+		bases[r] = "う"
+	}
+	return bases
+}()
+
+// collapseLongVowels は読みから長音（とうきょう の う、おおさか の お、ー）を除く
+// ローマ字では長音を省いて書くことが多いため（tokyo・osaka）、辞書はこの形で引く
+func collapseLongVowels(s string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range s {
+		if r == 'ー' || strings.ContainsRune(longVowelBases[prev], r) {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// hiraganaToKatakana はひらがな（ぁ〜ゖ）を対応するカタカナにする
+func hiraganaToKatakana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + ('ァ' - 'ぁ')
+		}
+		return r
+	}, s)
+}
+
+// expandRomajiKeyword はローマ字のキーワードを、ひらがな・カタカナと辞書にある読みの語に広げる
+// 元のキーワードを先頭に、重複を除いて返す。ローマ字として読めなければ元のキーワードだけ
+func expandRomajiKeyword(keyword string) []string {
+	keywords := []string{keyword}
+	hiragana, ok := romajiToHiragana(strings.TrimSpace(keyword))
+	if !ok || hiragana == "" {
+		return keywords
+	}
+	seen := map[string]bool{keyword: true}
+	add := func(k string) {
+		if !seen[k] {
+			seen[k] = true
+			keywords = append(keywords, k)
+		}
+	}
+	add(hiragana)
+	add(hiraganaToKatakana(hiragana))
+	for _, word := range readingDictionary[collapseLongVowels(hiragana)] {
+		add(word)
+	}
+	return keywords
+}
+
+// searchKeywords はフィルタのキーワードを検索に使う語の一覧にする（-romaji なら候補に広げる）
+func searchKeywords(filter SearchFilter) []string {
+	if filter.Keyword == "" {
+		return nil
+	}
+	if filter.Romaji {
+		return expandRomajiKeyword(filter.Keyword)
+	}
+	return []string{filter.Keyword}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRomajiToHiragana(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"nikki", "にっき", true},
+		{"Tokyo", "ときょ", true},
+		{"toukyou", "とうきょう", true},
+		{"kanji", "かんじ", true},
+		{"konnichiwa", "こんにちわ", true},
+		{"shinnyuu", "しんにゅう", true},
+		{"matcha", "まっちゃ", true},
+		{"tsukaikata", "つかいかた", true},
+		{"kan'i", "かんい", true},
+		{"ra-men", "らーめん", true},
+		{"tenki yohou", "てんき よほう", true},
+		{"github", "", false},
+		{"c++", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := romajiToHiragana(tt.in)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("romajiToHiragana(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestExpandRomajiKeyword(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"nikki", []string{"nikki", "にっき", "ニッキ", "日記"}},
+		// 長音を省いたつづりでも辞書を引ける
+		{"tokyo", []string{"tokyo", "ときょ", "トキョ", "東京"}},
+		{"osaka", []string{"osaka", "おさか", "オサカ", "大阪"}},
+		{"nihon", []string{"nihon", "にほん", "ニホン", "日本"}},
+		{"github", []string{"github"}},
+	}
+	for _, tt := range tests {
+		if got := expandRomajiKeyword(tt.keyword); !slices.Equal(got, tt.want) {
+			t.Errorf("expandRomajiKeyword(%q) = %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+}
+
+func TestParseReadingList(t *testing.T) {
+	dict := parseReadingList("# コメント\nにっき 日記\nのりかえ 乗換\nのりかえ 乗り換え\n不正な行\n\nにっき 日記\n")
+	if got := dict["にっき"]; !slices.Equal(got, []string{"日記"}) {
+		t.Errorf("dict[にっき] = %q", got)
+	}
+	if got := dict["のりかえ"]; !slices.Equal(got, []string{"乗換", "乗り換え"}) {
+		t.Errorf("dict[のりかえ] = %q", got)
+	}
+	// 同梱の辞書を読み込めている
+	if len(readingDictionary) == 0 {
+		t.Error("同梱の読みの辞書が空")
+	}
+}
+
+func TestRomajiKeywordSearch(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'https://example.jp/diary', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757418400 + 100, '今日の日記'),
+		(7, 5, 757418400 + 200, 'ニッキの木'),
+		(8, 5, 757418400 + 300, 'nikki.example の記事');
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	plain, err := getRecentVisits(db, 100, SearchFilter{Keyword: "nikki"})
+	if err != nil {
+		t.Fatalf("getRecentVisits() error = %v", err)
+	}
+	if len(plain) != 1 {
+		t.Errorf("-romaji なしで %d件, want 1", len(plain))
+	}
+
+	expanded, err := getRecentVisits(db, 100, SearchFilter{Keyword: "nikki", Romaji: true})
+	if err != nil {
+		t.Fatalf("getRecentVisits(romaji) error = %v", err)
+	}
+	if len(expanded) != 3 {
+		t.Errorf("-romaji で %d件, want 3", len(expanded))
+	}
+
+	// ローマ字として読めないキーワードは -romaji でもそのまま
+	visits, err := getRecentVisits(db, 100, SearchFilter{Keyword: "github", Romaji: true})
+	if err != nil {
+		t.Fatalf("getRecentVisits() error = %v", err)
+	}
+	if len(visits) != 2 {
+		t.Errorf("github で %d件, want 2", len(visits))
+	}
+}
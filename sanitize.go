@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitizeURLPreviewLength は data: URL を省略表示するときに残す長さ
+const sanitizeURLPreviewLength = 40
+
+// DataIssue はサニタイズで見つかった不正データ
+type DataIssue struct {
+	URL    string
+	Field  string
+	Reason string
+}
+
+// Error はerrorインターフェースの実装（-strict で返すエラー）
+func (i DataIssue) Error() string {
+	url := truncateText(strings.ToValidUTF8(i.URL, "�"), MaxTitleLength)
+	return fmt.Sprintf("不正なデータ（%s: %s）: %s", i.Field, i.Reason, url)
+}
+
+// sanitizeText は不正なUTF-8と制御文字を取り除いた文字列を返す
+// 改行・タブは1つの空白に置き換える（CSVやテキスト出力の行崩れを防ぐ）
+func sanitizeText(s string) (string, []string) {
+	var reasons []string
+	if !utf8.ValidString(s) {
+		reasons = append(reasons, "不正なUTF-8")
+		s = strings.ToValidUTF8(s, "�")
+	}
+
+	hasControl := false
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			hasControl = true
+			return ' '
+		case unicode.IsControl(r):
+			hasControl = true
+			return -1
+		}
+		return r
+	}, s)
+	if hasControl {
+		reasons = append(reasons, "制御文字")
+	}
+	return strings.TrimSpace(cleaned), reasons
+}
+
+// sanitizeURL は表示に適さないURL（data: / javascript:）を省略形に置き換える
+func sanitizeURL(u string) (string, []string) {
+	u, reasons := sanitizeText(u)
+	lower := strings.ToLower(u)
+	switch {
+	case strings.HasPrefix(lower, "javascript:"):
+		reasons = append(reasons, "javascript: URL")
+		return "javascript:(省略)", reasons
+	case strings.HasPrefix(lower, "data:"):
+		reasons = append(reasons, "data: URL")
+		// メディアタイプ部分だけ残す（本体は巨大になりうる）
+		header := u
+		if idx := strings.Index(header, ","); idx != -1 {
+			header = header[:idx]
+		}
+		header = truncateText(header, sanitizeURLPreviewLength)
+		return fmt.Sprintf("%s,(%d bytes)", header, len(u)), reasons
+	}
+	return u, reasons
+}
+
+// sanitizeVisit は訪問記録のURL・タイトル・ドメインを安全な文字列に整える
+// 見つかった問題も返す
+func sanitizeVisit(v HistoryVisit) (HistoryVisit, []DataIssue) {
+	var issues []DataIssue
+	original := v.URL
+
+	var reasons []string
+	v.URL, reasons = sanitizeURL(v.URL)
+	for _, r := range reasons {
+		issues = append(issues, DataIssue{URL: original, Field: "url", Reason: r})
+	}
+	v.Title, reasons = sanitizeText(v.Title)
+	for _, r := range reasons {
+		issues = append(issues, DataIssue{URL: original, Field: "title", Reason: r})
+	}
+	v.Domain, reasons = sanitizeText(v.Domain)
+	for _, r := range reasons {
+		issues = append(issues, DataIssue{URL: original, Field: "domain", Reason: r})
+	}
+	return v, issues
+}
+
+// sanitizeVisits は訪問記録の一覧をサニタイズしたコピーを返す
+func sanitizeVisits(visits []HistoryVisit) []HistoryVisit {
+	if visits == nil {
+		return nil
+	}
+	sanitized := make([]HistoryVisit, len(visits))
+	for i, v := range visits {
+		sanitized[i], _ = sanitizeVisit(v)
+	}
+	return sanitized
+}
+
+// sanitizeResult は分析結果の文字列をサニタイズしたコピーを返す
+// strict の場合はサニタイズせず、最初に見つかった問題をエラーとして返す
+func sanitizeResult(result AnalysisResult, strict bool) (AnalysisResult, error) {
+	sanitized := result
+
+	if result.RecentVisits != nil {
+		sanitized.RecentVisits = make([]HistoryVisit, len(result.RecentVisits))
+		for i, v := range result.RecentVisits {
+			clean, issues := sanitizeVisit(v)
+			if strict && len(issues) > 0 {
+				return result, issues[0]
+			}
+			sanitized.RecentVisits[i] = clean
+		}
+	}
+
+	if result.DomainStats != nil {
+		sanitized.DomainStats = make([]DomainStats, len(result.DomainStats))
+		for i, s := range result.DomainStats {
+			clean, reasons := sanitizeText(s.Domain)
+			if strict && len(reasons) > 0 {
+				return result, DataIssue{URL: s.Domain, Field: "domain", Reason: reasons[0]}
+			}
+			s.Domain = clean
+			sanitized.DomainStats[i] = s
+		}
+	}
+
+	if result.DomainPathStats != nil {
+		sanitized.DomainPathStats = make([]DomainPathStats, len(result.DomainPathStats))
+		for i, ds := range result.DomainPathStats {
+			clean, reasons := sanitizeText(ds.Domain)
+			if strict && len(reasons) > 0 {
+				return result, DataIssue{URL: ds.Domain, Field: "domain", Reason: reasons[0]}
+			}
+			ds.Domain = clean
+			paths := make([]PathStats, len(ds.Paths))
+			for j, p := range ds.Paths {
+				path, pathReasons := sanitizeText(p.Path)
+				title, titleReasons := sanitizeText(p.Title)
+				if strict && len(pathReasons) > 0 {
+					return result, DataIssue{URL: ds.Domain + p.Path, Field: "path", Reason: pathReasons[0]}
+				}
+				if strict && len(titleReasons) > 0 {
+					return result, DataIssue{URL: ds.Domain + p.Path, Field: "title", Reason: titleReasons[0]}
+				}
+				paths[j] = PathStats{Path: path, Title: title, VisitCount: p.VisitCount}
+			}
+			ds.Paths = paths
+			sanitized.DomainPathStats[i] = ds
+		}
+	}
+
+	if result.TitleClusters != nil {
+		sanitized.TitleClusters = make([]TitleCluster, len(result.TitleClusters))
+		for i, c := range result.TitleClusters {
+			urls := make([]string, len(c.URLs))
+			for j, u := range c.URLs {
+				clean, reasons := sanitizeURL(u)
+				if strict && len(reasons) > 0 {
+					return result, DataIssue{URL: u, Field: "url", Reason: reasons[0]}
+				}
+				urls[j] = clean
+			}
+			c.URLs = urls
+			sanitized.TitleClusters[i] = c
+		}
+	}
+
+	return sanitized, nil
+}
+
+// truncateText は文字数（rune数）で切り詰める
+// バイト単位で切るとマルチバイト文字が壊れるため、表示用の切り詰めは全てこれを使う
+func truncateText(s string, length int) string {
+	if length <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= length {
+		return s
+	}
+	if length <= 3 {
+		return string([]rune(s)[:length])
+	}
+	return string([]rune(s)[:length-3]) + "..."
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		want       string
+		wantIssues int
+	}{
+		{"正常", "GitHub - Repo", "GitHub - Repo", 0},
+		{"日本語", "日本語タイトル", "日本語タイトル", 0},
+		{"改行", "line1\nline2", "line1 line2", 1},
+		{"制御文字", "ti\x00tle\x07", "title", 1},
+		{"不正なUTF-8", "bad\xff\xfebytes", "bad�bytes", 1},
+		{"空", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reasons := sanitizeText(tt.input)
+			if got != tt.want {
+				t.Errorf("sanitizeText(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if len(reasons) != tt.wantIssues {
+				t.Errorf("sanitizeText(%q) reasons = %v, want %d", tt.input, reasons, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func TestSanitizeURL(t *testing.T) {
+	got, reasons := sanitizeURL("javascript:alert(1)")
+	if got != "javascript:(省略)" || len(reasons) != 1 {
+		t.Errorf("javascript: URL = %q, %v", got, reasons)
+	}
+
+	dataURL := "data:image/png;base64," + strings.Repeat("A", 10000)
+	got, reasons = sanitizeURL(dataURL)
+	if got != "data:image/png;base64,(10022 bytes)" || len(reasons) != 1 {
+		t.Errorf("data: URL = %q, %v", got, reasons)
+	}
+
+	got, reasons = sanitizeURL("https://example.com/path")
+	if got != "https://example.com/path" || len(reasons) != 0 {
+		t.Errorf("通常のURL = %q, %v", got, reasons)
+	}
+}
+
+func TestSanitizeResultCSV(t *testing.T) {
+	result := AnalysisResult{
+		RecentVisits: []HistoryVisit{
+			{URL: "https://example.com", Title: "bad\xfftitle\nwith newline", Domain: "example.com"},
+		},
+	}
+
+	sanitized, err := sanitizeResult(result, false)
+	if err != nil {
+		t.Fatalf("sanitizeResult失敗: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, sanitized, true, false, false, false, false, ','); err != nil {
+		t.Fatalf("writeCSV失敗: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSVの読み込み失敗: %v", err)
+	}
+	// ヘッダー + 1行で、タイトルが1セルに収まっている
+	if len(records) != 2 || records[1][2] != "bad�title with newline" {
+		t.Errorf("CSV = %q", records)
+	}
+
+	// strictモードでは問題をエラーとして返す
+	_, err = sanitizeResult(result, true)
+	var issue DataIssue
+	if !errors.As(err, &issue) || issue.Field != "title" {
+		t.Errorf("strictモードのエラー = %v", err)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		input  string
+		length int
+		want   string
+	}{
+		{"short", 10, "short"},
+		{"あいうえおかきくけこ", 6, "あいう..."},
+		{"abcdef", 3, "abc"},
+		{"abcdef", 0, ""},
+		{"abcdef", -5, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.input, tt.length); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.input, tt.length, got, tt.want)
+		}
+	}
+}
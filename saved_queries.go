@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// queriesDirName は保存済みクエリを置く設定ディレクトリ内のサブディレクトリ
+	queriesDirName = "queries"
+	// queryFileExt は保存済みクエリファイルの拡張子
+	queryFileExt = ".sql"
+)
+
+// queryNamePattern は保存済みクエリ名として使える文字
+var queryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// QueryParams は保存済みクエリのテンプレートに渡すパラメータ
+// 文字列はSQLの文字列リテラル用にシングルクォートをエスケープ済み
+//
+//	SELECT url FROM history_items hi JOIN history_visits hv ON hv.history_item = hi.id
+//	WHERE hv.visit_time >= {{.FromTimestamp}} AND hi.url LIKE '%{{.Domain}}%'
+type QueryParams struct {
+	From          string
+	To            string
+	FromTimestamp float64
+	ToTimestamp   float64
+	Domain        string
+	Search        string
+	Limit         int
+	Params        map[string]string
+}
+
+// paramFlag は -param key=value を複数回受け付けるフラグ
+type paramFlag map[string]string
+
+// String はflag.Valueインターフェースの実装
+func (p paramFlag) String() string {
+	var pairs []string
+	for k, v := range p {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set はflag.Valueインターフェースの実装
+func (p paramFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("パラメータは key=value 形式で指定してください: %s", value)
+	}
+	p[key] = val
+	return nil
+}
+
+// getQueriesDir は保存済みクエリのディレクトリパスを返す
+func getQueriesDir() (string, error) {
+	return getConfigFilePath(queriesDirName)
+}
+
+// getSavedQueryPath は保存済みクエリのファイルパスを返す
+func getSavedQueryPath(name string) (string, error) {
+	if !queryNamePattern.MatchString(name) {
+		return "", fmt.Errorf("クエリ名には英数字・ハイフン・アンダースコアのみ使用できます: %s", name)
+	}
+	dir, err := getQueriesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+queryFileExt), nil
+}
+
+// LoadSavedQuery は保存済みクエリを読み込む
+func LoadSavedQuery(name string) (string, error) {
+	path, err := getSavedQueryPath(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("保存済みクエリが見つかりません: %s（%s）", name, path)
+		}
+		return "", fmt.Errorf("保存済みクエリの読み込みに失敗: %w", err)
+	}
+	return string(data), nil
+}
+
+// SaveQuery はクエリを名前を付けて保存する
+func SaveQuery(name, query string) error {
+	path, err := getSavedQueryPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerms); err != nil {
+		return fmt.Errorf("クエリディレクトリの作成に失敗: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.TrimSpace(query)+"\n"), configFilePerms); err != nil {
+		return fmt.Errorf("クエリの保存に失敗: %w", err)
+	}
+	return nil
+}
+
+// ListSavedQueries は保存済みクエリ名の一覧を返す
+func ListSavedQueries() ([]string, error) {
+	dir, err := getQueriesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("クエリ一覧の取得に失敗: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != queryFileExt {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), queryFileExt))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// sqlEscapeString はSQLの文字列リテラル内に埋め込めるようシングルクォートをエスケープする
+func sqlEscapeString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// newQueryParams はフィルタとパラメータからテンプレート用の値を作る
+func newQueryParams(filter SearchFilter, limit int, params map[string]string) QueryParams {
+	qp := QueryParams{
+		Domain: sqlEscapeString(filter.Domain),
+		Search: sqlEscapeString(filter.Keyword),
+		Limit:  limit,
+		Params: make(map[string]string, len(params)),
+	}
+	if !filter.From.IsZero() {
+		qp.From = filter.From.Format(TimeFormatDate)
+		qp.FromTimestamp = convertToTimestamp(filter.From)
+	}
+	if !filter.To.IsZero() {
+		qp.To = filter.To.Format(TimeFormatDate)
+		// 終了日は当日の23:59:59まで含める（WithDateRangeと同じ扱い）
+		qp.ToTimestamp = convertToTimestamp(filter.To.Add(24*time.Hour - time.Second))
+	}
+	for k, v := range params {
+		qp.Params[k] = sqlEscapeString(v)
+	}
+	return qp
+}
+
+// renderSavedQuery は保存済みクエリのテンプレートにパラメータを埋め込む
+func renderSavedQuery(query string, params QueryParams) (string, error) {
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("クエリテンプレートの解析に失敗: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("クエリテンプレートの展開に失敗: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// runQueryCommand は hist query サブコマンドを実行する
+func runQueryCommand(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	var opts sqlOutputOptions
+	opts.registerFlags(fs)
+	list := fs.Bool("list", false, "保存済みクエリの一覧を表示")
+	save := fs.String("save", "", "指定した名前でクエリを保存（引数にSQLを指定）")
+	fromDate := fs.String("from", "", "{{.From}} に渡す開始日（YYYY-MM-DD）")
+	toDate := fs.String("to", "", "{{.To}} に渡す終了日（YYYY-MM-DD）")
+	domain := fs.String("domain", "", "{{.Domain}} に渡すドメイン")
+	search := fs.String("search", "", "{{.Search}} に渡すキーワード")
+	limit := fs.Int("limit", DefaultHistoryLimit, "{{.Limit}} に渡す件数")
+	params := paramFlag{}
+	fs.Var(params, "param", "{{.Params.key}} に渡す値（key=value、複数指定可）")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist query [オプション] <名前>\n")
+		fmt.Fprintf(fs.Output(), "       hist query -save <名前> \"SELECT ...\"\n")
+		fmt.Fprintf(fs.Output(), "       hist query -list\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		names, err := ListSavedQueries()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("保存済みクエリはありません")
+			return nil
+		}
+		fmt.Println("保存済みクエリ:")
+		for _, n := range names {
+			fmt.Printf("  - %s\n", n)
+		}
+		return nil
+	}
+
+	if *save != "" {
+		if fs.NArg() != 1 {
+			return errors.New("保存するSQLクエリを1つ指定してください")
+		}
+		if err := SaveQuery(*save, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Printf("クエリを保存しました: %s\n", *save)
+		return nil
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("実行するクエリ名を1つ指定してください")
+	}
+
+	filter := SearchFilter{Domain: *domain, Keyword: *search}
+	if *fromDate != "" {
+		t, err := parseLocalDate(*fromDate)
+		if err != nil {
+			return fmt.Errorf("開始日の形式が不正です（YYYY-MM-DD）: %w", err)
+		}
+		filter.From = t
+	}
+	if *toDate != "" {
+		t, err := parseLocalDate(*toDate)
+		if err != nil {
+			return fmt.Errorf("終了日の形式が不正です（YYYY-MM-DD）: %w", err)
+		}
+		filter.To = t
+	}
+
+	saved, err := LoadSavedQuery(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	query, err := renderSavedQuery(saved, newQueryParams(filter, *limit, params))
+	if err != nil {
+		return err
+	}
+	return runReadOnlySQL(query, &opts)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderSavedQuery(t *testing.T) {
+	filter := SearchFilter{
+		Domain: "o'reilly.com",
+		From:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	params := newQueryParams(filter, 10, map[string]string{"min": "3"})
+
+	query, err := renderSavedQuery(
+		"SELECT * FROM history_items WHERE url LIKE '%{{.Domain}}%' AND visit_count >= {{.Params.min}} AND '{{.From}}' != '' LIMIT {{.Limit}}",
+		params,
+	)
+	if err != nil {
+		t.Fatalf("renderSavedQuery失敗: %v", err)
+	}
+	want := "SELECT * FROM history_items WHERE url LIKE '%o''reilly.com%' AND visit_count >= 3 AND '2025-01-01' != '' LIMIT 10"
+	if query != want {
+		t.Errorf("renderSavedQuery() = %q, want %q", query, want)
+	}
+
+	if params.FromTimestamp != convertToTimestamp(filter.From) {
+		t.Errorf("FromTimestamp = %v, want %v", params.FromTimestamp, convertToTimestamp(filter.From))
+	}
+
+	// 未定義のパラメータはエラー
+	if _, err := renderSavedQuery("SELECT {{.Params.missing}}", params); err == nil {
+		t.Error("未定義のパラメータでエラーにならない")
+	}
+}
+
+func TestSaveAndLoadQuery(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := SaveQuery("top-domains", "SELECT 1"); err != nil {
+		t.Fatalf("SaveQuery失敗: %v", err)
+	}
+	query, err := LoadSavedQuery("top-domains")
+	if err != nil {
+		t.Fatalf("LoadSavedQuery失敗: %v", err)
+	}
+	if query != "SELECT 1\n" {
+		t.Errorf("LoadSavedQuery() = %q, want %q", query, "SELECT 1\n")
+	}
+
+	names, err := ListSavedQueries()
+	if err != nil {
+		t.Fatalf("ListSavedQueries失敗: %v", err)
+	}
+	if len(names) != 1 || names[0] != "top-domains" {
+		t.Errorf("ListSavedQueries() = %v, want [top-domains]", names)
+	}
+
+	if err := SaveQuery("../escape", "SELECT 1"); err == nil {
+		t.Error("不正なクエリ名でエラーにならない")
+	}
+	if _, err := LoadSavedQuery("missing"); err == nil {
+		t.Error("存在しないクエリでエラーにならない")
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchemaDraft は生成するスキーマのバージョン
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaFileSuffix は hist schema -dir で書き出すファイルの拡張子
+const schemaFileSuffix = ".schema.json"
+
+// SchemaDocument はスキーマを公開する出力型
+type SchemaDocument struct {
+	Name        string
+	Description string
+	Type        reflect.Type
+}
+
+// schemaDocuments は JSON 出力と API レスポンスの型（/api/schema/{name} と hist schema で公開する）
+var schemaDocuments = []SchemaDocument{
+	{"AnalysisResult", "hist -json の出力、および GET /api/stats のレスポンス", reflect.TypeFor[AnalysisResult]()},
+	{"HistoryVisit", "訪問1件（hist -ndjson の1行）", reflect.TypeFor[HistoryVisit]()},
+	{"HistoryResponse", "GET /api/history・GET /api/dashboard/recent のレスポンス", reflect.TypeFor[[]HistoryVisit]()},
+	{"HistoryPage", "GET /api/history?cursor= のレスポンス", reflect.TypeFor[HistoryPage]()},
+	{"SearchResults", "GET /api/search のレスポンス", reflect.TypeFor[SearchResults]()},
+	{"SuggestResponse", "GET /api/suggest のレスポンス", reflect.TypeFor[[]Suggestion]()},
+	{"SummaryResponse", "GET /api/summary のレスポンス", reflect.TypeFor[Summary]()},
+	{"KeyBindingsResponse", "GET /api/keybindings のレスポンス", reflect.TypeFor[[]KeyBinding]()},
+	{"HourlyStatsResponse", "GET /api/stats/hourly のレスポンス", reflect.TypeFor[[]HourlyStats]()},
+	{"HourlySplitStats", "GET /api/stats/hourly?split=weekpart のレスポンス", reflect.TypeFor[HourlySplitStats]()},
+	{"DailyStatsResponse", "GET /api/stats/daily のレスポンス", reflect.TypeFor[[]DailyStats]()},
+	{"HourlyProfilesResponse", "GET /api/stats/profiles のレスポンス", reflect.TypeFor[HourlyProfilesResponse]()},
+	{"DomainsResponse", "GET /api/domains のレスポンス", reflect.TypeFor[[]string]()},
+	{"DomainDrilldown", "GET /api/domains/{domain}/paths のレスポンス", reflect.TypeFor[DomainDrilldown]()},
+	{"DashboardTotal", "GET /api/dashboard/total のレスポンス", reflect.TypeFor[DashboardTotal]()},
+	{"DashboardDomainsResponse", "GET /api/dashboard/domains のレスポンス", reflect.TypeFor[[]DomainPathStats]()},
+	{"DashboardHeatmap", "GET /api/dashboard/heatmap のレスポンス", reflect.TypeFor[DashboardHeatmap]()},
+	{"LaterResponse", "GET /api/later・hist later list -json のレスポンス", reflect.TypeFor[[]LaterItem]()},
+	{"LaterRequest", "POST /api/later のリクエスト", reflect.TypeFor[LaterRequest]()},
+	{"SnapshotInfo", "POST /api/snapshots のレスポンス", reflect.TypeFor[SnapshotInfo]()},
+	{"SnapshotsResponse", "GET /api/snapshots のレスポンス", reflect.TypeFor[[]SnapshotInfo]()},
+	{"StarredResponse", "GET /api/stars・hist starred -json のレスポンス", reflect.TypeFor[[]StarredPage]()},
+	{"StarRequest", "POST /api/stars のリクエスト", reflect.TypeFor[StarRequest]()},
+	{"AnnotationsResponse", "GET /api/annotations のレスポンス", reflect.TypeFor[[]Annotation]()},
+	{"CSVManifest", "hist -csv-split で書き出す manifest.json", reflect.TypeFor[CSVManifest]()},
+}
+
+// findSchemaDocument は名前でスキーマを公開する型を探す
+func findSchemaDocument(name string) (SchemaDocument, bool) {
+	for _, d := range schemaDocuments {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return SchemaDocument{}, false
+}
+
+// schemaGenerator は Go の型から JSON Schema を組み立てる
+// 名前付きの構造体は $defs にまとめ、$ref で参照する
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{defs: make(map[string]any)}
+}
+
+// schemaFor は型 t を encoding/json で出力したときの値を表すスキーマを返す
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			// 自己参照に備えて先に登録してから中身を組み立てる
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	// interface など値の形が決まらない型は何でも受け付ける
+	return map[string]any{}
+}
+
+// structSchema は構造体のフィールドを json タグに従ってプロパティにする
+// omitempty のないフィールドは必須で、nil になりうるスライス・マップ・ポインタは null も許す
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	g.addFields(t, properties, &required)
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields は構造体のフィールドをプロパティに追加する（埋め込み構造体のフィールドは展開する）
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.addFields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schemaFor(f.Type)
+		omitEmpty := strings.Contains(","+opts+",", ",omitempty,")
+		if !omitEmpty {
+			*required = append(*required, name)
+			switch f.Type.Kind() {
+			case reflect.Slice, reflect.Map, reflect.Pointer:
+				prop = map[string]any{"anyOf": []any{prop, map[string]any{"type": "null"}}}
+			}
+		}
+		properties[name] = prop
+	}
+}
+
+// buildSchema は1つの型のスキーマ文書を組み立てる
+func buildSchema(doc SchemaDocument) map[string]any {
+	g := newSchemaGenerator()
+	schema := g.schemaFor(doc.Type)
+	// ルートの構造体は $ref ではなく本体を置き、$defs には参照される型だけを残す
+	if ref, ok := schema["$ref"].(string); ok && strings.TrimPrefix(ref, "#/$defs/") == doc.Name {
+		schema = g.defs[doc.Name].(map[string]any)
+		delete(g.defs, doc.Name)
+	}
+
+	result := map[string]any{
+		"$schema":     JSONSchemaDraft,
+		"title":       doc.Name,
+		"description": doc.Description,
+	}
+	for k, v := range schema {
+		result[k] = v
+	}
+	if len(g.defs) > 0 {
+		result["$defs"] = g.defs
+	}
+	return result
+}
+
+// buildSchemaBundle は全ての出力型を $defs にまとめたスキーマ文書を組み立てる
+func buildSchemaBundle() map[string]any {
+	g := newSchemaGenerator()
+	for _, doc := range schemaDocuments {
+		schema := g.schemaFor(doc.Type)
+		if _, ok := schema["$ref"]; ok && doc.Type.Name() == doc.Name {
+			continue
+		}
+		g.defs[doc.Name] = schema
+	}
+	return map[string]any{
+		"$schema":     JSONSchemaDraft,
+		"title":       "hist",
+		"description": "hist の JSON 出力と Web API のレスポンス（各型は $defs を参照）",
+		"$defs":       g.defs,
+	}
+}
+
+// marshalSchema はスキーマを整形した JSON にする
+func marshalSchema(schema map[string]any) ([]byte, error) {
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("スキーマの出力に失敗: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// writeSchemaFiles は各出力型のスキーマを dir に <名前>.schema.json として書き出す
+func writeSchemaFiles(dir string) error {
+	if err := os.MkdirAll(dir, configDirPerms); err != nil {
+		return fmt.Errorf("ディレクトリの作成に失敗: %w", err)
+	}
+	for _, doc := range schemaDocuments {
+		data, err := marshalSchema(buildSchema(doc))
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, doc.Name+schemaFileSuffix)
+		if err := os.WriteFile(path, data, configFilePerms); err != nil {
+			return fmt.Errorf("スキーマの書き込みに失敗: %w", err)
+		}
+	}
+	return nil
+}
+
+// runSchemaCommand は hist schema サブコマンドを実行する
+func runSchemaCommand(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	list := fs.Bool("list", false, "スキーマを公開している型の一覧を表示")
+	dir := fs.String("dir", "", "全ての型のスキーマをディレクトリに <名前>.schema.json として書き出す")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist schema [オプション] [型名]\n\n")
+		fmt.Fprintf(fs.Output(), "  型名を省略すると全ての型を $defs にまとめたスキーマを出力します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		for _, doc := range schemaDocuments {
+			fmt.Printf("%s\t%s\n", doc.Name, doc.Description)
+		}
+		return nil
+	}
+	if *dir != "" {
+		if err := writeSchemaFiles(*dir); err != nil {
+			return err
+		}
+		fmt.Printf("%d件のスキーマを %s に書き出しました\n", len(schemaDocuments), *dir)
+		return nil
+	}
+
+	schema := buildSchemaBundle()
+	if name := fs.Arg(0); name != "" {
+		doc, ok := findSchemaDocument(name)
+		if !ok {
+			return fmt.Errorf("不明な型です: %s（hist schema -list で一覧を表示）", name)
+		}
+		schema = buildSchema(doc)
+	}
+	data, err := marshalSchema(schema)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validateSchema は値が生成したスキーマに従っているかを確かめる
+// 生成するスキーマで使うキーワード（$ref, anyOf, type, properties, required, additionalProperties, items）だけを扱う
+func validateSchema(t *testing.T, path string, value any, schema, defs map[string]any) {
+	t.Helper()
+	if ref, ok := schema["$ref"].(string); ok {
+		def, ok := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if !ok {
+			t.Fatalf("%s: 参照先がない: %s", path, ref)
+		}
+		validateSchema(t, path, value, def, defs)
+		return
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		if value == nil {
+			return
+		}
+		validateSchema(t, path, value, anyOf[0].(map[string]any), defs)
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			t.Errorf("%s: object ではない: %v", path, value)
+			return
+		}
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				t.Errorf("%s: 必須プロパティ %s がない", path, name)
+			}
+		}
+		for k, v := range obj {
+			if prop, ok := props[k].(map[string]any); ok {
+				validateSchema(t, path+"."+k, v, prop, defs)
+			} else if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+				validateSchema(t, path+"."+k, v, additional, defs)
+			} else {
+				t.Errorf("%s: スキーマにないプロパティ %s", path, k)
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			t.Errorf("%s: array ではない: %v", path, value)
+			return
+		}
+		for i, v := range arr {
+			validateSchema(t, fmt.Sprintf("%s[%d]", path, i), v, schema["items"].(map[string]any), defs)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			t.Errorf("%s: string ではない: %v", path, value)
+		}
+	case "integer", "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: 数値ではない: %v", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: boolean ではない: %v", path, value)
+		}
+	}
+}
+
+// roundTripSchema はスキーマを JSON にしてから読み直し、検証で扱いやすい形にする
+func roundTripSchema(t *testing.T, schema map[string]any) map[string]any {
+	t.Helper()
+	data, err := marshalSchema(schema)
+	if err != nil {
+		t.Fatalf("marshalSchema失敗: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("スキーマのパースに失敗: %v", err)
+	}
+	return decoded
+}
+
+func TestAnalysisResultSchema(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	config := Config{
+		Limit:        10,
+		DomainLimit:  10,
+		PathLimit:    5,
+		Days:         7,
+		ShowHistory:  true,
+		ShowDomains:  true,
+		ShowPaths:    true,
+		ShowHourly:   true,
+		ShowDaily:    true,
+		ShowTitles:   true,
+		ShowVideos:   true,
+		ShowGitHub:   true,
+		ShowDocs:     true,
+		ShowLocal:    true,
+		ShowProfiles: true,
+		HourlySplit:  HourlySplitWeekpart,
+	}
+	for _, p := range defaultDocSites {
+		pattern, err := parseDocSitePattern(p)
+		if err != nil {
+			t.Fatalf("parseDocSitePattern失敗: %v", err)
+		}
+		config.DocSites = append(config.DocSites, pattern)
+	}
+	result, err := analyze(db, config)
+	if err != nil {
+		t.Fatalf("analyze失敗: %v", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("JSON出力に失敗: %v", err)
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		t.Fatalf("JSONのパースに失敗: %v", err)
+	}
+
+	doc, _ := findSchemaDocument("AnalysisResult")
+	schema := roundTripSchema(t, buildSchema(doc))
+	defs, _ := schema["$defs"].(map[string]any)
+	validateSchema(t, "$", value, schema, defs)
+
+	// omitempty のないフィールドだけが必須になる
+	if required := schema["required"].([]any); len(required) != 1 || required[0] != "total_visits" {
+		t.Errorf("必須プロパティが期待値と異なる: %v", required)
+	}
+}
+
+func TestBuildSchemaBundle(t *testing.T) {
+	bundle := roundTripSchema(t, buildSchemaBundle())
+	defs := bundle["$defs"].(map[string]any)
+	for _, doc := range schemaDocuments {
+		if _, ok := defs[doc.Name]; !ok {
+			t.Errorf("$defs に %s がない", doc.Name)
+		}
+	}
+
+	var visits any
+	if err := json.Unmarshal([]byte(`[{"id":1,"url":"https://github.com/","title":"GitHub","domain":"github","visit_time":"2025-01-01T10:00:00Z"}]`), &visits); err != nil {
+		t.Fatalf("JSONのパースに失敗: %v", err)
+	}
+	validateSchema(t, "$", visits, defs["HistoryResponse"].(map[string]any), defs)
+}
+
+// TestShippedSchemas は docs/schema のスキーマが型定義と一致しているかを確かめる
+// 型を変更したら hist schema -dir docs/schema（make schema）で更新する
+func TestShippedSchemas(t *testing.T) {
+	for _, doc := range schemaDocuments {
+		want, err := marshalSchema(buildSchema(doc))
+		if err != nil {
+			t.Fatalf("marshalSchema失敗: %v", err)
+		}
+		got, err := os.ReadFile(filepath.Join("docs", "schema", doc.Name+schemaFileSuffix))
+		if err != nil {
+			t.Fatalf("スキーマファイルの読み込みに失敗: %v", err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("docs/schema/%s%s が古くなっています（make schema で更新してください）", doc.Name, schemaFileSuffix)
+		}
+	}
+}
+
+func TestWriteSchemaFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "schema")
+	if err := writeSchemaFiles(dir); err != nil {
+		t.Fatalf("writeSchemaFiles失敗: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ディレクトリの読み込みに失敗: %v", err)
+	}
+	if len(entries) != len(schemaDocuments) {
+		t.Errorf("書き出したファイル数 = %d, want %d", len(entries), len(schemaDocuments))
+	}
+}
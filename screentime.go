@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// スクリーンタイムの指標の名前（-join-csv の指標と同じ形で訪問数と結合する）
+const (
+	screenMinutesMetric = "screen_minutes"
+	safariMinutesMetric = "safari_minutes"
+)
+
+// sqliteFileHeader は SQLite のDBファイルの先頭（knowledgeC.db とCSVを見分ける）
+const sqliteFileHeader = "SQLite format 3\x00"
+
+// safariBundleIDs はスクリーンタイムで Safari として数えるアプリ（macOS と iOS）
+var safariBundleIDs = []string{"com.apple.Safari", "com.apple.mobilesafari"}
+
+// knowledgeAppUsageQuery は knowledgeC.db のアプリの使用時間（開始・終了は Core Data タイムスタンプ）
+const knowledgeAppUsageQuery = `
+	SELECT COALESCE(ZVALUESTRING, ''), ZSTARTDATE, ZENDDATE FROM ZOBJECT
+	WHERE ZSTREAMNAME = '/app/usage' AND ZENDDATE > ZSTARTDATE`
+
+// ScreenTimeDay はスクリーンタイムと Safari の訪問数を日付で揃えた1日分
+type ScreenTimeDay struct {
+	Date          string  `json:"date"`
+	ScreenMinutes float64 `json:"screen_minutes"`
+	SafariMinutes float64 `json:"safari_minutes"`
+	Visits        int     `json:"visits"`
+	// VisitsPerHour はスクリーンタイム1時間あたりの訪問数（スクリーンタイムが0なら0）
+	VisitsPerHour float64 `json:"visits_per_hour"`
+}
+
+// ScreenTimeReport は -screentime の結果
+type ScreenTimeReport struct {
+	File string `json:"file"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// HasApps はアプリごとの使用時間があり Safari の時間を数えられたか
+	HasApps            bool                `json:"has_apps"`
+	TotalScreenMinutes float64             `json:"total_screen_minutes"`
+	TotalSafariMinutes float64             `json:"total_safari_minutes"`
+	TotalVisits        int                 `json:"total_visits"`
+	Correlations       []MetricCorrelation `json:"correlations"`
+	Days               []ScreenTimeDay     `json:"days"`
+}
+
+// LoadScreenTime はスクリーンタイムの使用時間を日ごとの指標として読み込む
+// knowledgeC.db（~/Library/Application Support/Knowledge/knowledgeC.db のコピー）か、
+// 1行にアプリ1つ・1日分の使用時間を書いたCSV（date と minutes・seconds・hours のいずれか、任意で app の列）を受け付ける
+func LoadScreenTime(path string) (*JoinedMetrics, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("スクリーンタイムのファイルを開けませんでした: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	r := bufio.NewReader(f)
+	if header, _ := r.Peek(len(sqliteFileHeader)); bytes.Equal(header, []byte(sqliteFileHeader)) {
+		return loadKnowledgeScreenTime(path)
+	}
+	metrics, err := parseScreenTimeCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	metrics.Path = path
+	return metrics, nil
+}
+
+// screenTimeAccumulator は日ごとのスクリーンタイムと Safari の使用時間（分）を足し合わせる
+type screenTimeAccumulator struct {
+	metrics *JoinedMetrics
+}
+
+func newScreenTimeAccumulator(path string, hasApps bool) screenTimeAccumulator {
+	metrics := &JoinedMetrics{Path: path, JoinOn: DefaultJoinOn, Metrics: []string{screenMinutesMetric}, Values: make(map[string]map[string]float64)}
+	if hasApps {
+		metrics.Metrics = append(metrics.Metrics, safariMinutesMetric)
+	}
+	return screenTimeAccumulator{metrics: metrics}
+}
+
+// add は date の使用時間を足す（app が Safari なら Safari の時間にも足す）
+func (a screenTimeAccumulator) add(date, app string, minutes float64) {
+	values := a.metrics.Values[date]
+	if values == nil {
+		values = map[string]float64{screenMinutesMetric: 0}
+		if len(a.metrics.Metrics) > 1 {
+			values[safariMinutesMetric] = 0
+		}
+		a.metrics.Values[date] = values
+	}
+	values[screenMinutesMetric] += minutes
+	if isSafariApp(app) {
+		values[safariMinutesMetric] += minutes
+	}
+}
+
+// isSafariApp は app が Safari（バンドルIDかアプリ名）か
+func isSafariApp(app string) bool {
+	if strings.EqualFold(app, "Safari") {
+		return true
+	}
+	for _, id := range safariBundleIDs {
+		if strings.EqualFold(app, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadKnowledgeScreenTime は knowledgeC.db のアプリの使用時間を開始した日ごとに足し合わせる
+func loadKnowledgeScreenTime(path string) (*JoinedMetrics, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+	rows, err := db.Query(knowledgeAppUsageQuery)
+	if err != nil {
+		return nil, fmt.Errorf("knowledgeC.db のアプリの使用時間の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	acc := newScreenTimeAccumulator(path, true)
+	for rows.Next() {
+		var app string
+		var start, end float64
+		if err := rows.Scan(&app, &start, &end); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		acc.add(convertCoreDataTimestamp(start).Format(TimeFormatDate), app, (end-start)/60)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	return acc.metrics, nil
+}
+
+// screenTimeDurationColumns は使用時間の列の名前と、分に直す倍率
+var screenTimeDurationColumns = []struct {
+	name   string
+	factor float64
+}{{"minutes", 1}, {"seconds", 1.0 / 60}, {"hours", 60}}
+
+// parseScreenTimeCSV はスクリーンタイムのCSVを日ごとに足し合わせる（app の列がなければ Safari の時間は数えない）
+func parseScreenTimeCSV(r io.Reader) (*JoinedMetrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("見出しの行がありません")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSVの読み取りに失敗: %w", err)
+	}
+	dateColumn, appColumn, durationColumn, factor := -1, -1, -1, 0.0
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case DefaultJoinOn:
+			dateColumn = i
+		case "app", "bundle_id":
+			appColumn = i
+		}
+		for _, c := range screenTimeDurationColumns {
+			if name == c.name && durationColumn < 0 {
+				durationColumn, factor = i, c.factor
+			}
+		}
+	}
+	if dateColumn < 0 || durationColumn < 0 {
+		return nil, fmt.Errorf("date の列と、minutes・seconds・hours のいずれかの列が必要です")
+	}
+
+	acc := newScreenTimeAccumulator("", appColumn >= 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSVの読み取りに失敗: %w", err)
+		}
+		cell := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if cell(dateColumn) == "" || cell(durationColumn) == "" {
+			continue
+		}
+		date, err := parseJoinDate(cell(dateColumn))
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %w", line, err)
+		}
+		duration, err := strconv.ParseFloat(cell(durationColumn), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: 使用時間として読めません: %s", line, cell(durationColumn))
+		}
+		acc.add(date, cell(appColumn), duration*factor)
+	}
+	return acc.metrics, nil
+}
+
+// getScreenTimeReport はスクリーンタイムのある日の Safari の訪問数を数え、スクリーンタイムとの相関を求める
+func getScreenTimeReport(db dbQuerier, screenTime *JoinedMetrics, now time.Time, filter SearchFilter) (*ScreenTimeReport, error) {
+	joined, err := getJoinReport(db, screenTime, nil, now, filter)
+	if err != nil {
+		return nil, err
+	}
+	report := &ScreenTimeReport{
+		File:         joined.File,
+		From:         joined.From,
+		To:           joined.To,
+		HasApps:      len(screenTime.Metrics) > 1,
+		Correlations: joined.Correlations,
+		Days:         []ScreenTimeDay{},
+	}
+	for _, d := range joined.Daily {
+		day := ScreenTimeDay{Date: d.Date, ScreenMinutes: d.Metrics[screenMinutesMetric], SafariMinutes: d.Metrics[safariMinutesMetric], Visits: d.Visits}
+		if day.ScreenMinutes > 0 {
+			day.VisitsPerHour = float64(day.Visits) / (day.ScreenMinutes / 60)
+		}
+		report.Days = append(report.Days, day)
+		report.TotalScreenMinutes += day.ScreenMinutes
+		report.TotalSafariMinutes += day.SafariMinutes
+		report.TotalVisits += day.Visits
+	}
+	return report, nil
+}
+
+// formatScreenMinutes は使用時間（分）を「時:分」で表示する
+func formatScreenMinutes(minutes float64) string {
+	return formatTimesheetMinutes(int(math.Round(minutes)))
+}
+
+// screenTimeMetricLabel はスクリーンタイムの指標の表示名
+func screenTimeMetricLabel(metric string) string {
+	switch metric {
+	case screenMinutesMetric:
+		return "スクリーンタイム"
+	case safariMinutesMetric:
+		return "Safari の使用時間"
+	}
+	return metric
+}
+
+// screenTimeTitle は -screentime の見出し
+func screenTimeTitle(report ScreenTimeReport) string {
+	if len(report.Days) == 0 {
+		return fmt.Sprintf("📱 スクリーンタイムと Safari の訪問数 (%s、履歴と重なる日なし)", report.File)
+	}
+	return fmt.Sprintf("📱 スクリーンタイムと Safari の訪問数 (%s〜%s、%d日間)", report.From, report.To, len(report.Days))
+}
+
+// visitsPerHour はスクリーンタイム1時間あたりの訪問数の表示（スクリーンタイムが0なら空）
+func visitsPerHour(visits int, screenMinutes float64) string {
+	if screenMinutes <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", float64(visits)/(screenMinutes/60))
+}
+
+// printScreenTimeReport はスクリーンタイムと訪問数の合計・相関・日別をテキストで表示する
+func printScreenTimeReport(w io.Writer, report ScreenTimeReport) {
+	_, _ = fmt.Fprintln(w, screenTimeTitle(report))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	if len(report.Days) == 0 {
+		_, _ = fmt.Fprintf(w, "  スクリーンタイムのある日の履歴がありません\n\n")
+		return
+	}
+	total := fmt.Sprintf("  合計 スクリーンタイム %s", formatScreenMinutes(report.TotalScreenMinutes))
+	if report.HasApps && report.TotalScreenMinutes > 0 {
+		total += fmt.Sprintf("  Safari %s (%.0f%%)", formatScreenMinutes(report.TotalSafariMinutes), report.TotalSafariMinutes/report.TotalScreenMinutes*100)
+	}
+	total += fmt.Sprintf("  訪問数 %d", report.TotalVisits)
+	if perHour := visitsPerHour(report.TotalVisits, report.TotalScreenMinutes); perHour != "" {
+		total += fmt.Sprintf("（1時間あたり %s件）", perHour)
+	}
+	_, _ = fmt.Fprintln(w, total)
+	for _, c := range report.Correlations {
+		_, _ = fmt.Fprintf(w, "  訪問数 × %s: r=%+.2f ρ=%+.2f（%s、%d日）\n", screenTimeMetricLabel(c.Metric), c.Pearson, c.Spearman, correlationStrength(c.Pearson), c.Days)
+	}
+	_, _ = fmt.Fprintf(w, "  日別:\n")
+	for _, d := range report.Days {
+		line := fmt.Sprintf("    %s  画面 %6s", forecastDateLabel(d.Date), formatScreenMinutes(d.ScreenMinutes))
+		if report.HasApps {
+			line += fmt.Sprintf("  Safari %6s", formatScreenMinutes(d.SafariMinutes))
+		}
+		line += fmt.Sprintf("  訪問 %5d", d.Visits)
+		if perHour := visitsPerHour(d.Visits, d.ScreenMinutes); perHour != "" {
+			line += fmt.Sprintf("  %s件/時", perHour)
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+	_, _ = fmt.Fprintln(w)
+}
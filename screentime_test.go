@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScreenTimeCSV(t *testing.T) {
+	input := "Date,App,Seconds\n" +
+		"2025-01-01,com.apple.Safari,1800\n" +
+		"2025-01-01,com.apple.Terminal,3600\n" +
+		"2025-01-02,Safari,600\n" +
+		"2025-01-02,Mail,\n"
+	metrics, err := parseScreenTimeCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseScreenTimeCSV: %v", err)
+	}
+	if fmt.Sprint(metrics.Metrics) != "[screen_minutes safari_minutes]" {
+		t.Errorf("Metrics = %v", metrics.Metrics)
+	}
+	if got := fmt.Sprint(metrics.Values); got != "map[2025-01-01:map[safari_minutes:30 screen_minutes:90] 2025-01-02:map[safari_minutes:10 screen_minutes:10]]" {
+		t.Errorf("Values = %s", got)
+	}
+
+	// app の列がなければ Safari の時間は数えない
+	metrics, err = parseScreenTimeCSV(strings.NewReader("date,hours\n2025-01-01,1.5\n"))
+	if err != nil {
+		t.Fatalf("parseScreenTimeCSV: %v", err)
+	}
+	if fmt.Sprint(metrics.Metrics) != "[screen_minutes]" || metrics.Values["2025-01-01"][screenMinutesMetric] != 90 {
+		t.Errorf("metrics = %+v", metrics)
+	}
+
+	for _, tt := range []struct {
+		input string
+		want  string
+	}{
+		{"date,app\n2025-01-01,Safari\n", "minutes・seconds・hours のいずれかの列が必要です"},
+		{"date,minutes\n2025-01-01,abc\n", "2行目: 使用時間として読めません: abc"},
+	} {
+		if _, err := parseScreenTimeCSV(strings.NewReader(tt.input)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseScreenTimeCSV(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestLoadScreenTimeKnowledgeDB(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	path := filepath.Join(t.TempDir(), "knowledgeC.db")
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatalf("DB作成に失敗: %v", err)
+	}
+	start := convertToTimestamp(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	if _, err := db.Exec(`CREATE TABLE ZOBJECT (Z_PK INTEGER PRIMARY KEY, ZSTREAMNAME TEXT, ZVALUESTRING TEXT, ZSTARTDATE REAL, ZENDDATE REAL);
+		INSERT INTO ZOBJECT (ZSTREAMNAME, ZVALUESTRING, ZSTARTDATE, ZENDDATE) VALUES
+		('/app/usage', 'com.apple.Safari', ?1, ?1 + 1200),
+		('/app/usage', 'com.apple.dt.Xcode', ?1 + 1200, ?1 + 4800),
+		('/display/isBacklit', NULL, ?1, ?1 + 9000)`, start); err != nil {
+		t.Fatalf("ZOBJECT作成に失敗: %v", err)
+	}
+	_ = db.Close()
+
+	metrics, err := LoadScreenTime(path)
+	if err != nil {
+		t.Fatalf("LoadScreenTime: %v", err)
+	}
+	if got := fmt.Sprint(metrics.Values); got != "map[2025-01-01:map[safari_minutes:20 screen_minutes:80]]" {
+		t.Errorf("Values = %s", got)
+	}
+}
+
+func TestGetScreenTimeReport(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 訪問は 1/1: 3件、1/2: 2件、1/3: 0件
+	path := filepath.Join(t.TempDir(), "screentime.csv")
+	csv := "date,app,minutes\n2025-01-01,Safari,30\n2025-01-01,Xcode,150\n2025-01-02,Safari,20\n2025-01-02,Xcode,100\n2025-01-03,Xcode,60\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatalf("CSVの書き込みに失敗: %v", err)
+	}
+	screenTime, err := LoadScreenTime(path)
+	if err != nil {
+		t.Fatalf("LoadScreenTime: %v", err)
+	}
+	report, err := getScreenTimeReport(db, screenTime, time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC), SearchFilter{})
+	if err != nil {
+		t.Fatalf("getScreenTimeReport: %v", err)
+	}
+	if !report.HasApps || len(report.Days) != 3 || report.TotalScreenMinutes != 360 || report.TotalSafariMinutes != 50 || report.TotalVisits != 5 {
+		t.Errorf("report = %+v", report)
+	}
+	if d := report.Days[0]; d.Date != "2025-01-01" || d.ScreenMinutes != 180 || d.Visits != 3 || d.VisitsPerHour != 1 {
+		t.Errorf("1日目 = %+v", d)
+	}
+	var got []string
+	for _, c := range report.Correlations {
+		got = append(got, fmt.Sprintf("%s/%d/%.2f", c.Metric, c.Days, c.Pearson))
+	}
+	if fmt.Sprint(got) != "[screen_minutes/3/0.98 safari_minutes/3/1.00]" {
+		t.Errorf("Correlations = %v", got)
+	}
+
+	var buf bytes.Buffer
+	printScreenTimeReport(&buf, *report)
+	for _, want := range []string{"2025-01-01〜2025-01-03、3日間", "合計 スクリーンタイム 6:00  Safari 0:50 (14%)  訪問数 5（1時間あたり 0.8件）", "訪問数 × スクリーンタイム: r=+0.98", "訪問数 × Safari の使用時間: r=+1.00", "2025-01-01 水曜  画面   3:00  Safari   0:30  訪問     3  1.0件/時"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("出力に %q がない:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// searchFacetDomainLimit は検索結果のドメインのファセットに表示するドメイン数
+const searchFacetDomainLimit = 20
+
+// 検索結果をファセットで絞り込むクエリパラメータ（共通の絞り込みパラメータと併用できる）
+const (
+	searchFacetDomainParam   = "f_domain"
+	searchFacetMonthParam    = "f_month"
+	searchFacetCategoryParam = "f_category"
+)
+
+// searchMonthFormat は月のファセットの値の形式
+const searchMonthFormat = "2006-01"
+
+// FacetCount はファセットの値1つと一致する訪問数
+type FacetCount struct {
+	Value    string `json:"value"`
+	Count    int    `json:"count"`
+	Selected bool   `json:"selected,omitempty"`
+}
+
+// SearchFacets は検索結果のファセット
+// 各ファセットの件数は、そのファセット以外の選択で絞り込んだ訪問から数える（選んだ値以外に切り替えたときの件数がわかる）
+type SearchFacets struct {
+	Domains []FacetCount `json:"domains"`
+	Months  []FacetCount `json:"months"`
+	// Categories は categories.txt のカテゴリ（設定していなければ省略）
+	Categories []FacetCount `json:"categories,omitempty"`
+}
+
+// SearchSelection はファセットで選んだ値（空なら絞り込まない）
+type SearchSelection struct {
+	Domain   string `json:"domain,omitempty"`
+	Month    string `json:"month,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// SearchResults は GET /api/search のレスポンス
+type SearchResults struct {
+	Total    int             `json:"total"`
+	Offset   int             `json:"offset"`
+	Visits   []HistoryVisit  `json:"visits"`
+	Facets   SearchFacets    `json:"facets"`
+	Selected SearchSelection `json:"selected"`
+}
+
+// searchWithFacets はフィルタに一致する訪問を1回だけ読み、ファセットで選んだ値に一致する訪問の offset 件目から limit 件と、
+// ドメイン・月・カテゴリごとの件数を返す。リダクト対象のドメインは [redacted] として数える
+func searchWithFacets(db dbQuerier, filter SearchFilter, selected SearchSelection, categories []DomainCategory, redactDomains []string, offset, limit int) (SearchResults, error) {
+	results := SearchResults{Offset: offset, Visits: []HistoryVisit{}, Selected: selected}
+	domains := make(map[string]int)
+	months := make(map[string]int)
+	categoryCounts := make(map[string]int)
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		domain := extractDomain(v.URL)
+		category := categoryForDomain(domain, categories)
+		if domainMatchesList(domain, redactDomains) {
+			domain = redactedLabel
+		}
+		month := v.VisitTime.Format(searchMonthFormat)
+
+		inDomain := selected.Domain == "" || selected.Domain == domain
+		inMonth := selected.Month == "" || selected.Month == month
+		inCategory := selected.Category == "" || selected.Category == category
+		if inMonth && inCategory {
+			domains[domain]++
+		}
+		if inDomain && inCategory {
+			months[month]++
+		}
+		if inDomain && inMonth {
+			categoryCounts[category]++
+		}
+		if !inDomain || !inMonth || !inCategory {
+			return nil
+		}
+		if results.Total >= offset && results.Total < offset+limit {
+			results.Visits = append(results.Visits, v)
+		}
+		results.Total++
+		return nil
+	})
+	if err != nil {
+		return SearchResults{}, fmt.Errorf("検索に失敗: %w", err)
+	}
+
+	results.Visits = redactVisits(sanitizeVisits(results.Visits), redactDomains)
+	results.Facets.Domains = facetCounts(domains, selected.Domain, searchFacetDomainLimit)
+	results.Facets.Months = facetCounts(months, selected.Month, 0)
+	// 月は件数ではなく新しい順に並べる
+	sort.Slice(results.Facets.Months, func(i, j int) bool {
+		return results.Facets.Months[i].Value > results.Facets.Months[j].Value
+	})
+	if len(categories) > 0 {
+		results.Facets.Categories = facetCounts(categoryCounts, selected.Category, 0)
+	}
+	return results, nil
+}
+
+// facetCounts は件数の多い順に最大 limit 件（0 なら全て）のファセットを返す
+// 選んだ値は limit を超えても含める
+func facetCounts(counts map[string]int, selected string, limit int) []FacetCount {
+	facets := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count, Selected: value == selected})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	if limit <= 0 || len(facets) <= limit {
+		return facets
+	}
+	trimmed := facets[:limit]
+	for _, f := range facets[limit:] {
+		if f.Selected {
+			trimmed = append(trimmed, f)
+		}
+	}
+	return trimmed
+}
+
+// searchRequest はリクエストの絞り込み・ファセットの選択・ページ番号
+type searchRequest struct {
+	filter   SearchFilter
+	selected SearchSelection
+	page     int
+}
+
+// parseSearchRequest はリクエストの絞り込み・ファセットの選択・ページ番号を読み取る
+func (s *WebServer) parseSearchRequest(r *http.Request) (searchRequest, error) {
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		return searchRequest{}, err
+	}
+	q := r.URL.Query()
+	selected := SearchSelection{
+		Domain:   q.Get(searchFacetDomainParam),
+		Month:    q.Get(searchFacetMonthParam),
+		Category: q.Get(searchFacetCategoryParam),
+	}
+	page := 1
+	if p, err := strconv.Atoi(q.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	return searchRequest{filter: filter, selected: selected, page: page}, nil
+}
+
+// search はリクエストの条件で1ページ分（WebPageSize 件）を検索する
+func (s *WebServer) search(req searchRequest) (SearchResults, error) {
+	categories, err := LoadDomainCategories()
+	if err != nil {
+		return SearchResults{}, err
+	}
+	return searchWithFacets(s.db, req.filter, req.selected, categories, s.redactList(), (req.page-1)*WebPageSize, WebPageSize)
+}
+
+// handleAPISearch は検索結果とファセットをJSONで返す
+func (s *WebServer) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	req, err := s.parseSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := s.search(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SearchPageData は検索ページ（/search）用のデータ
+type SearchPageData struct {
+	Theme    PageTheme
+	Favicons bool
+	Results  SearchResults
+	Search   string
+	From     string
+	To       string
+	Page     int
+	HasNext  bool
+	// query はファセット・ページのリンクに引き継ぐクエリパラメータ
+	query url.Values
+}
+
+// Link はクエリパラメータ key を value にした検索ページのURL（クエリ部分）を返す（value が空なら外す。テンプレートから呼ぶ）
+// 絞り込みを変えると結果の件数が変わるため、ページ番号は外す
+func (d SearchPageData) Link(key, value string) string {
+	query := url.Values{}
+	for k, values := range d.query {
+		query[k] = values
+	}
+	query.Del("page")
+	query.Del("theme")
+	if value == "" {
+		query.Del(key)
+	} else {
+		query.Set(key, value)
+	}
+	return "?" + query.Encode()
+}
+
+// PageLink は検索結果の page ページ目のURL（クエリ部分）を返す（テンプレートから呼ぶ）
+func (d SearchPageData) PageLink(page int) string {
+	link := d.Link("page", "")
+	if page <= 1 {
+		return link
+	}
+	if link != "?" {
+		link += "&"
+	}
+	return link + "page=" + strconv.Itoa(page)
+}
+
+// handleSearchPage は検索結果とファセットのページを表示する
+func (s *WebServer) handleSearchPage(w http.ResponseWriter, r *http.Request) {
+	req, err := s.parseSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := s.search(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := r.URL.Query()
+	data := SearchPageData{
+		Theme:    requestTheme(w, r),
+		Favicons: s.favicons != nil,
+		Results:  results,
+		Search:   q.Get("search"),
+		From:     q.Get("from"),
+		To:       q.Get("to"),
+		Page:     req.page,
+		HasNext:  results.Offset+len(results.Visits) < results.Total,
+		query:    q,
+	}
+	if err := s.templates.ExecuteTemplate(w, "search.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
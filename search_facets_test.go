@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// facetValues はファセットを「値:件数」の並びにする
+func facetValues(facets []FacetCount) string {
+	var parts []string
+	for _, f := range facets {
+		part := f.Value + ":" + strconv.Itoa(f.Count)
+		if f.Selected {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestSearchWithFacets(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	// 2025-02 の訪問を足す
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 3, ?, 'Google Search')`, 757418400.0+40*86400); err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+	categories := []DomainCategory{{Domain: "github.com", Category: "開発"}, {Domain: "google.com", Category: "調べもの"}}
+
+	results, err := searchWithFacets(db, SearchFilter{}, SearchSelection{}, categories, []string{"youtube.com"}, 0, 10)
+	if err != nil {
+		t.Fatalf("searchWithFacets: %v", err)
+	}
+	if results.Total != 6 || len(results.Visits) != 6 || results.Visits[0].ID != 6 {
+		t.Errorf("結果 = %d件 %+v", results.Total, results.Visits)
+	}
+	if got := facetValues(results.Facets.Domains); got != "[redacted]:2 github.com:2 google.com:2" {
+		t.Errorf("ドメインのファセット = %s", got)
+	}
+	if got := facetValues(results.Facets.Months); got != "2025-02:1 2025-01:5" {
+		t.Errorf("月のファセット = %s", got)
+	}
+	if got := facetValues(results.Facets.Categories); got != "未分類:2 調べもの:2 開発:2" {
+		t.Errorf("カテゴリのファセット = %s", got)
+	}
+
+	// 選んだファセット以外の件数は、他の選択だけで絞り込んで数える
+	selected := SearchSelection{Domain: "google.com", Month: "2025-01"}
+	results, err = searchWithFacets(db, SearchFilter{}, selected, categories, []string{"youtube.com"}, 0, 10)
+	if err != nil {
+		t.Fatalf("searchWithFacets: %v", err)
+	}
+	if results.Total != 1 || results.Visits[0].ID != 3 {
+		t.Errorf("google.com・2025-01 の結果 = %d件 %+v", results.Total, results.Visits)
+	}
+	if got := facetValues(results.Facets.Domains); got != "[redacted]:2 github.com:2 google.com:1*" {
+		t.Errorf("ドメインのファセット = %s", got)
+	}
+	if got := facetValues(results.Facets.Months); got != "2025-02:1 2025-01:1*" {
+		t.Errorf("月のファセット = %s", got)
+	}
+	if got := facetValues(results.Facets.Categories); got != "調べもの:1" {
+		t.Errorf("カテゴリのファセット = %s", got)
+	}
+
+	// リダクトした訪問もファセットで選べ、ページの範囲外の訪問は返さない
+	results, err = searchWithFacets(db, SearchFilter{}, SearchSelection{Domain: redactedLabel}, nil, []string{"youtube.com"}, 1, 10)
+	if err != nil {
+		t.Fatalf("searchWithFacets: %v", err)
+	}
+	if results.Total != 2 || len(results.Visits) != 1 || results.Visits[0].URL != redactedLabel || results.Facets.Categories != nil {
+		t.Errorf("[redacted] の2件目 = %+v", results)
+	}
+}
+
+func TestFacetCountsLimit(t *testing.T) {
+	counts := map[string]int{"a": 5, "b": 4, "c": 3, "d": 1}
+	if got := facetValues(facetCounts(counts, "", 2)); got != "a:5 b:4" {
+		t.Errorf("facetCounts(limit 2) = %s", got)
+	}
+	if got := facetValues(facetCounts(counts, "d", 2)); got != "a:5 b:4 d:1*" {
+		t.Errorf("選んだ値を残していない: %s", got)
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.MkdirAll(filepath.Join(dir, "hist"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hist", categoriesFileName), []byte("github.com 開発\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl}
+	defer func() { _ = s.db.Close() }()
+
+	rec := httptest.NewRecorder()
+	s.handleSearchPage(rec, httptest.NewRequest("GET", "/search?search=github&page=1", nil))
+	body := rec.Body.String()
+	if rec.Code != 200 {
+		t.Fatalf("ステータス = %d: %s", rec.Code, body)
+	}
+	if !strings.Contains(body, "2件") || !strings.Contains(body, `href="?f_domain=github.com&amp;search=github"`) || !strings.Contains(body, "開発") {
+		t.Errorf("検索ページにファセットのリンクがない:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAPISearch(rec, httptest.NewRequest("GET", "/api/search?search=github&f_month=2025-01&f_category=%E9%96%8B%E7%99%BA", nil))
+	var results SearchResults
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("JSON の解析に失敗: %v: %s", err, rec.Body.String())
+	}
+	if results.Total != 2 || results.Selected.Category != "開発" || results.Selected.Month != "2025-01" {
+		t.Errorf("GET /api/search = %+v", results)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAPISearch(rec, httptest.NewRequest("GET", "/api/search?from=2025-13-01", nil))
+	if rec.Code != 400 {
+		t.Errorf("不正な日付のステータス = %d", rec.Code)
+	}
+}
+
+func TestSearchPageDataLinks(t *testing.T) {
+	d := SearchPageData{query: map[string][]string{"search": {"go"}, "page": {"3"}, "theme": {"dark"}, "f_month": {"2025-01"}}}
+	if got := d.Link("f_domain", "go.dev"); got != "?f_domain=go.dev&f_month=2025-01&search=go" {
+		t.Errorf("Link = %s", got)
+	}
+	if got := d.Link("f_month", ""); got != "?search=go" {
+		t.Errorf("Link で外す = %s", got)
+	}
+	if got := d.PageLink(2); got != "?f_month=2025-01&search=go&page=2" {
+		t.Errorf("PageLink = %s", got)
+	}
+	if got := (SearchPageData{}).PageLink(2); got != "?page=2" {
+		t.Errorf("PageLink（クエリなし） = %s", got)
+	}
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	searchIndexFileName = "search_index.db"
+
+	// searchIndexMinKeywordLength 文字未満のキーワードはトライグラムの索引を引けないため LIKE で検索する
+	searchIndexMinKeywordLength = 3
+	// searchIndexRefreshInterval 以上経ってから検索したときは、先に索引を差分更新する
+	searchIndexRefreshInterval = time.Minute
+	// searchIndexCacheSize はキーワードごとに覚えておく検索結果の最大数
+	searchIndexCacheSize = 32
+	// searchIndexFormat は索引に格納する文字列の形式（変わったら作り直す）
+	//  1: normalizeSearchText でひらがな・カタカナまで正規化したURL・タイトル
+	searchIndexFormat = 1
+)
+
+// searchIndexSchema は索引DBのスキーマ
+// visit_text の rowid は history_visits.id。トライグラムで区切るため部分一致の検索に索引が効く
+// URL・タイトルは正規化して格納し、キーワードも同じく正規化して引く
+const searchIndexSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS visit_text USING fts5(url, title, tokenize = 'trigram');
+	CREATE TABLE IF NOT EXISTS index_meta (key TEXT PRIMARY KEY, value TEXT NOT NULL);`
+
+// errFTS5Unavailable は SQLite が FTS5 なしでビルドされている場合のエラー
+var errFTS5Unavailable = errors.New("このビルドでは全文検索（FTS5）が使えません（go build -tags sqlite_fts5 でビルドしてください）")
+
+// getCacheDir はキャッシュディレクトリのパスを返す（$XDG_CACHE_HOME/hist、未設定なら ~/.cache/hist）
+func getCacheDir() (string, error) {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
+		}
+		cacheHome = filepath.Join(homeDir, ".cache")
+	}
+	return filepath.Join(cacheHome, configDirName), nil
+}
+
+// getSearchIndexPath は全文検索の索引DBのパスを返す
+func getSearchIndexPath() (string, error) {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, searchIndexFileName), nil
+}
+
+// SearchIndex はSafari履歴のURL・タイトルの全文検索の索引（hist 自身のキャッシュDBに置く）
+// キーワード検索では索引で一致する訪問IDを引き、履歴DBへのクエリをそのIDに絞る
+type SearchIndex struct {
+	db  *sql.DB
+	src dbQuerier
+
+	mu        sync.Mutex
+	refreshed time.Time
+	matches   map[string]string // キーワード → 一致した訪問IDのJSON配列
+}
+
+// SearchIndexUpdate は索引の更新結果
+type SearchIndexUpdate struct {
+	Added   int
+	Removed int
+	Total   int
+}
+
+// createSearchIndex は索引DBを開き、なければ作成する
+func createSearchIndex(path string, src dbQuerier) (*SearchIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerms); err != nil {
+		return nil, fmt.Errorf("キャッシュディレクトリの作成に失敗: %w", err)
+	}
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("索引DBを開けませんでした: %w", err)
+	}
+	if _, err := db.Exec(searchIndexSchema); err != nil {
+		_ = db.Close()
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil, errFTS5Unavailable
+		}
+		return nil, fmt.Errorf("索引DBの作成に失敗: %w", err)
+	}
+	return &SearchIndex{db: db, src: src, matches: make(map[string]string)}, nil
+}
+
+// openSearchIndex は索引DBがあれば開く（hist index build で作成していなければ nil）
+func openSearchIndex(path string, src dbQuerier) (*SearchIndex, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("索引DBの確認に失敗: %w", err)
+	}
+	return createSearchIndex(path, src)
+}
+
+// Close は索引DBを閉じる
+func (idx *SearchIndex) Close() error {
+	return idx.db.Close()
+}
+
+// metaInt は index_meta の整数値を返す（未設定なら 0）
+func (idx *SearchIndex) metaInt(q dbQuerier, key string) (int64, error) {
+	var value string
+	err := q.QueryRow(`SELECT value FROM index_meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("索引の情報の取得に失敗: %w", err)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Update は履歴DBの変更を索引に反映する
+// 前回より後に追加された訪問を足し、削除された訪問を除く。rebuild なら作り直す
+// 履歴DBの最大IDが前回より小さい場合（履歴DBが置き換えられた場合）も作り直す
+func (idx *SearchIndex) Update(rebuild bool) (SearchIndexUpdate, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.update(rebuild)
+}
+
+func (idx *SearchIndex) update(rebuild bool) (SearchIndexUpdate, error) {
+	var result SearchIndexUpdate
+
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	lastID, err := idx.metaInt(tx, "last_visit_id")
+	if err != nil {
+		return result, err
+	}
+	format, err := idx.metaInt(tx, "format")
+	if err != nil {
+		return result, err
+	}
+	if format != searchIndexFormat {
+		rebuild = true
+	}
+	var maxID int64
+	if err := idx.src.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM history_visits`).Scan(&maxID); err != nil {
+		return result, fmt.Errorf("訪問IDの取得に失敗: %w", err)
+	}
+	if maxID < lastID {
+		rebuild = true
+	}
+	// hist gc で保持期間を適用した索引には、保持期間より前の訪問を入れない
+	minVisitTime, err := idx.retainFrom(tx)
+	if err != nil {
+		return result, err
+	}
+	if rebuild {
+		if _, err := tx.Exec(`DELETE FROM visit_text`); err != nil {
+			return result, fmt.Errorf("索引の削除に失敗: %w", err)
+		}
+		lastID = 0
+	}
+
+	if lastID > 0 {
+		result.Removed, err = idx.removeDeleted(tx, lastID, minVisitTime)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	rows, err := idx.src.Query(`
+		SELECT hv.id, hi.url, COALESCE(hv.title, '')
+		FROM history_visits hv
+		JOIN history_items hi ON hv.history_item = hi.id
+		WHERE hv.id > ? AND hv.visit_time >= ?
+		ORDER BY hv.id`, lastID, minVisitTime)
+	if err != nil {
+		return result, fmt.Errorf("訪問履歴の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	stmt, err := tx.Prepare(`INSERT INTO visit_text (rowid, url, title) VALUES (?, ?, ?)`)
+	if err != nil {
+		return result, fmt.Errorf("クエリの準備に失敗: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for rows.Next() {
+		var id int64
+		var url, title string
+		if err := rows.Scan(&id, &url, &title); err != nil {
+			return result, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if _, err := stmt.Exec(id, normalizeSearchText(url, true), normalizeSearchText(title, true)); err != nil {
+			return result, fmt.Errorf("索引への追加に失敗: %w", err)
+		}
+		result.Added++
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO index_meta (key, value) VALUES
+		('last_visit_id', ?), ('updated_at', ?), ('format', ?)`,
+		strconv.FormatInt(maxID, 10), strconv.FormatInt(time.Now().Unix(), 10), strconv.Itoa(searchIndexFormat)); err != nil {
+		return result, fmt.Errorf("索引の情報の更新に失敗: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM visit_text`).Scan(&result.Total); err != nil {
+		return result, fmt.Errorf("索引の件数の取得に失敗: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("索引の更新に失敗: %w", err)
+	}
+
+	idx.refreshed = time.Now()
+	clear(idx.matches)
+	return result, nil
+}
+
+// retainFrom は索引に入れる訪問の最も古い訪問時刻（Core Data timestamp）を返す
+// hist gc で保持期間を適用していなければ全ての訪問を入れる
+func (idx *SearchIndex) retainFrom(q dbQuerier) (float64, error) {
+	from, err := idx.metaInt(q, "retain_from")
+	if err != nil || from == 0 {
+		return -math.MaxFloat64, err
+	}
+	return convertToTimestamp(time.Unix(from, 0)), nil
+}
+
+// removeDeleted は履歴DBから削除された訪問（lastID 以下）を索引から除き、除いた件数を返す
+// minVisitTime より前の訪問は索引にないものとして数える。件数が一致すれば削除はないとみなし、IDの照合を省く
+func (idx *SearchIndex) removeDeleted(tx *sql.Tx, lastID int64, minVisitTime float64) (int, error) {
+	var srcCount, indexCount int
+	if err := idx.src.QueryRow(`SELECT COUNT(*) FROM history_visits WHERE id <= ? AND visit_time >= ?`, lastID, minVisitTime).Scan(&srcCount); err != nil {
+		return 0, fmt.Errorf("訪問数の取得に失敗: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM visit_text`).Scan(&indexCount); err != nil {
+		return 0, fmt.Errorf("索引の件数の取得に失敗: %w", err)
+	}
+	if srcCount == indexCount {
+		return 0, nil
+	}
+
+	alive := make(map[int64]bool, srcCount)
+	rows, err := idx.src.Query(`SELECT id FROM history_visits WHERE id <= ? AND visit_time >= ?`, lastID, minVisitTime)
+	if err != nil {
+		return 0, fmt.Errorf("訪問IDの取得に失敗: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		alive[id] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	var deleted []int64
+	indexRows, err := tx.Query(`SELECT rowid FROM visit_text`)
+	if err != nil {
+		return 0, fmt.Errorf("索引の取得に失敗: %w", err)
+	}
+	for indexRows.Next() {
+		var id int64
+		if err := indexRows.Scan(&id); err != nil {
+			_ = indexRows.Close()
+			return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if !alive[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	_ = indexRows.Close()
+	if err := indexRows.Err(); err != nil {
+		return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	for _, id := range deleted {
+		if _, err := tx.Exec(`DELETE FROM visit_text WHERE rowid = ?`, id); err != nil {
+			return 0, fmt.Errorf("索引からの削除に失敗: %w", err)
+		}
+	}
+	return len(deleted), nil
+}
+
+// Expire は before より前の訪問を索引から除き、除いた件数を返す（dryRun なら数えるだけ）
+// 以降の更新でも before より前の訪問は追加しない（保持期間を延ばした場合は hist index build -rebuild で戻せる）
+func (idx *SearchIndex) Expire(before time.Time, dryRun bool) (int, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	expired := make(map[int64]bool)
+	rows, err := idx.src.Query(`SELECT id FROM history_visits WHERE visit_time < ?`, convertToTimestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("訪問IDの取得に失敗: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		expired[id] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var deleted []int64
+	indexRows, err := tx.Query(`SELECT rowid FROM visit_text`)
+	if err != nil {
+		return 0, fmt.Errorf("索引の取得に失敗: %w", err)
+	}
+	for indexRows.Next() {
+		var id int64
+		if err := indexRows.Scan(&id); err != nil {
+			_ = indexRows.Close()
+			return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if expired[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	_ = indexRows.Close()
+	if err := indexRows.Err(); err != nil {
+		return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	if dryRun {
+		return len(deleted), nil
+	}
+
+	for _, id := range deleted {
+		if _, err := tx.Exec(`DELETE FROM visit_text WHERE rowid = ?`, id); err != nil {
+			return 0, fmt.Errorf("索引からの削除に失敗: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO index_meta (key, value) VALUES ('retain_from', ?)`,
+		strconv.FormatInt(before.Unix(), 10)); err != nil {
+		return 0, fmt.Errorf("索引の情報の更新に失敗: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("索引の更新に失敗: %w", err)
+	}
+	clear(idx.matches)
+	return len(deleted), nil
+}
+
+// matchingVisitIDs はURLかタイトルにいずれかのキーワードを含む訪問IDをJSON配列で返す
+// ひらがな・カタカナまで正規化して引くため、WithKeywords の条件で一致する訪問を全て含む
+// 索引を引けないキーワード（短い・ワイルドカードを含む）が1つでもある場合や索引の更新に失敗した場合は
+// false を返し、呼び出し側は LIKE での検索に戻る
+func (idx *SearchIndex) matchingVisitIDs(keywords []string) (string, bool) {
+	var phrases []string
+	for _, k := range keywords {
+		k = normalizeSearchText(k, true)
+		if utf8.RuneCountInString(k) < searchIndexMinKeywordLength || strings.ContainsAny(k, "%_") {
+			return "", false
+		}
+		// トライグラムのフレーズ検索は部分一致になる
+		phrases = append(phrases, `"`+strings.ReplaceAll(k, `"`, `""`)+`"`)
+	}
+	if len(phrases) == 0 {
+		return "", false
+	}
+	query := strings.Join(phrases, " OR ")
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if time.Since(idx.refreshed) >= searchIndexRefreshInterval {
+		if _, err := idx.update(false); err != nil {
+			return "", false
+		}
+	}
+	if ids, ok := idx.matches[query]; ok {
+		return ids, true
+	}
+
+	rows, err := idx.db.Query(`SELECT rowid FROM visit_text WHERE visit_text MATCH ?`, query)
+	if err != nil {
+		return "", false
+	}
+	defer func() { _ = rows.Close() }()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return "", false
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		return "", false
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return "", false
+	}
+
+	if len(idx.matches) >= searchIndexCacheSize {
+		clear(idx.matches)
+	}
+	idx.matches[query] = string(data)
+	return string(data), true
+}
+
+// WithIndexedKeywords はいずれかのキーワードを含む条件を追加する
+// 索引があれば一致する訪問IDに絞ってから LIKE で確かめる（結果は WithKeywords と同じ）
+func (qb *QueryBuilder) WithIndexedKeywords(keywords []string, foldKana bool, idx *SearchIndex) *QueryBuilder {
+	if len(keywords) > 0 && idx != nil {
+		if ids, ok := idx.matchingVisitIDs(keywords); ok {
+			qb.where.WriteString(` AND hv.id IN (SELECT value FROM json_each(?))`)
+			qb.args = append(qb.args, ids)
+		}
+	}
+	return qb.WithKeywords(keywords, foldKana)
+}
+
+// openDefaultSearchIndex は既定の場所の索引DBがあれば開く
+func openDefaultSearchIndex(src dbQuerier) (*SearchIndex, error) {
+	path, err := getSearchIndexPath()
+	if err != nil {
+		return nil, err
+	}
+	return openSearchIndex(path, src)
+}
+
+// runIndexCommand は hist index サブコマンドを実行する
+//
+//	hist index build [-rebuild]  索引を作成・差分更新する
+//	hist index status            索引の場所・件数・更新日時を表示する
+//	hist index drop              索引を削除する
+func runIndexCommand(args []string) error {
+	const usage = "使い方: hist index build [-rebuild] | status | drop"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	path, err := getSearchIndexPath()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "build":
+		fs := flag.NewFlagSet("index build", flag.ContinueOnError)
+		rebuild := fs.Bool("rebuild", false, "差分更新せずに索引を作り直す")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if os.Getenv(HistDBEnv) != "" {
+			return fmt.Errorf("索引は Safari の履歴DBにのみ作成できます（%s を外して実行してください）", HistDBEnv)
+		}
+		db, err := setupDatabase()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+		idx, err := createSearchIndex(path, db)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = idx.Close() }()
+		result, err := idx.Update(*rebuild)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("索引を更新しました: 追加 %d件・削除 %d件（合計 %d件）\n", result.Added, result.Removed, result.Total)
+		fmt.Printf("  %s\n", path)
+		return nil
+
+	case "status":
+		idx, err := openSearchIndex(path, nil)
+		if err != nil {
+			return err
+		}
+		if idx == nil {
+			fmt.Println("索引はありません（hist index build で作成できます）")
+			return nil
+		}
+		defer func() { _ = idx.Close() }()
+		var total int
+		if err := idx.db.QueryRow(`SELECT COUNT(*) FROM visit_text`).Scan(&total); err != nil {
+			return fmt.Errorf("索引の件数の取得に失敗: %w", err)
+		}
+		updatedAt, err := idx.metaInt(idx.db, "updated_at")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("索引: %s\n", path)
+		fmt.Printf("件数: %d件\n", total)
+		if updatedAt > 0 {
+			fmt.Printf("更新: %s\n", time.Unix(updatedAt, 0).Format(TimeFormatFull))
+		}
+		retainFrom, err := idx.metaInt(idx.db, "retain_from")
+		if err != nil {
+			return err
+		}
+		if retainFrom > 0 {
+			fmt.Printf("保持: %s 以降の訪問（hist gc）\n", time.Unix(retainFrom, 0).Format(TimeFormatFull))
+		}
+		return nil
+
+	case "drop":
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Println("索引はありません")
+				return nil
+			}
+			return fmt.Errorf("索引の削除に失敗: %w", err)
+		}
+		fmt.Println("索引を削除しました")
+		return nil
+	}
+	return errors.New(usage)
+}
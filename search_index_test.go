@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestSearchIndex は一時ディレクトリに索引を作成する（FTS5 なしでビルドした場合はスキップ）
+func newTestSearchIndex(t *testing.T, src *sql.DB) *SearchIndex {
+	t.Helper()
+	idx, err := createSearchIndex(filepath.Join(t.TempDir(), "cache", searchIndexFileName), src)
+	if errors.Is(err, errFTS5Unavailable) {
+		t.Skip("FTS5 が使えないためスキップ（-tags sqlite_fts5 で実行）")
+	}
+	if err != nil {
+		t.Fatalf("createSearchIndex() error = %v", err)
+	}
+	t.Cleanup(func() { _ = idx.Close() })
+	return idx
+}
+
+func TestSearchIndexUpdate(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	idx := newTestSearchIndex(t, db)
+
+	result, err := idx.Update(false)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if result.Added != 5 || result.Removed != 0 || result.Total != 5 {
+		t.Errorf("初回の Update() = %+v, want 追加5件・合計5件", result)
+	}
+
+	// 差分更新では追加・削除された訪問だけを反映する
+	if _, err := db.Exec(`
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 3, 757418400 + 100000, 'Google Maps');
+		DELETE FROM history_visits WHERE id = 2;
+	`); err != nil {
+		t.Fatalf("テストデータ更新に失敗: %v", err)
+	}
+	result, err = idx.Update(false)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if result.Added != 1 || result.Removed != 1 || result.Total != 5 {
+		t.Errorf("差分の Update() = %+v, want 追加1件・削除1件・合計5件", result)
+	}
+
+	result, err = idx.Update(true)
+	if err != nil {
+		t.Fatalf("Update(rebuild) error = %v", err)
+	}
+	if result.Added != 5 || result.Total != 5 {
+		t.Errorf("作り直しの Update() = %+v, want 追加5件・合計5件", result)
+	}
+}
+
+func TestSearchIndexKeywordSearch(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	idx := newTestSearchIndex(t, db)
+
+	// 索引を使っても LIKE と同じ訪問に一致する（未作成の索引は検索時に更新される）
+	for _, keyword := range []string{"github", "YouTube", "ＭＵＳＩＣ", "search", "gi", "notfound", "100%"} {
+		want, err := getRecentVisits(db, 100, SearchFilter{Keyword: keyword})
+		if err != nil {
+			t.Fatalf("getRecentVisits() error = %v", err)
+		}
+		got, err := getRecentVisits(db, 100, SearchFilter{Keyword: keyword, Index: idx})
+		if err != nil {
+			t.Fatalf("getRecentVisits(索引あり) error = %v", err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("キーワード %q: 索引あり %d件, LIKE %d件", keyword, len(got), len(want))
+		}
+		for i := range got {
+			if got[i].ID != want[i].ID {
+				t.Errorf("キーワード %q [%d]: ID = %d, want %d", keyword, i, got[i].ID, want[i].ID)
+			}
+		}
+	}
+
+	if _, ok := idx.matchingVisitIDs([]string{"gi"}); ok {
+		t.Error("短いキーワードで索引を引いた")
+	}
+	ids, ok := idx.matchingVisitIDs([]string{"github"})
+	if !ok || ids != "[4,1]" && ids != "[1,4]" {
+		t.Errorf("matchingVisitIDs(\"github\") = %s, %v", ids, ok)
+	}
+}
+
+func TestOpenSearchIndexMissing(t *testing.T) {
+	idx, err := openSearchIndex(filepath.Join(t.TempDir(), searchIndexFileName), nil)
+	if err != nil || idx != nil {
+		t.Errorf("索引がない場合 openSearchIndex() = %v, %v, want nil, nil", idx, err)
+	}
+}
+
+func TestGetSearchIndexPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache")
+	path, err := getSearchIndexPath()
+	if err != nil {
+		t.Fatalf("getSearchIndexPath() error = %v", err)
+	}
+	if want := filepath.Join("/tmp/cache", "hist", searchIndexFileName); path != want {
+		t.Errorf("getSearchIndexPath() = %q, want %q", path, want)
+	}
+}
+
+func TestSearchIndexExpire(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	idx := newTestSearchIndex(t, db)
+	if _, err := idx.Update(false); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	before := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	if n, err := idx.Expire(before, true); err != nil || n != 3 {
+		t.Fatalf("Expire(dryRun) = %d, %v, want 3", n, err)
+	}
+	if n, err := idx.Expire(before, false); err != nil || n != 3 {
+		t.Fatalf("Expire() = %d, %v, want 3", n, err)
+	}
+
+	// 保持期間より前の訪問は、差分更新・作り直しでも索引に戻さない
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 3, ?, 'Old')`,
+		convertToTimestamp(before.AddDate(0, 0, -10))); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := idx.Update(false); err != nil || result.Added != 0 || result.Removed != 0 || result.Total != 2 {
+		t.Errorf("Expire 後の Update() = %+v, %v, want 合計2件", result, err)
+	}
+	if result, err := idx.Update(true); err != nil || result.Added != 2 || result.Total != 2 {
+		t.Errorf("Expire 後の Update(rebuild) = %+v, %v, want 追加2件・合計2件", result, err)
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,11 +30,9 @@ var templateFuncs = template.FuncMap{
 	"formatDate": func(t time.Time) string {
 		return t.Format(TimeFormatDate)
 	},
-	"truncate": func(s string, length int) string {
-		if len(s) <= length {
-			return s
-		}
-		return s[:length-3] + "..."
+	"truncate": truncateText,
+	"redacted": func(domain string) bool {
+		return domain == redactedLabel
 	},
 	"percentage": func(count, max int) float64 {
 		if max == 0 {
@@ -44,6 +46,13 @@ var templateFuncs = template.FuncMap{
 	"sub": func(a, b int) int {
 		return a - b
 	},
+	// urlHost はURLのホスト名（ファビコンの取得に使う）
+	"urlHost": extractDomain,
+	// サーバー側で描くSVGグラフ（svg_chart.go）
+	"hourlyChart":  hourlyChart,
+	"dailyChart":   dailyChart,
+	"domainChart":  domainChart,
+	"heatmapChart": heatmapChart,
 	"seq": func(start, end int) []int {
 		var result []int
 		for i := start; i <= end; i++ {
@@ -55,68 +64,274 @@ var templateFuncs = template.FuncMap{
 
 // WebServer はWebサーバーの構造体
 type WebServer struct {
-	db            *sql.DB
-	templates     *template.Template
-	port          int
+	db        *preparedDB
+	templates *template.Template
+	port      int
+	// mu は設定ファイルの変更で再読み込みされる ignoreDomains・ignoreRules・redactDomains・holidays を守る
+	mu            sync.RWMutex
 	ignoreDomains []string
+	ignoreRules   []IgnoreRule
+	redactDomains []string
+	holidays      *Holidays
+	// dashboard はダッシュボードのパネルと件数（nil なら defaultDashboardConfig。mu で守る）
+	dashboard *DashboardConfig
+	// keyBindings は履歴ページのキーボード操作のキー（nil なら defaultKeyBindings。mu で守る）
+	keyBindings []KeyBinding
+	// searchIndex はキーワード検索に使う全文検索の索引（なければ nil）
+	searchIndex *SearchIndex
+	// favicons はドメインのファビコンの取得（-favicons を指定しなければ nil）
+	favicons *FaviconCache
+	// laterPath は「あとで読む」キューのファイル
+	laterPath string
+	// snapshotDir はスナップショットを保存するディレクトリ
+	snapshotDir string
+	// sidecarPath はタグ・メモを置くサイドカーDB
+	sidecarPath string
+	// panelTimeout はダッシュボードの各パネルの集計を打ち切るまでの時間（0 なら WebPanelTimeout）
+	panelTimeout time.Duration
+	// rateLimiter は API のクライアントIPごとのリクエスト数の制限（nil なら制限しない）
+	rateLimiter *rateLimiter
+	// summaryMu は summaryCache を守る（集計中の同じリクエストは待たせて集計を1回にする）
+	summaryMu sync.Mutex
+	// summaryCache は絞り込みなしの /api/summary の直近の集計（nil なら未集計）
+	summaryCache *Summary
 }
 
 // NewWebServer は新しいWebServerを作成
-func NewWebServer(db *sql.DB, port int) (*WebServer, error) {
+// redactDomains に一致するドメインは画面・APIで [redacted] として表示する
+func NewWebServer(db *sql.DB, port int, redactDomains []string) (*WebServer, error) {
 	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
 	if err != nil {
 		return nil, fmt.Errorf("テンプレートの解析に失敗: %w", err)
 	}
 
 	// イグノアリストを読み込み
-	ignoreDomains, err := LoadIgnoreList()
+	entries, err := LoadIgnoreList()
 	if err != nil {
 		return nil, fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
 	}
+	ignoreDomains, ignoreRules, err := splitIgnoreEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := LoadHolidays()
+	if err != nil {
+		return nil, fmt.Errorf("休日一覧の読み込みに失敗: %w", err)
+	}
+	dashboard, err := LoadDashboardConfig()
+	if err != nil {
+		return nil, err
+	}
+	keyBindings, err := LoadKeyBindings()
+	if err != nil {
+		return nil, err
+	}
+	laterPath, err := getLaterPath()
+	if err != nil {
+		return nil, err
+	}
+	snapshotDir, err := getSnapshotDir()
+	if err != nil {
+		return nil, err
+	}
+	sidecarPath, err := getSidecarPath()
+	if err != nil {
+		return nil, err
+	}
 
 	return &WebServer{
-		db:            db,
+		db:            newPreparedDB(db),
 		templates:     tmpl,
 		port:          port,
 		ignoreDomains: ignoreDomains,
+		ignoreRules:   ignoreRules,
+		redactDomains: redactDomains,
+		holidays:      holidays,
+		dashboard:     &dashboard,
+		keyBindings:   keyBindings,
+		laterPath:     laterPath,
+		snapshotDir:   snapshotDir,
+		sidecarPath:   sidecarPath,
 	}, nil
 }
 
 // Start はWebサーバーを起動
 func (s *WebServer) Start() error {
+	defer func() { _ = s.db.Close() }()
+
 	mux := http.NewServeMux()
 
 	// ページハンドラー
 	mux.HandleFunc("/", s.handleDashboard)
 	mux.HandleFunc("/domain", s.handleDomainDetail)
+	mux.HandleFunc("/url", s.handleURLTimeline)
 	mux.HandleFunc("/history", s.handleHistory)
+	mux.HandleFunc("/search", s.handleSearchPage)
 	mux.HandleFunc("/stats", s.handleStatsPage)
+	mux.HandleFunc("/later", s.handleLaterPage)
+	mux.HandleFunc("GET /snapshots", s.handleSnapshotsPage)
+	mux.HandleFunc("GET /snapshots/{name}", s.handleSnapshot)
 
 	// APIハンドラー
 	mux.HandleFunc("/api/stats", s.handleAPIStats)
 	mux.HandleFunc("/api/stats/hourly", s.handleAPIStatsHourly)
 	mux.HandleFunc("/api/stats/daily", s.handleAPIStatsDaily)
+	mux.HandleFunc("/api/stats/profiles", s.handleAPIStatsProfiles)
+	mux.HandleFunc("GET /api/dashboard/{panel}", s.handleAPIDashboardPanel)
 	mux.HandleFunc("/api/history", s.handleAPIHistory)
+	mux.HandleFunc("GET /api/suggest", s.handleAPISuggest)
+	mux.HandleFunc("GET /api/search", s.handleAPISearch)
+	mux.HandleFunc("GET /api/summary", s.handleAPISummary)
+	mux.HandleFunc("GET /api/keybindings", s.handleAPIKeyBindings)
 	mux.HandleFunc("/api/domains", s.handleAPIDomains)
+	mux.HandleFunc("GET /api/domains/{domain}/paths", s.handleAPIDomainPaths)
+	mux.HandleFunc("GET /api/favicon/{domain}", s.handleAPIFavicon)
+	mux.HandleFunc("/api/later", s.handleAPILater)
+	mux.HandleFunc("/api/snapshots", s.handleAPISnapshots)
+	mux.HandleFunc("GET /api/annotations", s.handleAPIAnnotations)
+	mux.HandleFunc("/api/stars", s.handleAPIStars)
+	mux.HandleFunc("GET /api/schema", s.handleAPISchema)
+	mux.HandleFunc("GET /api/schema/{name}", s.handleAPISchema)
 
 	// 静的ファイル
-	mux.Handle("/static/", http.FileServer(http.FS(staticFS)))
+	static, err := staticHandler()
+	if err != nil {
+		return err
+	}
+	mux.Handle("/static/", static)
 
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Web server starting at http://localhost%s", addr)
-	return http.ListenAndServe(addr, mux)
+	return http.ListenAndServe(addr, s.rateLimiter.limitAPI(mux))
+}
+
+// staticHandler は埋め込んだ web/static のファイルを /static/ で配信するハンドラーを返す
+func staticHandler() (http.Handler, error) {
+	static, err := fs.Sub(staticFS, "web")
+	if err != nil {
+		return nil, fmt.Errorf("静的ファイルの読み込みに失敗: %w", err)
+	}
+	return http.FileServerFS(static), nil
+}
+
+// baseFilter はイグノアリストを適用した基本のフィルタを返す
+func (s *WebServer) baseFilter() SearchFilter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SearchFilter{IgnoreDomains: s.ignoreDomains, IgnoreRules: s.ignoreRules, Index: s.searchIndex}
+}
+
+// holidayList は現在の休日の設定を返す
+func (s *WebServer) holidayList() *Holidays {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.holidays
+}
+
+// dashboardConfig は現在のダッシュボードの設定を返す
+func (s *WebServer) dashboardConfig() DashboardConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.dashboard == nil {
+		return defaultDashboardConfig
+	}
+	return *s.dashboard
+}
+
+// keyBindingList は現在の履歴ページのキーボード操作のキーを返す
+func (s *WebServer) keyBindingList() []KeyBinding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.keyBindings == nil {
+		return defaultKeyBindings
+	}
+	return s.keyBindings
+}
+
+// redactList は現在のリダクトリストを返す
+func (s *WebServer) redactList() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.redactDomains
+}
+
+// reloadConfig はイグノアリストと休日一覧を読み込み直す（reloadRedact ならリダクトリストも）
+// 読み込みに失敗した場合は以前の設定のまま動き続ける
+func (s *WebServer) reloadConfig(reloadRedact bool) error {
+	entries, err := LoadIgnoreList()
+	if err != nil {
+		return fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+	}
+	ignoreDomains, ignoreRules, err := splitIgnoreEntries(entries)
+	if err != nil {
+		return err
+	}
+	holidays, err := LoadHolidays()
+	if err != nil {
+		return fmt.Errorf("休日一覧の読み込みに失敗: %w", err)
+	}
+	dashboard, err := LoadDashboardConfig()
+	if err != nil {
+		return err
+	}
+	keyBindings, err := LoadKeyBindings()
+	if err != nil {
+		return err
+	}
+	var redactDomains []string
+	if reloadRedact {
+		if redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignoreDomains = ignoreDomains
+	s.ignoreRules = ignoreRules
+	s.holidays = holidays
+	s.dashboard = &dashboard
+	s.keyBindings = keyBindings
+	if reloadRedact {
+		s.redactDomains = redactDomains
+	}
+	return nil
+}
+
+// WatchConfig は設定ディレクトリを監視し、hist -ignore-add などでリストが変わったら再起動せずに反映する
+// -no-redact で起動した場合はリダクトリストを監視しないよう reloadRedact に false を渡す
+// 返り値の関数で監視を止める
+func (s *WebServer) WatchConfig(reloadRedact bool) (func() error, error) {
+	fileNames := []string{ignoreFileName, holidaysFileName, dashboardFileName, keysFileName}
+	if reloadRedact {
+		fileNames = append(fileNames, redactFileName)
+	}
+	return watchConfigDir(fileNames, func() {
+		if err := s.reloadConfig(reloadRedact); err != nil {
+			log.Printf("設定の再読み込みに失敗（以前の設定のまま続行）: %v", err)
+			return
+		}
+		log.Printf("設定を再読み込みしました")
+	})
 }
 
 // DashboardData はダッシュボード用のデータ
+// 統計はページを返した後に GET /api/dashboard/{panel} で読み込むため、表示するパネルとフォームの値だけを持つ
 type DashboardData struct {
-	TotalVisits     int
-	DomainPathStats []DomainPathStats
-	RecentVisits    []HistoryVisit
-	MaxDomainHits   int
+	// Theme は表示に使うテーマ（requestTheme）
+	Theme  PageTheme
+	Panels DashboardConfig
+	// Favicons はドメインにファビコンを添えるか（-favicons）
+	Favicons bool
+	// イグノアリストの一時的な上書き（?no_ignore=1・?ignore=）
+	NoIgnore bool
+	Ignore   string
 }
 
 // DomainDetailData はドメイン詳細ページ用のデータ
 type DomainDetailData struct {
+	Theme       PageTheme
+	Favicons    bool
 	Domain      string
 	TotalVisits int
 	Contents    []ContentStats
@@ -124,41 +339,26 @@ type DomainDetailData struct {
 }
 
 // handleDashboard はダッシュボードページを表示
+// 集計を待たずに枠だけを返し、各パネルはブラウザが /api/dashboard/{panel} から読み込む
 func (s *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
 	}
 
-	total, err := getTotalVisits(s.db)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	filter := SearchFilter{IgnoreDomains: s.ignoreDomains}
-	domainPathStats, err := getDomainPathStats(s.db, DefaultDomainLimit, DefaultPathLimit, filter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	recentVisits, err := getRecentVisits(s.db, WebDashboardRecentVisits, filter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// 絞り込みの誤りはパネルごとではなくページで知らせる
+	if _, err := s.requestFilter(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	maxHits := 0
-	if len(domainPathStats) > 0 {
-		maxHits = domainPathStats[0].TotalCount
-	}
+	noIgnore, extra := ignoreOverride(r)
 
 	data := DashboardData{
-		TotalVisits:     total,
-		DomainPathStats: domainPathStats,
-		RecentVisits:    recentVisits,
-		MaxDomainHits:   maxHits,
+		Theme:    requestTheme(w, r),
+		Panels:   s.dashboardConfig(),
+		Favicons: s.favicons != nil,
+		NoIgnore: noIgnore,
+		Ignore:   strings.Join(extra, ","),
 	}
 
 	if err := s.templates.ExecuteTemplate(w, "dashboard.html", data); err != nil {
@@ -186,9 +386,11 @@ func (s *WebServer) handleDomainDetail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := DomainDetailData{
+		Theme:       requestTheme(w, r),
+		Favicons:    s.favicons != nil && !domainMatchesList(domain, s.redactList()),
 		Domain:      domain,
 		TotalVisits: total,
-		Contents:    contents,
+		Contents:    redactContentStats(contents, s.redactList()),
 		MaxHits:     maxHits,
 	}
 
@@ -199,6 +401,8 @@ func (s *WebServer) handleDomainDetail(w http.ResponseWriter, r *http.Request) {
 
 // HistoryPageData は履歴ページ用のデータ
 type HistoryPageData struct {
+	Theme       PageTheme
+	Favicons    bool
 	Visits      []HistoryVisit
 	CurrentPage int
 	TotalPages  int
@@ -211,7 +415,22 @@ type HistoryPageData struct {
 	Domain  string
 	From    string
 	To      string
+	Tag     string
+	Starred bool
 	Domains []string
+	// イグノアリストの一時的な上書き（ページ送りでも引き継ぐ）
+	NoIgnore bool
+	Ignore   string
+	// PerPage は ?per_page= で変えた1ページの件数（既定の WebPageSize なら 0。ページ送りでも引き継ぐ）
+	PerPage int
+}
+
+// perPageParam はページ送りのリンクに付ける per_page の値（既定の件数なら付けない）
+func perPageParam(perPage int) int {
+	if perPage == WebPageSize {
+		return 0
+	}
+	return perPage
 }
 
 // handleHistory は履歴一覧ページを表示
@@ -222,29 +441,24 @@ func (s *WebServer) handleHistory(w http.ResponseWriter, r *http.Request) {
 			page = parsed
 		}
 	}
+	perPage := queryInt(r, "per_page", WebPageSize, WebMaxPageSize)
 
 	// フィルタ条件を取得
-	filter := SearchFilter{IgnoreDomains: s.ignoreDomains}
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	noIgnore, extra := ignoreOverride(r)
 	searchQuery := r.URL.Query().Get("search")
 	domainQuery := r.URL.Query().Get("domain")
 	fromQuery := r.URL.Query().Get("from")
 	toQuery := r.URL.Query().Get("to")
-
-	filter.Keyword = searchQuery
-	filter.Domain = domainQuery
-
-	if fromQuery != "" {
-		if t, err := time.Parse(TimeFormatDate, fromQuery); err == nil {
-			filter.From = t
-		}
-	}
-	if toQuery != "" {
-		if t, err := time.Parse(TimeFormatDate, toQuery); err == nil {
-			filter.To = t
-		}
+	tagQuery := ""
+	if filter.Tag != nil {
+		tagQuery = filter.Tag.Tag
 	}
 
-	perPage := WebPageSize
 	offset := (page - 1) * perPage
 
 	// フィルタ付きの総件数を取得
@@ -274,7 +488,9 @@ func (s *WebServer) handleHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := HistoryPageData{
-		Visits:      visits,
+		Theme:       requestTheme(w, r),
+		Favicons:    s.favicons != nil,
+		Visits:      redactVisits(sanitizeVisits(visits), s.redactList()),
 		CurrentPage: page,
 		TotalPages:  totalPages,
 		HasPrev:     page > 1,
@@ -285,7 +501,12 @@ func (s *WebServer) handleHistory(w http.ResponseWriter, r *http.Request) {
 		Domain:      domainQuery,
 		From:        fromQuery,
 		To:          toQuery,
-		Domains:     domains,
+		Tag:         tagQuery,
+		Starred:     filter.Starred != nil,
+		Domains:     removeRedactedDomains(domains, s.redactList()),
+		NoIgnore:    noIgnore,
+		PerPage:     perPageParam(perPage),
+		Ignore:      strings.Join(extra, ","),
 	}
 
 	if err := s.templates.ExecuteTemplate(w, "history.html", data); err != nil {
@@ -294,22 +515,39 @@ func (s *WebServer) handleHistory(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleAPIStats は統計データをJSONで返す
+// 期間・キーワードなどで絞り込んだ場合は、総訪問数・ドメイン別訪問数も絞り込んだ訪問から数える
 func (s *WebServer) handleAPIStats(w http.ResponseWriter, r *http.Request) {
-	total, err := getTotalVisits(s.db)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	filter := SearchFilter{IgnoreDomains: s.ignoreDomains}
-	domainStats, err := getDomainStats(s.db, DefaultDomainLimit, filter)
+	filter, err := s.requestFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	hourlyStats, err := getHourlyStats(s.db, filter)
-	if err != nil {
+	var total int
+	var domainStats []DomainStats
+	var hourlyStats []HourlyStats
+	g := newTaskGroup(StatsParallelism)
+	g.Go(func() (err error) {
+		if filter.narrowsVisits() {
+			total, err = getFilteredVisitCount(s.db, filter)
+		} else {
+			total, err = getTotalVisits(s.db)
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		if filter.narrowsVisits() {
+			domainStats, err = getVisitDomainStats(s.db, DefaultDomainLimit, filter)
+		} else {
+			domainStats, err = getDomainStats(s.db, DefaultDomainLimit, filter)
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		hourlyStats, err = getHourlyStats(s.db, filter)
+		return err
+	})
+	if err := g.Wait(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -318,7 +556,9 @@ func (s *WebServer) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 		TotalVisits: total,
 		DomainStats: domainStats,
 		HourlyStats: hourlyStats,
+		Query:       newQueryInfo(filter, time.Now()),
 	}
+	result = redactResult(result, s.redactList())
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(result); err != nil {
@@ -327,28 +567,58 @@ func (s *WebServer) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleAPIHistory は履歴データをJSONで返す
+// ?cursor= を付けるとキーセット・ページングになり、next_cursor 付きの HistoryPage を返す
+// （cursor が空なら最新から、次のページは next_cursor の値を cursor に渡す）
 func (s *WebServer) handleAPIHistory(w http.ResponseWriter, r *http.Request) {
-	limit := WebPageSize
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
-			limit = parsed
+	limit := queryInt(r, "limit", WebPageSize, WebMaxLimit)
+
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Has("cursor") {
+		var cursor *HistoryCursor
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			parsed, err := parseHistoryCursor(c)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			cursor = &parsed
+		}
+		page, err := getVisitsPage(s.db, limit, cursor, filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Visits = redactVisits(sanitizeVisits(page.Visits), s.redactList())
+		if page.Visits == nil {
+			page.Visits = []HistoryVisit{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
+		return
 	}
 
-	visits, err := getRecentVisits(s.db, limit, SearchFilter{})
+	visits, err := getRecentVisits(s.db, limit, filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(visits); err != nil {
+	if err := json.NewEncoder(w).Encode(redactVisits(sanitizeVisits(visits), s.redactList())); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
 // getRecentVisitsWithOffset はオフセット付きで履歴を取得
-func getRecentVisitsWithOffset(db *sql.DB, limit, offset int, filter SearchFilter) ([]HistoryVisit, error) {
+func getRecentVisitsWithOffset(db dbQuerier, limit, offset int, filter SearchFilter) ([]HistoryVisit, error) {
 	qb := NewQueryBuilder(historyBaseQuery).
 		WithFilter(filter).
 		OrderByDesc("hv.visit_time").
@@ -367,7 +637,7 @@ const countBaseQuery = `
 	WHERE 1=1`
 
 // getFilteredVisitCount はフィルタ条件に一致する訪問数を取得
-func getFilteredVisitCount(db *sql.DB, filter SearchFilter) (int, error) {
+func getFilteredVisitCount(db dbQuerier, filter SearchFilter) (int, error) {
 	qb := NewQueryBuilder(countBaseQuery).WithFilter(filter)
 	query, args := qb.Build()
 
@@ -380,7 +650,7 @@ func getFilteredVisitCount(db *sql.DB, filter SearchFilter) (int, error) {
 }
 
 // getAllDomains は全てのドメインを取得
-func getAllDomains(db *sql.DB) ([]string, error) {
+func getAllDomains(db dbQuerier) ([]string, error) {
 	query := `
 		SELECT DISTINCT COALESCE(domain_expansion, '') as domain
 		FROM history_items
@@ -406,9 +676,11 @@ func getAllDomains(db *sql.DB) ([]string, error) {
 
 // StatsPageData は統計ページ用のデータ
 type StatsPageData struct {
+	Theme       PageTheme
 	HourlyStats []HourlyStats
 	DailyStats  []DailyStats
 	DomainStats []DomainStats
+	Heatmap     DashboardHeatmap
 	Domains     []string
 	Domain      string
 	Days        int
@@ -417,44 +689,54 @@ type StatsPageData struct {
 // handleStatsPage は統計ページを表示
 func (s *WebServer) handleStatsPage(w http.ResponseWriter, r *http.Request) {
 	domainQuery := r.URL.Query().Get("domain")
-	days := WebDefaultDays
-	if d := r.URL.Query().Get("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 {
-			days = parsed
-		}
-	}
-
-	filter := SearchFilter{Domain: domainQuery, IgnoreDomains: s.ignoreDomains}
-
-	hourlyStats, err := getHourlyStats(s.db, filter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	days := queryInt(r, "days", WebDefaultDays, WebMaxDays)
 
-	dailyStats, err := getDailyStats(s.db, days, filter)
+	filter, err := s.requestFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	domainStats, err := getDomainStats(s.db, DefaultDomainLimit, filter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	domains, err := getAllDomains(s.db)
-	if err != nil {
+	// 時間帯別・日別・ドメイン別・曜日×時間帯の統計とドメイン一覧は独立しているため並行に集計する
+	var hourlyStats []HourlyStats
+	var dailyStats []DailyStats
+	var domainStats []DomainStats
+	var heatmap DashboardHeatmap
+	var domains []string
+	g := newTaskGroup(StatsParallelism)
+	g.Go(func() (err error) {
+		hourlyStats, err = getHourlyStats(s.db, filter)
+		return err
+	})
+	g.Go(func() (err error) {
+		dailyStats, err = getDailyStats(s.db, days, time.Now(), filter)
+		return err
+	})
+	g.Go(func() (err error) {
+		domainStats, err = getDomainStats(s.db, DefaultDomainLimit, filter)
+		return err
+	})
+	g.Go(func() (err error) {
+		heatmap, err = getWeekdayHourCounts(s.db, filter)
+		return err
+	})
+	g.Go(func() (err error) {
+		domains, err = getAllDomains(s.db)
+		return err
+	})
+	if err := g.Wait(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.holidayList().annotateDaily(dailyStats)
 
 	data := StatsPageData{
+		Theme:       requestTheme(w, r),
 		HourlyStats: hourlyStats,
 		DailyStats:  dailyStats,
-		DomainStats: domainStats,
-		Domains:     domains,
+		DomainStats: redactDomainStats(domainStats, s.redactList()),
+		Heatmap:     heatmap,
+		Domains:     removeRedactedDomains(domains, s.redactList()),
 		Domain:      domainQuery,
 		Days:        days,
 	}
@@ -466,37 +748,59 @@ func (s *WebServer) handleStatsPage(w http.ResponseWriter, r *http.Request) {
 
 // handleAPIStatsHourly は時間帯別統計をJSONで返す
 func (s *WebServer) handleAPIStatsHourly(w http.ResponseWriter, r *http.Request) {
-	domainQuery := r.URL.Query().Get("domain")
-	filter := SearchFilter{Domain: domainQuery, IgnoreDomains: s.ignoreDomains}
-
-	hourlyStats, err := getHourlyStats(s.db, filter)
+	filter, err := s.requestFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// ?split=weekpart で平日・週末の1日平均に分けた2系列を返す
+	var data interface{}
+	switch split := r.URL.Query().Get("split"); split {
+	case "":
+		hourlyStats, err := getHourlyStats(s.db, filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data = hourlyStats
+	case HourlySplitWeekpart:
+		splitStats, err := getHourlySplitStats(s.db, filter, s.holidayList())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data = splitStats
+	default:
+		http.Error(w, "不明な split です: "+split, http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(hourlyStats); err != nil {
+	if err := json.NewEncoder(w).Encode(data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
 // handleAPIStatsDaily は日別統計をJSONで返す
+// from を指定して days を省略した場合は、from 以降の全ての日を返す
 func (s *WebServer) handleAPIStatsDaily(w http.ResponseWriter, r *http.Request) {
-	domainQuery := r.URL.Query().Get("domain")
-	days := WebDefaultDays
-	if d := r.URL.Query().Get("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 {
-			days = parsed
-		}
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	filter := SearchFilter{Domain: domainQuery, IgnoreDomains: s.ignoreDomains}
-	dailyStats, err := getDailyStats(s.db, days, filter)
+	days := queryInt(r, "days", WebDefaultDays, WebMaxDays)
+	if !r.URL.Query().Has("days") && !filter.From.IsZero() {
+		days = min(int(time.Since(filter.From).Hours()/24)+1, WebMaxDays)
+	}
+	dailyStats, err := getDailyStats(s.db, days, time.Now(), filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.holidayList().annotateDaily(dailyStats)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(dailyStats); err != nil {
@@ -504,16 +808,239 @@ func (s *WebServer) handleAPIStatsDaily(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// HourlyProfilesResponse は時間帯プロファイルAPIのレスポンス
+type HourlyProfilesResponse struct {
+	Profiles []HourlyProfile `json:"profiles"`
+	Summary  string          `json:"summary"`
+	// Categories は categories.txt のカテゴリごとのプロファイル（設定していなければ省略）
+	Categories      []HourlyProfile `json:"categories,omitempty"`
+	CategorySummary string          `json:"category_summary,omitempty"`
+}
+
+// handleAPIStatsProfiles は上位ドメインごとの時間帯別の分布をJSONで返す（categories.txt があればカテゴリごとの分布も）
+func (s *WebServer) handleAPIStatsProfiles(w http.ResponseWriter, r *http.Request) {
+	limit := queryInt(r, "limit", WebProfileLimit, WebMaxProfileLimit)
+
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	profiles, err := getHourlyProfiles(s.db, limit, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	profiles = redactHourlyProfiles(profiles, s.redactList())
+	if profiles == nil {
+		profiles = []HourlyProfile{}
+	}
+	response := HourlyProfilesResponse{Profiles: profiles, Summary: summarizePeaks(profiles)}
+
+	categories, err := LoadDomainCategories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(categories) > 0 {
+		if response.Categories, err = getCategoryHourlyProfiles(s.db, filter, categories); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.CategorySummary = summarizePeaks(response.Categories)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // handleAPIDomains はドメイン一覧をJSONで返す
+// 絞り込みを指定した場合は、一致する訪問があるドメインだけを返す
 func (s *WebServer) handleAPIDomains(w http.ResponseWriter, r *http.Request) {
-	domains, err := getAllDomains(s.db)
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var domains []string
+	if filter.narrowsVisits() {
+		domains, err = getFilteredDomains(s.db, filter)
+	} else {
+		domains, err = getAllDomains(s.db)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(domains); err != nil {
+	if err := json.NewEncoder(w).Encode(removeRedactedDomains(domains, s.redactList())); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// DomainDrilldown はダッシュボードでドメインを展開したときに返すデータ
+type DomainDrilldown struct {
+	Domain       string         `json:"domain"`
+	TotalCount   int            `json:"total_count"`
+	Paths        []PathStats    `json:"paths"`
+	OtherCount   int            `json:"other_count"`
+	RecentVisits []HistoryVisit `json:"recent_visits"`
+}
+
+// handleAPIDomainPaths は指定ドメインの上位パスと最近の訪問をJSONで返す
+func (s *WebServer) handleAPIDomainPaths(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	if domain == "" || domainMatchesList(domain, s.redactList()) {
+		http.NotFound(w, r)
+		return
+	}
+
+	pathLimit := WebDrilldownPathLimit
+	if p := r.URL.Query().Get("paths"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			pathLimit = parsed
+		}
+	}
+	visitLimit := WebDashboardRecentVisits
+	if v := r.URL.Query().Get("visits"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			visitLimit = parsed
+		}
+	}
+
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 絞り込みを指定した場合は、パス別の訪問数も一致する訪問から数える
+	var paths []PathStats
+	var total, other int
+	if filter.narrowsVisits() {
+		paths, total, other, err = getVisitPathStatsByDomain(s.db, domain, pathLimit, filter)
+	} else {
+		paths, total, other, err = getPathStatsByDomain(s.db, domain, pathLimit, filter)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	visits, err := getRecentVisitsByBaseDomain(s.db, domain, visitLimit, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := DomainDrilldown{
+		Domain:       domain,
+		TotalCount:   total,
+		Paths:        paths,
+		OtherCount:   other,
+		RecentVisits: redactVisits(sanitizeVisits(visits), s.redactList()),
+	}
+	if data.Paths == nil {
+		data.Paths = []PathStats{}
+	}
+	if data.RecentVisits == nil {
+		data.RecentVisits = []HistoryVisit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// getPathStatsByDomain はベースドメイン配下のパス別訪問数を上位pathLimit件返す
+// 返り値はパス統計・ドメインの総訪問数・上位以外の訪問数
+// イグノアリストに一致するサブドメインのURLは数えない
+func getPathStatsByDomain(db dbQuerier, domain string, pathLimit int, filter SearchFilter) ([]PathStats, int, int, error) {
+	contents, _, err := getContentStatsByDomain(db, domain, 0)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// URL単位（クエリ違い）の統計をパス単位にまとめる
+	// contentsは訪問数の降順なので、最初に出てきたタイトルが最も訪問の多いURLのもの
+	index := make(map[string]int)
+	var paths []PathStats
+	total := 0
+	for _, c := range contents {
+		urlDomain := extractDomain(c.URL)
+		if shouldIgnoreDomain(urlDomain, filter.IgnoreDomains) || (filter.ExcludeTrackers && isTrackerDomain(urlDomain)) {
+			continue
+		}
+		total += c.VisitCount
+		if i, ok := index[c.Path]; ok {
+			paths[i].VisitCount += c.VisitCount
+			continue
+		}
+		index[c.Path] = len(paths)
+		paths = append(paths, PathStats{Path: c.Path, Title: c.Title, VisitCount: c.VisitCount})
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].VisitCount > paths[j].VisitCount
+	})
+
+	other := 0
+	if pathLimit > 0 && len(paths) > pathLimit {
+		for _, p := range paths[pathLimit:] {
+			other += p.VisitCount
+		}
+		paths = paths[:pathLimit]
+	}
+	return paths, total, other, nil
+}
+
+// getRecentVisitsByBaseDomain はベースドメイン（サブドメインを含む）の最近の訪問を取得
+// limit が0以下の場合は全件を返す
+func getRecentVisitsByBaseDomain(db dbQuerier, domain string, limit int, filter SearchFilter) ([]HistoryVisit, error) {
+	// SQLではホスト名の末尾で大まかに絞り、Go側でベースドメインが一致するものだけ残す
+	query, args := NewQueryBuilder(historyBaseQuery).
+		WithFilter(filter).
+		WithRawCondition(`hi.url LIKE ? OR hi.url LIKE ? OR hi.url LIKE ? OR hi.url LIKE ?`,
+			"%://"+domain, "%://"+domain+"/%", "%://%."+domain, "%://%."+domain+"/%").
+		OrderByDesc("hv.visit_time").
+		Build()
+
+	var visits []HistoryVisit
+	err := streamHistoryQuery(db, query, args, func(v HistoryVisit) error {
+		if extractBaseDomain(extractDomain(v.URL)) != domain || shouldIgnoreDomain(v.Domain, filter.IgnoreDomains) {
+			return nil
+		}
+		visits = append(visits, v)
+		if limit > 0 && len(visits) >= limit {
+			return errStopStream
+		}
+		return nil
+	})
+	return visits, err
+}
+
+// handleAPISchema は JSON 出力と API レスポンスの JSON Schema を返す
+// /api/schema は全ての型を $defs にまとめた文書、/api/schema/{name} は1つの型の文書を返す
+func (s *WebServer) handleAPISchema(w http.ResponseWriter, r *http.Request) {
+	schema := buildSchemaBundle()
+	if name := r.PathValue("name"); name != "" {
+		doc, ok := findSchemaDocument(strings.TrimSuffix(name, schemaFileSuffix))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		schema = buildSchema(doc)
+	}
+
+	data, err := marshalSchema(schema)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHandleAPIDomainPaths はドメイン展開APIのテスト
+func TestHandleAPIDomainPaths(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'https://github.com/test?tab=readme', 'github', 3),
+		(6, 'https://gist.github.com/snippet', 'gist.github', 2);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 6, 757600000, 'Gist Snippet');
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	s := &WebServer{db: newPreparedDB(db), redactDomains: []string{"youtube.com"}}
+	defer func() { _ = s.db.Close() }()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/domains/{domain}/paths", s.handleAPIDomainPaths)
+
+	t.Run("パスと最近の訪問", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/domains/github.com/paths?paths=1", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ステータスが期待値と異なる: got %d", rec.Code)
+		}
+
+		var data DomainDrilldown
+		if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+			t.Fatalf("JSONのデコードに失敗: %v", err)
+		}
+		if data.TotalCount != 15 {
+			t.Errorf("総訪問数が期待値と異なる: got %d, want 15", data.TotalCount)
+		}
+		// クエリ違いの /test は1つのパスにまとめられる
+		if len(data.Paths) != 1 || data.Paths[0].Path != "/test" || data.Paths[0].VisitCount != 13 {
+			t.Errorf("パス統計が期待値と異なる: %+v", data.Paths)
+		}
+		if data.OtherCount != 2 {
+			t.Errorf("その他の訪問数が期待値と異なる: got %d, want 2", data.OtherCount)
+		}
+		// サブドメインを含み、新しい順
+		if len(data.RecentVisits) != 3 || data.RecentVisits[0].Title != "Gist Snippet" {
+			t.Errorf("最近の訪問が期待値と異なる: %+v", data.RecentVisits)
+		}
+	})
+
+	t.Run("イグノアリストのサブドメインは数えない", func(t *testing.T) {
+		ignored := &WebServer{db: s.db, ignoreDomains: []string{"gist.github.com"}}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/domains/{domain}/paths", ignored.handleAPIDomainPaths)
+
+		get := func(url string) DomainDrilldown {
+			t.Helper()
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: ステータスが期待値と異なる: got %d", url, rec.Code)
+			}
+			var data DomainDrilldown
+			if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+				t.Fatalf("%s: JSONのデコードに失敗: %v", url, err)
+			}
+			return data
+		}
+
+		data := get("/api/domains/github.com/paths")
+		if data.TotalCount != 13 || len(data.Paths) != 1 || data.Paths[0].Path != "/test" || data.OtherCount != 0 {
+			t.Errorf("イグノアリストが反映されていない: total %d, paths %+v, other %d", data.TotalCount, data.Paths, data.OtherCount)
+		}
+		for _, v := range data.RecentVisits {
+			if v.Domain == "gist.github.com" {
+				t.Errorf("イグノアリストのドメインの訪問が含まれている: %+v", v)
+			}
+		}
+
+		// no_ignore=1 ならイグノアリストを使わない
+		if data := get("/api/domains/github.com/paths?no_ignore=1"); data.TotalCount != 15 {
+			t.Errorf("no_ignore=1 の総訪問数が期待値と異なる: got %d, want 15", data.TotalCount)
+		}
+		// ignore= で追加したドメインも除く
+		if data := get("/api/domains/github.com/paths?no_ignore=1&ignore=gist.github.com"); data.TotalCount != 13 {
+			t.Errorf("ignore= の総訪問数が期待値と異なる: got %d, want 13", data.TotalCount)
+		}
+	})
+
+	t.Run("リダクト対象は404", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/domains/youtube.com/paths", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("ステータスが期待値と異なる: got %d, want 404", rec.Code)
+		}
+	})
+}
+
+// TestHandleAPIStatsProfiles は時間帯プロファイルAPIのテスト
+func TestHandleAPIStatsProfiles(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	s := &WebServer{db: newPreparedDB(db), redactDomains: []string{"youtube.com"}}
+	defer func() { _ = s.db.Close() }()
+
+	rec := httptest.NewRecorder()
+	s.handleAPIStatsProfiles(rec, httptest.NewRequest("GET", "/api/stats/profiles?limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータスが期待値と異なる: got %d", rec.Code)
+	}
+
+	var data HourlyProfilesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("JSONのデコードに失敗: %v", err)
+	}
+	if len(data.Profiles) != 2 || data.Profiles[0].Domain != "github.com" || data.Profiles[1].Domain != redactedLabel {
+		t.Errorf("プロファイルが期待値と異なる: %+v", data.Profiles)
+	}
+	if !strings.Contains(data.Summary, "github.com は 10:00") || strings.Contains(data.Summary, "youtube") {
+		t.Errorf("要約が期待値と異なる: %q", data.Summary)
+	}
+	if data.Categories != nil {
+		t.Errorf("categories.txt がないのにカテゴリ別のプロファイルがある: %+v", data.Categories)
+	}
+
+	// categories.txt があればカテゴリごとのプロファイルも返す
+	path, err := getConfigFilePath(categoriesFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("github.com 開発\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	s.handleAPIStatsProfiles(rec, httptest.NewRequest("GET", "/api/stats/profiles", nil))
+	data = HourlyProfilesResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("JSONのデコードに失敗: %v", err)
+	}
+	if len(data.Categories) != 2 || data.Categories[0].Category != uncategorizedLabel || data.Categories[1].Category != "開発" ||
+		data.CategorySummary != "未分類 は 11:00、開発 は 10:00 にピーク" {
+		t.Errorf("カテゴリ別のプロファイルが期待値と異なる: %+v, %q", data.Categories, data.CategorySummary)
+	}
+}
+
+func TestHandleAPISchema(t *testing.T) {
+	s := &WebServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/schema", s.handleAPISchema)
+	mux.HandleFunc("GET /api/schema/{name}", s.handleAPISchema)
+
+	tests := []struct {
+		path  string
+		code  int
+		title string
+	}{
+		{"/api/schema", http.StatusOK, "hist"},
+		{"/api/schema/HistoryVisit", http.StatusOK, "HistoryVisit"},
+		{"/api/schema/AnalysisResult.schema.json", http.StatusOK, "AnalysisResult"},
+		{"/api/schema/Unknown", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("ステータスが期待値と異なる: got %d, want %d", rec.Code, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/schema+json" {
+				t.Errorf("Content-Type が期待値と異なる: %s", ct)
+			}
+			var schema map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &schema); err != nil {
+				t.Fatalf("JSONのデコードに失敗: %v", err)
+			}
+			if schema["title"] != tt.title || schema["$schema"] != JSONSchemaDraft {
+				t.Errorf("スキーマが期待値と異なる: title=%v $schema=%v", schema["title"], schema["$schema"])
+			}
+		})
+	}
+}
+
+func TestHandleAPIHistoryCursor(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	s := &WebServer{db: newPreparedDB(db), redactDomains: []string{"youtube.com"}}
+	defer func() { _ = s.db.Close() }()
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleAPIHistory(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	rec := get("/api/history?limit=3&cursor=")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータスが期待値と異なる: got %d", rec.Code)
+	}
+	var page HistoryPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("JSONのデコードに失敗: %v", err)
+	}
+	if len(page.Visits) != 3 || page.Visits[0].ID != 5 || page.Visits[0].Title != redactedLabel {
+		t.Errorf("1ページ目が期待値と異なる: %+v", page.Visits)
+	}
+	if page.NextCursor != "757425600,3" {
+		t.Errorf("next_cursor が期待値と異なる: %q", page.NextCursor)
+	}
+
+	rec = get("/api/history?limit=3&cursor=" + page.NextCursor)
+	page = HistoryPage{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("JSONのデコードに失敗: %v", err)
+	}
+	if len(page.Visits) != 2 || page.Visits[0].ID != 2 || page.NextCursor != "" {
+		t.Errorf("2ページ目が期待値と異なる: %+v next=%q", page.Visits, page.NextCursor)
+	}
+
+	if rec := get("/api/history?cursor=bad"); rec.Code != http.StatusBadRequest {
+		t.Errorf("不正なカーソルのステータスが期待値と異なる: got %d", rec.Code)
+	}
+
+	// cursor を付けなければ従来どおり配列を返す
+	var visits []HistoryVisit
+	if err := json.Unmarshal(get("/api/history?limit=2").Body.Bytes(), &visits); err != nil || len(visits) != 2 {
+		t.Errorf("cursor なしのレスポンスが期待値と異なる: %v %+v", err, visits)
+	}
+}
+
+// TestHandleAPIDashboardPanel はダッシュボードのパネルAPIのテスト
+func TestHandleAPIDashboardPanel(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	s := &WebServer{db: newPreparedDB(db), redactDomains: []string{"youtube.com"}}
+	defer func() { _ = s.db.Close() }()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/dashboard/{panel}", s.handleAPIDashboardPanel)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	var total DashboardTotal
+	if rec := get("/api/dashboard/total"); rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &total) != nil || total.TotalVisits != 5 {
+		t.Errorf("total = %d %s, want 5", rec.Code, rec.Body.String())
+	}
+
+	var domains []DomainPathStats
+	rec := get("/api/dashboard/domains")
+	if err := json.Unmarshal(rec.Body.Bytes(), &domains); err != nil {
+		t.Fatalf("JSONのデコードに失敗: %v", err)
+	}
+	// リダクト対象のドメインは [redacted] として数だけを表示する
+	if len(domains) != 4 || domains[0].Domain != redactedLabel || len(domains[0].Paths) != 0 || domains[1].Domain != "google.com" {
+		t.Errorf("ドメイン別統計が期待値と異なる: %+v", domains)
+	}
+
+	var visits []HistoryVisit
+	rec = get("/api/dashboard/recent?search=github")
+	if err := json.Unmarshal(rec.Body.Bytes(), &visits); err != nil {
+		t.Fatalf("JSONのデコードに失敗: %v", err)
+	}
+	if len(visits) != 2 {
+		t.Errorf("最近の訪問 = %d件, want 2", len(visits))
+	}
+
+	if rec := get("/api/dashboard/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("不明なパネルのステータス = %d, want 404", rec.Code)
+	}
+
+	// 時間内に集計が終わらなければ 504
+	s.panelTimeout = time.Nanosecond
+	if rec := get("/api/dashboard/domains"); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("タイムアウトしたパネルのステータス = %d, want 504", rec.Code)
+	}
+}
+
+// TestHandleDashboardShell はダッシュボードのページが集計せずに枠だけを返すことのテスト
+func TestHandleDashboardShell(t *testing.T) {
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	// DB がなくても表示できる
+	s := &WebServer{templates: tmpl}
+
+	rec := httptest.NewRecorder()
+	s.handleDashboard(rec, httptest.NewRequest("GET", "/?no_ignore=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータスが期待値と異なる: got %d", rec.Code)
+	}
+	for _, panel := range []string{`data-panel="domains"`, `data-panel="recent"`, `data-field="total"`} {
+		if !strings.Contains(rec.Body.String(), panel) {
+			t.Errorf("%s がない", panel)
+		}
+	}
+}
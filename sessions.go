@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// browsingSessionGap はこれより間が空いた訪問を別のブラウジングセッションとみなす間隔
+const browsingSessionGap = 30 * time.Minute
+
+// BrowsingSession は間を空けずに続いた訪問のまとまり
+type BrowsingSession struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	VisitCount int       `json:"visit_count"`
+	// Domains はセッション中の訪問をドメインごとに数えたもの（訪問の多い順）
+	Domains []DomainStats `json:"domains"`
+	visits  []HistoryVisit
+}
+
+// splitBrowsingSessions は古い順に並んだ訪問を browsingSessionGap より空いたところで区切る
+func splitBrowsingSessions(visits []HistoryVisit) []BrowsingSession {
+	var sessions []BrowsingSession
+	for _, v := range visits {
+		if n := len(sessions); n == 0 || v.VisitTime.Sub(sessions[n-1].End) > browsingSessionGap {
+			sessions = append(sessions, BrowsingSession{Start: v.VisitTime})
+		}
+		s := &sessions[len(sessions)-1]
+		s.End = v.VisitTime
+		s.VisitCount++
+		s.visits = append(s.visits, v)
+	}
+	for i := range sessions {
+		sessions[i].Domains = countVisitDomains(sessions[i].visits)
+	}
+	return sessions
+}
+
+// countVisitDomains は訪問をURLのホスト名ごとに数え、訪問の多い順に返す
+func countVisitDomains(visits []HistoryVisit) []DomainStats {
+	counts := make(map[string]int)
+	for _, v := range visits {
+		domain := extractDomain(v.URL)
+		if domain == "" {
+			domain = "不明"
+		}
+		counts[domain]++
+	}
+	stats := make([]DomainStats, 0, len(counts))
+	for domain, count := range counts {
+		stats = append(stats, DomainStats{Domain: domain, VisitCount: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].VisitCount != stats[j].VisitCount {
+			return stats[i].VisitCount > stats[j].VisitCount
+		}
+		return stats[i].Domain < stats[j].Domain
+	})
+	setDomainShares(stats)
+	return stats
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ショートカット（hist shortcut）のアクション
+const (
+	ShortcutActionRecent   = "recent"
+	ShortcutActionSearch   = "search"
+	ShortcutActionToday    = "today"
+	ShortcutActionOpen     = "open"
+	ShortcutActionCallback = "callback"
+)
+
+// defaultShortcutLimit は recent・search で返す訪問の既定の件数
+const defaultShortcutLimit = 10
+
+// shortcutTodayDomains は today で返す上位ドメインの数
+const shortcutTodayDomains = 5
+
+// shortcutParams はアクションごとの引数の名前（位置引数の順で、x-callback-url のパラメータ名と同じ）
+// 引数の形はショートカット・AppleScript から呼ぶため変えない
+var shortcutParams = map[string][]string{
+	ShortcutActionRecent: {"limit"},
+	ShortcutActionSearch: {"q", "limit"},
+	ShortcutActionToday:  {},
+	ShortcutActionOpen:   {"url"},
+}
+
+// ShortcutResponse は hist shortcut が出力する1つの JSON オブジェクト
+// 失敗した場合も ok を false にして同じ形で出力する
+type ShortcutResponse struct {
+	OK     bool   `json:"ok"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+	// Callback は callback で開いた x-success・x-error の URL
+	Callback string `json:"callback,omitempty"`
+}
+
+// ShortcutVisits は recent・search の結果
+type ShortcutVisits struct {
+	Count  int            `json:"count"`
+	Visits []HistoryVisit `json:"visits"`
+}
+
+// ShortcutToday は today の結果
+type ShortcutToday struct {
+	Date       string        `json:"date"`
+	Visits     int           `json:"visits"`
+	TopDomains []DomainStats `json:"top_domains"`
+}
+
+// ShortcutOpened は open の結果
+type ShortcutOpened struct {
+	URL string `json:"url"`
+}
+
+// shortcutEnv はアクションの実行に使う履歴DBと設定
+type shortcutEnv struct {
+	// openDB は履歴DBを開く（open では開かない）
+	openDB        func() (dbQuerier, error)
+	filter        SearchFilter
+	redactDomains []string
+	now           time.Time
+	// openURL は URL をブラウザ・アプリで開く
+	openURL func(string) error
+}
+
+// parseShortcutLimit は件数の引数を解析する（省略時は既定の件数）
+func parseShortcutLimit(args []string, i int) (int, error) {
+	if len(args) <= i || args[i] == "" {
+		return defaultShortcutLimit, nil
+	}
+	n, err := strconv.Atoi(args[i])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("件数は1以上の数で指定してください: %s", args[i])
+	}
+	return n, nil
+}
+
+// runShortcutAction は action を実行して結果を返す
+func runShortcutAction(env shortcutEnv, action string, args []string) (any, error) {
+	params, ok := shortcutParams[action]
+	if !ok {
+		return nil, fmt.Errorf("アクションは recent・search・today・open・callback のいずれかで指定してください: %s", action)
+	}
+	if len(args) > len(params) {
+		return nil, fmt.Errorf("%s の引数が多すぎます（%s）", action, strings.Join(params, "・"))
+	}
+
+	if action == ShortcutActionOpen {
+		if len(args) == 0 || args[0] == "" {
+			return nil, errors.New("開くURLを指定してください")
+		}
+		u, err := url.Parse(args[0])
+		if err != nil || u.Scheme == "" {
+			return nil, fmt.Errorf("URLの形式が不正です: %s", args[0])
+		}
+		if err := env.openURL(u.String()); err != nil {
+			return nil, fmt.Errorf("URLを開けませんでした: %w", err)
+		}
+		return ShortcutOpened{URL: u.String()}, nil
+	}
+
+	db, err := env.openDB()
+	if err != nil {
+		return nil, err
+	}
+	filter := env.filter
+	switch action {
+	case ShortcutActionRecent, ShortcutActionSearch:
+		limitArg := 0
+		if action == ShortcutActionSearch {
+			if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+				return nil, errors.New("検索するキーワードを指定してください")
+			}
+			filter.Keyword = args[0]
+			limitArg = 1
+		}
+		limit, err := parseShortcutLimit(args, limitArg)
+		if err != nil {
+			return nil, err
+		}
+		visits, err := getRecentVisits(db, limit, filter)
+		if err != nil {
+			return nil, err
+		}
+		visits = redactVisits(visits, env.redactDomains)
+		if visits == nil {
+			visits = []HistoryVisit{}
+		}
+		return ShortcutVisits{Count: len(visits), Visits: visits}, nil
+	default:
+		today := time.Date(env.now.Year(), env.now.Month(), env.now.Day(), 0, 0, 0, 0, env.now.Location())
+		filter.From, filter.To = today, today
+		count, err := getFilteredVisitCount(db, filter)
+		if err != nil {
+			return nil, err
+		}
+		domains, err := getVisitDomainStats(db, shortcutTodayDomains, filter)
+		if err != nil {
+			return nil, err
+		}
+		domains = redactDomainStats(domains, env.redactDomains)
+		if domains == nil {
+			domains = []DomainStats{}
+		}
+		return ShortcutToday{Date: today.Format(TimeFormatDate), Visits: count, TopDomains: domains}, nil
+	}
+}
+
+// runShortcutCallback は hist://x-callback-url/<アクション>?<引数> を実行し、
+// 成功したら x-success に result（結果の JSON）を、失敗したら x-error に errorCode・errorMessage を付けて開く
+func runShortcutCallback(env shortcutEnv, rawURL string) ShortcutResponse {
+	resp := ShortcutResponse{Action: ShortcutActionCallback}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != "x-callback-url" {
+		resp.Error = fmt.Sprintf("hist://x-callback-url/<アクション>?<引数> の形式で指定してください: %s", rawURL)
+		return resp
+	}
+	action := strings.Trim(u.Path, "/")
+	query := u.Query()
+	var args []string
+	for _, name := range shortcutParams[action] {
+		if !query.Has(name) {
+			break
+		}
+		args = append(args, query.Get(name))
+	}
+	resp.Action = action
+
+	result, err := runShortcutAction(env, action, args)
+	callback := query.Get("x-success")
+	params := url.Values{}
+	if err != nil {
+		resp.Error = err.Error()
+		callback = query.Get("x-error")
+		params.Set("errorCode", "1")
+		params.Set("errorMessage", err.Error())
+	} else {
+		resp.OK, resp.Result = true, result
+		data, err := json.Marshal(result)
+		if err != nil {
+			resp.OK, resp.Error = false, fmt.Sprintf("JSON出力エラー: %v", err)
+			return resp
+		}
+		params.Set("result", string(data))
+	}
+	if callback == "" {
+		return resp
+	}
+	target, err := url.Parse(callback)
+	if err != nil {
+		resp.OK, resp.Error = false, fmt.Sprintf("コールバックのURLが不正です: %s", callback)
+		return resp
+	}
+	// コールバックの URL に元からあるパラメータは残す
+	q := target.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	target.RawQuery = q.Encode()
+	resp.Callback = target.String()
+	if err := env.openURL(resp.Callback); err != nil {
+		resp.OK, resp.Error = false, fmt.Sprintf("コールバックのURLを開けませんでした: %v", err)
+	}
+	return resp
+}
+
+// runShortcut は hist shortcut の引数を実行し、出力する1つの JSON オブジェクトを返す
+func runShortcut(env shortcutEnv, args []string) ShortcutResponse {
+	if len(args) == 0 {
+		return ShortcutResponse{Error: "アクションを指定してください（recent・search・today・open・callback）"}
+	}
+	action, args := args[0], args[1:]
+	if action == ShortcutActionCallback {
+		if len(args) != 1 {
+			return ShortcutResponse{Action: action, Error: "hist://x-callback-url/ で始まるURLを1つ指定してください"}
+		}
+		return runShortcutCallback(env, args[0])
+	}
+	resp := ShortcutResponse{Action: action}
+	result, err := runShortcutAction(env, action, args)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.OK, resp.Result = true, result
+	return resp
+}
+
+// loadShortcutLists はイグノアリストとリダクトリストを読み込む
+func loadShortcutLists(env *shortcutEnv) error {
+	entries, err := LoadIgnoreList()
+	if err != nil {
+		return fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+	}
+	if env.filter.IgnoreDomains, env.filter.IgnoreRules, err = splitIgnoreEntries(entries); err != nil {
+		return err
+	}
+	if env.redactDomains, err = LoadRedactList(); err != nil {
+		return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+	}
+	return nil
+}
+
+// runShortcutCommand は hist shortcut サブコマンドを実行する
+// ショートカットの「シェルスクリプトを実行」や AppleScript の do shell script から呼ぶため、
+// フラグは受け付けず、成功・失敗とも標準出力に1行の JSON オブジェクトを1つだけ出力して終了コード0で終わる
+func runShortcutCommand(args []string) error {
+	if len(args) == 1 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		fmt.Fprintf(os.Stderr, "使い方: hist shortcut <アクション> [引数...]\n\n")
+		fmt.Fprintf(os.Stderr, "  recent [件数]              最近の訪問\n")
+		fmt.Fprintf(os.Stderr, "  search <キーワード> [件数]  キーワードに一致する訪問\n")
+		fmt.Fprintf(os.Stderr, "  today                      今日の訪問数と上位ドメイン\n")
+		fmt.Fprintf(os.Stderr, "  open <URL>                 URLを開く\n")
+		fmt.Fprintf(os.Stderr, "  callback <URL>             hist://x-callback-url/<アクション>?q=...&limit=...&x-success=...&x-error=...\n\n")
+		fmt.Fprintf(os.Stderr, "  結果は {\"ok\":true,\"action\":...,\"result\":{...}} の1行の JSON で出力します（失敗しても ok が false の JSON）\n")
+		return nil
+	}
+
+	env := shortcutEnv{
+		now:     time.Now(),
+		openURL: func(u string) error { return exec.Command(openCommand, u).Run() },
+	}
+	var db dbQuerier
+	env.openDB = func() (dbQuerier, error) {
+		if db == nil {
+			opened, err := setupDatabase()
+			if err != nil {
+				return nil, err
+			}
+			db = opened
+		}
+		return db, nil
+	}
+	if err := loadShortcutLists(&env); err != nil {
+		resp := ShortcutResponse{Error: err.Error()}
+		if len(args) > 0 {
+			resp.Action = args[0]
+		}
+		return json.NewEncoder(os.Stdout).Encode(resp)
+	}
+	resp := runShortcut(env, args)
+	if closer, ok := db.(interface{ Close() error }); ok {
+		_ = closer.Close()
+	}
+	return json.NewEncoder(os.Stdout).Encode(resp)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestShortcutEnv はテスト用の履歴DBを使い、開いたURLを記録する shortcutEnv を作る
+func newTestShortcutEnv(t *testing.T) (shortcutEnv, *[]string) {
+	t.Helper()
+	db := setupTestDB(t)
+	t.Cleanup(func() { _ = db.Close() })
+	insertTestData(t, db)
+	var opened []string
+	env := shortcutEnv{
+		openDB:        func() (dbQuerier, error) { return db, nil },
+		redactDomains: []string{"youtube.com"},
+		now:           time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC),
+		openURL: func(u string) error {
+			opened = append(opened, u)
+			return nil
+		},
+	}
+	return env, &opened
+}
+
+func TestRunShortcut(t *testing.T) {
+	env, opened := newTestShortcutEnv(t)
+
+	resp := runShortcut(env, []string{"recent", "2"})
+	visits, ok := resp.Result.(ShortcutVisits)
+	if !resp.OK || resp.Action != ShortcutActionRecent || !ok || visits.Count != 2 || visits.Visits[0].ID != 5 {
+		t.Fatalf("recent 2 = %+v", resp)
+	}
+	if strings.Contains(visits.Visits[0].URL, "youtube") {
+		t.Errorf("リダクト対象のURLが出力された: %s", visits.Visits[0].URL)
+	}
+
+	resp = runShortcut(env, []string{"search", "GitHub"})
+	if visits, ok := resp.Result.(ShortcutVisits); !resp.OK || !ok || visits.Count != 2 {
+		t.Errorf("search GitHub = %+v", resp)
+	}
+	resp = runShortcut(env, []string{"search", "no-such-page"})
+	data, _ := json.Marshal(resp)
+	if !resp.OK || !strings.Contains(string(data), `"visits":[]`) {
+		t.Errorf("一致なしの search = %s, want 空の配列", data)
+	}
+
+	resp = runShortcut(env, []string{"today"})
+	today, ok := resp.Result.(ShortcutToday)
+	if !resp.OK || !ok || today.Date != "2025-01-01" || today.Visits != 3 || len(today.TopDomains) != 3 {
+		t.Errorf("today = %+v", resp)
+	}
+
+	resp = runShortcut(env, []string{"open", "https://go.dev/doc/"})
+	if !resp.OK || len(*opened) != 1 || (*opened)[0] != "https://go.dev/doc/" {
+		t.Errorf("open = %+v, 開いたURL %v", resp, *opened)
+	}
+
+	for _, args := range [][]string{nil, {"delete"}, {"recent", "0"}, {"recent", "5", "extra"}, {"search"}, {"search", " "}, {"open", "go.dev"}, {"callback"}} {
+		resp := runShortcut(env, args)
+		if resp.OK || resp.Error == "" || resp.Result != nil {
+			t.Errorf("runShortcut(%q) = %+v, want 失敗", args, resp)
+		}
+		// 失敗しても1行の JSON オブジェクトになる
+		data, err := json.Marshal(resp)
+		if err != nil || !strings.HasPrefix(string(data), `{"ok":false`) {
+			t.Errorf("runShortcut(%q) の JSON = %s, %v", args, data, err)
+		}
+	}
+}
+
+func TestRunShortcutCallback(t *testing.T) {
+	env, opened := newTestShortcutEnv(t)
+
+	resp := runShortcut(env, []string{"callback", "hist://x-callback-url/search?q=GitHub&limit=1&x-success=" +
+		url.QueryEscape("shortcuts://x-callback-url/run-shortcut?name=履歴") + "&x-error=" + url.QueryEscape("shortcuts://error")})
+	if !resp.OK || resp.Action != ShortcutActionSearch || len(*opened) != 1 || resp.Callback != (*opened)[0] {
+		t.Fatalf("callback = %+v, 開いたURL %v", resp, *opened)
+	}
+	u, err := url.Parse(resp.Callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result ShortcutVisits
+	if err := json.Unmarshal([]byte(u.Query().Get("result")), &result); err != nil || result.Count != 1 {
+		t.Errorf("x-success の result = %q, %v", u.Query().Get("result"), err)
+	}
+	if u.Host != "x-callback-url" || u.Query().Get("name") != "履歴" {
+		t.Errorf("x-success = %s, want 元のパラメータを残す", resp.Callback)
+	}
+
+	// 失敗したら x-error に errorMessage を付けて開く
+	resp = runShortcut(env, []string{"callback", "hist://x-callback-url/recent?limit=abc&x-success=shortcuts://ok&x-error=shortcuts://error"})
+	if resp.OK || !strings.HasPrefix(resp.Callback, "shortcuts://error?") || !strings.Contains(resp.Callback, "errorMessage=") {
+		t.Errorf("失敗した callback = %+v", resp)
+	}
+
+	// x-success がなければ結果を出力するだけ
+	*opened = nil
+	if resp := runShortcut(env, []string{"callback", "hist://x-callback-url/today"}); !resp.OK || resp.Callback != "" || len(*opened) != 0 {
+		t.Errorf("x-success なしの callback = %+v, 開いたURL %v", resp, *opened)
+	}
+	if resp := runShortcut(env, []string{"callback", "hist://search?q=go"}); resp.OK {
+		t.Errorf("x-callback-url ではない callback = %+v", resp)
+	}
+}
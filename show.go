@@ -0,0 +1,435 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// showCandidateLimit は部分一致で複数見つかったときに表示する候補数
+const showCandidateLimit = 10
+
+// TitleSpan はあるタイトルが使われていた期間
+type TitleSpan struct {
+	Title      string    `json:"title"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	VisitCount int       `json:"visit_count"`
+}
+
+// URLDetail はURL単位の詳細情報
+type URLDetail struct {
+	ID             int64        `json:"id"`
+	URL            string       `json:"url"`
+	Domain         string       `json:"domain"`
+	VisitCount     int          `json:"visit_count"`
+	RecordedVisits int          `json:"recorded_visits"`
+	FirstVisit     *time.Time   `json:"first_visit,omitempty"`
+	LastVisit      *time.Time   `json:"last_visit,omitempty"`
+	Timeline       []DailyStats `json:"timeline,omitempty"`
+	Titles         []TitleSpan  `json:"titles,omitempty"`
+	RedirectsFrom  []string     `json:"redirects_from,omitempty"`
+	RedirectsTo    []string     `json:"redirects_to,omitempty"`
+	// Annotations は hist tag・hist note で付けたタグ・メモ
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// Visit は -id で訪問IDを指定したときの、その訪問
+	Visit *HistoryVisit `json:"visit,omitempty"`
+}
+
+// runShowCommand は hist show サブコマンドを実行する
+func runShowCommand(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	visitID := fs.Int64("id", 0, "訪問ID（-json・-csv・-ndjson の id）で指定")
+	noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist show [オプション] <URL または ID>\n")
+		fmt.Fprintf(fs.Output(), "        hist show -id <訪問ID>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *visitID > 0 && fs.NArg() != 0 {
+		return errors.New("-id と URL・ID は同時に指定できません")
+	}
+	if *visitID <= 0 && fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("URLまたはIDを1つ指定してください")
+	}
+
+	var redactDomains []string
+	if !*noRedact {
+		var err error
+		if redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	var detail URLDetail
+	if *visitID > 0 {
+		visit, itemID, err := getVisitByID(db, *visitID)
+		if err != nil {
+			return err
+		}
+		if detail, err = getURLDetail(db, itemID); err != nil {
+			return err
+		}
+		detail.Visit = &visit
+	} else {
+		id, err := resolveHistoryItem(db, fs.Arg(0), redactDomains)
+		if err != nil {
+			return err
+		}
+		if detail, err = getURLDetail(db, id); err != nil {
+			return err
+		}
+	}
+
+	sidecarPath, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+	if detail.Annotations, err = loadAnnotations(sidecarPath, detail.URL); err != nil {
+		return err
+	}
+	detail = redactURLDetail(detail, redactDomains)
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(detail)
+	}
+	printURLDetail(os.Stdout, detail)
+	return nil
+}
+
+// resolveHistoryItem はURL・ID・URLの一部から history_items.id を特定する
+// 部分一致で複数見つかった場合は候補をエラーメッセージに含める（リダクト対象のURLは伏せる）
+func resolveHistoryItem(db dbQuerier, arg string, redactDomains []string) (int64, error) {
+	var id int64
+
+	if n, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		err := db.QueryRow(`SELECT id FROM history_items WHERE id = ?`, n).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("URLの検索に失敗: %w", err)
+		}
+	}
+
+	err := db.QueryRow(`SELECT id FROM history_items WHERE url = ?`, arg).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("URLの検索に失敗: %w", err)
+	}
+
+	// 完全一致がなければ部分一致で探す
+	rows, err := db.Query(`SELECT id, url FROM history_items WHERE url LIKE ? ORDER BY visit_count DESC LIMIT ?`,
+		"%"+arg+"%", showCandidateLimit+1)
+	if err != nil {
+		return 0, fmt.Errorf("URLの検索に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []int64
+	var urls []string
+	for rows.Next() {
+		var candidateID int64
+		var url string
+		if err := rows.Scan(&candidateID, &url); err != nil {
+			return 0, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		ids = append(ids, candidateID)
+		urls = append(urls, url)
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("履歴に見つかりません: %s", arg)
+	case 1:
+		return ids[0], nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "複数のURLが一致しました。URLかIDで指定してください:")
+	for i, url := range urls {
+		if i == showCandidateLimit {
+			b.WriteString("\n  ...")
+			break
+		}
+		if domainMatchesList(extractDomain(url), redactDomains) {
+			url = redactedLabel
+		}
+		fmt.Fprintf(&b, "\n  [%d] %s", ids[i], url)
+	}
+	return 0, errors.New(b.String())
+}
+
+// getVisitByID は訪問ID（history_visits.id）の訪問と、その URL の history_items.id を返す
+func getVisitByID(db dbQuerier, visitID int64) (HistoryVisit, int64, error) {
+	var itemID int64
+	err := db.QueryRow(`SELECT history_item FROM history_visits WHERE id = ?`, visitID).Scan(&itemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return HistoryVisit{}, 0, fmt.Errorf("訪問IDが見つかりません: %d", visitID)
+	}
+	if err != nil {
+		return HistoryVisit{}, 0, fmt.Errorf("訪問の検索に失敗: %w", err)
+	}
+
+	var visit HistoryVisit
+	query, args := NewQueryBuilder(historyBaseQuery).WithRawCondition("hv.id = ?", visitID).Build()
+	err = streamHistoryQuery(db, query, args, func(v HistoryVisit) error {
+		visit, _ = sanitizeVisit(v)
+		return errStopStream
+	})
+	if err != nil {
+		return HistoryVisit{}, 0, err
+	}
+	return visit, itemID, nil
+}
+
+// getURLDetail はURLの訪問履歴・タイトルの変遷・リダイレクトを集める
+func getURLDetail(db dbQuerier, id int64) (URLDetail, error) {
+	detail := URLDetail{ID: id}
+
+	var domain sql.NullString
+	err := db.QueryRow(`SELECT url, domain_expansion, visit_count FROM history_items WHERE id = ?`, id).
+		Scan(&detail.URL, &domain, &detail.VisitCount)
+	if err != nil {
+		return detail, fmt.Errorf("URLの取得に失敗: %w", err)
+	}
+	detail.Domain = domain.String
+	if detail.Domain == "" {
+		detail.Domain = extractDomain(detail.URL)
+	}
+
+	rows, err := db.Query(`
+		SELECT hv.visit_time, COALESCE(hv.title, '')
+		FROM history_visits hv
+		WHERE hv.history_item = ?
+		ORDER BY hv.visit_time`, id)
+	if err != nil {
+		return detail, fmt.Errorf("訪問履歴の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	dateCounts := make(map[string]int)
+	var titles []TitleSpan
+	for rows.Next() {
+		var visitTime float64
+		var title string
+		if err := rows.Scan(&visitTime, &title); err != nil {
+			return detail, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		t := convertCoreDataTimestamp(visitTime)
+		detail.RecordedVisits++
+		if detail.FirstVisit == nil {
+			detail.FirstVisit = &t
+		}
+		last := t
+		detail.LastVisit = &last
+		dateCounts[t.Format(TimeFormatDate)]++
+
+		// タイトルが変わるたびに新しい期間を始める
+		title, _ = sanitizeText(title)
+		if n := len(titles); n > 0 && titles[n-1].Title == title {
+			titles[n-1].LastSeen = t
+			titles[n-1].VisitCount++
+		} else {
+			titles = append(titles, TitleSpan{Title: title, FirstSeen: t, LastSeen: t, VisitCount: 1})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return detail, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	detail.Titles = titles
+
+	for date, count := range dateCounts {
+		detail.Timeline = append(detail.Timeline, DailyStats{Date: date, VisitCount: count})
+	}
+	sort.Slice(detail.Timeline, func(i, j int) bool {
+		return detail.Timeline[i].Date < detail.Timeline[j].Date
+	})
+
+	detail.RedirectsFrom, detail.RedirectsTo, err = getRedirects(db, id)
+	if err != nil {
+		return detail, err
+	}
+
+	return detail, nil
+}
+
+// redactURLDetail はリダクト対象のURL・タイトル・リダイレクトを伏せたコピーを返す
+// URL自体が対象ならタグ・メモも出さない。訪問数やタイムラインはそのまま残す
+func redactURLDetail(d URLDetail, redactDomains []string) URLDetail {
+	if len(redactDomains) == 0 {
+		return d
+	}
+	if domainMatchesList(d.Domain, redactDomains) || domainMatchesList(extractDomain(d.URL), redactDomains) {
+		d.URL = redactedLabel
+		d.Domain = redactedLabel
+		titles := make([]TitleSpan, len(d.Titles))
+		for i, t := range d.Titles {
+			t.Title = redactedLabel
+			titles[i] = t
+		}
+		d.Titles = titles
+		d.Annotations = nil
+	}
+	redactURLs := func(urls []string) []string {
+		if urls == nil {
+			return nil
+		}
+		redacted := make([]string, len(urls))
+		for i, u := range urls {
+			if domainMatchesList(extractDomain(u), redactDomains) {
+				u = redactedLabel
+			}
+			redacted[i] = u
+		}
+		return redacted
+	}
+	d.RedirectsFrom = redactURLs(d.RedirectsFrom)
+	d.RedirectsTo = redactURLs(d.RedirectsTo)
+	if d.Visit != nil {
+		visit := redactVisits([]HistoryVisit{*d.Visit}, redactDomains)[0]
+		d.Visit = &visit
+	}
+	return d
+}
+
+// getRedirects はURLへのリダイレクト元・URLからのリダイレクト先を返す
+// 古いスキーマなどで redirect_source カラムがない場合は空を返す
+func getRedirects(db dbQuerier, id int64) ([]string, []string, error) {
+	ok, err := hasColumn(db, "history_visits", "redirect_source")
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+
+	collect := func(query string) ([]string, error) {
+		rows, err := db.Query(query, id)
+		if err != nil {
+			return nil, fmt.Errorf("リダイレクトの取得に失敗: %w", err)
+		}
+		defer func() { _ = rows.Close() }()
+
+		var urls []string
+		for rows.Next() {
+			var url string
+			if err := rows.Scan(&url); err != nil {
+				return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+			}
+			urls = append(urls, url)
+		}
+		return urls, rows.Err()
+	}
+
+	from, err := collect(`
+		SELECT DISTINCT src_item.url
+		FROM history_visits hv
+		JOIN history_visits src ON hv.redirect_source = src.id
+		JOIN history_items src_item ON src.history_item = src_item.id
+		WHERE hv.history_item = ?
+		ORDER BY src_item.url`)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := collect(`
+		SELECT DISTINCT dst_item.url
+		FROM history_visits hv
+		JOIN history_visits dst ON hv.redirect_destination = dst.id
+		JOIN history_items dst_item ON dst.history_item = dst_item.id
+		WHERE hv.history_item = ?
+		ORDER BY dst_item.url`)
+	if err != nil {
+		return nil, nil, err
+	}
+	return from, to, nil
+}
+
+// hasColumn はテーブルにカラムが存在するかチェック
+func hasColumn(db dbQuerier, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("スキーマの確認に失敗: %w", err)
+	}
+	return count > 0, nil
+}
+
+// printURLDetail はURLの詳細をテキスト形式で出力する
+func printURLDetail(w io.Writer, d URLDetail) {
+	_, _ = fmt.Fprintf(w, "\n🔗 %s\n", d.URL)
+	_, _ = fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	_, _ = fmt.Fprintf(w, "ID:         %d\n", d.ID)
+	if d.Visit != nil {
+		_, _ = fmt.Fprintf(w, "訪問:       #%d  %s  %s\n", d.Visit.ID, d.Visit.VisitTime.Format(TimeFormatFull), d.Visit.Title)
+	}
+	_, _ = fmt.Fprintf(w, "ドメイン:   %s\n", d.Domain)
+	_, _ = fmt.Fprintf(w, "訪問数:     %d（記録されている訪問: %d）\n", d.VisitCount, d.RecordedVisits)
+	if d.FirstVisit != nil && d.LastVisit != nil {
+		_, _ = fmt.Fprintf(w, "初回訪問:   %s\n", d.FirstVisit.Format(TimeFormatFull))
+		_, _ = fmt.Fprintf(w, "最終訪問:   %s\n", d.LastVisit.Format(TimeFormatFull))
+	}
+	_, _ = fmt.Fprintln(w)
+
+	if len(d.Timeline) > 0 {
+		_, _ = fmt.Fprintf(w, "📅 訪問タイムライン\n")
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		maxCount := 0
+		for _, s := range d.Timeline {
+			maxCount = max(maxCount, s.VisitCount)
+		}
+		for _, s := range d.Timeline {
+			barLen := max(1, int(float64(s.VisitCount)/float64(maxCount)*BarChartWidth))
+			_, _ = fmt.Fprintf(w, "  %s  %s %d\n", s.Date, strings.Repeat("█", barLen), s.VisitCount)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(d.Titles) > 0 {
+		_, _ = fmt.Fprintf(w, "📝 タイトルの変遷\n")
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		for _, t := range d.Titles {
+			title := t.Title
+			if title == "" {
+				title = "(タイトルなし)"
+			}
+			_, _ = fmt.Fprintf(w, "  %s 〜 %s  (%d回)\n", t.FirstSeen.Format(TimeFormatDate), t.LastSeen.Format(TimeFormatDate), t.VisitCount)
+			_, _ = fmt.Fprintf(w, "    %s\n", truncateText(title, MaxTitleLength))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(d.RedirectsFrom) > 0 || len(d.RedirectsTo) > 0 {
+		_, _ = fmt.Fprintf(w, "↪️  リダイレクト\n")
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		for _, u := range d.RedirectsFrom {
+			_, _ = fmt.Fprintf(w, "  ← %s\n", u)
+		}
+		for _, u := range d.RedirectsTo {
+			_, _ = fmt.Fprintf(w, "  → %s\n", u)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	printAnnotations(w, d.Annotations)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveHistoryItem(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tests := []struct {
+		name    string
+		arg     string
+		want    int64
+		wantErr bool
+	}{
+		{"ID", "2", 2, false},
+		{"完全一致", "https://github.com/test", 1, false},
+		{"部分一致", "youtube", 2, false},
+		{"複数一致", ".com", 0, true},
+		{"見つからない", "nowhere.example", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveHistoryItem(db, tt.arg, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveHistoryItem(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveHistoryItem(%q) = %d, want %d", tt.arg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetURLDetail(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	detail, err := getURLDetail(db, 1)
+	if err != nil {
+		t.Fatalf("getURLDetail失敗: %v", err)
+	}
+
+	if detail.URL != "https://github.com/test" || detail.VisitCount != 10 || detail.RecordedVisits != 2 {
+		t.Errorf("detail = %+v", detail)
+	}
+	// 2025-01-01 と 2025-01-02 に1回ずつ
+	if len(detail.Timeline) != 2 || detail.Timeline[0].Date != "2025-01-01" {
+		t.Errorf("Timeline = %+v", detail.Timeline)
+	}
+	// タイトルが途中で変わっている
+	if len(detail.Titles) != 2 || detail.Titles[0].Title != "GitHub - Test Repo" || detail.Titles[1].Title != "GitHub - Another Page" {
+		t.Errorf("Titles = %+v", detail.Titles)
+	}
+	// テスト用スキーマにはリダイレクト列がない
+	if detail.RedirectsFrom != nil || detail.RedirectsTo != nil {
+		t.Errorf("リダイレクト列がないのに結果がある: %+v", detail)
+	}
+
+	var buf bytes.Buffer
+	printURLDetail(&buf, detail)
+	for _, want := range []string{"https://github.com/test", "訪問タイムライン", "タイトルの変遷"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("出力に %q が含まれていない", want)
+		}
+	}
+}
+
+func TestGetURLDetailWithRedirects(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		ALTER TABLE history_visits ADD COLUMN redirect_source INTEGER;
+		ALTER TABLE history_visits ADD COLUMN redirect_destination INTEGER;
+		UPDATE history_visits SET redirect_destination = 1 WHERE id = 3;
+		UPDATE history_visits SET redirect_source = 3 WHERE id = 1;
+	`)
+	if err != nil {
+		t.Fatalf("リダイレクト列の追加に失敗: %v", err)
+	}
+
+	detail, err := getURLDetail(db, 1)
+	if err != nil {
+		t.Fatalf("getURLDetail失敗: %v", err)
+	}
+	if len(detail.RedirectsFrom) != 1 || detail.RedirectsFrom[0] != "https://google.com/search" {
+		t.Errorf("RedirectsFrom = %v", detail.RedirectsFrom)
+	}
+
+	google, err := getURLDetail(db, 3)
+	if err != nil {
+		t.Fatalf("getURLDetail失敗: %v", err)
+	}
+	if len(google.RedirectsTo) != 1 || google.RedirectsTo[0] != "https://github.com/test" {
+		t.Errorf("RedirectsTo = %v", google.RedirectsTo)
+	}
+
+	// リダクト対象へのリダイレクトは伏せる
+	redacted := redactURLDetail(detail, []string{"google.com"})
+	if redacted.URL != "https://github.com/test" || len(redacted.RedirectsFrom) != 1 || redacted.RedirectsFrom[0] != redactedLabel {
+		t.Errorf("リダイレクト元が伏せられていない: %+v", redacted)
+	}
+	if detail.RedirectsFrom[0] != "https://google.com/search" {
+		t.Errorf("元の詳細が書き換えられた: %v", detail.RedirectsFrom)
+	}
+
+	// URL自体がリダクト対象ならURL・ドメイン・タイトル・タグ・メモを伏せ、訪問数は残す
+	google.Annotations = []Annotation{{URL: google.URL, Note: "secret"}}
+	redacted = redactURLDetail(google, []string{"google.com"})
+	if redacted.URL != redactedLabel || redacted.Domain != redactedLabel || redacted.Annotations != nil ||
+		redacted.VisitCount != google.VisitCount || len(redacted.Titles) != len(google.Titles) {
+		t.Errorf("リダクト対象のURLが伏せられていない: %+v", redacted)
+	}
+	var buf bytes.Buffer
+	printURLDetail(&buf, redacted)
+	if strings.Contains(buf.String(), "google") || strings.Contains(buf.String(), "secret") {
+		t.Errorf("出力にリダクト対象が含まれている:\n%s", buf.String())
+	}
+}
+
+func TestResolveHistoryItemRedactsCandidates(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := resolveHistoryItem(db, ".com", []string{"youtube.com"})
+	if err == nil {
+		t.Fatal("複数一致でエラーにならない")
+	}
+	if strings.Contains(err.Error(), "youtube") || !strings.Contains(err.Error(), redactedLabel) {
+		t.Errorf("候補のリダクト対象が伏せられていない: %v", err)
+	}
+}
+
+func TestGetVisitByID(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	visit, itemID, err := getVisitByID(db, 4)
+	if err != nil {
+		t.Fatalf("getVisitByID失敗: %v", err)
+	}
+	if visit.ID != 4 || visit.Title != "GitHub - Another Page" || itemID != 1 {
+		t.Errorf("getVisitByID(4) = %+v, item %d", visit, itemID)
+	}
+
+	detail, err := getURLDetail(db, itemID)
+	if err != nil {
+		t.Fatalf("getURLDetail失敗: %v", err)
+	}
+	detail.Visit = &visit
+	var buf bytes.Buffer
+	printURLDetail(&buf, detail)
+	if !strings.Contains(buf.String(), "#4  2025-01-02 10:00:00  GitHub - Another Page") {
+		t.Errorf("出力に訪問が含まれていない:\n%s", buf.String())
+	}
+
+	if _, _, err := getVisitByID(db, 99); err == nil {
+		t.Error("存在しない訪問IDでエラーにならない")
+	}
+}
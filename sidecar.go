@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sidecarFileName は hist 自身のデータ（タグ・メモ・スターなど）を置くDB（設定ディレクトリに置く）
+// Safari の History.db は読み取り専用で開き、書き込むのはこのDBだけ
+const sidecarFileName = "hist.db"
+
+// sidecarSchema はサイドカーDBのスキーマ
+// tags・notes の visit_id が 0 の行はURL全体、それ以外は history_visits.id の訪問1件に付ける
+// stars はURL単位
+const sidecarSchema = `
+	CREATE TABLE IF NOT EXISTS tags (
+		url TEXT NOT NULL,
+		visit_id INTEGER NOT NULL DEFAULT 0,
+		tag TEXT NOT NULL,
+		added_at TEXT NOT NULL,
+		PRIMARY KEY (url, visit_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS tags_tag ON tags (tag);
+	CREATE TABLE IF NOT EXISTS notes (
+		url TEXT NOT NULL,
+		visit_id INTEGER NOT NULL DEFAULT 0,
+		note TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (url, visit_id)
+	);
+	CREATE TABLE IF NOT EXISTS stars (
+		url TEXT PRIMARY KEY,
+		title TEXT NOT NULL DEFAULT '',
+		starred_at TEXT NOT NULL
+	);`
+
+// SidecarDB は hist 自身のデータを置くDB
+type SidecarDB struct {
+	db *sql.DB
+}
+
+// getSidecarPath はサイドカーDBのパスを返す
+func getSidecarPath() (string, error) {
+	return getConfigFilePath(sidecarFileName)
+}
+
+// openSidecarDB は path のサイドカーDBを開く（なければ作る）
+func openSidecarDB(path string) (*SidecarDB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerms); err != nil {
+		return nil, fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+	}
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("サイドカーDBを開けませんでした: %w", err)
+	}
+	if _, err := db.Exec(sidecarSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("サイドカーDBの作成に失敗: %w", err)
+	}
+	return &SidecarDB{db: db}, nil
+}
+
+// openExistingSidecarDB はサイドカーDBがあれば開く（まだ作っていなければ nil）
+// 読むだけの処理で、空のDBを作らないために使う
+func openExistingSidecarDB(path string) (*SidecarDB, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("サイドカーDBの確認に失敗: %w", err)
+	}
+	return openSidecarDB(path)
+}
+
+// Close はサイドカーDBを閉じる
+func (s *SidecarDB) Close() error {
+	return s.db.Close()
+}
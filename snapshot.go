@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotDirName はスナップショットを置くディレクトリ（設定ディレクトリに置く）
+const snapshotDirName = "snapshots"
+
+// snapshotNameFormat はスナップショットのファイル名に使う作成日時の形式
+const snapshotNameFormat = "20060102-150405"
+
+// snapshotNamePattern はスナップショットのファイル名（/snapshots/{name} でこれ以外のファイルは返さない）
+var snapshotNamePattern = regexp.MustCompile(`^\d{8}-\d{6}(-\d+)?\.html$`)
+
+// SnapshotInfo は保存したスナップショット1件
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// getSnapshotDir はスナップショットのディレクトリのパスを返す
+func getSnapshotDir() (string, error) {
+	return getConfigFilePath(snapshotDirName)
+}
+
+// snapshotURL はスナップショットを表示するURL
+func snapshotURL(name string) string {
+	return "/snapshots/" + name
+}
+
+// buildSnapshot はダッシュボードと同じ件数で、絞り込んだ結果を集計する
+// ダッシュボードの各パネル（総訪問数・ドメイン・パス・最近の訪問）に時間帯別の訪問数を加える
+func buildSnapshot(db dbQuerier, cfg DashboardConfig, filter SearchFilter, redactDomains []string, now time.Time) (AnalysisResult, error) {
+	var result AnalysisResult
+	g := newTaskGroup(StatsParallelism)
+	g.Go(func() (err error) {
+		if filter.narrowsVisits() {
+			result.TotalVisits, err = getFilteredVisitCount(db, filter)
+		} else {
+			result.TotalVisits, err = getTotalVisits(db)
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		if filter.narrowsVisits() {
+			result.DomainStats, err = getVisitDomainStats(db, cfg.DomainLimit, filter)
+		} else {
+			result.DomainStats, err = getDomainStats(db, cfg.DomainLimit, filter)
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		result.DomainPathStats, err = getDomainPathStats(db, cfg.DomainLimit, cfg.PathLimit, filter)
+		return err
+	})
+	g.Go(func() (err error) {
+		visits, err := getRecentVisits(db, cfg.RecentVisits, filter)
+		result.RecentVisits = sanitizeVisits(visits)
+		return err
+	})
+	g.Go(func() (err error) {
+		result.HourlyStats, err = getHourlyStats(db, filter)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return AnalysisResult{}, err
+	}
+	result.Query = newQueryInfo(filter, now)
+	return redactResult(result, redactDomains), nil
+}
+
+// writeSnapshot は result を HTML レポートにして dir に保存する
+// ファイル名は作成日時で、同じ秒に作ったものがあれば -2, -3, ... を付ける
+func writeSnapshot(dir string, result AnalysisResult, now time.Time) (SnapshotInfo, error) {
+	var buf bytes.Buffer
+	if err := printHTMLReport(&buf, result, true, true, true, true, false); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("スナップショットの生成に失敗: %w", err)
+	}
+	if err := os.MkdirAll(dir, configDirPerms); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("スナップショットのディレクトリの作成に失敗: %w", err)
+	}
+
+	base := now.Format(snapshotNameFormat)
+	for i := 1; ; i++ {
+		name := base + ".html"
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d.html", base, i)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, configFilePerms)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return SnapshotInfo{}, fmt.Errorf("スナップショットの保存に失敗: %w", err)
+		}
+		_, err = f.Write(buf.Bytes())
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return SnapshotInfo{}, fmt.Errorf("スナップショットの保存に失敗: %w", err)
+		}
+		return SnapshotInfo{Name: name, URL: snapshotURL(name), CreatedAt: now, Size: int64(buf.Len())}, nil
+	}
+}
+
+// listSnapshots は dir のスナップショットを新しい順に返す（ディレクトリがなければ空）
+func listSnapshots(dir string) ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []SnapshotInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("スナップショットの一覧の取得に失敗: %w", err)
+	}
+	snapshots := []SnapshotInfo{}
+	for _, e := range entries {
+		if e.IsDir() || !snapshotNamePattern.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("スナップショットの一覧の取得に失敗: %w", err)
+		}
+		createdAt, err := time.ParseInLocation(snapshotNameFormat, e.Name()[:len(snapshotNameFormat)], time.Local)
+		if err != nil {
+			createdAt = info.ModTime()
+		}
+		snapshots = append(snapshots, SnapshotInfo{Name: e.Name(), URL: snapshotURL(e.Name()), CreatedAt: createdAt, Size: info.Size()})
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		}
+		// 同じ秒に作ったものは -2, -3, ... の大きい順（-10 は -9 より長い）
+		if len(snapshots[i].Name) != len(snapshots[j].Name) {
+			return len(snapshots[i].Name) > len(snapshots[j].Name)
+		}
+		return snapshots[i].Name > snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// handleAPISnapshots はスナップショットの API
+//
+//	GET  /api/snapshots          保存したスナップショットの一覧（新しい順）
+//	POST /api/snapshots?<絞り込み> 絞り込んだ表示をスナップショットとして保存し、201 で SnapshotInfo を返す
+//
+// 保存は Content-Type: application/json のリクエストだけを受け付ける（他のサイトのフォームから送らせないため）
+func (s *WebServer) handleAPISnapshots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snapshots, err := listSnapshots(s.snapshotDir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshots); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			http.Error(w, "Content-Type は application/json にしてください", http.StatusUnsupportedMediaType)
+			return
+		}
+		filter, err := s.requestFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		result, err := buildSnapshot(s.db, s.dashboardConfig(), filter, s.redactList(), now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		info, err := writeSnapshot(s.snapshotDir, result, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", info.URL)
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "許可されていないメソッドです", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSnapshot は保存したスナップショットをそのまま返す
+func (s *WebServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !snapshotNamePattern.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeFile(w, r, filepath.Join(s.snapshotDir, name))
+}
+
+// SnapshotsPageData はスナップショット一覧ページ用のデータ
+type SnapshotsPageData struct {
+	Theme     PageTheme
+	Snapshots []SnapshotInfo
+}
+
+// handleSnapshotsPage はスナップショットの一覧ページを表示
+func (s *WebServer) handleSnapshotsPage(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := listSnapshots(s.snapshotDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := SnapshotsPageData{Theme: requestTheme(w, r), Snapshots: snapshots}
+	if err := s.templates.ExecuteTemplate(w, "snapshots.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
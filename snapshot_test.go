@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAndListSnapshots(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	if got, err := listSnapshots(dir); err != nil || len(got) != 0 {
+		t.Fatalf("ディレクトリがないときの一覧 = %v, %v", got, err)
+	}
+
+	now := time.Date(2025, 1, 2, 10, 30, 0, 0, time.Local)
+	result := AnalysisResult{TotalVisits: 3, Query: newQueryInfo(SearchFilter{Keyword: "github"}, now)}
+	var names []string
+	for _, at := range []time.Time{now, now, now.Add(time.Minute)} {
+		info, err := writeSnapshot(dir, result, at)
+		if err != nil {
+			t.Fatalf("writeSnapshot: %v", err)
+		}
+		names = append(names, info.Name)
+	}
+	if names[0] != "20250102-103000.html" || names[1] != "20250102-103000-2.html" || names[2] != "20250102-103100.html" {
+		t.Errorf("ファイル名 = %v", names)
+	}
+	// スナップショット以外のファイルは一覧に出さない
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := listSnapshots(dir)
+	if err != nil {
+		t.Fatalf("listSnapshots: %v", err)
+	}
+	var listed []string
+	for _, s := range snapshots {
+		listed = append(listed, s.Name)
+	}
+	if strings.Join(listed, " ") != "20250102-103100.html 20250102-103000-2.html 20250102-103000.html" {
+		t.Errorf("一覧 = %v", listed)
+	}
+	if !snapshots[2].CreatedAt.Equal(now) || snapshots[2].URL != "/snapshots/20250102-103000.html" || snapshots[2].Size == 0 {
+		t.Errorf("スナップショット = %+v", snapshots[2])
+	}
+}
+
+func TestHandleSnapshots(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl, redactDomains: []string{"youtube.com"}, snapshotDir: t.TempDir()}
+	defer func() { _ = s.db.Close() }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /snapshots", s.handleSnapshotsPage)
+	mux.HandleFunc("GET /snapshots/{name}", s.handleSnapshot)
+	mux.HandleFunc("/api/snapshots", s.handleAPISnapshots)
+	do := func(method, target, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("POST", "/api/snapshots", ""); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Content-Type なしの POST のステータス = %d", rec.Code)
+	}
+	if rec := do("POST", "/api/snapshots?from=2025-13-01", "application/json"); rec.Code != http.StatusBadRequest {
+		t.Errorf("不正な日付の POST のステータス = %d", rec.Code)
+	}
+
+	rec := do("POST", "/api/snapshots?ignore=google", "application/json")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/snapshots のステータス = %d: %s", rec.Code, rec.Body.String())
+	}
+	var info SnapshotInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("JSON の解析に失敗: %v", err)
+	}
+	if rec.Header().Get("Location") != info.URL {
+		t.Errorf("Location = %q, URL = %q", rec.Header().Get("Location"), info.URL)
+	}
+
+	// 保存したスナップショットは絞り込んだ結果で、リダクトしたドメインは伏せる
+	rec = do("GET", info.URL, "")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "github.com") || !strings.Contains(body, redactedLabel) {
+		t.Fatalf("スナップショット（%d）:\n%s", rec.Code, body)
+	}
+	if strings.Contains(body, "google.com") || strings.Contains(body, "youtube.com") {
+		t.Errorf("除外・リダクトしたドメインがスナップショットにある:\n%s", body)
+	}
+
+	rec = do("GET", "/api/snapshots", "")
+	var snapshots []SnapshotInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &snapshots); err != nil || len(snapshots) != 1 || snapshots[0].Name != info.Name {
+		t.Errorf("GET /api/snapshots = %s (%v)", rec.Body.String(), err)
+	}
+	if rec := do("GET", "/snapshots", ""); !strings.Contains(rec.Body.String(), `href="`+info.URL+`"`) {
+		t.Errorf("一覧ページにスナップショットへのリンクがない:\n%s", rec.Body.String())
+	}
+
+	for _, name := range []string{"missing.html", "20250102-103000.html", "..%2Fhistory.db"} {
+		if rec := do("GET", "/snapshots/"+name, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("/snapshots/%s のステータス = %d", name, rec.Code)
+		}
+	}
+	if rec := do("DELETE", "/api/snapshots", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE のステータス = %d", rec.Code)
+	}
+}
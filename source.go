@@ -0,0 +1,64 @@
+package main
+
+import "fmt"
+
+// SourceSafari は -source を指定しない場合の履歴DBのソース名
+const SourceSafari = "safari"
+
+// visitSources は訪問のソース（どのブラウザ・デバイスの履歴か）
+// アーカイブDB（hist archive pull で取り込んだDB）の訪問は archive_visits の元のデバイス、
+// それ以外は履歴DBのソース名（-source）をソースとする
+type visitSources struct {
+	label string
+	// devices はアーカイブDBの訪問IDごとの元のデバイス（アーカイブDBでなければ nil）
+	devices map[int64]string
+}
+
+// loadVisitSources は db の訪問のソースを読み込む（label は archive_visits にない訪問のソース名）
+func loadVisitSources(db dbQuerier, label string) (*visitSources, error) {
+	if label == "" {
+		label = SourceSafari
+	}
+	sources := &visitSources{label: label}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'archive_visits'`).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("ソースの確認に失敗: %w", err)
+	}
+	if tables == 0 {
+		return sources, nil
+	}
+
+	rows, err := db.Query(`SELECT visit_id, device FROM archive_visits`)
+	if err != nil {
+		return nil, fmt.Errorf("ソースの取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	sources.devices = make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var device string
+		if err := rows.Scan(&id, &device); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		sources.devices[id] = device
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	return sources, nil
+}
+
+// sourceOf は訪問IDのソースを返す
+func (s *visitSources) sourceOf(id int64) string {
+	if device, ok := s.devices[id]; ok {
+		return device
+	}
+	return s.label
+}
+
+// tagVisits は visits にソースを付ける
+func (s *visitSources) tagVisits(visits []HistoryVisit) {
+	for i := range visits {
+		visits[i].Source = s.sourceOf(visits[i].ID)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestLoadVisitSources(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// アーカイブDBでなければ全ての訪問が履歴DBのソース
+	sources, err := loadVisitSources(db, "")
+	if err != nil {
+		t.Fatalf("loadVisitSources: %v", err)
+	}
+	if sources.sourceOf(1) != SourceSafari || sources.devices != nil {
+		t.Errorf("ソース = %q, devices = %v", sources.sourceOf(1), sources.devices)
+	}
+
+	// アーカイブDBの訪問は元のデバイス
+	if _, err := db.Exec(`CREATE TABLE archive_visits (visit_id INTEGER PRIMARY KEY, device TEXT NOT NULL, source_id INTEGER NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO archive_visits (visit_id, device, source_id) VALUES (1, 'macbook', 1), (2, 'iphone', 2), (4, 'macbook', 4)`); err != nil {
+		t.Fatal(err)
+	}
+	sources, err = loadVisitSources(db, "chrome")
+	if err != nil {
+		t.Fatalf("loadVisitSources: %v", err)
+	}
+	visits := []HistoryVisit{{ID: 1}, {ID: 2}, {ID: 3}}
+	sources.tagVisits(visits)
+	if visits[0].Source != "macbook" || visits[1].Source != "iphone" || visits[2].Source != "chrome" {
+		t.Errorf("ソース = %q, %q, %q", visits[0].Source, visits[1].Source, visits[2].Source)
+	}
+}
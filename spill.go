@@ -0,0 +1,271 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// spillEntryOverhead は部分集計1件あたりのメモリの見積もり（キー・タイトルの文字列を除く map のエントリと値）
+const spillEntryOverhead = 96
+
+// spillSchema は書き出した部分集計のテーブル（同じ grp・key の行は最後に SUM でまとめる）
+// title_seq は add した順番で、最後に add した空でないタイトルを選ぶのに使う
+const spillSchema = `
+PRAGMA journal_mode = OFF;
+PRAGMA synchronous = OFF;
+CREATE TABLE partials (
+	grp TEXT NOT NULL,
+	key TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	title_seq INTEGER NOT NULL
+);`
+
+// spillKey はグループ（ドメイン）とキー（パス）の組
+type spillKey struct {
+	Group string
+	Key   string
+}
+
+// spillEntry はキーごとの部分集計
+type spillEntry struct {
+	Count    int
+	Title    string
+	TitleSeq int64
+}
+
+// spillAggregator はドメイン・パスごとの訪問数と最新のタイトルを数える
+// 見積もったメモリが limit（バイト）を超えたら部分集計を一時 SQLite に書き出してメモリを空け、
+// 最後に SQLite で合計して上位だけを読み出す。limit が 0 なら書き出さずに全てメモリで数える
+type spillAggregator struct {
+	limit   int64
+	size    int64
+	seq     int64
+	entries map[spillKey]*spillEntry
+	dir     string
+	db      *sql.DB
+}
+
+// newSpillAggregator は limit バイトまでメモリで数える spillAggregator を作る
+func newSpillAggregator(limit int64) *spillAggregator {
+	return &spillAggregator{limit: limit, entries: make(map[spillKey]*spillEntry)}
+}
+
+// add は group・key の訪問数を足す（title が空でなければ最新のタイトルにする）
+func (a *spillAggregator) add(group, key string, count int, title string) error {
+	a.seq++
+	k := spillKey{Group: group, Key: key}
+	e, ok := a.entries[k]
+	if !ok {
+		e = &spillEntry{}
+		a.entries[k] = e
+		a.size += int64(len(group)+len(key)) + spillEntryOverhead
+	}
+	e.Count += count
+	if title != "" {
+		a.size += int64(len(title) - len(e.Title))
+		e.Title = title
+		e.TitleSeq = a.seq
+	}
+	if a.limit > 0 && a.size > a.limit {
+		return a.flush()
+	}
+	return nil
+}
+
+// spilled は部分集計を一時 SQLite に書き出したか
+func (a *spillAggregator) spilled() bool {
+	return a.db != nil
+}
+
+// flush はメモリの部分集計を一時 SQLite に書き出して空にする
+func (a *spillAggregator) flush() error {
+	if a.db == nil {
+		dir, err := os.MkdirTemp("", "hist-spill-*")
+		if err != nil {
+			return fmt.Errorf("一時ディレクトリの作成に失敗: %w", err)
+		}
+		a.dir = dir
+		db, err := sql.Open(SQLiteDriver, filepath.Join(dir, "spill.db"))
+		if err != nil {
+			return fmt.Errorf("一時DBを開けませんでした: %w", err)
+		}
+		// PRAGMA は接続ごとの設定のため、1本の接続だけを使う
+		useSharedConnection(db)
+		a.db = db
+		if _, err := db.Exec(spillSchema); err != nil {
+			return fmt.Errorf("一時DBの作成に失敗: %w", err)
+		}
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.Prepare(`INSERT INTO partials (grp, key, count, title, title_seq) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("部分集計の書き出しに失敗: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for k, e := range a.entries {
+		if _, err := stmt.Exec(k.Group, k.Key, e.Count, e.Title, e.TitleSeq); err != nil {
+			return fmt.Errorf("部分集計の書き出しに失敗: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("部分集計の書き出しに失敗: %w", err)
+	}
+	a.entries = make(map[spillKey]*spillEntry)
+	a.size = 0
+	return nil
+}
+
+// finish は残りの部分集計を書き出し、合計に使う索引を作る（書き出していなければ何もしない）
+func (a *spillAggregator) finish() error {
+	if a.db == nil {
+		return nil
+	}
+	if len(a.entries) > 0 {
+		if err := a.flush(); err != nil {
+			return err
+		}
+	}
+	if _, err := a.db.Exec(`CREATE INDEX IF NOT EXISTS partials_grp_key ON partials (grp, key)`); err != nil {
+		return fmt.Errorf("一時DBの索引の作成に失敗: %w", err)
+	}
+	return nil
+}
+
+// close は一時 SQLite を削除する
+func (a *spillAggregator) close() {
+	if a.db != nil {
+		_ = a.db.Close()
+	}
+	if a.dir != "" {
+		_ = os.RemoveAll(a.dir)
+	}
+}
+
+// spilledDomainStats は書き出した部分集計からドメイン別統計の上位 limit 件を求める
+// 割合は limit で切る前の全ドメインの合計に対して計算する（setDomainShares と同じ）
+func (a *spillAggregator) spilledDomainStats(limit int) ([]DomainStats, error) {
+	if err := a.finish(); err != nil {
+		return nil, err
+	}
+	var total int
+	if err := a.db.QueryRow(`SELECT COALESCE(SUM(count), 0) FROM partials`).Scan(&total); err != nil {
+		return nil, fmt.Errorf("ドメイン統計の集計に失敗: %w", err)
+	}
+	rows, err := a.db.Query(`SELECT grp, SUM(count) AS total FROM partials GROUP BY grp ORDER BY total DESC, grp LIMIT ?`, sqlLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("ドメイン統計の集計に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stats []DomainStats
+	for rows.Next() {
+		var s DomainStats
+		if err := rows.Scan(&s.Domain, &s.VisitCount); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	setDomainSharesOf(stats, total)
+	return stats, nil
+}
+
+// spilledDomainPathStats は書き出した部分集計からドメイン・パス別統計の上位 limit ドメインを求める
+// 各ドメインのパスは上位 pathLimit 件で、残りは OtherCount に数える
+func (a *spillAggregator) spilledDomainPathStats(limit, pathLimit int) ([]DomainPathStats, error) {
+	if err := a.finish(); err != nil {
+		return nil, err
+	}
+	rows, err := a.db.Query(`SELECT grp, SUM(count) AS total, COUNT(DISTINCT key) FROM partials GROUP BY grp ORDER BY total DESC, grp LIMIT ?`, sqlLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("ドメイン・パス統計の集計に失敗: %w", err)
+	}
+	var stats []DomainPathStats
+	for rows.Next() {
+		var ds DomainPathStats
+		var paths int
+		if err := rows.Scan(&ds.Domain, &ds.TotalCount, &paths); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		ds.HasPaths = paths > 1
+		stats = append(stats, ds)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	// 上位のドメインごとに、パスを訪問数の多い順に読む（タイトルは最後に add した空でないもの）
+	for i := range stats {
+		rows, err := a.db.Query(`
+			SELECT key, SUM(count) AS total,
+				COALESCE((SELECT p2.title FROM partials p2
+					WHERE p2.grp = p.grp AND p2.key = p.key AND p2.title != ''
+					ORDER BY p2.title_seq DESC LIMIT 1), '')
+			FROM partials p WHERE grp = ? GROUP BY key ORDER BY total DESC, key`, stats[i].Domain)
+		if err != nil {
+			return nil, fmt.Errorf("ドメイン・パス統計の集計に失敗: %w", err)
+		}
+		for rows.Next() {
+			var ps PathStats
+			if err := rows.Scan(&ps.Path, &ps.VisitCount, &ps.Title); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+			}
+			if len(stats[i].Paths) < pathLimit {
+				stats[i].Paths = append(stats[i].Paths, ps)
+			} else {
+				stats[i].OtherCount += ps.VisitCount
+			}
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// sqlLimit は 0 以下を無制限（-1）にした LIMIT の値
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+// parseByteSize は "512MB"・"2GB"・"800KB" のようなサイズをバイト数にする（単位がなければ MB、空なら 0）
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, nil
+	}
+	unit := int64(1 << 20)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("サイズとして読めません: %s（例: 512MB・2GB）", value)
+	}
+	return int64(n * float64(unit)), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  int64
+	}{
+		{"", 0},
+		{"512MB", 512 << 20},
+		{"2gb", 2 << 30},
+		{"1.5G", 3 << 29},
+		{"800 KB", 800 << 10},
+		{"64", 64 << 20},
+		{"100B", 100},
+	} {
+		got, err := parseByteSize(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"abc", "-1MB", "MB"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("parseByteSize(%q) がエラーにならない", value)
+		}
+	}
+}
+
+func TestSpilledDomainAggregation(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(`
+		INSERT INTO history_items (id, url, visit_count) VALUES
+		(1, 'https://github.com/a', 10),
+		(2, 'https://github.com/b', 7),
+		(3, 'https://github.com/c', 3),
+		(4, 'https://github.com/a?tab=1', 4),
+		(5, 'https://docs.github.com/x', 2),
+		(6, 'https://youtube.com/watch', 25),
+		(7, 'https://example.com', 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, 100, 'A old'), (2, 4, 200, 'A new'), (3, 2, 150, ''), (4, 6, 300, 'Video');
+	`); err != nil {
+		t.Fatalf("テストデータの挿入に失敗: %v", err)
+	}
+
+	format := func(v any) string { return fmt.Sprintf("%+v", v) }
+	for _, limit := range []int{0, 2} {
+		inMemory, err := getDomainStats(db, limit, SearchFilter{})
+		if err != nil {
+			t.Fatalf("getDomainStats: %v", err)
+		}
+		// 1バイトの上限では add のたびに一時 SQLite に書き出す
+		spilled, err := getDomainStats(db, limit, SearchFilter{MemoryLimit: 1})
+		if err != nil {
+			t.Fatalf("getDomainStats(MemoryLimit): %v", err)
+		}
+		if format(spilled) != format(inMemory) {
+			t.Errorf("limit %d: 書き出した集計 = %s, メモリの集計 = %s", limit, format(spilled), format(inMemory))
+		}
+
+		inMemoryPaths, err := getDomainPathStats(db, limit, 2, SearchFilter{})
+		if err != nil {
+			t.Fatalf("getDomainPathStats: %v", err)
+		}
+		spilledPaths, err := getDomainPathStats(db, limit, 2, SearchFilter{MemoryLimit: 1})
+		if err != nil {
+			t.Fatalf("getDomainPathStats(MemoryLimit): %v", err)
+		}
+		if format(spilledPaths) != format(inMemoryPaths) {
+			t.Errorf("limit %d: 書き出したパス別集計 = %s, メモリの集計 = %s", limit, format(spilledPaths), format(inMemoryPaths))
+		}
+	}
+
+	paths, err := getDomainPathStats(db, 1, 2, SearchFilter{MemoryLimit: 1})
+	if err != nil {
+		t.Fatalf("getDomainPathStats: %v", err)
+	}
+	// github.com の /a は2つのURLの合計で、タイトルは最後の空でないもの
+	if got := format(paths); got != "[{Domain:github.com TotalCount:26 Paths:[{Path:/a Title:A new VisitCount:14} {Path:/b Title: VisitCount:7}] HasPaths:true OtherCount:5}]" {
+		t.Errorf("paths = %s", got)
+	}
+}
+
+func TestSpillAggregatorFlushesOverLimit(t *testing.T) {
+	a := newSpillAggregator(3 * (spillEntryOverhead + 2))
+	defer a.close()
+	for i := range 3 {
+		if err := a.add(fmt.Sprintf("d%d", i), "", 1, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.spilled() {
+		t.Fatal("上限までで書き出した")
+	}
+	if err := a.add("d3", "", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !a.spilled() || len(a.entries) != 0 {
+		t.Errorf("上限を超えても書き出していない: spilled=%v entries=%d", a.spilled(), len(a.entries))
+	}
+	if err := a.add("d0", "", 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := a.spilledDomainStats(0)
+	if err != nil {
+		t.Fatalf("spilledDomainStats: %v", err)
+	}
+	if got := fmt.Sprintf("%+v", stats[0]); got != "{Domain:d0 VisitCount:3 Percentage:50 CumulativePercentage:50}" {
+		t.Errorf("stats[0] = %s", got)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// sqlNullDisplay はテーブル・CSV出力でのNULLの表示
+const sqlNullDisplay = "NULL"
+
+// SQLQueryResult は任意SQLクエリの結果
+type SQLQueryResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// sqlOutputOptions はSQL結果の出力オプション（hist sql / hist query 共通）
+type sqlOutputOptions struct {
+	jsonOutput bool
+	csvOutput  bool
+	tsvOutput  bool
+	outputFile string
+}
+
+// registerFlags は出力オプションのフラグを登録する
+func (o *sqlOutputOptions) registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.jsonOutput, "json", false, "JSON形式で出力")
+	fs.BoolVar(&o.csvOutput, "csv", false, "CSV形式で出力")
+	fs.BoolVar(&o.tsvOutput, "tsv", false, "TSV形式で出力")
+	fs.StringVar(&o.outputFile, "output", "", "出力ファイルパス")
+}
+
+// write は結果を指定された形式で出力する
+func (o *sqlOutputOptions) write(result SQLQueryResult) error {
+	var output io.Writer = os.Stdout
+	if o.outputFile != "" {
+		f, err := os.Create(o.outputFile)
+		if err != nil {
+			return fmt.Errorf("ファイル作成エラー: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		output = f
+	}
+
+	switch {
+	case o.jsonOutput:
+		return writeSQLResultJSON(output, result)
+	case o.csvOutput:
+		return writeSQLResultCSV(output, result, ',')
+	case o.tsvOutput:
+		return writeSQLResultCSV(output, result, '\t')
+	default:
+		return writeSQLResultTable(output, result)
+	}
+}
+
+// runSQLCommand は hist sql サブコマンドを実行する
+func runSQLCommand(args []string) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	var opts sqlOutputOptions
+	opts.registerFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist sql [オプション] \"SELECT ...\"\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("SQLクエリを1つ指定してください")
+	}
+
+	return runReadOnlySQL(fs.Arg(0), &opts)
+}
+
+// runReadOnlySQL はクエリを検証・実行して結果を出力する
+func runReadOnlySQL(query string, opts *sqlOutputOptions) error {
+	if err := validateReadOnlySQL(query); err != nil {
+		return err
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	result, err := executeReadOnlySQL(db, query)
+	if err != nil {
+		return err
+	}
+	return opts.write(result)
+}
+
+// validateReadOnlySQL はクエリが単一のSELECT文（WITH句を含む）であることを確認する
+// 文字列リテラルとコメントを除いた上で、文の種類（先頭のキーワードと、WITH句の後の本体のキーワード）だけを確かめる
+// replace() のような関数や analyze のような別名は文の種類ではないので通し、残りは読み取り専用の接続（SQLiteReadOnlyDriver）が拒否する
+func validateReadOnlySQL(query string) error {
+	stripped := stripSQLLiteralsAndComments(query)
+	stripped = strings.TrimSpace(stripped)
+	stripped = strings.TrimSuffix(stripped, ";")
+
+	if stripped == "" {
+		return errors.New("SQLクエリが空です")
+	}
+	if strings.Contains(stripped, ";") {
+		return errors.New("複数のSQL文は実行できません")
+	}
+
+	keyword := sqlStatementKeyword(stripped)
+	if keyword != "SELECT" {
+		if keyword != "" && keyword != "WITH" {
+			return fmt.Errorf("読み取り専用モードでは %s は使用できません", keyword)
+		}
+		return errors.New("SELECT文（またはWITH句付きのSELECT文）のみ実行できます")
+	}
+	return nil
+}
+
+// sqlStatementKeyword はリテラルとコメントを除いたクエリの文の種類のキーワード（大文字）を返す
+// WITH句で始まる場合は、共通テーブル式の本体（括弧の中）を除いた後の本体の文のキーワードを返す
+func sqlStatementKeyword(stripped string) string {
+	depth := 0
+	afterClose := false
+	first := ""
+	for i := 0; i < len(stripped); {
+		c := stripped[i]
+		switch {
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			afterClose = depth == 0
+			i++
+		case isSQLWordByte(c):
+			j := i
+			for j < len(stripped) && isSQLWordByte(stripped[j]) {
+				j++
+			}
+			word := strings.ToUpper(stripped[i:j])
+			i = j
+			if depth != 0 {
+				continue
+			}
+			if first == "" {
+				first = word
+				if first != "WITH" {
+					return first
+				}
+				continue
+			}
+			// 「名前(列) AS (…)」の AS の後ではなく、共通テーブル式の閉じ括弧に続く語が本体の文
+			if afterClose && word != "AS" {
+				return word
+			}
+			afterClose = false
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			afterClose = afterClose && c != ','
+			i++
+		}
+	}
+	return first
+}
+
+// isSQLWordByte はキーワード・識別子・数値に使えるバイトか（UTF-8 の2バイト目以降を含む）
+func isSQLWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c >= 0x80
+}
+
+// stripSQLLiteralsAndComments は文字列リテラル・引用識別子・コメントを空白に置き換える
+func stripSQLLiteralsAndComments(query string) string {
+	var b strings.Builder
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// 閉じ引用符まで読み飛ばす（'' のエスケープも同じ処理で扱える）
+			for i++; i < len(runes) && runes[i] != c; i++ {
+			}
+			b.WriteRune(' ')
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			b.WriteRune(' ')
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// executeReadOnlySQL はクエリを実行して全ての行を返す
+func executeReadOnlySQL(db *sql.DB, query string) (SQLQueryResult, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return SQLQueryResult{}, fmt.Errorf("クエリの実行に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return SQLQueryResult{}, fmt.Errorf("カラムの取得に失敗: %w", err)
+	}
+
+	result := SQLQueryResult{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return SQLQueryResult{}, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		// []byte はJSONでBase64にならないよう文字列に変換
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return SQLQueryResult{}, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	return result, nil
+}
+
+// formatSQLValue はクエリ結果の値を表示用の文字列に変換する
+func formatSQLValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return sqlNullDisplay
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// writeSQLResultTable は結果を整列したテーブル形式で出力する
+func writeSQLResultTable(w io.Writer, result SQLQueryResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(result.Columns, "\t")); err != nil {
+		return err
+	}
+	separators := make([]string, len(result.Columns))
+	for i, c := range result.Columns {
+		separators[i] = strings.Repeat("-", max(len(c), 3))
+	}
+	if _, err := fmt.Fprintln(tw, strings.Join(separators, "\t")); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			// タブ・改行は列を崩すので空白に置き換える
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(formatSQLValue(v))
+		}
+		if _, err := fmt.Fprintln(tw, strings.Join(cells, "\t")); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "(%d行)\n", len(result.Rows))
+	return err
+}
+
+// writeSQLResultCSV は結果をCSV/TSV形式で出力する
+func writeSQLResultCSV(w io.Writer, result SQLQueryResult, delimiter rune) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+	if err := writer.Write(result.Columns); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			if v == nil {
+				continue // NULLは空欄
+			}
+			record[i] = formatSQLValue(v)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeSQLResultJSON は結果をカラム名をキーとするオブジェクトの配列として出力する
+func writeSQLResultJSON(w io.Writer, result SQLQueryResult) error {
+	records := make([]map[string]interface{}, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		record := make(map[string]interface{}, len(row))
+		for i, v := range row {
+			record[result.Columns[i]] = v
+		}
+		records = append(records, record)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
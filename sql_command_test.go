@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"SELECT", "SELECT * FROM history_items", false},
+		{"小文字", "select url from history_items", false},
+		{"末尾セミコロン", "SELECT 1;", false},
+		{"WITH句", "WITH t AS (SELECT 1 AS n) SELECT n FROM t", false},
+		{"文字列内のキーワード", "SELECT * FROM history_items WHERE url LIKE '%delete%'", false},
+		{"コメント内のキーワード", "SELECT 1 -- DROP TABLE x", false},
+		{"空", "   ", true},
+		{"DELETE", "DELETE FROM history_items", true},
+		{"複数文", "SELECT 1; DROP TABLE history_items", true},
+		{"WITH+DELETE", "WITH t AS (SELECT 1) DELETE FROM history_items", true},
+		{"PRAGMA", "PRAGMA table_info(history_items)", true},
+		{"ATTACH", "ATTACH DATABASE 'other.db' AS other", true},
+		{"replace関数", "SELECT replace(url, 'https://', '') FROM history_items", false},
+		{"キーワードの別名", "SELECT visit_count AS analyze FROM history_items", false},
+		{"WITH句とreplace関数", "WITH t(u) AS (SELECT url FROM history_items) SELECT replace(u, 'https://', '') FROM t", false},
+		{"複数のWITH句", "WITH a AS (SELECT 1 AS n), b AS MATERIALIZED (SELECT n FROM a) SELECT n FROM b", false},
+		{"WITH+REPLACE", "WITH t AS (SELECT 1) REPLACE INTO history_items (url) SELECT 'x' FROM t", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReadOnlySQL(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateReadOnlySQL(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteReadOnlySQL(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	result, err := executeReadOnlySQL(db, "SELECT domain_expansion, visit_count FROM history_items ORDER BY id")
+	if err != nil {
+		t.Fatalf("executeReadOnlySQL失敗: %v", err)
+	}
+	if len(result.Columns) != 2 || result.Columns[0] != "domain_expansion" {
+		t.Errorf("Columns = %v", result.Columns)
+	}
+	if len(result.Rows) != 4 {
+		t.Fatalf("Rows = %d, want 4", len(result.Rows))
+	}
+	if result.Rows[3][0] != nil {
+		t.Errorf("NULLがnilになっていない: %v", result.Rows[3][0])
+	}
+
+	// 関数名が更新系のキーワードと同じでも読み取りのクエリは実行できる
+	replaced, err := executeReadOnlySQL(db, "SELECT replace(url, 'https://', '') AS analyze FROM history_items ORDER BY id LIMIT 1")
+	if err != nil {
+		t.Fatalf("replace() のクエリの実行に失敗: %v", err)
+	}
+	if len(replaced.Rows) != 1 || replaced.Rows[0][0] != "github.com/test" {
+		t.Errorf("replace() の結果 = %v", replaced.Rows)
+	}
+
+	var table bytes.Buffer
+	if err := writeSQLResultTable(&table, result); err != nil {
+		t.Fatalf("writeSQLResultTable失敗: %v", err)
+	}
+	if !strings.Contains(table.String(), "NULL") || !strings.Contains(table.String(), "(4行)") {
+		t.Errorf("テーブル出力が期待と異なる:\n%s", table.String())
+	}
+
+	var csvBuf bytes.Buffer
+	if err := writeSQLResultCSV(&csvBuf, result, ','); err != nil {
+		t.Fatalf("writeSQLResultCSV失敗: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvBuf.String()), "\n")
+	if lines[0] != "domain_expansion,visit_count" || lines[1] != "github,10" || lines[4] != ",5" {
+		t.Errorf("CSV出力が期待と異なる: %q", lines)
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := writeSQLResultJSON(&jsonBuf, result); err != nil {
+		t.Fatalf("writeSQLResultJSON失敗: %v", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &records); err != nil {
+		t.Fatalf("JSONデコード失敗: %v", err)
+	}
+	if len(records) != 4 || records[0]["domain_expansion"] != "github" {
+		t.Errorf("JSON出力が期待と異なる: %v", records)
+	}
+}
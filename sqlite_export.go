@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// sqliteExportSchema は -sqlite で書き出すデータベースのテーブル定義
+// 時刻は "YYYY-MM-DD HH:MM:SS"（集計と同じローカル時刻）で、SQLite の日付関数でそのまま扱える
+// visits.id は Safari の history_visits.id をそのまま使う（hist show -id で参照できる）
+const sqliteExportSchema = `
+CREATE TABLE sessions (
+	id           INTEGER PRIMARY KEY,
+	start_time   TEXT NOT NULL,
+	end_time     TEXT NOT NULL,
+	visit_count  INTEGER NOT NULL,
+	domain_count INTEGER NOT NULL
+);
+CREATE TABLE visits (
+	id         INTEGER PRIMARY KEY,
+	url        TEXT NOT NULL,
+	title      TEXT NOT NULL,
+	domain     TEXT NOT NULL,
+	visit_time TEXT NOT NULL,
+	session_id INTEGER NOT NULL REFERENCES sessions(id),
+	source     TEXT NOT NULL
+);
+CREATE INDEX idx_visits_domain ON visits(domain);
+CREATE INDEX idx_visits_visit_time ON visits(visit_time);
+CREATE TABLE domains (
+	domain      TEXT PRIMARY KEY,
+	visit_count INTEGER NOT NULL,
+	first_visit TEXT NOT NULL,
+	last_visit  TEXT NOT NULL
+);
+CREATE TABLE daily_stats (
+	date         TEXT PRIMARY KEY,
+	visit_count  INTEGER NOT NULL,
+	domain_count INTEGER NOT NULL
+);`
+
+// exportVisit は書き出し用に整えた訪問（domain はURLのホスト名）
+type exportVisit struct {
+	HistoryVisit
+	host string
+}
+
+// exportSQLite はフィルタに一致する全ての訪問を正規化したテーブルとして新しいSQLiteファイルに書き出す
+// サニタイズ・リダクト・匿名化は -json などと同じく適用し、書き出した訪問数を返す
+func exportSQLite(db dbQuerier, path string, config Config) (int, error) {
+	if _, err := os.Stat(path); err == nil {
+		return 0, fmt.Errorf("出力先のファイルが既に存在します: %s", path)
+	}
+
+	sources, err := loadVisitSources(db, config.Source)
+	if err != nil {
+		return 0, err
+	}
+	var visits []exportVisit
+	err = streamRecentVisits(db, 0, config.Filter, func(v HistoryVisit) error {
+		v.Source = sources.sourceOf(v.ID)
+		v, issues := sanitizeVisit(v)
+		if config.Strict && len(issues) > 0 {
+			return issues[0]
+		}
+		host := extractDomain(v.URL)
+		if isRedactedVisit(v, config.RedactDomains) {
+			host = redactedLabel
+		}
+		v = redactVisits([]HistoryVisit{v}, config.RedactDomains)[0]
+		v = anonymizeResult(AnalysisResult{RecentVisits: []HistoryVisit{v}}, config.Anonymize).RecentVisits[0]
+		visits = append(visits, exportVisit{HistoryVisit: v, host: anonymizeDomain(host, config.Anonymize)})
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("訪問履歴の取得に失敗: %w", err)
+	}
+	if len(visits) == 0 {
+		return 0, nil
+	}
+	sort.SliceStable(visits, func(i, j int) bool { return visits[i].VisitTime.Before(visits[j].VisitTime) })
+
+	// .enc は一時ファイルに書き出してから暗号化する
+	encrypted, _ := splitEncryptionExt(path)
+	if !encrypted {
+		return len(visits), writeSQLiteExport(path, visits)
+	}
+	key, err := loadEncryptionKey()
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".hist-export-*.db")
+	if err != nil {
+		return 0, fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := writeSQLiteExport(tmp.Name(), visits); err != nil {
+		return 0, err
+	}
+	if err := encryptFile(tmp.Name(), path, key); err != nil {
+		return 0, err
+	}
+	return len(visits), nil
+}
+
+// writeSQLiteExport は時刻順に並んだ訪問を path のSQLiteファイルに書き出す
+func writeSQLiteExport(path string, visits []exportVisit) error {
+	out, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return fmt.Errorf("出力先のデータベースを開けませんでした: %w", err)
+	}
+	defer func() { _ = out.Close() }()
+
+	tx, err := out.Begin()
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(sqliteExportSchema); err != nil {
+		return fmt.Errorf("テーブルの作成に失敗: %w", err)
+	}
+	if err := writeExportTables(tx, visits); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("書き出しの確定に失敗: %w", err)
+	}
+	return nil
+}
+
+// writeExportTables は時刻順に並んだ訪問から各テーブルの行を作って挿入する
+func writeExportTables(tx *sql.Tx, visits []exportVisit) error {
+	type domainInfo struct {
+		count       int
+		first, last time.Time
+	}
+	type dayInfo struct {
+		count   int
+		domains map[string]bool
+	}
+	type sessionInfo struct {
+		start, end time.Time
+		count      int
+		domains    map[string]bool
+	}
+
+	domains := make(map[string]*domainInfo)
+	days := make(map[string]*dayInfo)
+	var sessions []*sessionInfo
+
+	visitStmt, err := tx.Prepare(`INSERT INTO visits (id, url, title, domain, visit_time, session_id, source) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("訪問の書き出しの準備に失敗: %w", err)
+	}
+	defer func() { _ = visitStmt.Close() }()
+
+	for _, v := range visits {
+		if len(sessions) == 0 || v.VisitTime.Sub(sessions[len(sessions)-1].end) > browsingSessionGap {
+			sessions = append(sessions, &sessionInfo{start: v.VisitTime, domains: make(map[string]bool)})
+		}
+		s := sessions[len(sessions)-1]
+		s.end = v.VisitTime
+		s.count++
+		s.domains[v.host] = true
+
+		d := domains[v.host]
+		if d == nil {
+			d = &domainInfo{first: v.VisitTime}
+			domains[v.host] = d
+		}
+		d.count++
+		d.last = v.VisitTime
+
+		date := v.VisitTime.Format(TimeFormatDate)
+		day := days[date]
+		if day == nil {
+			day = &dayInfo{domains: make(map[string]bool)}
+			days[date] = day
+		}
+		day.count++
+		day.domains[v.host] = true
+
+		if _, err := visitStmt.Exec(v.ID, v.URL, v.Title, v.host, v.VisitTime.Format(TimeFormatFull), len(sessions), v.Source); err != nil {
+			return fmt.Errorf("訪問の書き出しに失敗: %w", err)
+		}
+	}
+
+	for i, s := range sessions {
+		if _, err := tx.Exec(`INSERT INTO sessions (id, start_time, end_time, visit_count, domain_count) VALUES (?, ?, ?, ?, ?)`,
+			i+1, s.start.Format(TimeFormatFull), s.end.Format(TimeFormatFull), s.count, len(s.domains)); err != nil {
+			return fmt.Errorf("セッションの書き出しに失敗: %w", err)
+		}
+	}
+	for domain, d := range domains {
+		if _, err := tx.Exec(`INSERT INTO domains (domain, visit_count, first_visit, last_visit) VALUES (?, ?, ?, ?)`,
+			domain, d.count, d.first.Format(TimeFormatFull), d.last.Format(TimeFormatFull)); err != nil {
+			return fmt.Errorf("ドメインの書き出しに失敗: %w", err)
+		}
+	}
+	for date, day := range days {
+		if _, err := tx.Exec(`INSERT INTO daily_stats (date, visit_count, domain_count) VALUES (?, ?, ?)`,
+			date, day.count, len(day.domains)); err != nil {
+			return fmt.Errorf("日別統計の書き出しに失敗: %w", err)
+		}
+	}
+	return nil
+}
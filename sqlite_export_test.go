@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportSQLite(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	path := filepath.Join(t.TempDir(), "out.db")
+	config := Config{RedactDomains: []string{"youtube.com"}}
+	count, err := exportSQLite(db, path, config)
+	if err != nil {
+		t.Fatalf("exportSQLite失敗: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("exportSQLite() = %d, want 5", count)
+	}
+
+	out, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatalf("書き出したデータベースを開けません: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+
+	counts := map[string]int{
+		"SELECT COUNT(*) FROM visits":                                            5,
+		"SELECT COUNT(*) FROM domains":                                           3,
+		"SELECT COUNT(*) FROM daily_stats":                                       2,
+		"SELECT COUNT(*) FROM sessions":                                          5,
+		"SELECT visit_count FROM domains WHERE domain = 'github.com'":            2,
+		"SELECT visit_count FROM daily_stats WHERE date = '2025-01-01'":          3,
+		"SELECT COUNT(*) FROM visits WHERE url LIKE '%youtube%'":                 0,
+		"SELECT visit_count FROM domains WHERE domain = '" + redactedLabel + "'": 2,
+		"SELECT COUNT(*) FROM visits v JOIN sessions s ON v.session_id = s.id":   5,
+	}
+	for query, want := range counts {
+		var got int
+		if err := out.QueryRow(query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Errorf("%s = %d, want %d", query, got, want)
+		}
+	}
+
+	var first string
+	if err := out.QueryRow("SELECT visit_time FROM visits WHERE id = 1").Scan(&first); err != nil {
+		t.Fatalf("最初の訪問の取得に失敗: %v", err)
+	}
+	if first != "2025-01-01 10:00:00" {
+		t.Errorf("最初の訪問 = %s, want 2025-01-01 10:00:00", first)
+	}
+
+	// 既存のファイルは上書きしない
+	if _, err := exportSQLite(db, path, config); err == nil {
+		t.Error("既存のファイルへの書き出しがエラーになっていない")
+	}
+}
+
+func TestExportSQLiteSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 12:00 の訪問を 11:00 の20分後に移すと、10:00 の訪問とは別、11:00 の訪問とは同じセッションになる
+	if _, err := db.Exec("UPDATE history_visits SET visit_time = ? WHERE id = 3", 757418400+3600+1200); err != nil {
+		t.Fatalf("テストデータの更新に失敗: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.db")
+	if _, err := exportSQLite(db, path, Config{}); err != nil {
+		t.Fatalf("exportSQLite失敗: %v", err)
+	}
+	out, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatalf("書き出したデータベースを開けません: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+
+	var sessions, visits, domains int
+	if err := out.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions); err != nil {
+		t.Fatalf("セッション数の取得に失敗: %v", err)
+	}
+	if sessions != 4 {
+		t.Errorf("セッション数 = %d, want 4", sessions)
+	}
+	if err := out.QueryRow("SELECT visit_count, domain_count FROM sessions WHERE id = 2").Scan(&visits, &domains); err != nil {
+		t.Fatalf("セッションの取得に失敗: %v", err)
+	}
+	if visits != 2 || domains != 2 {
+		t.Errorf("2番目のセッション = %d訪問/%dドメイン, want 2/2", visits, domains)
+	}
+}
+
+func TestExportSQLiteNoMatches(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	path := filepath.Join(t.TempDir(), "out.db")
+	count, err := exportSQLite(db, path, Config{Filter: SearchFilter{Keyword: "存在しないキーワード"}})
+	if err != nil {
+		t.Fatalf("exportSQLite失敗: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("exportSQLite() = %d, want 0", count)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("一致する訪問がないのにファイルが作成された")
+	}
+}
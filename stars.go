@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// StarredPage はスターを付けたページ
+type StarredPage struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title,omitempty"`
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// StarRequest は POST /api/stars のリクエスト
+type StarRequest struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// errNotStarred はスターを付けていないURLを指定した場合のエラー
+var errNotStarred = errors.New("スターを付けていないURLです")
+
+// IsStarred は rawURL にスターを付けたかを返す
+func (s *SidecarDB) IsStarred(rawURL string) (bool, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM stars WHERE url = ?`, rawURL).Scan(&n); err != nil {
+		return false, fmt.Errorf("スターの取得に失敗: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Star は rawURL にスターを付ける（付け直した場合は false を返し、空のタイトルだけを補う）
+func (s *SidecarDB) Star(rawURL, title string, now time.Time) (bool, error) {
+	if err := validAnnotationURL(rawURL); err != nil {
+		return false, err
+	}
+	starred, err := s.IsStarred(rawURL)
+	if err != nil {
+		return false, err
+	}
+	if starred {
+		_, err = s.db.Exec(`UPDATE stars SET title = ? WHERE url = ? AND title = ''`, title, rawURL)
+	} else {
+		_, err = s.db.Exec(`INSERT INTO stars (url, title, starred_at) VALUES (?, ?, ?)`,
+			rawURL, title, now.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return false, fmt.Errorf("スターの保存に失敗: %w", err)
+	}
+	return !starred, nil
+}
+
+// Unstar は rawURL のスターを外す（付けていなければ errNotStarred）
+func (s *SidecarDB) Unstar(rawURL string) error {
+	res, err := s.db.Exec(`DELETE FROM stars WHERE url = ?`, rawURL)
+	if err != nil {
+		return fmt.Errorf("スターの削除に失敗: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotStarred
+	}
+	return nil
+}
+
+// Starred はスターを付けたページを新しい順に返す
+func (s *SidecarDB) Starred() ([]StarredPage, error) {
+	pages := []StarredPage{}
+	if s == nil {
+		return pages, nil
+	}
+	rows, err := s.db.Query(`SELECT url, title, starred_at FROM stars ORDER BY starred_at DESC, url`)
+	if err != nil {
+		return nil, fmt.Errorf("スターの取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var p StarredPage
+		var starredAt string
+		if err := rows.Scan(&p.URL, &p.Title, &starredAt); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		p.StarredAt, _ = time.Parse(time.RFC3339, starredAt)
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// loadStarred はサイドカーDBからスターを付けたページを読み込む（DBがまだなければ空）
+func loadStarred(path string) ([]StarredPage, error) {
+	sidecar, err := openExistingSidecarDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if sidecar != nil {
+		defer func() { _ = sidecar.Close() }()
+	}
+	return sidecar.Starred()
+}
+
+// loadStarredMatch は -starred で絞り込む、スターを付けたURLの一覧を返す
+func loadStarredMatch(path string) (*MatchList, error) {
+	pages, err := loadStarred(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &MatchList{}
+	for _, p := range pages {
+		m.URLs = append(m.URLs, p.URL)
+	}
+	return m, nil
+}
+
+// starPage は rawURL にスターを付ける（title が空なら履歴の最後の訪問のタイトルを使う）
+// db が nil でも付けられる
+func starPage(db dbQuerier, rawURL, title string) (bool, error) {
+	if err := validAnnotationURL(rawURL); err != nil {
+		return false, err
+	}
+	if title == "" && db != nil {
+		if v, ok, err := latestVisit(db, rawURL); err == nil && ok {
+			title = v.Title
+		}
+	}
+	path, err := getSidecarPath()
+	if err != nil {
+		return false, err
+	}
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = sidecar.Close() }()
+	return sidecar.Star(rawURL, title, time.Now())
+}
+
+// toggleStar は rawURL のスターを付け外しし、付けた後なら true を返す
+func toggleStar(rawURL, title string) (bool, error) {
+	if err := validAnnotationURL(rawURL); err != nil {
+		return false, err
+	}
+	path, err := getSidecarPath()
+	if err != nil {
+		return false, err
+	}
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = sidecar.Close() }()
+	starred, err := sidecar.IsStarred(rawURL)
+	if err != nil {
+		return false, err
+	}
+	if starred {
+		return false, sidecar.Unstar(rawURL)
+	}
+	_, err = sidecar.Star(rawURL, title, time.Now())
+	return err == nil, err
+}
+
+// printStarred はスターを付けたページを出力する
+func printStarred(w io.Writer, pages []StarredPage) {
+	if len(pages) == 0 {
+		_, _ = fmt.Fprintln(w, "スターを付けたページはありません")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "⭐ スター (%d件)\n", len(pages))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, p := range pages {
+		title := p.Title
+		if title == "" {
+			title = "(タイトルなし)"
+		}
+		_, _ = fmt.Fprintf(w, "  %s  %s\n", p.StarredAt.Local().Format(TimeFormatDate), truncateText(title, MaxTitleLength))
+		_, _ = fmt.Fprintf(w, "              %s\n", p.URL)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// runStarCommand は hist star サブコマンドを実行する
+func runStarCommand(args []string) error {
+	fs := flag.NewFlagSet("star", flag.ContinueOnError)
+	title := fs.String("title", "", "タイトル（省略時は履歴の最後の訪問のタイトル）")
+	remove := fs.Bool("remove", false, "スターを外す")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist star [オプション] <URL>\n\n")
+		fmt.Fprintf(fs.Output(), "  ページにスターを付けます（一覧は hist starred、レポートの絞り込みは -starred）\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("URLを1つ指定してください")
+	}
+	rawURL := fs.Arg(0)
+
+	if *remove {
+		path, err := getSidecarPath()
+		if err != nil {
+			return err
+		}
+		sidecar, err := openSidecarDB(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = sidecar.Close() }()
+		if err := sidecar.Unstar(rawURL); err != nil {
+			return err
+		}
+		fmt.Printf("スターを外しました: %s\n", rawURL)
+		return nil
+	}
+
+	// 履歴DBを開けなくても、タイトルなしで付けられる
+	var db dbQuerier
+	if conn, err := setupDatabase(); err == nil {
+		defer func() { _ = conn.Close() }()
+		db = conn
+	}
+	added, err := starPage(db, rawURL, *title)
+	if err != nil {
+		return err
+	}
+	if added {
+		fmt.Printf("スターを付けました: %s\n", rawURL)
+	} else {
+		fmt.Printf("すでにスターを付けています: %s\n", rawURL)
+	}
+	return nil
+}
+
+// runStarredCommand は hist starred サブコマンドを実行する
+func runStarredCommand(args []string) error {
+	fs := flag.NewFlagSet("starred", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist starred [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  スターを付けたページを新しい順に表示します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+	pages, err := loadStarred(path)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(pages)
+	}
+	printStarred(os.Stdout, pages)
+	return nil
+}
+
+// handleAPIStars はスターの API
+//
+//	GET    /api/stars          スターを付けたページ（新しい順）
+//	POST   /api/stars          {"url": ..., "title": ...} でスターを付ける
+//	DELETE /api/stars?url=...  スターを外す
+//
+// 書き換えは Content-Type: application/json のリクエストだけを受け付ける（他のサイトのフォームから送らせないため）
+func (s *WebServer) handleAPIStars(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		pages, err := loadStarred(s.sidecarPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// リダクト対象のページは一覧に出さない
+		redact := s.redactList()
+		visible := make([]StarredPage, 0, len(pages))
+		for _, p := range pages {
+			if !domainMatchesList(extractDomain(p.URL), redact) {
+				visible = append(visible, p)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(visible); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			http.Error(w, "Content-Type は application/json にしてください", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req StarRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, WebMaxRequestBody)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("リクエストの解析に失敗: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validAnnotationURL(req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.writeStarResult(w, s.updateStars(func(sidecar *SidecarDB) error {
+			_, err := sidecar.Star(req.URL, req.Title, time.Now())
+			return err
+		}))
+
+	case http.MethodDelete:
+		s.writeStarResult(w, s.updateStars(func(sidecar *SidecarDB) error {
+			return sidecar.Unstar(r.URL.Query().Get("url"))
+		}))
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "許可されていないメソッドです", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateStars はサイドカーDBを開いて fn で書き換える
+func (s *WebServer) updateStars(fn func(*SidecarDB) error) error {
+	sidecar, err := openSidecarDB(s.sidecarPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sidecar.Close() }()
+	return fn(sidecar)
+}
+
+// writeStarResult はスターを書き換えた結果を返す（成功なら 204）
+func (s *WebServer) writeStarResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNotStarred):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSidecarStars(t *testing.T) {
+	sidecar, err := openSidecarDB(filepath.Join(t.TempDir(), "hist.db"))
+	if err != nil {
+		t.Fatalf("openSidecarDB() error = %v", err)
+	}
+	defer func() { _ = sidecar.Close() }()
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	if added, err := sidecar.Star("https://example.com/a", "", now); err != nil || !added {
+		t.Errorf("Star() = %v, %v", added, err)
+	}
+	if _, err := sidecar.Star("https://example.com/b", "B", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// 付け直すと空のタイトルだけを補う
+	if added, err := sidecar.Star("https://example.com/a", "A", now); err != nil || added {
+		t.Errorf("付け直しの Star() = %v, %v", added, err)
+	}
+	if _, err := sidecar.Star(redactedLabel, "", now); err == nil {
+		t.Error("リダクトしたURLにスターを付けられる")
+	}
+
+	pages, err := sidecar.Starred()
+	if err != nil {
+		t.Fatalf("Starred() error = %v", err)
+	}
+	if len(pages) != 2 || pages[0].URL != "https://example.com/b" || pages[1].Title != "A" || !pages[1].StarredAt.Equal(now) {
+		t.Errorf("Starred() = %+v", pages)
+	}
+
+	if err := sidecar.Unstar("https://example.com/a"); err != nil {
+		t.Errorf("Unstar() error = %v", err)
+	}
+	if err := sidecar.Unstar("https://example.com/a"); !errors.Is(err, errNotStarred) {
+		t.Errorf("2回目の Unstar() error = %v", err)
+	}
+	if starred, err := sidecar.IsStarred("https://example.com/b"); err != nil || !starred {
+		t.Errorf("IsStarred() = %v, %v", starred, err)
+	}
+}
+
+func TestStarredFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	path := filepath.Join(t.TempDir(), "hist.db")
+	// スターがなければ何も一致しない（サイドカーDBがなくてもよい）
+	empty, err := loadStarredMatch(path)
+	if err != nil {
+		t.Fatalf("loadStarredMatch() error = %v", err)
+	}
+	if visits, err := getRecentVisits(db, 10, SearchFilter{Starred: empty}); err != nil || len(visits) != 0 {
+		t.Errorf("スターなしで %d件, %v", len(visits), err)
+	}
+
+	sidecar, err := openSidecarDB(path)
+	if err != nil {
+		t.Fatalf("openSidecarDB() error = %v", err)
+	}
+	if _, err := sidecar.Star("https://github.com/test", "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	_ = sidecar.Close()
+
+	match, err := loadStarredMatch(path)
+	if err != nil {
+		t.Fatalf("loadStarredMatch() error = %v", err)
+	}
+	filter := SearchFilter{Starred: match}
+	visits, err := getRecentVisits(db, 10, filter)
+	if err != nil {
+		t.Fatalf("getRecentVisits失敗: %v", err)
+	}
+	if len(visits) != 2 {
+		t.Errorf("スターで絞り込んだ訪問 = %d件, want 2", len(visits))
+	}
+	stats, err := getDomainStats(db, 10, filter)
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Domain != "github.com" {
+		t.Errorf("スターで絞り込んだドメイン統計 = %+v", stats)
+	}
+	if !summarizeFilter(filter).Starred {
+		t.Error("summarizeFilter().Starred = false")
+	}
+}
+
+func TestHandleAPIStars(t *testing.T) {
+	s := &WebServer{sidecarPath: filepath.Join(t.TempDir(), "hist.db"), redactDomains: []string{"secret.example"}}
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		s.handleAPIStars(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"付ける", "POST", "/api/stars", "application/json", `{"url": "https://example.com/a", "title": "A"}`, http.StatusNoContent},
+		{"付ける", "POST", "/api/stars", "application/json", `{"url": "https://secret.example/b"}`, http.StatusNoContent},
+		{"付ける", "POST", "/api/stars", "application/json", `{"url": "https://example.com/c"}`, http.StatusNoContent},
+		{"フォームからは送れない", "POST", "/api/stars", "application/x-www-form-urlencoded", `url=https://example.com/d`, http.StatusUnsupportedMediaType},
+		{"URLなし", "POST", "/api/stars", "application/json", `{}`, http.StatusBadRequest},
+		{"外す", "DELETE", "/api/stars?url=https://example.com/c", "", "", http.StatusNoContent},
+		{"付けていない", "DELETE", "/api/stars?url=https://example.com/c", "", "", http.StatusNotFound},
+		{"PUT は不可", "PUT", "/api/stars", "application/json", `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := send(tt.method, tt.target, tt.contentType, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: ステータス = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	// リダクト対象のページは一覧に出さない
+	body := send("GET", "/api/stars", "", "").Body.String()
+	if !strings.Contains(body, `"url":"https://example.com/a"`) || strings.Contains(body, "secret.example") || strings.Contains(body, "example.com/c") {
+		t.Errorf("GET /api/stars = %s", body)
+	}
+}
+
+func TestInteractiveToggleStar(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := newInteractiveModel(nil)
+	m.visits = []HistoryVisit{{ID: 1, URL: "https://example.com/a", Title: "A"}}
+
+	press := func(m tea.Model) tea.Model {
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'*'}})
+		if cmd == nil {
+			t.Fatal("* キーでコマンドが返らない")
+		}
+		updated, _ := m.Update(cmd())
+		return updated
+	}
+	updated := press(m)
+	if status := updated.(interactiveModel).status; status != "スターを付けました" {
+		t.Errorf("status = %q", status)
+	}
+	path, err := getSidecarPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := loadStarred(path)
+	if err != nil || len(pages) != 1 || pages[0].Title != "A" {
+		t.Errorf("loadStarred() = %+v, %v", pages, err)
+	}
+
+	if status := press(updated).(interactiveModel).status; status != "スターを外しました" {
+		t.Errorf("2回目の status = %q", status)
+	}
+}
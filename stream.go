@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errStopStream はコールバックから返すとストリーミングを正常終了する
+var errStopStream = errors.New("stop stream")
+
+// streamHistoryQuery は履歴クエリを実行し、1行ごとにコールバックを呼ぶ
+// 結果をスライスに溜めないので、全履歴のエクスポートでもメモリ使用量が一定になる
+// コールバックが errStopStream を返した場合はエラーなしで終了する
+func streamHistoryQuery(db dbQuerier, query string, args []interface{}, fn func(HistoryVisit) error) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("履歴の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var v HistoryVisit
+		var visitTime float64
+		if err := rows.Scan(&v.ID, &v.URL, &v.Title, &v.Domain, &visitTime); err != nil {
+			return fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		v.VisitTime = convertCoreDataTimestamp(visitTime)
+		// domain_expansionが空の場合、URLからドメインを抽出
+		if v.Domain == "" {
+			v.Domain = extractDomain(v.URL)
+		}
+		if err := fn(v); err != nil {
+			if errors.Is(err, errStopStream) {
+				return nil
+			}
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+	return nil
+}
+
+// streamRecentVisits は新しい順に訪問履歴を1件ずつコールバックに渡す
+// limit が0以下の場合は全件を対象にする
+func streamRecentVisits(db dbQuerier, limit int, filter SearchFilter, fn func(HistoryVisit) error) error {
+	// イグノアリストはGo側でも判定するため、SQLでは件数を絞らずに必要な件数で打ち切る
+	sqlLimit := limit
+	if limit <= 0 || len(filter.IgnoreDomains) > 0 {
+		sqlLimit = -1 // SQLiteでは負のLIMITは無制限
+	}
+
+	query, args := buildRecentVisitsQuery(sqlLimit, filter)
+	count := 0
+	return streamHistoryQuery(db, query, args, func(v HistoryVisit) error {
+		if shouldIgnoreDomain(v.Domain, filter.IgnoreDomains) {
+			return nil
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+		count++
+		if limit > 0 && count >= limit {
+			return errStopStream
+		}
+		return nil
+	})
+}
+
+// writeNDJSON は訪問履歴を1行1件のJSON（NDJSON）として逐次出力する
+// サニタイズ・リダクト・匿名化も1件ずつ適用し、出力した件数を返す
+func writeNDJSON(w io.Writer, db dbQuerier, config Config) (int, error) {
+	sources, err := loadVisitSources(db, config.Source)
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	count := 0
+	err = streamRecentVisits(db, config.Limit, config.Filter, func(v HistoryVisit) error {
+		v.Source = sources.sourceOf(v.ID)
+		v, issues := sanitizeVisit(v)
+		if config.Strict && len(issues) > 0 {
+			return issues[0]
+		}
+		visit := redactVisits([]HistoryVisit{v}, config.RedactDomains)[0]
+		visit = anonymizeResult(AnalysisResult{RecentVisits: []HistoryVisit{visit}}, config.Anonymize).RecentVisits[0]
+		count++
+		return encoder.Encode(visit)
+	})
+	return count, err
+}
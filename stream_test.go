@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStreamRecentVisits(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tests := []struct {
+		name   string
+		limit  int
+		filter SearchFilter
+		want   int
+	}{
+		{"全件", 0, SearchFilter{}, 5},
+		{"件数制限", 2, SearchFilter{}, 2},
+		{"イグノアリスト+件数制限", 2, SearchFilter{IgnoreDomains: []string{"youtube"}}, 2},
+		{"イグノアリスト全件", 0, SearchFilter{IgnoreDomains: []string{"youtube"}}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []HistoryVisit
+			err := streamRecentVisits(db, tt.limit, tt.filter, func(v HistoryVisit) error {
+				got = append(got, v)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("streamRecentVisits失敗: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("streamRecentVisits() returned %d items, want %d", len(got), tt.want)
+			}
+			for _, v := range got {
+				if shouldIgnoreDomain(v.Domain, tt.filter.IgnoreDomains) {
+					t.Errorf("除外ドメインが含まれている: %s", v.Domain)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	var buf bytes.Buffer
+	config := Config{Limit: 0, RedactDomains: []string{"youtube.com"}}
+	if _, err := writeNDJSON(&buf, db, config); err != nil {
+		t.Fatalf("writeNDJSON失敗: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("NDJSON行数 = %d, want 5", len(lines))
+	}
+	var first HistoryVisit
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("JSONデコード失敗: %v", err)
+	}
+	// 最新の訪問はYouTube（リダクト対象）
+	if first.URL != redactedLabel {
+		t.Errorf("リダクトが適用されていない: %+v", first)
+	}
+}
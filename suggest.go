@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	// SuggestLimit は /api/suggest が既定で返す候補の数
+	SuggestLimit = 8
+	// SuggestMaxLimit は ?limit= で指定できる候補の最大数
+	SuggestMaxLimit = 50
+	// suggestLookback は候補を探す期間（入力のたびに全ての履歴を集計しないため）
+	suggestLookback = 365 * 24 * time.Hour
+)
+
+// Suggestion は検索欄・ランチャー向けの候補
+type Suggestion struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	LastVisit time.Time `json:"last_visit"`
+}
+
+// getSuggestions は keyword に一致するページを frecency の高い順に最大 limit 件返す
+// ブラウザで開けないURLとリダクト対象のページは候補にしない
+func getSuggestions(db dbQuerier, keyword string, limit int, filter SearchFilter, redactDomains []string, now time.Time) ([]Suggestion, error) {
+	filter.Keyword = keyword
+	if from := now.Add(-suggestLookback); filter.From.Before(from) {
+		filter.From = from
+	}
+	visits, err := getFrecencyVisits(db, 0, filter, "", now)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := []Suggestion{}
+	for _, v := range sanitizeVisits(visits) {
+		if !isOpenableURL(v.URL) || isRedactedVisit(v, redactDomains) {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{URL: v.URL, Title: v.Title, LastVisit: v.VisitTime})
+		if len(suggestions) >= limit {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// handleAPISuggest は GET /api/suggest?q=... で検索欄の入力に一致するページの候補を返す
+// 候補は過去1年の訪問から frecency（訪問の多さと新しさ）の高い順に選ぶ
+// ?limit= で候補の数（既定 SuggestLimit、最大 SuggestMaxLimit）を、他のフィルタのパラメータで範囲を指定できる
+func (s *WebServer) handleAPISuggest(w http.ResponseWriter, r *http.Request) {
+	limit := queryInt(r, "limit", SuggestLimit, SuggestMaxLimit)
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	suggestions := []Suggestion{}
+	if q := r.URL.Query().Get("q"); q != "" {
+		suggestions, err = getSuggestions(s.db, q, limit, filter, s.redactList(), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSuggestions(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	insertFrecencyTestData(t, db)
+	now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	// frecency の高い順に、リダクト対象（example.com）を除いて limit 件
+	suggestions, err := getSuggestions(db, "com", 2, SearchFilter{}, []string{"example.com"}, now)
+	if err != nil {
+		t.Fatalf("getSuggestions失敗: %v", err)
+	}
+	if len(suggestions) != 2 || suggestions[0].URL != "https://youtube.com/watch" || suggestions[0].Title != "YouTube - Music" || suggestions[1].URL != "https://github.com/test" {
+		t.Errorf("getSuggestions() = %+v", suggestions)
+	}
+
+	// 1年より前の訪問は候補にしない
+	suggestions, err = getSuggestions(db, "com", 10, SearchFilter{}, nil, now.AddDate(2, 0, 0))
+	if err != nil || len(suggestions) != 0 {
+		t.Errorf("1年より前の訪問の候補 = %+v, %v", suggestions, err)
+	}
+}
+
+func TestHandleAPISuggest(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	recent := convertToTimestamp(time.Now().Add(-time.Hour))
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://go.dev/doc/', 'go', 2),
+		(2, 'https://go.dev/blog/', 'go', 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, ?, 'Documentation'), (2, 1, ?, 'Documentation'), (3, 2, ?, 'Blog');
+	`, recent, recent-60, recent-120)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	s := &WebServer{db: newPreparedDB(db)}
+	defer func() { _ = s.db.Close() }()
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		s.handleAPISuggest(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != 200 {
+			t.Fatalf("%s のステータス = %d: %s", target, rec.Code, rec.Body.String())
+		}
+		return strings.TrimSpace(rec.Body.String())
+	}
+
+	body := get("/api/suggest?q=go.dev")
+	if !strings.HasPrefix(body, `[{"url":"https://go.dev/doc/","title":"Documentation"`) || !strings.Contains(body, "go.dev/blog") {
+		t.Errorf("GET /api/suggest?q=go.dev = %s", body)
+	}
+	if body := get("/api/suggest?q=go.dev&limit=1"); strings.Contains(body, "go.dev/blog") {
+		t.Errorf("limit=1 = %s", body)
+	}
+	if body := get("/api/suggest"); body != "[]" {
+		t.Errorf("q なし = %s", body)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// summaryTopDomains は /api/summary で返す上位ドメインの数
+const summaryTopDomains = 3
+
+// summaryCacheTTL は /api/summary の集計を使い回す時間
+// メニューバーのプラグインが短い間隔でポーリングしても履歴DBを毎回集計しないようにする
+const summaryCacheTTL = 30 * time.Second
+
+// Summary は GET /api/summary のレスポンス（SwiftBar・xbar のプラグイン向けの今日の要約）
+type Summary struct {
+	Date       string        `json:"date"`
+	Visits     int           `json:"visits"`
+	TopDomains []DomainStats `json:"top_domains"`
+	// FocusScore は今日の訪問のうち気が散るドメイン以外の割合（0〜100）
+	// 気が散るドメインを設定していないか、今日の訪問がなければ省略する
+	FocusScore *int `json:"focus_score,omitempty"`
+	// DistractionVisits は今日の気が散るドメインへの訪問数
+	DistractionVisits int `json:"distraction_visits"`
+	// Interruptions は今日の集中時間帯の中断回数（hist focus と同じ数え方）
+	Interruptions int       `json:"interruptions"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+// getSummary は now の日（loc のローカル日付）の訪問数・上位ドメイン・集中スコアを集計する
+func getSummary(db dbQuerier, now time.Time, filter SearchFilter, redactDomains, distractions []string, windows []FocusWindow, holidays *Holidays, loc *time.Location) (Summary, error) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	summary := Summary{Date: today.Format(TimeFormatDate), GeneratedAt: now}
+
+	filter.From, filter.To = today, today
+	count, err := getFilteredVisitCount(db, filter)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary.Visits = count
+	domains, err := getVisitDomainStats(db, summaryTopDomains, filter)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary.TopDomains = redactDomainStats(domains, redactDomains)
+	if summary.TopDomains == nil {
+		summary.TopDomains = []DomainStats{}
+	}
+
+	if len(distractions) == 0 {
+		return summary, nil
+	}
+	report, err := getFocusReport(db, windows, distractions, today, today, loc, holidays)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary.DistractionVisits = report.Inside + report.Outside
+	summary.Interruptions = report.Interruptions
+	if count > 0 {
+		// 気が散るドメインの訪問はイグノアリストで除外しないため、訪問数を超えた場合は0にする
+		score := max(0, int(math.Round(100*float64(count-summary.DistractionVisits)/float64(count))))
+		summary.FocusScore = &score
+	}
+	return summary, nil
+}
+
+// handleAPISummary は今日の訪問数・上位3ドメイン・集中スコアをJSONで返す
+// 絞り込みを指定しないリクエストは summaryCacheTTL の間同じ集計を返す
+func (s *WebServer) handleAPISummary(w http.ResponseWriter, r *http.Request) {
+	cacheable := r.URL.RawQuery == ""
+	now := time.Now()
+	if cacheable {
+		s.summaryMu.Lock()
+		defer s.summaryMu.Unlock()
+	}
+
+	summary := s.summaryCache
+	if !cacheable || summary == nil || now.Sub(summary.GeneratedAt) >= summaryCacheTTL {
+		filter, err := s.requestFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		distractions, err := LoadDistractionList()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		windows, err := LoadFocusWindows()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		computed, err := getSummary(s.db, now, filter, s.redactList(), distractions, windows, s.holidayList(), time.Local)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("要約の集計に失敗: %v", err), http.StatusInternalServerError)
+			return
+		}
+		summary = &computed
+		if cacheable {
+			s.summaryCache = summary
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(summaryCacheTTL.Seconds())))
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
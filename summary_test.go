@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSummary(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	now := time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC)
+
+	summary, err := getSummary(db, now, SearchFilter{}, nil, nil, nil, nil, time.UTC)
+	if err != nil {
+		t.Fatalf("getSummary: %v", err)
+	}
+	if summary.Date != "2025-01-01" || summary.Visits != 3 || len(summary.TopDomains) != 3 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if summary.FocusScore != nil {
+		t.Errorf("気が散るドメインがないのに集中スコア = %d", *summary.FocusScore)
+	}
+
+	window, err := parseFocusWindow("10:30-12:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err = getSummary(db, now, SearchFilter{}, []string{"google.com"}, []string{"youtube.com"}, []FocusWindow{window}, nil, time.UTC)
+	if err != nil {
+		t.Fatalf("getSummary: %v", err)
+	}
+	if summary.FocusScore == nil || *summary.FocusScore != 67 || summary.DistractionVisits != 1 || summary.Interruptions != 1 {
+		t.Errorf("集中スコア = %+v", summary)
+	}
+	for _, d := range summary.TopDomains {
+		if strings.Contains(d.Domain, "google") {
+			t.Errorf("リダクト対象のドメインが出力された: %+v", summary.TopDomains)
+		}
+	}
+
+	// 今日の訪問がなければ集中スコアは省略する
+	summary, err = getSummary(db, now.AddDate(0, 0, 7), SearchFilter{}, nil, []string{"youtube.com"}, nil, nil, time.UTC)
+	if err != nil {
+		t.Fatalf("getSummary: %v", err)
+	}
+	if summary.Visits != 0 || summary.FocusScore != nil || summary.TopDomains == nil {
+		t.Errorf("訪問のない日の summary = %+v", summary)
+	}
+	data, _ := json.Marshal(summary)
+	if bytes.Contains(data, []byte("focus_score")) || !bytes.Contains(data, []byte(`"top_domains":[]`)) {
+		t.Errorf("JSON = %s", data)
+	}
+}
+
+func TestHandleAPISummary(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.MkdirAll(filepath.Join(dir, "hist"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hist", distractionFileName), []byte("github.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := setupTestDB(t)
+	recent := convertToTimestamp(time.Now())
+	if _, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES (1, 'https://github.com/', 'github', 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (1, 1, ?, 'GitHub');
+	`, recent); err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db)}
+	defer func() { _ = s.db.Close() }()
+
+	get := func(target string) Summary {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.handleAPISummary(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != 200 {
+			t.Fatalf("%s のステータス = %d: %s", target, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "max-age=30" {
+			t.Errorf("Cache-Control = %q", got)
+		}
+		var summary Summary
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("JSON の解析に失敗: %v", err)
+		}
+		return summary
+	}
+
+	first := get("/api/summary")
+	if first.Visits != 1 || first.FocusScore == nil || *first.FocusScore != 0 || first.DistractionVisits != 1 {
+		t.Errorf("GET /api/summary = %+v", first)
+	}
+	// 絞り込みなしのリクエストは集計を使い回す
+	if second := get("/api/summary"); !second.GeneratedAt.Equal(first.GeneratedAt) {
+		t.Errorf("キャッシュが使われていない: %v != %v", second.GeneratedAt, first.GeneratedAt)
+	}
+	if filtered := get("/api/summary?ignore=github"); filtered.Visits != 0 || len(filtered.TopDomains) != 0 {
+		t.Errorf("GET /api/summary?ignore=github = %+v", filtered)
+	}
+}
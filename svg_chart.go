@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// サーバー側で描くSVGグラフ
+// Web UI のページと -output report.html の両方で使い、JavaScript なしで表示・印刷できるようにする
+// 文字は currentColor、目盛りや棒の背景は theme.css の変数（なければライトテーマの色）で塗るため、ダークテーマにも従う
+
+const (
+	// svgChartWidth はグラフの viewBox の幅（表示幅は親要素に合わせて伸縮する）
+	svgChartWidth = 640
+	// svgBarHeight は横棒グラフの1本の高さ
+	svgBarHeight = 22
+	// svgLabelWidth は横棒グラフのラベルの幅
+	svgLabelWidth = 150
+	// svgValueWidth は横棒グラフの値の幅
+	svgValueWidth = 110
+	// svgPlotHeight は縦棒・折れ線グラフの描画領域の高さ
+	svgPlotHeight = 160
+	// svgHeatmapCell はヒートマップの1マスの大きさ
+	svgHeatmapCell = 22
+)
+
+// グラフの色（Tailwind の 500 番台と同じ）
+const (
+	chartColorBlue   = "#3b82f6"
+	chartColorGreen  = "#22c55e"
+	chartColorPurple = "#a855f7"
+	chartColorRed    = "#ef4444"
+)
+
+// svgTrackStyle は棒の背景と目盛り線の色
+const svgTrackStyle = `fill:var(--hist-track,#e5e7eb)`
+
+// heatmapWeekdays はヒートマップの行の見出し（time.Weekday の順）
+var heatmapWeekdays = [...]string{"日", "月", "火", "水", "木", "金", "土"}
+
+// chartBar はグラフの1本の棒（折れ線では1点）
+type chartBar struct {
+	Label string
+	Value float64
+	// ValueLabel は値の表示（空なら Value を表示する）
+	ValueLabel string
+	// Title はマウスを重ねたときの説明（空なら「ラベル: 値」）
+	Title string
+	// Highlight は強調する棒（休日など）
+	Highlight bool
+}
+
+// valueLabel は棒の値の表示
+func (b chartBar) valueLabel() string {
+	if b.ValueLabel != "" {
+		return b.ValueLabel
+	}
+	return formatChartValue(b.Value)
+}
+
+// title は棒の説明
+func (b chartBar) title() string {
+	if b.Title != "" {
+		return b.Title
+	}
+	return b.Label + ": " + b.valueLabel()
+}
+
+// formatChartValue は整数ならそのまま、小数なら小数点以下1桁で表示する
+func formatChartValue(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// maxChartValue は棒の最大値を返す
+func maxChartValue(bars []chartBar) float64 {
+	maxValue := 0.0
+	for _, b := range bars {
+		maxValue = max(maxValue, b.Value)
+	}
+	return maxValue
+}
+
+// svgOpen は viewBox が width×height の SVG の開始タグを書き出す
+func svgOpen(sb *strings.Builder, width, height int, label string) {
+	fmt.Fprintf(sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img" aria-label="%s" font-family="sans-serif" font-size="12" fill="currentColor">`,
+		width, height, html.EscapeString(label))
+}
+
+// svgEmpty はデータがない場合の表示
+func svgEmpty(label string) template.HTML {
+	var sb strings.Builder
+	svgOpen(&sb, svgChartWidth, 40, label)
+	sb.WriteString(`<text x="0" y="24" opacity="0.6">データがありません</text></svg>`)
+	return template.HTML(sb.String())
+}
+
+// svgBarChart は横棒グラフを描く（ラベル・棒・値を1行ずつ並べる）
+func svgBarChart(label string, bars []chartBar, color string) template.HTML {
+	if len(bars) == 0 {
+		return svgEmpty(label)
+	}
+	var sb strings.Builder
+	svgOpen(&sb, svgChartWidth, len(bars)*svgBarHeight, label)
+	maxValue := maxChartValue(bars)
+	trackWidth := svgChartWidth - svgLabelWidth - svgValueWidth
+	for i, b := range bars {
+		y := i * svgBarHeight
+		width := 0.0
+		if maxValue > 0 {
+			width = b.Value / maxValue * float64(trackWidth)
+		}
+		fill := color
+		if b.Highlight {
+			fill = chartColorRed
+		}
+		fmt.Fprintf(&sb, `<g><title>%s</title>`, html.EscapeString(b.title()))
+		fmt.Fprintf(&sb, `<text x="0" y="%d">%s</text>`, y+15, html.EscapeString(truncateText(b.Label, 20)))
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="7" style="%s"/>`, svgLabelWidth, y+4, trackWidth, svgBarHeight-8, svgTrackStyle)
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%.1f" height="%d" rx="7" fill="%s"/>`, svgLabelWidth, y+4, width, svgBarHeight-8, fill)
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="end">%s</text></g>`, svgChartWidth, y+15, html.EscapeString(b.valueLabel()))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// svgColumnChart は縦棒グラフを描く（ラベルは labelEvery 本ごとに表示する）
+func svgColumnChart(label string, bars []chartBar, color string, labelEvery int) template.HTML {
+	if len(bars) == 0 {
+		return svgEmpty(label)
+	}
+	var sb strings.Builder
+	svgOpen(&sb, svgChartWidth, svgPlotHeight+24, label)
+	maxValue := maxChartValue(bars)
+	slot := float64(svgChartWidth) / float64(len(bars))
+	fmt.Fprintf(&sb, `<rect x="0" y="%d" width="%d" height="1" style="%s"/>`, svgPlotHeight, svgChartWidth, svgTrackStyle)
+	for i, b := range bars {
+		x := float64(i) * slot
+		height := 0.0
+		if maxValue > 0 {
+			height = b.Value / maxValue * float64(svgPlotHeight-4)
+		}
+		fill := color
+		if b.Highlight {
+			fill = chartColorRed
+		}
+		fmt.Fprintf(&sb, `<g><title>%s</title>`, html.EscapeString(b.title()))
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="2" fill="%s"/>`, x+slot*0.15, float64(svgPlotHeight)-height, slot*0.7, height, fill)
+		if labelEvery > 0 && i%labelEvery == 0 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%d" text-anchor="middle" opacity="0.7">%s</text>`, x+slot/2, svgPlotHeight+16, html.EscapeString(b.Label))
+		}
+		sb.WriteString(`</g>`)
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// chartSeries は折れ線グラフに重ねる点のない補助線（移動平均など）
+type chartSeries struct {
+	Label  string
+	Values []float64
+	Color  string
+}
+
+// svgLineChart は折れ線グラフを描く（点は左から順に並べ、ラベルは最初・中央・最後に表示する）
+// series は points と同じ順の値を破線で重ね、グラフの下に凡例を付ける
+func svgLineChart(label string, points []chartBar, color string, series ...chartSeries) template.HTML {
+	if len(points) == 0 {
+		return svgEmpty(label)
+	}
+	var sb strings.Builder
+	height := svgPlotHeight + 24
+	if len(series) > 0 {
+		height += 20
+	}
+	svgOpen(&sb, svgChartWidth, height, label)
+	maxValue := maxChartValue(points)
+	for _, s := range series {
+		for _, v := range s.Values {
+			maxValue = max(maxValue, v)
+		}
+	}
+	const padding = 8
+	x := func(i int) float64 {
+		if len(points) == 1 {
+			return svgChartWidth / 2
+		}
+		return padding + float64(i)*float64(svgChartWidth-2*padding)/float64(len(points)-1)
+	}
+	y := func(v float64) float64 {
+		if maxValue == 0 {
+			return svgPlotHeight
+		}
+		return float64(svgPlotHeight) - v/maxValue*float64(svgPlotHeight-padding)
+	}
+
+	fmt.Fprintf(&sb, `<rect x="0" y="%d" width="%d" height="1" style="%s"/>`, svgPlotHeight, svgChartWidth, svgTrackStyle)
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(p.Value))
+	}
+	fmt.Fprintf(&sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2" stroke-linejoin="round"/>`, strings.Join(coords, " "), color)
+	for _, s := range series {
+		coords := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			coords[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(v))
+		}
+		fmt.Fprintf(&sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-dasharray="4 3" stroke-linejoin="round"><title>%s</title></polyline>`,
+			strings.Join(coords, " "), s.Color, html.EscapeString(s.Label))
+	}
+	for i, p := range points {
+		fill := color
+		if p.Highlight {
+			fill = chartColorRed
+		}
+		fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="3.5" fill="%s"><title>%s</title></circle>`, x(i), y(p.Value), fill, html.EscapeString(p.title()))
+	}
+	for _, i := range []int{0, len(points) / 2, len(points) - 1} {
+		anchor := "middle"
+		switch i {
+		case 0:
+			anchor = "start"
+		case len(points) - 1:
+			anchor = "end"
+		}
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d" text-anchor="%s" opacity="0.7">%s</text>`, x(i), svgPlotHeight+16, anchor, html.EscapeString(points[i].Label))
+	}
+	for i, s := range series {
+		left := i * 120
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="16" height="2" fill="%s"/>`, left, svgPlotHeight+34, s.Color)
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" opacity="0.7">%s</text>`, left+22, svgPlotHeight+39, html.EscapeString(s.Label))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// svgHeatmap は曜日×時間帯のヒートマップを描く（月曜始まり、濃いほど訪問が多い）
+func svgHeatmap(label string, counts [7][24]int) template.HTML {
+	const left, top = 24, 16
+	var sb strings.Builder
+	svgOpen(&sb, left+24*svgHeatmapCell, top+7*svgHeatmapCell, label)
+	maxCount := 0
+	for _, hours := range counts {
+		for _, n := range hours {
+			maxCount = max(maxCount, n)
+		}
+	}
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&sb, `<text x="%d" y="11" text-anchor="middle" opacity="0.7" font-size="10">%d</text>`, left+hour*svgHeatmapCell+svgHeatmapCell/2, hour)
+	}
+	for row, day := range []int{1, 2, 3, 4, 5, 6, 0} {
+		y := top + row*svgHeatmapCell
+		fmt.Fprintf(&sb, `<text x="0" y="%d" opacity="0.7">%s</text>`, y+15, heatmapWeekdays[day])
+		for hour, n := range counts[day] {
+			style := svgTrackStyle
+			if n > 0 && maxCount > 0 {
+				style = fmt.Sprintf("fill:%s;fill-opacity:%.2f", chartColorBlue, 0.15+0.85*float64(n)/float64(maxCount))
+			}
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="3" style="%s"><title>%s %d時: %d</title></rect>`,
+				left+hour*svgHeatmapCell+1, y+1, svgHeatmapCell-2, svgHeatmapCell-2, style, weekdayLabel(time.Weekday(day)), hour, n)
+		}
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// hourlyChart は時間帯別訪問数の縦棒グラフ
+func hourlyChart(stats []HourlyStats) template.HTML {
+	bars := make([]chartBar, len(stats))
+	for i, s := range stats {
+		bars[i] = chartBar{Label: fmt.Sprintf("%d", s.Hour), Value: float64(s.VisitCount), Title: fmt.Sprintf("%02d:00 %d件", s.Hour, s.VisitCount)}
+	}
+	return svgColumnChart("時間帯別訪問数", bars, chartColorGreen, 3)
+}
+
+// dailyChart は日別訪問数の折れ線グラフ（古い日から順に並べ、休日は赤い点にする）
+// 7日・30日の移動平均を破線で重ねる
+func dailyChart(stats []DailyStats) template.HTML {
+	points := make([]chartBar, len(stats))
+	avg7 := chartSeries{Label: fmt.Sprintf("%d日平均", RollingShortDays), Values: make([]float64, len(stats)), Color: chartColorGreen}
+	avg30 := chartSeries{Label: fmt.Sprintf("%d日平均", RollingLongDays), Values: make([]float64, len(stats)), Color: chartColorPurple}
+	for i, s := range stats {
+		p := chartBar{Label: s.Date, Value: float64(s.VisitCount), Highlight: s.Holiday != ""}
+		p.Title = fmt.Sprintf("%s: %d（%s）", s.Date, s.VisitCount, formatRollingAverages(s))
+		if s.Holiday != "" {
+			p.Title = fmt.Sprintf("%s（%s）: %d（%s）", s.Date, s.Holiday, s.VisitCount, formatRollingAverages(s))
+		}
+		// getDailyStats は新しい順
+		points[len(stats)-1-i] = p
+		avg7.Values[len(stats)-1-i] = s.Avg7
+		avg30.Values[len(stats)-1-i] = s.Avg30
+	}
+	return svgLineChart("日別訪問数", points, chartColorBlue, avg7, avg30)
+}
+
+// domainChart はドメイン別訪問数の横棒グラフ（値に割合を添える）
+func domainChart(stats []DomainStats) template.HTML {
+	bars := make([]chartBar, len(stats))
+	for i, s := range stats {
+		bars[i] = chartBar{
+			Label:      s.Domain,
+			Value:      float64(s.VisitCount),
+			ValueLabel: fmt.Sprintf("%d (%.1f%%)", s.VisitCount, s.Percentage),
+			Title:      fmt.Sprintf("%s: %d件、%.1f%%（累積 %.1f%%）", s.Domain, s.VisitCount, s.Percentage, s.CumulativePercentage),
+		}
+	}
+	return svgBarChart("ドメイン別訪問数", bars, chartColorPurple)
+}
+
+// heatmapChart は曜日×時間帯の訪問数のヒートマップ
+func heatmapChart(heatmap DashboardHeatmap) template.HTML {
+	return svgHeatmap("曜日・時間帯別訪問数", heatmap.Counts)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/xml"
+	"html/template"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// parseSVG は SVG が整形式の XML かを確かめ、要素名ごとの数を返す
+func parseSVG(t *testing.T, svg template.HTML) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	decoder := xml.NewDecoder(strings.NewReader(string(svg)))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return counts
+		}
+		if err != nil {
+			t.Fatalf("SVG が整形式でない: %v\n%s", err, svg)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+		}
+	}
+}
+
+func TestSVGCharts(t *testing.T) {
+	// ラベルはエスケープする
+	bars := parseSVG(t, svgBarChart("テスト", []chartBar{{Label: "<script>&", Value: 3}, {Label: "b", Value: 1.5}}, chartColorBlue))
+	if bars["rect"] != 4 || bars["title"] != 2 {
+		t.Errorf("横棒グラフの要素 = %v", bars)
+	}
+
+	hourly := make([]HourlyStats, 24)
+	for i := range hourly {
+		hourly[i] = HourlyStats{Hour: i, VisitCount: i % 5}
+	}
+	if columns := parseSVG(t, hourlyChart(hourly)); columns["rect"] != 25 || columns["text"] != 8 {
+		t.Errorf("縦棒グラフの要素 = %v", columns)
+	}
+
+	daily := []DailyStats{{Date: "2025-01-02", VisitCount: 2}, {Date: "2025-01-01", VisitCount: 3, Holiday: "元日"}}
+	svg := dailyChart(daily)
+	// 日別訪問数に7日・30日の移動平均の線を重ねる
+	if lines := parseSVG(t, svg); lines["polyline"] != 3 || lines["circle"] != 2 {
+		t.Errorf("折れ線グラフの要素 = %v", lines)
+	}
+	// 古い日から順に描き、休日は強調する
+	if strings.Index(string(svg), "2025-01-01（元日）") > strings.Index(string(svg), "2025-01-02: 2") {
+		t.Error("日別訪問数が古い順に並んでいない")
+	}
+	if !strings.Contains(string(svg), chartColorRed) {
+		t.Error("休日が強調されていない")
+	}
+
+	var heatmap DashboardHeatmap
+	heatmap.Counts[1][9] = 4
+	if cells := parseSVG(t, heatmapChart(heatmap)); cells["rect"] != 7*24 {
+		t.Errorf("ヒートマップのマス = %d, want %d", cells["rect"], 7*24)
+	}
+
+	// データがなければ「データがありません」
+	if svg := domainChart(nil); !strings.Contains(string(svg), "データがありません") {
+		t.Errorf("空のグラフ = %s", svg)
+	}
+}
+
+func TestStatsPageCharts(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl}
+	defer func() { _ = s.db.Close() }()
+
+	rec := httptest.NewRecorder()
+	s.handleStatsPage(rec, httptest.NewRequest("GET", "/stats?days=36500", nil))
+	body := rec.Body.String()
+	for _, label := range []string{"時間帯別訪問数", "日別訪問数", "ドメイン別訪問数", "曜日・時間帯別訪問数"} {
+		if !strings.Contains(body, `aria-label="`+label+`"`) {
+			t.Errorf("%s のグラフがない", label)
+		}
+	}
+}
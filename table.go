@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// renderTable は表示幅（全角文字は2桁）を考慮して列を揃えた表を返す
+// rightAligned に含まれる列（0始まり）は右揃えにする
+func renderTable(headers []string, rows [][]string, rightAligned []int, border bool) string {
+	t := table.New().
+		Headers(headers...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			for _, c := range rightAligned {
+				if c == col {
+					return style.Align(lipgloss.Right)
+				}
+			}
+			return style
+		})
+
+	if border {
+		t = t.Border(lipgloss.NormalBorder())
+	} else {
+		t = t.Border(lipgloss.HiddenBorder()).
+			BorderTop(false).
+			BorderBottom(false).
+			BorderLeft(false).
+			BorderRight(false).
+			BorderHeader(false)
+	}
+
+	// 枠なしの場合は行末に空白が残るので取り除く
+	lines := strings.Split(t.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// padRight は表示幅が width になるまで右側を空白で埋める
+// fmt の %-20s は表示幅ではなくルーン数で揃えるため、全角文字を含むと列がずれる
+func padRight(s string, width int) string {
+	if w := lipgloss.Width(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// tableSection は表形式・Markdown 形式で統計の1節（見出しと表）を書き出す
+// rightAligned に含まれる列（0始まり）は右揃えにする
+type tableSection func(title string, headers []string, rows [][]string, rightAligned ...int)
+
+// printTableOutput は結果を表形式で出力する
+func printTableOutput(w io.Writer, result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily, border bool) {
+	_, _ = fmt.Fprintf(w, "\n📊 Safari 履歴分析結果（総訪問数: %d）\n\n", result.TotalVisits)
+	writeTableSections(result, showHistory, showDomains, showPaths, showHourly, showDaily, func(title string, headers []string, rows [][]string, rightAligned ...int) {
+		_, _ = fmt.Fprintf(w, "%s\n", title)
+		_, _ = fmt.Fprintln(w, renderTable(headers, rows, rightAligned, border))
+		_, _ = fmt.Fprintln(w)
+	})
+}
+
+// writeTableSections は表示する統計を1節ずつ表にして section に渡す
+func writeTableSections(result AnalysisResult, showHistory, showDomains, showPaths, showHourly, showDaily bool, section tableSection) {
+	if showHistory && len(result.RecentVisits) > 0 {
+		var rows [][]string
+		for _, v := range result.RecentVisits {
+			title := v.Title
+			if title == "" {
+				title = "(タイトルなし)"
+			}
+			rows = append(rows, []string{v.VisitTime.Format(TimeFormatDateTime), truncateText(title, TitleTruncateLength), v.Domain})
+		}
+		section("📝 最近の訪問履歴", []string{"日時", "タイトル", "ドメイン"}, rows)
+	}
+
+	if showDomains && len(result.DomainStats) > 0 {
+		var rows [][]string
+		for i, s := range result.DomainStats {
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", i+1),
+				s.Domain,
+				fmt.Sprintf("%d", s.VisitCount),
+				fmt.Sprintf("%.1f%%", s.Percentage),
+				fmt.Sprintf("%.1f%%", s.CumulativePercentage),
+			})
+		}
+		section(fmt.Sprintf("🌐 ドメイン別訪問数 (Top %d)", len(result.DomainStats)),
+			[]string{"#", "ドメイン", "訪問数", "割合", "累積"}, rows, 0, 2, 3, 4)
+	}
+
+	if showPaths && len(result.DomainPathStats) > 0 {
+		var rows [][]string
+		for _, ds := range result.DomainPathStats {
+			for _, p := range ds.Paths {
+				rows = append(rows, []string{ds.Domain, truncateText(p.Path, TitleTruncateLength), truncateText(p.Title, TitleTruncateLength), fmt.Sprintf("%d", p.VisitCount)})
+			}
+			if ds.OtherCount > 0 {
+				rows = append(rows, []string{ds.Domain, "その他", "", fmt.Sprintf("%d", ds.OtherCount)})
+			}
+		}
+		section(fmt.Sprintf("🗂  ドメイン・パス別訪問数 (Top %d)", len(result.DomainPathStats)),
+			[]string{"ドメイン", "パス", "タイトル", "訪問数"}, rows, 3)
+	}
+
+	if showHourly && len(result.HourlyStats) > 0 {
+		var rows [][]string
+		for _, s := range result.HourlyStats {
+			rows = append(rows, []string{fmt.Sprintf("%02d:00", s.Hour), fmt.Sprintf("%d", s.VisitCount)})
+		}
+		section("⏰ 時間帯別訪問数", []string{"時刻", "訪問数"}, rows, 1)
+	}
+
+	if showHourly && result.HourlySplit != nil {
+		var rows [][]string
+		for i, wd := range result.HourlySplit.Weekday {
+			we := result.HourlySplit.Weekend[i]
+			rows = append(rows, []string{fmt.Sprintf("%02d:00", wd.Hour), fmt.Sprintf("%.1f", wd.Average), fmt.Sprintf("%.1f", we.Average)})
+		}
+		section(result.HourlySplit.weekpartTitle(),
+			[]string{"時刻", "平日", "週末"}, rows, 1, 2)
+	}
+
+	if len(result.TitleClusters) > 0 {
+		var rows [][]string
+		for _, c := range result.TitleClusters {
+			rows = append(rows, []string{truncateText(c.Title, TitleTruncateLength), fmt.Sprintf("%d", c.VisitCount), fmt.Sprintf("%d", len(c.URLs))})
+		}
+		section(fmt.Sprintf("📰 タイトル別訪問数 (Top %d)", len(result.TitleClusters)), []string{"タイトル", "訪問数", "URL数"}, rows, 1, 2)
+	}
+
+	if result.Videos != nil && len(result.Videos.Videos) > 0 {
+		var rows [][]string
+		for _, v := range result.Videos.Videos {
+			rows = append(rows, []string{v.Platform, truncateText(v.Title, TitleTruncateLength), fmt.Sprintf("%d", v.VisitCount), fmt.Sprintf("%d", v.Sessions)})
+		}
+		section(fmt.Sprintf("🎬 動画の視聴 (Top %d)", len(result.Videos.Videos)), []string{"サービス", "タイトル", "訪問数", "セッション"}, rows, 2, 3)
+	}
+
+	if result.GitHub != nil && len(result.GitHub.Repos) > 0 {
+		headers := []string{"リポジトリ", "訪問数"}
+		for _, kind := range githubKindOrder {
+			headers = append(headers, githubKindLabel(kind))
+		}
+		var rows [][]string
+		for _, r := range result.GitHub.Repos {
+			row := []string{r.Repo, fmt.Sprintf("%d", r.VisitCount)}
+			for _, kind := range githubKindOrder {
+				row = append(row, fmt.Sprintf("%d", r.Kinds[kind]))
+			}
+			rows = append(rows, row)
+		}
+		section(fmt.Sprintf("🐙 GitHub リポジトリ (Top %d)", len(result.GitHub.Repos)), headers, rows, 1, 2, 3, 4, 5, 6)
+	}
+
+	if result.Docs != nil && len(result.Docs.Lookups) > 0 {
+		var rows [][]string
+		for _, l := range result.Docs.Lookups {
+			rows = append(rows, []string{l.Site, l.Path, truncateText(l.Title, TitleTruncateLength), fmt.Sprintf("%d", l.VisitCount)})
+		}
+		section(fmt.Sprintf("📚 調べたドキュメント (Top %d)", len(result.Docs.Lookups)), []string{"サイト", "パス", "タイトル", "訪問数"}, rows, 3)
+	}
+
+	if len(result.LocalHosts) > 0 {
+		var rows [][]string
+		for _, s := range result.LocalHosts {
+			rows = append(rows, []string{s.Addr(), "", fmt.Sprintf("%d", s.VisitCount)})
+			for _, p := range s.Paths {
+				rows = append(rows, []string{"", truncateText(p.Path, MaxTitleLength), fmt.Sprintf("%d", p.VisitCount)})
+			}
+		}
+		section(fmt.Sprintf("🏠 ローカル・社内ホスト (Top %d)", len(result.LocalHosts)), []string{"ホスト", "パス", "訪問数"}, rows, 2)
+	}
+
+	if len(result.HourlyProfiles) > 0 {
+		var rows [][]string
+		for _, p := range result.HourlyProfiles {
+			rows = append(rows, []string{p.Domain, sparkline(p.Hours), fmt.Sprintf("%02d:00", p.PeakHour), fmt.Sprintf("%.1f%%", p.PeakShare), fmt.Sprintf("%d", p.VisitCount)})
+		}
+		section(hourlyProfilesTitle(result.HourlyProfiles), []string{"ドメイン", "0時〜23時", "ピーク", "割合", "訪問数"}, rows, 3, 4)
+	}
+
+	if len(result.CategoryProfiles) > 0 {
+		var rows [][]string
+		for _, p := range result.CategoryProfiles {
+			rows = append(rows, []string{p.Category, sparkline(p.Hours), fmt.Sprintf("%02d:00", p.PeakHour), fmt.Sprintf("%.1f%%", p.PeakShare), fmt.Sprintf("%d", p.VisitCount)})
+		}
+		section(hourlyProfilesTitle(result.CategoryProfiles), []string{"カテゴリ", "0時〜23時", "ピーク", "割合", "訪問数"}, rows, 3, 4)
+	}
+
+	if len(result.TitleLanguages) > 0 {
+		var rows [][]string
+		for _, l := range result.TitleLanguages {
+			rows = append(rows, []string{l.Label, l.Lang, fmt.Sprintf("%d", l.VisitCount), fmt.Sprintf("%.1f%%", l.Percentage)})
+		}
+		section("🔤 タイトルの言語別訪問数", []string{"言語", "コード", "訪問数", "割合"}, rows, 2, 3)
+	}
+
+	if showDaily && len(result.DailyStats) > 0 {
+		var rows [][]string
+		for _, s := range result.DailyStats {
+			rows = append(rows, []string{s.Date, fmt.Sprintf("%d", s.VisitCount), fmt.Sprintf("%.1f", s.Avg7), fmt.Sprintf("%.1f", s.Avg30), fmt.Sprintf("%.1f", s.ActiveHours), fmt.Sprintf("%.1f", s.OfflineHours), s.Holiday})
+		}
+		section(fmt.Sprintf("📅 日別訪問数 (過去%d日間)", len(result.DailyStats)), []string{"日付", "訪問数", "7日平均", "30日平均", "活動時間", "オフライン", "休日"}, rows, 1, 2, 3, 4, 5)
+	}
+
+	if f := result.Forecast; f != nil {
+		var rows [][]string
+		for _, d := range f.Daily {
+			rows = append(rows, []string{forecastDateLabel(d.Date), fmt.Sprintf("%.1f", d.Visits)})
+		}
+		rows = append(rows, []string{"合計", fmt.Sprintf("%.1f", f.Total)})
+		section(forecastTitle(*f), []string{"日付", "予測"}, rows, 1)
+		if len(f.Domains) > 0 {
+			rows = nil
+			for _, d := range f.Domains {
+				rows = append(rows, []string{d.Domain, fmt.Sprintf("%d", d.LastWeek), fmt.Sprintf("%.1f", d.Projected), forecastChange(d.LastWeek, d.Projected)})
+			}
+			section("🔮 ドメイン別の訪問数の予測", []string{"ドメイン", fmt.Sprintf("直近%d日間", ForecastDays), "予測", "増減"}, rows, 1, 2, 3)
+		}
+	}
+
+	if report := result.Changepoints; report != nil {
+		var rows, domainRows [][]string
+		for _, cp := range report.Changepoints {
+			rows = append(rows, []string{cp.Date, cp.kindsLabel(), fmt.Sprintf("%.1f", cp.BeforeAvg), fmt.Sprintf("%.1f", cp.AfterAvg), cp.volumeChange(), fmt.Sprintf("%.0f%%", cp.MixDistance*100)})
+			for _, d := range cp.Domains {
+				domainRows = append(domainRows, []string{cp.Date, d.Domain, fmt.Sprintf("%.1f%%", d.BeforeShare*100), fmt.Sprintf("%.1f%%", d.AfterShare*100)})
+			}
+		}
+		section(changepointTitle(*report), []string{"日付", "変化", "前の1日平均", "後の1日平均", "増減", "構成の変化"}, rows, 2, 3, 4, 5)
+		if len(domainRows) > 0 {
+			section("🔀 変化点の前後の上位ドメイン", []string{"日付", "ドメイン", "前", "後"}, domainRows, 2, 3)
+		}
+	}
+
+	if report := result.Joined; report != nil {
+		var rows [][]string
+		for _, c := range report.Correlations {
+			rows = append(rows, []string{c.Metric, c.targetLabel(), fmt.Sprintf("%d", c.Days), fmt.Sprintf("%+.2f", c.Pearson), fmt.Sprintf("%+.2f", c.Spearman), correlationStrength(c.Pearson)})
+		}
+		section(joinReportTitle(*report), []string{"指標", "訪問数", "日数", "ピアソン", "スピアマン", "強さ"}, rows, 2, 3, 4)
+	}
+
+	if report := result.ScreenTime; report != nil {
+		var rows [][]string
+		for _, d := range report.Days {
+			rows = append(rows, []string{forecastDateLabel(d.Date), formatScreenMinutes(d.ScreenMinutes), formatScreenMinutes(d.SafariMinutes), fmt.Sprintf("%d", d.Visits), visitsPerHour(d.Visits, d.ScreenMinutes)})
+		}
+		if len(rows) > 0 {
+			rows = append(rows, []string{"合計", formatScreenMinutes(report.TotalScreenMinutes), formatScreenMinutes(report.TotalSafariMinutes), fmt.Sprintf("%d", report.TotalVisits), visitsPerHour(report.TotalVisits, report.TotalScreenMinutes)})
+		}
+		section(screenTimeTitle(*report), []string{"日付", "スクリーンタイム", "Safari", "訪問数", "1時間あたり"}, rows, 1, 2, 3, 4)
+		if len(report.Correlations) > 0 {
+			rows = nil
+			for _, c := range report.Correlations {
+				rows = append(rows, []string{screenTimeMetricLabel(c.Metric), fmt.Sprintf("%d", c.Days), fmt.Sprintf("%+.2f", c.Pearson), fmt.Sprintf("%+.2f", c.Spearman), correlationStrength(c.Pearson)})
+			}
+			section("📱 訪問数とスクリーンタイムの相関", []string{"指標", "日数", "ピアソン", "スピアマン", "強さ"}, rows, 1, 2, 3)
+		}
+	}
+
+	for _, report := range []*PeriodReport{result.WeeklyStats, result.MonthlyStats} {
+		if report == nil {
+			continue
+		}
+		var rows [][]string
+		for _, p := range report.Periods {
+			rows = append(rows, []string{p.periodLabel(report.Unit), report.calendar().fiscalYearLabel(p.FiscalYear), fmt.Sprintf("%d", p.VisitCount)})
+		}
+		section(report.periodTitle(), []string{"期間", "年度", "訪問数"}, rows, 2)
+	}
+
+	if dist := result.Distribution; dist != nil {
+		section(distributionTitle(*dist), []string{"指標", "値"}, [][]string{
+			{"平均", fmt.Sprintf("%.1f", dist.Mean)},
+			{"中央値(p50)", fmt.Sprintf("%.1f", dist.P50)},
+			{"p90", fmt.Sprintf("%.1f", dist.P90)},
+			{"最大", fmt.Sprintf("%d", dist.Max)},
+			{"最小", fmt.Sprintf("%d", dist.Min)},
+			{"最も多い日", formatDistributionDays(dist.Busiest)},
+			{"最も少ない日", formatDistributionDays(dist.Quietest)},
+		})
+		var rows [][]string
+		for _, b := range dist.Histogram {
+			rows = append(rows, []string{b.label(), fmt.Sprintf("%d", b.Days)})
+		}
+		section("📊 訪問数ごとの日数", []string{"訪問数", "日数"}, rows, 1)
+	}
+
+	if result.GroupStats != nil && !result.GroupStats.empty() {
+		headers, rows, rightAligned := groupTableRows(*result.GroupStats)
+		section(groupReportTitle(*result.GroupStats), headers, rows, rightAligned...)
+	}
+}
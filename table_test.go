@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPadRight は表示幅を考慮した右パディングのテスト
+func TestPadRight(t *testing.T) {
+	tests := []struct {
+		input string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"日本", 5, "日本 "},
+		{"toolongvalue", 5, "toolongvalue"},
+	}
+
+	for _, tt := range tests {
+		if got := padRight(tt.input, tt.width); got != tt.want {
+			t.Errorf("padRight(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.want)
+		}
+	}
+}
+
+// TestRenderTable は表の列揃えのテスト
+func TestRenderTable(t *testing.T) {
+	headers := []string{"ドメイン", "訪問数"}
+	rows := [][]string{{"github.com", "12"}, {"日本語.example.jp", "3"}}
+
+	t.Run("罫線なし", func(t *testing.T) {
+		got := renderTable(headers, rows, []int{1}, false)
+		want := strings.Join([]string{
+			" ドメイン            訪問数",
+			" github.com              12",
+			" 日本語.example.jp        3",
+		}, "\n")
+		if got != want {
+			t.Errorf("renderTable() =\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("罫線あり", func(t *testing.T) {
+		got := renderTable(headers, rows, []int{1}, true)
+		want := strings.Join([]string{
+			"┌───────────────────┬────────┐",
+			"│ ドメイン          │ 訪問数 │",
+			"├───────────────────┼────────┤",
+			"│ github.com        │     12 │",
+			"│ 日本語.example.jp │      3 │",
+			"└───────────────────┴────────┘",
+		}, "\n")
+		if got != want {
+			t.Errorf("renderTable() =\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
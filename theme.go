@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Web UI のテーマ（?theme= の値）
+const (
+	// ThemeAuto は OS の設定（prefers-color-scheme）に従う
+	ThemeAuto  = "auto"
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+const (
+	// themeCookieName は ?theme= で選んだテーマを覚えておく Cookie
+	themeCookieName = "hist_theme"
+	// themeCookieMaxAge はテーマの Cookie の有効期間
+	themeCookieMaxAge = 365 * 24 * time.Hour
+)
+
+// themes は ?theme= に指定できるテーマ
+var themes = []string{ThemeAuto, ThemeLight, ThemeDark}
+
+// PageTheme はページの表示に使うテーマ
+type PageTheme struct {
+	// Name は ThemeAuto・ThemeLight・ThemeDark のいずれか（<html data-theme> の値）
+	Name string
+	// query はテーマを切り替えるリンクに引き継ぐ、ページのクエリパラメータ
+	query url.Values
+}
+
+// Link は今のページをテーマ theme で表示するURL（クエリ部分）を返す（テンプレートから呼ぶ）
+func (t PageTheme) Link(theme string) string {
+	query := url.Values{}
+	for key, values := range t.query {
+		query[key] = values
+	}
+	query.Set("theme", theme)
+	return "?" + query.Encode()
+}
+
+// requestTheme はページの表示に使うテーマを返す
+// ?theme= があればそのテーマを Cookie に保存し（auto なら Cookie を消す）、なければ Cookie のテーマを使う
+// レスポンスヘッダーを書き換えるため、ページを書き出す前に呼ぶ
+func requestTheme(w http.ResponseWriter, r *http.Request) PageTheme {
+	query := r.URL.Query()
+	page := PageTheme{Name: ThemeAuto, query: query}
+	if theme := query.Get("theme"); slices.Contains(themes, theme) {
+		cookie := &http.Cookie{
+			Name:     themeCookieName,
+			Value:    theme,
+			Path:     "/",
+			MaxAge:   int(themeCookieMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}
+		if theme == ThemeAuto {
+			cookie.Value, cookie.MaxAge = "", -1
+		}
+		http.SetCookie(w, cookie)
+		page.Name = theme
+		return page
+	}
+	if c, err := r.Cookie(themeCookieName); err == nil && slices.Contains(themes, c.Value) {
+		page.Name = c.Value
+	}
+	return page
+}
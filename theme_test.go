@@ -0,0 +1,77 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestTheme(t *testing.T) {
+	tests := []struct {
+		url        string
+		cookie     string
+		want       string
+		wantCookie string
+	}{
+		{"/", "", ThemeAuto, ""},
+		{"/", ThemeDark, ThemeDark, ""},
+		{"/", "sepia", ThemeAuto, ""},
+		{"/?theme=dark", "", ThemeDark, "hist_theme=dark"},
+		{"/?theme=light", ThemeDark, ThemeLight, "hist_theme=light"},
+		{"/?theme=auto", ThemeDark, ThemeAuto, "hist_theme=; Path=/; Max-Age=0"},
+		{"/?theme=sepia", ThemeLight, ThemeLight, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: themeCookieName, Value: tt.cookie})
+		}
+		rec := httptest.NewRecorder()
+		got := requestTheme(rec, req)
+		if got.Name != tt.want {
+			t.Errorf("%s (Cookie %q): テーマ = %q, want %q", tt.url, tt.cookie, got.Name, tt.want)
+		}
+		if set := rec.Header().Get("Set-Cookie"); !strings.HasPrefix(set, tt.wantCookie) || (tt.wantCookie == "") != (set == "") {
+			t.Errorf("%s: Set-Cookie = %q, want %q", tt.url, set, tt.wantCookie)
+		}
+	}
+
+	// 切り替えのリンクはページのクエリパラメータを引き継ぐ
+	theme := requestTheme(httptest.NewRecorder(), httptest.NewRequest("GET", "/domain?d=github.com&theme=light", nil))
+	if got := theme.Link(ThemeDark); got != "?d=github.com&theme=dark" {
+		t.Errorf("Link() = %q", got)
+	}
+}
+
+func TestPagesUseTheme(t *testing.T) {
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{templates: tmpl}
+
+	req := httptest.NewRequest("GET", "/?no_ignore=1", nil)
+	req.AddCookie(&http.Cookie{Name: themeCookieName, Value: ThemeDark})
+	rec := httptest.NewRecorder()
+	s.handleDashboard(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, `data-theme="dark"`) || !strings.Contains(body, `href="/static/theme.css"`) {
+		t.Error("ダッシュボードにテーマが反映されていない")
+	}
+	if !strings.Contains(body, `href="?no_ignore=1&amp;theme=light"`) {
+		t.Error("テーマの切り替えリンクがクエリパラメータを引き継いでいない")
+	}
+
+	// 埋め込んだテーマのCSSを /static/ で配信できる
+	static, err := staticHandler()
+	if err != nil {
+		t.Fatalf("staticHandler() error = %v", err)
+	}
+	rec = httptest.NewRecorder()
+	static.ServeHTTP(rec, httptest.NewRequest("GET", "/static/theme.css", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "prefers-color-scheme: dark") {
+		t.Errorf("/static/theme.css = %d", rec.Code)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTimeMachineWindow は hist at で前後を遡る既定の時間
+	defaultTimeMachineWindow = time.Hour
+	// defaultTimeMachineLimit は hist at で時刻の前後それぞれに表示する訪問の既定の件数
+	defaultTimeMachineLimit = 20
+	// timeMachineDomainLimit は hist at で表示する主なドメインの数
+	timeMachineDomainLimit = 5
+)
+
+// timeMachineLayouts は hist at で受け付ける時刻の書式（日付を省略すると今日）
+var timeMachineLayouts = []string{TimeFormatFull, TimeFormatDateTime, "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// TimeMachineReport は指定した時刻の前後の閲覧状況
+type TimeMachineReport struct {
+	At   time.Time `json:"at"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// VisitCount は From〜To の訪問数（Before・After は時刻に近い訪問だけ）
+	VisitCount int `json:"visit_count"`
+	// Before は At より前の訪問、After は At 以降の訪問（どちらも古い順）
+	Before []HistoryVisit `json:"before"`
+	After  []HistoryVisit `json:"after"`
+	// Session は At の時点で続いていたセッション（なければ nil）
+	Session *BrowsingSession `json:"session,omitempty"`
+	// Domains は From〜To の訪問の多いドメイン
+	Domains []DomainStats `json:"domains"`
+}
+
+// parseTimeMachineTime は hist at の時刻を now のタイムゾーンで解釈する
+// "15:04" のように時刻だけなら now の日付にする
+func parseTimeMachineTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeMachineLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("15:04", s, now.Location()); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("不正な時刻: %s（\"2024-12-01 15:00\" または \"15:00\"）", s)
+}
+
+// getTimeMachineReport は at の前後 window の訪問から、その時刻に何をしていたかをまとめる
+// Before・After には at に近い訪問を limit 件ずつ入れる（0以下なら全件）
+// セッションは前後 window の訪問だけで区切るため、window より長く続いたセッションは途中で切れる
+func getTimeMachineReport(db dbQuerier, at time.Time, window time.Duration, limit int, redactDomains []string) (TimeMachineReport, error) {
+	report := TimeMachineReport{At: at, From: at.Add(-window), To: at.Add(window)}
+
+	// To は日単位で丸められるので、範囲外の訪問はここで除く
+	var visits []HistoryVisit
+	err := streamRecentVisits(db, 0, SearchFilter{From: report.From, To: report.To}, func(v HistoryVisit) error {
+		if !v.VisitTime.After(report.To) {
+			visits = append(visits, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("訪問の取得に失敗: %w", err)
+	}
+	// 新しい順に届くので古い順に並べ替える
+	for i, j := 0, len(visits)-1; i < j; i, j = i+1, j-1 {
+		visits[i], visits[j] = visits[j], visits[i]
+	}
+	visits = sanitizeVisits(visits)
+	report.VisitCount = len(visits)
+
+	report.Session = activeBrowsingSession(splitBrowsingSessions(visits), at)
+	if report.Session != nil {
+		report.Session.Domains = redactDomainStats(report.Session.Domains, redactDomains)
+	}
+	report.Domains = countVisitDomains(visits)
+	if len(report.Domains) > timeMachineDomainLimit {
+		report.Domains = report.Domains[:timeMachineDomainLimit]
+	}
+	report.Domains = redactDomainStats(report.Domains, redactDomains)
+
+	split := len(visits)
+	for i, v := range visits {
+		if !v.VisitTime.Before(at) {
+			split = i
+			break
+		}
+	}
+	before, after := visits[:split], visits[split:]
+	if limit > 0 {
+		before = before[max(0, len(before)-limit):]
+		after = after[:min(len(after), limit)]
+	}
+	report.Before = redactVisits(append([]HistoryVisit{}, before...), redactDomains)
+	report.After = redactVisits(append([]HistoryVisit{}, after...), redactDomains)
+	return report, nil
+}
+
+// activeBrowsingSession は at の時点で続いていたセッションを返す
+// at を含むセッションか、at との間が browsingSessionGap 以内の最も近いセッション（なければ nil）
+func activeBrowsingSession(sessions []BrowsingSession, at time.Time) *BrowsingSession {
+	var active *BrowsingSession
+	best := browsingSessionGap + 1
+	for i, s := range sessions {
+		var gap time.Duration
+		switch {
+		case at.Before(s.Start):
+			gap = s.Start.Sub(at)
+		case at.After(s.End):
+			gap = at.Sub(s.End)
+		}
+		if gap < best {
+			active, best = &sessions[i], gap
+		}
+	}
+	return active
+}
+
+// printTimeMachineReport は hist at の結果を出力する
+func printTimeMachineReport(w io.Writer, report TimeMachineReport, window time.Duration) {
+	_, _ = fmt.Fprintf(w, "\n🕰  %s の前後 %s\n", report.At.Format(TimeFormatDateTime), window)
+	_, _ = fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if report.VisitCount == 0 {
+		_, _ = fmt.Fprintf(w, "%s 〜 %s の訪問はありません\n\n", report.From.Format(TimeFormatDateTime), report.To.Format(TimeFormatDateTime))
+		return
+	}
+	_, _ = fmt.Fprintf(w, "訪問数:     %d（%s 〜 %s）\n", report.VisitCount, report.From.Format(TimeFormatShort), report.To.Format(TimeFormatShort))
+	if s := report.Session; s != nil {
+		_, _ = fmt.Fprintf(w, "セッション: %s 〜 %s（%s、%d件", s.Start.Format(TimeFormatShort), s.End.Format(TimeFormatShort),
+			s.End.Sub(s.Start).Round(time.Minute), s.VisitCount)
+		if len(s.Domains) > 0 {
+			_, _ = fmt.Fprintf(w, "、主に %s", s.Domains[0].Domain)
+		}
+		_, _ = fmt.Fprintf(w, "）\n")
+	} else {
+		_, _ = fmt.Fprintf(w, "セッション: この時刻には閲覧していません\n")
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintf(w, "🌐 主なドメイン\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, d := range report.Domains {
+		_, _ = fmt.Fprintf(w, "  %-30s %4d件 (%5.1f%%)\n", d.Domain, d.VisitCount, d.Percentage)
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintf(w, "📜 訪問\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	printTimeMachineVisits(w, report.Before)
+	_, _ = fmt.Fprintf(w, "  ▶ %s\n", report.At.Format(TimeFormatFull))
+	printTimeMachineVisits(w, report.After)
+	_, _ = fmt.Fprintln(w)
+}
+
+// printTimeMachineVisits は訪問を1行ずつ出力する
+func printTimeMachineVisits(w io.Writer, visits []HistoryVisit) {
+	for _, v := range visits {
+		title := v.Title
+		if title == "" {
+			title = v.URL
+		}
+		_, _ = fmt.Fprintf(w, "  %s  %-24s %s\n", v.VisitTime.Format("15:04:05"), truncateText(extractDomain(v.URL), 24), truncateText(title, MaxTitleLength))
+	}
+}
+
+// runAtCommand は hist at サブコマンドを実行する
+func runAtCommand(args []string) error {
+	fs := flag.NewFlagSet("at", flag.ContinueOnError)
+	window := fs.Duration("window", defaultTimeMachineWindow, "前後を遡る時間（例: 30m, 2h）")
+	limit := fs.Int("limit", defaultTimeMachineLimit, "時刻の前後それぞれに表示する訪問の件数（0で全件）")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist at [オプション] <時刻>\n\n")
+		fmt.Fprintf(fs.Output(), "  指定した時刻の前後の訪問・セッション・主なドメインを表示します\n")
+		fmt.Fprintf(fs.Output(), "  時刻は \"2024-12-01 15:00\" または今日の \"15:00\"（オプションは時刻の後ろにも書けます）\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// hist at "2024-12-01 15:00" -window 2h のように時刻の後ろのオプションも受け付ける
+	rest := fs.Args()
+	if len(rest) > 1 {
+		if err := fs.Parse(rest[1:]); err != nil {
+			return err
+		}
+		rest = append(rest[:1], fs.Args()...)
+	}
+	if len(rest) != 1 {
+		fs.Usage()
+		return errors.New("時刻を1つ指定してください")
+	}
+	if *window <= 0 {
+		return errors.New("-window は正の時間で指定してください")
+	}
+	at, err := parseTimeMachineTime(rest[0], time.Now())
+	if err != nil {
+		return err
+	}
+
+	var redactDomains []string
+	if !*noRedact {
+		if redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	report, err := getTimeMachineReport(db, at, *window, *limit, redactDomains)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printTimeMachineReport(os.Stdout, report, *window)
+	return nil
+}
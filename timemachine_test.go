@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimeMachineTime(t *testing.T) {
+	now := time.Date(2025, 3, 10, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-12-01 15:00", time.Date(2024, 12, 1, 15, 0, 0, 0, time.UTC), false},
+		{"2024-12-01 15:00:30", time.Date(2024, 12, 1, 15, 0, 30, 0, time.UTC), false},
+		{"2024-12-01T09:15", time.Date(2024, 12, 1, 9, 15, 0, 0, time.UTC), false},
+		{" 15:00 ", time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC), false},
+		{"2024-12-01", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeMachineTime(tt.s, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseTimeMachineTime(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestSplitBrowsingSessions(t *testing.T) {
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	visit := func(minutes int, url string) HistoryVisit {
+		return HistoryVisit{URL: url, VisitTime: base.Add(time.Duration(minutes) * time.Minute)}
+	}
+	sessions := splitBrowsingSessions([]HistoryVisit{
+		visit(0, "https://github.com/a"),
+		visit(10, "https://github.com/b"),
+		visit(35, "https://go.dev/"),
+		visit(120, "https://news.example/"),
+	})
+	if len(sessions) != 2 {
+		t.Fatalf("セッション数 = %d, want 2", len(sessions))
+	}
+	s := sessions[0]
+	if !s.Start.Equal(base) || s.End.Sub(s.Start) != 35*time.Minute || s.VisitCount != 3 ||
+		len(s.Domains) != 2 || s.Domains[0].Domain != "github.com" || s.Domains[0].VisitCount != 2 {
+		t.Errorf("1つ目のセッション = %+v", s)
+	}
+
+	if got := activeBrowsingSession(sessions, base.Add(20*time.Minute)); got != &sessions[0] {
+		t.Errorf("セッション中の時刻 = %+v", got)
+	}
+	if got := activeBrowsingSession(sessions, base.Add(100*time.Minute)); got != &sessions[1] {
+		t.Errorf("次のセッションの直前 = %+v", got)
+	}
+	if got := activeBrowsingSession(sessions, base.Add(75*time.Minute)); got != nil {
+		t.Errorf("セッションの間 = %+v, want nil", got)
+	}
+}
+
+func TestGetTimeMachineReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 訪問は 2025-01-01 10:00・11:00・12:00（UTC）と翌日の 10:00・11:00
+	at := time.Date(2025, 1, 1, 11, 10, 0, 0, time.UTC)
+	report, err := getTimeMachineReport(db, at, 2*time.Hour, 0, nil)
+	if err != nil {
+		t.Fatalf("getTimeMachineReport() error = %v", err)
+	}
+	if report.VisitCount != 3 || len(report.Before) != 2 || len(report.After) != 1 {
+		t.Fatalf("訪問 = %d件（前 %d件・後 %d件）, want 3件（前 2件・後 1件）", report.VisitCount, len(report.Before), len(report.After))
+	}
+	if report.Before[0].ID != 1 || report.Before[1].ID != 2 || report.After[0].ID != 3 {
+		t.Errorf("訪問の並び = %d, %d, %d, want 1, 2, 3", report.Before[0].ID, report.Before[1].ID, report.After[0].ID)
+	}
+	if report.Session == nil || report.Session.VisitCount != 1 || report.Session.Domains[0].Domain != "youtube.com" {
+		t.Errorf("セッション = %+v, want 11:00 の youtube.com", report.Session)
+	}
+	if len(report.Domains) != 3 {
+		t.Errorf("ドメイン = %+v", report.Domains)
+	}
+
+	// limit は時刻に近い訪問を残し、リダクト対象は伏せる
+	report, err = getTimeMachineReport(db, at, 2*time.Hour, 1, []string{"youtube.com"})
+	if err != nil {
+		t.Fatalf("getTimeMachineReport() error = %v", err)
+	}
+	if len(report.Before) != 1 || report.Before[0].ID != 2 || report.Before[0].URL != redactedLabel {
+		t.Errorf("limit・リダクト後の前の訪問 = %+v", report.Before)
+	}
+	if report.Session.Domains[0].Domain != redactedLabel {
+		t.Errorf("セッションのドメイン = %+v", report.Session.Domains)
+	}
+
+	var buf bytes.Buffer
+	printTimeMachineReport(&buf, report, 2*time.Hour)
+	if out := buf.String(); !strings.Contains(out, "セッション:") || strings.Contains(out, "youtube.com") {
+		t.Errorf("出力:\n%s", out)
+	}
+
+	// 訪問のない時刻
+	report, err = getTimeMachineReport(db, at.AddDate(0, 0, 5), time.Hour, 0, nil)
+	if err != nil {
+		t.Fatalf("getTimeMachineReport() error = %v", err)
+	}
+	if report.VisitCount != 0 || report.Session != nil || report.Before == nil || report.After == nil {
+		t.Errorf("訪問のない時刻 = %+v", report)
+	}
+}
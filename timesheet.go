@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// categoriesFileName はドメインとカテゴリの対応（1行「ドメイン カテゴリ」、サブドメインにもマッチ）
+const categoriesFileName = "categories.txt"
+
+const (
+	// TimesheetByCategory はタイムシートの時間をカテゴリごとに割り当てる指定
+	TimesheetByCategory = "category"
+	// TimesheetByDomain はタイムシートの時間をドメインごとに割り当てる指定
+	TimesheetByDomain = "domain"
+)
+
+const (
+	// defaultTimesheetDays は hist timesheet で集計する既定の日数（今日を含む）
+	defaultTimesheetDays = 7
+	// defaultTimesheetMinBlock はこれより短いブロックを直前のブロックにまとめる既定の長さ
+	defaultTimesheetMinBlock = 5 * time.Minute
+	// uncategorizedLabel は categories.txt のどのドメインにも一致しない訪問のカテゴリ
+	uncategorizedLabel = "未分類"
+)
+
+// DomainCategory は categories.txt の1行
+type DomainCategory struct {
+	Domain   string
+	Category string
+}
+
+// TimesheetBlock はタイムシートの1行（同じカテゴリ・ドメインの訪問が続いた時間）
+type TimesheetBlock struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Minutes int       `json:"minutes"`
+	Label   string    `json:"label"`
+	// Domains はブロック中に訪問したドメイン（訪問の多い順）
+	Domains    []string `json:"domains"`
+	VisitCount int      `json:"visit_count"`
+}
+
+// TimesheetTotal はカテゴリ・ドメインごとの1日の合計時間
+type TimesheetTotal struct {
+	Label   string `json:"label"`
+	Minutes int    `json:"minutes"`
+}
+
+// TimesheetDay は1日分のタイムシート
+type TimesheetDay struct {
+	Date         string           `json:"date"`
+	TotalMinutes int              `json:"total_minutes"`
+	Totals       []TimesheetTotal `json:"totals"`
+	Blocks       []TimesheetBlock `json:"blocks"`
+}
+
+// Timesheet は hist timesheet の結果
+type Timesheet struct {
+	// By は時間の割り当て先（category または domain）
+	By   string         `json:"by"`
+	From string         `json:"from"`
+	To   string         `json:"to"`
+	Days []TimesheetDay `json:"days"`
+}
+
+// parseDomainCategory は「ドメイン カテゴリ」形式の1行を解析する（カテゴリには空白を含められる）
+func parseDomainCategory(s string) (DomainCategory, error) {
+	domain, category, ok := strings.Cut(strings.TrimSpace(s), " ")
+	category = strings.TrimSpace(category)
+	if !ok || domain == "" || category == "" {
+		return DomainCategory{}, fmt.Errorf("カテゴリは「ドメイン カテゴリ」形式で指定してください: %s", s)
+	}
+	return DomainCategory{Domain: strings.ToLower(domain), Category: category}, nil
+}
+
+// LoadDomainCategories はドメインとカテゴリの対応を読み込む
+func LoadDomainCategories() ([]DomainCategory, error) {
+	lines, err := loadDomainListFile(categoriesFileName, "カテゴリ一覧")
+	if err != nil {
+		return nil, err
+	}
+	var categories []DomainCategory
+	for _, line := range lines {
+		c, err := parseDomainCategory(line)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// categoryForDomain は domain に一致するカテゴリを返す（複数一致すれば最も長いドメインの行）
+func categoryForDomain(domain string, categories []DomainCategory) string {
+	label, matched := uncategorizedLabel, ""
+	for _, c := range categories {
+		if len(c.Domain) > len(matched) && domainMatchesList(domain, []string{c.Domain}) {
+			label, matched = c.Category, c.Domain
+		}
+	}
+	return label
+}
+
+// getTimesheet は from〜to（日付単位、両端を含む）の訪問をセッションに区切り、カテゴリ・ドメインごとの時間のブロックにまとめる
+// ブロックは次に別のカテゴリ・ドメインの訪問をするまで続き、セッションの最後のブロックは最後の訪問で終わる
+// minBlock より短いブロックは同じセッションの直前のブロックに含める。日付はブロックの開始時刻で loc のタイムゾーンで決める
+func getTimesheet(db dbQuerier, from, to time.Time, by string, categories []DomainCategory, minBlock time.Duration, loc *time.Location, redactDomains []string) (Timesheet, error) {
+	sheet := Timesheet{By: by, From: from.Format(TimeFormatDate), To: to.Format(TimeFormatDate), Days: []TimesheetDay{}}
+	label := func(domain string) string {
+		if by == TimesheetByDomain {
+			if domainMatchesList(domain, redactDomains) {
+				return redactedLabel
+			}
+			return domain
+		}
+		return categoryForDomain(domain, categories)
+	}
+
+	var visits []HistoryVisit
+	err := streamRecentVisits(db, 0, SearchFilter{From: from, To: to}, func(v HistoryVisit) error {
+		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		return sheet, fmt.Errorf("訪問の取得に失敗: %w", err)
+	}
+	// 新しい順に届くので古い順に並べ替える
+	for i, j := 0, len(visits)-1; i < j; i, j = i+1, j-1 {
+		visits[i], visits[j] = visits[j], visits[i]
+	}
+	visits = sanitizeVisits(visits)
+
+	days := make(map[string]*TimesheetDay)
+	var dates []string
+	for _, s := range splitBrowsingSessions(visits) {
+		for _, b := range timesheetBlocks(s.visits, label, minBlock, redactDomains) {
+			date := b.Start.In(loc).Format(TimeFormatDate)
+			day := days[date]
+			if day == nil {
+				day = &TimesheetDay{Date: date}
+				days[date] = day
+				dates = append(dates, date)
+			}
+			day.Blocks = append(day.Blocks, b)
+		}
+	}
+	for _, date := range dates {
+		day := days[date]
+		minutes := make(map[string]int)
+		for _, b := range day.Blocks {
+			day.TotalMinutes += b.Minutes
+			if _, ok := minutes[b.Label]; !ok {
+				day.Totals = append(day.Totals, TimesheetTotal{Label: b.Label})
+			}
+			minutes[b.Label] += b.Minutes
+		}
+		for i := range day.Totals {
+			day.Totals[i].Minutes = minutes[day.Totals[i].Label]
+		}
+		sort.SliceStable(day.Totals, func(i, j int) bool {
+			return day.Totals[i].Minutes > day.Totals[j].Minutes
+		})
+		sheet.Days = append(sheet.Days, *day)
+	}
+	return sheet, nil
+}
+
+// timesheetBlocks は1つのセッションの訪問（古い順）を、同じラベルの訪問が続いたブロックに分ける
+func timesheetBlocks(visits []HistoryVisit, label func(string) string, minBlock time.Duration, redactDomains []string) []TimesheetBlock {
+	type block struct {
+		label  string
+		start  time.Time
+		visits []HistoryVisit
+	}
+	var blocks []*block
+	for _, v := range visits {
+		l := label(extractDomain(v.URL))
+		if n := len(blocks); n == 0 || blocks[n-1].label != l {
+			blocks = append(blocks, &block{label: l, start: v.VisitTime})
+		}
+		blocks[len(blocks)-1].visits = append(blocks[len(blocks)-1].visits, v)
+	}
+	end := func(i int) time.Time {
+		if i+1 < len(blocks) {
+			return blocks[i+1].start
+		}
+		b := blocks[i]
+		return b.visits[len(b.visits)-1].VisitTime
+	}
+
+	// 短いブロックを直前のブロックに含め、同じラベルが続けばまとめる
+	var merged []*block
+	for i, b := range blocks {
+		n := len(merged)
+		switch {
+		case n > 0 && merged[n-1].label == b.label:
+			merged[n-1].visits = append(merged[n-1].visits, b.visits...)
+		case n > 0 && end(i).Sub(b.start) < minBlock:
+			merged[n-1].visits = append(merged[n-1].visits, b.visits...)
+		default:
+			merged = append(merged, b)
+		}
+	}
+	blocks = merged
+
+	result := make([]TimesheetBlock, len(blocks))
+	for i, b := range blocks {
+		e := end(i)
+		var domains []string
+		for _, d := range redactDomainStats(countVisitDomains(b.visits), redactDomains) {
+			domains = append(domains, d.Domain)
+		}
+		result[i] = TimesheetBlock{
+			Start:      b.start,
+			End:        e,
+			Minutes:    int(e.Sub(b.start).Round(time.Minute) / time.Minute),
+			Label:      b.label,
+			Domains:    domains,
+			VisitCount: len(b.visits),
+		}
+	}
+	return result
+}
+
+// writeTimesheetCSV はタイムシートを1ブロック1行のCSVで出力する（時間管理ツールへの取り込み用）
+func writeTimesheetCSV(w io.Writer, sheet Timesheet, loc *time.Location) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"date", "start", "end", "minutes", "duration", sheet.By, "domains", "visit_count"}); err != nil {
+		return err
+	}
+	for _, day := range sheet.Days {
+		for _, b := range day.Blocks {
+			record := []string{
+				day.Date,
+				b.Start.In(loc).Format("15:04"),
+				b.End.In(loc).Format("15:04"),
+				strconv.Itoa(b.Minutes),
+				formatTimesheetMinutes(b.Minutes),
+				b.Label,
+				strings.Join(b.Domains, " "),
+				strconv.Itoa(b.VisitCount),
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatTimesheetMinutes は分を "H:MM" 形式にする
+func formatTimesheetMinutes(minutes int) string {
+	return fmt.Sprintf("%d:%02d", minutes/60, minutes%60)
+}
+
+// printTimesheet はタイムシートを日ごとに出力する
+func printTimesheet(w io.Writer, sheet Timesheet, loc *time.Location) {
+	if len(sheet.Days) == 0 {
+		_, _ = fmt.Fprintf(w, "%s 〜 %s の訪問はありません\n", sheet.From, sheet.To)
+		return
+	}
+	for _, day := range sheet.Days {
+		_, _ = fmt.Fprintf(w, "\n🗓  %s（合計 %s）\n", day.Date, formatTimesheetMinutes(day.TotalMinutes))
+		_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+		for _, b := range day.Blocks {
+			_, _ = fmt.Fprintf(w, "  %s-%s  %6s  %-20s %s\n", b.Start.In(loc).Format("15:04"), b.End.In(loc).Format("15:04"),
+				formatTimesheetMinutes(b.Minutes), truncateText(b.Label, 20), truncateText(strings.Join(b.Domains, ", "), MaxTitleLength))
+		}
+		_, _ = fmt.Fprintln(w)
+		for _, t := range day.Totals {
+			_, _ = fmt.Fprintf(w, "  %-20s %6s\n", truncateText(t.Label, 20), formatTimesheetMinutes(t.Minutes))
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// runTimesheetCommand は hist timesheet サブコマンドを実行する
+func runTimesheetCommand(args []string) error {
+	fs := flag.NewFlagSet("timesheet", flag.ContinueOnError)
+	days := fs.Int("days", defaultTimesheetDays, "今日を含む過去N日間を集計（-from 指定時は無視）")
+	fromDate := fs.String("from", "", "開始日 (YYYY-MM-DD)")
+	toDate := fs.String("to", "", "終了日 (YYYY-MM-DD、省略時は今日)")
+	by := fs.String("by", TimesheetByCategory, "時間の割り当て先（category, domain）")
+	minBlock := fs.Duration("min-block", defaultTimesheetMinBlock, "これより短いブロックを直前のブロックに含める")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	csvOutput := fs.Bool("csv", false, "CSV形式で出力（1ブロック1行）")
+	noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist timesheet [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  ブラウジングセッションを日ごとの時間のブロックに分け、カテゴリ・ドメインごとの時間を表示します\n")
+		fmt.Fprintf(fs.Output(), "  カテゴリは ~/.config/hist/%s に「ドメイン カテゴリ」形式で1行ずつ設定します\n\n", categoriesFileName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *by != TimesheetByCategory && *by != TimesheetByDomain {
+		return fmt.Errorf("-by は %s または %s で指定してください", TimesheetByCategory, TimesheetByDomain)
+	}
+	if *jsonOutput && *csvOutput {
+		return errors.New("-json と -csv は同時に指定できません")
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if *toDate != "" {
+		t, err := parseLocalDate(*toDate)
+		if err != nil {
+			return fmt.Errorf("終了日の形式が不正です: %w", err)
+		}
+		to = t
+	}
+	var from time.Time
+	if *fromDate != "" {
+		t, err := parseLocalDate(*fromDate)
+		if err != nil {
+			return fmt.Errorf("開始日の形式が不正です: %w", err)
+		}
+		from = t
+	} else {
+		if *days <= 0 {
+			return errors.New("-days は1以上で指定してください")
+		}
+		from = to.AddDate(0, 0, 1-*days)
+	}
+	if from.After(to) {
+		return errors.New("開始日が終了日より後になっています")
+	}
+
+	var categories []DomainCategory
+	if *by == TimesheetByCategory {
+		var err error
+		if categories, err = LoadDomainCategories(); err != nil {
+			return err
+		}
+		if len(categories) == 0 {
+			return errors.New("カテゴリが設定されていません（~/.config/hist/" + categoriesFileName + " に「github.com 開発」のように登録するか、-by domain を指定）")
+		}
+	}
+	var redactDomains []string
+	if !*noRedact {
+		var err error
+		if redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	sheet, err := getTimesheet(db, from, to, *by, categories, *minBlock, time.Local, redactDomains)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *jsonOutput:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sheet)
+	case *csvOutput:
+		return writeTimesheetCSV(os.Stdout, sheet, time.Local)
+	}
+	printTimesheet(os.Stdout, sheet, time.Local)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDomainCategory(t *testing.T) {
+	c, err := parseDomainCategory("GitHub.com  開発 作業")
+	if err != nil || c != (DomainCategory{Domain: "github.com", Category: "開発 作業"}) {
+		t.Errorf("parseDomainCategory() = %+v, %v", c, err)
+	}
+	if _, err := parseDomainCategory("github.com"); err == nil {
+		t.Error("カテゴリのない行がエラーにならない")
+	}
+
+	categories := []DomainCategory{{"google.com", "調査"}, {"docs.google.com", "資料"}}
+	tests := map[string]string{
+		"google.com":      "調査",
+		"www.google.com":  "調査",
+		"docs.google.com": "資料",
+		"example.com":     uncategorizedLabel,
+	}
+	for domain, want := range tests {
+		if got := categoryForDomain(domain, categories); got != want {
+			t.Errorf("categoryForDomain(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
+
+// insertTimesheetTestData は 2025-01-06（UTC）に2つのセッションの訪問を追加する
+func insertTimesheetTestData(t *testing.T, db *sql.DB) {
+	t.Helper()
+	base := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	for i, v := range []struct {
+		item    int
+		minutes int
+	}{
+		{1, 0}, {1, 20}, {2, 40}, {1, 42}, {3, 60}, {3, 80}, // 09:00〜10:20
+		{1, 180}, // 12:00
+	} {
+		if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (?, ?, ?, 'Page')`,
+			200+i, v.item, convertToTimestamp(base.Add(time.Duration(v.minutes)*time.Minute))); err != nil {
+			t.Fatalf("テストデータ挿入に失敗: %v", err)
+		}
+	}
+}
+
+func TestGetTimesheet(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	insertTimesheetTestData(t, db)
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	categories := []DomainCategory{{"github.com", "開発"}, {"google.com", "調査"}}
+
+	sheet, err := getTimesheet(db, day, day, TimesheetByCategory, categories, 5*time.Minute, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("getTimesheet() error = %v", err)
+	}
+	if len(sheet.Days) != 1 || sheet.Days[0].Date != "2025-01-06" {
+		t.Fatalf("日 = %+v", sheet.Days)
+	}
+	d := sheet.Days[0]
+	// 09:40 の YouTube（2分）は直前の開発のブロックに含める
+	if len(d.Blocks) != 3 {
+		t.Fatalf("ブロック = %+v", d.Blocks)
+	}
+	b := d.Blocks[0]
+	if b.Label != "開発" || b.Minutes != 60 || b.VisitCount != 4 || strings.Join(b.Domains, ",") != "github.com,youtube.com" {
+		t.Errorf("1つ目のブロック = %+v", b)
+	}
+	if b := d.Blocks[1]; b.Label != "調査" || b.Minutes != 20 || b.Start.Format("15:04") != "10:00" {
+		t.Errorf("2つ目のブロック = %+v", b)
+	}
+	if b := d.Blocks[2]; b.Label != "開発" || b.Minutes != 0 {
+		t.Errorf("別のセッションのブロック = %+v", b)
+	}
+	if d.TotalMinutes != 80 || len(d.Totals) != 2 || d.Totals[0] != (TimesheetTotal{"開発", 60}) {
+		t.Errorf("合計 = %d, %+v", d.TotalMinutes, d.Totals)
+	}
+
+	// ドメインごと、リダクト対象は伏せる
+	sheet, err = getTimesheet(db, day, day, TimesheetByDomain, nil, 5*time.Minute, time.UTC, []string{"google.com"})
+	if err != nil {
+		t.Fatalf("getTimesheet() error = %v", err)
+	}
+	if b := sheet.Days[0].Blocks[1]; b.Label != redactedLabel || b.Domains[0] != redactedLabel {
+		t.Errorf("リダクト対象のブロック = %+v", b)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTimesheetCSV(&buf, sheet, time.UTC); err != nil {
+		t.Fatalf("writeTimesheetCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[0] != "date,start,end,minutes,duration,domain,domains,visit_count" ||
+		lines[1] != "2025-01-06,09:00,10:00,60,1:00,github.com,github.com youtube.com,4" {
+		t.Errorf("CSV:\n%s", buf.String())
+	}
+
+	// 訪問のない期間
+	empty := day.AddDate(0, 0, 10)
+	if sheet, err := getTimesheet(db, empty, empty, TimesheetByDomain, nil, 0, time.UTC, nil); err != nil || len(sheet.Days) != 0 {
+		t.Errorf("訪問のない期間 = %+v, %v", sheet, err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// titleClusterURLPreview はテキスト出力でクラスタごとに表示するURL数
+const titleClusterURLPreview = 3
+
+// titleSeparators はタイトル中のサイト名との区切りとしてよく使われる文字列
+var titleSeparators = []string{" - ", " | ", " – ", " — ", " · ", " :: ", " : "}
+
+// titleCounterPrefix は通知件数などの先頭の "(3) " を表す
+var titleCounterPrefix = regexp.MustCompile(`^\(\d+\+?\)\s*`)
+
+// TitleCluster は正規化したタイトルが同じ訪問のまとまり
+// 同じ記事に別URL（クエリ違い・リダイレクト・AMPなど）で訪れた場合もまとめて数える
+type TitleCluster struct {
+	Title      string    `json:"title"`
+	VisitCount int       `json:"visit_count"`
+	URLs       []string  `json:"urls"`
+	LastVisit  time.Time `json:"last_visit"`
+}
+
+// normalizeTitle はタイトルをクラスタのキーに正規化する
+// 通知件数の接頭辞とサイト名の区切り部分を取り除き、記号・空白・大文字小文字の違いを無視する
+func normalizeTitle(title, domain string) string {
+	title = titleCounterPrefix.ReplaceAllString(strings.TrimSpace(title), "")
+
+	// サイト名（ドメインのベース名を含む区切り部分）を除く
+	// 例: "GitHub - Test Repo" と "Test Repo · GitHub" はどちらも "test repo"
+	site := strings.ToLower(strings.Split(extractBaseDomain(domain), ".")[0])
+	segments := []string{title}
+	for _, sep := range titleSeparators {
+		var next []string
+		for _, s := range segments {
+			next = append(next, strings.Split(s, sep)...)
+		}
+		segments = next
+	}
+	if len(segments) > 1 && site != "" {
+		var kept []string
+		for _, s := range segments {
+			if !strings.Contains(strings.ToLower(s), site) {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) > 0 {
+			segments = kept
+		}
+	}
+
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.Join(segments, " ")) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// getTitleClusters は訪問をタイトルでまとめ、訪問数の多い順に返す
+// 代表タイトルはクラスタ内で最も多く使われていた元のタイトル
+func getTitleClusters(db dbQuerier, limit int, filter SearchFilter) ([]TitleCluster, error) {
+	type clusterInfo struct {
+		titleCounts map[string]int
+		urlCounts   map[string]int
+		visitCount  int
+		lastVisit   time.Time
+	}
+	clusters := make(map[string]*clusterInfo)
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		title, _ := sanitizeText(v.Title)
+		key := normalizeTitle(title, extractDomain(v.URL))
+		if key == "" {
+			return nil
+		}
+
+		c := clusters[key]
+		if c == nil {
+			c = &clusterInfo{titleCounts: make(map[string]int), urlCounts: make(map[string]int)}
+			clusters[key] = c
+		}
+		c.titleCounts[title]++
+		c.urlCounts[v.URL]++
+		c.visitCount++
+		if v.VisitTime.After(c.lastVisit) {
+			c.lastVisit = v.VisitTime
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("タイトル別集計に失敗: %w", err)
+	}
+
+	// 件数の多い順（同数なら辞書順）に並べたキーを返す
+	byCount := func(counts map[string]int) []string {
+		keys := make([]string, 0, len(counts))
+		for k := range counts {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if counts[keys[i]] != counts[keys[j]] {
+				return counts[keys[i]] > counts[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		return keys
+	}
+
+	var result []TitleCluster
+	for _, c := range clusters {
+		result = append(result, TitleCluster{
+			Title:      byCount(c.titleCounts)[0],
+			VisitCount: c.visitCount,
+			URLs:       byCount(c.urlCounts),
+			LastVisit:  c.lastVisit,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].VisitCount != result[j].VisitCount {
+			return result[i].VisitCount > result[j].VisitCount
+		}
+		return result[i].Title < result[j].Title
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// redactTitleClusters はリダクト対象のURLを含むクラスタのタイトルとURLを伏せたコピーを返す
+func redactTitleClusters(clusters []TitleCluster, redactDomains []string) []TitleCluster {
+	if len(redactDomains) == 0 || clusters == nil {
+		return clusters
+	}
+	redacted := make([]TitleCluster, len(clusters))
+	for i, c := range clusters {
+		for _, u := range c.URLs {
+			if domainMatchesList(extractDomain(u), redactDomains) {
+				c.Title = redactedLabel
+				c.URLs = nil
+				break
+			}
+		}
+		redacted[i] = c
+	}
+	return redacted
+}
+
+// printTitleClusters はタイトル別の訪問数をテキスト形式で出力する
+func printTitleClusters(w io.Writer, clusters []TitleCluster) {
+	_, _ = fmt.Fprintf(w, "📰 タイトル別訪問数 (Top %d)\n", len(clusters))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, c := range clusters {
+		_, _ = fmt.Fprintf(w, "  %4d  %s\n", c.VisitCount, truncateText(c.Title, TitleTruncateLength))
+		for i, u := range c.URLs {
+			if i == titleClusterURLPreview {
+				_, _ = fmt.Fprintf(w, "          ... 他%d件のURL\n", len(c.URLs)-titleClusterURLPreview)
+				break
+			}
+			_, _ = fmt.Fprintf(w, "          🔗 %s\n", truncateText(u, MaxTitleLength))
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
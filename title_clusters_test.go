@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+// TestNormalizeTitle はタイトル正規化のテスト
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title  string
+		domain string
+		want   string
+	}{
+		{"GitHub - Test Repo", "github.com", "test repo"},
+		{"Test Repo · GitHub", "github.com", "test repo"},
+		{"(3) Test Repo | GitHub", "github.com", "test repo"},
+		{"Go言語入門：第1回", "example.com", "go言語入門 第1回"},
+		{"Example Domain", "example.com", "example domain"},
+		{"  ", "example.com", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeTitle(tt.title, tt.domain); got != tt.want {
+			t.Errorf("normalizeTitle(%q, %q) = %q, want %q", tt.title, tt.domain, got, tt.want)
+		}
+	}
+}
+
+// TestGetTitleClusters はタイトル別クラスタ集計のテスト
+func TestGetTitleClusters(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 同じ記事に別URLで訪問
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'https://github.com/test?utm_source=x', 'github', 1),
+		(6, 'https://m.github.com/test', 'm.github', 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757600000, 'Test Repo · GitHub'),
+		(7, 6, 757600100, '(2) GitHub - Test Repo');
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	clusters, err := getTitleClusters(db, 0, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getTitleClusters失敗: %v", err)
+	}
+	if len(clusters) == 0 {
+		t.Fatal("クラスタが空")
+	}
+
+	top := clusters[0]
+	if top.VisitCount != 3 || len(top.URLs) != 3 {
+		t.Errorf("最上位クラスタが期待値と異なる: %+v", top)
+	}
+	// 使用回数が同じ場合は辞書順で最初のタイトルが代表になる
+	if top.Title != "(2) GitHub - Test Repo" {
+		t.Errorf("代表タイトルが期待値と異なる: %q", top.Title)
+	}
+
+	limited, err := getTitleClusters(db, 1, SearchFilter{})
+	if err != nil || len(limited) != 1 {
+		t.Errorf("limit指定時の件数が期待値と異なる: %d, err=%v", len(limited), err)
+	}
+}
+
+// TestRedactTitleClusters はタイトル別クラスタのリダクトのテスト
+func TestRedactTitleClusters(t *testing.T) {
+	clusters := []TitleCluster{
+		{Title: "Bank", VisitCount: 2, URLs: []string{"https://bank.example.com/login"}},
+		{Title: "News", VisitCount: 1, URLs: []string{"https://news.example.org/a"}},
+	}
+	redacted := redactTitleClusters(clusters, []string{"bank.example.com"})
+	if redacted[0].Title != redactedLabel || redacted[0].URLs != nil || redacted[0].VisitCount != 2 {
+		t.Errorf("リダクト対象が伏せられていない: %+v", redacted[0])
+	}
+	if redacted[1].Title != "News" {
+		t.Errorf("対象外が変更された: %+v", redacted[1])
+	}
+	if clusters[0].Title != "Bank" {
+		t.Error("元のスライスが変更された")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// タイトルの言語（文字の種類から判定する）
+const (
+	TitleLangJapanese = "ja"
+	TitleLangKorean   = "ko"
+	TitleLangChinese  = "zh"
+	TitleLangRussian  = "ru"
+	TitleLangEnglish  = "en"
+	TitleLangUnknown  = "und"
+)
+
+// titleLangScript は言語と、その言語とみなす文字の範囲
+type titleLangScript struct {
+	Lang   string
+	Label  string
+	Ranges [][2]rune
+}
+
+// titleLangScripts は判定に使う文字の範囲（上から順に判定する）
+// かなを含めば日本語、含まずに漢字だけなら中国語、ラテン文字だけなら英語とみなす
+var titleLangScripts = []titleLangScript{
+	{TitleLangJapanese, "日本語", [][2]rune{{'ぁ', 'ゟ'}, {'゠', 'ヿ'}, {'ｦ', 'ﾟ'}}},
+	{TitleLangKorean, "韓国語", [][2]rune{{'가', '힣'}, {'ᄀ', 'ᇿ'}, {'㄰', '㆏'}}},
+	{TitleLangChinese, "中国語", [][2]rune{{'一', '鿿'}}},
+	{TitleLangRussian, "ロシア語", [][2]rune{{'Ѐ', 'ӿ'}}},
+	{TitleLangEnglish, "英語", [][2]rune{{'A', 'Z'}, {'a', 'z'}}},
+}
+
+// titleLangLabel は言語の表示名を返す
+func titleLangLabel(lang string) string {
+	for _, s := range titleLangScripts {
+		if s.Lang == lang {
+			return s.Label
+		}
+	}
+	return "不明"
+}
+
+// parseTitleLang は -title-lang の値を検証する
+func parseTitleLang(s string) (string, error) {
+	lang := strings.ToLower(strings.TrimSpace(s))
+	if lang == TitleLangUnknown {
+		return lang, nil
+	}
+	for _, script := range titleLangScripts {
+		if script.Lang == lang {
+			return lang, nil
+		}
+	}
+	return "", fmt.Errorf("タイトルの言語は ja, en, ko, zh, ru, und のいずれかで指定してください: %s", s)
+}
+
+// contains は文字が範囲のいずれかに含まれるか
+func (s titleLangScript) contains(r rune) bool {
+	for _, rg := range s.Ranges {
+		if r >= rg[0] && r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// globClass は文字の範囲を SQLite の GLOB の文字クラス（[ぁ-ゟ...]）にする
+func (s titleLangScript) globClass() string {
+	var b strings.Builder
+	b.WriteString("*[")
+	for _, rg := range s.Ranges {
+		b.WriteRune(rg[0])
+		b.WriteRune('-')
+		b.WriteRune(rg[1])
+	}
+	b.WriteString("]*")
+	return b.String()
+}
+
+// detectTitleLanguage はタイトルに含まれる文字の種類から言語を判定する
+// どの文字も含まない場合（空のタイトル・記号や数字だけ）は TitleLangUnknown
+func detectTitleLanguage(title string) string {
+	for _, s := range titleLangScripts {
+		for _, r := range title {
+			if s.contains(r) {
+				return s.Lang
+			}
+		}
+	}
+	return TitleLangUnknown
+}
+
+// WithTitleLang はタイトルの言語で絞り込む条件を追加（detectTitleLanguage と同じ判定をSQLで行う）
+func (qb *QueryBuilder) WithTitleLang(lang string) *QueryBuilder {
+	if lang == "" {
+		return qb
+	}
+	const title = `COALESCE(hv.title, '')`
+	var conds []string
+	for _, s := range titleLangScripts {
+		if s.Lang == lang {
+			conds = append(conds, title+` GLOB ?`)
+			qb.args = append(qb.args, s.globClass())
+			break
+		}
+		// 先に判定する言語の文字を含むタイトルは除く
+		conds = append(conds, title+` NOT GLOB ?`)
+		qb.args = append(qb.args, s.globClass())
+	}
+	qb.where.WriteString(` AND (` + strings.Join(conds, ` AND `) + `)`)
+	return qb
+}
+
+// TitleLanguageStats はタイトルの言語ごとの訪問数
+type TitleLanguageStats struct {
+	Lang       string  `json:"lang"`
+	Label      string  `json:"label"`
+	VisitCount int     `json:"visit_count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// getTitleLanguageStats はフィルタに一致する訪問をタイトルの言語ごとに数える
+func getTitleLanguageStats(db dbQuerier, filter SearchFilter) ([]TitleLanguageStats, error) {
+	counts := make(map[string]int)
+	total := 0
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		counts[detectTitleLanguage(v.Title)]++
+		total++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("タイトルの言語の集計に失敗: %w", err)
+	}
+
+	var stats []TitleLanguageStats
+	for lang, count := range counts {
+		stats = append(stats, TitleLanguageStats{
+			Lang:       lang,
+			Label:      titleLangLabel(lang),
+			VisitCount: count,
+			Percentage: roundPercentage(float64(count) / float64(total) * 100),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].VisitCount != stats[j].VisitCount {
+			return stats[i].VisitCount > stats[j].VisitCount
+		}
+		return stats[i].Lang < stats[j].Lang
+	})
+	return stats, nil
+}
+
+// printTitleLanguageStats はタイトルの言語別の訪問数を棒グラフで出力する
+func printTitleLanguageStats(w io.Writer, stats []TitleLanguageStats) {
+	_, _ = fmt.Fprintf(w, "🔤 タイトルの言語別訪問数\n")
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	maxCount := stats[0].VisitCount
+	for _, s := range stats {
+		barLen := int(float64(s.VisitCount) / float64(maxCount) * BarChartWidth)
+		_, _ = fmt.Fprintf(w, "  %s %s %d (%.1f%%)\n",
+			padRight(fmt.Sprintf("%s (%s)", s.Label, s.Lang), 16), strings.Repeat("█", barLen), s.VisitCount, s.Percentage)
+	}
+	_, _ = fmt.Fprintln(w)
+}
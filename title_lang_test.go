@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDetectTitleLanguage(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Go言語のチュートリアル", TitleLangJapanese},
+		{"ｶﾀｶﾅ", TitleLangJapanese},
+		{"東京都庁", TitleLangChinese},
+		{"GitHub - 日本語ドキュメント", TitleLangJapanese},
+		{"한국어 위키", TitleLangKorean},
+		{"Новости", TitleLangRussian},
+		{"GitHub - Test Repo", TitleLangEnglish},
+		{"", TitleLangUnknown},
+		{"123 - !!", TitleLangUnknown},
+	}
+	for _, tt := range tests {
+		if got := detectTitleLanguage(tt.title); got != tt.want {
+			t.Errorf("detectTitleLanguage(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestParseTitleLang(t *testing.T) {
+	for _, s := range []string{"ja", "EN", " zh ", "und"} {
+		if _, err := parseTitleLang(s); err != nil {
+			t.Errorf("parseTitleLang(%q) error = %v", s, err)
+		}
+	}
+	if _, err := parseTitleLang("fr"); err == nil {
+		t.Error("parseTitleLang(\"fr\") でエラーにならない")
+	}
+}
+
+func TestTitleLangFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'https://go.dev/doc', NULL, 1),
+		(6, 'https://www.ithome.com.tw/', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757418400 + 100, 'Go言語 ドキュメント'),
+		(7, 6, 757418400 + 200, '資訊新聞'),
+		(8, 6, 757418400 + 300, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	// SQLでの絞り込みは detectTitleLanguage と同じ判定になる
+	for _, lang := range []string{TitleLangJapanese, TitleLangChinese, TitleLangEnglish, TitleLangUnknown} {
+		visits, err := getRecentVisits(db, 100, SearchFilter{TitleLang: lang})
+		if err != nil {
+			t.Fatalf("getRecentVisits失敗: %v", err)
+		}
+		if len(visits) == 0 {
+			t.Errorf("%s: 訪問が見つからない", lang)
+		}
+		for _, v := range visits {
+			if got := detectTitleLanguage(v.Title); got != lang {
+				t.Errorf("%s で絞り込んだ訪問 %q の言語が %s", lang, v.Title, got)
+			}
+		}
+	}
+
+	stats, err := getTitleLanguageStats(db, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getTitleLanguageStats失敗: %v", err)
+	}
+	want := []TitleLanguageStats{
+		{Lang: TitleLangEnglish, Label: "英語", VisitCount: 5, Percentage: 62.5},
+		{Lang: TitleLangJapanese, Label: "日本語", VisitCount: 1, Percentage: 12.5},
+		{Lang: TitleLangUnknown, Label: "不明", VisitCount: 1, Percentage: 12.5},
+		{Lang: TitleLangChinese, Label: "中国語", VisitCount: 1, Percentage: 12.5},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("getTitleLanguageStats() = %+v", stats)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("stats[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
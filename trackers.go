@@ -0,0 +1,58 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// trackerListData は同梱のトラッカー・広告ドメインリスト（lists/trackers.txt）
+//
+//go:embed lists/trackers.txt
+var trackerListData string
+
+// trackerDomains は -filter-trackers で除外するドメインの一覧と、照合用の集合
+var trackerDomains, trackerDomainSet = parseTrackerList(trackerListData)
+
+// parseTrackerList は1行1ドメインのリストを読み込む（空行と "#" で始まる行は無視）
+func parseTrackerList(data string) ([]string, map[string]bool) {
+	var domains []string
+	set := make(map[string]bool)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") || set[line] {
+			continue
+		}
+		domains = append(domains, line)
+		set[line] = true
+	}
+	return domains, set
+}
+
+// isTrackerDomain はホスト名が同梱のトラッカー・広告ドメイン（またはそのサブドメイン）か
+func isTrackerDomain(host string) bool {
+	host = strings.ToLower(host)
+	for host != "" {
+		if trackerDomainSet[host] {
+			return true
+		}
+		_, rest, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = rest
+	}
+	return false
+}
+
+// WithoutTrackers は同梱のトラッカー・広告ドメインへの訪問を除外する条件を追加
+// ホスト部分が一致するURL（サブドメインを含む）を除く
+func (qb *QueryBuilder) WithoutTrackers(enabled bool) *QueryBuilder {
+	if !enabled {
+		return qb
+	}
+	for _, d := range trackerDomains {
+		qb.where.WriteString(` AND hi.url NOT LIKE ? AND hi.url NOT LIKE ?`)
+		qb.args = append(qb.args, "%://"+d+"/%", "%://%."+d+"/%")
+	}
+	return qb
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseTrackerList(t *testing.T) {
+	domains, set := parseTrackerList("# コメント\n\nDoubleClick.net\n  hotjar.com  \ndoubleclick.net\n")
+	if len(domains) != 2 || domains[0] != "doubleclick.net" || domains[1] != "hotjar.com" || !set["hotjar.com"] {
+		t.Errorf("parseTrackerList() = %v, %v", domains, set)
+	}
+
+	// 同梱のリストが読み込めている
+	if len(trackerDomains) == 0 {
+		t.Error("同梱のトラッカーリストが空")
+	}
+}
+
+func TestIsTrackerDomain(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"doubleclick.net", true},
+		{"stats.g.doubleclick.net", true},
+		{"www.googletagmanager.com", true},
+		{"API.Segment.io", true},
+		{"segment.io", false},
+		{"notdoubleclick.net", false},
+		{"google.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isTrackerDomain(tt.host); got != tt.want {
+			t.Errorf("isTrackerDomain(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestFilterTrackers(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(5, 'https://www.googletagmanager.com/gtm.js', 'googletagmanager', 7),
+		(6, 'https://stats.g.doubleclick.net/collect', 'stats.g.doubleclick', 3);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 5, 757418400 + 100, ''),
+		(7, 6, 757418400 + 200, '');
+	`)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	filter := SearchFilter{ExcludeTrackers: true}
+
+	visits, err := getRecentVisits(db, 100, filter)
+	if err != nil {
+		t.Fatalf("getRecentVisits失敗: %v", err)
+	}
+	if len(visits) != 5 {
+		t.Errorf("トラッカーを除外した訪問数 = %d, want 5", len(visits))
+	}
+
+	stats, err := getDomainStats(db, 0, filter)
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	for _, s := range stats {
+		if isTrackerDomain(s.Domain) {
+			t.Errorf("ドメイン統計にトラッカーが含まれている: %+v", s)
+		}
+	}
+
+	// 指定しなければ除外しない
+	all, err := getDomainStats(db, 0, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getDomainStats失敗: %v", err)
+	}
+	if len(all) != len(stats)+2 {
+		t.Errorf("ドメイン数 = %d（除外なし）, %d（除外あり）", len(all), len(stats))
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// URLTimelineVisit はURLのタイムラインの訪問1件
+type URLTimelineVisit struct {
+	ID    int64
+	Time  time.Time
+	Title string
+	// TitleChanged は1つ前の訪問からタイトルが変わったか（最初の訪問も true）
+	TitleChanged bool
+}
+
+// URLTimelineDay はURLのタイムラインの1日分の訪問（時刻の新しい順）
+// ページの境目で日が分かれても、Count はその日の全ての訪問数
+type URLTimelineDay struct {
+	Date   string
+	Count  int
+	Visits []URLTimelineVisit
+}
+
+// URLTimelineData はURLのタイムラインページ（/url?u=）用のデータ
+type URLTimelineData struct {
+	Theme    PageTheme
+	Favicons bool
+	Detail   URLDetail
+	Days     []URLTimelineDay
+	// MaxDaily は1日の訪問数の最大（棒の長さの基準）
+	MaxDaily int
+	// ページ送り（履歴一覧と同じく ?page=・?per_page=）
+	CurrentPage int
+	TotalPages  int
+	HasPrev     bool
+	HasNext     bool
+	PrevPage    int
+	NextPage    int
+	PerPage     int
+}
+
+// urlTimelinePath はURLのタイムラインページのパスを返す
+func urlTimelinePath(u string) string {
+	return "/url?u=" + url.QueryEscape(u)
+}
+
+// URLTimeline は getURLTimeline の結果（1ページ分の日と、ページ送りに使う全体の件数）
+type URLTimeline struct {
+	Days []URLTimelineDay
+	// Total は記録されている全ての訪問数、MaxDaily は全ての日のうち1日の訪問数の最大
+	Total    int
+	MaxDaily int
+}
+
+// getURLTimeline は history_items.id のURLの訪問を新しい順に offset 件飛ばして limit 件、日ごとにまとめて返す
+// タイトルの変化と日ごとの訪問数は、ページに入らない訪問も含めて判定する
+func getURLTimeline(db dbQuerier, id int64, limit, offset int) (URLTimeline, error) {
+	rows, err := db.Query(`
+		SELECT hv.id, hv.visit_time, COALESCE(hv.title, '')
+		FROM history_visits hv
+		WHERE hv.history_item = ?
+		ORDER BY hv.visit_time, hv.id`, id)
+	if err != nil {
+		return URLTimeline{}, fmt.Errorf("訪問履歴の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var visits []URLTimelineVisit
+	dayCounts := make(map[string]int)
+	var prevTitle string
+	for rows.Next() {
+		var v URLTimelineVisit
+		var visitTime float64
+		if err := rows.Scan(&v.ID, &visitTime, &v.Title); err != nil {
+			return URLTimeline{}, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		v.Time = convertCoreDataTimestamp(visitTime)
+		v.Title, _ = sanitizeText(v.Title)
+		v.TitleChanged = len(visits) == 0 || v.Title != prevTitle
+		prevTitle = v.Title
+		visits = append(visits, v)
+		dayCounts[v.Time.Format(TimeFormatDate)]++
+	}
+	if err := rows.Err(); err != nil {
+		return URLTimeline{}, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	timeline := URLTimeline{Total: len(visits)}
+	for _, n := range dayCounts {
+		timeline.MaxDaily = max(timeline.MaxDaily, n)
+	}
+
+	// 古い順に読んでタイトルの変化を判定してから、新しい順に並べ替えてページの分だけ日ごとにまとめる
+	for i, j := 0, len(visits)-1; i < j; i, j = i+1, j-1 {
+		visits[i], visits[j] = visits[j], visits[i]
+	}
+	start := min(offset, len(visits))
+	end := min(start+limit, len(visits))
+	for _, v := range visits[start:end] {
+		date := v.Time.Format(TimeFormatDate)
+		if n := len(timeline.Days); n == 0 || timeline.Days[n-1].Date != date {
+			timeline.Days = append(timeline.Days, URLTimelineDay{Date: date, Count: dayCounts[date]})
+		}
+		timeline.Days[len(timeline.Days)-1].Visits = append(timeline.Days[len(timeline.Days)-1].Visits, v)
+	}
+	return timeline, nil
+}
+
+// handleURLTimeline は1つのURLへの訪問をタイムラインで表示する（履歴一覧と同じく1ページ WebPageSize 件）
+// リダクト対象のドメインのURLは表示しない
+func (s *WebServer) handleURLTimeline(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Query().Get("u")
+	if u == "" {
+		http.Redirect(w, r, "/history", http.StatusFound)
+		return
+	}
+	if domainMatchesList(extractDomain(u), s.redactList()) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM history_items WHERE url = ?`, u).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, fmt.Sprintf("履歴に見つかりません: %s", u), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	detail, err := getURLDetail(s.db, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// URL自体は上で確かめたので、伏せるのはリダクト対象へのリダイレクトだけ
+	detail = redactURLDetail(detail, s.redactList())
+	page := 1
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	perPage := queryInt(r, "per_page", WebPageSize, WebMaxPageSize)
+	timeline, err := getURLTimeline(s.db, id, perPage, (page-1)*perPage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	totalPages := max((timeline.Total+perPage-1)/perPage, 1)
+
+	data := URLTimelineData{
+		Theme:       requestTheme(w, r),
+		Favicons:    s.favicons != nil,
+		Detail:      detail,
+		Days:        timeline.Days,
+		MaxDaily:    timeline.MaxDaily,
+		CurrentPage: page,
+		TotalPages:  totalPages,
+		HasPrev:     page > 1,
+		HasNext:     page < totalPages,
+		PrevPage:    page - 1,
+		NextPage:    page + 1,
+		PerPage:     perPageParam(perPage),
+	}
+	if err := s.templates.ExecuteTemplate(w, "url.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetURLTimeline(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	// github.com/test の訪問: 2025-01-01 10:00「GitHub - Test Repo」・2025-01-02 10:00「GitHub - Another Page」
+	// 同じ日にタイトルが変わらない訪問を足す
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 1, ?, 'GitHub - Another Page')`, 757418400.0+86400+600); err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	timeline, err := getURLTimeline(db, 1, WebPageSize, 0)
+	if err != nil {
+		t.Fatalf("getURLTimeline: %v", err)
+	}
+	days := timeline.Days
+	if timeline.Total != 3 || timeline.MaxDaily != 2 {
+		t.Errorf("Total = %d, MaxDaily = %d, want 3, 2", timeline.Total, timeline.MaxDaily)
+	}
+	if len(days) != 2 || days[0].Date != "2025-01-02" || days[1].Date != "2025-01-01" {
+		t.Fatalf("日 = %+v", days)
+	}
+	if len(days[0].Visits) != 2 || days[0].Visits[0].ID != 6 || days[0].Visits[1].ID != 4 {
+		t.Fatalf("2025-01-02 の訪問 = %+v", days[0].Visits)
+	}
+	if days[0].Visits[0].TitleChanged || !days[0].Visits[1].TitleChanged || !days[1].Visits[0].TitleChanged {
+		t.Errorf("タイトルの変化 = %+v / %+v", days[0].Visits, days[1].Visits)
+	}
+
+	// 2ページ目（1件ずつ）は 2025-01-02 の古いほうの訪問だけだが、日の訪問数とタイトルの変化は全ての訪問から求める
+	timeline, err = getURLTimeline(db, 1, 1, 1)
+	if err != nil {
+		t.Fatalf("getURLTimeline: %v", err)
+	}
+	if len(timeline.Days) != 1 || timeline.Days[0].Count != 2 || len(timeline.Days[0].Visits) != 1 || timeline.Days[0].Visits[0].ID != 4 || !timeline.Days[0].Visits[0].TitleChanged {
+		t.Errorf("2ページ目 = %+v", timeline.Days)
+	}
+	if timeline, err = getURLTimeline(db, 1, 1, 10); err != nil || len(timeline.Days) != 0 || timeline.Total != 3 {
+		t.Errorf("範囲外のページ = %+v, %v", timeline, err)
+	}
+}
+
+func TestHandleURLTimeline(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl, redactDomains: []string{"youtube.com"}}
+	defer func() { _ = s.db.Close() }()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleURLTimeline(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get(urlTimelinePath("https://github.com/test"))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータス = %d: %s", rec.Code, body)
+	}
+	for _, want := range []string{"訪問タイムライン", "2025-01-02", "GitHub - Test Repo", "GitHub - Another Page", "タイトルの変遷"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("タイムラインに %q がない", want)
+		}
+	}
+
+	if strings.Contains(body, "次へ") {
+		t.Error("1ページに収まるタイムラインにページ送りがある")
+	}
+
+	// 1ページの件数を超える訪問はページ送りで表示する
+	rec = get(urlTimelinePath("https://github.com/test") + "&per_page=1")
+	body = rec.Body.String()
+	if strings.Contains(body, `id="visit-1"`) || !strings.Contains(body, `id="visit-4"`) || !strings.Contains(body, "ページ <span class=\"font-medium\">1</span> / <span class=\"font-medium\">2</span>") {
+		t.Errorf("1ページ目に2025-01-02の訪問だけが表示されていない:\n%s", body)
+	}
+	if !strings.Contains(body, `href="/url?u=https%3a%2f%2fgithub.com%2ftest&page=2&per_page=1"`) {
+		t.Error("次のページへのリンクがない")
+	}
+	if body := get(urlTimelinePath("https://github.com/test") + "&per_page=1&page=2").Body.String(); !strings.Contains(body, `id="visit-1"`) || !strings.Contains(body, "前へ") {
+		t.Error("2ページ目に2025-01-01の訪問と前のページへのリンクがない")
+	}
+
+	if rec := get(urlTimelinePath("https://github.com/missing")); rec.Code != http.StatusNotFound {
+		t.Errorf("履歴にないURLのステータス = %d", rec.Code)
+	}
+	if rec := get(urlTimelinePath("https://youtube.com/watch")); rec.Code != http.StatusNotFound {
+		t.Errorf("リダクト対象のURLのステータス = %d", rec.Code)
+	}
+	if rec := get("/url"); rec.Code != http.StatusFound {
+		t.Errorf("u なしのステータス = %d", rec.Code)
+	}
+
+	// 履歴ページの行からタイムラインにリンクする（リダクトした訪問にはリンクしない）
+	rec = httptest.NewRecorder()
+	s.handleHistory(rec, httptest.NewRequest("GET", "/history", nil))
+	body = rec.Body.String()
+	if !strings.Contains(body, `href="/url?u=https%3a%2f%2fgithub.com%2ftest"`) {
+		t.Error("履歴ページに訪問タイムラインへのリンクがない")
+	}
+	if strings.Contains(body, `href="/url?u=%5bredacted%5d"`) {
+		t.Error("リダクトした訪問に訪問タイムラインへのリンクがある")
+	}
+}
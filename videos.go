@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// videoSessionGap は同じ動画への訪問を別の視聴セッションとみなす間隔
+// 再生中のリロードや関連動画からの戻りを1回の視聴として数えるため
+const videoSessionGap = 30 * time.Minute
+
+// 動画プラットフォーム名
+const (
+	VideoPlatformYouTube = "YouTube"
+	VideoPlatformNetflix = "Netflix"
+	VideoPlatformTwitch  = "Twitch"
+)
+
+// videoTitleSuffixes はプラットフォームがタイトル末尾に付けるサイト名
+var videoTitleSuffixes = map[string][]string{
+	VideoPlatformYouTube: {" - YouTube Music", " - YouTube"},
+	VideoPlatformNetflix: {" | Netflix", " - Netflix"},
+	VideoPlatformTwitch:  {" - Twitch"},
+}
+
+// VideoRef はURLから取り出した動画の識別子
+type VideoRef struct {
+	Platform string
+	ID       string
+	// URL は同じ動画を同じURLで表す正規化済みのURL
+	URL string
+}
+
+// VideoStats は動画ごとの訪問数と推定視聴セッション数
+type VideoStats struct {
+	Platform   string    `json:"platform"`
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	VisitCount int       `json:"visit_count"`
+	Sessions   int       `json:"sessions"`
+	LastVisit  time.Time `json:"last_visit"`
+}
+
+// VideoPlatformStats はプラットフォームごとの合計
+type VideoPlatformStats struct {
+	Platform   string `json:"platform"`
+	VideoCount int    `json:"video_count"`
+	VisitCount int    `json:"visit_count"`
+	Sessions   int    `json:"sessions"`
+}
+
+// VideoReport は -videos の結果
+type VideoReport struct {
+	Platforms []VideoPlatformStats `json:"platforms"`
+	Videos    []VideoStats         `json:"videos"`
+}
+
+// parseVideoURL は動画プラットフォームの視聴ページのURLから動画を特定する
+// 視聴ページでなければ ok=false を返す
+func parseVideoURL(rawURL string) (VideoRef, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return VideoRef{}, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		// /watch?v=ID, /shorts/ID, /live/ID
+		if u.Path == "/watch" {
+			if id := u.Query().Get("v"); id != "" {
+				return youTubeRef(id), true
+			}
+		}
+		if len(segments) == 2 && (segments[0] == "shorts" || segments[0] == "live") && segments[1] != "" {
+			return youTubeRef(segments[1]), true
+		}
+	case "youtu.be":
+		if len(segments) == 1 && segments[0] != "" {
+			return youTubeRef(segments[0]), true
+		}
+	case "netflix.com":
+		// /watch/ID
+		if len(segments) == 2 && segments[0] == "watch" && segments[1] != "" {
+			return VideoRef{Platform: VideoPlatformNetflix, ID: segments[1], URL: "https://www.netflix.com/watch/" + segments[1]}, true
+		}
+	case "twitch.tv", "m.twitch.tv":
+		// /videos/ID はアーカイブ、/チャンネル名 はライブ配信
+		if len(segments) == 2 && segments[0] == "videos" && segments[1] != "" {
+			return VideoRef{Platform: VideoPlatformTwitch, ID: segments[1], URL: "https://www.twitch.tv/videos/" + segments[1]}, true
+		}
+		if len(segments) == 1 && segments[0] != "" && !twitchReservedPaths[segments[0]] {
+			channel := strings.ToLower(segments[0])
+			return VideoRef{Platform: VideoPlatformTwitch, ID: channel, URL: "https://www.twitch.tv/" + channel}, true
+		}
+	}
+	return VideoRef{}, false
+}
+
+// twitchReservedPaths はチャンネル名ではないTwitchのトップレベルのパス
+var twitchReservedPaths = map[string]bool{
+	"directory": true, "search": true, "settings": true, "subscriptions": true,
+	"downloads": true, "p": true, "inventory": true, "wallet": true, "drops": true,
+}
+
+// youTubeRef はYouTubeの動画IDから正規化したURLを作る
+func youTubeRef(id string) VideoRef {
+	return VideoRef{Platform: VideoPlatformYouTube, ID: id, URL: "https://www.youtube.com/watch?v=" + id}
+}
+
+// trimVideoTitle はタイトル末尾のプラットフォーム名と通知件数の接頭辞を取り除く
+func trimVideoTitle(platform, title string) string {
+	title = titleCounterPrefix.ReplaceAllString(strings.TrimSpace(title), "")
+	for _, suffix := range videoTitleSuffixes[platform] {
+		if t, ok := strings.CutSuffix(title, suffix); ok {
+			return t
+		}
+	}
+	return title
+}
+
+// countSessions は時刻の列（昇順）を videoSessionGap より離れた訪問ごとに区切った数を返す
+func countSessions(times []time.Time) int {
+	sessions := 0
+	for i, t := range times {
+		if i == 0 || t.Sub(times[i-1]) > videoSessionGap {
+			sessions++
+		}
+	}
+	return sessions
+}
+
+// getVideoReport は動画の視聴ページへの訪問を動画ごとに集計する
+// limit は動画の表示件数（0以下なら全件）
+func getVideoReport(db dbQuerier, limit int, filter SearchFilter) (VideoReport, error) {
+	type videoInfo struct {
+		ref    VideoRef
+		title  string
+		times  []time.Time
+		latest time.Time
+	}
+	videos := make(map[string]*videoInfo)
+
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		ref, ok := parseVideoURL(v.URL)
+		if !ok {
+			return nil
+		}
+		key := ref.Platform + "/" + ref.ID
+		info := videos[key]
+		if info == nil {
+			info = &videoInfo{ref: ref}
+			videos[key] = info
+		}
+		info.times = append(info.times, v.VisitTime)
+		// 新しい順に流れてくるので、最初に見つかった空でないタイトルが最新
+		if info.title == "" {
+			title, _ := sanitizeText(v.Title)
+			info.title = trimVideoTitle(ref.Platform, title)
+		}
+		if v.VisitTime.After(info.latest) {
+			info.latest = v.VisitTime
+		}
+		return nil
+	})
+	if err != nil {
+		return VideoReport{}, fmt.Errorf("動画の集計に失敗: %w", err)
+	}
+
+	var report VideoReport
+	platforms := make(map[string]*VideoPlatformStats)
+	for _, info := range videos {
+		sort.Slice(info.times, func(i, j int) bool { return info.times[i].Before(info.times[j]) })
+		stats := VideoStats{
+			Platform:   info.ref.Platform,
+			ID:         info.ref.ID,
+			Title:      info.title,
+			URL:        info.ref.URL,
+			VisitCount: len(info.times),
+			Sessions:   countSessions(info.times),
+			LastVisit:  info.latest,
+		}
+		report.Videos = append(report.Videos, stats)
+
+		p := platforms[stats.Platform]
+		if p == nil {
+			p = &VideoPlatformStats{Platform: stats.Platform}
+			platforms[stats.Platform] = p
+		}
+		p.VideoCount++
+		p.VisitCount += stats.VisitCount
+		p.Sessions += stats.Sessions
+	}
+
+	sort.Slice(report.Videos, func(i, j int) bool {
+		a, b := report.Videos[i], report.Videos[j]
+		if a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		if a.VisitCount != b.VisitCount {
+			return a.VisitCount > b.VisitCount
+		}
+		return a.LastVisit.After(b.LastVisit)
+	})
+	if limit > 0 && len(report.Videos) > limit {
+		report.Videos = report.Videos[:limit]
+	}
+
+	for _, p := range platforms {
+		report.Platforms = append(report.Platforms, *p)
+	}
+	sort.Slice(report.Platforms, func(i, j int) bool {
+		return report.Platforms[i].Sessions > report.Platforms[j].Sessions
+	})
+	return report, nil
+}
+
+// redactVideoReport はリダクト対象のプラットフォームの動画を伏せたコピーを返す
+func redactVideoReport(report *VideoReport, redactDomains []string) *VideoReport {
+	if len(redactDomains) == 0 || report == nil {
+		return report
+	}
+	redacted := *report
+	redacted.Videos = make([]VideoStats, len(report.Videos))
+	for i, v := range report.Videos {
+		if domainMatchesList(extractDomain(v.URL), redactDomains) {
+			v.ID = redactedLabel
+			v.Title = redactedLabel
+			v.URL = redactedLabel
+		}
+		redacted.Videos[i] = v
+	}
+	return &redacted
+}
+
+// printVideoReport は動画の集計をテキスト形式で出力する
+func printVideoReport(w io.Writer, report VideoReport) {
+	_, _ = fmt.Fprintf(w, "🎬 動画の視聴 (Top %d)\n", len(report.Videos))
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	for _, p := range report.Platforms {
+		_, _ = fmt.Fprintf(w, "  %s: %d本 / %d訪問 / 推定%dセッション\n", padRight(p.Platform, 8), p.VideoCount, p.VisitCount, p.Sessions)
+	}
+	if len(report.Platforms) > 0 {
+		_, _ = fmt.Fprintln(w)
+	}
+	for _, v := range report.Videos {
+		title := v.Title
+		if title == "" {
+			title = "(タイトルなし)"
+		}
+		_, _ = fmt.Fprintf(w, "  %3dセッション %3d訪問  [%s] %s\n", v.Sessions, v.VisitCount, v.Platform, truncateText(title, TitleTruncateLength))
+		_, _ = fmt.Fprintf(w, "                         🔗 %s\n", v.URL)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseVideoURL は動画URLの判定のテスト
+func TestParseVideoURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantOK   bool
+		platform string
+		id       string
+	}{
+		{"https://www.youtube.com/watch?v=abc123&t=30s", true, VideoPlatformYouTube, "abc123"},
+		{"https://m.youtube.com/watch?v=abc123", true, VideoPlatformYouTube, "abc123"},
+		{"https://youtu.be/abc123", true, VideoPlatformYouTube, "abc123"},
+		{"https://www.youtube.com/shorts/xyz789", true, VideoPlatformYouTube, "xyz789"},
+		{"https://www.youtube.com/results?search_query=go", false, "", ""},
+		{"https://www.youtube.com/", false, "", ""},
+		{"https://www.netflix.com/watch/80100172?trackId=1", true, VideoPlatformNetflix, "80100172"},
+		{"https://www.netflix.com/browse", false, "", ""},
+		{"https://www.twitch.tv/videos/123456", true, VideoPlatformTwitch, "123456"},
+		{"https://www.twitch.tv/SomeStreamer", true, VideoPlatformTwitch, "somestreamer"},
+		{"https://www.twitch.tv/directory", false, "", ""},
+		{"https://example.com/watch?v=abc", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		ref, ok := parseVideoURL(tt.url)
+		if ok != tt.wantOK {
+			t.Errorf("parseVideoURL(%q) ok = %v, want %v", tt.url, ok, tt.wantOK)
+			continue
+		}
+		if ok && (ref.Platform != tt.platform || ref.ID != tt.id) {
+			t.Errorf("parseVideoURL(%q) = %+v, want %s/%s", tt.url, ref, tt.platform, tt.id)
+		}
+	}
+}
+
+// TestCountSessions は視聴セッション数の推定のテスト
+func TestCountSessions(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		base,
+		base.Add(5 * time.Minute),  // 同じセッション
+		base.Add(20 * time.Minute), // 同じセッション（直前から15分）
+		base.Add(2 * time.Hour),    // 新しいセッション
+	}
+	if got := countSessions(times); got != 2 {
+		t.Errorf("countSessions() = %d, want 2", got)
+	}
+	if got := countSessions(nil); got != 0 {
+		t.Errorf("countSessions(nil) = %d, want 0", got)
+	}
+}
+
+// TestGetVideoReport は動画の集計のテスト
+func TestGetVideoReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	// 2025-01-01 10:00 UTC を基準に、同じ動画を別URLで2セッション視聴
+	base := 757418400.0
+	_, err := db.Exec(`
+		INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES
+		(1, 'https://www.youtube.com/watch?v=abc123', 'youtube', 2),
+		(2, 'https://youtu.be/abc123', 'youtu', 1),
+		(3, 'https://www.netflix.com/watch/42', 'netflix', 1),
+		(4, 'https://example.com', NULL, 1);
+		INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(1, 1, ?, 'Go Tutorial - YouTube'),
+		(2, 1, ?, '(1) Go Tutorial - YouTube'),
+		(3, 2, ?, ''),
+		(4, 3, ?, 'Some Show | Netflix'),
+		(5, 4, ?, 'Example');
+	`, base, base+600, base+86400, base+3600, base+7200)
+	if err != nil {
+		t.Fatalf("テストデータ挿入に失敗: %v", err)
+	}
+
+	report, err := getVideoReport(db, 0, SearchFilter{})
+	if err != nil {
+		t.Fatalf("getVideoReport失敗: %v", err)
+	}
+	if len(report.Videos) != 2 {
+		t.Fatalf("動画数が期待値と異なる: %+v", report.Videos)
+	}
+
+	top := report.Videos[0]
+	if top.ID != "abc123" || top.VisitCount != 3 || top.Sessions != 2 || top.Title != "Go Tutorial" {
+		t.Errorf("YouTube動画の集計が期待値と異なる: %+v", top)
+	}
+	if report.Videos[1].Title != "Some Show" {
+		t.Errorf("Netflixのタイトルが期待値と異なる: %q", report.Videos[1].Title)
+	}
+	if len(report.Platforms) != 2 || report.Platforms[0].Platform != VideoPlatformYouTube || report.Platforms[0].Sessions != 2 {
+		t.Errorf("プラットフォーム別の集計が期待値と異なる: %+v", report.Platforms)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultWatchInterval は hist watch が履歴DBを確かめる間隔
+const defaultWatchInterval = 5 * time.Second
+
+// getNewVisits は lastID より後の訪問を ID の順に返し、次の確認に使う ID を返す
+// 除外した訪問も含めて最大の ID まで進める（履歴の消去で ID が戻った場合は戻った ID から数え直す）
+func getNewVisits(db dbQuerier, lastID int64, filter SearchFilter) ([]HistoryVisit, int64, error) {
+	var maxID int64
+	if err := db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM history_visits`).Scan(&maxID); err != nil {
+		return nil, lastID, fmt.Errorf("訪問IDの取得に失敗: %w", err)
+	}
+	if maxID <= lastID {
+		return nil, maxID, nil
+	}
+	query, args := NewQueryBuilder(historyBaseQuery).
+		WithFilter(filter).
+		WithRawCondition("hv.id > ? AND hv.id <= ?", lastID, maxID).
+		OrderByAsc("hv.id").Build()
+	visits, err := executeHistoryQuery(db, query, args)
+	if err != nil {
+		return nil, lastID, err
+	}
+	return visits, maxID, nil
+}
+
+// Watcher は hist watch の状態（最後に確かめた訪問ID）と出力先
+type Watcher struct {
+	db dbQuerier
+	// mu は設定ファイルの変更で再読み込みされる filter のイグノアリストと redactDomains を守る
+	mu            sync.Mutex
+	filter        SearchFilter
+	redactDomains []string
+	hooks         Hooks
+	out           io.Writer
+	jsonOutput    bool
+	lastID        int64
+}
+
+// newWatcher は今ある訪問より後の訪問を通知する Watcher を作る
+func newWatcher(db dbQuerier, filter SearchFilter, redactDomains []string, hooks Hooks, out io.Writer, jsonOutput bool) (*Watcher, error) {
+	w := &Watcher{db: db, filter: filter, redactDomains: redactDomains, hooks: hooks, out: out, jsonOutput: jsonOutput}
+	if err := db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM history_visits`).Scan(&w.lastID); err != nil {
+		return nil, fmt.Errorf("訪問IDの取得に失敗: %w", err)
+	}
+	return w, nil
+}
+
+// poll は新しい訪問を出力し、1件ごとに on_new_visit のフックを実行して、見つけた訪問の数を返す
+// フックの失敗は警告を出して続ける
+func (w *Watcher) poll() (int, error) {
+	w.mu.Lock()
+	filter, redactDomains := w.filter, w.redactDomains
+	w.mu.Unlock()
+
+	visits, lastID, err := getNewVisits(w.db, w.lastID, filter)
+	if err != nil {
+		return 0, err
+	}
+	w.lastID = lastID
+	visits = redactVisits(visits, redactDomains)
+	for _, v := range visits {
+		if w.jsonOutput {
+			if err := json.NewEncoder(w.out).Encode(v); err != nil {
+				return 0, fmt.Errorf("JSON出力エラー: %w", err)
+			}
+		} else {
+			title := v.Title
+			if title == "" {
+				title = v.URL
+			}
+			_, _ = fmt.Fprintf(w.out, "%s  %-24s %s\n", v.VisitTime.Format(TimeFormatDateTime), truncateText(extractDomain(v.URL), 24), truncateText(title, MaxTitleLength))
+		}
+		if err := w.hooks.run(HookOnNewVisit, NewVisitHookPayload{Event: HookOnNewVisit, Visit: v}); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		}
+	}
+	return len(visits), nil
+}
+
+// reloadConfig はイグノアリスト・リダクトリストを読み込み直す（reloadIgnore・reloadRedact が false のものはそのまま）
+// 読み込みに失敗した場合は以前の設定のまま続ける
+func (w *Watcher) reloadConfig(reloadIgnore, reloadRedact bool) error {
+	var ignoreDomains []string
+	var ignoreRules []IgnoreRule
+	if reloadIgnore {
+		entries, err := LoadIgnoreList()
+		if err != nil {
+			return fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+		}
+		if ignoreDomains, ignoreRules, err = splitIgnoreEntries(entries); err != nil {
+			return err
+		}
+	}
+	var redactDomains []string
+	if reloadRedact {
+		var err error
+		if redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if reloadIgnore {
+		w.filter.IgnoreDomains = ignoreDomains
+		w.filter.IgnoreRules = ignoreRules
+	}
+	if reloadRedact {
+		w.redactDomains = redactDomains
+	}
+	return nil
+}
+
+// WatchConfig は設定ディレクトリを監視し、hist -ignore-add などでリストが変わったら再起動せずに反映する（WebServer.WatchConfig と同じ）
+// -no-ignore・-no-redact で起動した場合はそのリストを監視しない。返り値の関数で監視を止める
+func (w *Watcher) WatchConfig(reloadIgnore, reloadRedact bool) (func() error, error) {
+	var fileNames []string
+	if reloadIgnore {
+		fileNames = append(fileNames, ignoreFileName)
+	}
+	if reloadRedact {
+		fileNames = append(fileNames, redactFileName)
+	}
+	if len(fileNames) == 0 {
+		return func() error { return nil }, nil
+	}
+	return watchConfigDir(fileNames, func() {
+		if err := w.reloadConfig(reloadIgnore, reloadRedact); err != nil {
+			log.Printf("設定の再読み込みに失敗（以前の設定のまま続行）: %v", err)
+			return
+		}
+		log.Printf("設定を再読み込みしました")
+	})
+}
+
+// runWatchCommand は hist watch サブコマンドを実行する
+func runWatchCommand(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", defaultWatchInterval, "履歴DBを確かめる間隔")
+	jsonOutput := fs.Bool("json", false, "新しい訪問を1行1件のJSON（NDJSON）で出力")
+	noHooks := fs.Bool("no-hooks", false, "hooks.txt の on_new_visit を実行しない")
+	noIgnore := fs.Bool("no-ignore", false, "イグノアリストを無視して実行")
+	noRedact := fs.Bool("no-redact", false, "リダクトリストを無視して実行")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist watch [オプション]\n\n")
+		fmt.Fprintf(fs.Output(), "  Safari の履歴DBを監視し、新しい訪問を表示します（Ctrl+C で終了）\n")
+		fmt.Fprintf(fs.Output(), "  ~/.config/hist/%s の on_new_visit のコマンドに訪問を1件ずつ JSON で渡します\n\n", hooksFileName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return errors.New("-interval は0より大きい値で指定してください")
+	}
+
+	var filter SearchFilter
+	if !*noIgnore {
+		entries, err := LoadIgnoreList()
+		if err != nil {
+			return fmt.Errorf("イグノアリストの読み込みに失敗: %w", err)
+		}
+		filter.IgnoreDomains, filter.IgnoreRules, err = splitIgnoreEntries(entries)
+		if err != nil {
+			return err
+		}
+	}
+	var redactDomains []string
+	if !*noRedact {
+		var err error
+		if redactDomains, err = LoadRedactList(); err != nil {
+			return fmt.Errorf("リダクトリストの読み込みに失敗: %w", err)
+		}
+	}
+	var hooks Hooks
+	if !*noHooks {
+		var err error
+		if hooks, err = LoadHooks(); err != nil {
+			return err
+		}
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	watcher, err := newWatcher(db, filter, redactDomains, hooks, os.Stdout, *jsonOutput)
+	if err != nil {
+		return err
+	}
+	// 監視できなくても新しい訪問の表示は続ける
+	if stop, err := watcher.WatchConfig(!*noIgnore, !*noRedact); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 設定ファイルの変更を反映できません: %v\n", err)
+	} else {
+		defer func() { _ = stop() }()
+	}
+	if !*jsonOutput {
+		fmt.Fprintf(os.Stderr, "新しい訪問を監視しています（%s ごと、Ctrl+C で終了）\n", *interval)
+	}
+	for {
+		time.Sleep(*interval)
+		// Safari の書き込み中で読めない場合などは次の確認でやり直す
+		if _, err := watcher.poll(); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	log := filepath.Join(t.TempDir(), "visits.log")
+	hooks := Hooks{HookOnNewVisit: {"cat >> " + log + " && echo >> " + log}}
+	var out bytes.Buffer
+	filter := SearchFilter{IgnoreDomains: []string{"google"}}
+	watcher, err := newWatcher(db, filter, []string{"youtube.com"}, hooks, &out, false)
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+
+	// 監視を始める前の訪問は通知しない
+	if n, err := watcher.poll(); err != nil || n != 0 || out.Len() != 0 {
+		t.Fatalf("最初の poll() = %d, %v, 出力 %q", n, err, out.String())
+	}
+
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES
+		(6, 1, 757598400, 'GitHub - New'), (7, 3, 757598410, 'Google Search'), (8, 2, 757598420, 'YouTube - New')`); err != nil {
+		t.Fatal(err)
+	}
+	n, err := watcher.poll()
+	if err != nil || n != 2 {
+		t.Fatalf("poll() = %d, %v, want 除外ドメイン以外の2件", n, err)
+	}
+	if s := out.String(); !strings.Contains(s, "GitHub - New") || strings.Contains(s, "Google") || strings.Contains(s, "YouTube - New") {
+		t.Errorf("出力 = %q", s)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("フックの実行回数 = %d, want 2", len(lines))
+	}
+	var payload NewVisitHookPayload
+	if err := json.Unmarshal([]byte(lines[1]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != HookOnNewVisit || payload.Visit.ID != 8 || strings.Contains(payload.Visit.URL, "youtube") {
+		t.Errorf("2件目のフックの内容 = %+v, want リダクトした訪問8", payload)
+	}
+
+	if n, err := watcher.poll(); err != nil || n != 0 {
+		t.Errorf("新しい訪問がない poll() = %d, %v", n, err)
+	}
+
+	// 履歴を消去して ID が戻っても、その後の訪問を通知する
+	if _, err := db.Exec(`DELETE FROM history_visits WHERE id > 5`); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := watcher.poll(); err != nil || n != 0 || watcher.lastID != 5 {
+		t.Errorf("消去後の poll() = %d, %v, lastID %d", n, err, watcher.lastID)
+	}
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 4, 757598500, 'Example')`); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := watcher.poll(); err != nil || n != 1 {
+		t.Errorf("消去後の新しい訪問の poll() = %d, %v, want 1", n, err)
+	}
+}
+
+func TestWatcherPollJSON(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+	var out bytes.Buffer
+	watcher, err := newWatcher(db, SearchFilter{}, nil, nil, &out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (6, 1, 757598400, 'GitHub - New')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.poll(); err != nil {
+		t.Fatal(err)
+	}
+	var v HistoryVisit
+	if err := json.Unmarshal(out.Bytes(), &v); err != nil || v.ID != 6 || v.Title != "GitHub - New" {
+		t.Errorf("NDJSON の出力 = %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// waybackAvailabilityURL は Internet Archive の Wayback Machine の可用性API
+	waybackAvailabilityURL = "https://archive.org/wayback/available"
+	// waybackCacheFileName は可用性APIの結果のキャッシュ（キャッシュディレクトリに置く）
+	waybackCacheFileName = "wayback.json"
+	// waybackTimestampFormat は Wayback Machine のタイムスタンプの形式（UTC）
+	waybackTimestampFormat = "20060102150405"
+	// WaybackTimeout は可用性APIへの問い合わせを打ち切るまでの時間
+	WaybackTimeout = 15 * time.Second
+	// WaybackCacheTTL はスナップショットが見つかった結果をキャッシュする期間
+	WaybackCacheTTL = 30 * 24 * time.Hour
+	// WaybackMissingTTL はスナップショットがなかった結果をキャッシュする期間
+	WaybackMissingTTL = 7 * 24 * time.Hour
+)
+
+// WaybackSnapshot は Wayback Machine のスナップショット
+type WaybackSnapshot struct {
+	URL       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// waybackAvailability は可用性APIのレスポンス
+type waybackAvailability struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// waybackCacheEntry はキャッシュした問い合わせ結果（Snapshot が nil ならスナップショットなし）
+type waybackCacheEntry struct {
+	Snapshot  *WaybackSnapshot `json:"snapshot,omitempty"`
+	CheckedAt time.Time        `json:"checked_at"`
+}
+
+// WaybackClient は URL と日時に最も近いスナップショットを可用性APIで探し、結果をキャッシュする
+type WaybackClient struct {
+	client   *http.Client
+	endpoint string
+	// cachePath はキャッシュのファイル（空ならキャッシュしない）
+	cachePath string
+	cache     map[string]waybackCacheEntry
+	dirty     bool
+}
+
+// newWaybackClient は ~/.cache/hist/wayback.json にキャッシュする WaybackClient を作る
+func newWaybackClient() (*WaybackClient, error) {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return openWaybackClient(filepath.Join(cacheDir, waybackCacheFileName))
+}
+
+// openWaybackClient は cachePath のキャッシュを読み込んだ WaybackClient を作る（ファイルがなければ空のキャッシュ）
+func openWaybackClient(cachePath string) (*WaybackClient, error) {
+	c := &WaybackClient{
+		client:    &http.Client{Timeout: WaybackTimeout},
+		endpoint:  waybackAvailabilityURL,
+		cachePath: cachePath,
+		cache:     make(map[string]waybackCacheEntry),
+	}
+	data, err := os.ReadFile(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Wayback のキャッシュの読み込みに失敗: %w", err)
+	}
+	// 壊れたキャッシュは捨てて問い合わせ直す
+	if err := json.Unmarshal(data, &c.cache); err != nil {
+		c.cache = make(map[string]waybackCacheEntry)
+	}
+	return c, nil
+}
+
+// waybackCacheKey はキャッシュのキー（日時は日単位にまとめる）
+func waybackCacheKey(rawURL string, at time.Time) string {
+	return at.UTC().Format(TimeFormatDate) + " " + rawURL
+}
+
+// Lookup は at に最も近い rawURL のスナップショットを返す（なければ nil）
+func (c *WaybackClient) Lookup(ctx context.Context, rawURL string, at time.Time) (*WaybackSnapshot, error) {
+	key := waybackCacheKey(rawURL, at)
+	if entry, ok := c.cache[key]; ok {
+		ttl := WaybackCacheTTL
+		if entry.Snapshot == nil {
+			ttl = WaybackMissingTTL
+		}
+		if time.Since(entry.CheckedAt) < ttl {
+			return entry.Snapshot, nil
+		}
+	}
+
+	snapshot, err := c.fetch(ctx, rawURL, at)
+	if err != nil {
+		return nil, err
+	}
+	c.cache[key] = waybackCacheEntry{Snapshot: snapshot, CheckedAt: time.Now()}
+	c.dirty = true
+	return snapshot, nil
+}
+
+// fetch は可用性APIに問い合わせる
+func (c *WaybackClient) fetch(ctx context.Context, rawURL string, at time.Time) (*WaybackSnapshot, error) {
+	params := url.Values{"url": {rawURL}}
+	if !at.IsZero() {
+		params.Set("timestamp", at.UTC().Format(waybackTimestampFormat))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "hist/"+toolVersion())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Wayback Machine への問い合わせに失敗: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Wayback Machine への問い合わせに失敗: %s", resp.Status)
+	}
+
+	var availability waybackAvailability
+	if err := json.NewDecoder(resp.Body).Decode(&availability); err != nil {
+		return nil, fmt.Errorf("Wayback Machine の応答の解析に失敗: %w", err)
+	}
+	closest := availability.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available || closest.URL == "" {
+		return nil, nil
+	}
+	snapshot := &WaybackSnapshot{URL: closest.URL}
+	if t, err := time.Parse(waybackTimestampFormat, closest.Timestamp); err == nil {
+		snapshot.Timestamp = t
+	}
+	return snapshot, nil
+}
+
+// Save は問い合わせた結果をキャッシュに書き出す（変更がなければ何もしない）
+func (c *WaybackClient) Save() error {
+	if !c.dirty || c.cachePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cachePath), configDirPerms); err != nil {
+		return fmt.Errorf("キャッシュディレクトリの作成に失敗: %w", err)
+	}
+	if err := os.WriteFile(c.cachePath, data, configFilePerms); err != nil {
+		return fmt.Errorf("Wayback のキャッシュの書き込みに失敗: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// latestVisit は履歴で rawURL を最後に訪問したときの訪問を返す（訪問がなければ false）
+func latestVisit(db dbQuerier, rawURL string) (HistoryVisit, bool, error) {
+	v := HistoryVisit{URL: rawURL}
+	var ts float64
+	err := db.QueryRow(`
+		SELECT hv.id, COALESCE(hv.title, ''), COALESCE(hi.domain_expansion, ''), hv.visit_time FROM history_visits hv
+		JOIN history_items hi ON hv.history_item = hi.id
+		WHERE hi.url = ? ORDER BY hv.visit_time DESC LIMIT 1`, rawURL).Scan(&v.ID, &v.Title, &v.Domain, &ts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, fmt.Errorf("最後の訪問の取得に失敗: %w", err)
+	}
+	v.VisitTime = convertCoreDataTimestamp(ts)
+	return v, true, nil
+}
+
+// WaybackResult は hist wayback の結果
+type WaybackResult struct {
+	URL       string           `json:"url"`
+	LastVisit *time.Time       `json:"last_visit,omitempty"`
+	Snapshot  *WaybackSnapshot `json:"snapshot"`
+}
+
+// printWaybackResult は hist wayback の結果を出力する
+func printWaybackResult(w io.Writer, result WaybackResult) {
+	if result.LastVisit != nil {
+		_, _ = fmt.Fprintf(w, "最後の訪問: %s\n", result.LastVisit.Local().Format(TimeFormatDateTime))
+	}
+	if result.Snapshot == nil {
+		_, _ = fmt.Fprintf(w, "Wayback Machine にスナップショットがありません: %s\n", result.URL)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "スナップショット: %s\n", result.Snapshot.Timestamp.Local().Format(TimeFormatDateTime))
+	_, _ = fmt.Fprintln(w, result.Snapshot.URL)
+}
+
+// runWaybackCommand は hist wayback サブコマンドを実行する
+func runWaybackCommand(args []string) error {
+	fs := flag.NewFlagSet("wayback", flag.ContinueOnError)
+	at := fs.String("at", "", "この日（YYYY-MM-DD）に最も近いスナップショットを探す（既定は最後に訪問した日時）")
+	jsonOutput := fs.Bool("json", false, "JSON形式で出力")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "使い方: hist wayback [オプション] <URL>\n\n")
+		fmt.Fprintf(fs.Output(), "  Wayback Machine（Internet Archive）で、最後に訪問した頃に最も近いスナップショットを探します\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("URL を1つ指定してください")
+	}
+	result := WaybackResult{URL: fs.Arg(0)}
+
+	var when time.Time
+	if *at != "" {
+		t, err := parseLocalDate(*at)
+		if err != nil {
+			return fmt.Errorf("-at の形式が不正です（YYYY-MM-DD）: %s", *at)
+		}
+		when = t
+	} else if db, err := setupDatabase(); err == nil {
+		// 履歴DBを読めない環境でも、最新のスナップショットは探せる
+		if v, ok, err := latestVisit(db, result.URL); err == nil && ok {
+			when = v.VisitTime
+			result.LastVisit = &v.VisitTime
+		}
+		_ = db.Close()
+	}
+
+	client, err := newWaybackClient()
+	if err != nil {
+		return err
+	}
+	result.Snapshot, err = client.Lookup(context.Background(), result.URL, when)
+	if err != nil {
+		return err
+	}
+	if err := client.Save(); err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	printWaybackResult(os.Stdout, result)
+	return nil
+}
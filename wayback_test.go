@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestWaybackClient は可用性APIをテスト用のサーバーに向けた WaybackClient とリクエスト数を返す
+// https://example.com/old だけスナップショットがある
+func newTestWaybackClient(t *testing.T, cachePath string) (*WaybackClient, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("url") != "https://example.com/old" {
+			_, _ = w.Write([]byte(`{"url": "x", "archived_snapshots": {}}`))
+			return
+		}
+		if got := r.URL.Query().Get("timestamp"); got != "20220301120000" {
+			t.Errorf("timestamp = %q, want 20220301120000", got)
+		}
+		_, _ = w.Write([]byte(`{"archived_snapshots": {"closest": {"status": "200", "available": true,
+			"url": "http://web.archive.org/web/20220228093000/https://example.com/old", "timestamp": "20220228093000"}}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := openWaybackClient(cachePath)
+	if err != nil {
+		t.Fatalf("openWaybackClient() error = %v", err)
+	}
+	client.endpoint = ts.URL
+	return client, &requests
+}
+
+func TestWaybackLookup(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "wayback.json")
+	client, requests := newTestWaybackClient(t, cachePath)
+	ctx := context.Background()
+	at := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for range 2 {
+		snapshot, err := client.Lookup(ctx, "https://example.com/old", at)
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if snapshot == nil || snapshot.URL != "http://web.archive.org/web/20220228093000/https://example.com/old" ||
+			!snapshot.Timestamp.Equal(time.Date(2022, 2, 28, 9, 30, 0, 0, time.UTC)) {
+			t.Errorf("Lookup() = %+v", snapshot)
+		}
+	}
+	snapshot, err := client.Lookup(ctx, "https://example.com/never", at)
+	if err != nil || snapshot != nil {
+		t.Errorf("スナップショットがないURLで %+v, %v", snapshot, err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("リクエスト = %d回, want 2（2回目はキャッシュ）", n)
+	}
+
+	// 書き出したキャッシュは次の実行でも使う
+	if err := client.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	reopened, requests := newTestWaybackClient(t, cachePath)
+	for _, u := range []string{"https://example.com/old", "https://example.com/never"} {
+		if _, err := reopened.Lookup(ctx, u, at); err != nil {
+			t.Fatalf("Lookup(%s) error = %v", u, err)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("キャッシュがあるのに %d回問い合わせた", n)
+	}
+}
+
+func TestAddWaybackSnapshots(t *testing.T) {
+	client, requests := newTestWaybackClient(t, filepath.Join(t.TempDir(), "wayback.json"))
+	visited := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	links := []LinkCheckResult{
+		{URL: "https://example.com/old", LastVisit: visited, Status: LinkStatusDead},
+		{URL: "https://example.com/ok", LastVisit: visited, Status: LinkStatusOK},
+		{URL: "https://example.com/gone", LastVisit: visited, Status: LinkStatusDead},
+	}
+	if err := addWaybackSnapshots(context.Background(), client, links, time.Millisecond); err != nil {
+		t.Fatalf("addWaybackSnapshots() error = %v", err)
+	}
+	if links[0].Wayback == nil || links[1].Wayback != nil || links[2].Wayback != nil {
+		t.Errorf("Wayback = %v, %v, %v", links[0].Wayback, links[1].Wayback, links[2].Wayback)
+	}
+	// リンク切れのURLだけを問い合わせる
+	if n := requests.Load(); n != 2 {
+		t.Errorf("リクエスト = %d回, want 2", n)
+	}
+}
+
+func TestLatestVisit(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	v, ok, err := latestVisit(db, "https://github.com/test")
+	if err != nil || !ok {
+		t.Fatalf("latestVisit() = %v, %v", ok, err)
+	}
+	if want := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC); !v.VisitTime.Equal(want) || v.Title != "GitHub - Another Page" {
+		t.Errorf("latestVisit() = %+v, want %v の GitHub - Another Page", v, want)
+	}
+	if _, ok, err := latestVisit(db, "https://example.com/never"); err != nil || ok {
+		t.Errorf("訪問のないURLで %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * hist Web UI のキーボード操作
+ * data-url を付けた行（tr[data-url]）を j・k で選び、o で開き、Enter で詳細を表示する。/ で検索欄に移動する。
+ * キーは /api/keybindings から読み込む（~/.config/hist/keys.txt で変更できる）。
+ * 入力欄にフォーカスがあるときと、詳細（#detailModal）を表示しているときは何もしない。
+ */
+(function() {
+    let selected = -1;
+
+    function rows() {
+        return Array.from(document.querySelectorAll('tr[data-url]'));
+    }
+
+    function select(index) {
+        const list = rows();
+        if (list.length === 0) return;
+        selected = Math.max(0, Math.min(index, list.length - 1));
+        list.forEach((row, i) => {
+            row.classList.toggle('kbd-selected', i === selected);
+            row.setAttribute('aria-selected', i === selected ? 'true' : 'false');
+        });
+        list[selected].scrollIntoView({block: 'nearest'});
+    }
+
+    function current() {
+        return selected < 0 ? null : rows()[selected];
+    }
+
+    const actions = {
+        next: () => select(selected + 1),
+        prev: () => select(selected < 0 ? 0 : selected - 1),
+        search: () => {
+            const input = document.getElementById('search');
+            if (!input) return;
+            input.focus();
+            input.select();
+        },
+        open: () => {
+            const row = current();
+            // リダクトしたURLや file: などは開かない
+            if (row && /^https?:/.test(row.dataset.url)) {
+                window.open(row.dataset.url, '_blank', 'noopener,noreferrer');
+            }
+        },
+        detail: () => {
+            const row = current();
+            if (row) row.click();
+        },
+    };
+
+    function modalOpen() {
+        const modal = document.getElementById('detailModal');
+        return modal && !modal.classList.contains('hidden');
+    }
+
+    fetch('/api/keybindings')
+        .then(res => res.ok ? res.json() : [])
+        .then(bindings => {
+            const keys = new Map(bindings.map(b => [b.key, b.action]));
+            document.addEventListener('keydown', function(e) {
+                if (e.ctrlKey || e.metaKey || e.altKey || e.isComposing) return;
+                if (e.target.closest && e.target.closest('input, textarea, select, [contenteditable="true"]')) return;
+                if (modalOpen()) return;
+                const action = actions[keys.get(e.key)];
+                if (!action) return;
+                e.preventDefault();
+                action();
+            });
+            const hints = document.getElementById('keyHints');
+            if (hints) {
+                hints.textContent = bindings.map(b => b.key + ' ' + b.description).join(' · ');
+            }
+        })
+        .catch(() => {});
+})();
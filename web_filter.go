@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// requestFilter はリクエストのクエリパラメータからフィルタを組み立てる
+// 全てのページ・APIで同じパラメータを受け付ける:
+//
+//	search     キーワード（URL・タイトル）
+//	fold_kana  1 なら search でひらがなとカタカナを区別しない
+//	romaji     1 ならローマ字の search をかな・読みの辞書の語にも広げる
+//	domain     ドメイン（カンマ区切りで複数指定可）
+//	from, to   期間（YYYY-MM-DD）
+//	title_lang タイトルの言語（ja, en など）
+//	tag        hist tag で付けたタグ
+//	starred    1 ならスターを付けたページだけ
+//	no_ignore  1 ならイグノアリストを適用しない
+//	ignore     このリクエストだけ追加で除外するドメイン（カンマ区切り）
+func (s *WebServer) requestFilter(r *http.Request) (SearchFilter, error) {
+	q := r.URL.Query()
+	filter := s.baseFilter()
+	noIgnore, extra := ignoreOverride(r)
+	if noIgnore {
+		filter = SearchFilter{Index: filter.Index}
+	}
+	if len(extra) > 0 {
+		// baseFilter のスライスを書き換えないようコピーしてから足す
+		filter.IgnoreDomains = append(append([]string(nil), filter.IgnoreDomains...), extra...)
+	}
+
+	filter.Keyword = q.Get("search")
+	filter.FoldKana = q.Get("fold_kana") == "1"
+	filter.Romaji = q.Get("romaji") == "1"
+	if domain := q.Get("domain"); strings.Contains(domain, ",") {
+		for _, d := range strings.Split(domain, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				filter.Domains = append(filter.Domains, d)
+			}
+		}
+	} else {
+		filter.Domain = domain
+	}
+
+	if from := q.Get("from"); from != "" {
+		t, err := parseLocalDate(from)
+		if err != nil {
+			return filter, fmt.Errorf("開始日の形式が不正です（YYYY-MM-DD）: %s", from)
+		}
+		filter.From = t
+	}
+	if lang := q.Get("title_lang"); lang != "" {
+		l, err := parseTitleLang(lang)
+		if err != nil {
+			return filter, err
+		}
+		filter.TitleLang = l
+	}
+	if tag := q.Get("tag"); tag != "" {
+		match, err := loadTagMatch(s.sidecarPath, tag)
+		if err != nil {
+			return filter, err
+		}
+		filter.Tag = match
+	}
+	if q.Get("starred") == "1" {
+		match, err := loadStarredMatch(s.sidecarPath)
+		if err != nil {
+			return filter, err
+		}
+		filter.Starred = match
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := parseLocalDate(to)
+		if err != nil {
+			return filter, fmt.Errorf("終了日の形式が不正です（YYYY-MM-DD）: %s", to)
+		}
+		filter.To = t
+	}
+	return filter, nil
+}
+
+// ignoreOverride はリクエストのイグノアリストの上書き（no_ignore・ignore）を返す
+func ignoreOverride(r *http.Request) (noIgnore bool, extra []string) {
+	q := r.URL.Query()
+	for _, d := range strings.Split(q.Get("ignore"), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			extra = append(extra, d)
+		}
+	}
+	return q.Get("no_ignore") == "1", extra
+}
+
+// narrowsVisits はフィルタが訪問単位の絞り込み（キーワード・ドメイン・期間・一致リスト・タグ・スター・タイトルの言語）を含むか
+// history_items.visit_count は期間などで絞れないため、含む場合は訪問を数えて集計する
+func (f SearchFilter) narrowsVisits() bool {
+	return f.Keyword != "" || f.Domain != "" || len(f.Domains) > 0 ||
+		!f.From.IsZero() || !f.To.IsZero() || f.Match != nil || f.Tag != nil || f.Starred != nil || f.TitleLang != ""
+}
+
+// getVisitDomainStats はフィルタに一致する訪問をドメインごとに数えた統計を返す
+// getDomainStats と違い、期間やキーワードでの絞り込みが反映される
+func getVisitDomainStats(db dbQuerier, limit int, filter SearchFilter) ([]DomainStats, error) {
+	counts := make(map[string]int)
+	err := streamRecentVisits(db, 0, filter, func(v HistoryVisit) error {
+		domain := extractDomain(v.URL)
+		if domain == "" {
+			domain = "不明"
+		}
+		counts[domain]++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ドメイン統計の取得に失敗: %w", err)
+	}
+
+	var stats []DomainStats
+	for domain, count := range counts {
+		stats = append(stats, DomainStats{Domain: domain, VisitCount: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].VisitCount != stats[j].VisitCount {
+			return stats[i].VisitCount > stats[j].VisitCount
+		}
+		return stats[i].Domain < stats[j].Domain
+	})
+	setDomainShares(stats)
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// ドメイン一覧をフィルタ付きで取得するクエリ
+const filteredDomainsBaseQuery = `
+	SELECT DISTINCT hi.domain_expansion
+	FROM history_visits hv
+	JOIN history_items hi ON hv.history_item = hi.id
+	WHERE hi.domain_expansion IS NOT NULL AND hi.domain_expansion != ''`
+
+// getFilteredDomains はフィルタに一致する訪問があるドメインの一覧を返す
+func getFilteredDomains(db dbQuerier, filter SearchFilter) ([]string, error) {
+	query, args := NewQueryBuilder(filteredDomainsBaseQuery).
+		WithFilter(filter).
+		OrderByAsc("hi.domain_expansion").
+		Build()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ドメイン一覧の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		if shouldIgnoreDomain(domain, filter.IgnoreDomains) {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// getVisitPathStatsByDomain はフィルタに一致するベースドメイン配下の訪問をパスごとに数える
+// 返り値は getPathStatsByDomain と同じく、パス統計・総訪問数・上位以外の訪問数
+func getVisitPathStatsByDomain(db dbQuerier, domain string, pathLimit int, filter SearchFilter) ([]PathStats, int, int, error) {
+	visits, err := getRecentVisitsByBaseDomain(db, domain, 0, filter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// 訪問は新しい順なので、最初に出てきたタイトルがそのパスの最新のタイトル
+	index := make(map[string]int)
+	var paths []PathStats
+	for _, v := range visits {
+		path := extractPath(v.URL)
+		if i, ok := index[path]; ok {
+			paths[i].VisitCount++
+			continue
+		}
+		index[path] = len(paths)
+		paths = append(paths, PathStats{Path: path, Title: v.Title, VisitCount: 1})
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].VisitCount > paths[j].VisitCount
+	})
+
+	other := 0
+	if pathLimit > 0 && len(paths) > pathLimit {
+		for _, p := range paths[pathLimit:] {
+			other += p.VisitCount
+		}
+		paths = paths[:pathLimit]
+	}
+	return paths, len(visits), other, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestFilter(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	s := &WebServer{ignoreDomains: []string{"youtube.com"}}
+
+	tests := []struct {
+		name    string
+		url     string
+		want    SearchFilter
+		wantErr bool
+	}{
+		{"指定なし", "/api/stats", SearchFilter{IgnoreDomains: []string{"youtube.com"}}, false},
+		{"全て指定", "/api/history?search=go&domain=github.com&from=2025-01-01&to=2025-01-31", SearchFilter{
+			Keyword:       "go",
+			Domain:        "github.com",
+			From:          time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:            time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+			IgnoreDomains: []string{"youtube.com"},
+		}, false},
+		{"複数ドメイン", "/api/stats?domain=github.com,+google.com", SearchFilter{
+			Domains:       []string{"github.com", "google.com"},
+			IgnoreDomains: []string{"youtube.com"},
+		}, false},
+		{"イグノアリストを無効化", "/api/stats?no_ignore=1", SearchFilter{}, false},
+		{"一時的に除外", "/api/stats?ignore=News.example.com,+,ads.example.com", SearchFilter{
+			IgnoreDomains: []string{"youtube.com", "news.example.com", "ads.example.com"},
+		}, false},
+		{"無効化して一時的に除外", "/api/stats?no_ignore=1&ignore=ads.example.com", SearchFilter{
+			IgnoreDomains: []string{"ads.example.com"},
+		}, false},
+		{"不正な開始日", "/api/stats?from=2025/01/01", SearchFilter{}, true},
+		{"不正な終了日", "/api/stats?to=yesterday", SearchFilter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.requestFilter(httptest.NewRequest("GET", tt.url, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("requestFilter(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Keyword != tt.want.Keyword || got.Domain != tt.want.Domain ||
+				!got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) ||
+				len(got.Domains) != len(tt.want.Domains) || len(got.IgnoreDomains) != len(tt.want.IgnoreDomains) {
+				t.Errorf("requestFilter(%q) = %+v, want %+v", tt.url, got, tt.want)
+			}
+			for i := range tt.want.Domains {
+				if got.Domains[i] != tt.want.Domains[i] {
+					t.Errorf("Domains = %v, want %v", got.Domains, tt.want.Domains)
+				}
+			}
+			for i := range tt.want.IgnoreDomains {
+				if got.IgnoreDomains[i] != tt.want.IgnoreDomains[i] {
+					t.Errorf("IgnoreDomains = %v, want %v", got.IgnoreDomains, tt.want.IgnoreDomains)
+				}
+			}
+		})
+	}
+
+	// リクエストごとの除外がサーバーのイグノアリストに残らない
+	if _, err := s.requestFilter(httptest.NewRequest("GET", "/?ignore=a.example.com", nil)); err != nil {
+		t.Fatalf("requestFilter失敗: %v", err)
+	}
+	if len(s.ignoreDomains) != 1 {
+		t.Errorf("サーバーのイグノアリストが変更された: %v", s.ignoreDomains)
+	}
+}
+
+// TestDashboardIgnoreOverride はダッシュボードでイグノアリストを一時的に上書きできることを確かめる
+func TestDashboardIgnoreOverride(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatalf("テンプレートの解析に失敗: %v", err)
+	}
+	s := &WebServer{db: newPreparedDB(db), templates: tmpl, ignoreDomains: []string{"youtube"}}
+	defer func() { _ = s.db.Close() }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleDashboard)
+	mux.HandleFunc("GET /api/dashboard/{panel}", s.handleAPIDashboardPanel)
+	render := func(url string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: ステータスが期待値と異なる: got %d", url, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	// パネルはページと同じクエリ文字列で読み込まれる
+	if body := render("/api/dashboard/recent"); strings.Contains(body, "YouTube - Music") {
+		t.Error("イグノアリストのドメインが表示されている")
+	}
+	body := render("/api/dashboard/recent?no_ignore=1&ignore=github")
+	if !strings.Contains(body, "YouTube - Music") {
+		t.Error("no_ignore=1 でイグノアリストのドメインが表示されない")
+	}
+	if strings.Contains(body, "GitHub - Another Page") {
+		t.Error("ignore で指定したドメインが表示されている")
+	}
+	body = render("/?no_ignore=1&ignore=github")
+	if !strings.Contains(body, `value="github"`) || !strings.Contains(body, "checked") {
+		t.Error("フォームに現在の上書きが反映されていない")
+	}
+}
+
+func TestGetVisitDomainStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	// 2025-01-02 は github と youtube に1回ずつ
+	stats, err := getVisitDomainStats(db, 0, SearchFilter{From: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("getVisitDomainStats失敗: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Domain != "github.com" || stats[0].VisitCount != 1 || stats[0].Percentage != 50 {
+		t.Errorf("getVisitDomainStats() = %+v", stats)
+	}
+}
+
+func TestGetFilteredDomains(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	domains, err := getFilteredDomains(db, SearchFilter{Keyword: "YouTube"})
+	if err != nil {
+		t.Fatalf("getFilteredDomains失敗: %v", err)
+	}
+	if len(domains) != 1 || domains[0] != "youtube" {
+		t.Errorf("getFilteredDomains() = %v", domains)
+	}
+}
+
+func TestGetVisitPathStatsByDomain(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	paths, total, other, err := getVisitPathStatsByDomain(db, "github.com", 5, SearchFilter{To: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("getVisitPathStatsByDomain失敗: %v", err)
+	}
+	if total != 1 || other != 0 || len(paths) != 1 || paths[0].Path != "/test" || paths[0].Title != "GitHub - Test Repo" {
+		t.Errorf("getVisitPathStatsByDomain() = %+v, total %d, other %d", paths, total, other)
+	}
+}
+
+// TestAPIFilterParity は全てのAPIが同じ絞り込みパラメータを受け付けることを確かめる
+func TestAPIFilterParity(t *testing.T) {
+	setLocalZone(t, time.UTC)
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	insertTestData(t, db)
+
+	s := &WebServer{db: newPreparedDB(db)}
+	defer func() { _ = s.db.Close() }()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/stats", s.handleAPIStats)
+	mux.HandleFunc("/api/stats/hourly", s.handleAPIStatsHourly)
+	mux.HandleFunc("/api/stats/daily", s.handleAPIStatsDaily)
+	mux.HandleFunc("/api/stats/profiles", s.handleAPIStatsProfiles)
+	mux.HandleFunc("/api/history", s.handleAPIHistory)
+	mux.HandleFunc("/api/domains", s.handleAPIDomains)
+	mux.HandleFunc("GET /api/domains/{domain}/paths", s.handleAPIDomainPaths)
+
+	get := func(url string, v any) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s: JSONのデコードに失敗: %v", url, err)
+			}
+		}
+		return rec.Code
+	}
+
+	const since = "from=2025-01-02"
+
+	var stats AnalysisResult
+	get("/api/stats?"+since, &stats)
+	if stats.TotalVisits != 2 || len(stats.DomainStats) != 2 {
+		t.Errorf("/api/stats = total %d, domains %+v", stats.TotalVisits, stats.DomainStats)
+	}
+
+	var hourly []HourlyStats
+	get("/api/stats/hourly?"+since+"&search=GitHub", &hourly)
+	if hourly[10].VisitCount != 1 || hourly[11].VisitCount != 0 {
+		t.Errorf("/api/stats/hourly = %+v", hourly)
+	}
+
+	var daily []DailyStats
+	get("/api/stats/daily?"+since, &daily)
+	if len(daily) != 1 || daily[0].Date != "2025-01-02" {
+		t.Errorf("/api/stats/daily = %+v", daily)
+	}
+
+	var profiles HourlyProfilesResponse
+	get("/api/stats/profiles?domain=youtube.com", &profiles)
+	if len(profiles.Profiles) != 1 || profiles.Profiles[0].Domain != "youtube.com" {
+		t.Errorf("/api/stats/profiles = %+v", profiles.Profiles)
+	}
+
+	var visits []HistoryVisit
+	get("/api/history?search=Google", &visits)
+	if len(visits) != 1 || visits[0].ID != 3 {
+		t.Errorf("/api/history = %+v", visits)
+	}
+
+	var domains []string
+	get("/api/domains?"+since, &domains)
+	if len(domains) != 2 {
+		t.Errorf("/api/domains = %v", domains)
+	}
+
+	var drilldown DomainDrilldown
+	get("/api/domains/github.com/paths?"+since, &drilldown)
+	if drilldown.TotalCount != 1 || len(drilldown.RecentVisits) != 1 || drilldown.RecentVisits[0].ID != 4 {
+		t.Errorf("/api/domains/github.com/paths = %+v", drilldown)
+	}
+
+	for _, url := range []string{"/api/stats", "/api/history", "/api/domains", "/api/stats/daily"} {
+		if code := get(url+"?from=bad", &struct{}{}); code != http.StatusBadRequest {
+			t.Errorf("%s?from=bad のステータスが期待値と異なる: got %d", url, code)
+		}
+	}
+}
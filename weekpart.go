@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// HourlySplitWeekpart は時間帯別統計を平日・週末に分けるときの -split の値
+const HourlySplitWeekpart = "weekpart"
+
+// weekpartBarWidth は平日・週末を並べて表示するときの棒グラフの幅
+const weekpartBarWidth = BarChartWidth / 2
+
+// HourlyAverage は1日あたりの時間帯別平均訪問数
+type HourlyAverage struct {
+	Hour       int     `json:"hour"`
+	Average    float64 `json:"average"`
+	VisitCount int     `json:"visit_count"`
+}
+
+// HourlySplitStats は平日・週末それぞれの時間帯別平均訪問数
+// 平均は対象期間（最初の訪問日〜最後の訪問日）に含まれる平日・週末の日数で割った値
+// holidays.txt で設定した休日は週末として数え、HolidayDays はそのうち土日以外の日数
+type HourlySplitStats struct {
+	WeekdayDays int             `json:"weekday_days"`
+	WeekendDays int             `json:"weekend_days"`
+	HolidayDays int             `json:"holiday_days,omitempty"`
+	Weekday     []HourlyAverage `json:"weekday"`
+	Weekend     []HourlyAverage `json:"weekend"`
+}
+
+// isWeekend は土曜・日曜かどうかを返す
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// countWeekparts は from〜to（日付単位、両端を含む）の平日・週末の日数を数える
+// 休日は週末に数え、そのうち土日以外の日数を dayOffs として返す
+func countWeekparts(from, to time.Time, holidays *Holidays) (weekdays, weekends, dayOffs int) {
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, to.Location())
+	for !day.After(last) {
+		switch {
+		case isWeekend(day):
+			weekends++
+		case holidays.isDayOff(day):
+			weekends++
+			dayOffs++
+		default:
+			weekdays++
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return weekdays, weekends, dayOffs
+}
+
+// getHourlySplitStats は平日・週末（休日を含む）に分けた時間帯別の平均訪問数を取得
+func getHourlySplitStats(db dbQuerier, filter SearchFilter, holidays *Holidays) (HourlySplitStats, error) {
+	var stats HourlySplitStats
+	query, args := buildVisitTimeQuery(filter)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return stats, fmt.Errorf("時間帯統計の取得に失敗: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var weekdayCounts, weekendCounts [24]int
+	var first, last time.Time
+	for rows.Next() {
+		var visitTime float64
+		if err := rows.Scan(&visitTime); err != nil {
+			return stats, fmt.Errorf("行の読み取りに失敗: %w", err)
+		}
+		t := convertCoreDataTimestamp(visitTime)
+		if holidays.isDayOff(t) {
+			weekendCounts[t.Hour()]++
+		} else {
+			weekdayCounts[t.Hour()]++
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if last.IsZero() || t.After(last) {
+			last = t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("行の読み取りに失敗: %w", err)
+	}
+
+	if !first.IsZero() {
+		stats.WeekdayDays, stats.WeekendDays, stats.HolidayDays = countWeekparts(first, last, holidays)
+	}
+
+	average := func(count, days int) float64 {
+		if days == 0 {
+			return 0
+		}
+		return float64(count) / float64(days)
+	}
+	for hour := 0; hour < 24; hour++ {
+		stats.Weekday = append(stats.Weekday, HourlyAverage{
+			Hour:       hour,
+			Average:    average(weekdayCounts[hour], stats.WeekdayDays),
+			VisitCount: weekdayCounts[hour],
+		})
+		stats.Weekend = append(stats.Weekend, HourlyAverage{
+			Hour:       hour,
+			Average:    average(weekendCounts[hour], stats.WeekendDays),
+			VisitCount: weekendCounts[hour],
+		})
+	}
+	return stats, nil
+}
+
+// weekpartTitle は平日・週末別の時間帯統計の見出し
+func (s HourlySplitStats) weekpartTitle() string {
+	if s.HolidayDays > 0 {
+		return fmt.Sprintf("⏰ 時間帯別訪問数（1日平均: 平日%d日 / 週末%d日、祝日%d日を含む）", s.WeekdayDays, s.WeekendDays, s.HolidayDays)
+	}
+	return fmt.Sprintf("⏰ 時間帯別訪問数（1日平均: 平日%d日 / 週末%d日）", s.WeekdayDays, s.WeekendDays)
+}
+
+// printHourlySplit は平日・週末の時間帯別平均を並べて出力する
+func printHourlySplit(w io.Writer, stats HourlySplitStats) {
+	_, _ = fmt.Fprintf(w, "%s\n", stats.weekpartTitle())
+	_, _ = fmt.Fprintf(w, "─────────────────────────────────────────\n")
+	maxAverage := 0.0
+	for i := range stats.Weekday {
+		maxAverage = max(maxAverage, stats.Weekday[i].Average, stats.Weekend[i].Average)
+	}
+	bar := func(v float64) string {
+		barLen := 0
+		if maxAverage > 0 {
+			barLen = int(v / maxAverage * weekpartBarWidth)
+		}
+		return strings.Repeat("█", barLen) + strings.Repeat(" ", weekpartBarWidth-barLen)
+	}
+	// 見出しは全角2文字（表示幅4）なので、棒グラフの開始位置に合わせて空白を入れる
+	_, _ = fmt.Fprintf(w, "         平日%s週末\n", strings.Repeat(" ", weekpartBarWidth+1+6+2-4))
+	for i, wd := range stats.Weekday {
+		we := stats.Weekend[i]
+		_, _ = fmt.Fprintf(w, "  %02d:00  %s %6.1f  %s %6.1f\n", wd.Hour, bar(wd.Average), wd.Average, bar(we.Average), we.Average)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCountWeekparts は期間内の平日・週末日数のテスト
+func TestCountWeekparts(t *testing.T) {
+	tests := []struct {
+		name         string
+		from, to     time.Time
+		wantWeekdays int
+		wantWeekends int
+	}{
+		{"同じ日（水曜）", time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local), time.Date(2025, 1, 1, 23, 0, 0, 0, time.Local), 1, 0},
+		{"1週間", time.Date(2025, 1, 6, 12, 0, 0, 0, time.Local), time.Date(2025, 1, 12, 1, 0, 0, 0, time.Local), 5, 2},
+		{"土日のみ", time.Date(2025, 1, 4, 0, 0, 0, 0, time.Local), time.Date(2025, 1, 5, 0, 0, 0, 0, time.Local), 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weekdays, weekends, _ := countWeekparts(tt.from, tt.to, nil)
+			if weekdays != tt.wantWeekdays || weekends != tt.wantWeekends {
+				t.Errorf("countWeekparts() = (%d, %d), want (%d, %d)", weekdays, weekends, tt.wantWeekdays, tt.wantWeekends)
+			}
+		})
+	}
+
+	// 祝日（1/13 成人の日）は週末に数える
+	from, to := time.Date(2025, 1, 11, 0, 0, 0, 0, time.Local), time.Date(2025, 1, 14, 0, 0, 0, 0, time.Local)
+	if weekdays, weekends, dayOffs := countWeekparts(from, to, &Holidays{Japan: true}); weekdays != 1 || weekends != 3 || dayOffs != 1 {
+		t.Errorf("countWeekparts(祝日あり) = (%d, %d, %d), want (1, 3, 1)", weekdays, weekends, dayOffs)
+	}
+}
+
+// TestGetHourlySplitStats は平日・週末別の時間帯統計のテスト
+func TestGetHourlySplitStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Exec(`INSERT INTO history_items (id, url, domain_expansion, visit_count) VALUES (1, 'https://example.com', NULL, 4)`); err != nil {
+		t.Fatalf("history_items挿入に失敗: %v", err)
+	}
+	// 2025-01-03（金）〜01-06（月）: 平日2日・週末2日
+	visits := []time.Time{
+		time.Date(2025, 1, 3, 9, 0, 0, 0, time.Local),
+		time.Date(2025, 1, 6, 9, 30, 0, 0, time.Local),
+		time.Date(2025, 1, 4, 21, 0, 0, 0, time.Local),
+		time.Date(2025, 1, 5, 21, 0, 0, 0, time.Local),
+	}
+	for i, v := range visits {
+		_, err := db.Exec(`INSERT INTO history_visits (id, history_item, visit_time, title) VALUES (?, 1, ?, '')`,
+			i+1, convertToTimestamp(v))
+		if err != nil {
+			t.Fatalf("history_visits挿入に失敗: %v", err)
+		}
+	}
+
+	stats, err := getHourlySplitStats(db, SearchFilter{}, nil)
+	if err != nil {
+		t.Fatalf("getHourlySplitStats失敗: %v", err)
+	}
+
+	if stats.WeekdayDays != 2 || stats.WeekendDays != 2 {
+		t.Errorf("日数が期待値と異なる: 平日%d日 / 週末%d日", stats.WeekdayDays, stats.WeekendDays)
+	}
+	if len(stats.Weekday) != 24 || len(stats.Weekend) != 24 {
+		t.Fatalf("24時間分の系列がない: %d / %d", len(stats.Weekday), len(stats.Weekend))
+	}
+	if got := stats.Weekday[9]; got.VisitCount != 2 || got.Average != 1 {
+		t.Errorf("平日9時台が期待値と異なる: %+v", got)
+	}
+	if got := stats.Weekend[21]; got.VisitCount != 2 || got.Average != 1 {
+		t.Errorf("週末21時台が期待値と異なる: %+v", got)
+	}
+	if stats.Weekday[21].VisitCount != 0 || stats.Weekend[9].VisitCount != 0 {
+		t.Error("平日・週末の振り分けが正しくない")
+	}
+}